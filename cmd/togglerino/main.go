@@ -12,18 +12,29 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/compression"
 	"github.com/togglerino/togglerino/internal/config"
+	"github.com/togglerino/togglerino/internal/cors"
 	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/events"
 	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/impressions"
 	"github.com/togglerino/togglerino/internal/logging"
+	"github.com/togglerino/togglerino/internal/metrics"
 	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/quota"
 	"github.com/togglerino/togglerino/internal/ratelimit"
+	"github.com/togglerino/togglerino/internal/retention"
+	"github.com/togglerino/togglerino/internal/seed"
 	"github.com/togglerino/togglerino/internal/staleness"
 	"github.com/togglerino/togglerino/internal/store"
 	"github.com/togglerino/togglerino/internal/stream"
+	"github.com/togglerino/togglerino/internal/tracing"
 	"github.com/togglerino/togglerino/migrations"
 	"github.com/togglerino/togglerino/web"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func main() {
@@ -34,13 +45,24 @@ func main() {
 	}
 
 	// 1b. Set up structured logging
-	logging.Setup(cfg.LogFormat)
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+	logging.SetEvaluateSampleRate(cfg.EvaluateLogSampleRate)
 	slog.Info("starting togglerino", "port", cfg.Port)
 
 	// 2. Connect to database
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	defer cancelCtx()
-	pool, err := store.NewPool(ctx, cfg.DatabaseURL)
+	var tracerProvider *sdktrace.TracerProvider
+	var queryTracer pgx.QueryTracer
+	if cfg.TracingEnabled {
+		tracerProvider, err = tracing.Setup(ctx, cfg.OTLPEndpoint, cfg.TracingServiceName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		queryTracer = tracing.NewQueryTracer()
+		slog.Info("tracing enabled", "endpoint", cfg.OTLPEndpoint, "service", cfg.TracingServiceName)
+	}
+	pool, err := store.NewPool(ctx, cfg.DatabaseURL, queryTracer)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -53,6 +75,7 @@ func main() {
 	// 4. Initialize all stores
 	userStore := store.NewUserStore(pool)
 	sessionStore := store.NewSessionStore(pool)
+	sessionStore.SetIdleTimeout(time.Duration(cfg.SessionIdleTimeoutMinutes) * time.Minute)
 	inviteStore := store.NewInviteStore(pool)
 	projectStore := store.NewProjectStore(pool)
 	environmentStore := store.NewEnvironmentStore(pool)
@@ -61,44 +84,158 @@ func main() {
 	auditStore := store.NewAuditStore(pool)
 	projectSettingsStore := store.NewProjectSettingsStore(pool)
 	unknownFlagStore := store.NewUnknownFlagStore(pool)
+	flagCommentStore := store.NewFlagCommentStore(pool)
+	codeReferenceStore := store.NewCodeReferenceStore(pool)
+	globalSettingsStore := store.NewGlobalSettingsStore(pool)
 
 	// 5. Initialize cache, engine, hub
 	cache := evaluation.NewCache()
+	cache.SetInListSetThreshold(cfg.InListSetThreshold)
 	engine := evaluation.NewEngine()
+	engine.SetAnonymousBucketing(evaluation.AnonymousBucketing(cfg.AnonymousBucketing), nil)
 	hub := stream.NewHub()
+	hub.SetMaxSubscribers(cfg.MaxStreamSubscribers)
 	cacheRefresher := cacheRefreshFunc(func(ctx context.Context) error {
 		return cache.LoadAll(ctx, pool)
 	})
 	stalenessChecker := staleness.NewChecker(flagStore, projectSettingsStore, auditStore, cacheRefresher, 1*time.Hour)
+	stalenessChecker.SetFullRolloutThreshold(flagStore, time.Duration(cfg.FullRolloutStaleDays)*24*time.Hour)
+	stalenessChecker.SetGlobalSettings(globalSettingsStore)
+	stalenessChecker.SetDeletedFlagRetention(flagStore, time.Duration(cfg.DeletedFlagRetentionDays)*24*time.Hour)
+	stalenessChecker.SetUsageWindow(time.Duration(cfg.StaleUsageWindowDays) * 24 * time.Hour)
+	if cfg.StaleDigestWebhookURL != "" {
+		stalenessChecker.SetNotifier(staleness.NewWebhookNotifier(cfg.StaleDigestWebhookURL))
+	}
+
+	// Seed flag defaults from environment variables (opt-in)
+	if cfg.SeedFlagDefaults {
+		seeder := seed.NewSeeder(flagStore, environmentStore, auditStore)
+		applied, err := seeder.Apply(ctx, seed.ParseEnv(os.Environ()))
+		if err != nil {
+			log.Fatalf("failed to seed flag defaults: %v", err)
+		}
+		slog.Info("seeded flag defaults from environment", "applied", applied)
+	}
 
 	// 6. Load all flags into cache
 	if err := cache.LoadAll(ctx, pool); err != nil {
 		log.Fatalf("failed to load flags into cache: %v", err)
 	}
 	go stalenessChecker.Run(ctx)
+	auditPruner := retention.NewPruner(auditStore, projectStore, projectSettingsStore, time.Duration(cfg.AuditRetentionDays)*24*time.Hour, 1*time.Hour)
+	auditPruner.SetSDKKeys(sdkKeyStore)
+	go auditPruner.Run(ctx)
+
+	// Evaluation quotas: counts are batched in memory and flushed periodically
+	usageStore := store.NewEvaluationUsageStore(pool)
+	quotaTracker := quota.NewTracker(usageStore, 10*time.Second)
+	if err := quotaTracker.Load(ctx); err != nil {
+		log.Fatalf("failed to load evaluation quotas: %v", err)
+	}
+	go quotaTracker.Run(ctx)
+
+	// Flag usage: last evaluation times are sampled and flushed periodically
+	usageTracker := staleness.NewUsageTracker(flagStore, time.Minute)
+	go usageTracker.Run(ctx)
+
+	// Variant evaluation counts: batched in memory and flushed periodically
+	variantEvaluationStore := store.NewVariantEvaluationStore(pool)
+	impressionTracker := impressions.NewTracker(variantEvaluationStore, 10*time.Second)
+	go impressionTracker.Run(ctx)
 
 	// 7. Initialize all handlers
 	authHandler := handler.NewAuthHandler(userStore, sessionStore, inviteStore)
+	authHandler.SetSessionDuration(time.Duration(cfg.SessionDurationHours) * time.Hour)
+	passwordPolicy := auth.PasswordPolicy{MinLength: cfg.PasswordMinLength, RejectCommon: cfg.PasswordRejectCommon}
+	if err := passwordPolicy.RequireClasses(cfg.PasswordRequiredClasses); err != nil {
+		log.Fatal(err)
+	}
+	authHandler.SetPasswordPolicy(passwordPolicy)
+	if cfg.OIDCIssuerURL != "" {
+		authHandler.SetOIDC(auth.NewOIDCProvider(auth.OIDCConfig{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		}), cfg.OIDCAdminEmails)
+	}
 	userHandler := handler.NewUserHandler(userStore, inviteStore)
 	projectHandler := handler.NewProjectHandler(projectStore, environmentStore, auditStore)
-	environmentHandler := handler.NewEnvironmentHandler(environmentStore, projectStore)
+	projectHandler.SetDefaultEnvironments(cfg.DefaultEnvironments)
+	environmentHandler := handler.NewEnvironmentHandler(environmentStore, projectStore, sdkKeyStore, auditStore, cache)
 	sdkKeyHandler := handler.NewSDKKeyHandler(sdkKeyStore, environmentStore, projectStore)
 	flagHandler := handler.NewFlagHandler(flagStore, projectStore, environmentStore, auditStore, hub, cache, pool, unknownFlagStore)
+	flagHandler.SetChangeApproval(projectSettingsStore, store.NewChangeRequestStore(pool))
 	auditHandler := handler.NewAuditHandler(auditStore, projectStore)
-	projectSettingsHandler := handler.NewProjectSettingsHandler(projectSettingsStore, projectStore)
+	projectSettingsHandler := handler.NewProjectSettingsHandler(projectSettingsStore, projectStore, auditStore, cache)
 	contextAttributeStore := store.NewContextAttributeStore(pool)
 	contextAttributeHandler := handler.NewContextAttributeHandler(contextAttributeStore, projectStore)
 	evaluateHandler := handler.NewEvaluateHandler(cache, engine, unknownFlagStore, contextAttributeStore)
+	evaluateHandler.SetMinPollTTL(cfg.MinPollTTLSeconds)
+	if cfg.EvalResultCacheSize > 0 {
+		evaluateHandler.SetResultCache(evaluation.NewResultCache(cfg.EvalResultCacheSize, time.Duration(cfg.EvalResultCacheTTLSeconds)*time.Second))
+		slog.Info("caching evaluation results", "size", cfg.EvalResultCacheSize, "ttl_seconds", cfg.EvalResultCacheTTLSeconds)
+	}
+	var eventSink events.Sink = events.NoopSink{}
+	if cfg.EventSink == "nats" {
+		natsSink, err := events.NewNATSSink(cfg.NATSURL, cfg.EventSubject)
+		if err != nil {
+			log.Fatal(err)
+		}
+		eventSink = events.NewAsyncSink(natsSink, cfg.EventBufferSize)
+		slog.Info("publishing evaluation events", "sink", cfg.EventSink, "subject", cfg.EventSubject)
+	}
+	evaluateHandler.SetEventSink(eventSink)
+	evaluateHandler.SetQuotaTracker(quotaTracker)
+	evaluateHandler.SetUsageTracker(usageTracker)
+	evaluateHandler.SetImpressionTracker(impressionTracker)
+	metricsRegistry := metrics.NewRegistry()
+	metricsRegistry.SetHub(hub)
+	evaluateHandler.SetMetrics(metricsRegistry)
+	usageHandler := handler.NewUsageHandler(usageStore, quotaTracker, projectStore, environmentStore, auditStore)
 	unknownFlagHandler := handler.NewUnknownFlagHandler(unknownFlagStore, projectStore)
 	streamHandler := handler.NewStreamHandler(hub)
+	streamHandler.SetKeepaliveInterval(time.Duration(cfg.StreamKeepaliveSeconds) * time.Second)
+	flagCommentHandler := handler.NewFlagCommentHandler(flagCommentStore, flagStore, projectStore)
+	flagStatsHandler := handler.NewFlagStatsHandler(variantEvaluationStore, flagStore, projectStore)
+	ruleTemplateHandler := handler.NewRuleTemplateHandler(store.NewRuleTemplateStore(pool), projectStore, auditStore)
+	dryRunHandler := handler.NewDryRunHandler(cache, engine, projectStore, environmentStore)
+	segmentHandler := handler.NewSegmentHandler(store.NewSegmentStore(pool), projectStore, environmentStore, auditStore, hub, cache, pool)
+	userOverrideHandler := handler.NewUserOverrideHandler(store.NewUserOverrideStore(pool), flagStore, projectStore, environmentStore, auditStore, hub, cache, pool)
 
 	// 8. Set up HTTP router
 	mux := http.NewServeMux()
 
 	// Middleware closures
-	sessionAuth := auth.SessionAuth(sessionStore, userStore)
+	// Management routes accept a session cookie or a personal access token;
+	// tokens can only be managed from a browser session.
+	tokenStore := store.NewPersonalAccessTokenStore(pool)
+	tokenHandler := handler.NewTokenHandler(tokenStore)
+	sessionOnly := auth.SessionAuth(sessionStore, userStore)
+	sessionAuth := auth.ManagementAuth(sessionStore, userStore, tokenStore)
+	membershipStore := store.NewProjectMembershipStore(pool)
+	projectRole := auth.ProjectRoleAuth(membershipStore)
+	projectHandler.SetMemberships(membershipStore)
+	flagHandler.SetMemberships(membershipStore)
+	flagHandler.SetCodeReferences(codeReferenceStore)
+	projectMemberHandler := handler.NewProjectMemberHandler(membershipStore, projectStore, userStore)
 	sdkAuth := auth.SDKAuth(sdkKeyStore)
-	authLimiter := ratelimit.New(10, 60) // 10 requests per minute
+	authLimiter := ratelimit.New(model.DefaultAuthRateLimitPerMinute, 60) // per minute, adjustable in global settings
+	go authLimiter.Run(ctx)
+	// Evaluate requests are limited per SDK key, so one noisy client can't
+	// starve others behind the same NAT.
+	sdkLimit := func(next http.Handler) http.Handler { return next }
+	var sdkLimiter *ratelimit.Limiter
+	if cfg.SDKRateLimitPerSecond > 0 {
+		sdkLimiter = ratelimit.NewTokenBucket(float64(cfg.SDKRateLimitPerSecond), cfg.SDKRateLimitBurstOrRate())
+		sdkLimiter.SetKeyFunc(ratelimit.KeyBySDKKey)
+		go sdkLimiter.Run(ctx)
+		sdkLimit = sdkLimiter.Middleware
+	}
+	globalSettingsHandler := handler.NewGlobalSettingsHandler(globalSettingsStore, auditStore, authLimiter)
+	if err := globalSettingsHandler.Load(ctx); err != nil {
+		slog.Warn("failed to load global settings, using defaults", "error", err)
+	}
 
 	// --- Public routes (no auth) ---
 	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -106,15 +243,23 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
 	})
+	mux.HandleFunc("GET /readyz", handler.NewHealthHandler(pool, cache).Ready)
+	mux.Handle("GET /metrics", metricsRegistry.Handler())
 	mux.HandleFunc("GET /api/v1/auth/status", authHandler.Status)
 	mux.Handle("POST /api/v1/auth/setup", authLimiter.Middleware(http.HandlerFunc(authHandler.Setup)))
 	mux.Handle("POST /api/v1/auth/login", authLimiter.Middleware(http.HandlerFunc(authHandler.Login)))
 	mux.HandleFunc("POST /api/v1/auth/logout", authHandler.Logout)
 	mux.Handle("POST /api/v1/auth/accept-invite", authLimiter.Middleware(http.HandlerFunc(authHandler.AcceptInvite)))
 	mux.Handle("POST /api/v1/auth/reset-password", authLimiter.Middleware(http.HandlerFunc(authHandler.ResetPassword)))
+	mux.Handle("GET /api/v1/auth/oidc/login", authLimiter.Middleware(http.HandlerFunc(authHandler.OIDCLogin)))
+	mux.Handle("GET /api/v1/auth/oidc/callback", authLimiter.Middleware(http.HandlerFunc(authHandler.OIDCCallback)))
 
 	// --- Session-authed routes (management API) ---
 	mux.Handle("GET /api/v1/auth/me", wrap(authHandler.Me, sessionAuth))
+	mux.Handle("POST /api/v1/auth/refresh", wrap(authHandler.Refresh, sessionOnly))
+	mux.Handle("POST /api/v1/auth/tokens", wrap(tokenHandler.Create, sessionOnly))
+	mux.Handle("GET /api/v1/auth/tokens", wrap(tokenHandler.List, sessionOnly))
+	mux.Handle("DELETE /api/v1/auth/tokens/{id}", wrap(tokenHandler.Revoke, sessionOnly))
 
 	// User management (admin-only)
 	requireAdmin := auth.RequireRole(model.RoleAdmin)
@@ -123,51 +268,112 @@ func main() {
 	mux.Handle("GET /api/v1/management/users/invites", wrap(userHandler.ListInvites, sessionAuth, requireAdmin))
 	mux.Handle("DELETE /api/v1/management/users/{id}", wrap(userHandler.Delete, sessionAuth, requireAdmin))
 	mux.Handle("POST /api/v1/management/users/{id}/reset-password", wrap(http.HandlerFunc(userHandler.ResetPassword), sessionAuth, requireAdmin))
+	mux.Handle("GET /api/v1/admin/settings", wrap(globalSettingsHandler.Get, sessionAuth, requireAdmin))
+	mux.Handle("PUT /api/v1/admin/settings", wrap(globalSettingsHandler.Update, sessionAuth, requireAdmin))
 
 	// Projects
 	mux.Handle("POST /api/v1/projects", wrap(projectHandler.Create, sessionAuth))
 	mux.Handle("GET /api/v1/projects", wrap(projectHandler.List, sessionAuth))
 	mux.Handle("GET /api/v1/projects/{key}", wrap(projectHandler.Get, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}", wrap(projectHandler.Update, sessionAuth))
+	mux.Handle("PUT /api/v1/projects/{key}", wrap(projectHandler.Update, sessionAuth, projectRole))
 	mux.Handle("DELETE /api/v1/projects/{key}", wrap(projectHandler.Delete, sessionAuth, requireAdmin))
+	mux.Handle("GET /api/v1/projects/{key}/export", wrap(flagHandler.ExportProject, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/import", wrap(flagHandler.ImportProject, sessionAuth))
+
+	// Project members (changing roles needs project admin)
+	mux.Handle("GET /api/v1/projects/{key}/members", wrap(projectMemberHandler.List, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/members/{user}", wrap(projectMemberHandler.Set, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/members/{user}", wrap(projectMemberHandler.Delete, sessionAuth, projectRole))
 
 	// Environments
-	mux.Handle("POST /api/v1/projects/{key}/environments", wrap(environmentHandler.Create, sessionAuth))
-	mux.Handle("GET /api/v1/projects/{key}/environments", wrap(environmentHandler.List, sessionAuth))
+	mux.Handle("POST /api/v1/projects/{key}/environments", wrap(environmentHandler.Create, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/environments", wrap(environmentHandler.List, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/environments/reorder", wrap(environmentHandler.Reorder, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/environments/{env}", wrap(environmentHandler.Update, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/environments/{env}", wrap(environmentHandler.Delete, sessionAuth, projectRole))
 
 	// SDK Keys
-	mux.Handle("POST /api/v1/projects/{key}/environments/{env}/sdk-keys", wrap(sdkKeyHandler.Create, sessionAuth))
-	mux.Handle("GET /api/v1/projects/{key}/environments/{env}/sdk-keys", wrap(sdkKeyHandler.List, sessionAuth))
-	mux.Handle("DELETE /api/v1/projects/{key}/environments/{env}/sdk-keys/{id}", wrap(sdkKeyHandler.Revoke, sessionAuth))
+	mux.Handle("GET /api/v1/projects/{key}/environments/{env}/usage", wrap(usageHandler.Get, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/environments/{env}/quota", wrap(usageHandler.SetQuota, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/environments/{env}/sdk-keys", wrap(sdkKeyHandler.Create, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/environments/{env}/sdk-keys", wrap(sdkKeyHandler.List, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/environments/{env}/sdk-keys/{id}", wrap(sdkKeyHandler.Revoke, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/environments/{env}/sdk-keys/{id}/rotate", wrap(sdkKeyHandler.Rotate, sessionAuth, projectRole))
 
 	// Flags
-	mux.Handle("POST /api/v1/projects/{key}/flags", wrap(flagHandler.Create, sessionAuth))
-	mux.Handle("GET /api/v1/projects/{key}/flags", wrap(flagHandler.List, sessionAuth))
-	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Get, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Update, sessionAuth))
-	mux.Handle("DELETE /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Delete, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/archive", wrap(flagHandler.Archive, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/staleness", wrap(flagHandler.SetStaleness, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/environments/{env}", wrap(flagHandler.UpdateEnvironmentConfig, sessionAuth))
+	mux.Handle("POST /api/v1/projects/{key}/flags", wrap(flagHandler.Create, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags", wrap(flagHandler.List, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags/cleanup-report", wrap(flagHandler.CleanupReport, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/bulk", wrap(flagHandler.Bulk, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/bulk-tag", wrap(flagHandler.BulkTag, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Get, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Update, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/flags/{flag}", wrap(flagHandler.Delete, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/restore", wrap(flagHandler.Restore, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/code-references", wrap(flagHandler.UploadCodeReferences, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/clone", wrap(flagHandler.Clone, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/toggle-all", wrap(flagHandler.ToggleAll, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}/stats", wrap(flagStatsHandler.Get, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}/dependents", wrap(flagHandler.Dependents, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/archive", wrap(flagHandler.Archive, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/staleness", wrap(flagHandler.SetStaleness, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/flags/{flag}/environments/{env}", wrap(flagHandler.UpdateEnvironmentConfig, sessionAuth, projectRole))
+	mux.Handle("PATCH /api/v1/projects/{key}/flags/{flag}/environments/{env}/enabled", wrap(flagHandler.SetEnvironmentEnabled, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}/environments/{from}/diff/{to}", wrap(flagHandler.PromotionDiff, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/promote/{from}/to/{to}", wrap(flagHandler.Promote, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/environments/{env}/validate", wrap(flagHandler.ValidateEnvironmentConfig, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/environments/{env}/rules/{index}/test", wrap(flagHandler.TestRule, sessionAuth, projectRole))
+
+	// Change requests for approval-gated environments
+	mux.Handle("GET /api/v1/projects/{key}/change-requests", wrap(flagHandler.ListChangeRequests, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/change-requests/{id}/approve", wrap(flagHandler.ApproveChangeRequest, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/change-requests/{id}/reject", wrap(flagHandler.RejectChangeRequest, sessionAuth, projectRole))
+
+	// Flag comments
+	mux.Handle("GET /api/v1/projects/{key}/flags/{flag}/comments", wrap(flagCommentHandler.List, sessionAuth))
+	mux.Handle("POST /api/v1/projects/{key}/flags/{flag}/comments", wrap(flagCommentHandler.Create, sessionAuth))
+
+	// Rule templates
+	mux.Handle("GET /api/v1/projects/{key}/rule-templates", wrap(ruleTemplateHandler.List, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/rule-templates", wrap(ruleTemplateHandler.Create, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/rule-templates/{template}", wrap(ruleTemplateHandler.Get, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/rule-templates/{template}", wrap(ruleTemplateHandler.Update, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/rule-templates/{template}", wrap(ruleTemplateHandler.Delete, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/rule-templates/{template}/instantiate", wrap(ruleTemplateHandler.Instantiate, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/environments/{env}/evaluate-test", wrap(dryRunHandler.Evaluate, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/segments", wrap(segmentHandler.List, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/segments", wrap(segmentHandler.Create, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/segments/{segment}", wrap(segmentHandler.Get, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/segments/{segment}", wrap(segmentHandler.Update, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/segments/{segment}", wrap(segmentHandler.Delete, sessionAuth, projectRole))
+	mux.Handle("GET /api/v1/projects/{key}/users/{user}/overrides", wrap(userOverrideHandler.List, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/users/{user}/overrides/{flag}/environments/{env}", wrap(userOverrideHandler.Set, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/users/{user}/overrides/{flag}/environments/{env}", wrap(userOverrideHandler.Clear, sessionAuth, projectRole))
 
 	// Unknown flags
-	mux.Handle("GET /api/v1/projects/{key}/unknown-flags", wrap(unknownFlagHandler.List, sessionAuth))
-	mux.Handle("DELETE /api/v1/projects/{key}/unknown-flags/{id}", wrap(unknownFlagHandler.Dismiss, sessionAuth))
+	mux.Handle("GET /api/v1/projects/{key}/unknown-flags", wrap(unknownFlagHandler.List, sessionAuth, projectRole))
+	mux.Handle("DELETE /api/v1/projects/{key}/unknown-flags/{id}", wrap(unknownFlagHandler.Dismiss, sessionAuth, projectRole))
+	mux.Handle("POST /api/v1/projects/{key}/unknown-flags/{id}/create", wrap(flagHandler.CreateFromUnknown, sessionAuth, projectRole))
 
 	// Audit log
 	mux.Handle("GET /api/v1/projects/{key}/audit-log", wrap(auditHandler.List, sessionAuth))
+	mux.Handle("GET /api/v1/projects/{key}/audit-log/export", wrap(auditHandler.Export, sessionAuth))
 
 	// Project settings (flag lifetimes)
-	mux.Handle("GET /api/v1/projects/{key}/settings/flags", wrap(projectSettingsHandler.Get, sessionAuth))
-	mux.Handle("PUT /api/v1/projects/{key}/settings/flags", wrap(projectSettingsHandler.Update, sessionAuth))
+	mux.Handle("GET /api/v1/projects/{key}/settings/flags", wrap(projectSettingsHandler.Get, sessionAuth, projectRole))
+	mux.Handle("PUT /api/v1/projects/{key}/settings/flags", wrap(projectSettingsHandler.Update, sessionAuth, projectRole))
 
 	// Context attributes
 	mux.Handle("GET /api/v1/projects/{key}/context-attributes", wrap(contextAttributeHandler.List, sessionAuth))
 
 	// --- SDK-authed routes (client API) ---
-	mux.Handle("POST /api/v1/evaluate", wrap(evaluateHandler.EvaluateAll, sdkAuth))
-	mux.Handle("POST /api/v1/evaluate/{flag}", wrap(evaluateHandler.EvaluateSingle, sdkAuth))
-	mux.Handle("GET /api/v1/stream", wrap(streamHandler.Handle, sdkAuth))
+	evaluateScope := auth.RequireSDKScope(model.SDKKeyScopeEvaluate)
+	mux.Handle("POST /api/v1/evaluate", wrap(evaluateHandler.EvaluateAll, sdkAuth, evaluateScope, sdkLimit))
+	mux.Handle("POST /api/v1/evaluate/{flag}", wrap(evaluateHandler.EvaluateSingle, sdkAuth, evaluateScope, sdkLimit))
+	mux.Handle("GET /api/v1/evaluate/{project}/{env}", wrap(evaluateHandler.EvaluateQuery, sdkAuth, evaluateScope, sdkLimit))
+	mux.Handle("POST /api/v1/evaluate/{project}/{env}/batch", wrap(evaluateHandler.EvaluateBatch, sdkAuth, evaluateScope, sdkLimit))
+	mux.Handle("GET /api/v1/rules", wrap(evaluateHandler.Rules, sdkAuth, auth.RequireSDKScope(model.SDKKeyScopeServer)))
+	mux.Handle("GET /api/v1/stream", wrap(streamHandler.Handle, sdkAuth, auth.RequireSDKScope(model.SDKKeyScopeStream)))
 
 	// Serve the embedded React dashboard
 	distFS, err := fs.Sub(web.DistFS, "dist")
@@ -197,15 +403,25 @@ func main() {
 		fileServer.ServeHTTP(w, r)
 	})
 
-	// Start server with logging and CORS middleware
+	// Start server with logging, compression and CORS middleware
 	slog.Info("cors configured", "origins", cfg.CORSOrigins)
 	slog.Info("listening", "addr", cfg.Addr())
 
+	corsPolicy := cors.NewPolicy(corsConfig(cfg))
+	serverHandler := logging.Middleware(compression.Middleware(compression.DefaultMinSize)(corsPolicy.Middleware(mux)))
+	if cfg.TracingEnabled {
+		serverHandler = tracing.Middleware(serverHandler)
+	}
 	srv := &http.Server{
 		Addr:    cfg.Addr(),
-		Handler: logging.Middleware(corsMiddleware(cfg.CORSOrigins, mux)),
+		Handler: serverHandler,
 	}
 
+	// Shutdown waits for in-flight requests, and SSE streams never finish on
+	// their own: drain the hub as shutdown starts so streams end with a
+	// closing comment and clients reconnect to another instance.
+	srv.RegisterOnShutdown(func() { hub.Drain(streamDrainGrace) })
+
 	// Start listening in a goroutine so we can wait for shutdown signals.
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -213,6 +429,15 @@ func main() {
 		}
 	}()
 
+	// SIGHUP reloads the settings that are safe to change while serving.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(cfg, corsPolicy, sdkLimiter)
+		}
+	}()
+
 	// Wait for SIGINT or SIGTERM.
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -228,12 +453,63 @@ func main() {
 	}
 
 	cancelCtx()
+	quotaTracker.Flush(context.Background())
+	usageTracker.Flush(context.Background())
+	impressionTracker.Flush(context.Background())
 	hub.Close()
+	if err := eventSink.Close(); err != nil {
+		slog.Warn("failed to close event sink", "error", err)
+	}
 	pool.Close()
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(context.Background()); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}
 
 	slog.Info("server stopped")
 }
 
+// corsConfig returns the CORS settings of cfg.
+func corsConfig(cfg *config.Config) cors.Config {
+	return cors.Config{
+		Origins:       cfg.CORSOrigins,
+		Methods:       cfg.CORSMethods,
+		Headers:       cfg.CORSHeaders,
+		MaxAgeSeconds: cfg.CORSMaxAgeSeconds,
+	}
+}
+
+// reloadConfig re-reads the configuration and applies the CORS settings, log
+// format, level and evaluate sampling, and SDK rate limit without restarting.
+// Everything else, such as the port or database, and enabling or disabling the
+// SDK rate limit, needs a restart; a configuration that fails to load leaves
+// the running one untouched.
+func reloadConfig(current *config.Config, corsPolicy *cors.Policy, sdkLimiter *ratelimit.Limiter) {
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("config reload failed, keeping the current config", "error", err)
+		return
+	}
+
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+	logging.SetEvaluateSampleRate(cfg.EvaluateLogSampleRate)
+	corsPolicy.Set(corsConfig(cfg))
+	if (sdkLimiter != nil) != (cfg.SDKRateLimitPerSecond > 0) {
+		slog.Warn("enabling or disabling the SDK rate limit requires a restart")
+	} else if sdkLimiter != nil {
+		sdkLimiter.SetRate(float64(cfg.SDKRateLimitPerSecond), cfg.SDKRateLimitBurstOrRate())
+	}
+	if cfg.Port != current.Port || cfg.DatabaseURL != current.DatabaseURL {
+		slog.Warn("port and database changes require a restart")
+	}
+	slog.Info("config reloaded", "cors_origins", cfg.CORSOrigins, "log_format", cfg.LogFormat, "log_level", cfg.LogLevel, "evaluate_log_sample_rate", cfg.EvaluateLogSampleRate)
+}
+
+// streamDrainGrace is how long shutdown gives SSE clients to disconnect after
+// being told the server is closing, well within the shutdown timeout.
+const streamDrainGrace = 3 * time.Second
+
 // wrap applies middleware to a handler function.
 func wrap(h http.HandlerFunc, middlewares ...func(http.Handler) http.Handler) http.Handler {
 	var handler http.Handler = h
@@ -248,50 +524,3 @@ func wrap(h http.HandlerFunc, middlewares ...func(http.Handler) http.Handler) ht
 type cacheRefreshFunc func(ctx context.Context) error
 
 func (f cacheRefreshFunc) LoadAll(ctx context.Context) error { return f(ctx) }
-
-// corsMiddleware adds CORS headers based on the configured allowed origins.
-// If origins contains only "*", all origins are allowed. Otherwise, the
-// request's Origin header is checked against the whitelist.
-func corsMiddleware(origins []string, next http.Handler) http.Handler {
-	allowAll := len(origins) == 1 && origins[0] == "*"
-
-	// Build a set for fast lookup when not allowing all.
-	allowed := make(map[string]struct{}, len(origins))
-	if !allowAll {
-		for _, o := range origins {
-			allowed[o] = struct{}{}
-		}
-	}
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
-
-		if allowAll {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-		} else if origin != "" {
-			if _, ok := allowed[origin]; ok {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Add("Vary", "Origin")
-			} else {
-				// Origin not in whitelist — don't set any CORS headers.
-				if r.Method == "OPTIONS" {
-					w.WriteHeader(http.StatusForbidden)
-					return
-				}
-				next.ServeHTTP(w, r)
-				return
-			}
-		}
-
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
@@ -2,7 +2,10 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
@@ -10,14 +13,31 @@ import (
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey        contextKey = "user"
+	projectRoleContextKey contextKey = "project_role"
+)
 
 func UserFromContext(ctx context.Context) *model.User {
 	u, _ := ctx.Value(userContextKey).(*model.User)
 	return u
 }
 
-// SessionAuth middleware checks for a valid session cookie and loads the user.
+// ProjectRoleFromContext returns the caller's role in the request's project.
+// ok is false when ProjectRoleAuth did not run for the route.
+func ProjectRoleFromContext(ctx context.Context) (role model.ProjectRole, ok bool) {
+	role, ok = ctx.Value(projectRoleContextKey).(model.ProjectRole)
+	return role, ok
+}
+
+// ContextWithProjectRole returns a copy of ctx carrying role, as
+// ProjectRoleAuth stores it.
+func ContextWithProjectRole(ctx context.Context, role model.ProjectRole) context.Context {
+	return context.WithValue(ctx, projectRoleContextKey, role)
+}
+
+// SessionAuth middleware checks for a valid session cookie, records activity
+// on the session and loads the user.
 func SessionAuth(sessions *store.SessionStore, users *store.UserStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -33,6 +53,11 @@ func SessionAuth(sessions *store.SessionStore, users *store.UserStore) func(http
 				return
 			}
 
+			// Best-effort: a failed write only shortens the idle window.
+			if err := sessions.Touch(r.Context(), session.ID); err != nil {
+				slog.Warn("failed to record session activity", "error", err)
+			}
+
 			user, err := users.FindByID(r.Context(), session.UserID)
 			if err != nil {
 				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
@@ -45,6 +70,75 @@ func SessionAuth(sessions *store.SessionStore, users *store.UserStore) func(http
 	}
 }
 
+// ManagementAuth middleware accepts either a personal access token in an
+// "Authorization: Bearer pat_..." header or a session cookie, and loads the
+// owning user. Tokens act with the owner's current role.
+func ManagementAuth(sessions *store.SessionStore, users *store.UserStore, tokens *store.PersonalAccessTokenStore) func(http.Handler) http.Handler {
+	withSession := SessionAuth(sessions, users)
+	return func(next http.Handler) http.Handler {
+		sessionNext := withSession(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !strings.HasPrefix(bearer, store.PersonalAccessTokenPrefix) {
+				sessionNext.ServeHTTP(w, r)
+				return
+			}
+
+			token, err := tokens.Authenticate(r.Context(), bearer)
+			if err != nil {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			user, err := users.FindByID(r.Context(), token.UserID)
+			if err != nil {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ProjectRoleAuth middleware loads the authenticated user's role in the
+// project named by the {key} path value. Global admins are project admins
+// everywhere and users without a membership are viewers. Handlers enforce the
+// role they need. Must run after SessionAuth or ManagementAuth.
+func ProjectRoleAuth(memberships *store.ProjectMembershipStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := UserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+
+			role, err := ResolveProjectRole(r.Context(), memberships, user, r.PathValue("key"))
+			if err != nil {
+				http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithProjectRole(r.Context(), role)))
+		})
+	}
+}
+
+// ResolveProjectRole returns a user's role in a project, applying the same
+// rules as ProjectRoleAuth.
+func ResolveProjectRole(ctx context.Context, memberships *store.ProjectMembershipStore, user *model.User, projectKey string) (model.ProjectRole, error) {
+	if user.Role == model.RoleAdmin {
+		return model.ProjectRoleAdmin, nil
+	}
+	role, err := memberships.RoleFor(ctx, user.ID, projectKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return model.ProjectRoleViewer, nil
+	}
+	return role, err
+}
+
 // RequireRole middleware checks that the authenticated user has the required role.
 func RequireRole(role model.Role) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEmailNotVerified is returned by Exchange unless the provider reports the
+// user's email address as verified.
+var ErrEmailNotVerified = errors.New("oidc: email not verified")
+
+// OIDCConfig holds the client registration for an OpenID Connect provider.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// OIDCIdentity is the identity returned by the provider after login. Issuer
+// and Subject together identify the user; Email is only a contact address.
+type OIDCIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+}
+
+// OIDCProvider runs the authorization code flow against a provider. Endpoints
+// are discovered from the issuer on first use and cached.
+type OIDCProvider struct {
+	config     OIDCConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// NewOIDCProvider creates a provider for the given client registration.
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{config: config, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NewOIDCState returns a random value for the state parameter.
+func NewOIDCState() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the provider URL the browser is redirected to for login.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.config.ClientID},
+		"redirect_uri":  {p.config.RedirectURL},
+		"scope":         {"openid email"},
+		"state":         {state},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for an access token and looks up the
+// user's identity at the userinfo endpoint. The identity comes straight from
+// the provider over TLS, so no ID token signature check is needed.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string) (*OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.config.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("exchanging code: no access token in response")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, d.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating userinfo request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err := p.doJSON(req, &info); err != nil {
+		return nil, fmt.Errorf("fetching userinfo: %w", err)
+	}
+	if info.Subject == "" {
+		return nil, errors.New("fetching userinfo: no sub claim")
+	}
+	if info.Email == "" {
+		return nil, errors.New("fetching userinfo: no email claim")
+	}
+	// A missing claim counts as unverified
+	if info.EmailVerified == nil || !*info.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	return &OIDCIdentity{
+		Issuer:  strings.TrimSuffix(p.config.IssuerURL, "/"),
+		Subject: info.Subject,
+		Email:   info.Email,
+	}, nil
+}
+
+// discover fetches and caches the provider's metadata document.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating discovery request: %w", err)
+	}
+	var d oidcDiscovery
+	if err := p.doJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("discovering oidc provider: %w", err)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, errors.New("discovering oidc provider: metadata is missing required endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
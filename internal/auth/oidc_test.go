@@ -0,0 +1,134 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+)
+
+// fakeOIDCServer serves discovery, token and userinfo endpoints. The token
+// endpoint accepts only code "good-code" from client "client"/"secret".
+func fakeOIDCServer(t *testing.T, userinfo map[string]any) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"authorization_endpoint": srv.URL + "/authorize",
+				"token_endpoint":         srv.URL + "/token",
+				"userinfo_endpoint":      srv.URL + "/userinfo",
+			})
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			if r.Method != http.MethodPost || id != "client" || secret != "secret" || r.FormValue("code") != "good-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "token-123", "token_type": "Bearer"})
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer token-123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(userinfo)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestProvider(issuer string) *auth.OIDCProvider {
+	return auth.NewOIDCProvider(auth.OIDCConfig{
+		IssuerURL:    issuer,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://flags.example.com/api/v1/auth/oidc/callback",
+	})
+}
+
+func TestOIDCProvider_AuthCodeURL(t *testing.T) {
+	srv := fakeOIDCServer(t, nil)
+	p := newTestProvider(srv.URL + "/")
+
+	raw, err := p.AuthCodeURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", raw, err)
+	}
+	if u.Path != "/authorize" {
+		t.Errorf("expected authorize endpoint, got %q", u.Path)
+	}
+	q := u.Query()
+	for key, want := range map[string]string{
+		"response_type": "code",
+		"client_id":     "client",
+		"redirect_uri":  "https://flags.example.com/api/v1/auth/oidc/callback",
+		"scope":         "openid email",
+		"state":         "state-1",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	srv := fakeOIDCServer(t, map[string]any{"sub": "abc", "email": "dana@example.com", "email_verified": true})
+	p := newTestProvider(srv.URL)
+
+	identity, err := p.Exchange(context.Background(), "good-code")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Email != "dana@example.com" || identity.Subject != "abc" || identity.Issuer != srv.URL {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	if _, err := p.Exchange(context.Background(), "bad-code"); err == nil {
+		t.Error("expected error for a rejected code")
+	}
+}
+
+func TestOIDCProvider_ExchangeRejectsUnverifiedEmail(t *testing.T) {
+	for name, userinfo := range map[string]map[string]any{
+		"false":   {"sub": "abc", "email": "dana@example.com", "email_verified": false},
+		"missing": {"sub": "abc", "email": "dana@example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newTestProvider(fakeOIDCServer(t, userinfo).URL)
+			if _, err := p.Exchange(context.Background(), "good-code"); !errors.Is(err, auth.ErrEmailNotVerified) {
+				t.Errorf("expected ErrEmailNotVerified, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_ExchangeRequiresEmail(t *testing.T) {
+	srv := fakeOIDCServer(t, map[string]any{"sub": "abc"})
+	p := newTestProvider(srv.URL)
+
+	if _, err := p.Exchange(context.Background(), "good-code"); err == nil {
+		t.Error("expected error when userinfo has no email")
+	}
+}
+
+func TestOIDCProvider_ExchangeRequiresSubject(t *testing.T) {
+	srv := fakeOIDCServer(t, map[string]any{"email": "dana@example.com", "email_verified": true})
+	p := newTestProvider(srv.URL)
+
+	if _, err := p.Exchange(context.Background(), "good-code"); err == nil {
+		t.Error("expected error when userinfo has no sub")
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy describes the passwords accepted when users choose one.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// RejectCommon rejects passwords on the embedded common-password list.
+	RejectCommon bool
+}
+
+// DefaultPasswordPolicy only requires a length of 8 characters.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+// PasswordCharacterClasses are the names accepted by RequireClasses.
+var PasswordCharacterClasses = []string{"upper", "lower", "digit", "symbol"}
+
+// RequireClasses sets the policy's character class requirements from names
+// such as "upper" or "digit".
+func (p *PasswordPolicy) RequireClasses(classes []string) error {
+	for _, c := range classes {
+		switch c {
+		case "upper":
+			p.RequireUpper = true
+		case "lower":
+			p.RequireLower = true
+		case "digit":
+			p.RequireDigit = true
+		case "symbol":
+			p.RequireSymbol = true
+		default:
+			return fmt.Errorf("unknown character class %q: must be one of %s", c, strings.Join(PasswordCharacterClasses, ", "))
+		}
+	}
+	return nil
+}
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			m[line] = true
+		}
+	}
+	return m
+}()
+
+// ValidatePassword checks password against policy. The error describes the
+// first rule the password breaks and is suitable for showing to the user.
+func ValidatePassword(password string, policy PasswordPolicy) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case policy.RequireUpper && !upper:
+		return errors.New("password must contain an uppercase letter")
+	case policy.RequireLower && !lower:
+		return errors.New("password must contain a lowercase letter")
+	case policy.RequireDigit && !digit:
+		return errors.New("password must contain a digit")
+	case policy.RequireSymbol && !symbol:
+		return errors.New("password must contain a symbol")
+	}
+
+	if policy.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		return errors.New("password is too common")
+	}
+	return nil
+}
@@ -20,3 +20,50 @@ func TestHashAndVerifyPassword(t *testing.T) {
 		t.Error("VerifyPassword returned true for wrong password")
 	}
 }
+
+func TestValidatePassword(t *testing.T) {
+	strict := auth.PasswordPolicy{MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, RejectCommon: true}
+	tests := []struct {
+		name     string
+		password string
+		policy   auth.PasswordPolicy
+		wantErr  string
+	}{
+		{"default accepts 8 characters", "abcdefgh", auth.DefaultPasswordPolicy, ""},
+		{"default rejects short", "abcdefg", auth.DefaultPasswordPolicy, "password must be at least 8 characters"},
+		{"length counts characters not bytes", "ünïcødé!", auth.DefaultPasswordPolicy, ""},
+		{"missing uppercase", "correct-horse-42", strict, "password must contain an uppercase letter"},
+		{"missing lowercase", "CORRECT-HORSE-42", strict, "password must contain a lowercase letter"},
+		{"missing digit", "Correct-Horse-Battery", strict, "password must contain a digit"},
+		{"missing symbol", "CorrectHorse42", strict, "password must contain a symbol"},
+		{"common password", "Password123", auth.PasswordPolicy{MinLength: 8, RejectCommon: true}, "password is too common"},
+		{"strong password", "Correct-Horse-42", strict, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.ValidatePassword(tt.password, tt.policy)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected %q to pass, got %v", tt.password, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy_RequireClasses(t *testing.T) {
+	var p auth.PasswordPolicy
+	if err := p.RequireClasses([]string{"upper", "digit"}); err != nil {
+		t.Fatalf("RequireClasses: %v", err)
+	}
+	if !p.RequireUpper || !p.RequireDigit || p.RequireLower || p.RequireSymbol {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+	if err := p.RequireClasses([]string{"emoji"}); err == nil {
+		t.Error("expected unknown class to be rejected")
+	}
+}
@@ -2,8 +2,10 @@ package auth
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
@@ -19,8 +21,15 @@ func SDKKeyFromContext(ctx context.Context) *model.SDKKey {
 	return k
 }
 
+// ContextWithSDKKey returns a copy of ctx carrying the SDK key, as SDKAuth
+// stores it.
+func ContextWithSDKKey(ctx context.Context, sdkKey *model.SDKKey) context.Context {
+	return context.WithValue(ctx, sdkKeyContextKey, sdkKey)
+}
+
 // SDKAuth middleware reads the Authorization: Bearer <sdk_key> header,
-// looks up the SDK key, and injects it into the context.
+// looks up the SDK key, records that it was used, and injects it into the
+// context.
 func SDKAuth(sdkKeys *store.SDKKeyStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -37,8 +46,30 @@ func SDKAuth(sdkKeys *store.SDKKeyStore) func(http.Handler) http.Handler {
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), sdkKeyContextKey, sdkKey)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// Best-effort, and skipped while the recorded use is recent so
+			// most requests cost no write.
+			if sdkKey.LastUsedAt == nil || time.Since(*sdkKey.LastUsedAt) >= store.SDKKeyTouchInterval {
+				if err := sdkKeys.Touch(r.Context(), sdkKey.ID); err != nil {
+					slog.Warn("failed to record SDK key use", "error", err)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithSDKKey(r.Context(), sdkKey)))
+		})
+	}
+}
+
+// RequireSDKScope middleware rejects requests whose SDK key, injected by
+// SDKAuth, lacks scope with 403.
+func RequireSDKScope(scope model.SDKKeyScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sdkKey := SDKKeyFromContext(r.Context())
+			if sdkKey == nil || !sdkKey.HasScope(scope) {
+				http.Error(w, `{"error":"SDK key lacks the `+string(scope)+` scope"}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
 	}
 }
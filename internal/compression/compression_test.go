@@ -0,0 +1,104 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func largeJSON() string {
+	var b strings.Builder
+	b.WriteString(`{"flags": {`)
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `"flag-%d": {"value": true, "variant": "on", "reason": "default"}`, i)
+	}
+	b.WriteString("}}")
+	return b.String()
+}
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+	})
+}
+
+func serve(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Middleware(DefaultMinSize)(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_CompressesLargeResponses(t *testing.T) {
+	body := largeJSON()
+	rec := serve(jsonHandler(body), "br, gzip")
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzip-encoded 200, got %d with encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Errorf("expected the compressed body to be smaller than %d bytes, got %d", len(body), rec.Body.Len())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("opening gzip body: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil || string(decoded) != body {
+		t.Errorf("decoded body does not match the original (err %v)", err)
+	}
+}
+
+func TestMiddleware_LeavesOtherResponsesAlone(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		acceptEncoding string
+	}{
+		{"small response", jsonHandler(`{"ok": true}`), "gzip"},
+		{"gzip not accepted", jsonHandler(largeJSON()), ""},
+		{"gzip refused", jsonHandler(largeJSON()), "gzip;q=0, identity"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.handler, tt.acceptEncoding)
+		if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s: expected no Content-Encoding, got %q", tt.name, enc)
+		}
+		if !strings.HasPrefix(rec.Body.String(), "{") {
+			t.Errorf("%s: expected the plain JSON body, got %q", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestMiddleware_DoesNotCompressEventStreams(t *testing.T) {
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, ": connected\n\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, strings.Repeat("data: {}\n\n", 500))
+	})
+	rec := serve(stream, "gzip")
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected the stream to stay uncompressed, got Content-Encoding %q", enc)
+	}
+	if !rec.Flushed {
+		t.Error("expected the stream flush to reach the client")
+	}
+	if !strings.HasPrefix(rec.Body.String(), ": connected") {
+		t.Errorf("expected plain stream events, got %q", rec.Body.String()[:20])
+	}
+}
@@ -0,0 +1,147 @@
+// Package cors adds Cross-Origin Resource Sharing headers to HTTP responses.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Config controls which cross-origin requests are allowed.
+type Config struct {
+	// Origins lists the allowed origins. A single "*" allows every origin;
+	// otherwise entries match exactly, or as "scheme://*.domain" for any
+	// subdomain of domain (not domain itself).
+	Origins []string
+	// Methods and Headers are sent as Access-Control-Allow-Methods and
+	// Access-Control-Allow-Headers.
+	Methods []string
+	Headers []string
+	// MaxAgeSeconds is sent as Access-Control-Max-Age on preflight
+	// responses so browsers cache them. Zero or less omits the header.
+	MaxAgeSeconds int
+}
+
+// Middleware adds CORS headers based on cfg. Requests from origins outside
+// the allowlist get no CORS headers, and their preflight requests get 403.
+func Middleware(cfg Config, next http.Handler) http.Handler {
+	return NewPolicy(cfg).Middleware(next)
+}
+
+// Policy holds a CORS config that can be replaced while its middleware
+// serves requests, e.g. when the configuration is reloaded.
+type Policy struct {
+	current atomic.Pointer[compiled]
+}
+
+// compiled is a Config prepared for matching.
+type compiled struct {
+	allowAll      bool
+	allowed       map[string]struct{}
+	wildcards     []wildcardOrigin
+	methods       string
+	headers       string
+	maxAgeSeconds int
+}
+
+// NewPolicy creates a policy enforcing cfg.
+func NewPolicy(cfg Config) *Policy {
+	p := &Policy{}
+	p.Set(cfg)
+	return p
+}
+
+// Set replaces the config. Requests already past the middleware keep the
+// headers they were given; every later request uses cfg.
+func (p *Policy) Set(cfg Config) {
+	c := &compiled{
+		allowAll:      len(cfg.Origins) == 1 && cfg.Origins[0] == "*",
+		methods:       strings.Join(cfg.Methods, ", "),
+		headers:       strings.Join(cfg.Headers, ", "),
+		maxAgeSeconds: cfg.MaxAgeSeconds,
+	}
+	// Build a set for fast lookup when not allowing all.
+	c.allowed = make(map[string]struct{}, len(cfg.Origins))
+	if !c.allowAll {
+		for _, o := range cfg.Origins {
+			if w, ok := parseWildcard(o); ok {
+				c.wildcards = append(c.wildcards, w)
+				continue
+			}
+			c.allowed[o] = struct{}{}
+		}
+	}
+	p.current.Store(c)
+}
+
+// Middleware adds CORS headers based on the policy's current config, read
+// once per request.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := p.current.Load()
+		origin := r.Header.Get("Origin")
+
+		if c.allowAll {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if origin != "" {
+			if originAllowed(origin, c.allowed, c.wildcards) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			} else {
+				// Origin not in whitelist — don't set any CORS headers.
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", c.methods)
+		w.Header().Set("Access-Control-Allow-Headers", c.headers)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions {
+			if c.maxAgeSeconds > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(c.maxAgeSeconds))
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wildcardOrigin is an allowlist entry of the form "scheme://*.domain",
+// split around the "*".
+type wildcardOrigin struct {
+	prefix string // "scheme://"
+	suffix string // ".domain", including any port
+}
+
+func parseWildcard(origin string) (wildcardOrigin, bool) {
+	prefix, suffix, ok := strings.Cut(origin, "*.")
+	if !ok || !strings.HasSuffix(prefix, "://") || suffix == "" {
+		return wildcardOrigin{}, false
+	}
+	return wildcardOrigin{prefix: prefix, suffix: "." + suffix}, true
+}
+
+func originAllowed(origin string, allowed map[string]struct{}, wildcards []wildcardOrigin) bool {
+	if _, ok := allowed[origin]; ok {
+		return true
+	}
+	for _, w := range wildcards {
+		if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+		sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+		if sub != "" && !strings.ContainsAny(sub, "/:@?#") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testConfig(origins ...string) Config {
+	return Config{
+		Origins:       origins,
+		Methods:       []string{"GET", "POST", "OPTIONS"},
+		Headers:       []string{"Content-Type", "Authorization"},
+		MaxAgeSeconds: 600,
+	}
+}
+
+func serve(cfg Config, method, origin string) *httptest.ResponseRecorder {
+	h := Middleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(method, "/api/v1/evaluate", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_ExactAndWildcardOrigins(t *testing.T) {
+	cfg := testConfig("https://app.example.org", "https://*.example.com")
+
+	for _, origin := range []string{"https://app.example.org", "https://app.example.com", "https://a.b.example.com"} {
+		rec := serve(cfg, http.MethodGet, origin)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", origin, origin, got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
+			t.Errorf("%s: expected configured methods, got %q", origin, got)
+		}
+	}
+}
+
+func TestMiddleware_NonMatchingOriginGetsNoHeaders(t *testing.T) {
+	cfg := testConfig("https://*.example.com")
+
+	for _, origin := range []string{"https://example.com", "http://app.example.com", "https://evil.com", "https://example.com.evil.com", "https://x.evil.com/.example.com"} {
+		rec := serve(cfg, http.MethodGet, origin)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected the request to be served, got %d", origin, rec.Code)
+		}
+		for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Credentials"} {
+			if got := rec.Header().Get(header); got != "" {
+				t.Errorf("%s: expected no %s, got %q", origin, header, got)
+			}
+		}
+		if rec := serve(cfg, http.MethodOptions, origin); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected preflight status %d, got %d", origin, http.StatusForbidden, rec.Code)
+		}
+	}
+}
+
+func TestMiddleware_PreflightMaxAge(t *testing.T) {
+	rec := serve(testConfig("*"), http.MethodOptions, "https://anywhere.test")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("expected 200 with Access-Control-Max-Age 600, got %d %q", rec.Code, rec.Header().Get("Access-Control-Max-Age"))
+	}
+	if rec := serve(testConfig("*"), http.MethodGet, "https://anywhere.test"); rec.Header().Get("Access-Control-Max-Age") != "" {
+		t.Error("expected Access-Control-Max-Age only on preflight responses")
+	}
+
+	cfg := testConfig("*")
+	cfg.MaxAgeSeconds = 0
+	if rec := serve(cfg, http.MethodOptions, "https://anywhere.test"); rec.Header().Get("Access-Control-Max-Age") != "" {
+		t.Error("expected no Access-Control-Max-Age when disabled")
+	}
+}
+
+func TestPolicy_SetSwapsOrigins(t *testing.T) {
+	policy := NewPolicy(testConfig("https://old.example.org"))
+	h := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	allowedOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/evaluate", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if got := allowedOrigin("https://old.example.org"); got != "https://old.example.org" {
+		t.Fatalf("expected the initial origin to be allowed, got %q", got)
+	}
+
+	policy.Set(testConfig("https://new.example.org"))
+	if got := allowedOrigin("https://new.example.org"); got != "https://new.example.org" {
+		t.Errorf("expected the new origin to be allowed after Set, got %q", got)
+	}
+	if got := allowedOrigin("https://old.example.org"); got != "" {
+		t.Errorf("expected the old origin to be rejected after Set, got %q", got)
+	}
+}
@@ -3,14 +3,23 @@ package evaluation
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// Querier is the part of *pgxpool.Pool the cache loads data through.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 // FlagData holds everything needed to evaluate a flag.
 type FlagData struct {
 	Flag   model.Flag
@@ -22,15 +31,68 @@ type Cache struct {
 	mu sync.RWMutex
 	// Key: "projectKey:envKey", Value: map of flagKey -> FlagData
 	data map[string]map[string]FlagData
+	// inListSetThreshold is the list length at which in/not_in conditions
+	// are converted to sets when flags are loaded.
+	inListSetThreshold int
+	// caseInsensitive holds the project keys whose flag keys are matched
+	// case-insensitively by GetFlag.
+	caseInsensitive map[string]bool
+	// segments holds segment conditions keyed by project key, then segment
+	// key, used to resolve in_segment conditions when flags are loaded.
+	segments map[string]map[string][]model.Condition
+	// loadedAt is when LoadAll last succeeded; zero until then.
+	loadedAt time.Time
+	// generation is bumped whenever cached flag data changes, so results
+	// derived from it can be invalidated.
+	generation atomic.Uint64
+}
+
+// CacheStats describes what the cache holds, for readiness checks.
+type CacheStats struct {
+	// Scopes is the number of project/environment pairs with flags loaded.
+	Scopes int `json:"scopes"`
+	// LoadedAt is when all flags were last loaded; nil before the first load.
+	LoadedAt *time.Time `json:"loaded_at"`
 }
 
 // NewCache creates a new empty cache.
 func NewCache() *Cache {
 	return &Cache{
-		data: make(map[string]map[string]FlagData),
+		data:               make(map[string]map[string]FlagData),
+		inListSetThreshold: DefaultInListSetThreshold,
+		caseInsensitive:    make(map[string]bool),
+		segments:           make(map[string]map[string][]model.Condition),
 	}
 }
 
+// SetInListSetThreshold configures the minimum list length at which in/not_in
+// conditions are preprocessed into sets. Zero or less disables preprocessing.
+// It applies to flags loaded after the call.
+func (c *Cache) SetInListSetThreshold(n int) {
+	c.mu.Lock()
+	c.inListSetThreshold = n
+	c.mu.Unlock()
+}
+
+// SetCaseInsensitiveKeys enables or disables case-insensitive flag key
+// matching in GetFlag for a project.
+func (c *Cache) SetCaseInsensitiveKeys(projectKey string, enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enabled {
+		c.caseInsensitive[projectKey] = true
+	} else {
+		delete(c.caseInsensitive, projectKey)
+	}
+}
+
+// threshold returns the configured in-list set threshold.
+func (c *Cache) threshold() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.inListSetThreshold
+}
+
 // cacheKey builds the composite key for the cache map.
 func cacheKey(projectKey, envKey string) string {
 	return projectKey + ":" + envKey
@@ -40,17 +102,20 @@ const baseFlagQuery = `
 SELECT
     p.key AS project_key,
     e.key AS env_key,
-    f.id, f.project_id, f.key, f.name, f.description, f.value_type, f.flag_type, f.default_value, f.tags, f.lifecycle_status, f.lifecycle_status_changed_at, f.created_at, f.updated_at,
-    fec.id, fec.flag_id, fec.environment_id, fec.enabled, fec.default_variant, fec.variants, fec.targeting_rules, fec.updated_at
+    f.id, f.project_id, f.key, f.name, f.description, f.value_type, f.flag_type, f.default_value, f.tags, f.poll_ttl_seconds, f.require_identifier, f.lifecycle_status, f.lifecycle_status_changed_at, f.created_at, f.updated_at,
+    fec.id, fec.flag_id, fec.environment_id, fec.enabled, fec.default_variant, fec.variants, fec.targeting_rules, fec.prerequisites, fec.default_rollout, fec.included_users, fec.excluded_users, fec.included_variant, fec.updated_at,
+    COALESCE((SELECT jsonb_object_agg(o.user_id, o.variant) FROM flag_user_overrides o
+              WHERE o.flag_id = f.id AND o.environment_id = fec.environment_id), '{}'::jsonb)
 FROM flags f
 JOIN projects p ON p.id = f.project_id
 JOIN flag_environment_configs fec ON fec.flag_id = f.id
 JOIN environments e ON e.id = fec.environment_id
+WHERE f.deleted_at IS NULL
 `
 
 // LoadAll loads all flags and their environment configs from the database.
 // Called once on startup.
-func (c *Cache) LoadAll(ctx context.Context, pool *pgxpool.Pool) error {
+func (c *Cache) LoadAll(ctx context.Context, pool Querier) error {
 	rows, err := pool.Query(ctx, baseFlagQuery)
 	if err != nil {
 		return fmt.Errorf("cache LoadAll query: %w", err)
@@ -58,12 +123,18 @@ func (c *Cache) LoadAll(ctx context.Context, pool *pgxpool.Pool) error {
 	defer rows.Close()
 
 	newData := make(map[string]map[string]FlagData)
+	threshold := c.threshold()
+	segments, err := loadSegments(ctx, pool, "", threshold)
+	if err != nil {
+		return err
+	}
 
 	for rows.Next() {
 		projectKey, envKey, fd, err := scanFlagRow(rows)
 		if err != nil {
 			return fmt.Errorf("cache LoadAll scan: %w", err)
 		}
+		prepareConfig(&fd.Config, threshold, segments[projectKey])
 		key := cacheKey(projectKey, envKey)
 		if newData[key] == nil {
 			newData[key] = make(map[string]FlagData)
@@ -75,45 +146,156 @@ func (c *Cache) LoadAll(ctx context.Context, pool *pgxpool.Pool) error {
 		return fmt.Errorf("cache LoadAll rows: %w", err)
 	}
 
+	caseInsensitive, err := loadCaseInsensitiveProjects(ctx, pool)
+	if err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	c.data = newData
+	c.caseInsensitive = caseInsensitive
+	c.segments = segments
+	c.loadedAt = time.Now()
+	c.generation.Add(1)
 	c.mu.Unlock()
 
 	return nil
 }
 
+// loadCaseInsensitiveProjects returns the keys of projects that have
+// case-insensitive flag key matching enabled in their settings.
+func loadCaseInsensitiveProjects(ctx context.Context, pool Querier) (map[string]bool, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT p.key FROM projects p
+		 JOIN project_settings ps ON ps.project_id = p.id
+		 WHERE (ps.settings->>'case_insensitive_flag_keys')::boolean`)
+	if err != nil {
+		return nil, fmt.Errorf("cache LoadAll project settings query: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("cache LoadAll project settings scan: %w", err)
+		}
+		result[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache LoadAll project settings rows: %w", err)
+	}
+	return result, nil
+}
+
 // Refresh reloads flag data for a specific project/environment from the database.
-// Called after a flag is updated.
-func (c *Cache) Refresh(ctx context.Context, pool *pgxpool.Pool, projectKey, envKey string) error {
-	query := baseFlagQuery + " WHERE p.key = $1 AND e.key = $2"
+// Called after a flag is updated. The cached flags are only replaced once the
+// whole result has been read: if any query fails the previous data stays in
+// place and the error is returned, while a successful query with no rows
+// empties the scope.
+func (c *Cache) Refresh(ctx context.Context, pool Querier, projectKey, envKey string) error {
+	threshold := c.threshold()
+	segments, err := loadSegments(ctx, pool, projectKey, threshold)
+	if err != nil {
+		return err
+	}
+	flags, err := loadFlags(ctx, pool, projectKey, envKey)
+	if err != nil {
+		return err
+	}
+	for k, fd := range flags {
+		prepareConfig(&fd.Config, threshold, segments[projectKey])
+		flags[k] = fd
+	}
+
+	key := cacheKey(projectKey, envKey)
+	c.mu.Lock()
+	c.data[key] = flags
+	c.segments[projectKey] = segments[projectKey]
+	c.generation.Add(1)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// loadFlags reads every flag of a project/environment. It returns an error
+// rather than a partial result if reading fails part way through.
+func loadFlags(ctx context.Context, pool Querier, projectKey, envKey string) (map[string]FlagData, error) {
+	query := baseFlagQuery + " AND p.key = $1 AND e.key = $2"
 	rows, err := pool.Query(ctx, query, projectKey, envKey)
 	if err != nil {
-		return fmt.Errorf("cache Refresh query: %w", err)
+		return nil, fmt.Errorf("cache Refresh query: %w", err)
 	}
 	defer rows.Close()
 
 	flags := make(map[string]FlagData)
-
 	for rows.Next() {
 		_, _, fd, err := scanFlagRow(rows)
 		if err != nil {
-			return fmt.Errorf("cache Refresh scan: %w", err)
+			return nil, fmt.Errorf("cache Refresh scan: %w", err)
 		}
 		flags[fd.Flag.Key] = fd
 	}
-
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("cache Refresh rows: %w", err)
+		return nil, fmt.Errorf("cache Refresh rows: %w", err)
+	}
+	return flags, nil
+}
+
+// RefreshFlag reloads a single flag for a project/environment from the
+// database, leaving the other cached flags as they are. A flag that no
+// longer exists is removed. Segments are taken from the cache, so segment
+// changes still need a full Refresh.
+func (c *Cache) RefreshFlag(ctx context.Context, pool Querier, projectKey, envKey, flagKey string) error {
+	query := baseFlagQuery + " AND p.key = $1 AND e.key = $2 AND f.key = $3"
+	_, _, fd, err := scanFlagRow(pool.QueryRow(ctx, query, projectKey, envKey, flagKey))
+	found := true
+	if errors.Is(err, pgx.ErrNoRows) {
+		found = false
+	} else if err != nil {
+		return fmt.Errorf("cache RefreshFlag query: %w", err)
 	}
 
 	key := cacheKey(projectKey, envKey)
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	if found {
+		prepareConfig(&fd.Config, c.inListSetThreshold, c.segments[projectKey])
+	}
+	// GetFlags hands out the map without holding the lock, so it is copied
+	// rather than modified in place.
+	flags := make(map[string]FlagData, len(c.data[key])+1)
+	for k, v := range c.data[key] {
+		flags[k] = v
+	}
+	if found {
+		flags[flagKey] = fd
+	} else {
+		delete(flags, flagKey)
+	}
 	c.data[key] = flags
-	c.mu.Unlock()
-
+	c.generation.Add(1)
 	return nil
 }
 
+// Stats returns the number of loaded scopes and when flags were last loaded.
+func (c *Cache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats := CacheStats{Scopes: len(c.data)}
+	if !c.loadedAt.IsZero() {
+		loadedAt := c.loadedAt
+		stats.LoadedAt = &loadedAt
+	}
+	return stats
+}
+
+// Generation returns a counter that changes whenever any cached flag data is
+// loaded, refreshed, set or evicted.
+func (c *Cache) Generation() uint64 {
+	return c.generation.Load()
+}
+
 // GetFlags returns all flag data for a project/environment.
 // Returns nil if the project/environment combination is not found.
 func (c *Cache) GetFlags(projectKey, envKey string) map[string]FlagData {
@@ -124,6 +306,9 @@ func (c *Cache) GetFlags(projectKey, envKey string) map[string]FlagData {
 }
 
 // GetFlag returns a single flag's data for a project/environment.
+// If the project has case-insensitive flag keys enabled and there is no exact
+// match, the key is compared in lowercase against every flag; a key matching
+// more than one flag this way is treated as not found.
 func (c *Cache) GetFlag(projectKey, envKey, flagKey string) (FlagData, bool) {
 	key := cacheKey(projectKey, envKey)
 	c.mu.RLock()
@@ -132,18 +317,96 @@ func (c *Cache) GetFlag(projectKey, envKey, flagKey string) (FlagData, bool) {
 	if flags == nil {
 		return FlagData{}, false
 	}
-	fd, ok := flags[flagKey]
-	return fd, ok
+	if fd, ok := flags[flagKey]; ok {
+		return fd, true
+	}
+	if !c.caseInsensitive[projectKey] {
+		return FlagData{}, false
+	}
+
+	lower := strings.ToLower(flagKey)
+	var match FlagData
+	found := false
+	for k, fd := range flags {
+		if strings.ToLower(k) != lower {
+			continue
+		}
+		if found {
+			return FlagData{}, false
+		}
+		match, found = fd, true
+	}
+	return match, found
 }
 
 // Set directly sets flag data for a project/environment (useful for testing).
+// Configs are preprocessed the same way as flags loaded from the database.
 func (c *Cache) Set(projectKey, envKey string, flags map[string]FlagData) {
 	key := cacheKey(projectKey, envKey)
+	threshold := c.threshold()
+	c.mu.RLock()
+	segments := c.segments[projectKey]
+	c.mu.RUnlock()
+	for k, fd := range flags {
+		prepareConfig(&fd.Config, threshold, segments)
+		flags[k] = fd
+	}
 	c.mu.Lock()
 	c.data[key] = flags
+	c.generation.Add(1)
 	c.mu.Unlock()
 }
 
+// Prepare preprocesses a config read straight from the database the way
+// cached configs are, resolving in_segment conditions against the project's
+// cached segments, so it can be passed to the engine or MatchesRule.
+func (c *Cache) Prepare(projectKey string, cfg *model.FlagEnvironmentConfig) {
+	threshold := c.threshold()
+	c.mu.RLock()
+	segments := c.segments[projectKey]
+	c.mu.RUnlock()
+	prepareConfig(cfg, threshold, segments)
+}
+
+// Evict removes a project/environment from the cache, e.g. after the
+// environment was deleted.
+func (c *Cache) Evict(projectKey, envKey string) {
+	c.mu.Lock()
+	delete(c.data, cacheKey(projectKey, envKey))
+	c.generation.Add(1)
+	c.mu.Unlock()
+}
+
+// SetSegments directly sets a project's segments, keyed by segment key
+// (useful for testing). It applies to flags set or loaded after the call.
+func (c *Cache) SetSegments(projectKey string, segments map[string][]model.Condition) {
+	c.mu.Lock()
+	c.segments[projectKey] = segments
+	c.mu.Unlock()
+}
+
+// GetSegments returns a project's segment conditions keyed by segment key.
+// Returns nil if the project has no segments.
+func (c *Cache) GetSegments(projectKey string) map[string][]model.Condition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.segments[projectKey]
+}
+
+// FlagsUsingSegment returns the keys of flags in a project/environment whose
+// targeting rules reference the segment.
+func (c *Cache) FlagsUsingSegment(projectKey, envKey, segmentKey string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	for flagKey, fd := range c.data[cacheKey(projectKey, envKey)] {
+		if usesSegment(&fd.Config, segmentKey) {
+			keys = append(keys, flagKey)
+		}
+	}
+	return keys
+}
+
 // rowScanner is an interface satisfied by pgx.Rows for scanning a single row.
 type rowScanner interface {
 	Scan(dest ...any) error
@@ -154,6 +417,9 @@ func scanFlagRow(row rowScanner) (projectKey, envKey string, fd FlagData, err er
 	var (
 		variantsJSON       []byte
 		targetingRulesJSON []byte
+		prerequisitesJSON  []byte
+		defaultRolloutJSON []byte
+		overridesJSON      []byte
 		fecUpdatedAt       time.Time
 	)
 
@@ -170,6 +436,8 @@ func scanFlagRow(row rowScanner) (projectKey, envKey string, fd FlagData, err er
 		&fd.Flag.FlagType,
 		&fd.Flag.DefaultValue,
 		&fd.Flag.Tags,
+		&fd.Flag.PollTTLSeconds,
+		&fd.Flag.RequireIdentifier,
 		&fd.Flag.LifecycleStatus,
 		&fd.Flag.LifecycleStatusChangedAt,
 		&fd.Flag.CreatedAt,
@@ -182,7 +450,13 @@ func scanFlagRow(row rowScanner) (projectKey, envKey string, fd FlagData, err er
 		&fd.Config.DefaultVariant,
 		&variantsJSON,
 		&targetingRulesJSON,
+		&prerequisitesJSON,
+		&defaultRolloutJSON,
+		&fd.Config.IncludedUsers,
+		&fd.Config.ExcludedUsers,
+		&fd.Config.IncludedVariant,
 		&fecUpdatedAt,
+		&overridesJSON,
 	)
 	if err != nil {
 		return "", "", FlagData{}, err
@@ -202,5 +476,23 @@ func scanFlagRow(row rowScanner) (projectKey, envKey string, fd FlagData, err er
 		}
 	}
 
+	if len(prerequisitesJSON) > 0 {
+		if err := json.Unmarshal(prerequisitesJSON, &fd.Config.Prerequisites); err != nil {
+			return "", "", FlagData{}, fmt.Errorf("unmarshal prerequisites: %w", err)
+		}
+	}
+
+	if len(defaultRolloutJSON) > 0 {
+		if err := json.Unmarshal(defaultRolloutJSON, &fd.Config.DefaultRollout); err != nil {
+			return "", "", FlagData{}, fmt.Errorf("unmarshal default_rollout: %w", err)
+		}
+	}
+
+	if len(overridesJSON) > 0 {
+		if err := json.Unmarshal(overridesJSON, &fd.Config.UserOverrides); err != nil {
+			return "", "", FlagData{}, fmt.Errorf("unmarshal user overrides: %w", err)
+		}
+	}
+
 	return projectKey, envKey, fd, nil
 }
@@ -1,10 +1,14 @@
 package evaluation_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/togglerino/togglerino/internal/evaluation"
 	"github.com/togglerino/togglerino/internal/model"
 )
@@ -57,6 +61,47 @@ func TestCache_GetFlag(t *testing.T) {
 	}
 }
 
+func TestCache_GetFlag_CaseInsensitive(t *testing.T) {
+	c := evaluation.NewCache()
+	c.Set("web-app", "production", map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode"}},
+	})
+
+	if _, ok := c.GetFlag("web-app", "production", "Dark-Mode"); ok {
+		t.Error("expected case-sensitive lookup by default")
+	}
+
+	c.SetCaseInsensitiveKeys("web-app", true)
+	fd, ok := c.GetFlag("web-app", "production", "Dark-Mode")
+	if !ok {
+		t.Fatal("expected Dark-Mode to resolve dark-mode")
+	}
+	if fd.Flag.Key != "dark-mode" {
+		t.Errorf("got key %q, want canonical dark-mode", fd.Flag.Key)
+	}
+
+	c.SetCaseInsensitiveKeys("web-app", false)
+	if _, ok := c.GetFlag("web-app", "production", "Dark-Mode"); ok {
+		t.Error("expected case-sensitive lookup after disabling")
+	}
+}
+
+func TestCache_GetFlag_CaseInsensitiveAmbiguous(t *testing.T) {
+	c := evaluation.NewCache()
+	c.SetCaseInsensitiveKeys("web-app", true)
+	c.Set("web-app", "production", map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode"}},
+		"Dark-Mode": {Flag: model.Flag{Key: "Dark-Mode"}},
+	})
+
+	if fd, ok := c.GetFlag("web-app", "production", "Dark-Mode"); !ok || fd.Flag.Key != "Dark-Mode" {
+		t.Errorf("expected exact match to win, got %q (found=%v)", fd.Flag.Key, ok)
+	}
+	if _, ok := c.GetFlag("web-app", "production", "DARK-MODE"); ok {
+		t.Error("expected ambiguous case-insensitive match to be treated as not found")
+	}
+}
+
 func TestCache_GetFlags_Empty(t *testing.T) {
 	c := evaluation.NewCache()
 	got := c.GetFlags("no-project", "no-env")
@@ -164,3 +209,121 @@ func TestCache_ConcurrentReadWrite(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestCache_SetResolvesSegments(t *testing.T) {
+	c := evaluation.NewCache()
+	c.SetSegments("web-app", map[string][]model.Condition{
+		"beta": {{Attribute: "beta", Operator: "equals", Value: "yes"}},
+	})
+	rule := model.TargetingRule{Conditions: []model.Condition{{Operator: "in_segment", Value: "beta"}}, Variant: "on"}
+	c.Set("web-app", "production", map[string]evaluation.FlagData{
+		"segmented": {
+			Flag:   model.Flag{Key: "segmented"},
+			Config: model.FlagEnvironmentConfig{Enabled: true, TargetingRules: []model.TargetingRule{rule}},
+		},
+		"plain": {
+			Flag:   model.Flag{Key: "plain"},
+			Config: model.FlagEnvironmentConfig{Enabled: true},
+		},
+	})
+
+	fd, _ := c.GetFlag("web-app", "production", "segmented")
+	if _, ok := fd.Config.TargetingRules[0].Conditions[0].Value.(*evaluation.SegmentRef); !ok {
+		t.Errorf("expected in_segment value to be resolved, got %T", fd.Config.TargetingRules[0].Conditions[0].Value)
+	}
+
+	using := c.FlagsUsingSegment("web-app", "production", "beta")
+	if len(using) != 1 || using[0] != "segmented" {
+		t.Errorf("FlagsUsingSegment: got %v, want [segmented]", using)
+	}
+}
+
+// fakeQuerier serves segment queries with no rows and fails flag queries
+// either up front (queryErr) or part way through reading rows (rowsErr).
+type fakeQuerier struct {
+	queryErr error
+	rowsErr  error
+}
+
+func (q fakeQuerier) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	if strings.Contains(sql, "FROM segments") {
+		return &fakeRows{}, nil
+	}
+	if q.queryErr != nil {
+		return nil, q.queryErr
+	}
+	return &fakeRows{err: q.rowsErr}, nil
+}
+
+func (q fakeQuerier) QueryRow(context.Context, string, ...any) pgx.Row {
+	return nil
+}
+
+// fakeRows is an empty result set whose Err reports err.
+type fakeRows struct {
+	pgx.Rows
+	err error
+}
+
+func (r *fakeRows) Next() bool { return false }
+func (r *fakeRows) Err() error { return r.err }
+func (r *fakeRows) Close()     {}
+
+func TestCache_RefreshKeepsDataOnError(t *testing.T) {
+	tests := []struct {
+		name      string
+		querier   fakeQuerier
+		wantErr   bool
+		wantFlags int
+	}{
+		{"query fails", fakeQuerier{queryErr: errors.New("connection reset")}, true, 1},
+		{"rows fail mid-read", fakeQuerier{rowsErr: errors.New("connection reset")}, true, 1},
+		{"no flags", fakeQuerier{}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := evaluation.NewCache()
+			c.Set("web-app", "production", map[string]evaluation.FlagData{
+				"dark-mode": {Flag: model.Flag{Key: "dark-mode"}},
+			})
+
+			err := c.Refresh(context.Background(), tt.querier, "web-app", "production")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Refresh error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(c.GetFlags("web-app", "production")); got != tt.wantFlags {
+				t.Errorf("expected %d cached flags, got %d", tt.wantFlags, got)
+			}
+		})
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	c := evaluation.NewCache()
+	if stats := c.Stats(); stats.Scopes != 0 || stats.LoadedAt != nil {
+		t.Errorf("expected an empty, never loaded cache, got %+v", stats)
+	}
+
+	c.Set("proj", "prod", map[string]evaluation.FlagData{"a": {Flag: model.Flag{Key: "a"}}})
+	c.Set("proj", "staging", map[string]evaluation.FlagData{"a": {Flag: model.Flag{Key: "a"}}})
+	if stats := c.Stats(); stats.Scopes != 2 {
+		t.Errorf("expected 2 scopes, got %d", stats.Scopes)
+	}
+}
+
+func TestCache_GenerationChangesWithData(t *testing.T) {
+	c := evaluation.NewCache()
+	before := c.Generation()
+	c.Set("proj", "prod", map[string]evaluation.FlagData{"a": {Flag: model.Flag{Key: "a"}}})
+	afterSet := c.Generation()
+	if afterSet == before {
+		t.Error("expected Set to change the generation")
+	}
+	if c.Generation() != afterSet {
+		t.Error("expected reads to leave the generation alone")
+	}
+	c.Evict("proj", "prod")
+	if c.Generation() == afterSet {
+		t.Error("expected Evict to change the generation")
+	}
+}
@@ -2,21 +2,74 @@ package evaluation
 
 import (
 	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// AnonymousBucketing selects how percentage splits treat contexts that have
+// no user ID to bucket by.
+type AnonymousBucketing string
+
+const (
+	// AnonymousHash buckets identifier-less contexts by the empty ID, so they
+	// all land in the same bucket. This is the default.
+	AnonymousHash AnonymousBucketing = "hash"
+	// AnonymousControl serves the control (default) variant whenever a
+	// matching rule would split traffic.
+	AnonymousControl AnonymousBucketing = "control"
+	// AnonymousRandom draws a new random bucket on every evaluation, so the
+	// split is honored across traffic but no context sticks to a variant.
+	AnonymousRandom AnonymousBucketing = "random"
+)
+
 // Engine evaluates feature flags for a given context.
-type Engine struct{}
+type Engine struct {
+	anonymous AnonymousBucketing
+
+	mu  sync.Mutex // guards rng
+	rng *rand.Rand
+}
 
 // NewEngine creates a new evaluation engine.
 func NewEngine() *Engine {
-	return &Engine{}
+	return &Engine{anonymous: AnonymousHash}
+}
+
+// SetAnonymousBucketing sets how percentage splits treat contexts without a
+// user ID. src seeds AnonymousRandom; nil uses a randomly seeded source.
+func (e *Engine) SetAnonymousBucketing(mode AnonymousBucketing, src rand.Source) {
+	if src == nil {
+		src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.anonymous = mode
+	e.rng = rand.New(src)
+}
+
+// Deterministic reports whether evaluating the same flag for the same context
+// always gives the same result, which is not the case in AnonymousRandom mode.
+func (e *Engine) Deterministic() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.anonymous != AnonymousRandom
 }
 
 // Evaluate evaluates a flag for a given context.
 // Returns the evaluation result with value, variant key, and reason.
-func (e *Engine) Evaluate(flag *model.Flag, config *model.FlagEnvironmentConfig, ctx *model.EvaluationContext) *model.EvaluationResult {
+// flags holds every flag of the environment (as returned by Cache.GetFlags)
+// and is used to resolve prerequisites; it may be nil if the flag has none.
+func (e *Engine) Evaluate(flag *model.Flag, config *model.FlagEnvironmentConfig, ctx *model.EvaluationContext, flags map[string]FlagData) *model.EvaluationResult {
+	return e.evaluate(flag, config, ctx, flags, nil)
+}
+
+// evaluate implements Evaluate. visiting holds the flags on the current
+// prerequisite chain so circular prerequisites fail instead of recursing.
+func (e *Engine) evaluate(flag *model.Flag, config *model.FlagEnvironmentConfig, ctx *model.EvaluationContext, flags map[string]FlagData, visiting map[string]bool) *model.EvaluationResult {
 	// 1. If flag is archived, return default value with reason "archived".
 	if flag.LifecycleStatus == model.LifecycleArchived {
 		return &model.EvaluationResult{
@@ -35,29 +88,120 @@ func (e *Engine) Evaluate(flag *model.Flag, config *model.FlagEnvironmentConfig,
 		}
 	}
 
-	// 3. Evaluate targeting rules in order.
-	for _, rule := range config.TargetingRules {
-		if matchesAllConditions(rule.Conditions, ctx) {
-			// Check percentage rollout.
+	// 3. Every prerequisite flag must serve its required variant.
+	if !e.prerequisitesMet(flag.Key, config.Prerequisites, ctx, flags, visiting) {
+		return &model.EvaluationResult{
+			Value:   rawToAny(flag.DefaultValue),
+			Variant: "",
+			Reason:  "prerequisite_failed",
+		}
+	}
+
+	// 4. Excluded users are served the flag's default value.
+	if ctx.UserID != "" && slices.Contains(config.ExcludedUsers, ctx.UserID) {
+		return &model.EvaluationResult{
+			Value:   rawToAny(flag.DefaultValue),
+			Variant: "",
+			Reason:  "excluded",
+		}
+	}
+
+	// 5. A sticky per-user override forces its variant ahead of the rules.
+	// Overrides naming a variant that no longer exists are ignored.
+	if variant, ok := config.UserOverrides[ctx.UserID]; ok && ctx.UserID != "" && hasVariant(config.Variants, variant) {
+		return &model.EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+			Variant: variant,
+			Reason:  "user_override",
+		}
+	}
+
+	// 6. Included users get the included variant, or the default variant when
+	// none is set.
+	if ctx.UserID != "" && slices.Contains(config.IncludedUsers, ctx.UserID) {
+		variant := config.IncludedVariant
+		if variant == "" {
+			variant = config.DefaultVariant
+		}
+		return &model.EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+			Variant: variant,
+			Reason:  "included",
+		}
+	}
+
+	// 7. If the flag requires a stable identifier and none was sent, serve the
+	// default variant rather than bucketing all anonymous traffic together.
+	if flag.RequireIdentifier && !hasIdentifier(config, ctx) {
+		return &model.EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, config.DefaultVariant, ctx.Locale, flag.DefaultValue),
+			Variant: config.DefaultVariant,
+			Reason:  "missing_identifier",
+		}
+	}
+
+	// 8. Evaluate targeting rules in order.
+	for i, rule := range config.TargetingRules {
+		if MatchesRule(&rule, ctx) {
+			pct := 100
 			if rule.PercentageRollout != nil {
-				bucket := ConsistentHash(flag.Key, ctx.UserID)
-				if bucket >= *rule.PercentageRollout {
+				pct = *rule.PercentageRollout
+			}
+			weighted := len(rule.VariantWeights) > 0
+			bucketKey, fellBack := bucketingKey(rule.BucketBy, ctx)
+			if bucketKey == "" && e.anonymous == AnonymousControl && pct > 0 && (pct < 100 || weighted) {
+				return &model.EvaluationResult{
+					Value:   lookupVariantValue(config.Variants, config.DefaultVariant, ctx.Locale, flag.DefaultValue),
+					Variant: config.DefaultVariant,
+					Reason:  "anonymous_control",
+				}
+			}
+
+			variant := rule.Variant
+			// Check percentage rollout and pick a weighted arm from one bucket.
+			if rule.PercentageRollout != nil || weighted {
+				bucket := e.bucket(flag.Key, rule.Salt, bucketKey)
+				if bucket >= pct {
 					// User is outside the rollout percentage; continue to next rule.
 					continue
 				}
+				if weighted {
+					// Rescale the bucket over the rolled-out share so the arms keep
+					// their weights under a partial rollout.
+					if arm, ok := pickWeightedVariant(rule.VariantWeights, bucket*100/pct); ok {
+						variant = arm
+					}
+				}
 			}
 			// Rule matched.
-			value := lookupVariantValue(config.Variants, rule.Variant, flag.DefaultValue)
+			reason := "rule_match"
+			if fellBack && (rule.PercentageRollout != nil || weighted) {
+				reason = "rule_match_bucket_fallback"
+			}
+			value := lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue)
 			return &model.EvaluationResult{
-				Value:   value,
-				Variant: rule.Variant,
-				Reason:  "rule_match",
+				Value:           value,
+				Variant:         variant,
+				Reason:          reason,
+				RuleIndex:       &i,
+				RuleDescription: rule.Description,
 			}
 		}
 	}
 
-	// 4. Return default variant.
-	value := lookupVariantValue(config.Variants, config.DefaultVariant, flag.DefaultValue)
+	// 9. Split the remaining traffic by the default rollout, if any.
+	if len(config.DefaultRollout) > 0 {
+		if variant, ok := pickWeightedVariant(config.DefaultRollout, ConsistentHash(flag.Key, ctx.UserID)); ok {
+			return &model.EvaluationResult{
+				Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+				Variant: variant,
+				Reason:  "default_rollout",
+			}
+		}
+	}
+
+	// 10. Return default variant.
+	value := lookupVariantValue(config.Variants, config.DefaultVariant, ctx.Locale, flag.DefaultValue)
 	return &model.EvaluationResult{
 		Value:   value,
 		Variant: config.DefaultVariant,
@@ -65,28 +209,219 @@ func (e *Engine) Evaluate(flag *model.Flag, config *model.FlagEnvironmentConfig,
 	}
 }
 
+// prerequisitesMet reports whether every prerequisite flag serves its required
+// variant for ctx. A prerequisite that is missing from flags, or that is
+// already on the chain being evaluated (a cycle), is not met.
+func (e *Engine) prerequisitesMet(flagKey string, prereqs []model.Prerequisite, ctx *model.EvaluationContext, flags map[string]FlagData, visiting map[string]bool) bool {
+	if len(prereqs) == 0 {
+		return true
+	}
+	if visiting == nil {
+		visiting = make(map[string]bool)
+	}
+	visiting[flagKey] = true
+	defer delete(visiting, flagKey)
+
+	for _, p := range prereqs {
+		fd, ok := flags[p.FlagKey]
+		if !ok || visiting[p.FlagKey] {
+			return false
+		}
+		if e.evaluate(&fd.Flag, &fd.Config, ctx, flags, visiting).Variant != p.Variant {
+			return false
+		}
+	}
+	return true
+}
+
+// bucketingKey returns the value a rule's rollout hashes: the stringified
+// bucketBy attribute when it is set and non-empty, otherwise the user ID.
+// fellBack reports that bucketBy was set but the user ID had to be used.
+func bucketingKey(bucketBy string, ctx *model.EvaluationContext) (key string, fellBack bool) {
+	if bucketBy == "" {
+		return ctx.UserID, false
+	}
+	if v := toString(contextAttribute(ctx.Attributes, bucketBy)); v != "" {
+		return v, false
+	}
+	return ctx.UserID, true
+}
+
+// hasIdentifier reports whether ctx carries a stable identifier: a user ID, or
+// a value for the attribute a targeting rule buckets by.
+func hasIdentifier(config *model.FlagEnvironmentConfig, ctx *model.EvaluationContext) bool {
+	if ctx.UserID != "" {
+		return true
+	}
+	for _, rule := range config.TargetingRules {
+		if rule.BucketBy != "" && toString(contextAttribute(ctx.Attributes, rule.BucketBy)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the rollout bucket (0-99) for a bucketing key. Non-empty
+// keys always use the consistent hash, salted with the rule's salt; an empty
+// key gets a random bucket in AnonymousRandom mode.
+func (e *Engine) bucket(flagKey, salt, key string) int {
+	if key == "" && e.anonymous == AnonymousRandom {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.rng.IntN(100)
+	}
+	return SaltedHash(flagKey, salt, key)
+}
+
+// pickWeightedVariant maps a bucket (0-99) onto the cumulative weights, so
+// with weights 34/33/33 buckets 0-33 get the first arm, 34-66 the second and
+// 67-99 the third. ok is false if the weights sum to less than bucket+1.
+func pickWeightedVariant(weights []model.VariantWeight, bucket int) (string, bool) {
+	cumulative := 0
+	for _, w := range weights {
+		cumulative += w.Weight
+		if bucket < cumulative {
+			return w.Variant, true
+		}
+	}
+	return "", false
+}
+
+// MatchesRule reports whether a targeting rule's conditions match ctx: all
+// of its flat conditions and, if it has one, its condition group, inverted
+// for a negated rule.
+func MatchesRule(rule *model.TargetingRule, ctx *model.EvaluationContext) bool {
+	matched := matchesAllConditions(rule.Conditions, ctx) && (rule.Group == nil || matchesGroup(rule.Group, ctx))
+	return matched != rule.Negate
+}
+
+// matchesGroup evaluates a condition group recursively. An "or" group matches
+// when any of its conditions or subgroups does; any other group when all do.
+func matchesGroup(g *model.ConditionGroup, ctx *model.EvaluationContext) bool {
+	if g.Operator == model.GroupOr {
+		for _, cond := range g.Conditions {
+			if conditionPasses(cond, ctx) {
+				return true
+			}
+		}
+		for i := range g.Groups {
+			if matchesGroup(&g.Groups[i], ctx) {
+				return true
+			}
+		}
+		return false
+	}
+	if !matchesAllConditions(g.Conditions, ctx) {
+		return false
+	}
+	for i := range g.Groups {
+		if !matchesGroup(&g.Groups[i], ctx) {
+			return false
+		}
+	}
+	return true
+}
+
 // matchesAllConditions checks if all conditions in a rule match the evaluation context.
 func matchesAllConditions(conditions []model.Condition, ctx *model.EvaluationContext) bool {
 	for _, cond := range conditions {
-		attrValue := ctx.Attributes[cond.Attribute]
-		if !EvaluateCondition(attrValue, cond.Operator, cond.Value) {
+		if !conditionPasses(cond, ctx) {
 			return false
 		}
 	}
 	return true
 }
 
+// conditionPasses evaluates a single condition against ctx. An in_segment
+// condition expands to the conditions of its resolved segment; one naming an
+// unknown segment never passes.
+func conditionPasses(cond model.Condition, ctx *model.EvaluationContext) bool {
+	if cond.Operator == string(model.OpInSegment) {
+		seg, ok := cond.Value.(*SegmentRef)
+		return ok && matchesAllConditions(seg.conditions, ctx)
+	}
+	return EvaluateCondition(contextAttribute(ctx.Attributes, cond.Attribute), cond.Operator, cond.Value)
+}
+
+// contextAttribute returns the value of the named attribute. A name that is
+// a top-level attribute is used as is; otherwise a dotted name such as
+// "device.os" descends into nested objects. A missing key at any level
+// yields nil, so exists and not_exists see the attribute as absent.
+func contextAttribute(attrs map[string]any, name string) any {
+	if v, ok := attrs[name]; ok || !strings.Contains(name, ".") {
+		return v
+	}
+	var v any = attrs
+	for _, key := range strings.Split(name, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+// EvaluateConditions evaluates every condition against ctx without
+// short-circuiting, so callers can see which conditions pass and which fail.
+func EvaluateConditions(conditions []model.Condition, ctx *model.EvaluationContext) []model.ConditionResult {
+	results := make([]model.ConditionResult, 0, len(conditions))
+	for _, cond := range conditions {
+		attrValue := contextAttribute(ctx.Attributes, cond.Attribute)
+		results = append(results, model.ConditionResult{
+			Attribute: cond.Attribute,
+			Operator:  cond.Operator,
+			Value:     cond.Value,
+			Actual:    attrValue,
+			Passed:    conditionPasses(cond, ctx),
+		})
+	}
+	return results
+}
+
+// hasVariant reports whether variants contains one with the given key.
+func hasVariant(variants []model.Variant, key string) bool {
+	for _, v := range variants {
+		if v.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
 // lookupVariantValue finds the value for a variant key in the variants list.
-// If the variant is not found, returns the flag's default value.
-func lookupVariantValue(variants []model.Variant, variantKey string, defaultValue json.RawMessage) any {
+// If the variant has a localized value for locale, that value is returned
+// instead of the base value. If the variant is not found, returns the flag's
+// default value.
+func lookupVariantValue(variants []model.Variant, variantKey, locale string, defaultValue json.RawMessage) any {
 	for _, v := range variants {
 		if v.Key == variantKey {
+			if raw, ok := localizedValue(v.Localized, locale); ok {
+				return rawToAny(raw)
+			}
 			return rawToAny(v.Value)
 		}
 	}
 	return rawToAny(defaultValue)
 }
 
+// localizedValue looks up locale in a variant's localized values, falling back
+// from a regional locale ("fr-CA") to its base language ("fr").
+func localizedValue(localized map[string]json.RawMessage, locale string) (json.RawMessage, bool) {
+	if locale == "" || len(localized) == 0 {
+		return nil, false
+	}
+	if raw, ok := localized[locale]; ok {
+		return raw, true
+	}
+	if lang, _, found := strings.Cut(locale, "-"); found {
+		if raw, ok := localized[lang]; ok {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
 // rawToAny converts json.RawMessage to a Go value.
 func rawToAny(raw json.RawMessage) any {
 	if raw == nil {
@@ -2,6 +2,9 @@ package evaluation
 
 import (
 	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
 	"testing"
 
 	"github.com/togglerino/togglerino/internal/model"
@@ -45,7 +48,7 @@ func TestEngine_FlagDisabled(t *testing.T) {
 		Attributes: map[string]any{},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "disabled" {
 		t.Errorf("expected reason 'disabled', got %q", result.Reason)
@@ -66,7 +69,7 @@ func TestEngine_FlagArchived(t *testing.T) {
 		Attributes: map[string]any{},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "archived" {
 		t.Errorf("expected reason 'archived', got %q", result.Reason)
@@ -88,7 +91,7 @@ func TestEngine_NoRulesEnabled(t *testing.T) {
 		Attributes: map[string]any{},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "default" {
 		t.Errorf("expected reason 'default', got %q", result.Reason)
@@ -122,7 +125,7 @@ func TestEngine_SingleRuleMatches(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "rule_match" {
 		t.Errorf("expected reason 'rule_match', got %q", result.Reason)
@@ -156,7 +159,7 @@ func TestEngine_SingleRuleDoesNotMatch(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "default" {
 		t.Errorf("expected reason 'default', got %q", result.Reason)
@@ -181,6 +184,7 @@ func TestEngine_MultipleRulesFirstMatchWins(t *testing.T) {
 			Variant: "vip",
 		},
 		{
+			Description: "Beta testers",
 			Conditions: []model.Condition{
 				{Attribute: "beta", Operator: "equals", Value: "true"},
 			},
@@ -197,7 +201,7 @@ func TestEngine_MultipleRulesFirstMatchWins(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Variant != "vip" {
 		t.Errorf("expected variant 'vip' (first match), got %q", result.Variant)
@@ -208,6 +212,29 @@ func TestEngine_MultipleRulesFirstMatchWins(t *testing.T) {
 	if result.Value != "vip-experience" {
 		t.Errorf("expected value 'vip-experience', got %v", result.Value)
 	}
+	if result.RuleIndex == nil || *result.RuleIndex != 0 {
+		t.Errorf("expected rule index 0, got %v", result.RuleIndex)
+	}
+	if result.RuleDescription != "" {
+		t.Errorf("expected no rule description, got %q", result.RuleDescription)
+	}
+
+	// Only the second rule matches.
+	ctx.Attributes["plan"] = "free"
+	result = engine.Evaluate(flag, config, ctx, nil)
+	if result.RuleIndex == nil || *result.RuleIndex != 1 {
+		t.Errorf("expected rule index 1, got %v", result.RuleIndex)
+	}
+	if result.RuleDescription != "Beta testers" {
+		t.Errorf("expected rule description 'Beta testers', got %q", result.RuleDescription)
+	}
+
+	// Falling through to the default reports no rule.
+	ctx.Attributes["beta"] = "false"
+	result = engine.Evaluate(flag, config, ctx, nil)
+	if result.Reason != "default" || result.RuleIndex != nil {
+		t.Errorf("expected default with no rule index, got %s/%v", result.Reason, result.RuleIndex)
+	}
 }
 
 func TestEngine_PercentageRollout_InBucket(t *testing.T) {
@@ -234,7 +261,7 @@ func TestEngine_PercentageRollout_InBucket(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "rule_match" {
 		t.Errorf("expected reason 'rule_match', got %q", result.Reason)
@@ -271,7 +298,7 @@ func TestEngine_PercentageRollout_OutOfBucket(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	// User-abc hashes to bucket 89, which is >= 50, so rollout does not apply.
 	// Falls through to default.
@@ -354,7 +381,7 @@ func TestEngine_ComplexConditionsANDLogic(t *testing.T) {
 				UserID:     "user-1",
 				Attributes: tt.attrs,
 			}
-			result := engine.Evaluate(flag, config, ctx)
+			result := engine.Evaluate(flag, config, ctx, nil)
 			if result.Reason != tt.expectedReason {
 				t.Errorf("expected reason %q, got %q", tt.expectedReason, result.Reason)
 			}
@@ -365,6 +392,133 @@ func TestEngine_ComplexConditionsANDLogic(t *testing.T) {
 	}
 }
 
+func TestEngine_ConditionGroup_OrMatchesEitherBranch(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("test-flag", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "on", Value: rawJSON(true)},
+		{Key: "off", Value: rawJSON(false)},
+	}, []model.TargetingRule{
+		{
+			Group: &model.ConditionGroup{
+				Operator: model.GroupOr,
+				Conditions: []model.Condition{
+					{Attribute: "country", Operator: "equals", Value: "US"},
+					{Attribute: "country", Operator: "equals", Value: "CA"},
+				},
+			},
+			Variant: "on",
+		},
+	})
+
+	for country, want := range map[string]string{"US": "on", "CA": "on", "UK": "off"} {
+		ctx := &model.EvaluationContext{UserID: "user-1", Attributes: map[string]any{"country": country}}
+		if result := engine.Evaluate(flag, config, ctx, nil); result.Variant != want {
+			t.Errorf("country %s: expected variant %q, got %q (%s)", country, want, result.Variant, result.Reason)
+		}
+	}
+}
+
+func TestEngine_ConditionGroup_MixedAndOr(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("test-flag", false, model.LifecycleActive)
+	// (country=US OR country=CA) AND plan=pro, once with the AND in the flat
+	// conditions and once as a nested group.
+	countries := model.ConditionGroup{
+		Operator: model.GroupOr,
+		Conditions: []model.Condition{
+			{Attribute: "country", Operator: "equals", Value: "US"},
+			{Attribute: "country", Operator: "equals", Value: "CA"},
+		},
+	}
+	pro := model.Condition{Attribute: "plan", Operator: "equals", Value: "pro"}
+	rules := map[string]model.TargetingRule{
+		"flat":   {Conditions: []model.Condition{pro}, Group: &countries, Variant: "on"},
+		"nested": {Group: &model.ConditionGroup{Operator: model.GroupAnd, Conditions: []model.Condition{pro}, Groups: []model.ConditionGroup{countries}}, Variant: "on"},
+	}
+
+	tests := []struct {
+		country, plan, want string
+	}{
+		{"US", "pro", "on"},
+		{"CA", "pro", "on"},
+		{"UK", "pro", "off"},
+		{"US", "free", "off"},
+	}
+	for name, rule := range rules {
+		config := makeConfig(true, "off", []model.Variant{
+			{Key: "on", Value: rawJSON(true)},
+			{Key: "off", Value: rawJSON(false)},
+		}, []model.TargetingRule{rule})
+		for _, tt := range tests {
+			ctx := &model.EvaluationContext{UserID: "user-1", Attributes: map[string]any{"country": tt.country, "plan": tt.plan}}
+			if result := engine.Evaluate(flag, config, ctx, nil); result.Variant != tt.want {
+				t.Errorf("%s: country %s, plan %s: expected variant %q, got %q", name, tt.country, tt.plan, tt.want, result.Variant)
+			}
+		}
+	}
+}
+
+func negatedBetaConfig(rollout *int) *model.FlagEnvironmentConfig {
+	return makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{
+				{Attribute: "group", Operator: "equals", Value: "beta"},
+			},
+			Negate:            true,
+			Variant:           "on",
+			PercentageRollout: rollout,
+		},
+	})
+}
+
+func TestEngine_NegatedRule_MatchesComplement(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("test-flag", false, model.LifecycleActive)
+	config := negatedBetaConfig(nil)
+
+	tests := []struct {
+		name  string
+		attrs map[string]any
+		want  string
+	}{
+		{"in the beta group", map[string]any{"group": "beta"}, "off"},
+		{"in another group", map[string]any{"group": "alpha"}, "on"},
+		{"without a group", map[string]any{}, "on"},
+	}
+	for _, tt := range tests {
+		ctx := &model.EvaluationContext{UserID: "user-1", Attributes: tt.attrs}
+		if result := engine.Evaluate(flag, config, ctx, nil); result.Variant != tt.want {
+			t.Errorf("%s: expected variant %q, got %q (%s)", tt.name, tt.want, result.Variant, result.Reason)
+		}
+	}
+}
+
+func TestEngine_NegatedRule_RespectsRollout(t *testing.T) {
+	// rollout-flag hashes user-xyz to bucket 28 and user-abc to bucket 89.
+	engine := NewEngine()
+	flag := makeFlag("rollout-flag", false, model.LifecycleActive)
+	config := negatedBetaConfig(intPtr(50))
+
+	tests := []struct {
+		name, userID, group, want, reason string
+	}{
+		{"outside beta, in rollout", "user-xyz", "alpha", "on", "rule_match"},
+		{"outside beta, out of rollout", "user-abc", "alpha", "off", "default"},
+		{"in beta, in rollout bucket", "user-xyz", "beta", "off", "default"},
+	}
+	for _, tt := range tests {
+		ctx := &model.EvaluationContext{UserID: tt.userID, Attributes: map[string]any{"group": tt.group}}
+		result := engine.Evaluate(flag, config, ctx, nil)
+		if result.Variant != tt.want || result.Reason != tt.reason {
+			t.Errorf("%s: expected (%q, %q), got (%q, %q)", tt.name, tt.want, tt.reason, result.Variant, result.Reason)
+		}
+	}
+}
+
 func TestEngine_ExistsNotExistsOperators(t *testing.T) {
 	engine := NewEngine()
 	flag := makeFlag("test-flag", false, model.LifecycleActive)
@@ -387,7 +541,7 @@ func TestEngine_ExistsNotExistsOperators(t *testing.T) {
 				"email": "user@example.com",
 			},
 		}
-		result := engine.Evaluate(flag, config, ctx)
+		result := engine.Evaluate(flag, config, ctx, nil)
 		if result.Reason != "rule_match" {
 			t.Errorf("expected reason 'rule_match', got %q", result.Reason)
 		}
@@ -401,7 +555,7 @@ func TestEngine_ExistsNotExistsOperators(t *testing.T) {
 			UserID:     "user-2",
 			Attributes: map[string]any{},
 		}
-		result := engine.Evaluate(flag, config, ctx)
+		result := engine.Evaluate(flag, config, ctx, nil)
 		if result.Reason != "default" {
 			t.Errorf("expected reason 'default', got %q", result.Reason)
 		}
@@ -428,7 +582,7 @@ func TestEngine_ExistsNotExistsOperators(t *testing.T) {
 			UserID:     "user-3",
 			Attributes: map[string]any{},
 		}
-		result := engine.Evaluate(flag, configNotExists, ctx)
+		result := engine.Evaluate(flag, configNotExists, ctx, nil)
 		if result.Reason != "rule_match" {
 			t.Errorf("expected reason 'rule_match', got %q", result.Reason)
 		}
@@ -441,7 +595,7 @@ func TestEngine_ExistsNotExistsOperators(t *testing.T) {
 				"email": "user@example.com",
 			},
 		}
-		result := engine.Evaluate(flag, configNotExists, ctx)
+		result := engine.Evaluate(flag, configNotExists, ctx, nil)
 		if result.Reason != "default" {
 			t.Errorf("expected reason 'default', got %q", result.Reason)
 		}
@@ -459,7 +613,7 @@ func TestEngine_VariantNotFound_FallbackToDefault(t *testing.T) {
 		Attributes: map[string]any{},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "default" {
 		t.Errorf("expected reason 'default', got %q", result.Reason)
@@ -497,7 +651,7 @@ func TestEngine_PercentageRollout_100Percent(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "rule_match" {
 		t.Errorf("expected reason 'rule_match', got %q", result.Reason)
@@ -530,9 +684,895 @@ func TestEngine_PercentageRollout_0Percent(t *testing.T) {
 		},
 	}
 
-	result := engine.Evaluate(flag, config, ctx)
+	result := engine.Evaluate(flag, config, ctx, nil)
 
 	if result.Reason != "default" {
 		t.Errorf("expected reason 'default', got %q", result.Reason)
 	}
 }
+
+func TestEngine_LocalizedVariantValue(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("welcome-banner", "Welcome", model.LifecycleActive)
+	config := makeConfig(true, "greeting", []model.Variant{
+		{
+			Key:   "greeting",
+			Value: rawJSON("Welcome"),
+			Localized: map[string]json.RawMessage{
+				"fr": rawJSON("Bienvenue"),
+				"de": rawJSON("Willkommen"),
+			},
+		},
+	}, nil)
+
+	tests := []struct {
+		name     string
+		locale   string
+		expected string
+	}{
+		{name: "matching locale", locale: "fr", expected: "Bienvenue"},
+		{name: "regional locale falls back to language", locale: "fr-CA", expected: "Bienvenue"},
+		{name: "unknown locale uses base value", locale: "es", expected: "Welcome"},
+		{name: "no locale uses base value", locale: "", expected: "Welcome"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &model.EvaluationContext{UserID: "user-1", Locale: tt.locale}
+			result := engine.Evaluate(flag, config, ctx, nil)
+			if result.Value != tt.expected {
+				t.Errorf("expected value %q, got %v", tt.expected, result.Value)
+			}
+			if result.Variant != "greeting" {
+				t.Errorf("expected variant 'greeting', got %q", result.Variant)
+			}
+		})
+	}
+}
+
+func TestEngine_LocalizedVariantValue_RuleMatch(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("cta-label", "Buy", model.LifecycleActive)
+	config := makeConfig(true, "default", []model.Variant{
+		{Key: "default", Value: rawJSON("Buy")},
+		{Key: "promo", Value: rawJSON("Buy now"), Localized: map[string]json.RawMessage{"fr": rawJSON("Achetez maintenant")}},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{{Attribute: "plan", Operator: "equals", Value: "free"}},
+			Variant:    "promo",
+		},
+	})
+
+	ctx := &model.EvaluationContext{UserID: "user-1", Locale: "fr", Attributes: map[string]any{"plan": "free"}}
+	result := engine.Evaluate(flag, config, ctx, nil)
+	if result.Value != "Achetez maintenant" {
+		t.Errorf("expected localized value, got %v", result.Value)
+	}
+
+	ctx.Locale = "en"
+	result = engine.Evaluate(flag, config, ctx, nil)
+	if result.Value != "Buy now" {
+		t.Errorf("expected base value, got %v", result.Value)
+	}
+}
+
+func TestEngine_RequireIdentifier_EmptyUserIDReturnsDefault(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("rollout-flag", false, model.LifecycleActive)
+	flag.RequireIdentifier = true
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(100)},
+	})
+	ctx := &model.EvaluationContext{UserID: "", Attributes: map[string]any{}}
+
+	result := engine.Evaluate(flag, config, ctx, nil)
+
+	if result.Reason != "missing_identifier" {
+		t.Errorf("expected reason 'missing_identifier', got %q", result.Reason)
+	}
+	if result.Variant != "off" {
+		t.Errorf("expected default variant 'off', got %q", result.Variant)
+	}
+	if result.Value != false {
+		t.Errorf("expected value false, got %v", result.Value)
+	}
+
+	// With an identifier, the rollout applies as usual.
+	ctx.UserID = "user-xyz"
+	result = engine.Evaluate(flag, config, ctx, nil)
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match/on with a user ID, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_RequireIdentifier_BucketByAttributeCounts(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("rollout-flag", false, model.LifecycleActive)
+	flag.RequireIdentifier = true
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(100), BucketBy: "company_id"},
+	})
+
+	// The bucketing attribute identifies the context without a user ID.
+	ctx := &model.EvaluationContext{Attributes: map[string]any{"company_id": "acme"}}
+	result := engine.Evaluate(flag, config, ctx, nil)
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match/on with company_id, got %s/%s", result.Reason, result.Variant)
+	}
+
+	ctx = &model.EvaluationContext{Attributes: map[string]any{}}
+	result = engine.Evaluate(flag, config, ctx, nil)
+	if result.Reason != "missing_identifier" {
+		t.Errorf("expected missing_identifier without company_id or user ID, got %q", result.Reason)
+	}
+}
+
+func TestEngine_RequireIdentifier_OffByDefault(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("rollout-flag", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(100)},
+	})
+	ctx := &model.EvaluationContext{UserID: "", Attributes: map[string]any{}}
+
+	result := engine.Evaluate(flag, config, ctx, nil)
+
+	if result.Reason != "rule_match" {
+		t.Errorf("expected reason 'rule_match' when identifier is not required, got %q", result.Reason)
+	}
+}
+
+func TestEngine_UserOverride_SupersedesRules(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("override-flag", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+		{Key: "beta", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(100)},
+	})
+	config.UserOverrides = map[string]string{"qa-user": "beta"}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "qa-user", Attributes: map[string]any{}}, nil)
+	if result.Reason != "user_override" || result.Variant != "beta" {
+		t.Errorf("expected user_override/beta, got %s/%s", result.Reason, result.Variant)
+	}
+
+	// Other users still go through the rules.
+	result = engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "someone-else", Attributes: map[string]any{}}, nil)
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match/on for another user, got %s/%s", result.Reason, result.Variant)
+	}
+
+	// Clearing the override restores normal evaluation.
+	delete(config.UserOverrides, "qa-user")
+	result = engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "qa-user", Attributes: map[string]any{}}, nil)
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match/on after clearing, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_UserOverride_UnknownVariantIgnored(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("override-flag", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+	}, nil)
+	config.UserOverrides = map[string]string{"qa-user": "removed"}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "qa-user", Attributes: map[string]any{}}, nil)
+	if result.Reason != "default" {
+		t.Errorf("expected reason 'default' for an unknown override variant, got %q", result.Reason)
+	}
+}
+
+func TestEngine_UserOverride_DisabledWins(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("override-flag", false, model.LifecycleActive)
+	config := makeConfig(false, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, nil)
+	config.UserOverrides = map[string]string{"qa-user": "on"}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "qa-user", Attributes: map[string]any{}}, nil)
+	if result.Reason != "disabled" {
+		t.Errorf("expected reason 'disabled', got %q", result.Reason)
+	}
+}
+
+// userListConfig returns an enabled config serving "off" by default with a
+// rule that turns "on" for pro users.
+func userListConfig() *model.FlagEnvironmentConfig {
+	return makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{{Attribute: "plan", Operator: "equals", Value: "pro"}},
+			Variant:    "on",
+		},
+	})
+}
+
+func TestEngine_IncludedUser_OnWithoutMatchingRule(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("list-flag", false, model.LifecycleActive)
+	config := userListConfig()
+	config.IncludedUsers = []string{"vip"}
+	config.IncludedVariant = "on"
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "vip", Attributes: map[string]any{"plan": "free"}}, nil)
+	if result.Reason != "included" || result.Variant != "on" || result.Value != true {
+		t.Errorf("expected included/on (true), got %s/%s (%v)", result.Reason, result.Variant, result.Value)
+	}
+
+	// Other users still go through the rules.
+	result = engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "someone-else", Attributes: map[string]any{"plan": "free"}}, nil)
+	if result.Reason != "default" || result.Variant != "off" {
+		t.Errorf("expected default/off for another user, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_IncludedUser_DefaultsToDefaultVariant(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("list-flag", false, model.LifecycleActive)
+	config := userListConfig()
+	config.IncludedUsers = []string{"vip"}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "vip", Attributes: map[string]any{"plan": "pro"}}, nil)
+	if result.Reason != "included" || result.Variant != "off" {
+		t.Errorf("expected included/off, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_ExcludedUser_OffWhenRuleMatches(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("list-flag", false, model.LifecycleActive)
+	config := userListConfig()
+	config.ExcludedUsers = []string{"blocked"}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "blocked", Attributes: map[string]any{"plan": "pro"}}, nil)
+	if result.Reason != "excluded" || result.Variant != "" || result.Value != false {
+		t.Errorf("expected excluded with the flag default, got %s/%s (%v)", result.Reason, result.Variant, result.Value)
+	}
+
+	// The rule still matches for everyone else.
+	result = engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "someone-else", Attributes: map[string]any{"plan": "pro"}}, nil)
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match/on for another user, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_UserLists_IgnoreMissingUserID(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("list-flag", false, model.LifecycleActive)
+	config := userListConfig()
+	config.IncludedUsers = []string{""}
+	config.ExcludedUsers = []string{""}
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{Attributes: map[string]any{"plan": "pro"}}, nil)
+	if result.Reason != "rule_match" {
+		t.Errorf("expected rule_match for an anonymous context, got %q", result.Reason)
+	}
+}
+
+func TestEvaluateConditions_ReportsEachCondition(t *testing.T) {
+	conditions := []model.Condition{
+		{Attribute: "country", Operator: "equals", Value: "DE"},
+		{Attribute: "plan", Operator: "equals", Value: "pro"},
+		{Attribute: "beta", Operator: "equals", Value: true},
+	}
+	ctx := &model.EvaluationContext{Attributes: map[string]any{"country": "FR", "plan": "pro"}}
+
+	results := EvaluateConditions(conditions, ctx)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	// A failing first condition must not stop the rest from being evaluated.
+	if results[0].Passed || !results[1].Passed || results[2].Passed {
+		t.Errorf("unexpected pass/fail: %+v", results)
+	}
+	if results[0].Actual != "FR" || results[2].Actual != nil {
+		t.Errorf("unexpected actual values: %v, %v", results[0].Actual, results[2].Actual)
+	}
+}
+
+// splitConfig returns a config whose single rule serves "on" to 50% of users.
+func splitConfig() *model.FlagEnvironmentConfig {
+	return makeConfig(true, "control", []model.Variant{
+		{Key: "control", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(50)},
+	})
+}
+
+func TestEngine_AnonymousControl_ServesControlVariant(t *testing.T) {
+	engine := NewEngine()
+	engine.SetAnonymousBucketing(AnonymousControl, nil)
+	flag := makeFlag("experiment", false, model.LifecycleActive)
+
+	result := engine.Evaluate(flag, splitConfig(), &model.EvaluationContext{Attributes: map[string]any{}}, nil)
+	if result.Reason != "anonymous_control" || result.Variant != "control" {
+		t.Errorf("expected anonymous_control/control, got %s/%s", result.Reason, result.Variant)
+	}
+
+	// Identified contexts are still bucketed by hash.
+	for i := 0; i < 20; i++ {
+		result = engine.Evaluate(flag, splitConfig(), &model.EvaluationContext{UserID: fmt.Sprintf("user-%d", i), Attributes: map[string]any{}}, nil)
+		if result.Reason == "anonymous_control" {
+			t.Fatalf("user-%d: identified context got anonymous_control", i)
+		}
+	}
+}
+
+func TestEngine_AnonymousRandom_SeededWeightedSplit(t *testing.T) {
+	flag := makeFlag("experiment", false, model.LifecycleActive)
+	run := func() []string {
+		engine := NewEngine()
+		engine.SetAnonymousBucketing(AnonymousRandom, rand.NewPCG(1, 2))
+		variants := make([]string, 200)
+		for i := range variants {
+			variants[i] = engine.Evaluate(flag, splitConfig(), &model.EvaluationContext{Attributes: map[string]any{}}, nil).Variant
+		}
+		return variants
+	}
+
+	first := run()
+	if !slices.Equal(first, run()) {
+		t.Error("expected the same seed to produce the same assignments")
+	}
+
+	on := 0
+	for _, v := range first {
+		if v == "on" {
+			on++
+		}
+	}
+	// A 50% split over 200 draws should land well away from all-or-nothing.
+	if on < 70 || on > 130 {
+		t.Errorf("expected roughly half of anonymous contexts on, got %d/200", on)
+	}
+}
+
+func TestEngine_AnonymousHash_DefaultIsSticky(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("experiment", false, model.LifecycleActive)
+
+	want := engine.Evaluate(flag, splitConfig(), &model.EvaluationContext{Attributes: map[string]any{}}, nil).Variant
+	for i := 0; i < 20; i++ {
+		if got := engine.Evaluate(flag, splitConfig(), &model.EvaluationContext{Attributes: map[string]any{}}, nil).Variant; got != want {
+			t.Fatalf("expected every anonymous evaluation to get %q, got %q", want, got)
+		}
+	}
+}
+
+// weightedConfig returns a config whose single rule splits users 34/33/33
+// across "a", "b" and "c".
+func weightedConfig(rollout *int) *model.FlagEnvironmentConfig {
+	return makeConfig(true, "control", []model.Variant{
+		{Key: "control", Value: rawJSON("control")},
+		{Key: "a", Value: rawJSON("a")},
+		{Key: "b", Value: rawJSON("b")},
+		{Key: "c", Value: rawJSON("c")},
+	}, []model.TargetingRule{
+		{
+			PercentageRollout: rollout,
+			VariantWeights: []model.VariantWeight{
+				{Variant: "a", Weight: 34},
+				{Variant: "b", Weight: 33},
+				{Variant: "c", Weight: 33},
+			},
+		},
+	})
+}
+
+// userInBucket returns a user ID that hashes into bucket for flagKey.
+func userInBucket(t *testing.T, flagKey string, bucket int) string {
+	t.Helper()
+	for i := 0; i < 100000; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		if ConsistentHash(flagKey, id) == bucket {
+			return id
+		}
+	}
+	t.Fatalf("no user found in bucket %d", bucket)
+	return ""
+}
+
+func TestEngine_VariantWeights_Boundaries(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("weighted", false, model.LifecycleActive)
+
+	tests := []struct {
+		bucket int
+		want   string
+	}{
+		{0, "a"},
+		{33, "a"},
+		{34, "b"},
+		{66, "b"},
+		{67, "c"},
+		{99, "c"},
+	}
+	for _, tt := range tests {
+		ctx := &model.EvaluationContext{UserID: userInBucket(t, flag.Key, tt.bucket), Attributes: map[string]any{}}
+		result := engine.Evaluate(flag, weightedConfig(nil), ctx, nil)
+		if result.Reason != "rule_match" || result.Variant != tt.want {
+			t.Errorf("bucket %d: expected rule_match/%s, got %s/%s", tt.bucket, tt.want, result.Reason, result.Variant)
+		}
+		if result.Value != tt.want {
+			t.Errorf("bucket %d: expected value %q, got %v", tt.bucket, tt.want, result.Value)
+		}
+	}
+}
+
+func TestEngine_VariantWeights_Sticky(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("weighted", false, model.LifecycleActive)
+
+	for i := 0; i < 20; i++ {
+		ctx := &model.EvaluationContext{UserID: fmt.Sprintf("user-%d", i), Attributes: map[string]any{}}
+		want := engine.Evaluate(flag, weightedConfig(nil), ctx, nil).Variant
+		for j := 0; j < 5; j++ {
+			if got := engine.Evaluate(flag, weightedConfig(nil), ctx, nil).Variant; got != want {
+				t.Fatalf("user-%d: expected %q on every evaluation, got %q", i, want, got)
+			}
+		}
+	}
+}
+
+func TestEngine_VariantWeights_WithPercentageRollout(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("weighted", false, model.LifecycleActive)
+
+	// With a 50% rollout the arms are spread over buckets 0-49: 0-16 "a",
+	// 17-33 "b", 34-49 "c"; everyone else falls through to the default.
+	tests := []struct {
+		bucket int
+		want   string
+		reason string
+	}{
+		{16, "a", "rule_match"},
+		{17, "b", "rule_match"},
+		{33, "b", "rule_match"},
+		{34, "c", "rule_match"},
+		{49, "c", "rule_match"},
+		{50, "control", "default"},
+	}
+	for _, tt := range tests {
+		ctx := &model.EvaluationContext{UserID: userInBucket(t, flag.Key, tt.bucket), Attributes: map[string]any{}}
+		result := engine.Evaluate(flag, weightedConfig(intPtr(50)), ctx, nil)
+		if result.Reason != tt.reason || result.Variant != tt.want {
+			t.Errorf("bucket %d: expected %s/%s, got %s/%s", tt.bucket, tt.reason, tt.want, result.Reason, result.Variant)
+		}
+	}
+}
+
+// defaultRolloutConfig returns an enabled config with a rule for pro users
+// and a 20/30/50 default rollout across a, b and c for everyone else.
+func defaultRolloutConfig() *model.FlagEnvironmentConfig {
+	config := makeConfig(true, "a", []model.Variant{
+		{Key: "a", Value: rawJSON("a")},
+		{Key: "b", Value: rawJSON("b")},
+		{Key: "c", Value: rawJSON("c")},
+		{Key: "pro", Value: rawJSON("pro")},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{{Attribute: "plan", Operator: "equals", Value: "pro"}},
+			Variant:    "pro",
+		},
+	})
+	config.DefaultRollout = []model.VariantWeight{
+		{Variant: "a", Weight: 20},
+		{Variant: "b", Weight: 30},
+		{Variant: "c", Weight: 50},
+	}
+	return config
+}
+
+func TestEngine_DefaultRollout_Boundaries(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("fallthrough", "", model.LifecycleActive)
+
+	tests := []struct {
+		bucket int
+		want   string
+	}{
+		{0, "a"},
+		{19, "a"},
+		{20, "b"},
+		{49, "b"},
+		{50, "c"},
+		{99, "c"},
+	}
+	for _, tt := range tests {
+		ctx := &model.EvaluationContext{UserID: userInBucket(t, flag.Key, tt.bucket), Attributes: map[string]any{}}
+		result := engine.Evaluate(flag, defaultRolloutConfig(), ctx, nil)
+		if result.Reason != "default_rollout" || result.Variant != tt.want || result.Value != tt.want {
+			t.Errorf("bucket %d: expected default_rollout/%s, got %s/%s (%v)", tt.bucket, tt.want, result.Reason, result.Variant, result.Value)
+		}
+	}
+}
+
+func TestEngine_DefaultRollout_Distribution(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("fallthrough", "", model.LifecycleActive)
+
+	counts := make(map[string]int)
+	numUsers := 10000
+	for i := 0; i < numUsers; i++ {
+		ctx := &model.EvaluationContext{UserID: fmt.Sprintf("user-%d", i), Attributes: map[string]any{}}
+		counts[engine.Evaluate(flag, defaultRolloutConfig(), ctx, nil).Variant]++
+	}
+
+	for variant, weight := range map[string]int{"a": 20, "b": 30, "c": 50} {
+		want := numUsers * weight / 100
+		if got := counts[variant]; got < want*9/10 || got > want*11/10 {
+			t.Errorf("variant %q served %d of %d users, want about %d", variant, got, numUsers, want)
+		}
+	}
+	if counts["pro"] != 0 {
+		t.Errorf("rule variant served %d times without a matching rule", counts["pro"])
+	}
+}
+
+func TestEngine_DefaultRollout_RulesTakePrecedence(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("fallthrough", "", model.LifecycleActive)
+	ctx := &model.EvaluationContext{UserID: "user-1", Attributes: map[string]any{"plan": "pro"}}
+
+	result := engine.Evaluate(flag, defaultRolloutConfig(), ctx, nil)
+	if result.Reason != "rule_match" || result.Variant != "pro" {
+		t.Errorf("expected rule_match/pro, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_DefaultRollout_EmptyServesDefaultVariant(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("fallthrough", "", model.LifecycleActive)
+	config := defaultRolloutConfig()
+	config.DefaultRollout = nil
+	ctx := &model.EvaluationContext{UserID: "user-1", Attributes: map[string]any{}}
+
+	result := engine.Evaluate(flag, config, ctx, nil)
+	if result.Reason != "default" || result.Variant != "a" {
+		t.Errorf("expected default/a, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+// prerequisiteFlags returns an environment where "child" requires "parent"
+// to serve "on". parent serves parentVariant to everyone.
+func prerequisiteFlags(parentVariant string) map[string]FlagData {
+	onOff := []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}
+	child := makeConfig(true, "on", onOff, nil)
+	child.Prerequisites = []model.Prerequisite{{FlagKey: "parent", Variant: "on"}}
+	return map[string]FlagData{
+		"parent": {Flag: *makeFlag("parent", false, model.LifecycleActive), Config: *makeConfig(true, parentVariant, onOff, nil)},
+		"child":  {Flag: *makeFlag("child", false, model.LifecycleActive), Config: *child},
+	}
+}
+
+func TestEngine_Prerequisite_Met(t *testing.T) {
+	engine := NewEngine()
+	flags := prerequisiteFlags("on")
+	child := flags["child"]
+
+	result := engine.Evaluate(&child.Flag, &child.Config, &model.EvaluationContext{UserID: "user-1"}, flags)
+	if result.Reason != "default" || result.Variant != "on" {
+		t.Errorf("expected default/on, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_Prerequisite_Failed(t *testing.T) {
+	engine := NewEngine()
+	flags := prerequisiteFlags("off")
+	child := flags["child"]
+
+	result := engine.Evaluate(&child.Flag, &child.Config, &model.EvaluationContext{UserID: "user-1"}, flags)
+	if result.Reason != "prerequisite_failed" {
+		t.Errorf("expected reason 'prerequisite_failed', got %q", result.Reason)
+	}
+	if result.Value != false {
+		t.Errorf("expected the flag default value false, got %v", result.Value)
+	}
+
+	// A prerequisite flag missing from the environment is never met.
+	delete(flags, "parent")
+	result = engine.Evaluate(&child.Flag, &child.Config, &model.EvaluationContext{UserID: "user-1"}, flags)
+	if result.Reason != "prerequisite_failed" {
+		t.Errorf("missing prerequisite: expected reason 'prerequisite_failed', got %q", result.Reason)
+	}
+}
+
+func TestEngine_Prerequisite_CycleFails(t *testing.T) {
+	engine := NewEngine()
+	flags := prerequisiteFlags("on")
+	parent := flags["parent"]
+	parent.Config.Prerequisites = []model.Prerequisite{{FlagKey: "child", Variant: "on"}}
+	flags["parent"] = parent
+	child := flags["child"]
+
+	result := engine.Evaluate(&child.Flag, &child.Config, &model.EvaluationContext{UserID: "user-1"}, flags)
+	if result.Reason != "prerequisite_failed" {
+		t.Errorf("expected reason 'prerequisite_failed', got %q", result.Reason)
+	}
+}
+
+// segmentConfig returns a config whose single rule serves "on" to contexts in
+// the given segment, with the segment resolved from segments.
+func segmentConfig(segmentKey string, segments map[string][]model.Condition) *model.FlagEnvironmentConfig {
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{{Operator: "in_segment", Value: segmentKey}},
+			Variant:    "on",
+		},
+	})
+	prepareConfig(config, DefaultInListSetThreshold, segments)
+	return config
+}
+
+func TestEngine_InSegment_ExpandsConditions(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("segmented", false, model.LifecycleActive)
+	segments := map[string][]model.Condition{
+		"enterprise-eu": {
+			{Attribute: "plan", Operator: "equals", Value: "enterprise"},
+			{Attribute: "region", Operator: "in", Value: []any{"de", "fr"}},
+		},
+	}
+	config := segmentConfig("enterprise-eu", segments)
+
+	tests := []struct {
+		name       string
+		attributes map[string]any
+		want       string
+	}{
+		{"all segment conditions match", map[string]any{"plan": "enterprise", "region": "de"}, "on"},
+		{"one segment condition fails", map[string]any{"plan": "enterprise", "region": "us"}, "off"},
+		{"no attributes", map[string]any{}, "off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "user-1", Attributes: tt.attributes}, nil)
+			if result.Variant != tt.want {
+				t.Errorf("expected variant %q, got %q (%s)", tt.want, result.Variant, result.Reason)
+			}
+		})
+	}
+}
+
+func TestEngine_InSegment_UnknownSegmentNeverMatches(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("segmented", false, model.LifecycleActive)
+	config := segmentConfig("missing", map[string][]model.Condition{})
+
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "user-1", Attributes: map[string]any{}}, nil)
+	if result.Reason != "default" || result.Variant != "off" {
+		t.Errorf("expected default/off, got %s/%s", result.Reason, result.Variant)
+	}
+}
+
+func TestEvaluateConditions_InSegment(t *testing.T) {
+	segments := map[string][]model.Condition{
+		"beta": {{Attribute: "beta", Operator: "equals", Value: "true"}},
+	}
+	config := segmentConfig("beta", segments)
+
+	results := EvaluateConditions(config.TargetingRules[0].Conditions, &model.EvaluationContext{Attributes: map[string]any{"beta": "true"}})
+	if len(results) != 1 || !results[0].Passed {
+		t.Fatalf("expected the in_segment condition to pass, got %+v", results)
+	}
+	// The resolved segment still serializes as its key.
+	raw, err := json.Marshal(results[0].Value)
+	if err != nil || string(raw) != `"beta"` {
+		t.Errorf("expected value to marshal as \"beta\", got %s (%v)", raw, err)
+	}
+}
+
+func companyRolloutConfig() *model.FlagEnvironmentConfig {
+	return makeConfig(true, "off", []model.Variant{
+		{Key: "on", Value: rawJSON(true)},
+		{Key: "off", Value: rawJSON(false)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(50), BucketBy: "company_id"},
+	})
+}
+
+func TestEngine_BucketBy_SameCompanySameBucket(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("org-rollout", false, model.LifecycleActive)
+	config := companyRolloutConfig()
+
+	inRollout := 0
+	for i := 0; i < 20; i++ {
+		company := fmt.Sprintf("company-%d", i)
+		want := "off"
+		if ConsistentHash(flag.Key, company) < 50 {
+			want = "on"
+			inRollout++
+		}
+		for _, userID := range []string{"alice-" + company, "bob-" + company, ""} {
+			ctx := &model.EvaluationContext{UserID: userID, Attributes: map[string]any{"company_id": company}}
+			result := engine.Evaluate(flag, config, ctx, nil)
+			if result.Variant != want {
+				t.Errorf("%s user %q: variant = %q, want %q", company, userID, result.Variant, want)
+			}
+			if want == "on" && result.Reason != "rule_match" {
+				t.Errorf("%s user %q: reason = %q, want rule_match", company, userID, result.Reason)
+			}
+		}
+	}
+	if inRollout == 0 || inRollout == 20 {
+		t.Fatalf("expected companies on both sides of the rollout, %d of 20 in", inRollout)
+	}
+}
+
+func TestEngine_BucketBy_StringifiesAttribute(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("org-rollout", false, model.LifecycleActive)
+	config := companyRolloutConfig()
+
+	// A numeric company ID buckets the same as its string form.
+	numeric := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "u1", Attributes: map[string]any{"company_id": float64(42)}}, nil)
+	str := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: "u2", Attributes: map[string]any{"company_id": "42"}}, nil)
+	if numeric.Variant != str.Variant {
+		t.Errorf("numeric company id variant %q, string %q", numeric.Variant, str.Variant)
+	}
+}
+
+func TestEngine_BucketBy_FallsBackToUserID(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("org-rollout", false, model.LifecycleActive)
+	config := companyRolloutConfig()
+	userID := userInBucket(t, flag.Key, 10)
+
+	for _, attrs := range []map[string]any{{}, {"company_id": ""}} {
+		result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: userID, Attributes: attrs}, nil)
+		if result.Variant != "on" || result.Reason != "rule_match_bucket_fallback" {
+			t.Errorf("attrs %v: got (%q, %q), want (on, rule_match_bucket_fallback)", attrs, result.Variant, result.Reason)
+		}
+	}
+
+	// Without bucket_by the reason is unchanged.
+	config.TargetingRules[0].BucketBy = ""
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{UserID: userID}, nil)
+	if result.Reason != "rule_match" {
+		t.Errorf("reason without bucket_by = %q, want rule_match", result.Reason)
+	}
+}
+
+func TestEngine_BucketBy_AnonymousControlUsesAttribute(t *testing.T) {
+	engine := NewEngine()
+	engine.SetAnonymousBucketing(AnonymousControl, nil)
+	flag := makeFlag("org-rollout", false, model.LifecycleActive)
+	config := companyRolloutConfig()
+
+	// No user ID, but the company ID is a stable bucketing key.
+	company := userInBucket(t, flag.Key, 10)
+	result := engine.Evaluate(flag, config, &model.EvaluationContext{Attributes: map[string]any{"company_id": company}}, nil)
+	if result.Variant != "on" || result.Reason != "rule_match" {
+		t.Errorf("got (%q, %q), want (on, rule_match)", result.Variant, result.Reason)
+	}
+
+	result = engine.Evaluate(flag, config, &model.EvaluationContext{Attributes: map[string]any{}}, nil)
+	if result.Reason != "anonymous_control" {
+		t.Errorf("reason without company id = %q, want anonymous_control", result.Reason)
+	}
+}
+
+func TestEngine_Salt_ChangesAssignmentStably(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("experiment", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "on", Value: rawJSON(true)},
+		{Key: "off", Value: rawJSON(false)},
+	}, []model.TargetingRule{
+		{Variant: "on", PercentageRollout: intPtr(50)},
+	})
+
+	// Pick a user inside the unsalted rollout but outside the salted one.
+	var userID string
+	for i := 0; i < 10000 && userID == ""; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		if ConsistentHash(flag.Key, id) < 50 && SaltedHash(flag.Key, "v2", id) >= 50 {
+			userID = id
+		}
+	}
+	if userID == "" {
+		t.Fatal("no user found whose assignment changes with the salt")
+	}
+	ctx := &model.EvaluationContext{UserID: userID}
+
+	if result := engine.Evaluate(flag, config, ctx, nil); result.Variant != "on" {
+		t.Errorf("unsalted: variant = %q, want on", result.Variant)
+	}
+	config.TargetingRules[0].Salt = "v2"
+	for i := 0; i < 3; i++ {
+		if result := engine.Evaluate(flag, config, ctx, nil); result.Variant != "off" {
+			t.Errorf("salted: variant = %q, want off", result.Variant)
+		}
+	}
+}
+
+func nestedOSFlag() (*model.Flag, *model.FlagEnvironmentConfig) {
+	flag := makeFlag("ios-banner", false, model.LifecycleActive)
+	config := makeConfig(true, "off", []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}, []model.TargetingRule{
+		{
+			Conditions: []model.Condition{{Attribute: "device.os", Operator: "equals", Value: "ios"}},
+			Variant:    "on",
+		},
+	})
+	return flag, config
+}
+
+func TestEngine_NestedAttributePath_Matches(t *testing.T) {
+	engine := NewEngine()
+	flag, config := nestedOSFlag()
+	ctx := &model.EvaluationContext{
+		UserID:     "user-1",
+		Attributes: map[string]any{"device": map[string]any{"os": "ios", "version": "17.2"}},
+	}
+
+	result := engine.Evaluate(flag, config, ctx, nil)
+
+	if result.Reason != "rule_match" || result.Variant != "on" {
+		t.Errorf("expected rule_match with variant on, got %q/%q", result.Reason, result.Variant)
+	}
+}
+
+func TestEngine_NestedAttributePath_MissingFallsThroughToDefault(t *testing.T) {
+	engine := NewEngine()
+	flag, config := nestedOSFlag()
+
+	for name, attrs := range map[string]map[string]any{
+		"missing parent":   {},
+		"missing leaf":     {"device": map[string]any{"model": "pixel"}},
+		"parent not a map": {"device": "ios"},
+	} {
+		ctx := &model.EvaluationContext{UserID: "user-1", Attributes: attrs}
+		result := engine.Evaluate(flag, config, ctx, nil)
+		if result.Reason != "default" || result.Variant != "off" {
+			t.Errorf("%s: expected default with variant off, got %q/%q", name, result.Reason, result.Variant)
+		}
+		if !conditionPasses(model.Condition{Attribute: "device.os", Operator: "not_exists"}, ctx) {
+			t.Errorf("%s: expected not_exists to pass for device.os", name)
+		}
+	}
+}
+
+func TestEngine_DottedAttributeName_PrefersFlatKey(t *testing.T) {
+	ctx := &model.EvaluationContext{
+		UserID: "user-1",
+		Attributes: map[string]any{
+			"app.version": "2.0",
+			"app":         map[string]any{"version": "1.0"},
+		},
+	}
+	if got := contextAttribute(ctx.Attributes, "app.version"); got != "2.0" {
+		t.Errorf("expected the flat attribute 2.0, got %v", got)
+	}
+	if got := contextAttribute(ctx.Attributes, "app"); got == nil {
+		t.Error("expected the non-dotted name to resolve to the nested object")
+	}
+}
@@ -13,3 +13,13 @@ func ConsistentHash(flagKey, userID string) int {
 	n := binary.BigEndian.Uint64(h[:8])
 	return int(n % 100)
 }
+
+// SaltedHash is ConsistentHash with a per-rule salt mixed into the input
+// (flagKey + ":" + salt + ":" + userID), so changing the salt reshuffles
+// assignments. An empty salt hashes exactly like ConsistentHash.
+func SaltedHash(flagKey, salt, userID string) int {
+	if salt == "" {
+		return ConsistentHash(flagKey, userID)
+	}
+	return ConsistentHash(flagKey+":"+salt+":", userID)
+}
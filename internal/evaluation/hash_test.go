@@ -84,3 +84,67 @@ func TestConsistentHash_Distribution(t *testing.T) {
 		}
 	}
 }
+
+func TestSaltedHash_EmptySaltMatchesConsistentHash(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if got, want := SaltedHash("flag", "", userID), ConsistentHash("flag", userID); got != want {
+			t.Fatalf("SaltedHash with empty salt = %d for %s, ConsistentHash = %d", got, userID, want)
+		}
+	}
+}
+
+func TestSaltedHash_Deterministic(t *testing.T) {
+	first := SaltedHash("feature-flag-1", "relaunch-2", "user-123")
+	for i := 0; i < 3; i++ {
+		if got := SaltedHash("feature-flag-1", "relaunch-2", "user-123"); got != first {
+			t.Fatalf("SaltedHash is not deterministic: %d then %d", first, got)
+		}
+	}
+}
+
+func TestSaltedHash_ReshufflesAssignments(t *testing.T) {
+	// With a 50% rollout, a new salt should move a large share of users
+	// across the rollout boundary, while keeping the overall split.
+	numUsers := 10000
+	inUnsalted, inSalted, moved := 0, 0, 0
+	for i := 0; i < numUsers; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		unsalted := ConsistentHash("experiment", userID) < 50
+		salted := SaltedHash("experiment", "v2", userID) < 50
+		if unsalted {
+			inUnsalted++
+		}
+		if salted {
+			inSalted++
+		}
+		if unsalted != salted {
+			moved++
+		}
+	}
+
+	for name, in := range map[string]int{"unsalted": inUnsalted, "salted": inSalted} {
+		if in < 4500 || in > 5500 {
+			t.Errorf("%s: %d of %d users in a 50%% rollout", name, in, numUsers)
+		}
+	}
+	// Independent assignments move about half the users.
+	if moved < 4000 || moved > 6000 {
+		t.Errorf("salt moved %d of %d users across the rollout boundary, want about half", moved, numUsers)
+	}
+}
+
+func TestSaltedHash_Distribution(t *testing.T) {
+	counts := make([]int, 100)
+	numUsers := 10000
+	for i := 0; i < numUsers; i++ {
+		counts[SaltedHash("distribution-test-flag", "salt", fmt.Sprintf("user-%d", i))]++
+	}
+
+	expected := numUsers / 100
+	for bucket, count := range counts {
+		if count == 0 || count > expected*2 {
+			t.Errorf("bucket %d has %d entries, expected between 1 and %d", bucket, count, expected*2)
+		}
+	}
+}
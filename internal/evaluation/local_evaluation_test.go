@@ -0,0 +1,65 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// TestEngine_MatchesSDKLocalEvaluationFixture evaluates the Go SDK's local
+// evaluation fixture with the server engine. The SDK checks its evaluator
+// against the same expectations, so together the tests keep both in step.
+func TestEngine_MatchesSDKLocalEvaluationFixture(t *testing.T) {
+	raw, err := os.ReadFile("../../sdks/go/testdata/local_evaluation.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var fx struct {
+		Rules struct {
+			Flags map[string]struct {
+				Flag          model.Flag                  `json:"flag"`
+				Config        model.FlagEnvironmentConfig `json:"config"`
+				UserOverrides map[string]string           `json:"user_overrides"`
+			} `json:"flags"`
+			Segments map[string][]model.Condition `json:"segments"`
+		} `json:"rules"`
+		Cases []struct {
+			Name     string                            `json:"name"`
+			Context  model.EvaluationContext           `json:"context"`
+			Expected map[string]model.EvaluationResult `json:"expected"`
+		} `json:"cases"`
+	}
+	if err := json.Unmarshal(raw, &fx); err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+
+	cache := NewCache()
+	cache.SetSegments("proj", fx.Rules.Segments)
+	flags := make(map[string]FlagData, len(fx.Rules.Flags))
+	for key, f := range fx.Rules.Flags {
+		f.Config.UserOverrides = f.UserOverrides
+		flags[key] = FlagData{Flag: f.Flag, Config: f.Config}
+	}
+	cache.Set("proj", "prod", flags)
+	envFlags := cache.GetFlags("proj", "prod")
+
+	engine := NewEngine()
+	for _, tc := range fx.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Context.Attributes == nil {
+				tc.Context.Attributes = map[string]any{}
+			}
+			for key, want := range tc.Expected {
+				fd := envFlags[key]
+				got := engine.Evaluate(&fd.Flag, &fd.Config, &tc.Context, envFlags)
+				if got.Variant != want.Variant || got.Reason != want.Reason || !reflect.DeepEqual(got.Value, want.Value) {
+					t.Errorf("%s: got (%v, %q, %q), fixture expects (%v, %q, %q)",
+						key, got.Value, got.Variant, got.Reason, want.Value, want.Variant, want.Reason)
+				}
+			}
+		})
+	}
+}
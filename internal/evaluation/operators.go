@@ -5,8 +5,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// now is the clock within_last compares against; injectable for testing.
+var now = time.Now
+
 // EvaluateCondition checks if an attribute value satisfies a condition.
 func EvaluateCondition(attributeValue any, operator string, conditionValue any) bool {
 	switch operator {
@@ -46,6 +50,24 @@ func EvaluateCondition(attributeValue any, operator string, conditionValue any)
 		pattern := toString(conditionValue)
 		matched, err := regexp.MatchString(pattern, toString(attributeValue))
 		return err == nil && matched
+	case "before":
+		a, b, ok := toTimePair(attributeValue, conditionValue)
+		return ok && a.Before(b)
+	case "after":
+		a, b, ok := toTimePair(attributeValue, conditionValue)
+		return ok && a.After(b)
+	case "within_last":
+		return evalWithinLast(attributeValue, conditionValue)
+	case "equals_ci":
+		return toLower(attributeValue) == toLower(conditionValue)
+	case "in_ci":
+		return evalInCI(attributeValue, conditionValue)
+	case "contains_ci":
+		return evalContainsCI(attributeValue, conditionValue)
+	case "starts_with_ci":
+		return strings.HasPrefix(toLower(attributeValue), toLower(conditionValue))
+	case "ends_with_ci":
+		return strings.HasSuffix(toLower(attributeValue), toLower(conditionValue))
 	default:
 		return false
 	}
@@ -59,6 +81,11 @@ func toString(v any) string {
 	return fmt.Sprintf("%v", v)
 }
 
+// toLower converts a value to its lowercased string representation.
+func toLower(v any) string {
+	return strings.ToLower(toString(v))
+}
+
 // toFloat64 attempts to convert a value to float64.
 func toFloat64(v any) (float64, bool) {
 	switch n := v.(type) {
@@ -97,6 +124,44 @@ func toFloat64(v any) (float64, bool) {
 	}
 }
 
+// toTime converts an RFC3339 timestamp or a number of seconds since the Unix
+// epoch (as a number or numeric string) to a time.
+func toTime(v any) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, true
+		}
+	}
+	secs, ok := toFloat64(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	whole := int64(secs)
+	return time.Unix(whole, int64((secs-float64(whole))*1e9)), true
+}
+
+// toTimePair converts both values to times.
+func toTimePair(a, b any) (time.Time, time.Time, bool) {
+	ta, okA := toTime(a)
+	tb, okB := toTime(b)
+	return ta, tb, okA && okB
+}
+
+// evalWithinLast checks that the attribute time lies within the duration
+// (e.g. "168h") before now, inclusive. Future times are not within it.
+func evalWithinLast(attributeValue, conditionValue any) bool {
+	t, ok := toTime(attributeValue)
+	if !ok {
+		return false
+	}
+	d, err := time.ParseDuration(toString(conditionValue))
+	if err != nil || d <= 0 {
+		return false
+	}
+	current := now()
+	return !t.After(current) && !t.Before(current.Add(-d))
+}
+
 // toFloat64Pair converts both values to float64.
 func toFloat64Pair(a, b any) (float64, float64, bool) {
 	fa, okA := toFloat64(a)
@@ -124,6 +189,9 @@ func evalContains(attributeValue, conditionValue any) bool {
 
 // evalIn checks if the attribute value is in the condition list.
 func evalIn(attributeValue, conditionValue any) bool {
+	if set, ok := conditionValue.(*ValueSet); ok {
+		return set.Contains(attributeValue)
+	}
 	list, ok := toSlice(conditionValue)
 	if !ok {
 		return false
@@ -137,6 +205,35 @@ func evalIn(attributeValue, conditionValue any) bool {
 	return false
 }
 
+// evalContainsCI is evalContains comparing lowercased values.
+func evalContainsCI(attributeValue, conditionValue any) bool {
+	if slice, ok := toSlice(attributeValue); ok {
+		target := toLower(conditionValue)
+		for _, item := range slice {
+			if toLower(item) == target {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(toLower(attributeValue), toLower(conditionValue))
+}
+
+// evalInCI is evalIn comparing lowercased values.
+func evalInCI(attributeValue, conditionValue any) bool {
+	list, ok := toSlice(conditionValue)
+	if !ok {
+		return false
+	}
+	target := toLower(attributeValue)
+	for _, item := range list {
+		if toLower(item) == target {
+			return true
+		}
+	}
+	return false
+}
+
 // toSlice attempts to convert a value to []any.
 func toSlice(v any) ([]any, bool) {
 	switch s := v.(type) {
@@ -2,6 +2,7 @@ package evaluation
 
 import (
 	"testing"
+	"time"
 )
 
 func TestEvaluateCondition_Equals(t *testing.T) {
@@ -368,6 +369,138 @@ func TestEvaluateCondition_Matches(t *testing.T) {
 	}
 }
 
+func TestEvaluateCondition_CaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name string
+		op   string
+		attr any
+		cond any
+		want bool
+	}{
+		{"equals_ci mixed case", "equals_ci", "Us", "US", true},
+		{"equals_ci lower vs upper", "equals_ci", "us", "US", true},
+		{"equals_ci mismatch", "equals_ci", "US", "UK", false},
+		{"equals_ci bool stringified", "equals_ci", true, "TRUE", true},
+		{"equals_ci int stringified", "equals_ci", 42, "42", true},
+		{"equals_ci nil vs empty", "equals_ci", nil, "", true},
+		{"in_ci mixed case", "in_ci", "us", []any{"US", "CA"}, true},
+		{"in_ci upper attribute", "in_ci", "FR", []any{"fr", "de"}, true},
+		{"in_ci not in list", "in_ci", "us", []any{"CA", "MX"}, false},
+		{"in_ci bool stringified", "in_ci", false, []any{"True", "False"}, true},
+		{"in_ci not a list", "in_ci", "us", "US", false},
+		{"contains_ci substring", "contains_ci", "hello@ACME.com", "acme", true},
+		{"contains_ci no match", "contains_ci", "hello@example.com", "ACME", false},
+		{"contains_ci slice", "contains_ci", []any{"Admin", "Editor"}, "admin", true},
+		{"contains_ci slice no match", "contains_ci", []any{"Admin"}, "viewer", false},
+		{"contains_ci float stringified", "contains_ci", 3.14, "3.1", true},
+		{"starts_with_ci", "starts_with_ci", "EN-us", "en", true},
+		{"starts_with_ci mismatch", "starts_with_ci", "fr-FR", "EN", false},
+		{"starts_with_ci int stringified", "starts_with_ci", 12345, "123", true},
+		{"ends_with_ci", "ends_with_ci", "user@Example.COM", "@example.com", true},
+		{"ends_with_ci mismatch", "ends_with_ci", "user@example.org", ".COM", false},
+		{"ends_with_ci bool stringified", "ends_with_ci", true, "UE", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateCondition(tt.attr, tt.op, tt.cond)
+			if got != tt.want {
+				t.Errorf("%s(%v, %v) = %v, want %v", tt.op, tt.attr, tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition_CaseSensitiveOperatorsUnchanged(t *testing.T) {
+	tests := []struct {
+		op   string
+		attr any
+		cond any
+	}{
+		{"equals", "us", "US"},
+		{"in", "us", []any{"US"}},
+		{"contains", "hello@ACME.com", "acme"},
+		{"starts_with", "EN-us", "en"},
+		{"ends_with", "user@Example.COM", "@example.com"},
+	}
+	for _, tt := range tests {
+		if EvaluateCondition(tt.attr, tt.op, tt.cond) {
+			t.Errorf("%s(%v, %v) matched across case", tt.op, tt.attr, tt.cond)
+		}
+	}
+}
+
+func TestEvaluateCondition_BeforeAfter(t *testing.T) {
+	// 2024-03-01T12:00:00Z is 1709294400 Unix seconds.
+	tests := []struct {
+		name string
+		op   string
+		attr any
+		cond any
+		want bool
+	}{
+		{"before rfc3339", "before", "2024-01-15T00:00:00Z", "2024-03-01T12:00:00Z", true},
+		{"before later", "before", "2024-06-01T00:00:00Z", "2024-03-01T12:00:00Z", false},
+		{"before equal", "before", "2024-03-01T12:00:00Z", "2024-03-01T12:00:00Z", false},
+		{"before offset timezone", "before", "2024-03-01T13:00:00+02:00", "2024-03-01T12:00:00Z", true},
+		{"before epoch attribute", "before", float64(1709294399), "2024-03-01T12:00:00Z", true},
+		{"before epoch condition", "before", "2024-03-01T11:59:59Z", float64(1709294400), true},
+		{"before epoch string", "before", "1709294399", "1709294400", true},
+		{"before int epoch", "before", int64(1709294399), "2024-03-01T12:00:00Z", true},
+		{"after rfc3339", "after", "2024-06-01T00:00:00Z", "2024-03-01T12:00:00Z", true},
+		{"after fractional seconds", "after", "2024-03-01T12:00:00.5Z", "2024-03-01T12:00:00Z", true},
+		{"after earlier", "after", "2024-01-15T00:00:00Z", "2024-03-01T12:00:00Z", false},
+		{"after equal", "after", float64(1709294400), "2024-03-01T12:00:00Z", false},
+		{"invalid attribute", "after", "next tuesday", "2024-03-01T12:00:00Z", false},
+		{"invalid condition", "before", "2024-01-15T00:00:00Z", "soon", false},
+		{"date without time", "before", "2024-01-15", "2024-03-01T12:00:00Z", false},
+		{"nil attribute", "before", nil, "2024-03-01T12:00:00Z", false},
+		{"bool attribute", "after", true, float64(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateCondition(tt.attr, tt.op, tt.cond)
+			if got != tt.want {
+				t.Errorf("%s(%v, %v) = %v, want %v", tt.op, tt.attr, tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition_WithinLast(t *testing.T) {
+	fixed := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	t.Cleanup(func() { now = time.Now })
+
+	tests := []struct {
+		name string
+		attr any
+		cond any
+		want bool
+	}{
+		{"one day ago within a week", "2024-03-07T12:00:00Z", "168h", true},
+		{"exactly at window start", "2024-03-01T12:00:00Z", "168h", true},
+		{"just before window", "2024-03-01T11:59:59Z", "168h", false},
+		{"now", "2024-03-08T12:00:00Z", "1h", true},
+		{"future", "2024-03-08T12:00:01Z", "168h", false},
+		{"epoch seconds", float64(fixed.Add(-30 * time.Minute).Unix()), "1h", true},
+		{"epoch seconds outside", float64(fixed.Add(-2 * time.Hour).Unix()), "1h", false},
+		{"minutes duration", "2024-03-08T11:45:00Z", "30m", true},
+		{"invalid timestamp", "last week", "168h", false},
+		{"invalid duration", "2024-03-07T12:00:00Z", "a week", false},
+		{"numeric duration", "2024-03-07T12:00:00Z", float64(168), false},
+		{"negative duration", "2024-03-07T12:00:00Z", "-168h", false},
+		{"nil attribute", nil, "168h", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateCondition(tt.attr, "within_last", tt.cond)
+			if got != tt.want {
+				t.Errorf("within_last(%v, %v) = %v, want %v", tt.attr, tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEvaluateCondition_UnknownOperator(t *testing.T) {
 	got := EvaluateCondition("hello", "unknown_op", "hello")
 	if got != false {
@@ -0,0 +1,110 @@
+package evaluation
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// ResultCache is a size-bounded LRU of evaluation results with a TTL. It
+// lets repeated evaluations of the same flag for the same context skip
+// rule evaluation. Keys include the Cache generation, so any flag change
+// invalidates earlier results; the TTL bounds how long results of
+// time-based conditions can be served.
+type ResultCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type resultEntry struct {
+	key     string
+	result  *model.EvaluationResult
+	expires time.Time
+}
+
+// NewResultCache creates a cache holding at most size results, each for at
+// most ttl.
+func NewResultCache(size int, ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// ResultKey builds the cache key for a flag of a project/environment
+// evaluated for the context hashed by ContextHash, at a Cache generation.
+func ResultKey(projectKey, envKey, flagKey, contextHash string, generation uint64) string {
+	return projectKey + "\x00" + envKey + "\x00" + flagKey + "\x00" + contextHash + "\x00" + strconv.FormatUint(generation, 10)
+}
+
+// ContextHash returns a stable hash of an evaluation context. Attribute
+// maps are encoded with sorted keys, so equal contexts hash equally.
+func ContextHash(ctx *model.EvaluationContext) string {
+	b, _ := json.Marshal(ctx)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Get returns the unexpired result cached under key. The result is shared
+// and must not be modified.
+func (c *ResultCache) Get(key string) (*model.EvaluationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*resultEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+// Add caches result under key, evicting the least recently used result
+// when the cache is full.
+func (c *ResultCache) Add(key string, result *model.EvaluationResult) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*resultEntry)
+		entry.result, entry.expires = result, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	for c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&resultEntry{key: key, result: result, expires: expires})
+}
+
+// Len returns the number of cached results, including expired ones not yet
+// evicted.
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
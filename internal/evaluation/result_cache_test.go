@@ -0,0 +1,73 @@
+package evaluation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+func TestResultCache_GetAfterAdd(t *testing.T) {
+	c := NewResultCache(10, time.Minute)
+	key := ResultKey("proj", "prod", "dark-mode", "abc", 1)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	c.Add(key, &model.EvaluationResult{Variant: "on"})
+	if got, ok := c.Get(key); !ok || got.Variant != "on" {
+		t.Errorf("Get = %+v, %v; want variant on", got, ok)
+	}
+	if _, ok := c.Get(ResultKey("proj", "prod", "dark-mode", "abc", 2)); ok {
+		t.Error("expected a miss for another generation")
+	}
+}
+
+func TestResultCache_Expires(t *testing.T) {
+	now := time.Now()
+	c := NewResultCache(10, time.Second)
+	c.now = func() time.Time { return now }
+	c.Add("k", &model.EvaluationResult{Variant: "on"})
+
+	now = now.Add(999 * time.Millisecond)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatal("expected a hit before the TTL")
+	}
+	now = now.Add(time.Millisecond)
+	if _, ok := c.Get("k"); ok {
+		t.Error("expected a miss once the TTL passed")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected the expired entry to be dropped, Len = %d", c.Len())
+	}
+}
+
+func TestResultCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewResultCache(2, time.Minute)
+	c.Add("a", &model.EvaluationResult{Variant: "a"})
+	c.Add("b", &model.EvaluationResult{Variant: "b"})
+	c.Get("a")
+	c.Add("c", &model.EvaluationResult{Variant: "c"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b, the least recently used, to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to stay cached", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestContextHash_IgnoresAttributeOrder(t *testing.T) {
+	a := ContextHash(&model.EvaluationContext{UserID: "u1", Attributes: map[string]any{"plan": "pro", "country": "DE"}})
+	b := ContextHash(&model.EvaluationContext{UserID: "u1", Attributes: map[string]any{"country": "DE", "plan": "pro"}})
+	if a != b {
+		t.Errorf("equal contexts hashed differently: %s vs %s", a, b)
+	}
+	if c := ContextHash(&model.EvaluationContext{UserID: "u2", Attributes: map[string]any{"plan": "pro", "country": "DE"}}); c == a {
+		t.Error("different users hashed equally")
+	}
+}
@@ -0,0 +1,119 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// SegmentRef is an in_segment condition value resolved to the segment's
+// conditions at cache-load time, so matching needs no lookup. It serializes
+// as the segment key, exactly as the condition was stored.
+type SegmentRef struct {
+	key        string
+	conditions []model.Condition
+}
+
+// MarshalJSON encodes the reference as its segment key.
+func (s *SegmentRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.key)
+}
+
+// segmentKey returns the segment key of an in_segment condition value,
+// whether or not it has been resolved.
+func segmentKey(v any) (string, bool) {
+	switch v := v.(type) {
+	case *SegmentRef:
+		return v.key, true
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
+// resolveSegments replaces the value of each in_segment condition with a
+// SegmentRef to the named segment. Conditions naming an unknown segment keep
+// their key and never match.
+func resolveSegments(conditions []model.Condition, segments map[string][]model.Condition) {
+	for j := range conditions {
+		cond := &conditions[j]
+		if cond.Operator != string(model.OpInSegment) {
+			continue
+		}
+		key, ok := segmentKey(cond.Value)
+		if !ok {
+			continue
+		}
+		if segConditions, found := segments[key]; found {
+			cond.Value = &SegmentRef{key: key, conditions: segConditions}
+		} else {
+			cond.Value = key
+		}
+	}
+}
+
+// usesSegment reports whether any targeting rule of cfg has an in_segment
+// condition naming key.
+func usesSegment(cfg *model.FlagEnvironmentConfig, key string) bool {
+	for i := range cfg.TargetingRules {
+		for _, conditions := range cfg.TargetingRules[i].ConditionLists() {
+			for _, cond := range conditions {
+				if cond.Operator != string(model.OpInSegment) {
+					continue
+				}
+				if k, ok := segmentKey(cond.Value); ok && k == key {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+const baseSegmentQuery = `
+SELECT p.key, s.key, s.conditions
+FROM segments s
+JOIN projects p ON p.id = s.project_id
+`
+
+// loadSegments loads segment conditions keyed by project key, then segment
+// key. A non-empty projectKey limits the query to that project. Conditions
+// are preprocessed with threshold like those of targeting rules.
+func loadSegments(ctx context.Context, pool Querier, projectKey string, threshold int) (map[string]map[string][]model.Condition, error) {
+	query, args := baseSegmentQuery, []any{}
+	if projectKey != "" {
+		query += " WHERE p.key = $1"
+		args = append(args, projectKey)
+	}
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cache segments query: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string][]model.Condition)
+	for rows.Next() {
+		var projKey, segKey string
+		var conditionsJSON []byte
+		if err := rows.Scan(&projKey, &segKey, &conditionsJSON); err != nil {
+			return nil, fmt.Errorf("cache segments scan: %w", err)
+		}
+		var conditions []model.Condition
+		if err := json.Unmarshal(conditionsJSON, &conditions); err != nil {
+			return nil, fmt.Errorf("unmarshal segment conditions: %w", err)
+		}
+		if threshold > 0 {
+			prepareConditions(conditions, threshold)
+		}
+		if result[projKey] == nil {
+			result[projKey] = make(map[string][]model.Condition)
+		}
+		result[projKey][segKey] = conditions
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cache segments rows: %w", err)
+	}
+	return result, nil
+}
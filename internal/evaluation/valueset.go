@@ -0,0 +1,71 @@
+package evaluation
+
+import (
+	"encoding/json"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// DefaultInListSetThreshold is the minimum number of elements an in/not_in
+// condition list must have before it is converted to a set at cache-load time.
+const DefaultInListSetThreshold = 32
+
+// ValueSet is a preprocessed in/not_in condition list. It keeps the original
+// list (so the condition serializes exactly as it was stored) alongside a
+// set of stringified members for O(1) membership checks.
+type ValueSet struct {
+	items   []any
+	members map[string]struct{}
+}
+
+// NewValueSet builds a ValueSet from a condition list, stringifying each
+// element the same way evalIn does.
+func NewValueSet(items []any) *ValueSet {
+	members := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		members[toString(item)] = struct{}{}
+	}
+	return &ValueSet{items: items, members: members}
+}
+
+// Contains reports whether the stringified value is a member of the set.
+func (s *ValueSet) Contains(v any) bool {
+	_, ok := s.members[toString(v)]
+	return ok
+}
+
+// MarshalJSON encodes the set as its original list.
+func (s *ValueSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.items)
+}
+
+// prepareConfig resolves in_segment conditions in a config's targeting rules
+// against segments and converts large in/not_in condition lists into
+// ValueSets. Lists shorter than threshold are left as-is, since a linear scan
+// is cheaper than building a map for them. A threshold of zero or less
+// disables the conversion.
+func prepareConfig(cfg *model.FlagEnvironmentConfig, threshold int, segments map[string][]model.Condition) {
+	for i := range cfg.TargetingRules {
+		for _, conditions := range cfg.TargetingRules[i].ConditionLists() {
+			resolveSegments(conditions, segments)
+			if threshold > 0 {
+				prepareConditions(conditions, threshold)
+			}
+		}
+	}
+}
+
+// prepareConditions converts qualifying condition values in place.
+func prepareConditions(conditions []model.Condition, threshold int) {
+	for j := range conditions {
+		cond := &conditions[j]
+		if cond.Operator != string(model.OpIn) && cond.Operator != string(model.OpNotIn) {
+			continue
+		}
+		list, ok := toSlice(cond.Value)
+		if !ok || len(list) < threshold {
+			continue
+		}
+		cond.Value = NewValueSet(list)
+	}
+}
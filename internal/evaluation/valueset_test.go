@@ -0,0 +1,116 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+func largeIDList(n int) []any {
+	list := make([]any, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("user-%d", i)
+	}
+	return list
+}
+
+func TestPrepareConfig_ConvertsLargeLists(t *testing.T) {
+	cfg := makeConfig(true, "off", nil, []model.TargetingRule{
+		{Conditions: []model.Condition{
+			{Attribute: "id", Operator: "in", Value: largeIDList(100)},
+			{Attribute: "plan", Operator: "in", Value: []any{"pro", "enterprise"}},
+			{Attribute: "id", Operator: "not_in", Value: largeIDList(50)},
+			{Attribute: "country", Operator: "equals", Value: "US"},
+		}},
+	})
+
+	prepareConfig(cfg, 32, nil)
+
+	conds := cfg.TargetingRules[0].Conditions
+	if _, ok := conds[0].Value.(*ValueSet); !ok {
+		t.Errorf("expected large in list to become a ValueSet, got %T", conds[0].Value)
+	}
+	if _, ok := conds[1].Value.([]any); !ok {
+		t.Errorf("expected small in list to stay a slice, got %T", conds[1].Value)
+	}
+	if _, ok := conds[2].Value.(*ValueSet); !ok {
+		t.Errorf("expected large not_in list to become a ValueSet, got %T", conds[2].Value)
+	}
+	if _, ok := conds[3].Value.(string); !ok {
+		t.Errorf("expected equals value to be untouched, got %T", conds[3].Value)
+	}
+}
+
+func TestPrepareConfig_DisabledThreshold(t *testing.T) {
+	cfg := makeConfig(true, "off", nil, []model.TargetingRule{
+		{Conditions: []model.Condition{{Attribute: "id", Operator: "in", Value: largeIDList(100)}}},
+	})
+
+	prepareConfig(cfg, 0, nil)
+
+	if _, ok := cfg.TargetingRules[0].Conditions[0].Value.([]any); !ok {
+		t.Errorf("expected list to stay a slice when disabled, got %T", cfg.TargetingRules[0].Conditions[0].Value)
+	}
+}
+
+func TestValueSet_MarshalsAsOriginalList(t *testing.T) {
+	list := []any{"a", float64(2), true}
+	got, err := json.Marshal(model.Condition{Attribute: "x", Operator: "in", Value: NewValueSet(list)})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want, _ := json.Marshal(model.Condition{Attribute: "x", Operator: "in", Value: list})
+	if string(got) != string(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestEngine_InSetMatchesScan(t *testing.T) {
+	engine := NewEngine()
+	flag := makeFlag("allowlist", false, model.LifecycleActive)
+	variants := []model.Variant{
+		{Key: "off", Value: rawJSON(false)},
+		{Key: "on", Value: rawJSON(true)},
+	}
+	ids := largeIDList(1000)
+	ids = append(ids, float64(42))
+
+	for _, op := range []string{"in", "not_in"} {
+		rules := func() []model.TargetingRule {
+			return []model.TargetingRule{{
+				Conditions: []model.Condition{{Attribute: "id", Operator: op, Value: ids}},
+				Variant:    "on",
+			}}
+		}
+		scanCfg := makeConfig(true, "off", variants, rules())
+		setCfg := makeConfig(true, "off", variants, rules())
+		prepareConfig(setCfg, 32, nil)
+
+		for _, id := range []any{"user-0", "user-999", "user-1000", "", 42, "42", nil} {
+			ctx := &model.EvaluationContext{Attributes: map[string]any{"id": id}}
+			scan := engine.Evaluate(flag, scanCfg, ctx, nil)
+			set := engine.Evaluate(flag, setCfg, ctx, nil)
+			if scan.Variant != set.Variant || scan.Reason != set.Reason || scan.Value != set.Value {
+				t.Errorf("%s %v: scan=%+v set=%+v", op, id, scan, set)
+			}
+		}
+	}
+}
+
+func BenchmarkEvalIn_Scan(b *testing.B) {
+	list := largeIDList(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EvaluateCondition("user-4999", "in", list)
+	}
+}
+
+func BenchmarkEvalIn_Set(b *testing.B) {
+	set := NewValueSet(largeIDList(5000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EvaluateCondition("user-4999", "in", set)
+	}
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSSink publishes events as JSON messages to a NATS subject using the core
+// NATS text protocol. It connects lazily and reconnects on the next publish
+// after a connection failure.
+type NATSSink struct {
+	addr    string
+	subject string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewNATSSink creates a sink publishing to subject on the server at natsURL
+// (e.g. "nats://localhost:4222").
+func NewNATSSink(natsURL, subject string) (*NATSSink, error) {
+	u, err := url.Parse(natsURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q", natsURL)
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("invalid NATS subject %q", subject)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return &NATSSink{addr: addr, subject: subject, timeout: 5 * time.Second}, nil
+}
+
+// Publish sends the event as a single NATS message.
+func (s *NATSSink) Publish(ctx context.Context, event EvaluationEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\n", s.subject, len(payload), payload)
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("publishing to NATS: %w", err)
+	}
+	return nil
+}
+
+// connect dials the server, reads its INFO line, and sends CONNECT.
+// Must be called with s.mu held.
+func (s *NATSSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to NATS: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(s.timeout))
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("NATS handshake: unexpected greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	connectMsg := "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"togglerino\"}\r\n"
+	if _, err := conn.Write([]byte(connectMsg)); err != nil {
+		conn.Close()
+		return fmt.Errorf("NATS handshake: %w", err)
+	}
+
+	s.conn = conn
+	go s.readLoop(conn, reader)
+	return nil
+}
+
+// readLoop answers server PINGs so the connection stays alive, and clears
+// the connection when the server closes it.
+func (s *NATSSink) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			s.mu.Lock()
+			if s.conn == conn {
+				s.conn = nil
+			}
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			s.mu.Lock()
+			conn.SetWriteDeadline(time.Now().Add(s.timeout))
+			conn.Write([]byte("PONG\r\n"))
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close closes the connection, if any.
+func (s *NATSSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,95 @@
+// Package events publishes flag evaluation events to external sinks for
+// analytics pipelines.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// EvaluationEvent describes a single flag evaluation served to an SDK.
+type EvaluationEvent struct {
+	Type           string    `json:"type"`
+	ProjectKey     string    `json:"project_key"`
+	EnvironmentKey string    `json:"environment_key"`
+	FlagKey        string    `json:"flag_key"`
+	UserID         string    `json:"user_id"`
+	Variant        string    `json:"variant"`
+	Value          any       `json:"value"`
+	Reason         string    `json:"reason"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// EventTypeEvaluation is the Type of events emitted for served evaluations.
+const EventTypeEvaluation = "evaluation"
+
+// Sink receives evaluation events. Implementations must be safe for
+// concurrent use.
+type Sink interface {
+	Publish(ctx context.Context, event EvaluationEvent) error
+	Close() error
+}
+
+// NoopSink discards all events. It is the default when no sink is configured.
+type NoopSink struct{}
+
+// Publish discards the event.
+func (NoopSink) Publish(context.Context, EvaluationEvent) error { return nil }
+
+// Close does nothing.
+func (NoopSink) Close() error { return nil }
+
+// AsyncSink buffers events and publishes them to an underlying sink from a
+// background goroutine, so callers never block on the broker. Events are
+// dropped (and logged) when the buffer is full.
+type AsyncSink struct {
+	next      Sink
+	queue     chan EvaluationEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAsyncSink wraps next with a buffer of the given size and starts the
+// publishing goroutine.
+func NewAsyncSink(next Sink, buffer int) *AsyncSink {
+	s := &AsyncSink{
+		next:  next,
+		queue: make(chan EvaluationEvent, buffer),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *AsyncSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.next.Publish(context.Background(), event); err != nil {
+			slog.Warn("failed to publish evaluation event", "flag_key", event.FlagKey, "error", err)
+		}
+	}
+}
+
+// Publish enqueues the event without blocking.
+func (s *AsyncSink) Publish(_ context.Context, event EvaluationEvent) error {
+	select {
+	case s.queue <- event:
+	default:
+		slog.Warn("evaluation event buffer full, dropping event", "flag_key", event.FlagKey)
+	}
+	return nil
+}
+
+// Close drains buffered events, then closes the underlying sink.
+// Publish must not be called after Close.
+func (s *AsyncSink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.queue)
+		<-s.done
+		err = s.next.Close()
+	})
+	return err
+}
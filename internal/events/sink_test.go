@@ -0,0 +1,135 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []EvaluationEvent
+	closed bool
+}
+
+func (r *recordingSink) Publish(_ context.Context, event EvaluationEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingSink) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestAsyncSink_DeliversBufferedEventsOnClose(t *testing.T) {
+	next := &recordingSink{}
+	sink := NewAsyncSink(next, 10)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := sink.Publish(context.Background(), EvaluationEvent{FlagKey: key}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if len(next.events) != 3 {
+		t.Fatalf("expected 3 events delivered, got %d", len(next.events))
+	}
+	if !next.closed {
+		t.Error("expected underlying sink to be closed")
+	}
+}
+
+func TestNATSSink_PublishesJSONToSubject(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	type received struct {
+		subject string
+		payload string
+	}
+	got := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(line, "PUB ") {
+				continue
+			}
+			fields := strings.Fields(line)
+			payload, _ := r.ReadString('\n')
+			got <- received{subject: fields[1], payload: strings.TrimSpace(payload)}
+			return
+		}
+	}()
+
+	sink, err := NewNATSSink("nats://"+ln.Addr().String(), "togglerino.evaluations")
+	if err != nil {
+		t.Fatalf("NewNATSSink: %v", err)
+	}
+	defer sink.Close()
+
+	event := EvaluationEvent{
+		Type:           EventTypeEvaluation,
+		ProjectKey:     "web",
+		EnvironmentKey: "production",
+		FlagKey:        "dark-mode",
+		UserID:         "user-1",
+		Variant:        "on",
+		Value:          true,
+		Reason:         "default",
+		Timestamp:      time.Now().UTC(),
+	}
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case msg := <-got:
+		if msg.subject != "togglerino.evaluations" {
+			t.Errorf("subject = %q, want %q", msg.subject, "togglerino.evaluations")
+		}
+		var decoded EvaluationEvent
+		if err := json.Unmarshal([]byte(msg.payload), &decoded); err != nil {
+			t.Fatalf("decoding payload %q: %v", msg.payload, err)
+		}
+		if decoded.FlagKey != "dark-mode" || decoded.UserID != "user-1" || decoded.Variant != "on" {
+			t.Errorf("unexpected payload: %+v", decoded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for published message")
+	}
+}
+
+func TestNewNATSSink_RejectsInvalidConfig(t *testing.T) {
+	if _, err := NewNATSSink("not a url", "subject"); err == nil {
+		t.Error("expected error for invalid URL")
+	}
+	if _, err := NewNATSSink("nats://localhost:4222", "bad subject"); err == nil {
+		t.Error("expected error for subject with whitespace")
+	}
+}
@@ -1,8 +1,14 @@
 package handler
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
@@ -18,6 +24,9 @@ func NewAuditHandler(audit *store.AuditStore, projects *store.ProjectStore) *Aud
 }
 
 // List handles GET /api/v1/projects/{key}/audit-log?limit=50&offset=0
+// Optional filters: action, entity_type, entity_id, user_id, and RFC 3339
+// from/to bounds. The total number of matching entries is returned in the
+// X-Total-Count header.
 func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
@@ -45,7 +54,12 @@ func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	entries, err := h.audit.ListByProject(r.Context(), project.ID, limit, offset)
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.audit.ListByProject(r.Context(), project.ID, filter, limit, offset)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list audit log")
 		return
@@ -54,5 +68,131 @@ func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
 		entries = []model.AuditEntry{}
 	}
 
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	writeJSON(w, http.StatusOK, entries)
 }
+
+// Export handles GET /api/v1/projects/{key}/audit-log/export?format=csv|json
+// It streams every matching entry as a file download; the same filters as
+// List apply, but there is no pagination.
+func (h *AuditHandler) Export(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, http.StatusBadRequest, "format must be csv or json")
+		return
+	}
+
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-audit-log.%s"`, project.Key, format))
+	if format == "csv" {
+		err = h.exportCSV(w, r, project.ID, filter)
+	} else {
+		err = h.exportJSON(w, r, project.ID, filter)
+	}
+	if err != nil {
+		// Headers are already sent, so the download is simply cut short.
+		slog.Warn("failed to export audit log", "project", project.Key, "error", err)
+	}
+}
+
+func (h *AuditHandler) exportCSV(w http.ResponseWriter, r *http.Request, projectID string, filter store.AuditFilter) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "created_at", "user_id", "action", "entity_type", "entity_id", "old_value", "new_value", "diff", "request_id"}); err != nil {
+		return err
+	}
+	err := h.audit.Export(r.Context(), projectID, filter, func(e model.AuditEntry) error {
+		userID := ""
+		if e.UserID != nil {
+			userID = *e.UserID
+		}
+		return cw.Write([]string{
+			e.ID,
+			e.CreatedAt.UTC().Format(time.RFC3339Nano),
+			userID,
+			e.Action,
+			e.EntityType,
+			e.EntityID,
+			string(e.OldValue),
+			string(e.NewValue),
+			string(e.Diff),
+			e.RequestID,
+		})
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func (h *AuditHandler) exportJSON(w http.ResponseWriter, r *http.Request, projectID string, filter store.AuditFilter) error {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := h.audit.Export(r.Context(), projectID, filter, func(e model.AuditEntry) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return err
+}
+
+// parseAuditFilter reads the audit log filter query parameters, writing a 400
+// if a time bound is malformed.
+func parseAuditFilter(w http.ResponseWriter, r *http.Request) (store.AuditFilter, bool) {
+	q := r.URL.Query()
+	filter := store.AuditFilter{
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+		UserID:     q.Get("user_id"),
+	}
+	for _, bound := range []struct {
+		param string
+		dst   **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, bound.param+" must be an RFC 3339 timestamp")
+			return store.AuditFilter{}, false
+		}
+		*bound.dst = &t
+	}
+	return filter, true
+}
@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestAuditHandler_ExportCSV(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	audit := store.NewAuditStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("audit-export"), "Audit Export", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	err = audit.Record(ctx, model.AuditEntry{
+		ProjectID:  &project.ID,
+		Action:     "update",
+		EntityType: "flag",
+		EntityID:   "checkout",
+		OldValue:   json.RawMessage(`{"enabled":false}`),
+		NewValue:   json.RawMessage(`{"enabled":true}`),
+	})
+	if err != nil {
+		t.Fatalf("recording audit entry: %v", err)
+	}
+
+	h := handler.NewAuditHandler(audit, projects)
+	req := httptest.NewRequest(http.MethodGet, "/?format=csv", nil)
+	req.SetPathValue("key", project.Key)
+	rec := httptest.NewRecorder()
+	h.Export(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv content type, got %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("expected attachment disposition, got %q", cd)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing csv: %v", err)
+	}
+	want := []string{"id", "created_at", "user_id", "action", "entity_type", "entity_id", "old_value", "new_value", "diff", "request_id"}
+	if len(records) != 2 {
+		t.Fatalf("expected header and 1 row, got %d records", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected header row %v", records[0])
+	}
+	row := records[1]
+	if row[5] != "checkout" {
+		t.Errorf("expected entity_id checkout, got %q", row[5])
+	}
+	var newValue map[string]bool
+	if err := json.Unmarshal([]byte(row[7]), &newValue); err != nil || !newValue["enabled"] {
+		t.Errorf("expected new_value as a JSON string column, got %q", row[7])
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,14 +10,35 @@ import (
 	"github.com/togglerino/togglerino/internal/store"
 )
 
+// DefaultSessionDuration is the absolute lifetime of a new session.
+const DefaultSessionDuration = 7 * 24 * time.Hour
+
 type AuthHandler struct {
 	users    *store.UserStore
 	sessions *store.SessionStore
 	invites  *store.InviteStore
+	// sessionDuration is the absolute lifetime of new and refreshed sessions.
+	sessionDuration time.Duration
+	// passwords is the policy for passwords chosen at setup, invite
+	// acceptance and reset.
+	passwords auth.PasswordPolicy
+	// oidc is nil unless single sign-on is configured.
+	oidc       *auth.OIDCProvider
+	oidcAdmins []string
 }
 
 func NewAuthHandler(users *store.UserStore, sessions *store.SessionStore, invites *store.InviteStore) *AuthHandler {
-	return &AuthHandler{users: users, sessions: sessions, invites: invites}
+	return &AuthHandler{users: users, sessions: sessions, invites: invites, sessionDuration: DefaultSessionDuration, passwords: auth.DefaultPasswordPolicy}
+}
+
+// SetPasswordPolicy sets the rules passwords chosen by users must satisfy.
+func (h *AuthHandler) SetPasswordPolicy(policy auth.PasswordPolicy) {
+	h.passwords = policy
+}
+
+// SetSessionDuration sets how long sessions last after login or refresh.
+func (h *AuthHandler) SetSessionDuration(d time.Duration) {
+	h.sessionDuration = d
 }
 
 // POST /api/v1/auth/setup — create the initial admin user (only works when no users exist)
@@ -33,6 +55,10 @@ func (h *AuthHandler) Setup(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "email and password are required")
 		return
 	}
+	if err := auth.ValidatePassword(req.Password, h.passwords); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	count, err := h.users.Count(r.Context())
 	if err != nil {
@@ -56,20 +82,13 @@ func (h *AuthHandler) Setup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := h.sessions.Create(r.Context(), user.ID, 7*24*time.Hour)
+	session, err := h.sessions.Create(r.Context(), user.ID, h.sessionDuration)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create session")
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    session.ID,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   7 * 24 * 60 * 60,
-	})
+	h.setSessionCookie(w, session.ID)
 
 	writeJSON(w, http.StatusCreated, user)
 }
@@ -96,22 +115,50 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := h.sessions.Create(r.Context(), user.ID, 7*24*time.Hour)
+	session, err := h.sessions.Create(r.Context(), user.ID, h.sessionDuration)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create session")
 		return
 	}
 
+	h.setSessionCookie(w, session.ID)
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// setSessionCookie sets the session cookie issued by every successful login.
+func (h *AuthHandler) setSessionCookie(w http.ResponseWriter, sessionID string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_id",
-		Value:    session.ID,
+		Value:    sessionID,
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   7 * 24 * 60 * 60,
+		MaxAge:   int(h.sessionDuration.Seconds()),
 	})
+}
 
-	writeJSON(w, http.StatusOK, user)
+// POST /api/v1/auth/refresh — extends the current session by the session
+// duration (requires an active session)
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	session, err := h.sessions.Extend(r.Context(), cookie.Value, h.sessionDuration)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
+	}
+
+	h.setSessionCookie(w, session.ID)
+	writeJSON(w, http.StatusOK, map[string]any{"expires_at": session.ExpiresAt})
 }
 
 // POST /api/v1/auth/logout
@@ -151,6 +198,7 @@ func (h *AuthHandler) Status(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"setup_required": count == 0,
+		"oidc_enabled":   h.oidc != nil,
 	})
 }
 
@@ -172,8 +220,8 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "password is required")
 		return
 	}
-	if len(req.Password) < 8 {
-		writeError(w, http.StatusBadRequest, "password must be at least 8 characters")
+	if err := auth.ValidatePassword(req.Password, h.passwords); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -238,8 +286,8 @@ func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "password is required")
 		return
 	}
-	if len(req.Password) < 8 {
-		writeError(w, http.StatusBadRequest, "password must be at least 8 characters")
+	if err := auth.ValidatePassword(req.Password, h.passwords); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
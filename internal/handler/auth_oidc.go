@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// oidcStateCookie carries the state parameter between login and callback.
+const oidcStateCookie = "oidc_state"
+
+// SetOIDC enables single sign-on through provider. Users created on first
+// login get RoleMember, or RoleAdmin if their email is in adminEmails.
+func (h *AuthHandler) SetOIDC(provider *auth.OIDCProvider, adminEmails []string) {
+	h.oidc = provider
+	h.oidcAdmins = nil
+	for _, email := range adminEmails {
+		h.oidcAdmins = append(h.oidcAdmins, strings.ToLower(email))
+	}
+}
+
+// GET /api/v1/auth/oidc/login — redirect to the identity provider
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		writeError(w, http.StatusNotFound, "oidc login is not configured")
+		return
+	}
+
+	state, err := auth.NewOIDCState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	target, err := h.oidc.AuthCodeURL(r.Context(), state)
+	if err != nil {
+		slog.Warn("oidc discovery failed", "error", err)
+		writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   10 * 60,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// GET /api/v1/auth/oidc/callback — finish login, provisioning the user on first visit
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		writeError(w, http.StatusNotFound, "oidc login is not configured")
+		return
+	}
+
+	// The state is single-use whatever the outcome
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/api/v1/auth/oidc",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+
+	if r.URL.Query().Get("error") != "" {
+		writeError(w, http.StatusUnauthorized, "login was denied by the identity provider")
+		return
+	}
+	cookie, err := r.Cookie(oidcStateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		writeError(w, http.StatusBadRequest, "invalid login state")
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	identity, err := h.oidc.Exchange(r.Context(), code)
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		writeError(w, http.StatusForbidden, "email address is not verified")
+		return
+	}
+	if err != nil {
+		slog.Warn("oidc code exchange failed", "error", err)
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	user, err := h.users.FindByOIDCIdentity(r.Context(), identity.Issuer, identity.Subject)
+	if errors.Is(err, store.ErrNotFound) {
+		user, err = h.provisionOIDCUser(r, identity)
+	}
+	if errors.Is(err, errOIDCEmailTaken) {
+		writeError(w, http.StatusConflict, "an account with this email already exists")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+
+	session, err := h.sessions.Create(r.Context(), user.ID, h.sessionDuration)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create session")
+		return
+	}
+	h.setSessionCookie(w, session.ID)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// errOIDCEmailTaken means a first OIDC login matched, by email, an account
+// that has a password or another identity. Such accounts are never linked
+// automatically.
+var errOIDCEmailTaken = errors.New("email belongs to another account")
+
+// provisionOIDCUser creates the user for an identity seen for the first time.
+// A passwordless, unlinked account with the same email (provisioned before
+// identities were stored) is linked instead; any other match is refused.
+func (h *AuthHandler) provisionOIDCUser(r *http.Request, identity *auth.OIDCIdentity) (*model.User, error) {
+	existing, err := h.users.FindByEmail(r.Context(), identity.Email)
+	if err == nil {
+		if existing.PasswordHash != "" {
+			return nil, errOIDCEmailTaken
+		}
+		err := h.users.LinkOIDCIdentity(r.Context(), existing.ID, identity.Issuer, identity.Subject)
+		if errors.Is(err, store.ErrAlreadyExists) {
+			return nil, errOIDCEmailTaken
+		}
+		if err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	role := model.RoleMember
+	if slices.Contains(h.oidcAdmins, strings.ToLower(identity.Email)) {
+		role = model.RoleAdmin
+	}
+	// No password hash: the account can only sign in through the provider
+	// until a password is set via a reset link.
+	return h.users.CreateWithOIDCIdentity(r.Context(), identity.Email, role, identity.Issuer, identity.Subject)
+}
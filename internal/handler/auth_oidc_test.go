@@ -0,0 +1,203 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// mockOIDCProvider serves discovery, a token endpoint that accepts any code
+// and a userinfo endpoint returning email, verified, with a subject derived
+// from it.
+func mockOIDCProvider(t *testing.T, email string) *auth.OIDCProvider {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"authorization_endpoint": srv.URL + "/authorize",
+				"token_endpoint":         srv.URL + "/token",
+				"userinfo_endpoint":      srv.URL + "/userinfo",
+			})
+		case "/token":
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "at-" + r.FormValue("code")})
+		case "/userinfo":
+			json.NewEncoder(w).Encode(map[string]any{"sub": "sub-" + email, "email": email, "email_verified": true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return auth.NewOIDCProvider(auth.OIDCConfig{
+		IssuerURL:    srv.URL,
+		ClientID:     "togglerino",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/callback",
+	})
+}
+
+// oidcLogin runs the login endpoint and returns the redirect target's state
+// together with the state cookie.
+func oidcLogin(t *testing.T, h *handler.AuthHandler) (string, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.OIDCLogin(rec, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: expected %d, got %d: %s", http.StatusFound, rec.Code, rec.Body.String())
+	}
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing redirect: %v", err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "oidc_state" {
+			return target.Query().Get("state"), c
+		}
+	}
+	t.Fatal("login did not set the state cookie")
+	return "", nil
+}
+
+func oidcCallback(h *handler.AuthHandler, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.OIDCCallback(rec, req)
+	return rec
+}
+
+func TestAuthHandler_OIDCNotConfigured(t *testing.T) {
+	pool := testPool(t)
+	h := handler.NewAuthHandler(store.NewUserStore(pool), store.NewSessionStore(pool), store.NewInviteStore(pool))
+
+	rec := httptest.NewRecorder()
+	h.OIDCLogin(rec, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAuthHandler_OIDCCallback_RejectsStateMismatch(t *testing.T) {
+	pool := testPool(t)
+	h := handler.NewAuthHandler(store.NewUserStore(pool), store.NewSessionStore(pool), store.NewInviteStore(pool))
+	h.SetOIDC(mockOIDCProvider(t, "nobody@test.togglerino.dev"), nil)
+
+	_, cookie := oidcLogin(t, h)
+	if rec := oidcCallback(h, "forged", cookie); rec.Code != http.StatusBadRequest {
+		t.Errorf("forged state: expected %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := oidcCallback(h, cookie.Value, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing cookie: expected %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestAuthHandler_OIDCCallback_ProvisionsUser(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	users := store.NewUserStore(pool)
+	sessions := store.NewSessionStore(pool)
+
+	for _, tc := range []struct {
+		name   string
+		admins []string
+		want   model.Role
+	}{
+		{name: "member", want: model.RoleMember},
+		{name: "admin", admins: []string{"first@example.com", "ADMIN-%d@test.togglerino.dev"}, want: model.RoleAdmin},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stamp := time.Now().UnixNano()
+			email := fmt.Sprintf("%s-%d@test.togglerino.dev", tc.name, stamp)
+			var admins []string
+			for _, a := range tc.admins {
+				admins = append(admins, fmt.Sprintf(a, stamp))
+			}
+
+			h := handler.NewAuthHandler(users, sessions, store.NewInviteStore(pool))
+			h.SetOIDC(mockOIDCProvider(t, email), admins)
+
+			state, cookie := oidcLogin(t, h)
+			rec := oidcCallback(h, state, cookie)
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+				t.Fatalf("callback: expected redirect to /, got %d %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body.String())
+			}
+
+			user, err := users.FindByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("expected user to be provisioned: %v", err)
+			}
+			if user.Role != tc.want {
+				t.Errorf("role = %q, want %q", user.Role, tc.want)
+			}
+
+			var sessionID string
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "session_id" {
+					sessionID = c.Value
+				}
+			}
+			session, err := sessions.FindByID(ctx, sessionID)
+			if err != nil || session.UserID != user.ID {
+				t.Fatalf("expected a session for the user, got %+v, %v", session, err)
+			}
+
+			// A second login finds the same user instead of creating another
+			state, cookie = oidcLogin(t, h)
+			if rec := oidcCallback(h, state, cookie); rec.Code != http.StatusFound {
+				t.Fatalf("second callback: expected %d, got %d", http.StatusFound, rec.Code)
+			}
+			again, err := users.FindByEmail(ctx, email)
+			if err != nil || again.ID != user.ID {
+				t.Errorf("expected the existing user on second login, got %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_OIDCCallback_RefusesPasswordAccount(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	users := store.NewUserStore(pool)
+
+	email := fmt.Sprintf("password-%d@test.togglerino.dev", time.Now().UnixNano())
+	existing, err := users.Create(ctx, email, "hashed", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	h := handler.NewAuthHandler(users, store.NewSessionStore(pool), store.NewInviteStore(pool))
+	provider := mockOIDCProvider(t, email)
+	h.SetOIDC(provider, nil)
+
+	state, cookie := oidcLogin(t, h)
+	rec := oidcCallback(h, state, cookie)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session_id" && c.Value != "" {
+			t.Error("expected no session for a refused login")
+		}
+	}
+
+	identity, err := provider.Exchange(ctx, "abc")
+	if err != nil {
+		t.Fatalf("exchanging code: %v", err)
+	}
+	if user, err := users.FindByOIDCIdentity(ctx, identity.Issuer, identity.Subject); err == nil {
+		t.Errorf("expected the identity to stay unlinked, got user %s (password account %s)", user.ID, existing.ID)
+	}
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// Config size limits. Zero means the built-in default from model; the global
+// settings handler adjusts them at runtime.
+var (
+	targetingRuleLimit atomic.Int64
+	conditionLimit     atomic.Int64
+)
+
+// applyValidationLimits sets the config size limits from the global settings.
+func applyValidationLimits(gs *model.GlobalSettings) {
+	targetingRuleLimit.Store(int64(gs.TargetingRuleLimit()))
+	conditionLimit.Store(int64(gs.ConditionLimit()))
+}
+
+// maxTargetingRules caps the number of targeting rules per environment config.
+func maxTargetingRules() int {
+	if n := targetingRuleLimit.Load(); n > 0 {
+		return int(n)
+	}
+	return model.DefaultMaxTargetingRules
+}
+
+// maxConditionsPerRule caps the number of conditions in a single rule.
+func maxConditionsPerRule() int {
+	if n := conditionLimit.Load(); n > 0 {
+		return int(n)
+	}
+	return model.DefaultMaxConditionsPerRule
+}
+
+// validationProblem describes a single problem found in a candidate config.
+// Path points at the offending field, e.g. "targeting_rules[0].conditions[1].value".
+type validationProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// configCandidate is a parsed environment config payload ready for validation.
+type configCandidate struct {
+	DefaultVariant  string
+	Variants        []model.Variant
+	TargetingRules  []model.TargetingRule
+	Prerequisites   []model.Prerequisite
+	DefaultRollout  []model.VariantWeight
+	IncludedUsers   []string
+	ExcludedUsers   []string
+	IncludedVariant string
+}
+
+// parseConfigCandidate decodes the raw variants, targeting rules,
+// prerequisites and default rollout of an environment config payload. Decoding failures are
+// reported as problems rather than errors so callers can surface them
+// alongside other checks.
+func parseConfigCandidate(defaultVariant string, variants, targetingRules, prerequisites, defaultRollout json.RawMessage) (configCandidate, []validationProblem) {
+	c := configCandidate{DefaultVariant: defaultVariant}
+	var problems []validationProblem
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &c.Variants); err != nil {
+			problems = append(problems, validationProblem{Path: "variants", Message: "must be an array of variants"})
+		}
+	}
+	if len(targetingRules) > 0 {
+		if err := json.Unmarshal(targetingRules, &c.TargetingRules); err != nil {
+			problems = append(problems, validationProblem{Path: "targeting_rules", Message: "must be an array of targeting rules"})
+		}
+	}
+	if len(prerequisites) > 0 {
+		if err := json.Unmarshal(prerequisites, &c.Prerequisites); err != nil {
+			problems = append(problems, validationProblem{Path: "prerequisites", Message: "must be an array of prerequisites"})
+		}
+	}
+	if len(defaultRollout) > 0 {
+		if err := json.Unmarshal(defaultRollout, &c.DefaultRollout); err != nil {
+			problems = append(problems, validationProblem{Path: "default_rollout", Message: "must be an array of variant weights"})
+		}
+	}
+	return c, problems
+}
+
+// validateEnvironmentConfig runs all save-time checks against a candidate
+// environment config for flag and returns every problem found.
+func validateEnvironmentConfig(flag *model.Flag, c configCandidate) []validationProblem {
+	var problems []validationProblem
+	add := func(path, format string, args ...any) {
+		problems = append(problems, validationProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	defined := make(map[string]bool, len(c.Variants))
+	for i, v := range c.Variants {
+		path := fmt.Sprintf("variants[%d]", i)
+		if v.Key == "" {
+			add(path+".key", "variant key is required")
+			continue
+		}
+		if defined[v.Key] {
+			add(path+".key", "duplicate variant key %q", v.Key)
+		}
+		defined[v.Key] = true
+		if err := model.ValidateFlagValue(flag.ValueType, flag.JSONSchema, v.Value); err != nil {
+			add(path+".value", "variant %q: %v", v.Key, err)
+		}
+		for locale, raw := range v.Localized {
+			if locale == "" {
+				add(path+".localized", "locale key must not be empty")
+				continue
+			}
+			if err := model.ValidateFlagValue(flag.ValueType, flag.JSONSchema, raw); err != nil {
+				add(fmt.Sprintf("%s.localized[%q]", path, locale), "variant %q: %v", v.Key, err)
+			}
+		}
+	}
+
+	// With no variants defined the flag serves its default value, so the
+	// default variant is only checked once variants exist.
+	if len(c.Variants) > 0 && c.DefaultVariant != "" && !defined[c.DefaultVariant] {
+		add("default_variant", "variant %q is not defined", c.DefaultVariant)
+	}
+	if len(c.DefaultRollout) > 0 {
+		problems = append(problems, validateVariantWeights("default_rollout", c.DefaultRollout, defined)...)
+	}
+	if c.IncludedVariant != "" && !defined[c.IncludedVariant] {
+		add("included_variant", "variant %q is not defined", c.IncludedVariant)
+	}
+	included := make(map[string]bool, len(c.IncludedUsers))
+	for i, id := range c.IncludedUsers {
+		if id == "" {
+			add(fmt.Sprintf("included_users[%d]", i), "user ID must not be empty")
+		}
+		included[id] = true
+	}
+	for i, id := range c.ExcludedUsers {
+		path := fmt.Sprintf("excluded_users[%d]", i)
+		if id == "" {
+			add(path, "user ID must not be empty")
+		} else if included[id] {
+			add(path, "user %q is also in included_users", id)
+		}
+	}
+
+	if len(c.TargetingRules) > maxTargetingRules() {
+		add("targeting_rules", "at most %d targeting rules are allowed", maxTargetingRules())
+	}
+	for i, rule := range c.TargetingRules {
+		path := fmt.Sprintf("targeting_rules[%d]", i)
+		if len(rule.VariantWeights) > 0 {
+			// A weighted rule serves its arms; variant is optional.
+			if rule.Variant != "" && !defined[rule.Variant] {
+				add(path+".variant", "variant %q is not defined", rule.Variant)
+			}
+			problems = append(problems, validateVariantWeights(path+".variant_weights", rule.VariantWeights, defined)...)
+		} else if rule.Variant == "" {
+			add(path+".variant", "variant is required")
+		} else if !defined[rule.Variant] {
+			add(path+".variant", "variant %q is not defined", rule.Variant)
+		}
+		if rule.PercentageRollout != nil && (*rule.PercentageRollout < 0 || *rule.PercentageRollout > 100) {
+			add(path+".percentage_rollout", "must be between 0 and 100")
+		}
+		count := 0
+		for _, conditions := range rule.ConditionLists() {
+			count += len(conditions)
+		}
+		if count > maxConditionsPerRule() {
+			add(path+".conditions", "at most %d conditions are allowed per rule", maxConditionsPerRule())
+		}
+		problems = append(problems, validateConditions(path+".conditions", rule.Conditions)...)
+		if rule.Group != nil {
+			problems = append(problems, validateConditionGroup(path+".group", rule.Group, 1)...)
+		}
+	}
+
+	// Prerequisite flags are resolved at evaluation time, where a missing
+	// flag or a cycle fails the prerequisite, so only the shape is checked.
+	seen := make(map[string]bool, len(c.Prerequisites))
+	for i, p := range c.Prerequisites {
+		path := fmt.Sprintf("prerequisites[%d]", i)
+		switch {
+		case p.FlagKey == "":
+			add(path+".flag_key", "flag key is required")
+		case p.FlagKey == flag.Key:
+			add(path+".flag_key", "a flag cannot be its own prerequisite")
+		case seen[p.FlagKey]:
+			add(path+".flag_key", "duplicate prerequisite %q", p.FlagKey)
+		}
+		seen[p.FlagKey] = true
+		if p.Variant == "" {
+			add(path+".variant", "variant is required")
+		}
+	}
+
+	return problems
+}
+
+// validateVariantWeights checks that each arm names a defined variant once
+// with a weight between 0 and 100, and that the weights sum to 100.
+func validateVariantWeights(path string, weights []model.VariantWeight, defined map[string]bool) []validationProblem {
+	var problems []validationProblem
+	seen := make(map[string]bool, len(weights))
+	total := 0
+	for i, w := range weights {
+		armPath := fmt.Sprintf("%s[%d]", path, i)
+		if !defined[w.Variant] {
+			problems = append(problems, validationProblem{Path: armPath + ".variant", Message: fmt.Sprintf("variant %q is not defined", w.Variant)})
+		} else if seen[w.Variant] {
+			problems = append(problems, validationProblem{Path: armPath + ".variant", Message: fmt.Sprintf("duplicate variant %q", w.Variant)})
+		}
+		seen[w.Variant] = true
+		if w.Weight < 0 || w.Weight > 100 {
+			problems = append(problems, validationProblem{Path: armPath + ".weight", Message: "must be between 0 and 100"})
+		}
+		total += w.Weight
+	}
+	if total != 100 {
+		problems = append(problems, validationProblem{Path: path, Message: fmt.Sprintf("weights must sum to 100, got %d", total)})
+	}
+	return problems
+}
+
+// maxConditionGroupDepth is how deeply condition groups may nest, counting
+// the rule's own group as the first level.
+const maxConditionGroupDepth = 3
+
+// validateConditionGroup checks a condition group's operator, that it is not
+// empty, its conditions and, recursively, its subgroups.
+func validateConditionGroup(path string, g *model.ConditionGroup, depth int) []validationProblem {
+	var problems []validationProblem
+	if g.Operator != model.GroupAnd && g.Operator != model.GroupOr {
+		problems = append(problems, validationProblem{Path: path + ".operator", Message: `operator must be "and" or "or"`})
+	}
+	if len(g.Conditions) == 0 && len(g.Groups) == 0 {
+		problems = append(problems, validationProblem{Path: path, Message: "a group needs at least one condition or group"})
+	}
+	problems = append(problems, validateConditions(path+".conditions", g.Conditions)...)
+	if len(g.Groups) > 0 && depth >= maxConditionGroupDepth {
+		problems = append(problems, validationProblem{Path: path + ".groups", Message: fmt.Sprintf("groups may be nested at most %d levels deep", maxConditionGroupDepth)})
+		return problems
+	}
+	for i := range g.Groups {
+		problems = append(problems, validateConditionGroup(fmt.Sprintf("%s.groups[%d]", path, i), &g.Groups[i], depth+1)...)
+	}
+	return problems
+}
+
+// validateConditions checks each condition's attribute, operator, and value.
+func validateConditions(path string, conditions []model.Condition) []validationProblem {
+	var problems []validationProblem
+	for i, cond := range conditions {
+		condPath := fmt.Sprintf("%s[%d]", path, i)
+		// in_segment matches on the segment's own attributes.
+		if cond.Attribute == "" && cond.Operator != string(model.OpInSegment) {
+			problems = append(problems, validationProblem{Path: condPath + ".attribute", Message: "attribute is required"})
+		}
+		if !model.ValidOperators[model.Operator(cond.Operator)] {
+			problems = append(problems, validationProblem{Path: condPath + ".operator", Message: fmt.Sprintf("unknown operator %q", cond.Operator)})
+			continue
+		}
+		switch model.Operator(cond.Operator) {
+		case model.OpMatches:
+			pattern, ok := cond.Value.(string)
+			if !ok {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: "regex pattern must be a string"})
+			} else if _, err := regexp.Compile(pattern); err != nil {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: fmt.Sprintf("invalid regex: %v", err)})
+			}
+		case model.OpIn, model.OpNotIn, model.OpInCI:
+			if _, ok := cond.Value.([]any); !ok {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: "value must be an array"})
+			}
+		case model.OpBefore, model.OpAfter:
+			if !validTimestamp(cond.Value) {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: "value must be an RFC3339 timestamp or Unix seconds"})
+			}
+		case model.OpWithinLast:
+			s, _ := cond.Value.(string)
+			if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: `value must be a positive duration such as "168h"`})
+			}
+		case model.OpInSegment:
+			if key, ok := cond.Value.(string); !ok || key == "" {
+				problems = append(problems, validationProblem{Path: condPath + ".value", Message: "value must be a segment key"})
+			}
+		}
+	}
+	return problems
+}
+
+// validTimestamp reports whether v is an RFC3339 timestamp or a number of
+// Unix seconds, as accepted by the before and after operators.
+func validTimestamp(v any) bool {
+	switch v := v.(type) {
+	case float64:
+		return true
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return true
+		}
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}
+	return false
+}
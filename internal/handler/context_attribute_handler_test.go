@@ -0,0 +1,58 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestContextAttributeHandler_List_SortedWithValues(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("ctxattrs"), "Context Attributes", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	attrs := store.NewContextAttributeStore(pool)
+	if err := attrs.UpsertSamplesByProjectKey(ctx, project.Key, map[string][]string{
+		"plan":    {"pro", "free"},
+		"country": {"DE"},
+		"beta":    nil,
+	}); err != nil {
+		t.Fatalf("recording attributes: %v", err)
+	}
+
+	h := handler.NewContextAttributeHandler(attrs, store.NewProjectStore(pool))
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	_, cookie := testSession(t, pool, model.RoleMember)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/"+project.Key+"/context-attributes", nil)
+	req.SetPathValue("key", project.Key)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.List)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var got []model.ContextAttribute
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.Name)
+	}
+	if len(names) != 3 || names[0] != "beta" || names[1] != "country" || names[2] != "plan" {
+		t.Fatalf("expected beta, country, plan, got %v", names)
+	}
+	if len(got[2].Values) != 2 || got[2].Values[0] != "pro" || got[2].Values[1] != "free" {
+		t.Errorf("expected plan values [pro free], got %v", got[2].Values)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// DryRunHandler evaluates flags for hypothetical contexts from the dashboard.
+type DryRunHandler struct {
+	cache        *evaluation.Cache
+	engine       *evaluation.Engine
+	projects     *store.ProjectStore
+	environments *store.EnvironmentStore
+}
+
+// NewDryRunHandler creates a new DryRunHandler.
+func NewDryRunHandler(cache *evaluation.Cache, engine *evaluation.Engine, projects *store.ProjectStore, environments *store.EnvironmentStore) *DryRunHandler {
+	return &DryRunHandler{cache: cache, engine: engine, projects: projects, environments: environments}
+}
+
+// Evaluate handles POST /api/v1/projects/{key}/environments/{env}/evaluate-test
+// Body: {"context": {"user_id": "...", "attributes": {...}}}. Every flag is
+// evaluated from the same cache the SDK endpoints use, so results match
+// production. Unlike SDK evaluations, nothing is counted, published or tracked.
+func (h *DryRunHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	env, err := h.environments.FindByKey(r.Context(), project.ID, r.PathValue("env"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	var req evaluateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Context == nil {
+		req.Context = &model.EvaluationContext{}
+	}
+	if req.Context.Attributes == nil {
+		req.Context.Attributes = map[string]any{}
+	}
+
+	flags := h.cache.GetFlags(project.Key, env.Key)
+	results := make(map[string]*model.EvaluationResult, len(flags))
+	for flagKey, fd := range flags {
+		results[flagKey] = h.engine.Evaluate(&fd.Flag, &fd.Config, req.Context, flags)
+	}
+
+	writeJSON(w, http.StatusOK, evaluateAllResponse{Flags: results})
+}
@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestDryRunHandler_Evaluate(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	environments := store.NewEnvironmentStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("dryrun"), "Dry Run", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := environments.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"pro-features": {
+			Flag: model.Flag{Key: "pro-features", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "off",
+				Variants:       []model.Variant{{Key: "off", Value: []byte(`false`)}, {Key: "on", Value: []byte(`true`)}},
+				TargetingRules: []model.TargetingRule{{
+					Description: "Pro plan",
+					Conditions:  []model.Condition{{Attribute: "plan", Operator: "equals", Value: "pro"}},
+					Variant:     "on",
+				}},
+			},
+		},
+		"kill-switch": {
+			Flag:   model.Flag{Key: "kill-switch", DefaultValue: []byte(`true`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{Enabled: false},
+		},
+	})
+	h := handler.NewDryRunHandler(cache, evaluation.NewEngine(), projects, environments)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+
+	evaluate := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"context": {"user_id": "pm", "attributes": {"plan": "pro"}}}`))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("env", env.Key)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.Evaluate)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := evaluate(nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without session: expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	_, cookie := testSession(t, pool, model.RoleMember)
+	rec := evaluate(cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Evaluate: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Flags map[string]model.EvaluationResult `json:"flags"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	pro := resp.Flags["pro-features"]
+	if pro.Variant != "on" || pro.RuleIndex == nil || *pro.RuleIndex != 0 || pro.RuleDescription != "Pro plan" {
+		t.Errorf("pro-features: unexpected result %+v", pro)
+	}
+	killSwitch, ok := resp.Flags["kill-switch"]
+	if !ok {
+		t.Fatal("expected disabled flag kill-switch in results")
+	}
+	if killSwitch.Reason != "disabled" || killSwitch.Value != true {
+		t.Errorf("kill-switch: expected disabled/true, got %s/%v", killSwitch.Reason, killSwitch.Value)
+	}
+}
@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
@@ -11,14 +17,20 @@ import (
 type EnvironmentHandler struct {
 	environments *store.EnvironmentStore
 	projects     *store.ProjectStore
+	sdkKeys      *store.SDKKeyStore
+	audit        *store.AuditStore
+	cache        *evaluation.Cache
 }
 
-func NewEnvironmentHandler(environments *store.EnvironmentStore, projects *store.ProjectStore) *EnvironmentHandler {
-	return &EnvironmentHandler{environments: environments, projects: projects}
+func NewEnvironmentHandler(environments *store.EnvironmentStore, projects *store.ProjectStore, sdkKeys *store.SDKKeyStore, audit *store.AuditStore, cache *evaluation.Cache) *EnvironmentHandler {
+	return &EnvironmentHandler{environments: environments, projects: projects, sdkKeys: sdkKeys, audit: audit, cache: cache}
 }
 
 // Create handles POST /api/v1/projects/{key}/environments
 func (h *EnvironmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -81,3 +93,173 @@ func (h *EnvironmentHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, envs)
 }
+
+// Reorder handles PUT /api/v1/projects/{key}/environments/reorder
+// Body: {"keys": ["development", "staging", "production"]}, listing every
+// environment of the project exactly once. Responds with the reordered list.
+func (h *EnvironmentHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	if err := h.environments.Reorder(r.Context(), project.ID, req.Keys); err != nil {
+		if errors.Is(err, store.ErrInvalidOrder) {
+			writeError(w, http.StatusBadRequest, "keys must list every environment of the project exactly once")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to reorder environments")
+		return
+	}
+
+	envs, err := h.environments.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list environments")
+		return
+	}
+	writeJSON(w, http.StatusOK, envs)
+}
+
+// Update handles PUT /api/v1/projects/{key}/environments/{env}
+// Body: {"name": "..."}. Only the name can change; the key is immutable.
+func (h *EnvironmentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	envKey := r.PathValue("env")
+	if projectKey == "" || envKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and environment key are required")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, envKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	updated, err := h.environments.Update(r.Context(), env.ID, req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update environment")
+		return
+	}
+
+	h.recordAudit(r, project, "update", env, updated)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Delete handles DELETE /api/v1/projects/{key}/environments/{env}
+// An environment with active SDK keys is only deleted with ?force=true, which
+// removes the keys along with the environment's flag configs. The cached
+// flags for the environment are evicted; nothing is broadcast since no client
+// can subscribe to the environment anymore.
+func (h *EnvironmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	envKey := r.PathValue("env")
+	if projectKey == "" || envKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and environment key are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, envKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	if r.URL.Query().Get("force") != "true" {
+		keys, err := h.sdkKeys.ListByEnvironment(r.Context(), env.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to list SDK keys")
+			return
+		}
+		active := 0
+		for _, k := range keys {
+			if !k.Revoked {
+				active++
+			}
+		}
+		if active > 0 {
+			writeError(w, http.StatusConflict, fmt.Sprintf("environment has %d active SDK key(s); revoke them or pass force=true", active))
+			return
+		}
+	}
+
+	if err := h.environments.Delete(r.Context(), env.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete environment")
+		return
+	}
+	h.cache.Evict(projectKey, envKey)
+
+	h.recordAudit(r, project, "delete", env, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordAudit records a best-effort audit entry for an environment change.
+func (h *EnvironmentHandler) recordAudit(r *http.Request, project *model.Project, action string, old, updated *model.Environment) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return
+	}
+	entry := model.AuditEntry{
+		ProjectID:  &project.ID,
+		UserID:     &user.ID,
+		Action:     action,
+		EntityType: "environment",
+		EntityID:   old.Key,
+	}
+	entry.OldValue, _ = json.Marshal(old)
+	if updated != nil {
+		entry.NewValue, _ = json.Marshal(updated)
+	}
+	if err := h.audit.Record(r.Context(), entry); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
@@ -0,0 +1,138 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func newTestEnvironmentHandler(pool *pgxpool.Pool, cache *evaluation.Cache) *handler.EnvironmentHandler {
+	return handler.NewEnvironmentHandler(
+		store.NewEnvironmentStore(pool),
+		store.NewProjectStore(pool),
+		store.NewSDKKeyStore(pool),
+		store.NewAuditStore(pool),
+		cache,
+	)
+}
+
+// serveEnvironment calls an environment handler as a project admin for the
+// "staging" environment of projectKey.
+func serveEnvironment(t *testing.T, pool *pgxpool.Pool, fn http.HandlerFunc, method, target, body, projectKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleAdmin)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("env", "staging")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(fn).ServeHTTP(rec, req)
+	return rec
+}
+
+// setupStagingEnv creates a project with a "staging" environment and a flag
+// configured in it, returning the project and environment.
+func setupStagingEnv(t *testing.T, pool *pgxpool.Pool, prefix string) (*model.Project, *model.Environment) {
+	t.Helper()
+	ctx := context.Background()
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey(prefix), "Environments", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	if _, err := store.NewFlagStore(pool).Create(ctx, project.ID, "beta", "Beta", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	return project, env
+}
+
+func TestEnvironmentHandler_Update_Renames(t *testing.T) {
+	pool := testPool(t)
+	project, _ := setupStagingEnv(t, pool, "envrename")
+	h := newTestEnvironmentHandler(pool, evaluation.NewCache())
+
+	rec := serveEnvironment(t, pool, h.Update, http.MethodPut, "/", `{"name": "Pre-production"}`, project.Key)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var env model.Environment
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if env.Name != "Pre-production" || env.Key != "staging" {
+		t.Errorf("expected staging renamed to Pre-production, got %+v", env)
+	}
+
+	rec = serveEnvironment(t, pool, h.Update, http.MethodPut, "/", `{"name": "  "}`, project.Key)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a blank name, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestEnvironmentHandler_Delete_RefusesWithActiveSDKKeys(t *testing.T) {
+	pool := testPool(t)
+	project, env := setupStagingEnv(t, pool, "envdeletekeys")
+	if _, err := store.NewSDKKeyStore(pool).Create(context.Background(), env.ID, "backend", nil); err != nil {
+		t.Fatalf("creating SDK key: %v", err)
+	}
+	h := newTestEnvironmentHandler(pool, evaluation.NewCache())
+
+	rec := serveEnvironment(t, pool, h.Delete, http.MethodDelete, "/", "", project.Key)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	if _, err := store.NewEnvironmentStore(pool).FindByKey(context.Background(), project.ID, "staging"); err != nil {
+		t.Errorf("expected environment to still exist: %v", err)
+	}
+}
+
+func TestEnvironmentHandler_Delete_ForceCascades(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	project, env := setupStagingEnv(t, pool, "envdeleteforce")
+	if _, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "backend", nil); err != nil {
+		t.Fatalf("creating SDK key: %v", err)
+	}
+	cache := evaluation.NewCache()
+	if err := cache.Refresh(ctx, pool, project.Key, "staging"); err != nil {
+		t.Fatalf("loading cache: %v", err)
+	}
+	if len(cache.GetFlags(project.Key, "staging")) == 0 {
+		t.Fatal("expected the flag to be cached before deletion")
+	}
+	h := newTestEnvironmentHandler(pool, cache)
+
+	rec := serveEnvironment(t, pool, h.Delete, http.MethodDelete, "/?force=true", "", project.Key)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+
+	if _, err := store.NewEnvironmentStore(pool).FindByKey(ctx, project.ID, "staging"); err == nil {
+		t.Error("expected environment to be deleted")
+	}
+	keys, err := store.NewSDKKeyStore(pool).ListByEnvironment(ctx, env.ID)
+	if err != nil || len(keys) != 0 {
+		t.Errorf("expected SDK keys to be removed, got %d (err %v)", len(keys), err)
+	}
+	var configs int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM flag_environment_configs WHERE environment_id = $1`, env.ID).Scan(&configs); err != nil || configs != 0 {
+		t.Errorf("expected flag configs to be removed, got %d (err %v)", configs, err)
+	}
+	if flags := cache.GetFlags(project.Key, "staging"); len(flags) != 0 {
+		t.Errorf("expected cache to be evicted, got %d flags", len(flags))
+	}
+}
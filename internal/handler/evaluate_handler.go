@@ -2,70 +2,449 @@ package handler
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/auth"
 	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/events"
+	"github.com/togglerino/togglerino/internal/impressions"
+	"github.com/togglerino/togglerino/internal/metrics"
 	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/msgpack"
+	"github.com/togglerino/togglerino/internal/quota"
+	"github.com/togglerino/togglerino/internal/staleness"
 	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// DefaultMinPollTTLSeconds is the default floor for per-flag poll TTL hints.
+const DefaultMinPollTTLSeconds = 5
+
+// maxBatchContexts caps the number of contexts in one batch evaluation request.
+const maxBatchContexts = 1000
+
+// maxQueryContextLength caps the encoded context of a GET evaluate request,
+// well below the URL limits of common proxies and CDNs.
+const maxQueryContextLength = 4096
+
 // EvaluateHandler handles flag evaluation requests from SDKs.
 type EvaluateHandler struct {
 	cache        *evaluation.Cache
 	engine       *evaluation.Engine
 	unknownFlags *store.UnknownFlagStore
 	contextAttrs *store.ContextAttributeStore
+	// minPollTTL is the floor applied to per-flag poll TTL hints.
+	minPollTTL int
+	// events receives an event for every evaluation served.
+	events events.Sink
+	// quotas enforces monthly evaluation quotas; nil disables enforcement.
+	quotas *quota.Tracker
+	// metrics counts evaluations and their latency; nil disables metrics.
+	metrics *metrics.Registry
+	// usage records when flags were last evaluated; nil disables tracking.
+	usage *staleness.UsageTracker
+	// results caches evaluation results per flag and context; nil disables
+	// result caching.
+	results *evaluation.ResultCache
+	// impressions counts the variants served; nil disables counting.
+	impressions *impressions.Tracker
 }
 
 // NewEvaluateHandler creates a new EvaluateHandler.
 func NewEvaluateHandler(cache *evaluation.Cache, engine *evaluation.Engine, unknownFlags *store.UnknownFlagStore, contextAttrs *store.ContextAttributeStore) *EvaluateHandler {
-	return &EvaluateHandler{cache: cache, engine: engine, unknownFlags: unknownFlags, contextAttrs: contextAttrs}
+	return &EvaluateHandler{cache: cache, engine: engine, unknownFlags: unknownFlags, contextAttrs: contextAttrs, minPollTTL: DefaultMinPollTTLSeconds, events: events.NoopSink{}}
+}
+
+// SetEventSink sets the sink that receives evaluation events. The sink must
+// not block; wrap broker sinks in events.NewAsyncSink.
+func (h *EvaluateHandler) SetEventSink(sink events.Sink) {
+	h.events = sink
+}
+
+// SetMinPollTTL sets the floor, in seconds, applied to per-flag poll TTL hints
+// so a misconfigured flag cannot make SDKs poll too aggressively.
+func (h *EvaluateHandler) SetMinPollTTL(seconds int) {
+	h.minPollTTL = seconds
+}
+
+// SetQuotaTracker enables per-environment monthly evaluation quotas.
+func (h *EvaluateHandler) SetQuotaTracker(tracker *quota.Tracker) {
+	h.quotas = tracker
+}
+
+// SetUsageTracker records the flags served so the staleness checker can tell
+// which flags SDKs still evaluate.
+func (h *EvaluateHandler) SetUsageTracker(tracker *staleness.UsageTracker) {
+	h.usage = tracker
+}
+
+// SetImpressionTracker counts how often each variant is served, for flag
+// stats.
+func (h *EvaluateHandler) SetImpressionTracker(tracker *impressions.Tracker) {
+	h.impressions = tracker
+}
+
+// SetResultCache makes repeated evaluations of a flag for the same context
+// reuse the earlier result until it expires or any flag changes. It is not
+// used while the engine buckets anonymous contexts randomly.
+func (h *EvaluateHandler) SetResultCache(results *evaluation.ResultCache) {
+	h.results = results
+}
+
+// SetMetrics makes the handler record evaluation counts and latency.
+func (h *EvaluateHandler) SetMetrics(registry *metrics.Registry) {
+	h.metrics = registry
+}
+
+// startEvaluation opens a trace span around evaluating flagCount flags and
+// returns a function that ends it and records the latency.
+func (h *EvaluateHandler) startEvaluation(ctx context.Context, endpoint string, flagCount int) func() {
+	start := time.Now()
+	_, span := tracing.Start(ctx, "evaluation.Evaluate")
+	span.SetAttributes(
+		attribute.String("togglerino.endpoint", endpoint),
+		attribute.Int("togglerino.flag_count", flagCount),
+	)
+	return func() {
+		span.End()
+		if h.metrics != nil {
+			h.metrics.ObserveLatency(endpoint, time.Since(start))
+		}
+	}
+}
+
+// allowEvaluations counts n evaluations against the SDK key's environment
+// quota. If the quota is exhausted it writes a 429 and returns false.
+func (h *EvaluateHandler) allowEvaluations(w http.ResponseWriter, sdkKey *model.SDKKey, n int) bool {
+	if h.quotas == nil || h.quotas.Allow(sdkKey.EnvironmentID, int64(n)) {
+		return true
+	}
+	retryAfter := time.Until(h.quotas.Usage(sdkKey.EnvironmentID).ResetsAt)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	writeError(w, http.StatusTooManyRequests, "monthly evaluation quota exceeded")
+	return false
+}
+
+// resultScope identifies one context's evaluations at one cache generation
+// for the result cache.
+type resultScope struct {
+	projectKey, envKey string
+	contextHash        string
+	generation         uint64
+}
+
+// resultScope returns the result cache scope for evaluating evalCtx, or nil
+// if results are not cached. It must be called before the flags are read
+// from the cache, so a result is never stored under a newer generation
+// than the data it was computed from.
+func (h *EvaluateHandler) resultScope(sdkKey *model.SDKKey, evalCtx *model.EvaluationContext) *resultScope {
+	if h.results == nil || !h.engine.Deterministic() {
+		return nil
+	}
+	return &resultScope{
+		projectKey:  sdkKey.ProjectKey,
+		envKey:      sdkKey.EnvironmentKey,
+		contextHash: evaluation.ContextHash(evalCtx),
+		generation:  h.cache.Generation(),
+	}
+}
+
+// evaluate evaluates a flag and attaches its poll TTL hint, clamped to the floor.
+// envFlags are all flags of the environment, used to resolve prerequisites.
+// With a non-nil scope the result is served from and stored in the result
+// cache; cached results are shared and must not be modified.
+func (h *EvaluateHandler) evaluate(fd *evaluation.FlagData, evalCtx *model.EvaluationContext, envFlags map[string]evaluation.FlagData, scope *resultScope) *model.EvaluationResult {
+	var key string
+	if scope != nil {
+		key = evaluation.ResultKey(scope.projectKey, scope.envKey, fd.Flag.Key, scope.contextHash, scope.generation)
+		if result, ok := h.results.Get(key); ok {
+			return result
+		}
+	}
+	result := h.engine.Evaluate(&fd.Flag, &fd.Config, evalCtx, envFlags)
+	if ttl := fd.Flag.PollTTLSeconds; ttl != nil {
+		clamped := max(*ttl, h.minPollTTL)
+		result.PollTTLSeconds = &clamped
+	}
+	if scope != nil {
+		h.results.Add(key, result)
+	}
+	return result
+}
+
+// publishEvaluation records a served flag's usage and variant and sends an evaluation
+// event for it to the event sink.
+func (h *EvaluateHandler) publishEvaluation(ctx context.Context, sdkKey *model.SDKKey, flag *model.Flag, evalCtx *model.EvaluationContext, result *model.EvaluationResult) {
+	if h.usage != nil {
+		h.usage.Record(flag.ID)
+	}
+	if h.impressions != nil {
+		h.impressions.Record(flag.ID, sdkKey.EnvironmentID, result.Variant)
+	}
+	if h.metrics != nil {
+		h.metrics.CountEvaluation(sdkKey.ProjectKey, sdkKey.EnvironmentKey, result.Reason)
+	}
+	if err := h.events.Publish(ctx, events.EvaluationEvent{
+		Type:           events.EventTypeEvaluation,
+		ProjectKey:     sdkKey.ProjectKey,
+		EnvironmentKey: sdkKey.EnvironmentKey,
+		FlagKey:        flag.Key,
+		UserID:         evalCtx.UserID,
+		Variant:        result.Variant,
+		Value:          result.Value,
+		Reason:         result.Reason,
+		Timestamp:      time.Now().UTC(),
+	}); err != nil {
+		slog.Warn("failed to publish evaluation event", "flag_key", flag.Key, "error", err)
+	}
+}
+
+// writeEvaluation writes an evaluation response as MessagePack when the client
+// sends Accept: application/x-msgpack, and as JSON otherwise. Both carry the
+// same fields; only the serialization differs.
+func writeEvaluation(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	if !strings.Contains(r.Header.Get("Accept"), msgpack.ContentType) {
+		writeJSON(w, status, v)
+		return
+	}
+
+	data, err := msgpack.MarshalJSONValue(v)
+	if err != nil {
+		slog.Error("encoding msgpack evaluation response", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	w.Header().Set("Content-Type", msgpack.ContentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// evaluationETag returns a strong ETag for an evaluate response: a hash of
+// the context, the results and the negotiated encoding, so a changed context
+// or a changed flag yields a new tag.
+func evaluationETag(r *http.Request, evalCtx *model.EvaluationContext, v any) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode(evalCtx)
+	json.NewEncoder(h).Encode(v)
+	if strings.Contains(r.Header.Get("Accept"), msgpack.ContentType) {
+		h.Write([]byte(msgpack.ContentType))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 type evaluateRequest struct {
 	Context *model.EvaluationContext `json:"context"`
+	// Tag limits EvaluateAll to flags carrying this tag.
+	Tag string `json:"tag,omitempty"`
 }
 
 type evaluateAllResponse struct {
 	Flags map[string]*model.EvaluationResult `json:"flags"`
+	// Tag echoes the tag filter applied, if any.
+	Tag string `json:"tag,omitempty"`
+}
+
+type batchEvaluateRequest struct {
+	Contexts []*model.EvaluationContext `json:"contexts"`
+}
+
+type batchEvaluateResponse struct {
+	// Results holds one entry per request context, in request order.
+	Results []evaluateAllResponse `json:"results"`
+}
+
+// rulesFlag is one flag of a rules response: everything an SDK needs to
+// evaluate it locally.
+type rulesFlag struct {
+	Flag   model.Flag                  `json:"flag"`
+	Config model.FlagEnvironmentConfig `json:"config"`
+	// UserOverrides maps user IDs to a forced variant key.
+	UserOverrides map[string]string `json:"user_overrides,omitempty"`
 }
 
-// trackAttributes asynchronously records the context attribute names sent
-// by SDK clients so the management UI can offer autocomplete suggestions.
+type rulesResponse struct {
+	Flags map[string]rulesFlag `json:"flags"`
+	// Segments holds the conditions of every project segment, keyed by
+	// segment key, so in_segment conditions can be expanded client-side.
+	Segments map[string][]model.Condition `json:"segments"`
+}
+
+// maxSampledValueLength is the longest attribute value sampled for
+// suggestions; longer values are unlikely to be typed into a rule.
+const maxSampledValueLength = 128
+
+// trackAttributes asynchronously records the context attribute names and
+// values sent by SDK clients so the management UI can offer autocomplete
+// suggestions.
 func (h *EvaluateHandler) trackAttributes(projectKey string, evalCtx *model.EvaluationContext) {
-	if len(evalCtx.Attributes) == 0 {
-		return
+	samples := make(map[string][]string, len(evalCtx.Attributes))
+	addAttributeSamples(samples, evalCtx.Attributes)
+	h.trackAttributeSamples(projectKey, samples)
+}
+
+// addAttributeSamples adds the names of attrs to samples, along with the
+// values that can be suggested: strings, numbers and booleans.
+func addAttributeSamples(samples map[string][]string, attrs map[string]any) {
+	for k, v := range attrs {
+		var value string
+		switch v := v.(type) {
+		case string:
+			value = v
+		case float64, bool:
+			value = fmt.Sprint(v)
+		}
+		if _, ok := samples[k]; !ok {
+			samples[k] = nil
+		}
+		if value != "" && len(value) <= maxSampledValueLength {
+			samples[k] = append(samples[k], value)
+		}
 	}
+}
 
-	names := make([]string, 0, len(evalCtx.Attributes))
-	for k := range evalCtx.Attributes {
-		names = append(names, k)
+// trackAttributeSamples asynchronously records attribute names and sampled
+// values for a project.
+func (h *EvaluateHandler) trackAttributeSamples(projectKey string, samples map[string][]string) {
+	if len(samples) == 0 {
+		return
 	}
 
 	go func() {
-		if err := h.contextAttrs.UpsertByProjectKey(context.Background(), projectKey, names); err != nil {
+		if err := h.contextAttrs.UpsertSamplesByProjectKey(context.Background(), projectKey, samples); err != nil {
 			slog.Error("tracking context attributes", "error", err, "project", projectKey)
 		}
 	}()
 }
 
+// trackUnknownFlag asynchronously records a request for a flag that does not
+// exist in the SDK key's environment, so the management UI can list flags
+// that SDKs expect but nobody has created. Tracking is best-effort.
+func (h *EvaluateHandler) trackUnknownFlag(sdkKey *model.SDKKey, flagKey string) {
+	go func() {
+		if err := h.unknownFlags.Upsert(context.Background(), sdkKey.ProjectID, sdkKey.EnvironmentID, flagKey); err != nil {
+			slog.Warn("failed to track unknown flag", "flag_key", flagKey, "error", err)
+		}
+	}()
+}
+
 // EvaluateAll evaluates all flags for the SDK key's project/environment.
 // POST /api/v1/evaluate
+// An optional "tag" in the body limits the response to flags carrying that tag.
+// Each flag evaluated counts toward the environment's monthly quota.
+// The response carries an ETag over the context and the results; a request
+// whose If-None-Match matches it gets 304 without a body. Flags are still
+// evaluated (and counted) to tell whether anything changed.
 func (h *EvaluateHandler) EvaluateAll(w http.ResponseWriter, r *http.Request) {
+	h.evaluateAll(w, r, auth.SDKKeyFromContext(r.Context()), h.parseRequest(r))
+}
+
+// EvaluateQuery is the GET form of EvaluateAll for clients that can only
+// send GET requests and want responses cached by a CDN.
+// GET /api/v1/evaluate/{project}/{env}?context=<base64url JSON>&tag=...
+// The context is the JSON of the POST body's "context", base64url-encoded
+// (padding optional); encodings longer than maxQueryContextLength get 414.
+// The path must name the SDK key's own project and environment. Responses
+// may be cached by shared caches for the poll TTL floor, varying on the SDK
+// key and the negotiated encoding.
+func (h *EvaluateHandler) EvaluateQuery(w http.ResponseWriter, r *http.Request) {
 	sdkKey := auth.SDKKeyFromContext(r.Context())
+	if r.PathValue("project") != sdkKey.ProjectKey || r.PathValue("env") != sdkKey.EnvironmentKey {
+		writeError(w, http.StatusForbidden, "SDK key does not belong to this project and environment")
+		return
+	}
 
-	evalCtx := h.parseContext(r)
+	query := r.URL.Query()
+	encoded := query.Get("context")
+	if len(encoded) > maxQueryContextLength {
+		writeError(w, http.StatusRequestURITooLong, fmt.Sprintf("context must be at most %d characters when encoded", maxQueryContextLength))
+		return
+	}
+	req := evaluateRequest{Tag: query.Get("tag")}
+	if encoded != "" {
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "context must be base64url-encoded JSON")
+			return
+		}
+		if err := json.Unmarshal(data, &req.Context); err != nil {
+			writeError(w, http.StatusBadRequest, "context must be base64url-encoded JSON")
+			return
+		}
+	}
+	if req.Context == nil {
+		req.Context = &model.EvaluationContext{}
+	}
+	if req.Context.Attributes == nil {
+		req.Context.Attributes = map[string]any{}
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", h.minPollTTL))
+	w.Header().Add("Vary", "Authorization")
+	h.evaluateAll(w, r, sdkKey, req)
+}
+
+// evaluateAll evaluates the SDK key's flags for req and writes the response
+// of EvaluateAll and EvaluateQuery.
+func (h *EvaluateHandler) evaluateAll(w http.ResponseWriter, r *http.Request, sdkKey *model.SDKKey, req evaluateRequest) {
+	evalCtx := req.Context
 	h.trackAttributes(sdkKey.ProjectKey, evalCtx)
 
-	flags := h.cache.GetFlags(sdkKey.ProjectKey, sdkKey.EnvironmentKey)
+	scope := h.resultScope(sdkKey, evalCtx)
+	envFlags := h.cache.GetFlags(sdkKey.ProjectKey, sdkKey.EnvironmentKey)
+	flags := envFlags
+	if req.Tag != "" {
+		// The cache map is shared, so build a filtered copy rather than deleting.
+		tagged := make(map[string]evaluation.FlagData)
+		for flagKey, fd := range flags {
+			if slices.Contains(fd.Flag.Tags, req.Tag) {
+				tagged[flagKey] = fd
+			}
+		}
+		flags = tagged
+	}
+	if !h.allowEvaluations(w, sdkKey, len(flags)) {
+		return
+	}
+	done := h.startEvaluation(r.Context(), "all", len(flags))
 	results := make(map[string]*model.EvaluationResult, len(flags))
 	for flagKey, fd := range flags {
-		results[flagKey] = h.engine.Evaluate(&fd.Flag, &fd.Config, evalCtx)
+		results[flagKey] = h.evaluate(&fd, evalCtx, envFlags, scope)
+		h.publishEvaluation(r.Context(), sdkKey, &fd.Flag, evalCtx, results[flagKey])
 	}
+	done()
 
-	writeJSON(w, http.StatusOK, evaluateAllResponse{Flags: results})
+	resp := evaluateAllResponse{Flags: results, Tag: req.Tag}
+	etag := evaluationETag(r, evalCtx, resp)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeEvaluation(w, r, http.StatusOK, resp)
 }
 
 // EvaluateSingle evaluates a single flag for the SDK key's project/environment.
@@ -74,33 +453,123 @@ func (h *EvaluateHandler) EvaluateSingle(w http.ResponseWriter, r *http.Request)
 	flagKey := r.PathValue("flag")
 
 	sdkKey := auth.SDKKeyFromContext(r.Context())
-	evalCtx := h.parseContext(r)
+	evalCtx := h.parseRequest(r).Context
 	h.trackAttributes(sdkKey.ProjectKey, evalCtx)
 
+	scope := h.resultScope(sdkKey, evalCtx)
 	fd, ok := h.cache.GetFlag(sdkKey.ProjectKey, sdkKey.EnvironmentKey, flagKey)
 	if !ok {
-		// Best-effort unknown flag tracking
-		go func() {
-			if err := h.unknownFlags.Upsert(context.Background(), sdkKey.ProjectID, sdkKey.EnvironmentID, flagKey); err != nil {
-				slog.Warn("failed to track unknown flag", "flag_key", flagKey, "error", err)
-			}
-		}()
+		h.trackUnknownFlag(sdkKey, flagKey)
 		writeError(w, http.StatusNotFound, "flag not found")
 		return
 	}
 
-	result := h.engine.Evaluate(&fd.Flag, &fd.Config, evalCtx)
-	writeJSON(w, http.StatusOK, result)
+	if !h.allowEvaluations(w, sdkKey, 1) {
+		return
+	}
+	done := h.startEvaluation(r.Context(), "single", 1)
+	result := h.evaluate(&fd, evalCtx, h.cache.GetFlags(sdkKey.ProjectKey, sdkKey.EnvironmentKey), scope)
+	h.publishEvaluation(r.Context(), sdkKey, &fd.Flag, evalCtx, result)
+	done()
+	writeEvaluation(w, r, http.StatusOK, result)
+}
+
+// EvaluateBatch evaluates all flags of the SDK key's environment for many
+// contexts in one request.
+// POST /api/v1/evaluate/{project}/{env}/batch
+// Body: {"contexts": [{"user_id": "...", "attributes": {...}}, ...]}. The path
+// must name the SDK key's own project and environment. At most
+// maxBatchContexts contexts are accepted; each flag evaluated for each context
+// counts toward the environment's monthly quota.
+func (h *EvaluateHandler) EvaluateBatch(w http.ResponseWriter, r *http.Request) {
+	sdkKey := auth.SDKKeyFromContext(r.Context())
+	if r.PathValue("project") != sdkKey.ProjectKey || r.PathValue("env") != sdkKey.EnvironmentKey {
+		writeError(w, http.StatusForbidden, "SDK key does not belong to this project and environment")
+		return
+	}
+
+	var req batchEvaluateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Contexts) > maxBatchContexts {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d contexts are allowed per batch", maxBatchContexts))
+		return
+	}
+
+	// Record the union of attributes once rather than per context.
+	samples := make(map[string][]string)
+	for i, evalCtx := range req.Contexts {
+		if evalCtx == nil {
+			evalCtx = &model.EvaluationContext{}
+			req.Contexts[i] = evalCtx
+		}
+		if evalCtx.Attributes == nil {
+			evalCtx.Attributes = map[string]any{}
+		}
+		addAttributeSamples(samples, evalCtx.Attributes)
+	}
+	h.trackAttributeSamples(sdkKey.ProjectKey, samples)
+
+	scopes := make([]*resultScope, len(req.Contexts))
+	for i, evalCtx := range req.Contexts {
+		scopes[i] = h.resultScope(sdkKey, evalCtx)
+	}
+	flags := h.cache.GetFlags(sdkKey.ProjectKey, sdkKey.EnvironmentKey)
+	if !h.allowEvaluations(w, sdkKey, len(flags)*len(req.Contexts)) {
+		return
+	}
+	done := h.startEvaluation(r.Context(), "batch", len(flags)*len(req.Contexts))
+	results := make([]evaluateAllResponse, len(req.Contexts))
+	for i, evalCtx := range req.Contexts {
+		contextResults := make(map[string]*model.EvaluationResult, len(flags))
+		for flagKey, fd := range flags {
+			contextResults[flagKey] = h.evaluate(&fd, evalCtx, flags, scopes[i])
+			h.publishEvaluation(r.Context(), sdkKey, &fd.Flag, evalCtx, contextResults[flagKey])
+		}
+		results[i] = evaluateAllResponse{Flags: contextResults}
+	}
+	done()
+
+	writeEvaluation(w, r, http.StatusOK, batchEvaluateResponse{Results: results})
+}
+
+// Rules returns the flags, environment configs and segments of the SDK key's
+// environment so server-side SDKs can evaluate flags locally.
+// GET /api/v1/rules
+// Poll TTL hints are clamped to the floor exactly as in evaluate responses.
+// Nothing is evaluated, so the request does not count toward the quota.
+func (h *EvaluateHandler) Rules(w http.ResponseWriter, r *http.Request) {
+	sdkKey := auth.SDKKeyFromContext(r.Context())
+
+	flags := h.cache.GetFlags(sdkKey.ProjectKey, sdkKey.EnvironmentKey)
+	resp := rulesResponse{
+		Flags:    make(map[string]rulesFlag, len(flags)),
+		Segments: h.cache.GetSegments(sdkKey.ProjectKey),
+	}
+	for flagKey, fd := range flags {
+		if ttl := fd.Flag.PollTTLSeconds; ttl != nil {
+			clamped := max(*ttl, h.minPollTTL)
+			fd.Flag.PollTTLSeconds = &clamped
+		}
+		resp.Flags[flagKey] = rulesFlag{Flag: fd.Flag, Config: fd.Config, UserOverrides: fd.Config.UserOverrides}
+	}
+	if resp.Segments == nil {
+		resp.Segments = map[string][]model.Condition{}
+	}
+
+	writeEvaluation(w, r, http.StatusOK, resp)
 }
 
-// parseContext reads the evaluation context from the request body.
-// If the body is empty or context is nil, returns an empty context.
-func (h *EvaluateHandler) parseContext(r *http.Request) *model.EvaluationContext {
+// parseRequest reads the evaluate request body.
+// If the body is empty or context is nil, the request gets an empty context.
+func (h *EvaluateHandler) parseRequest(r *http.Request) evaluateRequest {
 	var req evaluateRequest
 	_ = readJSON(r, &req)
 
 	if req.Context == nil {
-		return &model.EvaluationContext{
+		req.Context = &model.EvaluationContext{
 			UserID:     "",
 			Attributes: map[string]any{},
 		}
@@ -110,5 +579,5 @@ func (h *EvaluateHandler) parseContext(r *http.Request) *model.EvaluationContext
 		req.Context.Attributes = map[string]any{}
 	}
 
-	return req.Context
+	return req
 }
@@ -0,0 +1,992 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/events"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/metrics"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/msgpack"
+	"github.com/togglerino/togglerino/internal/quota"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEvaluateHandler_EvaluateSingle_CaseInsensitiveKeys(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalcase"), "Eval Case", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag:   model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{Enabled: false},
+		},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluate := func(flagKey string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.SetPathValue("flag", flagKey)
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateSingle)).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := evaluate("Dark-Mode"); code != http.StatusNotFound {
+		t.Errorf("option off: expected status %d, got %d", http.StatusNotFound, code)
+	}
+
+	cache.SetCaseInsensitiveKeys(project.Key, true)
+	if code := evaluate("Dark-Mode"); code != http.StatusOK {
+		t.Errorf("option on: expected status %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestEvaluateHandler_MetricsCountEvaluations(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalmetrics"), "Eval Metrics", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag:   model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{Enabled: false},
+		},
+	})
+	registry := metrics.NewRegistry()
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetMetrics(registry)
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+	counter := `togglerino_evaluations_total{env="production",project="` + project.Key + `",reason="disabled"}`
+	if strings.Contains(scrape(), counter) {
+		t.Fatal("expected no evaluations before the request")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.SetPathValue("flag", "dark-mode")
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	sdkAuth(http.HandlerFunc(h.EvaluateSingle)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	body := scrape()
+	if !strings.Contains(body, counter+" 1\n") {
+		t.Errorf("expected evaluation counter at 1, got:\n%s", body)
+	}
+	if !strings.Contains(body, `togglerino_evaluation_duration_seconds_count{endpoint="single"} 1`) {
+		t.Errorf("expected one latency observation, got:\n%s", body)
+	}
+}
+
+func TestEvaluateHandler_TracesEvaluation(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evaltrace"), "Eval Trace", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag:   model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{Enabled: false},
+		},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/evaluate/{flag}", auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(h.EvaluateSingle)))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate/dark-mode", nil)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	tracing.Middleware(mux).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected evaluation and request spans, got %d", len(spans))
+	}
+	eval, server := spans[0], spans[1]
+	if eval.Name() != "evaluation.Evaluate" || !slices.Contains(eval.Attributes(), attribute.String("togglerino.endpoint", "single")) {
+		t.Errorf("unexpected evaluation span %q with %v", eval.Name(), eval.Attributes())
+	}
+	if server.Name() != "POST /api/v1/evaluate/{flag}" {
+		t.Errorf("expected server span named after the route, got %q", server.Name())
+	}
+	if eval.Parent().SpanID() != server.SpanContext().SpanID() || eval.SpanContext().TraceID() != server.SpanContext().TraceID() {
+		t.Error("expected evaluation span to be a child of the request span")
+	}
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected incoming trace to be continued, got trace %s", got)
+	}
+}
+
+func TestEvaluateHandler_EvaluateAll_PollTTL(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalttl"), "Eval TTL", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	killTTL, slowTTL, tooLowTTL := 10, 600, 1
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"kill-switch": {Flag: model.Flag{Key: "kill-switch", DefaultValue: []byte(`false`), PollTTLSeconds: &killTTL}},
+		"slow-config": {Flag: model.Flag{Key: "slow-config", DefaultValue: []byte(`"a"`), PollTTLSeconds: &slowTTL}},
+		"too-eager":   {Flag: model.Flag{Key: "too-eager", DefaultValue: []byte(`1`), PollTTLSeconds: &tooLowTTL}},
+		"no-ttl":      {Flag: model.Flag{Key: "no-ttl", DefaultValue: []byte(`true`)}},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetMinPollTTL(5)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(h.EvaluateAll)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("EvaluateAll: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Flags map[string]model.EvaluationResult `json:"flags"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	want := map[string]int{"kill-switch": 10, "slow-config": 600, "too-eager": 5}
+	for key, ttl := range want {
+		got := resp.Flags[key].PollTTLSeconds
+		if got == nil || *got != ttl {
+			t.Errorf("%s: poll_ttl_seconds = %v, want %d", key, got, ttl)
+		}
+	}
+	if resp.Flags["no-ttl"].PollTTLSeconds != nil {
+		t.Errorf("no-ttl: expected no poll_ttl_seconds, got %d", *resp.Flags["no-ttl"].PollTTLSeconds)
+	}
+}
+
+type mockEventSink struct {
+	mu     sync.Mutex
+	events []events.EvaluationEvent
+}
+
+func (m *mockEventSink) Publish(_ context.Context, event events.EvaluationEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockEventSink) Close() error { return nil }
+
+func TestEvaluateHandler_EvaluateSingle_PublishesEvent(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalevents"), "Eval Events", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "on",
+				Variants:       []model.Variant{{Key: "on", Value: []byte(`true`)}},
+			},
+		},
+	})
+	sink := &mockEventSink{}
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetEventSink(sink)
+
+	body := strings.NewReader(`{"context":{"user_id":"user-42"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.SetPathValue("flag", "dark-mode")
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(h.EvaluateSingle)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("EvaluateSingle: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected 1 published event, got %d", len(sink.events))
+	}
+	e := sink.events[0]
+	if e.Type != events.EventTypeEvaluation {
+		t.Errorf("Type = %q, want %q", e.Type, events.EventTypeEvaluation)
+	}
+	if e.ProjectKey != project.Key || e.EnvironmentKey != env.Key || e.FlagKey != "dark-mode" {
+		t.Errorf("unexpected event target: %+v", e)
+	}
+	if e.UserID != "user-42" {
+		t.Errorf("UserID = %q, want %q", e.UserID, "user-42")
+	}
+	if e.Variant != "on" || e.Value != true || e.Reason != "default" {
+		t.Errorf("unexpected evaluation in event: variant=%q value=%v reason=%q", e.Variant, e.Value, e.Reason)
+	}
+	if e.Timestamp.IsZero() {
+		t.Error("expected non-zero Timestamp")
+	}
+}
+
+func TestEvaluateHandler_QuotaExceeded(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalquota"), "Eval Quota", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`)}},
+		"beta":      {Flag: model.Flag{Key: "beta", DefaultValue: []byte(`false`)}},
+	})
+
+	usageStore := store.NewEvaluationUsageStore(pool)
+	limit := int64(3)
+	if err := usageStore.SetQuota(ctx, env.ID, &limit); err != nil {
+		t.Fatalf("setting quota: %v", err)
+	}
+	tracker := quota.NewTracker(usageStore, time.Minute)
+	if err := tracker.Load(ctx); err != nil {
+		t.Fatalf("loading quotas: %v", err)
+	}
+
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetQuotaTracker(tracker)
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluateAll := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateAll)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Two flags per request: the first request uses 2 of 3 evaluations
+	if rec := evaluateAll(); rec.Code != http.StatusOK {
+		t.Fatalf("first request: expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	rec := evaluateAll()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header on quota-exceeded response")
+	}
+
+	// Flushed usage matches the allowed evaluations only
+	tracker.Flush(ctx)
+	got, err := usageStore.Get(ctx, env.ID, quota.PeriodStart(time.Now()))
+	if err != nil {
+		t.Fatalf("getting usage: %v", err)
+	}
+	if got != 2 {
+		t.Errorf("persisted usage: got %d, want 2", got)
+	}
+}
+
+func TestEvaluateHandler_EvaluateAll_MessagePack(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalmsgpack"), "Eval Msgpack", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	ttl := 30
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), PollTTLSeconds: &ttl},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "on",
+				Variants:       []model.Variant{{Key: "on", Value: []byte(`true`)}},
+			},
+		},
+		"theme":   {Flag: model.Flag{Key: "theme", DefaultValue: []byte(`"light"`)}},
+		"limit":   {Flag: model.Flag{Key: "limit", DefaultValue: []byte(`2.5`)}},
+		"layout":  {Flag: model.Flag{Key: "layout", DefaultValue: []byte(`{"columns":[1,2],"dense":null}`)}},
+		"retries": {Flag: model.Flag{Key: "retries", DefaultValue: []byte(`3`)}},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluateAll := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateAll)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("EvaluateAll(Accept=%q): got status %d, body %s", accept, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	jsonRec := evaluateAll("")
+	packedRec := evaluateAll(msgpack.ContentType)
+	if ct := packedRec.Header().Get("Content-Type"); ct != msgpack.ContentType {
+		t.Fatalf("Content-Type = %q, want %q", ct, msgpack.ContentType)
+	}
+	if packedRec.Body.Len() >= jsonRec.Body.Len() {
+		t.Errorf("expected msgpack payload (%d bytes) to be smaller than JSON (%d bytes)", packedRec.Body.Len(), jsonRec.Body.Len())
+	}
+
+	// Normalize the JSON response through msgpack so numbers compare alike.
+	var fromJSON any
+	if err := json.Unmarshal(jsonRec.Body.Bytes(), &fromJSON); err != nil {
+		t.Fatalf("decoding JSON response: %v", err)
+	}
+	normalized, err := msgpack.Marshal(fromJSON)
+	if err != nil {
+		t.Fatalf("re-encoding JSON response: %v", err)
+	}
+	want, _ := msgpack.Unmarshal(normalized)
+
+	got, err := msgpack.Unmarshal(packedRec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("decoding msgpack response: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("msgpack flags differ from JSON flags:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestEvaluateHandler_EvaluateAll_TagFilter(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evaltag"), "Eval Tag", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"mobile-nav":   {Flag: model.Flag{Key: "mobile-nav", DefaultValue: []byte(`true`), Tags: []string{"mobile", "nav"}}},
+		"mobile-theme": {Flag: model.Flag{Key: "mobile-theme", DefaultValue: []byte(`"dark"`), Tags: []string{"mobile"}}},
+		"web-only":     {Flag: model.Flag{Key: "web-only", DefaultValue: []byte(`false`), Tags: []string{"web"}}},
+		"untagged":     {Flag: model.Flag{Key: "untagged", DefaultValue: []byte(`1`), Tags: []string{}}},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluateAll := func(body string) map[string]model.EvaluationResult {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateAll)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("EvaluateAll(%s): got status %d, body %s", body, rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Flags map[string]model.EvaluationResult `json:"flags"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return resp.Flags
+	}
+
+	flags := evaluateAll(`{"tag": "mobile", "context": {"user_id": "u1"}}`)
+	if len(flags) != 2 {
+		t.Fatalf("expected 2 mobile flags, got %d: %v", len(flags), flags)
+	}
+	for _, key := range []string{"mobile-nav", "mobile-theme"} {
+		if _, ok := flags[key]; !ok {
+			t.Errorf("expected %s in filtered response", key)
+		}
+	}
+	for _, key := range []string{"web-only", "untagged"} {
+		if _, ok := flags[key]; ok {
+			t.Errorf("expected %s to be absent from filtered response", key)
+		}
+	}
+
+	// Without a tag every flag is returned, and the cache was not modified by filtering.
+	if all := evaluateAll(`{}`); len(all) != 4 {
+		t.Errorf("expected 4 flags without a tag filter, got %d", len(all))
+	}
+}
+
+func TestEvaluateHandler_EvaluateBatch(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalbatch"), "Eval Batch", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"beta-ui": {
+			Flag: model.Flag{Key: "beta-ui", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "off",
+				Variants:       []model.Variant{{Key: "off", Value: []byte(`false`)}, {Key: "on", Value: []byte(`true`)}},
+				TargetingRules: []model.TargetingRule{{
+					Conditions: []model.Condition{{Attribute: "plan", Operator: "equals", Value: "pro"}},
+					Variant:    "on",
+				}},
+			},
+		},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	batch := func(projectKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("project", projectKey)
+		req.SetPathValue("env", env.Key)
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateBatch)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := batch(project.Key, `{"contexts": [
+		{"user_id": "u1", "attributes": {"plan": "pro"}},
+		{"user_id": "u2", "attributes": {"plan": "free"}}
+	]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("EvaluateBatch: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Results []struct {
+			Flags map[string]model.EvaluationResult `json:"flags"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(resp.Results))
+	}
+	if got := resp.Results[0].Flags["beta-ui"].Variant; got != "on" {
+		t.Errorf("context 0: expected variant on, got %q", got)
+	}
+	if got := resp.Results[1].Flags["beta-ui"].Variant; got != "off" {
+		t.Errorf("context 1: expected variant off, got %q", got)
+	}
+
+	if rec := batch("other-project", `{"contexts": []}`); rec.Code != http.StatusForbidden {
+		t.Errorf("mismatched project: expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	tooMany := `{"contexts": [` + strings.TrimSuffix(strings.Repeat(`{"user_id": "u"},`, 1001), ",") + `]}`
+	if rec := batch(project.Key, tooMany); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch: expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestEvaluateHandler_Rules(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalrules"), "Eval Rules", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	tooLowTTL := 1
+	cache := evaluation.NewCache()
+	cache.SetSegments(project.Key, map[string][]model.Condition{
+		"beta": {{Attribute: "plan", Operator: "equals", Value: "pro"}},
+	})
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), PollTTLSeconds: &tooLowTTL},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "off",
+				Variants:       []model.Variant{{Key: "on", Value: []byte(`true`)}, {Key: "off", Value: []byte(`false`)}},
+				TargetingRules: []model.TargetingRule{{
+					Conditions: []model.Condition{{Operator: "in_segment", Value: "beta"}},
+					Variant:    "on",
+				}},
+				UserOverrides: map[string]string{"user-1": "on"},
+			},
+		},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetMinPollTTL(5)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(h.Rules)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Rules: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Flags map[string]struct {
+			Flag          model.Flag                  `json:"flag"`
+			Config        model.FlagEnvironmentConfig `json:"config"`
+			UserOverrides map[string]string           `json:"user_overrides"`
+		} `json:"flags"`
+		Segments map[string][]model.Condition `json:"segments"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	flag, ok := resp.Flags["dark-mode"]
+	if !ok {
+		t.Fatalf("expected dark-mode in response, got %v", resp.Flags)
+	}
+	if ttl := flag.Flag.PollTTLSeconds; ttl == nil || *ttl != 5 {
+		t.Errorf("poll_ttl_seconds = %v, want 5", ttl)
+	}
+	if got := flag.Config.TargetingRules[0].Conditions[0].Value; got != "beta" {
+		t.Errorf("in_segment value = %v, want segment key %q", got, "beta")
+	}
+	if flag.UserOverrides["user-1"] != "on" {
+		t.Errorf("user_overrides = %v, want user-1 -> on", flag.UserOverrides)
+	}
+	if len(resp.Segments["beta"]) != 1 {
+		t.Errorf("segments = %v, want beta with one condition", resp.Segments)
+	}
+}
+
+func TestEvaluateHandler_EvaluateSingle_TracksUnknownFlags(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalunknown"), "Eval Unknown", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {
+			Flag:   model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{Enabled: false},
+		},
+	})
+	unknownFlags := store.NewUnknownFlagStore(pool)
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), unknownFlags, store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluate := func(flagKey string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.SetPathValue("flag", flagKey)
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateSingle)).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := evaluate("dark-mode"); code != http.StatusOK {
+		t.Fatalf("known flag: expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := evaluate("missing-flag"); code != http.StatusNotFound {
+		t.Fatalf("unknown flag: expected status %d, got %d", http.StatusNotFound, code)
+	}
+
+	// Tracking is asynchronous, so wait for the unknown flag to appear.
+	var tracked []model.UnknownFlag
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if tracked, err = unknownFlags.ListByProject(ctx, project.ID); err != nil {
+			t.Fatalf("listing unknown flags: %v", err)
+		}
+		if len(tracked) > 0 {
+			break
+		}
+	}
+	if len(tracked) != 1 {
+		t.Fatalf("expected exactly 1 unknown flag, got %d", len(tracked))
+	}
+	if tracked[0].FlagKey != "missing-flag" || tracked[0].EnvironmentID != env.ID || tracked[0].RequestCount != 1 {
+		t.Errorf("expected missing-flag tracked once in %s, got %+v", env.ID, tracked[0])
+	}
+}
+
+func TestEvaluateHandler_EvaluateAll_ETag(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evaletag"), "Eval ETag", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`)}},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluateAll := func(body, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateAll)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	const u1 = `{"context": {"user_id": "u1", "attributes": {"plan": "pro"}}}`
+	first := evaluateAll(u1, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d (ETag %q)", first.Code, etag)
+	}
+
+	again := evaluateAll(u1, etag)
+	if again.Code != http.StatusNotModified || again.Body.Len() != 0 {
+		t.Errorf("expected 304 without a body for an unchanged context, got %d: %s", again.Code, again.Body.String())
+	}
+	if again.Header().Get("ETag") != etag {
+		t.Errorf("expected the 304 to repeat ETag %s, got %s", etag, again.Header().Get("ETag"))
+	}
+
+	other := evaluateAll(`{"context": {"user_id": "u2", "attributes": {"plan": "pro"}}}`, etag)
+	if other.Code != http.StatusOK || other.Header().Get("ETag") == etag {
+		t.Errorf("expected 200 with a new ETag for a changed context, got %d (ETag %s)", other.Code, other.Header().Get("ETag"))
+	}
+
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`true`)}},
+	})
+	if changed := evaluateAll(u1, etag); changed.Code != http.StatusOK {
+		t.Errorf("expected 200 after the flag changed, got %d", changed.Code)
+	}
+}
+
+func TestEvaluateHandler_ResultCache(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalresults"), "Eval Results", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	flags := map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`false`)}},
+	}
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, flags)
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	h.SetResultCache(evaluation.NewResultCache(100, time.Minute))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+
+	evaluate := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"context": {"user_id": "u1"}}`))
+		req.SetPathValue("flag", "dark-mode")
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		sdkAuth(http.HandlerFunc(h.EvaluateSingle)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result model.EvaluationResult
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatalf("decoding result: %v", err)
+		}
+		return fmt.Sprint(result.Value)
+	}
+
+	if got := evaluate(); got != "false" {
+		t.Fatalf("first evaluation = %s, want false", got)
+	}
+
+	// Changing the cached map in place does not bump the cache generation,
+	// so an identical evaluation is still served the cached result.
+	flags["dark-mode"] = evaluation.FlagData{Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`true`)}}
+	if got := evaluate(); got != "false" {
+		t.Errorf("second evaluation = %s, want the cached false", got)
+	}
+
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"dark-mode": {Flag: model.Flag{Key: "dark-mode", DefaultValue: []byte(`true`)}},
+	})
+	if got := evaluate(); got != "true" {
+		t.Errorf("evaluation after a config refresh = %s, want true", got)
+	}
+}
+
+func TestSDKAuth_RecordsKeyUse(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("sdkuse"), "SDK Use", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	keys := store.NewSDKKeyStore(pool)
+	sdkKey, err := keys.Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	auth.SDKAuth(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the key to authenticate, got %d", rec.Code)
+	}
+
+	found, err := keys.FindByKey(ctx, sdkKey.Key)
+	if err != nil {
+		t.Fatalf("FindByKey: %v", err)
+	}
+	if found.LastUsedAt == nil || found.LastUsedAt.Before(sdkKey.CreatedAt) {
+		t.Errorf("expected last_used_at to advance past creation, got %v", found.LastUsedAt)
+	}
+}
+
+func TestEvaluateHandler_EvaluateQuery_MatchesPost(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("evalget"), "Eval GET", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.Set(project.Key, env.Key, map[string]evaluation.FlagData{
+		"pro-banner": {
+			Flag: model.Flag{Key: "pro-banner", DefaultValue: []byte(`false`), LifecycleStatus: model.LifecycleActive},
+			Config: model.FlagEnvironmentConfig{
+				Enabled:        true,
+				DefaultVariant: "off",
+				Variants:       []model.Variant{{Key: "on", Value: json.RawMessage(`true`)}, {Key: "off", Value: json.RawMessage(`false`)}},
+				TargetingRules: []model.TargetingRule{{
+					Conditions: []model.Condition{{Attribute: "plan", Operator: string(model.OpEquals), Value: "pro"}},
+					Variant:    "on",
+				}},
+			},
+		},
+	})
+	h := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/evaluate", sdkAuth(http.HandlerFunc(h.EvaluateAll)))
+	mux.Handle("GET /api/v1/evaluate/{project}/{env}", sdkAuth(http.HandlerFunc(h.EvaluateQuery)))
+
+	evalCtx := `{"user_id":"user-1","attributes":{"plan":"pro"}}`
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	post := serve(httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(`{"context":`+evalCtx+`}`)))
+	if post.Code != http.StatusOK {
+		t.Fatalf("POST: got status %d, body %s", post.Code, post.Body.String())
+	}
+	getURL := "/api/v1/evaluate/" + project.Key + "/" + env.Key + "?context=" + base64.RawURLEncoding.EncodeToString([]byte(evalCtx))
+	get := serve(httptest.NewRequest(http.MethodGet, getURL, nil))
+	if get.Code != http.StatusOK {
+		t.Fatalf("GET: got status %d, body %s", get.Code, get.Body.String())
+	}
+	if get.Body.String() != post.Body.String() {
+		t.Errorf("GET result %s differs from POST result %s", get.Body.String(), post.Body.String())
+	}
+	if !strings.Contains(get.Body.String(), `"variant":"on"`) {
+		t.Errorf("expected the query context to match the rule, got %s", get.Body.String())
+	}
+	if cc := get.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+		t.Errorf("expected a public Cache-Control header, got %q", cc)
+	}
+	if vary := get.Header().Values("Vary"); !slices.Contains(vary, "Authorization") || !slices.Contains(vary, "Accept") {
+		t.Errorf("expected Vary on Authorization and Accept, got %v", vary)
+	}
+
+	long := serve(httptest.NewRequest(http.MethodGet, "/api/v1/evaluate/"+project.Key+"/"+env.Key+"?context="+strings.Repeat("a", 5000), nil))
+	if long.Code != http.StatusRequestURITooLong {
+		t.Errorf("oversized context: expected 414, got %d", long.Code)
+	}
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// maxBulkFlags caps the number of flags a single bulk operation may touch.
+const maxBulkFlags = 500
+
+// bulkActions maps a bulk action name to the lifecycle status it applies.
+var bulkActions = map[string]model.LifecycleStatus{
+	"archive":   model.LifecycleArchived,
+	"unarchive": model.LifecycleActive,
+}
+
+// blockedFlag is a flag a bulk archive skipped, with the dependents that
+// blocked it.
+type blockedFlag struct {
+	FlagKey    string                `json:"flag_key"`
+	Dependents []model.FlagDependent `json:"dependents"`
+}
+
+// Bulk handles POST /api/v1/projects/{key}/flags/bulk
+// It applies one action to many flags: {"action": "archive", "flag_keys": [...]}.
+// Unknown flag keys are reported back rather than failing the whole request,
+// and so are flags left unarchived because enabled flags outside the request
+// still use them as a prerequisite.
+func (h *FlagHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	var req struct {
+		Action   string   `json:"action"`
+		FlagKeys []string `json:"flag_keys"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	status, ok := bulkActions[req.Action]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid action: must be one of archive, unarchive")
+		return
+	}
+	if len(req.FlagKeys) == 0 {
+		writeError(w, http.StatusBadRequest, "flag_keys is required")
+		return
+	}
+	if len(req.FlagKeys) > maxBulkFlags {
+		writeError(w, http.StatusBadRequest, "at most 500 flags can be updated at once")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	updated := []model.Flag{}
+	notFound := []string{}
+	blocked := []blockedFlag{}
+	requested := make(map[string]bool, len(req.FlagKeys))
+	for _, flagKey := range req.FlagKeys {
+		requested[flagKey] = true
+	}
+	for _, flagKey := range req.FlagKeys {
+		flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+		if err != nil {
+			notFound = append(notFound, flagKey)
+			continue
+		}
+
+		if status == model.LifecycleArchived {
+			dependents, err := h.activeDependents(r.Context(), project.ID, flag.Key, requested)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to check dependents of flag "+flagKey)
+				return
+			}
+			if len(dependents) > 0 {
+				blocked = append(blocked, blockedFlag{FlagKey: flag.Key, Dependents: dependents})
+				continue
+			}
+		}
+
+		result, err := h.flags.SetLifecycleStatus(r.Context(), flag.ID, status)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update flag "+flagKey)
+			return
+		}
+		updated = append(updated, *result)
+
+		// Best-effort audit logging
+		if user != nil {
+			oldVal, _ := json.Marshal(flag)
+			newVal, _ := json.Marshal(result)
+			if err := h.audit.Record(r.Context(), model.AuditEntry{
+				ProjectID:  &project.ID,
+				UserID:     &user.ID,
+				Action:     req.Action,
+				EntityType: "flag",
+				EntityID:   flag.Key,
+				OldValue:   oldVal,
+				NewValue:   newVal,
+			}); err != nil {
+				slog.Warn("failed to record audit log", "error", err)
+			}
+		}
+	}
+
+	if len(updated) > 0 {
+		h.refreshAndBroadcast(r, projectKey, project.ID, updated)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated":   updated,
+		"not_found": notFound,
+		"blocked":   blocked,
+	})
+}
+
+// BulkTag handles POST /api/v1/projects/{key}/flags/bulk-tag
+// It adds and removes tags on many flags at once:
+// {"flag_keys": [...], "add": [...], "remove": [...]}. Unknown flag keys are
+// reported back, and a single audit entry summarizes the change.
+func (h *FlagHandler) BulkTag(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	var req struct {
+		FlagKeys []string `json:"flag_keys"`
+		Add      []string `json:"add"`
+		Remove   []string `json:"remove"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if len(req.FlagKeys) == 0 {
+		writeError(w, http.StatusBadRequest, "flag_keys is required")
+		return
+	}
+	if len(req.FlagKeys) > maxBulkFlags {
+		writeError(w, http.StatusBadRequest, "at most 500 flags can be updated at once")
+		return
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		writeError(w, http.StatusBadRequest, "add or remove is required")
+		return
+	}
+	if slices.Contains(req.Add, "") || slices.Contains(req.Remove, "") {
+		writeError(w, http.StatusBadRequest, "tags must not be empty")
+		return
+	}
+
+	updated, err := h.flags.BulkUpdateTags(r.Context(), project.ID, req.FlagKeys, req.Add, req.Remove)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update flag tags")
+		return
+	}
+
+	found := make(map[string]bool, len(updated))
+	updatedKeys := make([]string, 0, len(updated))
+	for _, f := range updated {
+		found[f.Key] = true
+		updatedKeys = append(updatedKeys, f.Key)
+	}
+	notFound := []string{}
+	for _, flagKey := range req.FlagKeys {
+		if !found[flagKey] && !slices.Contains(notFound, flagKey) {
+			notFound = append(notFound, flagKey)
+		}
+	}
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil && len(updated) > 0 {
+		newVal, _ := json.Marshal(map[string]any{
+			"flag_keys": updatedKeys,
+			"add":       nonNilStrings(req.Add),
+			"remove":    nonNilStrings(req.Remove),
+		})
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &project.ID,
+			UserID:     &user.ID,
+			Action:     "bulk_tag",
+			EntityType: "flag",
+			EntityID:   strings.Join(updatedKeys, ","),
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	if len(updated) > 0 {
+		h.refreshAndBroadcast(r, projectKey, project.ID, updated)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated":   updated,
+		"not_found": notFound,
+	})
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// refreshAndBroadcast refreshes each environment's cache once and broadcasts
+// a flag_update event per changed flag, instead of refreshing per flag.
+func (h *FlagHandler) refreshAndBroadcast(r *http.Request, projectKey, projectID string, flags []model.Flag) {
+	envs, err := h.environments.ListByProject(r.Context(), projectID)
+	if err != nil {
+		slog.Warn("failed to list environments for cache refresh", "error", err)
+		return
+	}
+	for _, env := range envs {
+		if err := h.cache.Refresh(r.Context(), h.pool, projectKey, env.Key); err != nil {
+			slog.Warn("failed to refresh cache", "project", projectKey, "env", env.Key, "error", err)
+		}
+		for _, f := range flags {
+			h.hub.Broadcast(projectKey, env.Key, stream.Event{
+				Type:    "flag_update",
+				FlagKey: f.Key,
+				Value:   f.LifecycleStatus == model.LifecycleArchived,
+				Variant: "",
+			})
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// SetChangeApproval enables change requests: flag config updates in the
+// environments a project lists in require_approval_environments are held
+// until a different user approves them.
+func (h *FlagHandler) SetChangeApproval(settings *store.ProjectSettingsStore, changes *store.ChangeRequestStore) {
+	h.settings = settings
+	h.changes = changes
+}
+
+// requestChange stores a pending change request for a validated config
+// update and responds 202 with it.
+func (h *FlagHandler) requestChange(w http.ResponseWriter, r *http.Request, project *model.Project, flag *model.Flag, env *model.Environment, proposed model.ProposedConfig) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	// The current config is kept for the reviewer's diff.
+	previous, err := h.currentEnvironmentConfig(r.Context(), flag.ID, env.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
+	}
+
+	cr, err := h.changes.Create(r.Context(), project.ID, flag.ID, env.ID, user.ID, proposed, previous)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create change request")
+		return
+	}
+	h.recordChangeAudit(r, project.ID, "request_change", cr)
+	writeJSON(w, http.StatusAccepted, cr)
+}
+
+// ListChangeRequests handles GET /api/v1/projects/{key}/change-requests
+// An optional ?status= filters by pending, approved or rejected.
+func (h *FlagHandler) ListChangeRequests(w http.ResponseWriter, r *http.Request) {
+	if h.changes == nil {
+		writeError(w, http.StatusNotFound, "change requests are not enabled")
+		return
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	status := model.ChangeRequestStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", model.ChangeRequestPending, model.ChangeRequestApproved, model.ChangeRequestRejected:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be one of pending, approved, rejected")
+		return
+	}
+
+	requests, err := h.changes.ListByProject(r.Context(), project.ID, status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list change requests")
+		return
+	}
+	if requests == nil {
+		requests = []model.ChangeRequest{}
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// ApproveChangeRequest handles POST /api/v1/projects/{key}/change-requests/{id}/approve
+// The proposed config is applied as if it had been saved directly.
+func (h *FlagHandler) ApproveChangeRequest(w http.ResponseWriter, r *http.Request) {
+	project, cr, ok := h.claimChangeRequest(w, r, model.ChangeRequestApproved)
+	if !ok {
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, cr.FlagKey)
+	if err == nil {
+		var env *model.Environment
+		if env, err = h.environments.FindByKey(r.Context(), project.ID, cr.EnvironmentKey); err == nil {
+			_, err = h.applyEnvironmentConfig(r, project, flag, env, cr.Proposed, "")
+		}
+	}
+	if err != nil {
+		if reopenErr := h.changes.Reopen(r.Context(), cr.ID); reopenErr != nil {
+			slog.Warn("failed to reopen change request", "id", cr.ID, "error", reopenErr)
+		}
+		writeError(w, http.StatusInternalServerError, "failed to apply change request")
+		return
+	}
+
+	h.respondResolved(w, r, project, cr.ID, "approve_change")
+}
+
+// RejectChangeRequest handles POST /api/v1/projects/{key}/change-requests/{id}/reject
+func (h *FlagHandler) RejectChangeRequest(w http.ResponseWriter, r *http.Request) {
+	project, cr, ok := h.claimChangeRequest(w, r, model.ChangeRequestRejected)
+	if !ok {
+		return
+	}
+	h.respondResolved(w, r, project, cr.ID, "reject_change")
+}
+
+// claimChangeRequest loads the change request in the path and atomically
+// moves it from pending to status, writing the error response on failure.
+// Requesters cannot review their own changes.
+func (h *FlagHandler) claimChangeRequest(w http.ResponseWriter, r *http.Request, status model.ChangeRequestStatus) (*model.Project, *model.ChangeRequest, bool) {
+	if h.changes == nil {
+		writeError(w, http.StatusNotFound, "change requests are not enabled")
+		return nil, nil, false
+	}
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return nil, nil, false
+	}
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return nil, nil, false
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, false
+	}
+
+	cr, err := h.changes.FindByID(r.Context(), project.ID, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "change request not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to load change request")
+		}
+		return nil, nil, false
+	}
+	if cr.RequestedBy == user.ID {
+		writeError(w, http.StatusForbidden, "change requests must be reviewed by someone other than the requester")
+		return nil, nil, false
+	}
+
+	claimed, err := h.changes.Resolve(r.Context(), cr.ID, status, user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to review change request")
+		return nil, nil, false
+	}
+	if !claimed {
+		writeError(w, http.StatusConflict, "change request is no longer pending")
+		return nil, nil, false
+	}
+	return project, cr, true
+}
+
+// respondResolved audits a reviewed change request and writes its new state.
+func (h *FlagHandler) respondResolved(w http.ResponseWriter, r *http.Request, project *model.Project, id, action string) {
+	cr, err := h.changes.FindByID(r.Context(), project.ID, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load change request")
+		return
+	}
+	h.recordChangeAudit(r, project.ID, action, cr)
+	writeJSON(w, http.StatusOK, cr)
+}
+
+// recordChangeAudit records a best-effort audit entry for a change request.
+func (h *FlagHandler) recordChangeAudit(r *http.Request, projectID, action string, cr *model.ChangeRequest) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return
+	}
+	newVal, _ := json.Marshal(cr)
+	if err := h.audit.Record(r.Context(), model.AuditEntry{
+		ProjectID:  &projectID,
+		UserID:     &user.ID,
+		Action:     action,
+		EntityType: "change_request",
+		EntityID:   cr.FlagKey,
+		NewValue:   newVal,
+	}); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// setupApprovalFlag creates the setupFlagEnv project with production gated
+// behind approval and returns a change-approval enabled handler.
+func setupApprovalFlag(t *testing.T, pool *pgxpool.Pool, prefix string) (*handler.FlagHandler, *model.Project) {
+	t.Helper()
+	ctx := context.Background()
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, setupFlagEnv(t, pool, prefix))
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+	settings := store.NewProjectSettingsStore(pool)
+	if _, err := settings.SetRequireApprovalEnvironments(ctx, project.ID, []string{"production"}); err != nil {
+		t.Fatalf("requiring approval: %v", err)
+	}
+	h := newTestFlagHandler(pool)
+	h.SetChangeApproval(settings, store.NewChangeRequestStore(pool))
+	return h, project
+}
+
+// serveAs runs fn behind session auth with the flag, environment and change
+// request path values of the approval tests.
+func serveAs(pool *pgxpool.Pool, cookie *http.Cookie, fn http.HandlerFunc, projectKey, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", "theme")
+	req.SetPathValue("env", "production")
+	req.SetPathValue("id", id)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuthAs(pool, model.ProjectRoleEditor)(fn).ServeHTTP(rec, req)
+	return rec
+}
+
+const approvalConfig = `{
+	"enabled": true,
+	"default_variant": "dark",
+	"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+	"targeting_rules": []
+}`
+
+func TestFlagHandler_UpdateEnvironmentConfig_DeferredUntilApproved(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	h, project := setupApprovalFlag(t, pool, "approvalapply")
+	_, requester := testSession(t, pool, model.RoleMember)
+	_, reviewer := testSession(t, pool, model.RoleMember)
+
+	rec := serveAs(pool, requester, h.UpdateEnvironmentConfig, project.Key, "", approvalConfig)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("update: expected %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var cr model.ChangeRequest
+	if err := json.NewDecoder(rec.Body).Decode(&cr); err != nil {
+		t.Fatalf("decoding change request: %v", err)
+	}
+	if cr.Status != model.ChangeRequestPending || cr.FlagKey != "theme" || cr.EnvironmentKey != "production" || cr.Proposed.DefaultVariant != "dark" {
+		t.Errorf("unexpected change request: %+v", cr)
+	}
+
+	flag, err := store.NewFlagStore(pool).FindByKey(ctx, project.ID, "theme")
+	if err != nil {
+		t.Fatalf("finding flag: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).FindByKey(ctx, project.ID, "production")
+	if err != nil {
+		t.Fatalf("finding environment: %v", err)
+	}
+	if cfg, err := store.NewFlagStore(pool).GetEnvironmentConfig(ctx, flag.ID, env.ID); err == nil && cfg.DefaultVariant == "dark" {
+		t.Fatal("expected config to stay unchanged until approval")
+	}
+
+	rec = serveAs(pool, reviewer, h.ApproveChangeRequest, project.Key, cr.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("approve: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var approved model.ChangeRequest
+	if err := json.NewDecoder(rec.Body).Decode(&approved); err != nil {
+		t.Fatalf("decoding change request: %v", err)
+	}
+	if approved.Status != model.ChangeRequestApproved || approved.ReviewedBy == nil {
+		t.Errorf("expected approved request with reviewer, got %+v", approved)
+	}
+
+	cfg, err := store.NewFlagStore(pool).GetEnvironmentConfig(ctx, flag.ID, env.ID)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	if !cfg.Enabled || cfg.DefaultVariant != "dark" {
+		t.Errorf("expected approved config to be applied, got %+v", cfg)
+	}
+
+	// Approving twice is a conflict
+	if rec := serveAs(pool, reviewer, h.ApproveChangeRequest, project.Key, cr.ID, ""); rec.Code != http.StatusConflict {
+		t.Errorf("second approve: expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+func TestFlagHandler_ChangeRequest_RejectsSelfApproval(t *testing.T) {
+	pool := testPool(t)
+	h, project := setupApprovalFlag(t, pool, "approvalself")
+	_, requester := testSession(t, pool, model.RoleMember)
+	_, reviewer := testSession(t, pool, model.RoleMember)
+
+	rec := serveAs(pool, requester, h.UpdateEnvironmentConfig, project.Key, "", approvalConfig)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("update: expected %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var cr model.ChangeRequest
+	if err := json.NewDecoder(rec.Body).Decode(&cr); err != nil {
+		t.Fatalf("decoding change request: %v", err)
+	}
+
+	if rec := serveAs(pool, requester, h.ApproveChangeRequest, project.Key, cr.ID, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("self-approve: expected %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(pool, requester, h.RejectChangeRequest, project.Key, cr.ID, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("self-reject: expected %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// Still pending, so another user can reject it
+	rec = serveAs(pool, reviewer, h.RejectChangeRequest, project.Key, cr.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reject: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?status=rejected", nil)
+	req.SetPathValue("key", project.Key)
+	rec = httptest.NewRecorder()
+	h.ListChangeRequests(rec, req)
+	var listed []model.ChangeRequest
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatalf("decoding list: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != cr.ID || listed[0].Status != model.ChangeRequestRejected {
+		t.Errorf("expected the rejected request, got %+v", listed)
+	}
+}
+
+func TestProjectSettingsHandler_RequireApproval_AdminOnly(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	_, project := setupApprovalFlag(t, pool, "approvaladmin")
+	settings := store.NewProjectSettingsStore(pool)
+	audit := store.NewAuditStore(pool)
+	h := handler.NewProjectSettingsHandler(settings, store.NewProjectStore(pool), audit, evaluation.NewCache())
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	update := func(role model.ProjectRole) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"require_approval_environments": []}`))
+		req.SetPathValue("key", project.Key)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuthAs(pool, role)(http.HandlerFunc(h.Update)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, role := range []model.ProjectRole{model.ProjectRoleViewer, model.ProjectRoleEditor} {
+		if rec := update(role); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected %d, got %d: %s", role, http.StatusForbidden, rec.Code, rec.Body.String())
+		}
+	}
+	current, err := settings.Get(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("getting settings: %v", err)
+	}
+	if len(current.RequireApprovalEnvironments) != 1 {
+		t.Fatalf("expected production to stay gated, got %v", current.RequireApprovalEnvironments)
+	}
+
+	if rec := update(model.ProjectRoleAdmin); rec.Code != http.StatusOK {
+		t.Fatalf("admin: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	entries, _, err := audit.ListByProject(ctx, project.ID, store.AuditFilter{EntityType: "project_settings"}, 10, 0)
+	if err != nil {
+		t.Fatalf("listing audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != "update" {
+		t.Fatalf("expected one project_settings update entry, got %+v", entries)
+	}
+	var oldVal struct {
+		RequireApprovalEnvironments []string `json:"require_approval_environments"`
+	}
+	if err := json.Unmarshal(entries[0].OldValue, &oldVal); err != nil || len(oldVal.RequireApprovalEnvironments) != 1 || oldVal.RequireApprovalEnvironments[0] != "production" {
+		t.Errorf("expected old value [production], got %s", entries[0].OldValue)
+	}
+}
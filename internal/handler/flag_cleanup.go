@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// defaultCleanupStaleDays is how long a flag must have been stale to appear
+// in the cleanup report when no ?stale_days= is given.
+const defaultCleanupStaleDays = 30
+
+type cleanupReportEntry struct {
+	Key        string         `json:"key"`
+	Name       string         `json:"name"`
+	FlagType   model.FlagType `json:"flag_type"`
+	Tags       []string       `json:"tags"`
+	StaleSince time.Time      `json:"stale_since"`
+	StaleDays  int            `json:"stale_days"`
+}
+
+// CleanupReport handles GET /api/v1/projects/{key}/flags/cleanup-report
+// It lists flags that have been stale for at least ?stale_days= days (default 30),
+// oldest first, along with a ready-made payload for POST .../flags/bulk to archive them.
+func (h *FlagHandler) CleanupReport(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	staleDays := defaultCleanupStaleDays
+	if v := r.URL.Query().Get("stale_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "stale_days must be a non-negative integer")
+			return
+		}
+		staleDays = n
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flags, _, err := h.flags.ListByProject(r.Context(), project.ID, "", "", string(model.LifecycleStale), "", "", 0, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list flags")
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -staleDays)
+	entries := []cleanupReportEntry{}
+	for _, f := range flags {
+		if f.LifecycleStatusChangedAt == nil || f.LifecycleStatusChangedAt.After(cutoff) {
+			continue
+		}
+		entries = append(entries, cleanupReportEntry{
+			Key:        f.Key,
+			Name:       f.Name,
+			FlagType:   f.FlagType,
+			Tags:       f.Tags,
+			StaleSince: *f.LifecycleStatusChangedAt,
+			StaleDays:  int(now.Sub(*f.LifecycleStatusChangedAt).Hours() / 24),
+		})
+	}
+	slices.SortFunc(entries, func(a, b cleanupReportEntry) int {
+		return a.StaleSince.Compare(b.StaleSince)
+	})
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"stale_days": staleDays,
+		"flags":      entries,
+		"bulk_action": map[string]any{
+			"action":    "archive",
+			"flag_keys": keys,
+		},
+	})
+}
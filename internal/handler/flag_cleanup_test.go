@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestFlagHandler_CleanupReport_ListsOnlyLongStaleFlags(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	flags := store.NewFlagStore(pool)
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("cleanup"), "Cleanup", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+
+	// key -> (lifecycle status, days since status change)
+	fixtures := []struct {
+		key    string
+		status model.LifecycleStatus
+		days   int
+	}{
+		{"long-stale", model.LifecycleStale, 90},
+		{"recently-stale", model.LifecycleStale, 5},
+		{"old-but-active", model.LifecycleActive, 120},
+		{"potentially-stale", model.LifecyclePotentiallyStale, 60},
+	}
+	for _, fx := range fixtures {
+		f, err := flags.Create(ctx, project.ID, fx.key, fx.key, "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+		if err != nil {
+			t.Fatalf("creating flag %s: %v", fx.key, err)
+		}
+		if _, err := pool.Exec(ctx,
+			`UPDATE flags SET lifecycle_status = $2, lifecycle_status_changed_at = NOW() - make_interval(days => $3) WHERE id = $1`,
+			f.ID, fx.status, fx.days,
+		); err != nil {
+			t.Fatalf("backdating flag %s: %v", fx.key, err)
+		}
+	}
+
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodGet, "/?stale_days=30", nil)
+	req.SetPathValue("key", project.Key)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.CleanupReport)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CleanupReport: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Flags []struct {
+			Key       string `json:"key"`
+			StaleDays int    `json:"stale_days"`
+		} `json:"flags"`
+		BulkAction struct {
+			Action   string   `json:"action"`
+			FlagKeys []string `json:"flag_keys"`
+		} `json:"bulk_action"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(resp.Flags) != 1 || resp.Flags[0].Key != "long-stale" {
+		t.Fatalf("expected only long-stale in report, got %+v", resp.Flags)
+	}
+	if resp.Flags[0].StaleDays < 89 {
+		t.Errorf("stale_days: got %d, want ~90", resp.Flags[0].StaleDays)
+	}
+	if resp.BulkAction.Action != "archive" || len(resp.BulkAction.FlagKeys) != 1 || resp.BulkAction.FlagKeys[0] != "long-stale" {
+		t.Errorf("unexpected bulk action: %+v", resp.BulkAction)
+	}
+}
+
+func TestFlagHandler_Bulk_ArchivesFlags(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	flags := store.NewFlagStore(pool)
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("bulkarchive"), "Bulk Archive", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	for _, key := range []string{"old-a", "old-b"} {
+		if _, err := flags.Create(ctx, project.ID, key, key, "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+			t.Fatalf("creating flag %s: %v", key, err)
+		}
+	}
+
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	body := `{"action":"archive","flag_keys":["old-a","old-b","missing"]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetPathValue("key", project.Key)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.Bulk)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Bulk: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Updated  []model.Flag `json:"updated"`
+		NotFound []string     `json:"not_found"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Updated) != 2 {
+		t.Fatalf("expected 2 updated flags, got %d", len(resp.Updated))
+	}
+	for _, f := range resp.Updated {
+		if f.LifecycleStatus != model.LifecycleArchived {
+			t.Errorf("flag %s: got status %q, want archived", f.Key, f.LifecycleStatus)
+		}
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != "missing" {
+		t.Errorf("not_found: got %v, want [missing]", resp.NotFound)
+	}
+}
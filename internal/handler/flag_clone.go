@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// SetMemberships makes cross-project clones require the editor role in the
+// target project as well as the source.
+func (h *FlagHandler) SetMemberships(memberships *store.ProjectMembershipStore) {
+	h.memberships = memberships
+}
+
+// Clone handles POST /api/v1/projects/{key}/flags/{flag}/clone
+// It copies a flag under a new key, into the same project or the one named by
+// target_project_key, optionally with its per-environment configs.
+func (h *FlagHandler) Clone(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	source, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	var req struct {
+		TargetProjectKey string `json:"target_project_key"`
+		NewKey           string `json:"new_key"`
+		CopyConfigs      bool   `json:"copy_configs"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.NewKey == "" {
+		writeError(w, http.StatusBadRequest, "new_key is required")
+		return
+	}
+
+	target := project
+	if req.TargetProjectKey != "" && req.TargetProjectKey != project.Key {
+		target, err = h.projects.FindByKey(r.Context(), req.TargetProjectKey)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "target project not found")
+			return
+		}
+		if h.memberships != nil {
+			if user := auth.UserFromContext(r.Context()); user != nil {
+				role, err := auth.ResolveProjectRole(r.Context(), h.memberships, user, target.Key)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "failed to load target project role")
+					return
+				}
+				if !role.AtLeast(model.ProjectRoleEditor) {
+					writeError(w, http.StatusForbidden, "requires project role editor in the target project")
+					return
+				}
+			}
+		}
+	}
+
+	flag, err := h.flags.Clone(r.Context(), source.ID, target.ID, req.NewKey, req.CopyConfigs)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "flag not found")
+			return
+		}
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+			writeError(w, http.StatusConflict, "flag key already exists for this project")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to clone flag")
+		return
+	}
+
+	// Best-effort cleanup of unknown flags with this key
+	if err := h.unknownFlags.DeleteByProjectAndKey(r.Context(), target.ID, flag.Key); err != nil {
+		slog.Warn("failed to cleanup unknown flags", "flag_key", flag.Key, "error", err)
+	}
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		newVal, _ := json.Marshal(map[string]any{
+			"flag":           flag,
+			"source_project": project.Key,
+			"source_flag":    source.Key,
+			"copy_configs":   req.CopyConfigs,
+		})
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &target.ID,
+			UserID:     &user.ID,
+			Action:     "clone",
+			EntityType: "flag",
+			EntityID:   flag.Key,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	// Copied configs may already be enabled, so SDKs need to hear about them.
+	if req.CopyConfigs {
+		h.refreshAllEnvironments(r.Context(), target.Key, target.ID, flag.Key, stream.Event{
+			Type: "flag_update",
+		})
+	}
+
+	writeJSON(w, http.StatusCreated, flag)
+}
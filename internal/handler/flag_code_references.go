@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// maxCodeReferences caps the number of references accepted in one upload.
+const maxCodeReferences = 5000
+
+// SetCodeReferences enables code reference uploads and lists a flag's code
+// references in its Get response. Nil disables both.
+func (h *FlagHandler) SetCodeReferences(refs *store.CodeReferenceStore) {
+	h.codeRefs = refs
+}
+
+// codeReferences returns a flag's code references, or nil if code references
+// are disabled or cannot be loaded.
+func (h *FlagHandler) codeReferences(r *http.Request, flag *model.Flag) []model.CodeReference {
+	if h.codeRefs == nil {
+		return nil
+	}
+	refs, err := h.codeRefs.ListByFlag(r.Context(), flag.ID)
+	if err != nil {
+		slog.Warn("failed to list code references", "flag", flag.Key, "error", err)
+		return nil
+	}
+	return refs
+}
+
+// UploadCodeReferences handles POST /api/v1/projects/{key}/flags/{flag}/code-references
+// Body: {"references": [{"repo", "file", "line", "sha"}, ...]}, as uploaded by
+// a CI scanner. References are upserted by repo, file and line; the response
+// is the flag's full list.
+func (h *FlagHandler) UploadCodeReferences(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	if h.codeRefs == nil {
+		writeError(w, http.StatusNotFound, "code references are not enabled")
+		return
+	}
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	var req struct {
+		References []model.CodeReference `json:"references"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.References) > maxCodeReferences {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d references are allowed per upload", maxCodeReferences))
+		return
+	}
+	for i, ref := range req.References {
+		if ref.Repo == "" || ref.File == "" || ref.Line < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("references[%d]: repo, file and a positive line are required", i))
+			return
+		}
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	if err := h.codeRefs.Upsert(r.Context(), flag.ID, req.References); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to store code references")
+		return
+	}
+
+	refs, err := h.codeRefs.ListByFlag(r.Context(), flag.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list code references")
+		return
+	}
+	if refs == nil {
+		refs = []model.CodeReference{}
+	}
+	writeJSON(w, http.StatusOK, refs)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// maxCommentLength caps the size of a single comment body.
+const maxCommentLength = 10000
+
+// FlagCommentHandler handles the per-flag discussion thread.
+type FlagCommentHandler struct {
+	comments *store.FlagCommentStore
+	flags    *store.FlagStore
+	projects *store.ProjectStore
+}
+
+// NewFlagCommentHandler creates a new FlagCommentHandler.
+func NewFlagCommentHandler(comments *store.FlagCommentStore, flags *store.FlagStore, projects *store.ProjectStore) *FlagCommentHandler {
+	return &FlagCommentHandler{comments: comments, flags: flags, projects: projects}
+}
+
+// List handles GET /api/v1/projects/{key}/flags/{flag}/comments
+func (h *FlagCommentHandler) List(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	comments, err := h.comments.ListByFlag(r.Context(), flag.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list comments")
+		return
+	}
+	if comments == nil {
+		comments = []model.FlagComment{}
+	}
+
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// Create handles POST /api/v1/projects/{key}/flags/{flag}/comments
+func (h *FlagCommentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		writeError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+	if len(req.Body) > maxCommentLength {
+		writeError(w, http.StatusBadRequest, "body must be at most 10000 characters")
+		return
+	}
+
+	comment, err := h.comments.Create(r.Context(), flag.ID, user.ID, req.Body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create comment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, comment)
+}
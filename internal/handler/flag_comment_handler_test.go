@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestFlagCommentHandler_CreateAndList(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	flags := store.NewFlagStore(pool)
+	h := handler.NewFlagCommentHandler(store.NewFlagCommentStore(pool), flags, projects)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	ctx := context.Background()
+
+	project, err := projects.Create(ctx, uniqueKey("commenthandler"), "Comment Handler", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := flags.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	user, cookie := testSession(t, pool, model.RoleMember)
+
+	for _, body := range []string{"rolling out tomorrow", "rollout done"} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"body":"`+body+`"}`))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("flag", "dark-mode")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.Create)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Create %q: got status %d, body %s", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("key", project.Key)
+	req.SetPathValue("flag", "dark-mode")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.List)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("List: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var comments []model.FlagComment
+	if err := json.NewDecoder(rec.Body).Decode(&comments); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(comments))
+	}
+	if comments[0].Body != "rolling out tomorrow" || comments[1].Body != "rollout done" {
+		t.Errorf("expected chronological order, got %q then %q", comments[0].Body, comments[1].Body)
+	}
+	if comments[0].UserEmail != user.Email {
+		t.Errorf("UserEmail: got %q, want %q", comments[0].UserEmail, user.Email)
+	}
+}
+
+func TestFlagCommentHandler_Create_EmptyBody(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	flags := store.NewFlagStore(pool)
+	h := handler.NewFlagCommentHandler(store.NewFlagCommentStore(pool), flags, projects)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	ctx := context.Background()
+
+	project, err := projects.Create(ctx, uniqueKey("commentempty"), "Comment Empty", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := flags.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"body":"   "}`))
+	req.SetPathValue("key", project.Key)
+	req.SetPathValue("flag", "dark-mode")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.Create)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
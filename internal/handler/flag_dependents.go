@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// Dependents handles GET /api/v1/projects/{key}/flags/{flag}/dependents
+// It lists the flags that name this flag as a prerequisite, once per
+// environment that does, so its impact can be checked before archiving it.
+func (h *FlagHandler) Dependents(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	flagKey := r.PathValue("flag")
+	if flagKey == "" {
+		writeError(w, http.StatusBadRequest, "flag key is required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	if _, err := h.flags.FindByKey(r.Context(), project.ID, flagKey); err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	dependents, err := h.flags.ListDependents(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list flag dependents")
+		return
+	}
+	if dependents == nil {
+		dependents = []model.FlagDependent{}
+	}
+	writeJSON(w, http.StatusOK, dependents)
+}
+
+// activeDependents returns the dependents of a flag that would break if it
+// were archived: those enabled in their environment and not archived
+// themselves. Flags in ignore are skipped, so flags archived together don't
+// block each other.
+func (h *FlagHandler) activeDependents(ctx context.Context, projectID, flagKey string, ignore map[string]bool) ([]model.FlagDependent, error) {
+	dependents, err := h.flags.ListDependents(ctx, projectID, flagKey)
+	if err != nil {
+		return nil, err
+	}
+	active := []model.FlagDependent{}
+	for _, d := range dependents {
+		if d.Enabled && d.LifecycleStatus != model.LifecycleArchived && !ignore[d.FlagKey] {
+			active = append(active, d)
+		}
+	}
+	return active, nil
+}
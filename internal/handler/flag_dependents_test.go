@@ -0,0 +1,92 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestFlagHandler_Archive_BlockedByEnabledDependents(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	flags := store.NewFlagStore(pool)
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("archivedeps"), "Archive Deps", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	if _, err := flags.Create(ctx, project.ID, "base", "Base", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("creating base: %v", err)
+	}
+	checkout, err := flags.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating checkout: %v", err)
+	}
+	setEnabled := func(enabled bool) {
+		t.Helper()
+		if _, err := flags.UpdateEnvironmentConfig(ctx, checkout.ID, env.ID, enabled, "", json.RawMessage(`[]`), json.RawMessage(`[]`),
+			json.RawMessage(`[{"flag_key": "base", "variant": "on"}]`), nil, nil, nil, "", ""); err != nil {
+			t.Fatalf("updating checkout config: %v", err)
+		}
+	}
+	setEnabled(true)
+
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+	serve := func(method string, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("flag", "base")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(handler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, h.Dependents, "")
+	var dependents []model.FlagDependent
+	if err := json.NewDecoder(rec.Body).Decode(&dependents); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Dependents: status %d, err %v", rec.Code, err)
+	}
+	if len(dependents) != 1 || dependents[0].FlagKey != "checkout" || dependents[0].EnvironmentKey != "production" {
+		t.Fatalf("expected checkout in production, got %+v", dependents)
+	}
+
+	rec = serve(http.MethodPut, h.Archive, `{"archived": true}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Archive with an enabled dependent: got status %d, want 409", rec.Code)
+	}
+	var conflict struct {
+		Dependents []model.FlagDependent `json:"dependents"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&conflict); err != nil || len(conflict.Dependents) != 1 || conflict.Dependents[0].FlagKey != "checkout" {
+		t.Errorf("expected the 409 to list checkout, got %+v (%v)", conflict.Dependents, err)
+	}
+
+	rec = serve(http.MethodPost, h.Bulk, `{"action": "archive", "flag_keys": ["base"]}`)
+	var bulk struct {
+		Updated []model.Flag `json:"updated"`
+		Blocked []struct {
+			FlagKey string `json:"flag_key"`
+		} `json:"blocked"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&bulk); err != nil || len(bulk.Updated) != 0 || len(bulk.Blocked) != 1 || bulk.Blocked[0].FlagKey != "base" {
+		t.Errorf("expected bulk archive to block base, got %+v (%v)", bulk, err)
+	}
+
+	setEnabled(false)
+	if rec := serve(http.MethodPut, h.Archive, `{"archived": true}`); rec.Code != http.StatusOK {
+		t.Errorf("Archive with only a disabled dependent: got status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
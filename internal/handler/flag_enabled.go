@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// SetEnvironmentEnabled handles PATCH /api/v1/projects/{key}/flags/{flag}/environments/{env}/enabled
+// Body: {"enabled": bool}. It turns a flag on or off in one environment
+// without resending its config, so variants, targeting rules and
+// prerequisites are kept. Enabling is subject to the same rollout stage
+// check as a full config update, and an approval-gated environment gets a
+// change request for the current config with only enabled changed.
+func (h *FlagHandler) SetEnvironmentEnabled(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	envKey := r.PathValue("env")
+	if projectKey == "" || flagKey == "" || envKey == "" {
+		writeError(w, http.StatusBadRequest, "project key, flag key and environment key are required")
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := readJSON(r, &req); err != nil || req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+	enabled := *req.Enabled
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, envKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	previous, err := h.flags.GetEnvironmentConfig(r.Context(), flag.ID, env.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "flag is not configured in this environment")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
+	}
+
+	// A later rollout stage may not get ahead of the stage before it.
+	if stageKey, stagePct, ok := h.previousStageRollout(r.Context(), flag, envKey); ok {
+		toggled := *previous
+		toggled.Enabled = enabled
+		if pct := rolloutPercentage(&toggled); pct > stagePct {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"error":                    fmt.Sprintf("rollout of %d%% exceeds the %d%% currently rolled out in %s", pct, stagePct, stageKey),
+				"stage_environment":        stageKey,
+				"stage_rollout_percentage": stagePct,
+			})
+			return
+		}
+	}
+
+	if h.changes != nil {
+		settings, err := h.settings.Get(r.Context(), project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load project settings")
+			return
+		}
+		if settings.RequiresApproval(envKey) {
+			proposed := model.ProposedConfig{Enabled: enabled, DefaultVariant: previous.DefaultVariant}
+			proposed.Variants, _ = json.Marshal(previous.Variants)
+			proposed.TargetingRules, _ = json.Marshal(previous.TargetingRules)
+			proposed.Prerequisites, _ = json.Marshal(previous.Prerequisites)
+			proposed.DefaultRollout, _ = json.Marshal(previous.DefaultRollout)
+			proposed.IncludedUsers, proposed.ExcludedUsers, proposed.IncludedVariant = previous.IncludedUsers, previous.ExcludedUsers, previous.IncludedVariant
+			h.requestChange(w, r, project, flag, env, proposed)
+			return
+		}
+	}
+
+	cfg, err := h.flags.SetEnabled(r.Context(), flag.ID, env.ID, enabled, modifiedBy(r))
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "flag is not configured in this environment")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update environment config")
+		return
+	}
+
+	h.environmentConfigChanged(r, project, flag, env, previous, cfg, "")
+	writeJSON(w, http.StatusOK, cfg)
+}
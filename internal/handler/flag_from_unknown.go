@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// CreateFromUnknown handles POST /api/v1/projects/{key}/unknown-flags/{id}/create
+// Body: the same fields as creating a flag, without the key. It creates a
+// real flag under the key SDKs have been requesting, then clears the unknown
+// flag entries for that key in every environment.
+func (h *FlagHandler) CreateFromUnknown(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	id := r.PathValue("id")
+	if projectKey == "" || id == "" {
+		writeError(w, http.StatusBadRequest, "project key and unknown flag id are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	unknown, err := h.unknownFlags.FindByID(r.Context(), id, project.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "unknown flag not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to get unknown flag")
+		return
+	}
+
+	var req createFlagRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Key = unknown.FlagKey
+	h.createFlag(w, r, project, req)
+}
@@ -3,8 +3,11 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,6 +27,15 @@ type FlagHandler struct {
 	cache        *evaluation.Cache
 	pool         *pgxpool.Pool
 	unknownFlags *store.UnknownFlagStore
+	// settings and changes are set by SetChangeApproval; nil disables
+	// approval-gated environments.
+	settings *store.ProjectSettingsStore
+	changes  *store.ChangeRequestStore
+	// memberships is set by SetMemberships; nil skips the role check on the
+	// target project of a cross-project clone.
+	memberships *store.ProjectMembershipStore
+	// codeRefs is set by SetCodeReferences; nil disables code references.
+	codeRefs *store.CodeReferenceStore
 }
 
 func NewFlagHandler(flags *store.FlagStore, projects *store.ProjectStore, environments *store.EnvironmentStore, audit *store.AuditStore, hub *stream.Hub, cache *evaluation.Cache, pool *pgxpool.Pool, unknownFlags *store.UnknownFlagStore) *FlagHandler {
@@ -49,6 +61,9 @@ func (h *FlagHandler) refreshAllEnvironments(ctx context.Context, projectKey, pr
 
 // Create handles POST /api/v1/projects/{key}/flags
 func (h *FlagHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -61,19 +76,30 @@ func (h *FlagHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Key          string          `json:"key"`
-		Name         string          `json:"name"`
-		Description  string          `json:"description"`
-		ValueType    model.ValueType `json:"value_type"`
-		FlagType     model.FlagType  `json:"flag_type"`
-		DefaultValue json.RawMessage `json:"default_value"`
-		Tags         []string        `json:"tags"`
-	}
+	var req createFlagRequest
 	if err := readJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	h.createFlag(w, r, project, req)
+}
+
+// createFlagRequest is the body accepted when creating a flag.
+type createFlagRequest struct {
+	Key          string          `json:"key"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	ValueType    model.ValueType `json:"value_type"`
+	FlagType     model.FlagType  `json:"flag_type"`
+	DefaultValue json.RawMessage `json:"default_value"`
+	Tags         []string        `json:"tags"`
+	// JSONSchema optionally constrains the values of a json flag.
+	JSONSchema json.RawMessage `json:"json_schema"`
+}
+
+// createFlag validates req, creates the flag in project and writes the
+// response. Unknown flag entries with the new key are cleared.
+func (h *FlagHandler) createFlag(w http.ResponseWriter, r *http.Request, project *model.Project, req createFlagRequest) {
 	if req.Key == "" || req.Name == "" {
 		writeError(w, http.StatusBadRequest, "key and name are required")
 		return
@@ -93,13 +119,30 @@ func (h *FlagHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.DefaultValue == nil {
-		req.DefaultValue = json.RawMessage(`false`)
+		req.DefaultValue = model.ZeroValue(req.ValueType)
+	}
+	if string(req.JSONSchema) == "null" {
+		req.JSONSchema = nil
+	}
+	if req.JSONSchema != nil {
+		if req.ValueType != model.ValueTypeJSON {
+			writeError(w, http.StatusBadRequest, "json_schema is only allowed for json flags")
+			return
+		}
+		if err := model.CheckJSONSchema(req.JSONSchema); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json_schema: "+err.Error())
+			return
+		}
+	}
+	if err := model.ValidateFlagValue(req.ValueType, req.JSONSchema, req.DefaultValue); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid default_value: "+err.Error())
+		return
 	}
 	if req.Tags == nil {
 		req.Tags = []string{}
 	}
 
-	flag, err := h.flags.Create(r.Context(), project.ID, req.Key, req.Name, req.Description, req.ValueType, req.FlagType, req.DefaultValue, req.Tags)
+	flag, err := h.flags.CreateWithSchema(r.Context(), project.ID, req.Key, req.Name, req.Description, req.ValueType, req.FlagType, req.DefaultValue, req.Tags, req.JSONSchema)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
 			writeError(w, http.StatusConflict, "flag key already exists for this project")
@@ -132,7 +175,11 @@ func (h *FlagHandler) Create(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, flag)
 }
 
-// List handles GET /api/v1/projects/{key}/flags?tag=ui&search=dark
+// List handles GET /api/v1/projects/{key}/flags?tag=ui&search=dark&sort=-updated_at&limit=50&offset=0
+// sort is one of name, -name, updated_at, -updated_at or status; newest
+// first by default.
+// Without a limit every matching flag from offset on is returned. The total number of
+// matching flags is returned in the X-Total-Count header.
 func (h *FlagHandler) List(w http.ResponseWriter, r *http.Request) {
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
@@ -151,7 +198,30 @@ func (h *FlagHandler) List(w http.ResponseWriter, r *http.Request) {
 	lifecycleStatus := r.URL.Query().Get("lifecycle_status")
 	flagType := r.URL.Query().Get("flag_type")
 
-	flags, err := h.flags.ListByProject(r.Context(), project.ID, tag, search, lifecycleStatus, flagType)
+	limit := 0
+	offset := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = parsed
+	}
+
+	flags, total, err := h.flags.ListByProject(r.Context(), project.ID, tag, search, lifecycleStatus, flagType, r.URL.Query().Get("sort"), limit, offset)
+	if errors.Is(err, store.ErrInvalidSort) {
+		writeError(w, http.StatusBadRequest, "sort must be one of name, -name, updated_at, -updated_at, status")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list flags")
 		return
@@ -159,6 +229,7 @@ func (h *FlagHandler) List(w http.ResponseWriter, r *http.Request) {
 	if flags == nil {
 		flags = []model.Flag{}
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	writeJSON(w, http.StatusOK, flags)
 }
 
@@ -197,14 +268,25 @@ func (h *FlagHandler) Get(w http.ResponseWriter, r *http.Request) {
 		configs = []model.FlagEnvironmentConfig{}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"flag":                flag,
 		"environment_configs": configs,
-	})
+	}
+	if h.codeRefs != nil {
+		refs := h.codeReferences(r, flag)
+		if refs == nil {
+			refs = []model.CodeReference{}
+		}
+		resp["code_references"] = refs
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Update handles PUT /api/v1/projects/{key}/flags/{flag}
 func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -230,10 +312,17 @@ func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name        string         `json:"name"`
-		Description string         `json:"description"`
-		Tags        []string       `json:"tags"`
-		FlagType    model.FlagType `json:"flag_type"`
+		Name          string         `json:"name"`
+		Description   string         `json:"description"`
+		Tags          []string       `json:"tags"`
+		FlagType      model.FlagType `json:"flag_type"`
+		RolloutStages []string       `json:"rollout_stages"`
+		// PollTTLSeconds is left unchanged when omitted and cleared when null.
+		PollTTLSeconds json.RawMessage `json:"poll_ttl_seconds"`
+		// RequireIdentifier is left unchanged when omitted.
+		RequireIdentifier *bool `json:"require_identifier"`
+		// JSONSchema is left unchanged when omitted and removed when null.
+		JSONSchema json.RawMessage `json:"json_schema"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -247,11 +336,68 @@ func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid flag_type: must be one of release, experiment, operational, kill-switch, permission")
 		return
 	}
-	updated, err := h.flags.Update(r.Context(), flag.ID, req.Name, req.Description, req.Tags, flagTypeToUse)
+
+	stagesToUse := req.RolloutStages
+	if stagesToUse == nil {
+		stagesToUse = flag.RolloutStages
+	} else {
+		seen := make(map[string]bool, len(stagesToUse))
+		for _, stage := range stagesToUse {
+			if seen[stage] {
+				writeError(w, http.StatusBadRequest, "duplicate rollout stage: "+stage)
+				return
+			}
+			seen[stage] = true
+			if _, err := h.environments.FindByKey(r.Context(), project.ID, stage); err != nil {
+				writeError(w, http.StatusBadRequest, "unknown rollout stage environment: "+stage)
+				return
+			}
+		}
+	}
+
+	pollTTLToUse := flag.PollTTLSeconds
+	if req.PollTTLSeconds != nil {
+		pollTTLToUse = nil
+		if string(req.PollTTLSeconds) != "null" {
+			var ttl int
+			if err := json.Unmarshal(req.PollTTLSeconds, &ttl); err != nil || ttl <= 0 {
+				writeError(w, http.StatusBadRequest, "poll_ttl_seconds must be a positive integer or null")
+				return
+			}
+			pollTTLToUse = &ttl
+		}
+	}
+
+	requireIdentifierToUse := flag.RequireIdentifier
+	if req.RequireIdentifier != nil {
+		requireIdentifierToUse = *req.RequireIdentifier
+	}
+
+	schemaChanged := req.JSONSchema != nil
+	schemaToUse := req.JSONSchema
+	if string(schemaToUse) == "null" {
+		schemaToUse = nil
+	} else if schemaChanged {
+		if problems := h.checkSchemaChange(r, flag, schemaToUse); len(problems) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error":    "invalid json_schema",
+				"problems": problems,
+			})
+			return
+		}
+	}
+
+	updated, err := h.flags.Update(r.Context(), flag.ID, req.Name, req.Description, req.Tags, flagTypeToUse, stagesToUse, pollTTLToUse, requireIdentifierToUse, modifiedBy(r))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update flag")
 		return
 	}
+	if schemaChanged {
+		if updated, err = h.flags.SetJSONSchema(r.Context(), flag.ID, schemaToUse); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update flag")
+			return
+		}
+	}
 
 	// Best-effort audit logging
 	if user := auth.UserFromContext(r.Context()); user != nil {
@@ -275,6 +421,9 @@ func (h *FlagHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 // Delete handles DELETE /api/v1/projects/{key}/flags/{flag}
 func (h *FlagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -305,10 +454,18 @@ func (h *FlagHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Code references only warn: the scan may be out of date, and the flag
+	// stays restorable for the retention period.
+	refs := h.codeReferences(r, flag)
+
 	if err := h.flags.Delete(r.Context(), flag.ID); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to delete flag")
 		return
 	}
+	if len(refs) > 0 {
+		slog.Warn("deleted flag still referenced in code", "project", projectKey, "flag", flagKey, "references", len(refs))
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "flag is still referenced in %d code location(s)"`, len(refs)))
+	}
 
 	// Best-effort audit logging
 	if user := auth.UserFromContext(r.Context()); user != nil {
@@ -333,8 +490,69 @@ func (h *FlagHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Restore handles POST /api/v1/projects/{key}/flags/{flag}/restore
+// It brings back a deleted flag, still archived, with its environment configs.
+func (h *FlagHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.Restore(r.Context(), project.ID, flagKey)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "deleted flag not found")
+			return
+		}
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+			writeError(w, http.StatusConflict, "a flag with this key already exists")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to restore flag")
+		return
+	}
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		newVal, _ := json.Marshal(flag)
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &project.ID,
+			UserID:     &user.ID,
+			Action:     "restore",
+			EntityType: "flag",
+			EntityID:   flag.Key,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	h.refreshAllEnvironments(r.Context(), projectKey, project.ID, flagKey, stream.Event{
+		Type:  "flag_update",
+		Value: flag.LifecycleStatus == model.LifecycleArchived,
+	})
+
+	writeJSON(w, http.StatusOK, flag)
+}
+
 // Archive handles PUT /api/v1/projects/{key}/flags/{flag}/archive
+// Archiving a flag that enabled, unarchived flags still use as a
+// prerequisite returns 409 with those dependents.
 func (h *FlagHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -369,6 +587,18 @@ func (h *FlagHandler) Archive(w http.ResponseWriter, r *http.Request) {
 
 	var status model.LifecycleStatus
 	if req.Archived {
+		dependents, err := h.activeDependents(r.Context(), project.ID, flag.Key, nil)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to check flag dependents")
+			return
+		}
+		if len(dependents) > 0 {
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"error":      "flag is a prerequisite of enabled flags",
+				"dependents": dependents,
+			})
+			return
+		}
 		status = model.LifecycleArchived
 	} else {
 		status = model.LifecycleActive
@@ -412,7 +642,12 @@ func (h *FlagHandler) Archive(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateEnvironmentConfig handles PUT /api/v1/projects/{key}/flags/{flag}/environments/{env}
+// Fields present in the body replace the current config's; omitted or null
+// fields are left unchanged.
 func (h *FlagHandler) UpdateEnvironmentConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -449,61 +684,132 @@ func (h *FlagHandler) UpdateEnvironmentConfig(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var req struct {
-		Enabled        bool            `json:"enabled"`
-		DefaultVariant string          `json:"default_variant"`
-		Variants       json.RawMessage `json:"variants"`
-		TargetingRules json.RawMessage `json:"targeting_rules"`
-	}
-	if err := readJSON(r, &req); err != nil {
+	var patch environmentConfigPatch
+	if err := readJSON(r, &patch); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	if req.Variants == nil {
-		req.Variants = json.RawMessage(`[]`)
+	proposed, err := h.mergeEnvironmentConfig(r.Context(), flag.ID, env.ID, patch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
 	}
-	if req.TargetingRules == nil {
-		req.TargetingRules = json.RawMessage(`[]`)
+	candidate, problems := validateProposedConfig(flag, proposed)
+	if len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid environment config",
+			"problems": problems,
+		})
+		return
+	}
+
+	// A later rollout stage may not get ahead of the stage before it.
+	if stageKey, stagePct, ok := h.previousStageRollout(r.Context(), flag, envKey); ok {
+		if pct := rolloutPercentage(&model.FlagEnvironmentConfig{
+			Enabled:        proposed.Enabled,
+			DefaultVariant: candidate.DefaultVariant,
+			TargetingRules: candidate.TargetingRules,
+			DefaultRollout: candidate.DefaultRollout,
+		}); pct > stagePct {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"error":                    fmt.Sprintf("rollout of %d%% exceeds the %d%% currently rolled out in %s", pct, stagePct, stageKey),
+				"stage_environment":        stageKey,
+				"stage_rollout_percentage": stagePct,
+			})
+			return
+		}
+	}
+
+	// Approval-gated environments hold the update for another user to review.
+	if h.changes != nil {
+		settings, err := h.settings.Get(r.Context(), project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load project settings")
+			return
+		}
+		if settings.RequiresApproval(envKey) {
+			h.requestChange(w, r, project, flag, env, proposed)
+			return
+		}
 	}
 
-	cfg, err := h.flags.UpdateEnvironmentConfig(r.Context(), flag.ID, env.ID, req.Enabled, req.DefaultVariant, req.Variants, req.TargetingRules)
+	cfg, err := h.applyEnvironmentConfig(r, project, flag, env, proposed, "")
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update environment config")
 		return
 	}
 
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// applyEnvironmentConfig saves a flag's config for an environment, records
+// the audit entry, refreshes the cache and broadcasts the change. A non-empty
+// promotedFrom names the environment the config was promoted from.
+func (h *FlagHandler) applyEnvironmentConfig(r *http.Request, project *model.Project, flag *model.Flag, env *model.Environment, proposed model.ProposedConfig, promotedFrom string) (*model.FlagEnvironmentConfig, error) {
+	// The previous config feeds the audit entry.
+	previous, err := h.currentEnvironmentConfig(r.Context(), flag.ID, env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := h.flags.UpdateEnvironmentConfig(r.Context(), flag.ID, env.ID, proposed.Enabled, proposed.DefaultVariant, proposed.Variants, proposed.TargetingRules, proposed.Prerequisites, proposed.DefaultRollout,
+		proposed.IncludedUsers, proposed.ExcludedUsers, proposed.IncludedVariant, modifiedBy(r))
+	if err != nil {
+		return nil, err
+	}
+
+	h.environmentConfigChanged(r, project, flag, env, previous, cfg, promotedFrom)
+	return cfg, nil
+}
+
+// environmentConfigChanged records the audit entry for a saved environment
+// config, refreshes the cached flag and broadcasts the change. previous is
+// nil if the flag was not configured in the environment before.
+func (h *FlagHandler) environmentConfigChanged(r *http.Request, project *model.Project, flag *model.Flag, env *model.Environment, previous, cfg *model.FlagEnvironmentConfig, promotedFrom string) {
 	// Best-effort audit logging
 	if user := auth.UserFromContext(r.Context()); user != nil {
-		newVal, _ := json.Marshal(cfg)
-		if err := h.audit.Record(r.Context(), model.AuditEntry{
+		entry := model.AuditEntry{
 			ProjectID:  &project.ID,
 			UserID:     &user.ID,
 			Action:     "update",
 			EntityType: "flag_config",
 			EntityID:   flag.Key,
-			NewValue:   newVal,
-		}); err != nil {
+		}
+		if previous != nil {
+			entry.OldValue, _ = json.Marshal(previous)
+		}
+		entry.NewValue, _ = json.Marshal(cfg)
+		if promotedFrom != "" {
+			entry.Action = "promote"
+			entry.NewValue, _ = json.Marshal(struct {
+				*model.FlagEnvironmentConfig
+				PromotedFrom string `json:"promoted_from"`
+			}{cfg, promotedFrom})
+		}
+		entry.Diff, _ = json.Marshal(model.DiffEnvironmentConfigs(previous, cfg))
+		if err := h.audit.Record(r.Context(), entry); err != nil {
 			slog.Warn("failed to record audit log", "error", err)
 		}
 	}
 
 	// Refresh cache and broadcast SSE event
-	if err := h.cache.Refresh(r.Context(), h.pool, projectKey, envKey); err != nil {
+	if err := h.cache.RefreshFlag(r.Context(), h.pool, project.Key, env.Key, flag.Key); err != nil {
 		slog.Warn("failed to refresh cache", "error", err)
 	}
-	h.hub.Broadcast(projectKey, envKey, stream.Event{
+	h.hub.Broadcast(project.Key, env.Key, stream.Event{
 		Type:    "flag_update",
-		FlagKey: flagKey,
+		FlagKey: flag.Key,
 		Value:   cfg.Enabled,
 		Variant: cfg.DefaultVariant,
 	})
-
-	writeJSON(w, http.StatusOK, cfg)
 }
 
 // SetStaleness handles PUT /api/v1/projects/{key}/flags/{flag}/staleness
 func (h *FlagHandler) SetStaleness(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	flagKey := r.PathValue("flag")
 	if projectKey == "" || flagKey == "" {
@@ -559,3 +865,214 @@ func (h *FlagHandler) SetStaleness(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, updated)
 }
+
+// environmentConfigPatch is the body of a per-environment config update.
+// Omitted or null fields keep their current value, so a partial payload such
+// as {"enabled": true} cannot wipe variants or rules; an explicit [] still
+// clears a list.
+type environmentConfigPatch struct {
+	Enabled         *bool            `json:"enabled"`
+	DefaultVariant  *string          `json:"default_variant"`
+	Variants        *json.RawMessage `json:"variants"`
+	TargetingRules  *json.RawMessage `json:"targeting_rules"`
+	Prerequisites   *json.RawMessage `json:"prerequisites"`
+	DefaultRollout  *json.RawMessage `json:"default_rollout"`
+	IncludedUsers   *[]string        `json:"included_users"`
+	ExcludedUsers   *[]string        `json:"excluded_users"`
+	IncludedVariant *string          `json:"included_variant"`
+}
+
+// currentEnvironmentConfig returns the flag's config in the environment, or
+// nil if it has never been configured there. Any other error is returned, so
+// a failed read is never mistaken for an empty config.
+func (h *FlagHandler) currentEnvironmentConfig(ctx context.Context, flagID, envID string) (*model.FlagEnvironmentConfig, error) {
+	current, err := h.flags.GetEnvironmentConfig(ctx, flagID, envID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	return current, err
+}
+
+// mergeEnvironmentConfig applies patch to the flag's current config in the
+// environment, or to an empty config if it has none yet.
+func (h *FlagHandler) mergeEnvironmentConfig(ctx context.Context, flagID, envID string, patch environmentConfigPatch) (model.ProposedConfig, error) {
+	current, err := h.currentEnvironmentConfig(ctx, flagID, envID)
+	if err != nil {
+		return model.ProposedConfig{}, err
+	}
+	proposed := model.ProposedConfig{
+		Variants:       json.RawMessage(`[]`),
+		TargetingRules: json.RawMessage(`[]`),
+		Prerequisites:  json.RawMessage(`[]`),
+		DefaultRollout: json.RawMessage(`[]`),
+	}
+	if current != nil {
+		proposed.Enabled = current.Enabled
+		proposed.DefaultVariant = current.DefaultVariant
+		proposed.Variants, _ = json.Marshal(current.Variants)
+		proposed.TargetingRules, _ = json.Marshal(current.TargetingRules)
+		proposed.Prerequisites, _ = json.Marshal(current.Prerequisites)
+		proposed.DefaultRollout, _ = json.Marshal(current.DefaultRollout)
+		proposed.IncludedUsers = current.IncludedUsers
+		proposed.ExcludedUsers = current.ExcludedUsers
+		proposed.IncludedVariant = current.IncludedVariant
+	}
+	if patch.Enabled != nil {
+		proposed.Enabled = *patch.Enabled
+	}
+	if patch.DefaultVariant != nil {
+		proposed.DefaultVariant = *patch.DefaultVariant
+	}
+	if patch.Variants != nil {
+		proposed.Variants = *patch.Variants
+	}
+	if patch.TargetingRules != nil {
+		proposed.TargetingRules = *patch.TargetingRules
+	}
+	if patch.Prerequisites != nil {
+		proposed.Prerequisites = *patch.Prerequisites
+	}
+	if patch.DefaultRollout != nil {
+		proposed.DefaultRollout = *patch.DefaultRollout
+	}
+	if patch.IncludedUsers != nil {
+		proposed.IncludedUsers = *patch.IncludedUsers
+	}
+	if patch.ExcludedUsers != nil {
+		proposed.ExcludedUsers = *patch.ExcludedUsers
+	}
+	if patch.IncludedVariant != nil {
+		proposed.IncludedVariant = *patch.IncludedVariant
+	}
+	return proposed, nil
+}
+
+// validateProposedConfig parses and checks a merged config, returning the
+// parsed candidate and any problems found.
+func validateProposedConfig(flag *model.Flag, proposed model.ProposedConfig) (configCandidate, []validationProblem) {
+	candidate, problems := parseConfigCandidate(proposed.DefaultVariant, proposed.Variants, proposed.TargetingRules, proposed.Prerequisites, proposed.DefaultRollout)
+	candidate.IncludedUsers, candidate.ExcludedUsers, candidate.IncludedVariant = proposed.IncludedUsers, proposed.ExcludedUsers, proposed.IncludedVariant
+	if problems == nil {
+		problems = validateEnvironmentConfig(flag, candidate)
+	}
+	return candidate, problems
+}
+
+// ValidateEnvironmentConfig handles POST /api/v1/projects/{key}/flags/{flag}/environments/{env}/validate.
+// It merges the body into the current config and runs the same checks as
+// UpdateEnvironmentConfig, without persisting anything.
+func (h *FlagHandler) ValidateEnvironmentConfig(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	envKey := r.PathValue("env")
+	if projectKey == "" || flagKey == "" || envKey == "" {
+		writeError(w, http.StatusBadRequest, "project key, flag key, and environment key are required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, envKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	var patch environmentConfigPatch
+	if err := readJSON(r, &patch); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	proposed, err := h.mergeEnvironmentConfig(r.Context(), flag.ID, env.ID, patch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
+	}
+	_, problems := validateProposedConfig(flag, proposed)
+	if problems == nil {
+		problems = []validationProblem{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+	})
+}
+
+// TestRule handles POST /api/v1/projects/{key}/flags/{flag}/environments/{env}/rules/{index}/test
+// Body: {"context": {"user_id": "...", "attributes": {...}}}. It evaluates only
+// the conditions of the rule at index, ignoring earlier rules and percentage
+// rollout, and reports whether each condition passed.
+func (h *FlagHandler) TestRule(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, r.PathValue("flag"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, r.PathValue("env"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		writeError(w, http.StatusBadRequest, "rule index must be a non-negative integer")
+		return
+	}
+
+	var req struct {
+		Context model.EvaluationContext `json:"context"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Context.Attributes == nil {
+		req.Context.Attributes = map[string]any{}
+	}
+
+	cfg, err := h.flags.GetEnvironmentConfig(r.Context(), flag.ID, env.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
+	}
+	if index >= len(cfg.TargetingRules) {
+		writeError(w, http.StatusNotFound, "rule not found")
+		return
+	}
+	h.cache.Prepare(project.Key, cfg)
+
+	rule := cfg.TargetingRules[index]
+	// Grouped conditions follow the flat ones, depth first.
+	conditions := []model.ConditionResult{}
+	for _, list := range rule.ConditionLists() {
+		conditions = append(conditions, evaluation.EvaluateConditions(list, &req.Context)...)
+	}
+	matched := evaluation.MatchesRule(&rule, &req.Context)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"rule_index": index,
+		"variant":    rule.Variant,
+		"matched":    matched,
+		"conditions": conditions,
+	})
+}
@@ -0,0 +1,1042 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+func newTestFlagHandler(pool *pgxpool.Pool) *handler.FlagHandler {
+	return handler.NewFlagHandler(
+		store.NewFlagStore(pool),
+		store.NewProjectStore(pool),
+		store.NewEnvironmentStore(pool),
+		store.NewAuditStore(pool),
+		stream.NewHub(),
+		evaluation.NewCache(),
+		pool,
+		store.NewUnknownFlagStore(pool),
+	)
+}
+
+// setupFlagEnv creates a project with a single "production" environment and a
+// string flag "theme", returning the project key.
+func setupFlagEnv(t *testing.T, pool *pgxpool.Pool, prefix string) string {
+	t.Helper()
+	ctx := context.Background()
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey(prefix), "Flag Handler", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production"); err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	if _, err := store.NewFlagStore(pool).Create(ctx, project.ID, "theme", "Theme", "", model.ValueTypeString, model.FlagTypeRelease, json.RawMessage(`"light"`), []string{}); err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	return project.Key
+}
+
+type validateResponse struct {
+	Valid    bool `json:"valid"`
+	Problems []struct {
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	} `json:"problems"`
+}
+
+func validateConfig(t *testing.T, pool *pgxpool.Pool, projectKey, body string) validateResponse {
+	t.Helper()
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", "theme")
+	req.SetPathValue("env", "production")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.ValidateEnvironmentConfig)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Validate: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var resp validateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_UndefinedVariant(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validatevariant")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{"conditions": [], "variant": "sepia"}]
+	}`)
+
+	if resp.Valid {
+		t.Fatal("expected config to be invalid")
+	}
+	if len(resp.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got %d: %+v", len(resp.Problems), resp.Problems)
+	}
+	if resp.Problems[0].Path != "targeting_rules[0].variant" {
+		t.Errorf("Path: got %q, want %q", resp.Problems[0].Path, "targeting_rules[0].variant")
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_MergesPartialBody(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projectKey := setupFlagEnv(t, pool, "validatepartial")
+
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+	flag, err := store.NewFlagStore(pool).FindByKey(ctx, project.ID, "theme")
+	if err != nil {
+		t.Fatalf("finding flag: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).FindByKey(ctx, project.ID, "production")
+	if err != nil {
+		t.Fatalf("finding environment: %v", err)
+	}
+	if _, err := store.NewFlagStore(pool).UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "light",
+		json.RawMessage(`[{"key":"light","value":"light"},{"key":"dark","value":"dark"}]`),
+		json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", ""); err != nil {
+		t.Fatalf("updating environment config: %v", err)
+	}
+
+	// Omitted variants come from the saved config, as they would on update
+	if resp := validateConfig(t, pool, projectKey, `{"targeting_rules": [{"conditions": [], "variant": "dark"}]}`); !resp.Valid {
+		t.Errorf("expected rule on a saved variant to be valid, got %+v", resp.Problems)
+	}
+	if resp := validateConfig(t, pool, projectKey, `{"targeting_rules": [{"conditions": [], "variant": "sepia"}]}`); resp.Valid {
+		t.Error("expected rule on an undefined variant to be invalid")
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_VariantWeightsMustSumTo100(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validateweights")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{
+			"conditions": [],
+			"variant_weights": [{"variant": "light", "weight": 50}, {"variant": "dark", "weight": 40}]
+		}]
+	}`)
+
+	if resp.Valid {
+		t.Fatal("expected config to be invalid")
+	}
+	if len(resp.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got %d: %+v", len(resp.Problems), resp.Problems)
+	}
+	if resp.Problems[0].Path != "targeting_rules[0].variant_weights" {
+		t.Errorf("Path: got %q, want %q", resp.Problems[0].Path, "targeting_rules[0].variant_weights")
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_SelfPrerequisite(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validateprereq")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"prerequisites": [{"flag_key": "theme", "variant": "on"}]
+	}`)
+
+	if resp.Valid {
+		t.Fatal("expected config to be invalid")
+	}
+	if len(resp.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got %d: %+v", len(resp.Problems), resp.Problems)
+	}
+	if resp.Problems[0].Path != "prerequisites[0].flag_key" {
+		t.Errorf("Path: got %q, want %q", resp.Problems[0].Path, "prerequisites[0].flag_key")
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_BadRegex(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validateregex")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{
+			"conditions": [
+				{"attribute": "country", "operator": "equals", "value": "DE"},
+				{"attribute": "email", "operator": "matches", "value": "([a-z"}
+			],
+			"variant": "dark"
+		}]
+	}`)
+
+	if resp.Valid {
+		t.Fatal("expected config to be invalid")
+	}
+	if len(resp.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got %d: %+v", len(resp.Problems), resp.Problems)
+	}
+	if resp.Problems[0].Path != "targeting_rules[0].conditions[1].value" {
+		t.Errorf("Path: got %q, want %q", resp.Problems[0].Path, "targeting_rules[0].conditions[1].value")
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_TimeOperators(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validatetime")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{
+			"conditions": [
+				{"attribute": "signup_at", "operator": "after", "value": "2024-01-01T00:00:00Z"},
+				{"attribute": "trial_ends_at", "operator": "before", "value": 1735689600},
+				{"attribute": "last_seen", "operator": "within_last", "value": "168h"},
+				{"attribute": "signup_at", "operator": "before", "value": "next week"},
+				{"attribute": "last_seen", "operator": "within_last", "value": "a week"}
+			],
+			"variant": "dark"
+		}]
+	}`)
+
+	if resp.Valid {
+		t.Fatal("expected config to be invalid")
+	}
+	want := []string{"targeting_rules[0].conditions[3].value", "targeting_rules[0].conditions[4].value"}
+	if len(resp.Problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d: %+v", len(want), len(resp.Problems), resp.Problems)
+	}
+	for i, path := range want {
+		if resp.Problems[i].Path != path {
+			t.Errorf("problem %d: got path %q, want %q", i, resp.Problems[i].Path, path)
+		}
+	}
+}
+
+func TestFlagHandler_ValidateEnvironmentConfig_Valid(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "validateok")
+
+	resp := validateConfig(t, pool, projectKey, `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{"conditions": [{"attribute": "plan", "operator": "in", "value": ["pro", "enterprise"]}], "variant": "dark"}]
+	}`)
+
+	if !resp.Valid {
+		t.Fatalf("expected config to be valid, got problems: %+v", resp.Problems)
+	}
+	if resp.Problems == nil || len(resp.Problems) != 0 {
+		t.Errorf("expected empty problems array, got %+v", resp.Problems)
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_RejectsInvalid(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "updateinvalid")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{
+		"enabled": true,
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}],
+		"targeting_rules": [{"conditions": [], "variant": "missing"}]
+	}`))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", "theme")
+	req.SetPathValue("env", "production")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.UpdateEnvironmentConfig)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
+
+func TestFlagHandler_NumberFlagRejectsStringValues(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "numberflag")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	do := func(fn http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "max-items")
+		req.SetPathValue("env", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(h.Create, `{"key": "max-items", "name": "Max Items", "value_type": "number", "default_value": "ten"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Create with string default: expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(h.Create, `{"key": "max-items", "name": "Max Items", "value_type": "number"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var flag model.Flag
+	if err := json.NewDecoder(rec.Body).Decode(&flag); err != nil {
+		t.Fatalf("decoding flag: %v", err)
+	}
+	if string(flag.DefaultValue) != "0" {
+		t.Errorf("expected zero default for a number flag, got %s", flag.DefaultValue)
+	}
+
+	rec = do(h.UpdateEnvironmentConfig, `{
+		"enabled": true,
+		"default_variant": "small",
+		"variants": [{"key": "small", "value": 10}, {"key": "large", "value": "100"}]
+	}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("UpdateEnvironmentConfig: expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp validateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Problems) != 1 || resp.Problems[0].Path != "variants[1].value" || !strings.Contains(resp.Problems[0].Message, `"large"`) {
+		t.Errorf("expected a problem naming variant large, got %+v", resp.Problems)
+	}
+}
+
+func TestFlagHandler_JSONSchemaRejectsVariantMissingProperty(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "jsonschema")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	do := func(fn http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "banner")
+		req.SetPathValue("env", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		return rec
+	}
+
+	schema := `{"type": "object", "required": ["color"], "properties": {"color": {"type": "string"}}}`
+	rec := do(h.Create, `{"key": "banner", "name": "Banner", "value_type": "json", "json_schema": `+schema+`}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "#/required") {
+		t.Fatalf("Create with default missing color: expected 400 naming #/required, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(h.Create, `{"key": "banner", "name": "Banner", "value_type": "json", "default_value": {"color": "red"}, "json_schema": `+schema+`}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(h.UpdateEnvironmentConfig, `{
+		"enabled": true,
+		"default_variant": "red",
+		"variants": [{"key": "red", "value": {"color": "red"}}, {"key": "plain", "value": {"size": 2}}]
+	}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("UpdateEnvironmentConfig: expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp validateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Problems) != 1 || resp.Problems[0].Path != "variants[1].value" || !strings.Contains(resp.Problems[0].Message, "schema path #/required") {
+		t.Errorf("expected a problem for variant plain at schema path #/required, got %+v", resp.Problems)
+	}
+
+	rec = do(h.UpdateEnvironmentConfig, `{
+		"enabled": true,
+		"default_variant": "red",
+		"variants": [{"key": "red", "value": {"color": "red"}}, {"key": "blue", "value": {"color": "blue"}}]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateEnvironmentConfig with matching variants: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_PersistsSalt(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "updatesalt")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{
+		"enabled": true,
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{"conditions": [], "variant": "dark", "percentage_rollout": 50, "salt": "relaunch-2"}]
+	}`))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", "theme")
+	req.SetPathValue("env", "production")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.UpdateEnvironmentConfig)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var cfg model.FlagEnvironmentConfig
+	if err := json.NewDecoder(rec.Body).Decode(&cfg); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(cfg.TargetingRules) != 1 || cfg.TargetingRules[0].Salt != "relaunch-2" {
+		t.Errorf("targeting rules = %+v, want salt relaunch-2", cfg.TargetingRules)
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_AuditDiff(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "auditdiff")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	update := func(enabled bool) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{
+			"enabled": `+strconv.FormatBool(enabled)+`,
+			"default_variant": "light",
+			"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+			"targeting_rules": [{"conditions": [], "variant": "dark", "percentage_rollout": 50}]
+		}`))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("env", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.UpdateEnvironmentConfig)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+	update(false)
+	update(true)
+
+	project, err := store.NewProjectStore(pool).FindByKey(context.Background(), projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+	entries, _, err := store.NewAuditStore(pool).ListByProject(context.Background(), project.ID, store.AuditFilter{EntityType: "flag_config"}, 1, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("listing audit entries: %v (%d entries)", err, len(entries))
+	}
+	if entries[0].OldValue == nil {
+		t.Error("expected the previous config as old_value")
+	}
+	var diff map[string]json.RawMessage
+	if err := json.Unmarshal(entries[0].Diff, &diff); err != nil {
+		t.Fatalf("decoding diff %s: %v", entries[0].Diff, err)
+	}
+	if len(diff) != 1 {
+		t.Fatalf("expected only enabled in diff, got %s", entries[0].Diff)
+	}
+	var enabled struct{ Old, New bool }
+	if err := json.Unmarshal(diff["enabled"], &enabled); err != nil || enabled.Old || !enabled.New {
+		t.Errorf("expected enabled false -> true, got %s", diff["enabled"])
+	}
+}
+
+// setupStagedFlag creates a project with staging and production environments
+// and a boolean flag "checkout" rolled out staging → production, with staging
+// at the given rollout percentage. It returns the project key.
+func setupStagedFlag(t *testing.T, pool *pgxpool.Pool, prefix string, stagingPct int) string {
+	t.Helper()
+	ctx := context.Background()
+	envs := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey(prefix), "Staged Rollout", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	staging, err := envs.Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating staging environment: %v", err)
+	}
+	if _, err := envs.Create(ctx, project.ID, "production", "Production"); err != nil {
+		t.Fatalf("creating production environment: %v", err)
+	}
+	flag, err := flags.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	if _, err := flags.Update(ctx, flag.ID, flag.Name, flag.Description, flag.Tags, flag.FlagType, []string{"staging", "production"}, nil, false, ""); err != nil {
+		t.Fatalf("setting rollout stages: %v", err)
+	}
+	if _, err := flags.UpdateEnvironmentConfig(ctx, flag.ID, staging.ID, true, "off",
+		json.RawMessage(`[{"key":"off","value":false},{"key":"on","value":true}]`),
+		json.RawMessage(`[{"conditions":[],"variant":"on","percentage_rollout":`+strconv.Itoa(stagingPct)+`}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "",
+	); err != nil {
+		t.Fatalf("configuring staging: %v", err)
+	}
+	return project.Key
+}
+
+func updateProductionRollout(t *testing.T, pool *pgxpool.Pool, projectKey string, pct int) *httptest.ResponseRecorder {
+	t.Helper()
+	return updateProductionTargeting(t, pool, projectKey,
+		`"targeting_rules": [{"conditions": [], "variant": "on", "percentage_rollout": `+strconv.Itoa(pct)+`}]`)
+}
+
+// updateProductionTargeting puts an enabled production config for the
+// setupStagedFlag flag, with targeting given as body fields such as
+// "targeting_rules" and "default_rollout".
+func updateProductionTargeting(t *testing.T, pool *pgxpool.Pool, projectKey, targeting string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	body := `{
+		"enabled": true,
+		"default_variant": "off",
+		"variants": [{"key": "off", "value": false}, {"key": "on", "value": true}],
+		` + targeting + `
+	}`
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", "checkout")
+	req.SetPathValue("env", "production")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.UpdateEnvironmentConfig)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_RolloutAboveStaging(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupStagedFlag(t, pool, "rolloutabove", 25)
+
+	rec := updateProductionRollout(t, pool, projectKey, 50)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		StageEnvironment       string `json:"stage_environment"`
+		StageRolloutPercentage int    `json:"stage_rollout_percentage"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.StageEnvironment != "staging" {
+		t.Errorf("stage_environment: got %q, want %q", resp.StageEnvironment, "staging")
+	}
+	if resp.StageRolloutPercentage != 25 {
+		t.Errorf("stage_rollout_percentage: got %d, want 25", resp.StageRolloutPercentage)
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_RolloutAtOrBelowStaging(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupStagedFlag(t, pool, "rolloutbelow", 25)
+
+	for _, pct := range []int{25, 10} {
+		rec := updateProductionRollout(t, pool, projectKey, pct)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("rollout %d%%: expected status %d, got %d: %s", pct, http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_StageGuardCountsDefaultRollout(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupStagedFlag(t, pool, "rolloutdefault", 25)
+
+	// Only the "on" arm of the default rollout counts toward the rollout
+	rec := updateProductionTargeting(t, pool, projectKey, `"default_rollout": [{"variant": "off", "weight": 80}, {"variant": "on", "weight": 20}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("20%% default rollout: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	rec = updateProductionTargeting(t, pool, projectKey, `"default_rollout": [{"variant": "off", "weight": 50}, {"variant": "on", "weight": 50}]`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "rollout of 50%") {
+		t.Errorf("50%% default rollout: expected status %d for 50%%, got %d: %s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_StageGuardCountsVariantWeights(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupStagedFlag(t, pool, "rolloutweights", 25)
+
+	// 40% of users match, half of them get "on": 20% rolled out
+	rec := updateProductionTargeting(t, pool, projectKey, `"targeting_rules": [{"conditions": [], "percentage_rollout": 40,
+		"variant_weights": [{"variant": "off", "weight": 50}, {"variant": "on", "weight": 50}]}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("20%% weighted rule: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	rec = updateProductionTargeting(t, pool, projectKey, `"targeting_rules": [{"conditions": [],
+		"variant_weights": [{"variant": "off", "weight": 70}, {"variant": "on", "weight": 30}]}]`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "rollout of 30%") {
+		t.Errorf("30%% weighted rule: expected status %d for 30%%, got %d: %s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+}
+
+func TestFlagHandler_TestRule_IgnoresEarlierRules(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projectKey := setupFlagEnv(t, pool, "testrule")
+
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+	flag, err := store.NewFlagStore(pool).FindByKey(ctx, project.ID, "theme")
+	if err != nil {
+		t.Fatalf("finding flag: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).FindByKey(ctx, project.ID, "production")
+	if err != nil {
+		t.Fatalf("finding environment: %v", err)
+	}
+	// Rule 0 matches every context, so rule 1 never wins in a real evaluation.
+	_, err = store.NewFlagStore(pool).UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "light",
+		json.RawMessage(`[{"key":"light","value":"light"},{"key":"dark","value":"dark"}]`),
+		json.RawMessage(`[
+			{"conditions": [], "variant": "light"},
+			{"conditions": [
+				{"attribute": "country", "operator": "equals", "value": "DE"},
+				{"attribute": "plan", "operator": "in", "value": ["pro", "enterprise"]}
+			], "variant": "dark", "percentage_rollout": 0}
+		]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("updating environment config: %v", err)
+	}
+
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	type testRuleResponse struct {
+		Matched    bool                    `json:"matched"`
+		Variant    string                  `json:"variant"`
+		Conditions []model.ConditionResult `json:"conditions"`
+	}
+	testRule := func(index, body string) (int, testRuleResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("env", "production")
+		req.SetPathValue("index", index)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.TestRule)).ServeHTTP(rec, req)
+		var resp testRuleResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := testRule("1", `{"context": {"attributes": {"country": "DE", "plan": "pro"}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("TestRule: got status %d", code)
+	}
+	if !resp.Matched || resp.Variant != "dark" {
+		t.Errorf("expected rule 1 to match with variant dark despite rule 0 and 0%% rollout, got %+v", resp)
+	}
+
+	_, resp = testRule("1", `{"context": {"attributes": {"country": "DE", "plan": "free"}}}`)
+	if resp.Matched {
+		t.Error("expected rule 1 not to match for plan free")
+	}
+	if len(resp.Conditions) != 2 || !resp.Conditions[0].Passed || resp.Conditions[1].Passed {
+		t.Errorf("expected country to pass and plan to fail, got %+v", resp.Conditions)
+	}
+	if resp.Conditions[1].Actual != "free" {
+		t.Errorf("expected actual plan %q, got %v", "free", resp.Conditions[1].Actual)
+	}
+
+	if code, _ := testRule("2", `{"context": {}}`); code != http.StatusNotFound {
+		t.Errorf("out-of-range index: expected 404, got %d", code)
+	}
+	if code, _ := testRule("x", `{"context": {}}`); code != http.StatusBadRequest {
+		t.Errorf("non-numeric index: expected 400, got %d", code)
+	}
+}
+
+func TestFlagHandler_TestRule_ResolvesSegments(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projectKey := setupFlagEnv(t, pool, "testruleseg")
+
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+	flag, err := store.NewFlagStore(pool).FindByKey(ctx, project.ID, "theme")
+	if err != nil {
+		t.Fatalf("finding flag: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).FindByKey(ctx, project.ID, "production")
+	if err != nil {
+		t.Fatalf("finding environment: %v", err)
+	}
+	_, err = store.NewFlagStore(pool).UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "light",
+		json.RawMessage(`[{"key":"light","value":"light"},{"key":"dark","value":"dark"}]`),
+		json.RawMessage(`[{"conditions": [{"attribute": "", "operator": "in_segment", "value": "pro-users"}], "variant": "dark"}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("updating environment config: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	cache.SetSegments(projectKey, map[string][]model.Condition{
+		"pro-users": {{Attribute: "plan", Operator: "equals", Value: "pro"}},
+	})
+	h := handler.NewFlagHandler(store.NewFlagStore(pool), store.NewProjectStore(pool), store.NewEnvironmentStore(pool),
+		store.NewAuditStore(pool), stream.NewHub(), cache, pool, store.NewUnknownFlagStore(pool))
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	testRule := func(body string) (matched bool) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("env", "production")
+		req.SetPathValue("index", "0")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.TestRule)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("TestRule: got status %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			Matched bool `json:"matched"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return resp.Matched
+	}
+
+	if !testRule(`{"context": {"attributes": {"plan": "pro"}}}`) {
+		t.Error("expected a segment member to match the in_segment rule")
+	}
+	if testRule(`{"context": {"attributes": {"plan": "free"}}}`) {
+		t.Error("expected a non-member not to match the in_segment rule")
+	}
+}
+
+func toggleAll(t *testing.T, pool *pgxpool.Pool, h *handler.FlagHandler, projectKey, flagKey string, enabled bool) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"enabled": `+strconv.FormatBool(enabled)+`}`))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("flag", flagKey)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.ToggleAll)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFlagHandler_ToggleAll_FlipsEveryEnvironment(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	envs := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("toggleall"), "Toggle All", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	envKeys := []string{"development", "staging", "production"}
+	for _, key := range envKeys {
+		if _, err := envs.Create(ctx, project.ID, key, key); err != nil {
+			t.Fatalf("creating environment %s: %v", key, err)
+		}
+	}
+	flag, err := flags.Create(ctx, project.ID, "kill-switch", "Kill Switch", "", model.ValueTypeBoolean, model.FlagTypeKillSwitch, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	hub := stream.NewHub()
+	h := handler.NewFlagHandler(flags, projects, envs, store.NewAuditStore(pool), hub, evaluation.NewCache(), pool, store.NewUnknownFlagStore(pool))
+	subs := make(map[string]chan stream.Event)
+	for _, key := range envKeys {
+		ch, err := hub.Subscribe(project.Key, key)
+		if err != nil {
+			t.Fatalf("subscribing to %s: %v", key, err)
+		}
+		subs[key] = ch
+	}
+
+	rec := toggleAll(t, pool, h, project.Key, "kill-switch", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Enabled      bool     `json:"enabled"`
+		Environments []string `json:"environments"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if !resp.Enabled || len(resp.Environments) != len(envKeys) {
+		t.Errorf("expected all %d environments enabled, got %+v", len(envKeys), resp)
+	}
+
+	configs, err := flags.GetAllEnvironmentConfigs(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("getting configs: %v", err)
+	}
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			t.Errorf("expected environment %s to be enabled", cfg.EnvironmentID)
+		}
+	}
+
+	for key, ch := range subs {
+		select {
+		case evt := <-ch:
+			if evt.Type != "flag_update" || evt.FlagKey != "kill-switch" || evt.Value != true {
+				t.Errorf("%s: unexpected event %+v", key, evt)
+			}
+		default:
+			t.Errorf("%s: expected a flag_update event", key)
+		}
+	}
+
+	entries, _, err := store.NewAuditStore(pool).ListByProject(ctx, project.ID, store.AuditFilter{EntityType: "flag_config"}, 10, 0)
+	if err != nil {
+		t.Fatalf("listing audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != "toggle_all" {
+		t.Fatalf("expected one toggle_all audit entry, got %+v", entries)
+	}
+	var audited struct {
+		Enabled      bool     `json:"enabled"`
+		Environments []string `json:"environments"`
+	}
+	if err := json.Unmarshal(entries[0].NewValue, &audited); err != nil || len(audited.Environments) != len(envKeys) {
+		t.Errorf("expected audit to list %d environments, got %s", len(envKeys), entries[0].NewValue)
+	}
+
+	// Toggling to the current state changes nothing.
+	rec = toggleAll(t, pool, h, project.Key, "kill-switch", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Environments) != 0 {
+		t.Errorf("expected no environments to change, got %s", rec.Body.String())
+	}
+}
+
+func TestFlagHandler_ToggleAll_RejectsOutOfOrderStages(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupStagedFlag(t, pool, "toggleallstaged", 10)
+
+	// Production has no rules, so enabling it would serve 100% against
+	// staging's 10%.
+	rec := toggleAll(t, pool, newTestFlagHandler(pool), projectKey, "checkout", true)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+
+	rec = toggleAll(t, pool, newTestFlagHandler(pool), projectKey, "checkout", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected disabling to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFlagHandler_Promote_CopiesConfigAfterDiff(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	envs := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("promote"), "Promote", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	staging, err := envs.Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating staging: %v", err)
+	}
+	production, err := envs.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating production: %v", err)
+	}
+	flag, err := flags.Create(ctx, project.ID, "theme", "Theme", "", model.ValueTypeString, model.FlagTypeRelease, json.RawMessage(`"light"`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	if _, err := flags.UpdateEnvironmentConfig(ctx, flag.ID, staging.ID, true, "light",
+		json.RawMessage(`[{"key":"light","value":"light"},{"key":"dark","value":"dark"}]`),
+		json.RawMessage(`[{"conditions":[{"attribute":"plan","operator":"equals","value":"pro"}],"variant":"dark","percentage_rollout":25}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "",
+	); err != nil {
+		t.Fatalf("configuring staging: %v", err)
+	}
+
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+	serve := func(fn http.HandlerFunc, method string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/", nil)
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("from", "staging")
+		req.SetPathValue("to", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+	type diffResponse struct {
+		Diff      model.ConfigDiff `json:"diff"`
+		Identical bool             `json:"identical"`
+	}
+
+	var before diffResponse
+	if err := json.Unmarshal(serve(h.PromotionDiff, http.MethodGet).Body.Bytes(), &before); err != nil {
+		t.Fatalf("decoding diff: %v", err)
+	}
+	if before.Identical {
+		t.Fatal("expected staging and production to differ before promotion")
+	}
+	if before.Diff.Enabled == nil || len(before.Diff.VariantsAdded) != 2 || len(before.Diff.RulesChanged) != 1 {
+		t.Errorf("expected enabled, variants and rule differences, got %+v", before.Diff)
+	}
+
+	serve(h.Promote, http.MethodPost)
+
+	src, err := flags.GetEnvironmentConfig(ctx, flag.ID, staging.ID)
+	if err != nil {
+		t.Fatalf("getting staging config: %v", err)
+	}
+	dst, err := flags.GetEnvironmentConfig(ctx, flag.ID, production.ID)
+	if err != nil {
+		t.Fatalf("getting production config: %v", err)
+	}
+	if dst.Enabled != src.Enabled || dst.DefaultVariant != src.DefaultVariant {
+		t.Errorf("expected enabled/default variant %v/%q, got %v/%q", src.Enabled, src.DefaultVariant, dst.Enabled, dst.DefaultVariant)
+	}
+	srcRules, _ := json.Marshal(src.TargetingRules)
+	dstRules, _ := json.Marshal(dst.TargetingRules)
+	srcVariants, _ := json.Marshal(src.Variants)
+	dstVariants, _ := json.Marshal(dst.Variants)
+	if string(srcRules) != string(dstRules) || string(srcVariants) != string(dstVariants) {
+		t.Errorf("expected identical variants and rules, got %s %s vs %s %s", dstVariants, dstRules, srcVariants, srcRules)
+	}
+
+	var after diffResponse
+	if err := json.Unmarshal(serve(h.PromotionDiff, http.MethodGet).Body.Bytes(), &after); err != nil {
+		t.Fatalf("decoding diff: %v", err)
+	}
+	if !after.Identical {
+		t.Errorf("expected no differences after promotion, got %+v", after.Diff)
+	}
+
+	entries, _, err := store.NewAuditStore(pool).ListByProject(ctx, project.ID, store.AuditFilter{EntityType: "flag_config"}, 1, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("listing audit entries: %v (%d entries)", err, len(entries))
+	}
+	var audited struct {
+		PromotedFrom string `json:"promoted_from"`
+	}
+	if err := json.Unmarshal(entries[0].NewValue, &audited); err != nil || entries[0].Action != "promote" || audited.PromotedFrom != "staging" {
+		t.Errorf("expected a promote audit entry from staging, got %s %s", entries[0].Action, entries[0].NewValue)
+	}
+}
+
+func TestFlagHandler_UpdateEnvironmentConfig_PartialPayloadKeepsRules(t *testing.T) {
+	pool := testPool(t)
+	projectKey := setupFlagEnv(t, pool, "partialcfg")
+	h := newTestFlagHandler(pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	update := func(body string) model.FlagEnvironmentConfig {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("env", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(http.HandlerFunc(h.UpdateEnvironmentConfig)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("UpdateEnvironmentConfig %s: expected 200, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+		var cfg model.FlagEnvironmentConfig
+		if err := json.NewDecoder(rec.Body).Decode(&cfg); err != nil {
+			t.Fatalf("decoding config: %v", err)
+		}
+		return cfg
+	}
+
+	update(`{
+		"enabled": false,
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{"conditions": [{"attribute": "plan", "operator": "equals", "value": "pro"}], "variant": "dark"}]
+	}`)
+
+	cfg := update(`{"enabled": true}`)
+	if !cfg.Enabled {
+		t.Error("expected the partial update to enable the flag")
+	}
+	if cfg.DefaultVariant != "light" || len(cfg.Variants) != 2 {
+		t.Errorf("expected default variant and variants to be kept, got %q and %d variants", cfg.DefaultVariant, len(cfg.Variants))
+	}
+	if len(cfg.TargetingRules) != 1 || cfg.TargetingRules[0].Variant != "dark" {
+		t.Errorf("expected the targeting rule to be kept, got %+v", cfg.TargetingRules)
+	}
+
+	cfg = update(`{"targeting_rules": []}`)
+	if len(cfg.TargetingRules) != 0 {
+		t.Errorf("expected explicit [] to clear targeting rules, got %+v", cfg.TargetingRules)
+	}
+	if !cfg.Enabled || len(cfg.Variants) != 2 {
+		t.Errorf("expected enabled and variants to be kept, got enabled=%v with %d variants", cfg.Enabled, len(cfg.Variants))
+	}
+}
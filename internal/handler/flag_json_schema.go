@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// checkSchemaChange checks that schema can be set on flag: it must be a json
+// flag, the schema must be usable, and the flag's default value and every
+// variant value already configured must match it.
+func (h *FlagHandler) checkSchemaChange(r *http.Request, flag *model.Flag, schema json.RawMessage) []validationProblem {
+	if flag.ValueType != model.ValueTypeJSON {
+		return []validationProblem{{Path: "json_schema", Message: "json_schema is only allowed for json flags"}}
+	}
+	if err := model.CheckJSONSchema(schema); err != nil {
+		return []validationProblem{{Path: "json_schema", Message: err.Error()}}
+	}
+
+	var problems []validationProblem
+	if err := model.ValidateAgainstSchema(schema, flag.DefaultValue); err != nil {
+		problems = append(problems, validationProblem{Path: "default_value", Message: err.Error()})
+	}
+	configs, err := h.flags.GetAllEnvironmentConfigs(r.Context(), flag.ID)
+	if err != nil {
+		return append(problems, validationProblem{Path: "json_schema", Message: "failed to load environment configs"})
+	}
+	envKeys := make(map[string]string)
+	if envs, err := h.environments.ListByProject(r.Context(), flag.ProjectID); err == nil {
+		for _, env := range envs {
+			envKeys[env.ID] = env.Key
+		}
+	}
+	for _, cfg := range configs {
+		for i, v := range cfg.Variants {
+			path := fmt.Sprintf("environments[%q].variants[%d]", envKeys[cfg.EnvironmentID], i)
+			if err := model.ValidateAgainstSchema(schema, v.Value); err != nil {
+				problems = append(problems, validationProblem{Path: path + ".value", Message: fmt.Sprintf("variant %q: %v", v.Key, err)})
+			}
+			for locale, raw := range v.Localized {
+				if err := model.ValidateAgainstSchema(schema, raw); err != nil {
+					problems = append(problems, validationProblem{Path: fmt.Sprintf("%s.localized[%q]", path, locale), Message: fmt.Sprintf("variant %q: %v", v.Key, err)})
+				}
+			}
+		}
+	}
+	return problems
+}
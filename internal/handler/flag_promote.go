@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// promotionEnvs resolves the project, flag and the source and target
+// environment configs named by a promotion request's path. It writes an error
+// response and returns ok=false if any of them is missing.
+func (h *FlagHandler) promotionEnvs(w http.ResponseWriter, r *http.Request) (project *model.Project, flag *model.Flag, to *model.Environment, src, dst *model.FlagEnvironmentConfig, ok bool) {
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	fromKey, toKey := r.PathValue("from"), r.PathValue("to")
+	if projectKey == "" || flagKey == "" || fromKey == "" || toKey == "" {
+		writeError(w, http.StatusBadRequest, "project key, flag key and both environment keys are required")
+		return nil, nil, nil, nil, nil, false
+	}
+	if fromKey == toKey {
+		writeError(w, http.StatusBadRequest, "source and target environments must differ")
+		return nil, nil, nil, nil, nil, false
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, nil, nil, nil, false
+	}
+	flag, err = h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return nil, nil, nil, nil, nil, false
+	}
+	from, err := h.environments.FindByKey(r.Context(), project.ID, fromKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "source environment not found")
+		return nil, nil, nil, nil, nil, false
+	}
+	to, err = h.environments.FindByKey(r.Context(), project.ID, toKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "target environment not found")
+		return nil, nil, nil, nil, nil, false
+	}
+	if src, err = h.flags.GetEnvironmentConfig(r.Context(), flag.ID, from.ID); err != nil {
+		writeError(w, http.StatusNotFound, "flag is not configured in the source environment")
+		return nil, nil, nil, nil, nil, false
+	}
+	if dst, err = h.flags.GetEnvironmentConfig(r.Context(), flag.ID, to.ID); err != nil {
+		writeError(w, http.StatusNotFound, "flag is not configured in the target environment")
+		return nil, nil, nil, nil, nil, false
+	}
+	return project, flag, to, src, dst, true
+}
+
+// promotedConfig returns the target config as it would be after promoting
+// src into it: enabled, default variant, variants, targeting rules and
+// default rollout are copied, and the target keeps its own prerequisites and
+// included and excluded users.
+func promotedConfig(src, dst *model.FlagEnvironmentConfig) *model.FlagEnvironmentConfig {
+	promoted := *dst
+	promoted.Enabled = src.Enabled
+	promoted.DefaultVariant = src.DefaultVariant
+	promoted.Variants = src.Variants
+	promoted.TargetingRules = src.TargetingRules
+	promoted.DefaultRollout = src.DefaultRollout
+	return &promoted
+}
+
+// PromotionDiff handles GET /api/v1/projects/{key}/flags/{flag}/environments/{from}/diff/{to}
+// It previews what promoting {from} to {to} would change in {to}'s config,
+// in the same shape as the diff recorded with config updates.
+func (h *FlagHandler) PromotionDiff(w http.ResponseWriter, r *http.Request) {
+	_, _, _, src, dst, ok := h.promotionEnvs(w, r)
+	if !ok {
+		return
+	}
+
+	diff := model.DiffEnvironmentConfigs(dst, promotedConfig(src, dst))
+	encoded, _ := json.Marshal(diff)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":      r.PathValue("from"),
+		"to":        r.PathValue("to"),
+		"diff":      diff,
+		"identical": string(encoded) == "{}",
+	})
+}
+
+// Promote handles POST /api/v1/projects/{key}/flags/{flag}/promote/{from}/to/{to}
+// It copies {from}'s enabled state, default variant, variants, targeting
+// rules and default rollout to {to}, keeping {to}'s prerequisites and user
+// lists. The target's rollout stage and
+// approval rules apply exactly as for a direct update.
+func (h *FlagHandler) Promote(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, flag, to, src, dst, ok := h.promotionEnvs(w, r)
+	if !ok {
+		return
+	}
+
+	promoted := promotedConfig(src, dst)
+	variants, _ := json.Marshal(promoted.Variants)
+	rules, _ := json.Marshal(promoted.TargetingRules)
+	prereqs, _ := json.Marshal(nonNilPrerequisites(promoted.Prerequisites))
+	rollout, _ := json.Marshal(promoted.DefaultRollout)
+	proposed := model.ProposedConfig{
+		Enabled:         promoted.Enabled,
+		DefaultVariant:  promoted.DefaultVariant,
+		Variants:        variants,
+		TargetingRules:  rules,
+		Prerequisites:   prereqs,
+		DefaultRollout:  rollout,
+		IncludedUsers:   promoted.IncludedUsers,
+		ExcludedUsers:   promoted.ExcludedUsers,
+		IncludedVariant: promoted.IncludedVariant,
+	}
+
+	if stageKey, stagePct, ok := h.previousStageRollout(r.Context(), flag, to.Key); ok {
+		if pct := rolloutPercentage(promoted); pct > stagePct {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"error":                    fmt.Sprintf("rollout of %d%% exceeds the %d%% currently rolled out in %s", pct, stagePct, stageKey),
+				"stage_environment":        stageKey,
+				"stage_rollout_percentage": stagePct,
+			})
+			return
+		}
+	}
+
+	if h.changes != nil {
+		settings, err := h.settings.Get(r.Context(), project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load project settings")
+			return
+		}
+		if settings.RequiresApproval(to.Key) {
+			h.requestChange(w, r, project, flag, to, proposed)
+			return
+		}
+	}
+
+	cfg, err := h.applyEnvironmentConfig(r, project, flag, to, proposed, r.PathValue("from"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update environment config")
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func nonNilPrerequisites(p []model.Prerequisite) []model.Prerequisite {
+	if p == nil {
+		return []model.Prerequisite{}
+	}
+	return p
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+const (
+	// defaultStatsWindowHours is the stats window when ?hours= is omitted.
+	defaultStatsWindowHours = 24
+	// maxStatsWindowHours caps the stats window at 90 days.
+	maxStatsWindowHours = 90 * 24
+)
+
+// FlagStatsHandler serves how often each variant of a flag was served.
+type FlagStatsHandler struct {
+	variants *store.VariantEvaluationStore
+	flags    *store.FlagStore
+	projects *store.ProjectStore
+}
+
+// NewFlagStatsHandler creates a new FlagStatsHandler.
+func NewFlagStatsHandler(variants *store.VariantEvaluationStore, flags *store.FlagStore, projects *store.ProjectStore) *FlagStatsHandler {
+	return &FlagStatsHandler{variants: variants, flags: flags, projects: projects}
+}
+
+// Get handles GET /api/v1/projects/{key}/flags/{flag}/stats?hours=24
+// It returns the evaluation count of each variant per environment over the
+// last ?hours= hours (default 24, at most 90 days), counted in whole hours,
+// so the current hour is included. Counts are flushed periodically and may
+// lag by a few seconds.
+func (h *FlagStatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	hours := defaultStatsWindowHours
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxStatsWindowHours {
+			writeError(w, http.StatusBadRequest, "hours must be an integer between 1 and 2160")
+			return
+		}
+		hours = n
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	since := time.Now().UTC().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	counts, err := h.variants.CountsByFlag(r.Context(), flag.ID, since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load flag stats")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"flag":         flag.Key,
+		"hours":        hours,
+		"since":        since,
+		"environments": counts,
+	})
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// ToggleAll handles POST /api/v1/projects/{key}/flags/{flag}/toggle-all
+// Body: {"enabled": bool}. It turns a flag on or off in every environment at
+// once, e.g. to flip a kill switch during an incident. Targeting rules and
+// variants are left as they are. Approval-gated environments reject the
+// request, as does enabling a flag whose rollout stages would then be out of
+// order.
+func (h *FlagHandler) ToggleAll(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	flagKey := r.PathValue("flag")
+	if projectKey == "" || flagKey == "" {
+		writeError(w, http.StatusBadRequest, "project key and flag key are required")
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := readJSON(r, &req); err != nil || req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+	enabled := *req.Enabled
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, flagKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return
+	}
+
+	envs, err := h.environments.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list environments")
+		return
+	}
+
+	if h.changes != nil {
+		settings, err := h.settings.Get(r.Context(), project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load project settings")
+			return
+		}
+		gated := []string{}
+		for _, env := range envs {
+			if settings.RequiresApproval(env.Key) {
+				gated = append(gated, env.Key)
+			}
+		}
+		if len(gated) > 0 {
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"error":        "some environments require approval; update them individually",
+				"environments": gated,
+			})
+			return
+		}
+	}
+
+	// Turning a flag off can't get a later stage ahead of an earlier one.
+	if enabled && len(flag.RolloutStages) > 1 {
+		configs, err := h.flags.GetAllEnvironmentConfigs(r.Context(), flag.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get environment configs")
+			return
+		}
+		byEnv := make(map[string]model.FlagEnvironmentConfig, len(configs))
+		for _, cfg := range configs {
+			byEnv[cfg.EnvironmentID] = cfg
+		}
+		pcts := make(map[string]int, len(envs))
+		for _, env := range envs {
+			cfg := byEnv[env.ID]
+			cfg.Enabled = true
+			pcts[env.Key] = rolloutPercentage(&cfg)
+		}
+		for i := 1; i < len(flag.RolloutStages); i++ {
+			prev, stage := flag.RolloutStages[i-1], flag.RolloutStages[i]
+			if pcts[stage] > pcts[prev] {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+					"error":                    fmt.Sprintf("enabling would roll out %d%% in %s, more than the %d%% in %s", pcts[stage], stage, pcts[prev], prev),
+					"stage_environment":        prev,
+					"stage_rollout_percentage": pcts[prev],
+				})
+				return
+			}
+		}
+	}
+
+	changed, err := h.flags.SetEnabledAllEnvironments(r.Context(), flag.ID, enabled, modifiedBy(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update environment configs")
+		return
+	}
+	envKeys := make(map[string]string, len(envs))
+	for _, env := range envs {
+		envKeys[env.ID] = env.Key
+	}
+	affected := make([]string, 0, len(changed))
+	for _, cfg := range changed {
+		affected = append(affected, envKeys[cfg.EnvironmentID])
+	}
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil && len(affected) > 0 {
+		newVal, _ := json.Marshal(map[string]any{"enabled": enabled, "environments": affected})
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &project.ID,
+			UserID:     &user.ID,
+			Action:     "toggle_all",
+			EntityType: "flag_config",
+			EntityID:   flag.Key,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	h.refreshAllEnvironments(r.Context(), projectKey, project.ID, flagKey, stream.Event{
+		Type:  "flag_update",
+		Value: enabled,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":      enabled,
+		"environments": affected,
+	})
+}
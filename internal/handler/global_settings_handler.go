@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/ratelimit"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// GlobalSettingsHandler serves the admin endpoints for instance-wide
+// settings and applies changes to the running limiters and validators.
+type GlobalSettingsHandler struct {
+	settings    *store.GlobalSettingsStore
+	audit       *store.AuditStore
+	authLimiter *ratelimit.Limiter
+}
+
+// NewGlobalSettingsHandler creates a new GlobalSettingsHandler. authLimiter
+// may be nil, in which case the auth rate limit setting is stored but not applied.
+func NewGlobalSettingsHandler(settings *store.GlobalSettingsStore, audit *store.AuditStore, authLimiter *ratelimit.Limiter) *GlobalSettingsHandler {
+	return &GlobalSettingsHandler{settings: settings, audit: audit, authLimiter: authLimiter}
+}
+
+// Load reads the saved settings and applies them. Call it once at startup.
+func (h *GlobalSettingsHandler) Load(ctx context.Context) error {
+	gs, err := h.settings.Get(ctx)
+	if err != nil {
+		return err
+	}
+	h.apply(gs)
+	return nil
+}
+
+// apply pushes settings into the components that hold them in memory. The
+// staleness checker reads settings from the store on each tick instead.
+func (h *GlobalSettingsHandler) apply(gs *model.GlobalSettings) {
+	applyValidationLimits(gs)
+	if h.authLimiter != nil {
+		h.authLimiter.SetLimit(gs.AuthRateLimit())
+	}
+}
+
+// Get handles GET /api/v1/admin/settings
+// Unset settings are reported with their effective default under "effective".
+func (h *GlobalSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	gs, err := h.settings.Get(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get global settings")
+		return
+	}
+	writeJSON(w, http.StatusOK, globalSettingsResponse(gs))
+}
+
+// Update handles PUT /api/v1/admin/settings
+// Only keys present in the body change; a key set to null resets it to the
+// built-in default.
+func (h *GlobalSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var raw map[string]json.RawMessage
+	if err := readJSON(r, &raw); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	patch := make(map[string]any, len(raw))
+	var problems []validationProblem
+	for key, value := range raw {
+		switch key {
+		case "flag_lifetimes":
+			var lifetimes map[model.FlagType]*int
+			if err := json.Unmarshal(value, &lifetimes); err != nil {
+				problems = append(problems, validationProblem{Path: key, Message: "must be an object of flag type to days"})
+				continue
+			}
+			for ft, days := range lifetimes {
+				if !model.ValidFlagTypes[ft] {
+					problems = append(problems, validationProblem{Path: key + "." + string(ft), Message: "unknown flag type"})
+				} else if days != nil && *days <= 0 {
+					problems = append(problems, validationProblem{Path: key + "." + string(ft), Message: "must be a positive integer or null"})
+				}
+			}
+			patch[key] = lifetimes
+		case "grace_period_days", "auth_rate_limit_per_minute", "max_targeting_rules", "max_conditions_per_rule":
+			var n *int
+			if err := json.Unmarshal(value, &n); err != nil || (n != nil && *n <= 0) {
+				problems = append(problems, validationProblem{Path: key, Message: "must be a positive integer or null"})
+				continue
+			}
+			patch[key] = n
+		default:
+			problems = append(problems, validationProblem{Path: key, Message: "unknown setting"})
+		}
+	}
+	if len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid settings",
+			"problems": problems,
+		})
+		return
+	}
+
+	old, err := h.settings.Get(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get global settings")
+		return
+	}
+	gs, err := h.settings.Update(r.Context(), patch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update global settings")
+		return
+	}
+	h.apply(gs)
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		oldVal, _ := json.Marshal(old)
+		newVal, _ := json.Marshal(gs)
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			UserID:     &user.ID,
+			Action:     "update",
+			EntityType: "global_settings",
+			EntityID:   "global",
+			OldValue:   oldVal,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, globalSettingsResponse(gs))
+}
+
+func globalSettingsResponse(gs *model.GlobalSettings) map[string]any {
+	lifetimes := make(map[model.FlagType]*int)
+	for ft := range model.DefaultFlagLifetimes() {
+		lifetimes[ft] = gs.Lifetime(ft)
+	}
+	return map[string]any{
+		"settings": gs,
+		"effective": map[string]any{
+			"flag_lifetimes":             lifetimes,
+			"grace_period_days":          int(gs.GracePeriod().Hours() / 24),
+			"auth_rate_limit_per_minute": gs.AuthRateLimit(),
+			"max_targeting_rules":        gs.TargetingRuleLimit(),
+			"max_conditions_per_rule":    gs.ConditionLimit(),
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/ratelimit"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestGlobalSettingsHandler_UpdateAppliesWithoutRestart(t *testing.T) {
+	pool := testPool(t)
+	limiter := ratelimit.New(model.DefaultAuthRateLimitPerMinute, 60)
+	h := handler.NewGlobalSettingsHandler(store.NewGlobalSettingsStore(pool), store.NewAuditStore(pool), limiter)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	requireAdmin := auth.RequireRole(model.RoleAdmin)
+	_, adminCookie := testSession(t, pool, model.RoleAdmin)
+	_, memberCookie := testSession(t, pool, model.RoleMember)
+
+	update := func(cookie *http.Cookie, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(requireAdmin(http.HandlerFunc(h.Update))).ServeHTTP(rec, req)
+		return rec
+	}
+	t.Cleanup(func() {
+		update(adminCookie, `{"max_conditions_per_rule": null, "auth_rate_limit_per_minute": null}`)
+		pool.Exec(context.Background(), `DELETE FROM global_settings`)
+	})
+
+	if rec := update(memberCookie, `{"max_conditions_per_rule": 1}`); rec.Code != http.StatusForbidden {
+		t.Errorf("member update: expected 403, got %d", rec.Code)
+	}
+	if rec := update(adminCookie, `{"max_conditions_per_rule": 0}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("zero limit: expected 400, got %d", rec.Code)
+	}
+	if rec := update(adminCookie, `{"no_such_setting": 1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown setting: expected 400, got %d", rec.Code)
+	}
+
+	config := `{
+		"default_variant": "light",
+		"variants": [{"key": "light", "value": "light"}, {"key": "dark", "value": "dark"}],
+		"targeting_rules": [{"conditions": [
+			{"attribute": "plan", "operator": "equals", "value": "pro"},
+			{"attribute": "country", "operator": "equals", "value": "DE"}
+		], "variant": "dark"}]
+	}`
+	projectKey := setupFlagEnv(t, pool, "globalsettings")
+	if resp := validateConfig(t, pool, projectKey, config); !resp.Valid {
+		t.Fatalf("expected two conditions to be valid under the default limit, got %+v", resp.Problems)
+	}
+
+	if rec := update(adminCookie, `{"max_conditions_per_rule": 1, "auth_rate_limit_per_minute": 1}`); rec.Code != http.StatusOK {
+		t.Fatalf("Update: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	resp := validateConfig(t, pool, projectKey, config)
+	if resp.Valid || len(resp.Problems) != 1 || resp.Problems[0].Path != "targeting_rules[0].conditions" {
+		t.Errorf("expected the new condition limit to apply immediately, got %+v", resp)
+	}
+
+	// The auth limiter now allows a single request per minute.
+	limited := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	codes := make([]int, 2)
+	for i := range codes {
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		codes[i] = rec.Code
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected the new auth rate limit to apply, got statuses %v", codes)
+	}
+}
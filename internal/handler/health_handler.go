@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/evaluation"
+)
+
+// readyPingTimeout bounds the database ping of a readiness check.
+const readyPingTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	pool  *pgxpool.Pool
+	cache *evaluation.Cache
+}
+
+func NewHealthHandler(pool *pgxpool.Pool, cache *evaluation.Cache) *HealthHandler {
+	return &HealthHandler{pool: pool, cache: cache}
+}
+
+// Ready handles GET /readyz
+// It pings the database and reports the flag cache state, returning 503 when
+// the database is unreachable or flags have never been loaded, so load
+// balancers stop routing to the instance. GET /healthz stays a cheap
+// liveness check.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyPingTimeout)
+	defer cancel()
+
+	status, database := http.StatusOK, "ok"
+	if err := h.pool.Ping(ctx); err != nil {
+		slog.Warn("readiness check: database ping failed", "error", err)
+		status, database = http.StatusServiceUnavailable, "unreachable"
+	}
+	stats := h.cache.Stats()
+	if stats.LoadedAt == nil {
+		status = http.StatusServiceUnavailable
+	}
+
+	state := "ready"
+	if status != http.StatusOK {
+		state = "unavailable"
+	}
+	writeJSON(w, status, map[string]any{
+		"status":   state,
+		"database": database,
+		"cache":    stats,
+	})
+}
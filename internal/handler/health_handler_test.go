@@ -0,0 +1,43 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+)
+
+func TestHealthHandler_Ready_ClosedPool(t *testing.T) {
+	// pgxpool connects lazily, so this needs no database.
+	pool, err := pgxpool.New(context.Background(), "postgres://togglerino@127.0.0.1:1/togglerino?sslmode=disable")
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	pool.Close()
+
+	cache := evaluation.NewCache()
+	h := handler.NewHealthHandler(pool, cache)
+
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Status   string                `json:"status"`
+		Database string                `json:"database"`
+		Cache    evaluation.CacheStats `json:"cache"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Status != "unavailable" || resp.Database != "unreachable" {
+		t.Errorf("expected an unavailable status with an unreachable database, got %+v", resp)
+	}
+}
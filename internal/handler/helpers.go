@@ -3,6 +3,9 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
 )
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -19,3 +22,24 @@ func readJSON(r *http.Request, v any) error {
 func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
+
+// requireProjectRole writes a 403 and returns false unless the caller's role
+// in the request's project is at least min. It fails closed: a route mounted
+// without auth.ProjectRoleAuth has no role and is always rejected.
+func requireProjectRole(w http.ResponseWriter, r *http.Request, min model.ProjectRole) bool {
+	role, ok := auth.ProjectRoleFromContext(r.Context())
+	if ok && role.AtLeast(min) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, "requires project role "+string(min))
+	return false
+}
+
+// modifiedBy returns the ID of the user making the request, recorded as a
+// flag's last editor, or empty if the request has no user.
+func modifiedBy(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.ID
+	}
+	return ""
+}
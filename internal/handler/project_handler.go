@@ -12,21 +12,38 @@ import (
 )
 
 type ProjectHandler struct {
-	projects     *store.ProjectStore
-	environments *store.EnvironmentStore
-	audit        *store.AuditStore
+	projects            *store.ProjectStore
+	environments        *store.EnvironmentStore
+	audit               *store.AuditStore
+	defaultEnvironments []model.EnvironmentTemplate
+	memberships         *store.ProjectMembershipStore
 }
 
 func NewProjectHandler(projects *store.ProjectStore, environments *store.EnvironmentStore, audit *store.AuditStore) *ProjectHandler {
-	return &ProjectHandler{projects: projects, environments: environments, audit: audit}
+	return &ProjectHandler{projects: projects, environments: environments, audit: audit, defaultEnvironments: model.DefaultEnvironmentTemplates}
+}
+
+// SetDefaultEnvironments sets the environments created for new projects whose
+// create request doesn't list its own.
+func (h *ProjectHandler) SetDefaultEnvironments(templates []model.EnvironmentTemplate) {
+	h.defaultEnvironments = templates
+}
+
+// SetMemberships makes non-admin creators project admins of the projects
+// they create, so project-scoped roles don't lock them out.
+func (h *ProjectHandler) SetMemberships(memberships *store.ProjectMembershipStore) {
+	h.memberships = memberships
 }
 
 // Create handles POST /api/v1/projects
+// An optional "environments" list of {"key", "name"} replaces the default
+// environments; a missing name defaults to the key.
 func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Key         string `json:"key"`
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Key          string                      `json:"key"`
+		Name         string                      `json:"name"`
+		Description  string                      `json:"description"`
+		Environments []model.EnvironmentTemplate `json:"environments"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -37,6 +54,20 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	environments := h.defaultEnvironments
+	if req.Environments != nil {
+		for i := range req.Environments {
+			if req.Environments[i].Name == "" {
+				req.Environments[i].Name = req.Environments[i].Key
+			}
+		}
+		if err := model.ValidateEnvironmentTemplates(req.Environments); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		environments = req.Environments
+	}
+
 	project, err := h.projects.Create(r.Context(), req.Key, req.Name, req.Description)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
@@ -47,13 +78,20 @@ func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.environments.CreateDefaultEnvironments(r.Context(), project.ID); err != nil {
+	if err := h.environments.CreateDefaultEnvironments(r.Context(), project.ID, environments); err != nil {
 		// Log but don't fail — the project was created successfully
 		slog.Warn("failed to create default environments", "error", err)
 	}
 
+	user := auth.UserFromContext(r.Context())
+	if h.memberships != nil && user != nil && user.Role != model.RoleAdmin {
+		if _, err := h.memberships.Set(r.Context(), project.ID, user.ID, model.ProjectRoleAdmin); err != nil {
+			slog.Warn("failed to grant project admin to creator", "error", err)
+		}
+	}
+
 	// Best-effort audit logging
-	if user := auth.UserFromContext(r.Context()); user != nil {
+	if user != nil {
 		newVal, _ := json.Marshal(project)
 		if err := h.audit.Record(r.Context(), model.AuditEntry{
 			ProjectID:  &project.ID,
@@ -102,6 +140,9 @@ func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 // Update handles PUT /api/v1/projects/{key}
 func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
 	key := r.PathValue("key")
 	if key == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestProjectHandler_Create_Environments(t *testing.T) {
+	pool := testPool(t)
+	environments := store.NewEnvironmentStore(pool)
+	h := handler.NewProjectHandler(store.NewProjectStore(pool), environments, store.NewAuditStore(pool))
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.Create(rec, req)
+		return rec
+	}
+	envKeys := func(rec *httptest.ResponseRecorder) []string {
+		var project model.Project
+		if err := json.NewDecoder(rec.Body).Decode(&project); err != nil {
+			t.Fatalf("decoding project: %v", err)
+		}
+		envs, err := environments.ListByProject(context.Background(), project.ID)
+		if err != nil {
+			t.Fatalf("ListByProject: %v", err)
+		}
+		var keys []string
+		for _, env := range envs {
+			keys = append(keys, env.Key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	t.Run("default list when unspecified", func(t *testing.T) {
+		rec := create(fmt.Sprintf(`{"key":%q,"name":"Defaults"}`, uniqueKey("projdefault")))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Create: got status %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(envKeys(rec), ","); got != "development,production,staging" {
+			t.Errorf("environments = %s, want development,production,staging", got)
+		}
+	})
+
+	t.Run("configured default", func(t *testing.T) {
+		h.SetDefaultEnvironments([]model.EnvironmentTemplate{{Key: "dev", Name: "Dev"}, {Key: "prod", Name: "Prod"}})
+		defer h.SetDefaultEnvironments(model.DefaultEnvironmentTemplates)
+
+		rec := create(fmt.Sprintf(`{"key":%q,"name":"Configured"}`, uniqueKey("projconfigured")))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Create: got status %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(envKeys(rec), ","); got != "dev,prod" {
+			t.Errorf("environments = %s, want dev,prod", got)
+		}
+	})
+
+	t.Run("custom list in request", func(t *testing.T) {
+		rec := create(fmt.Sprintf(`{"key":%q,"name":"Custom","environments":[{"key":"dev"},{"key":"prod-us","name":"Prod US"},{"key":"prod-eu","name":"Prod EU"}]}`, uniqueKey("projcustom")))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Create: got status %d, body %s", rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(envKeys(rec), ","); got != "dev,prod-eu,prod-us" {
+			t.Errorf("environments = %s, want dev,prod-eu,prod-us", got)
+		}
+	})
+
+	t.Run("invalid keys rejected", func(t *testing.T) {
+		for _, envs := range []string{`[]`, `[{"key":"has space"}]`, `[{"key":"dev"},{"key":"dev"}]`} {
+			rec := create(fmt.Sprintf(`{"key":%q,"name":"Invalid","environments":%s}`, uniqueKey("projinvalid"), envs))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("environments %s: expected 400, got %d", envs, rec.Code)
+			}
+		}
+	})
+}
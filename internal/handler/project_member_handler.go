@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// ProjectMemberHandler manages project-scoped roles.
+type ProjectMemberHandler struct {
+	memberships *store.ProjectMembershipStore
+	projects    *store.ProjectStore
+	users       *store.UserStore
+}
+
+// NewProjectMemberHandler creates a new ProjectMemberHandler.
+func NewProjectMemberHandler(memberships *store.ProjectMembershipStore, projects *store.ProjectStore, users *store.UserStore) *ProjectMemberHandler {
+	return &ProjectMemberHandler{memberships: memberships, projects: projects, users: users}
+}
+
+// List handles GET /api/v1/projects/{key}/members
+func (h *ProjectMemberHandler) List(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	memberships, err := h.memberships.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list members")
+		return
+	}
+	if memberships == nil {
+		memberships = []model.ProjectMembership{}
+	}
+	writeJSON(w, http.StatusOK, memberships)
+}
+
+// Set handles PUT /api/v1/projects/{key}/members/{user}
+// Grants or changes a user's role; requires project admin.
+func (h *ProjectMemberHandler) Set(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	var req struct {
+		Role model.ProjectRole `json:"role"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !req.Role.Valid() {
+		writeError(w, http.StatusBadRequest, "role must be one of viewer, editor, admin")
+		return
+	}
+
+	user, err := h.users.FindByID(r.Context(), r.PathValue("user"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	membership, err := h.memberships.Set(r.Context(), project.ID, user.ID, req.Role)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to set member role")
+		return
+	}
+	writeJSON(w, http.StatusOK, membership)
+}
+
+// Delete handles DELETE /api/v1/projects/{key}/members/{user}
+// The user falls back to viewer; requires project admin.
+func (h *ProjectMemberHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	err = h.memberships.Delete(r.Context(), project.ID, r.PathValue("user"))
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "membership not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+func TestProjectRoles_ViewerCannotEditFlags(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projectKey := setupFlagEnv(t, pool, "projectroles")
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+
+	h := newTestFlagHandler(pool)
+	memberships := store.NewProjectMembershipStore(pool)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	projectRole := auth.ProjectRoleAuth(memberships)
+
+	serve := func(cookie *http.Cookie, method string, fn http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("flag", "theme")
+		req.SetPathValue("env", "production")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(projectRole(fn)).ServeHTTP(rec, req)
+		return rec
+	}
+	const config = `{"enabled": true, "default_variant": "light", "variants": [{"key": "light", "value": "light"}], "targeting_rules": []}`
+
+	viewer, viewerCookie := testSession(t, pool, model.RoleMember)
+	if _, err := memberships.Set(ctx, project.ID, viewer.ID, model.ProjectRoleViewer); err != nil {
+		t.Fatalf("granting viewer: %v", err)
+	}
+	if rec := serve(viewerCookie, http.MethodGet, h.Get, ""); rec.Code != http.StatusOK {
+		t.Errorf("viewer Get: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if rec := serve(viewerCookie, http.MethodPut, h.UpdateEnvironmentConfig, config); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer UpdateEnvironmentConfig: expected %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+
+	// Members without a membership are viewers too
+	_, outsiderCookie := testSession(t, pool, model.RoleMember)
+	if rec := serve(outsiderCookie, http.MethodPut, h.UpdateEnvironmentConfig, config); rec.Code != http.StatusForbidden {
+		t.Errorf("non-member UpdateEnvironmentConfig: expected %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	if _, err := memberships.Set(ctx, project.ID, viewer.ID, model.ProjectRoleEditor); err != nil {
+		t.Fatalf("granting editor: %v", err)
+	}
+	if rec := serve(viewerCookie, http.MethodPut, h.UpdateEnvironmentConfig, config); rec.Code != http.StatusOK {
+		t.Errorf("editor UpdateEnvironmentConfig: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	// Global admins bypass memberships
+	_, adminCookie := testSession(t, pool, model.RoleAdmin)
+	if rec := serve(adminCookie, http.MethodPut, h.UpdateEnvironmentConfig, config); rec.Code != http.StatusOK {
+		t.Errorf("global admin UpdateEnvironmentConfig: expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
+
+func TestProjectMemberHandler_SetRequiresProjectAdmin(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projectKey := setupFlagEnv(t, pool, "projectmembers")
+	project, err := store.NewProjectStore(pool).FindByKey(ctx, projectKey)
+	if err != nil {
+		t.Fatalf("finding project: %v", err)
+	}
+
+	memberships := store.NewProjectMembershipStore(pool)
+	h := handler.NewProjectMemberHandler(memberships, store.NewProjectStore(pool), store.NewUserStore(pool))
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	projectRole := auth.ProjectRoleAuth(memberships)
+
+	target, _ := testSession(t, pool, model.RoleMember)
+	set := func(cookie *http.Cookie, role string) int {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "`+role+`"}`))
+		req.SetPathValue("key", projectKey)
+		req.SetPathValue("user", target.ID)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(projectRole(http.HandlerFunc(h.Set))).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	editor, editorCookie := testSession(t, pool, model.RoleMember)
+	if _, err := memberships.Set(ctx, project.ID, editor.ID, model.ProjectRoleEditor); err != nil {
+		t.Fatalf("granting editor: %v", err)
+	}
+	if code := set(editorCookie, "editor"); code != http.StatusForbidden {
+		t.Errorf("editor granting roles: expected %d, got %d", http.StatusForbidden, code)
+	}
+
+	projectAdmin, adminCookie := testSession(t, pool, model.RoleMember)
+	if _, err := memberships.Set(ctx, project.ID, projectAdmin.ID, model.ProjectRoleAdmin); err != nil {
+		t.Fatalf("granting admin: %v", err)
+	}
+	if code := set(adminCookie, "owner"); code != http.StatusBadRequest {
+		t.Errorf("invalid role: expected %d, got %d", http.StatusBadRequest, code)
+	}
+	if code := set(adminCookie, "editor"); code != http.StatusOK {
+		t.Fatalf("project admin granting editor: expected %d, got %d", http.StatusOK, code)
+	}
+	if role, err := memberships.RoleFor(ctx, target.ID, projectKey); err != nil || role != model.ProjectRoleEditor {
+		t.Errorf("expected target to be editor, got %q, %v", role, err)
+	}
+}
+
+func TestProjectRoles_SegmentWritesNeedEditor(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	project, err := projects.Create(ctx, uniqueKey("segmentroles"), "Segment Roles", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+
+	h := handler.NewSegmentHandler(store.NewSegmentStore(pool), projects, store.NewEnvironmentStore(pool), store.NewAuditStore(pool), stream.NewHub(), evaluation.NewCache(), pool)
+	memberships := store.NewProjectMembershipStore(pool)
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	projectRole := auth.ProjectRoleAuth(memberships)
+
+	serve := func(cookie *http.Cookie, next http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{
+			"key": "pro-users",
+			"name": "Pro users",
+			"conditions": [{"attribute": "plan", "operator": "equals", "value": "pro"}]
+		}`))
+		req.SetPathValue("key", project.Key)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(next).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Logged-in users without a membership are viewers
+	_, outsiderCookie := testSession(t, pool, model.RoleMember)
+	if rec := serve(outsiderCookie, projectRole(http.HandlerFunc(h.Create))); rec.Code != http.StatusForbidden {
+		t.Errorf("non-member Create: expected %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+
+	// Without ProjectRoleAuth there is no role, which fails closed even for admins
+	_, adminCookie := testSession(t, pool, model.RoleAdmin)
+	if rec := serve(adminCookie, http.HandlerFunc(h.Create)); rec.Code != http.StatusForbidden {
+		t.Errorf("unscoped route Create: expected %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+
+	editor, editorCookie := testSession(t, pool, model.RoleMember)
+	if _, err := memberships.Set(ctx, project.ID, editor.ID, model.ProjectRoleEditor); err != nil {
+		t.Fatalf("granting editor: %v", err)
+	}
+	if rec := serve(editorCookie, projectRole(http.HandlerFunc(h.Create))); rec.Code != http.StatusCreated {
+		t.Errorf("editor Create: expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"slices"
 
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
@@ -10,10 +15,12 @@ import (
 type ProjectSettingsHandler struct {
 	settings *store.ProjectSettingsStore
 	projects *store.ProjectStore
+	audit    *store.AuditStore
+	cache    *evaluation.Cache
 }
 
-func NewProjectSettingsHandler(settings *store.ProjectSettingsStore, projects *store.ProjectStore) *ProjectSettingsHandler {
-	return &ProjectSettingsHandler{settings: settings, projects: projects}
+func NewProjectSettingsHandler(settings *store.ProjectSettingsStore, projects *store.ProjectStore, audit *store.AuditStore, cache *evaluation.Cache) *ProjectSettingsHandler {
+	return &ProjectSettingsHandler{settings: settings, projects: projects, audit: audit, cache: cache}
 }
 
 // Get handles GET /api/v1/projects/{key}/settings/flags
@@ -45,12 +52,19 @@ func (h *ProjectSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"flag_lifetimes": merged,
+		"flag_lifetimes":                merged,
+		"case_insensitive_flag_keys":    settings != nil && settings.CaseInsensitiveFlagKeys,
+		"require_approval_environments": requireApprovalEnvironments(settings),
+		"audit_retention_days":          auditRetentionDays(settings),
 	})
 }
 
-// Update handles PUT /api/v1/projects/{key}/settings/flags
+// Update handles PUT /api/v1/projects/{key}/settings/flags. It requires
+// project admin since the settings include the approval gate on flag changes.
 func (h *ProjectSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -64,7 +78,12 @@ func (h *ProjectSettingsHandler) Update(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		FlagLifetimes map[model.FlagType]*int `json:"flag_lifetimes"`
+		FlagLifetimes               map[model.FlagType]*int `json:"flag_lifetimes"`
+		CaseInsensitiveFlagKeys     *bool                   `json:"case_insensitive_flag_keys"`
+		RequireApprovalEnvironments *[]string               `json:"require_approval_environments"`
+		// AuditRetentionDays is kept raw so null (revert to the instance
+		// default) can be told apart from an absent field.
+		AuditRetentionDays json.RawMessage `json:"audit_retention_days"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -82,13 +101,97 @@ func (h *ProjectSettingsHandler) Update(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	settings, err := h.settings.Upsert(r.Context(), project.ID, req.FlagLifetimes)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to update project settings")
-		return
+	var retentionDays *int
+	if len(req.AuditRetentionDays) > 0 && string(req.AuditRetentionDays) != "null" {
+		if err := json.Unmarshal(req.AuditRetentionDays, &retentionDays); err != nil || *retentionDays < 0 {
+			writeError(w, http.StatusBadRequest, "audit_retention_days must be a non-negative integer or null")
+			return
+		}
+	}
+
+	var settings *model.ProjectSettings
+	// Requests that only change other settings leave lifetimes untouched.
+	if req.FlagLifetimes != nil || (req.CaseInsensitiveFlagKeys == nil && req.RequireApprovalEnvironments == nil && req.AuditRetentionDays == nil) {
+		settings, err = h.settings.Upsert(r.Context(), project.ID, req.FlagLifetimes)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update project settings")
+			return
+		}
+	}
+	if req.CaseInsensitiveFlagKeys != nil {
+		settings, err = h.settings.SetCaseInsensitiveFlagKeys(r.Context(), project.ID, *req.CaseInsensitiveFlagKeys)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update project settings")
+			return
+		}
+		h.cache.SetCaseInsensitiveKeys(project.Key, settings.CaseInsensitiveFlagKeys)
+	}
+	if req.RequireApprovalEnvironments != nil {
+		old, err := h.settings.Get(r.Context(), project.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get project settings")
+			return
+		}
+		settings, err = h.settings.SetRequireApprovalEnvironments(r.Context(), project.ID, *req.RequireApprovalEnvironments)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update project settings")
+			return
+		}
+		h.recordApprovalChange(r, project, requireApprovalEnvironments(old), requireApprovalEnvironments(settings))
+	}
+
+	if req.AuditRetentionDays != nil {
+		settings, err = h.settings.SetAuditRetentionDays(r.Context(), project.ID, retentionDays)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to update project settings")
+			return
+		}
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"flag_lifetimes": settings.FlagLifetimes,
+		"flag_lifetimes":                settings.FlagLifetimes,
+		"case_insensitive_flag_keys":    settings.CaseInsensitiveFlagKeys,
+		"require_approval_environments": requireApprovalEnvironments(settings),
+		"audit_retention_days":          auditRetentionDays(settings),
 	})
 }
+
+// recordApprovalChange audits a change to the approval-gated environments.
+// Best-effort: failures are logged, not returned.
+func (h *ProjectSettingsHandler) recordApprovalChange(r *http.Request, project *model.Project, oldEnvs, newEnvs []string) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil || slices.Equal(oldEnvs, newEnvs) {
+		return
+	}
+	oldVal, _ := json.Marshal(map[string]any{"require_approval_environments": oldEnvs})
+	newVal, _ := json.Marshal(map[string]any{"require_approval_environments": newEnvs})
+	if err := h.audit.Record(r.Context(), model.AuditEntry{
+		ProjectID:  &project.ID,
+		UserID:     &user.ID,
+		Action:     "update",
+		EntityType: "project_settings",
+		EntityID:   project.Key,
+		OldValue:   oldVal,
+		NewValue:   newVal,
+	}); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
+
+// requireApprovalEnvironments returns the approval-gated environment keys,
+// never nil so the response always carries a list.
+func requireApprovalEnvironments(settings *model.ProjectSettings) []string {
+	if settings == nil || settings.RequireApprovalEnvironments == nil {
+		return []string{}
+	}
+	return settings.RequireApprovalEnvironments
+}
+
+// auditRetentionDays returns the project's audit retention override, nil when
+// the instance-wide default applies.
+func auditRetentionDays(settings *model.ProjectSettings) *int {
+	if settings == nil {
+		return nil
+	}
+	return settings.AuditRetentionDays
+}
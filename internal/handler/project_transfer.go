@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// ExportProject handles GET /api/v1/projects/{key}/export
+// It returns the project, its environments and every flag with its config in
+// each environment as a document that ImportProject accepts, so a project can
+// be moved between instances.
+func (h *FlagHandler) ExportProject(w http.ResponseWriter, r *http.Request) {
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	envs, err := h.environments.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list environments")
+		return
+	}
+	flags, _, err := h.flags.ListByProject(r.Context(), project.ID, "", "", "", "", "", 0, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list flags")
+		return
+	}
+	slices.SortFunc(flags, func(a, b model.Flag) int { return strings.Compare(a.Key, b.Key) })
+
+	doc := model.ProjectExport{
+		Version: model.ProjectExportVersion,
+		Project: model.ExportedProject{
+			Key:         project.Key,
+			Name:        project.Name,
+			Description: project.Description,
+		},
+		Environments: make([]model.EnvironmentTemplate, 0, len(envs)),
+		Flags:        make([]model.ExportedFlag, 0, len(flags)),
+	}
+	envKeys := make(map[string]string, len(envs))
+	for _, env := range envs {
+		envKeys[env.ID] = env.Key
+		doc.Environments = append(doc.Environments, model.EnvironmentTemplate{Key: env.Key, Name: env.Name})
+	}
+	for _, f := range flags {
+		configs, err := h.flags.GetAllEnvironmentConfigs(r.Context(), f.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get environment configs")
+			return
+		}
+		exported := model.ExportedFlag{
+			Key:               f.Key,
+			Name:              f.Name,
+			Description:       f.Description,
+			ValueType:         f.ValueType,
+			FlagType:          f.FlagType,
+			DefaultValue:      f.DefaultValue,
+			Tags:              nonNilStrings(f.Tags),
+			RolloutStages:     nonNilStrings(f.RolloutStages),
+			PollTTLSeconds:    f.PollTTLSeconds,
+			RequireIdentifier: f.RequireIdentifier,
+			JSONSchema:        f.JSONSchema,
+			Environments:      make(map[string]model.ExportedFlagConfig, len(configs)),
+		}
+		for _, cfg := range configs {
+			exported.Environments[envKeys[cfg.EnvironmentID]] = model.ExportedFlagConfig{
+				Enabled:         cfg.Enabled,
+				DefaultVariant:  cfg.DefaultVariant,
+				Variants:        cfg.Variants,
+				TargetingRules:  cfg.TargetingRules,
+				Prerequisites:   cfg.Prerequisites,
+				DefaultRollout:  cfg.DefaultRollout,
+				IncludedUsers:   cfg.IncludedUsers,
+				ExcludedUsers:   cfg.ExcludedUsers,
+				IncludedVariant: cfg.IncludedVariant,
+			}
+		}
+		doc.Flags = append(doc.Flags, exported)
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export.json"`, project.Key))
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// ImportProject handles POST /api/v1/projects/import?overwrite=true
+// Body: a document from ExportProject. It recreates the project, its
+// environments and flags in one transaction. An existing project with the
+// same key returns 409 unless overwrite is set, which replaces the listed
+// environments, flags and configs (project admin only) and keeps the rest.
+func (h *FlagHandler) ImportProject(w http.ResponseWriter, r *http.Request) {
+	var doc model.ProjectExport
+	if err := readJSON(r, &doc); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+
+	if problems := validateProjectImport(&doc); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid project export",
+			"problems": problems,
+		})
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	existing, err := h.projects.FindByKey(r.Context(), doc.Project.Key)
+	if err == nil && overwrite && h.memberships != nil && user != nil {
+		role, err := auth.ResolveProjectRole(r.Context(), h.memberships, user, existing.Key)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load project role")
+			return
+		}
+		if !role.AtLeast(model.ProjectRoleAdmin) {
+			writeError(w, http.StatusForbidden, "requires project role admin to overwrite an existing project")
+			return
+		}
+	}
+
+	project, err := h.projects.Import(r.Context(), &doc, overwrite)
+	if err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			writeError(w, http.StatusConflict, "project key already exists; set overwrite=true to replace it")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to import project")
+		return
+	}
+
+	created := existing == nil
+	if created && h.memberships != nil && user != nil && user.Role != model.RoleAdmin {
+		if _, err := h.memberships.Set(r.Context(), project.ID, user.ID, model.ProjectRoleAdmin); err != nil {
+			slog.Warn("failed to grant project admin to importer", "error", err)
+		}
+	}
+
+	// Best-effort audit logging
+	if user != nil {
+		newVal, _ := json.Marshal(map[string]any{
+			"environments": len(doc.Environments),
+			"flags":        len(doc.Flags),
+			"overwrite":    overwrite,
+		})
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &project.ID,
+			UserID:     &user.ID,
+			Action:     "import",
+			EntityType: "project",
+			EntityID:   project.Key,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	imported := make(map[string]bool, len(doc.Flags))
+	for _, f := range doc.Flags {
+		imported[f.Key] = true
+	}
+	flags, _, err := h.flags.ListByProject(r.Context(), project.ID, "", "", "", "", "", 0, 0)
+	if err != nil {
+		slog.Warn("failed to list imported flags for cache refresh", "error", err)
+	}
+	h.refreshAndBroadcast(r, project.Key, project.ID, slices.DeleteFunc(flags, func(f model.Flag) bool { return !imported[f.Key] }))
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, map[string]any{
+		"project":      project,
+		"environments": len(doc.Environments),
+		"flags":        len(doc.Flags),
+	})
+}
+
+// validateProjectImport checks an import document with the same rules as
+// creating its project, flags and configs through the API, filling in the
+// same defaults, and returns every problem found.
+func validateProjectImport(doc *model.ProjectExport) []validationProblem {
+	var problems []validationProblem
+	add := func(path, format string, args ...any) {
+		problems = append(problems, validationProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if doc.Version != model.ProjectExportVersion {
+		add("version", "unsupported export version %d", doc.Version)
+	}
+	if doc.Project.Key == "" || doc.Project.Name == "" {
+		add("project", "key and name are required")
+	}
+	for i := range doc.Environments {
+		if doc.Environments[i].Name == "" {
+			doc.Environments[i].Name = doc.Environments[i].Key
+		}
+	}
+	if err := model.ValidateEnvironmentTemplates(doc.Environments); err != nil {
+		add("environments", "%v", err)
+	}
+	envs := make(map[string]bool, len(doc.Environments))
+	for _, env := range doc.Environments {
+		envs[env.Key] = true
+	}
+
+	seen := make(map[string]bool, len(doc.Flags))
+	for i := range doc.Flags {
+		f := &doc.Flags[i]
+		path := fmt.Sprintf("flags[%d]", i)
+		if f.Key == "" || f.Name == "" {
+			add(path, "key and name are required")
+			continue
+		}
+		if seen[f.Key] {
+			add(path+".key", "duplicate flag key %q", f.Key)
+		}
+		seen[f.Key] = true
+
+		if f.ValueType == "" {
+			f.ValueType = model.ValueTypeBoolean
+		}
+		if f.FlagType == "" {
+			f.FlagType = model.FlagTypeRelease
+		}
+		if !model.ValidValueTypes[f.ValueType] {
+			add(path+".value_type", "must be one of boolean, string, number, json")
+			continue
+		}
+		if !model.ValidFlagTypes[f.FlagType] {
+			add(path+".flag_type", "must be one of release, experiment, operational, kill-switch, permission")
+		}
+		if f.DefaultValue == nil {
+			f.DefaultValue = model.ZeroValue(f.ValueType)
+		}
+		if string(f.JSONSchema) == "null" {
+			f.JSONSchema = nil
+		}
+		if f.JSONSchema != nil {
+			if f.ValueType != model.ValueTypeJSON {
+				add(path+".json_schema", "only allowed for json flags")
+				continue
+			}
+			if err := model.CheckJSONSchema(f.JSONSchema); err != nil {
+				add(path+".json_schema", "%v", err)
+				continue
+			}
+		}
+		if err := model.ValidateFlagValue(f.ValueType, f.JSONSchema, f.DefaultValue); err != nil {
+			add(path+".default_value", "%v", err)
+		}
+		f.Tags = nonNilStrings(f.Tags)
+		f.RolloutStages = nonNilStrings(f.RolloutStages)
+		stages := make(map[string]bool, len(f.RolloutStages))
+		for _, stage := range f.RolloutStages {
+			if !envs[stage] || stages[stage] {
+				add(path+".rollout_stages", "unknown or duplicate environment %q", stage)
+			}
+			stages[stage] = true
+		}
+		if f.PollTTLSeconds != nil && *f.PollTTLSeconds <= 0 {
+			add(path+".poll_ttl_seconds", "must be a positive integer or null")
+		}
+
+		flag := &model.Flag{Key: f.Key, ValueType: f.ValueType, JSONSchema: f.JSONSchema}
+		for envKey, cfg := range f.Environments {
+			cfgPath := fmt.Sprintf("%s.environments[%q]", path, envKey)
+			if !envs[envKey] {
+				add(cfgPath, "environment %q is not in the export", envKey)
+				continue
+			}
+			for _, p := range validateEnvironmentConfig(flag, configCandidate{
+				DefaultVariant:  cfg.DefaultVariant,
+				Variants:        cfg.Variants,
+				TargetingRules:  cfg.TargetingRules,
+				Prerequisites:   cfg.Prerequisites,
+				DefaultRollout:  cfg.DefaultRollout,
+				IncludedUsers:   cfg.IncludedUsers,
+				ExcludedUsers:   cfg.ExcludedUsers,
+				IncludedVariant: cfg.IncludedVariant,
+			}) {
+				add(cfgPath+"."+p.Path, "%s", p.Message)
+			}
+			if cfg.Variants == nil {
+				cfg.Variants = []model.Variant{}
+			}
+			if cfg.TargetingRules == nil {
+				cfg.TargetingRules = []model.TargetingRule{}
+			}
+			if cfg.Prerequisites == nil {
+				cfg.Prerequisites = []model.Prerequisite{}
+			}
+			if cfg.DefaultRollout == nil {
+				cfg.DefaultRollout = []model.VariantWeight{}
+			}
+			if cfg.IncludedUsers == nil {
+				cfg.IncludedUsers = []string{}
+			}
+			if cfg.ExcludedUsers == nil {
+				cfg.ExcludedUsers = []string{}
+			}
+			f.Environments[envKey] = cfg
+		}
+	}
+	return problems
+}
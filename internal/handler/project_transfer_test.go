@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func exportProject(t *testing.T, pool *pgxpool.Pool, h *handler.FlagHandler, projectKey string) model.ProjectExport {
+	t.Helper()
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/"+projectKey+"/export", nil)
+	req.SetPathValue("key", projectKey)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.ExportProject)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var doc model.ProjectExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	return doc
+}
+
+func importProject(t *testing.T, pool *pgxpool.Pool, h *handler.FlagHandler, doc model.ProjectExport, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionAuth := auth.SessionAuth(store.NewSessionStore(pool), store.NewUserStore(pool))
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	body, _ := json.Marshal(doc)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/projects/import"+query, bytes.NewReader(body))
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.ImportProject)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFlagHandler_ExportImport_RoundTrip(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	environments := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("export"), "Export", "round trip")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if err := environments.CreateDefaultEnvironments(ctx, project.ID, []model.EnvironmentTemplate{
+		{Key: "staging", Name: "Staging"},
+		{Key: "production", Name: "Production"},
+	}); err != nil {
+		t.Fatalf("creating environments: %v", err)
+	}
+	flag, err := flags.Create(ctx, project.ID, "checkout", "Checkout", "new flow", model.ValueTypeString, model.FlagTypeExperiment, json.RawMessage(`"old"`), []string{"ui"})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	staging, err := environments.FindByKey(ctx, project.ID, "staging")
+	if err != nil {
+		t.Fatalf("finding staging: %v", err)
+	}
+	if _, err := flags.UpdateEnvironmentConfig(ctx, flag.ID, staging.ID, true, "old",
+		json.RawMessage(`[{"key": "old", "value": "old"}, {"key": "new", "value": "new"}]`),
+		json.RawMessage(`[{"conditions": [{"attribute": "country", "operator": "equals", "value": "DE"}], "variant": "new", "percentage_rollout": 50}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "",
+	); err != nil {
+		t.Fatalf("updating staging config: %v", err)
+	}
+
+	h := newTestFlagHandler(pool)
+	exported := exportProject(t, pool, h, project.Key)
+	if len(exported.Environments) != 2 || len(exported.Flags) != 1 {
+		t.Fatalf("expected 2 environments and 1 flag, got %+v", exported)
+	}
+
+	// Import under a new key, as another instance would.
+	doc := exported
+	doc.Project.Key = uniqueKey("imported")
+	rec := importProject(t, pool, h, doc, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import: expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	reexported := exportProject(t, pool, h, doc.Project.Key)
+	want, _ := json.Marshal(exported.Flags)
+	got, _ := json.Marshal(reexported.Flags)
+	if !bytes.Equal(want, got) {
+		t.Errorf("imported flags differ from exported ones:\nwant %s\ngot  %s", want, got)
+	}
+	if reexported.Environments[0].Key != "staging" || reexported.Environments[1].Key != "production" {
+		t.Errorf("expected environment order to be kept, got %+v", reexported.Environments)
+	}
+
+	if rec := importProject(t, pool, h, doc, ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d for an existing project, got %d: %s", http.StatusConflict, rec.Code, rec.Body.String())
+	}
+	doc.Flags[0].Name = "Checkout v2"
+	if rec := importProject(t, pool, h, doc, "?overwrite=true"); rec.Code != http.StatusOK {
+		t.Fatalf("overwrite: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if again := exportProject(t, pool, h, doc.Project.Key); len(again.Flags) != 1 || again.Flags[0].Name != "Checkout v2" {
+		t.Errorf("expected overwrite to update the flag in place, got %+v", again.Flags)
+	}
+}
+
+func TestFlagHandler_Import_RejectsMismatchedValueTypes(t *testing.T) {
+	pool := testPool(t)
+	h := newTestFlagHandler(pool)
+
+	doc := model.ProjectExport{
+		Version:      model.ProjectExportVersion,
+		Project:      model.ExportedProject{Key: uniqueKey("badimport"), Name: "Bad"},
+		Environments: []model.EnvironmentTemplate{{Key: "production", Name: "Production"}},
+		Flags: []model.ExportedFlag{{
+			Key:          "limit",
+			Name:         "Limit",
+			ValueType:    model.ValueTypeNumber,
+			DefaultValue: json.RawMessage(`10`),
+			Environments: map[string]model.ExportedFlagConfig{
+				"production": {Variants: []model.Variant{{Key: "high", Value: json.RawMessage(`"lots"`)}}},
+			},
+		}},
+	}
+	rec := importProject(t, pool, h, doc, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+	if _, err := store.NewProjectStore(pool).FindByKey(context.Background(), doc.Project.Key); err == nil {
+		t.Error("expected no project to be created for an invalid import")
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// rolloutPercentage returns the largest share of users (0-100) a config can
+// serve a targeted variant to. A disabled config rolls out to nobody, and an
+// enabled config without rules or a default rollout to everyone. A rule counts
+// with its percentage rollout (100 without one), scaled to the weight of its
+// arms other than the default variant when it splits across variants; the
+// default rollout counts with the weight of its non-default variants.
+func rolloutPercentage(cfg *model.FlagEnvironmentConfig) int {
+	if !cfg.Enabled {
+		return 0
+	}
+	if len(cfg.TargetingRules) == 0 && len(cfg.DefaultRollout) == 0 {
+		return 100
+	}
+	highest := targetedWeight(cfg.DefaultRollout, cfg.DefaultVariant)
+	for _, rule := range cfg.TargetingRules {
+		pct := 100
+		if rule.PercentageRollout != nil {
+			pct = *rule.PercentageRollout
+		}
+		if len(rule.VariantWeights) > 0 {
+			pct = pct * targetedWeight(rule.VariantWeights, cfg.DefaultVariant) / 100
+		}
+		highest = max(highest, pct)
+	}
+	return highest
+}
+
+// targetedWeight sums the weights of the arms serving a variant other than
+// defaultVariant.
+func targetedWeight(weights []model.VariantWeight, defaultVariant string) int {
+	total := 0
+	for _, w := range weights {
+		if w.Variant != defaultVariant {
+			total += w.Weight
+		}
+	}
+	return total
+}
+
+// previousStageRollout looks up the stage preceding envKey in the flag's
+// rollout stages and returns its key and current rollout percentage. ok is
+// false when envKey is not a later stage or the previous stage cannot be loaded.
+func (h *FlagHandler) previousStageRollout(ctx context.Context, flag *model.Flag, envKey string) (stageKey string, pct int, ok bool) {
+	idx := slices.Index(flag.RolloutStages, envKey)
+	if idx <= 0 {
+		return "", 0, false
+	}
+	stageKey = flag.RolloutStages[idx-1]
+
+	env, err := h.environments.FindByKey(ctx, flag.ProjectID, stageKey)
+	if err != nil {
+		return "", 0, false
+	}
+	cfg, err := h.flags.GetEnvironmentConfig(ctx, flag.ID, env.ID)
+	if err != nil {
+		return "", 0, false
+	}
+	return stageKey, rolloutPercentage(cfg), true
+}
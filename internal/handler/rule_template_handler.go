@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// RuleTemplateHandler handles CRUD and instantiation of targeting rule templates.
+type RuleTemplateHandler struct {
+	templates *store.RuleTemplateStore
+	projects  *store.ProjectStore
+	audit     *store.AuditStore
+}
+
+// NewRuleTemplateHandler creates a new RuleTemplateHandler.
+func NewRuleTemplateHandler(templates *store.RuleTemplateStore, projects *store.ProjectStore, audit *store.AuditStore) *RuleTemplateHandler {
+	return &RuleTemplateHandler{templates: templates, projects: projects, audit: audit}
+}
+
+// validateRuleTemplate checks a template's parameters and conditions. Every
+// placeholder used in the conditions must be a declared parameter.
+func validateRuleTemplate(parameters []string, conditions []model.Condition) []validationProblem {
+	var problems []validationProblem
+	for i, name := range parameters {
+		if !model.ValidParameterName(name) {
+			problems = append(problems, validationProblem{Path: fmt.Sprintf("parameters[%d]", i), Message: fmt.Sprintf("invalid parameter name %q", name)})
+		} else if slices.Index(parameters, name) != i {
+			problems = append(problems, validationProblem{Path: fmt.Sprintf("parameters[%d]", i), Message: fmt.Sprintf("duplicate parameter %q", name)})
+		}
+	}
+
+	if len(conditions) == 0 {
+		problems = append(problems, validationProblem{Path: "conditions", Message: "at least one condition is required"})
+	} else if len(conditions) > maxConditionsPerRule() {
+		problems = append(problems, validationProblem{Path: "conditions", Message: fmt.Sprintf("at most %d conditions are allowed per rule", maxConditionsPerRule())})
+	}
+	problems = append(problems, validateConditions("conditions", conditions)...)
+
+	t := model.RuleTemplate{Conditions: conditions}
+	for _, name := range t.Placeholders() {
+		if !slices.Contains(parameters, name) {
+			problems = append(problems, validationProblem{Path: "conditions", Message: fmt.Sprintf("placeholder {{%s}} is not a declared parameter", name)})
+		}
+	}
+	return problems
+}
+
+type ruleTemplateRequest struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Parameters  []string          `json:"parameters"`
+	Conditions  []model.Condition `json:"conditions"`
+}
+
+// List handles GET /api/v1/projects/{key}/rule-templates
+func (h *RuleTemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	templates, err := h.templates.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list rule templates")
+		return
+	}
+	if templates == nil {
+		templates = []model.RuleTemplate{}
+	}
+
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// Create handles POST /api/v1/projects/{key}/rule-templates
+// When parameters are omitted they are derived from the placeholders used in the conditions.
+func (h *RuleTemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	var req ruleTemplateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Key == "" || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "key and name are required")
+		return
+	}
+	if req.Parameters == nil {
+		req.Parameters = (&model.RuleTemplate{Conditions: req.Conditions}).Placeholders()
+	}
+	if problems := validateRuleTemplate(req.Parameters, req.Conditions); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid rule template",
+			"problems": problems,
+		})
+		return
+	}
+
+	template, err := h.templates.Create(r.Context(), project.ID, req.Key, req.Name, req.Description, req.Parameters, req.Conditions)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+			writeError(w, http.StatusConflict, "rule template key already exists for this project")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create rule template")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "create", template.Key, nil, template)
+	writeJSON(w, http.StatusCreated, template)
+}
+
+// Get handles GET /api/v1/projects/{key}/rule-templates/{template}
+func (h *RuleTemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, template, ok := h.resolveTemplate(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, template)
+}
+
+// Update handles PUT /api/v1/projects/{key}/rule-templates/{template}
+// Rules already instantiated from the template are not changed.
+func (h *RuleTemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, template, ok := h.resolveTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req ruleTemplateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if req.Parameters == nil {
+		req.Parameters = (&model.RuleTemplate{Conditions: req.Conditions}).Placeholders()
+	}
+	if problems := validateRuleTemplate(req.Parameters, req.Conditions); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid rule template",
+			"problems": problems,
+		})
+		return
+	}
+
+	updated, err := h.templates.Update(r.Context(), template.ID, req.Name, req.Description, req.Parameters, req.Conditions)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update rule template")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "update", template.Key, template, updated)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Delete handles DELETE /api/v1/projects/{key}/rule-templates/{template}
+func (h *RuleTemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, template, ok := h.resolveTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.templates.Delete(r.Context(), template.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete rule template")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "delete", template.Key, template, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Instantiate handles POST /api/v1/projects/{key}/rule-templates/{template}/instantiate
+// Body: {"parameters": {"domain": "acme.com"}, "variant": "on", "percentage_rollout": 50}.
+// It returns a concrete targeting rule with every placeholder substituted, ready
+// to be saved in an environment config; the rule keeps no link to the template.
+func (h *RuleTemplateHandler) Instantiate(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	_, template, ok := h.resolveTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Parameters        map[string]string `json:"parameters"`
+		Variant           string            `json:"variant"`
+		PercentageRollout *int              `json:"percentage_rollout"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Variant == "" {
+		writeError(w, http.StatusBadRequest, "variant is required")
+		return
+	}
+	if req.PercentageRollout != nil && (*req.PercentageRollout < 0 || *req.PercentageRollout > 100) {
+		writeError(w, http.StatusBadRequest, "percentage_rollout must be between 0 and 100")
+		return
+	}
+
+	conditions, err := template.Instantiate(req.Parameters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Substituted values can still produce invalid conditions, e.g. a bad regex.
+	if problems := validateConditions("conditions", conditions); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "instantiated rule is invalid",
+			"problems": problems,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"rule": model.TargetingRule{
+			Conditions:        conditions,
+			Variant:           req.Variant,
+			PercentageRollout: req.PercentageRollout,
+		},
+	})
+}
+
+// resolveTemplate looks up the project and template named in the path,
+// writing a 404 if either is missing.
+func (h *RuleTemplateHandler) resolveTemplate(w http.ResponseWriter, r *http.Request) (*model.Project, *model.RuleTemplate, bool) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, false
+	}
+	template, err := h.templates.FindByKey(r.Context(), project.ID, r.PathValue("template"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "rule template not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to load rule template")
+		}
+		return nil, nil, false
+	}
+	return project, template, true
+}
+
+// recordAudit records a best-effort audit entry for a template change.
+func (h *RuleTemplateHandler) recordAudit(r *http.Request, projectID, action, key string, oldTemplate, newTemplate *model.RuleTemplate) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return
+	}
+	entry := model.AuditEntry{
+		ProjectID:  &projectID,
+		UserID:     &user.ID,
+		Action:     action,
+		EntityType: "rule_template",
+		EntityID:   key,
+	}
+	if oldTemplate != nil {
+		entry.OldValue, _ = json.Marshal(oldTemplate)
+	}
+	if newTemplate != nil {
+		entry.NewValue, _ = json.Marshal(newTemplate)
+	}
+	if err := h.audit.Record(r.Context(), entry); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
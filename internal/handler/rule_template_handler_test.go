@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestRuleTemplateHandler_CreateAndInstantiate(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	h := handler.NewRuleTemplateHandler(store.NewRuleTemplateStore(pool), projects, store.NewAuditStore(pool))
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	ctx := context.Background()
+
+	project, err := projects.Create(ctx, uniqueKey("ruletemplate"), "Rule Template", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	do := func(fn http.HandlerFunc, body, templateKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("template", templateKey)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(h.Create, `{
+		"key": "internal-users",
+		"name": "Internal users",
+		"conditions": [
+			{"attribute": "email", "operator": "ends_with", "value": "@{{domain}}"},
+			{"attribute": "org", "operator": "in", "value": ["{{domain}}", "staff.{{domain}}"]}
+		]
+	}`, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var created model.RuleTemplate
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding template: %v", err)
+	}
+	if len(created.Parameters) != 1 || created.Parameters[0] != "domain" {
+		t.Errorf("expected parameters derived as [domain], got %v", created.Parameters)
+	}
+
+	rec = do(h.Instantiate, `{"parameters": {"domain": "acme.com"}, "variant": "on", "percentage_rollout": 50}`, "internal-users")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Instantiate: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Rule model.TargetingRule `json:"rule"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding rule: %v", err)
+	}
+
+	if resp.Rule.Variant != "on" || resp.Rule.PercentageRollout == nil || *resp.Rule.PercentageRollout != 50 {
+		t.Errorf("unexpected rule variant/rollout: %+v", resp.Rule)
+	}
+	if len(resp.Rule.Conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(resp.Rule.Conditions))
+	}
+	first := resp.Rule.Conditions[0]
+	if first.Attribute != "email" || first.Operator != "ends_with" || first.Value != "@acme.com" {
+		t.Errorf("unexpected first condition: %+v", first)
+	}
+	list, ok := resp.Rule.Conditions[1].Value.([]any)
+	if !ok || len(list) != 2 || list[0] != "acme.com" || list[1] != "staff.acme.com" {
+		t.Errorf("unexpected second condition value: %#v", resp.Rule.Conditions[1].Value)
+	}
+}
+
+func TestRuleTemplateHandler_Instantiate_MissingParameter(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	templates := store.NewRuleTemplateStore(pool)
+	h := handler.NewRuleTemplateHandler(templates, projects, store.NewAuditStore(pool))
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	ctx := context.Background()
+
+	project, err := projects.Create(ctx, uniqueKey("ruletemplatemissing"), "Rule Template Missing", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := templates.Create(ctx, project.ID, "internal-users", "Internal users", "", []string{"domain"}, []model.Condition{
+		{Attribute: "email", Operator: "ends_with", Value: "@{{domain}}"},
+	}); err != nil {
+		t.Fatalf("creating template: %v", err)
+	}
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"parameters": {}, "variant": "on"}`))
+	req.SetPathValue("key", project.Key)
+	req.SetPathValue("template", "internal-users")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.Instantiate)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `missing parameter \"domain\"`) {
+		t.Errorf("expected missing parameter error, got %s", rec.Body.String())
+	}
+}
+
+func TestRuleTemplateHandler_Create_UndeclaredPlaceholder(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	h := handler.NewRuleTemplateHandler(store.NewRuleTemplateStore(pool), projects, store.NewAuditStore(pool))
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	ctx := context.Background()
+
+	project, err := projects.Create(ctx, uniqueKey("ruletemplateundeclared"), "Rule Template Undeclared", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	body := `{"key": "t", "name": "T", "parameters": ["domain"], "conditions": [{"attribute": "email", "operator": "ends_with", "value": "@{{tld}}"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.SetPathValue("key", project.Key)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(http.HandlerFunc(h.Create)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "{{tld}}") {
+		t.Errorf("expected undeclared placeholder problem, got %s", rec.Body.String())
+	}
+}
@@ -1,12 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
 
+// defaultSDKKeyRotationGrace is how long a rotated-out key keeps working when
+// the rotate request names no grace period.
+const defaultSDKKeyRotationGrace = 24 * time.Hour
+
 type SDKKeyHandler struct {
 	sdkKeys      *store.SDKKeyStore
 	environments *store.EnvironmentStore
@@ -18,7 +26,12 @@ func NewSDKKeyHandler(sdkKeys *store.SDKKeyStore, environments *store.Environmen
 }
 
 // Create handles POST /api/v1/projects/{key}/environments/{env}/sdk-keys
+// Body: {"name": "...", "scopes": ["evaluate", "stream", "server"]}; scopes
+// may be omitted to grant all of them.
 func (h *SDKKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -45,6 +58,8 @@ func (h *SDKKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		Name string `json:"name"`
+		// Scopes limits the SDK routes the key may call; omitted grants all.
+		Scopes []model.SDKKeyScope `json:"scopes"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -54,8 +69,18 @@ func (h *SDKKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	if req.Scopes != nil && len(req.Scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "scopes must not be empty")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !model.ValidSDKKeyScopes[scope] {
+			writeError(w, http.StatusBadRequest, "scopes must be evaluate, stream or server")
+			return
+		}
+	}
 
-	sdkKey, err := h.sdkKeys.Create(r.Context(), env.ID, req.Name)
+	sdkKey, err := h.sdkKeys.Create(r.Context(), env.ID, req.Name, slices.Compact(slices.Sorted(slices.Values(req.Scopes))))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create SDK key")
 		return
@@ -66,6 +91,9 @@ func (h *SDKKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 // List handles GET /api/v1/projects/{key}/environments/{env}/sdk-keys
 func (h *SDKKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -101,8 +129,82 @@ func (h *SDKKeyHandler) List(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, keys)
 }
 
+// Rotate handles POST /api/v1/projects/{key}/environments/{env}/sdk-keys/{id}/rotate
+// Body (optional): {"grace_period_seconds": 86400}
+// It issues a replacement key with the same name and scopes and keeps the
+// old key valid for the grace period (24h by default), after which it stops
+// authenticating and the pruner revokes it. Returns both keys.
+func (h *SDKKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	projectKey := r.PathValue("key")
+	if projectKey == "" {
+		writeError(w, http.StatusBadRequest, "project key is required")
+		return
+	}
+
+	envKey := r.PathValue("env")
+	if envKey == "" {
+		writeError(w, http.StatusBadRequest, "environment key is required")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "SDK key id is required")
+		return
+	}
+
+	var req struct {
+		GracePeriodSeconds *int `json:"grace_period_seconds"`
+	}
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	grace := defaultSDKKeyRotationGrace
+	if req.GracePeriodSeconds != nil {
+		if *req.GracePeriodSeconds < 0 {
+			writeError(w, http.StatusBadRequest, "grace_period_seconds must not be negative")
+			return
+		}
+		grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	project, err := h.projects.FindByKey(r.Context(), projectKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	env, err := h.environments.FindByKey(r.Context(), project.ID, envKey)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return
+	}
+
+	old, replacement, err := h.sdkKeys.Rotate(r.Context(), env.ID, id, grace)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "SDK key not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to rotate SDK key")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"old_key": old,
+		"new_key": replacement,
+	})
+}
+
 // Revoke handles DELETE /api/v1/projects/{key}/environments/{env}/sdk-keys/{id}
 func (h *SDKKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	id := r.PathValue("id")
 	if id == "" {
 		writeError(w, http.StatusBadRequest, "SDK key id is required")
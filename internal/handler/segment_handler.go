@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// SegmentHandler handles CRUD of reusable user segments.
+type SegmentHandler struct {
+	segments     *store.SegmentStore
+	projects     *store.ProjectStore
+	environments *store.EnvironmentStore
+	audit        *store.AuditStore
+	hub          *stream.Hub
+	cache        *evaluation.Cache
+	pool         *pgxpool.Pool
+}
+
+// NewSegmentHandler creates a new SegmentHandler.
+func NewSegmentHandler(segments *store.SegmentStore, projects *store.ProjectStore, environments *store.EnvironmentStore, audit *store.AuditStore, hub *stream.Hub, cache *evaluation.Cache, pool *pgxpool.Pool) *SegmentHandler {
+	return &SegmentHandler{segments: segments, projects: projects, environments: environments, audit: audit, hub: hub, cache: cache, pool: pool}
+}
+
+// validateSegment checks a segment's conditions. Segments cannot reference
+// other segments, which keeps expansion a single level deep.
+func validateSegment(conditions []model.Condition) []validationProblem {
+	var problems []validationProblem
+	if len(conditions) == 0 {
+		problems = append(problems, validationProblem{Path: "conditions", Message: "at least one condition is required"})
+	} else if len(conditions) > maxConditionsPerRule() {
+		problems = append(problems, validationProblem{Path: "conditions", Message: fmt.Sprintf("at most %d conditions are allowed per segment", maxConditionsPerRule())})
+	}
+	for i, cond := range conditions {
+		if cond.Operator == string(model.OpInSegment) {
+			problems = append(problems, validationProblem{Path: fmt.Sprintf("conditions[%d].operator", i), Message: "segments cannot reference other segments"})
+		}
+	}
+	return append(problems, validateConditions("conditions", conditions)...)
+}
+
+type segmentRequest struct {
+	Key         string            `json:"key"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Conditions  []model.Condition `json:"conditions"`
+}
+
+// List handles GET /api/v1/projects/{key}/segments
+func (h *SegmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	segments, err := h.segments.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list segments")
+		return
+	}
+	if segments == nil {
+		segments = []model.Segment{}
+	}
+
+	writeJSON(w, http.StatusOK, segments)
+}
+
+// Create handles POST /api/v1/projects/{key}/segments
+func (h *SegmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	var req segmentRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Key == "" || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "key and name are required")
+		return
+	}
+	if problems := validateSegment(req.Conditions); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid segment",
+			"problems": problems,
+		})
+		return
+	}
+
+	segment, err := h.segments.Create(r.Context(), project.ID, req.Key, req.Name, req.Description, req.Conditions)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique") {
+			writeError(w, http.StatusConflict, "segment key already exists for this project")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create segment")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "create", segment.Key, nil, segment)
+	// Rules may already reference the key before the segment exists.
+	h.refresh(r.Context(), project, segment.Key)
+	writeJSON(w, http.StatusCreated, segment)
+}
+
+// Get handles GET /api/v1/projects/{key}/segments/{segment}
+func (h *SegmentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, segment, ok := h.resolveSegment(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, segment)
+}
+
+// Update handles PUT /api/v1/projects/{key}/segments/{segment}
+// Flags referencing the segment pick up the new conditions immediately.
+func (h *SegmentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, segment, ok := h.resolveSegment(w, r)
+	if !ok {
+		return
+	}
+
+	var req segmentRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if problems := validateSegment(req.Conditions); len(problems) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "invalid segment",
+			"problems": problems,
+		})
+		return
+	}
+
+	updated, err := h.segments.Update(r.Context(), segment.ID, req.Name, req.Description, req.Conditions)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update segment")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "update", segment.Key, segment, updated)
+	h.refresh(r.Context(), project, segment.Key)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Delete handles DELETE /api/v1/projects/{key}/segments/{segment}
+// A segment still referenced by a flag in any environment cannot be deleted.
+func (h *SegmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, segment, ok := h.resolveSegment(w, r)
+	if !ok {
+		return
+	}
+
+	envs, err := h.environments.ListByProject(r.Context(), project.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list environments")
+		return
+	}
+	var usedBy []string
+	for _, env := range envs {
+		for _, flagKey := range h.cache.FlagsUsingSegment(project.Key, env.Key, segment.Key) {
+			if !slices.Contains(usedBy, flagKey) {
+				usedBy = append(usedBy, flagKey)
+			}
+		}
+	}
+	if len(usedBy) > 0 {
+		slices.Sort(usedBy)
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error": "segment is used by targeting rules",
+			"flags": usedBy,
+		})
+		return
+	}
+
+	if err := h.segments.Delete(r.Context(), segment.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete segment")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "delete", segment.Key, segment, nil)
+	h.refresh(r.Context(), project, segment.Key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveSegment looks up the project and segment named in the path,
+// writing a 404 if either is missing.
+func (h *SegmentHandler) resolveSegment(w http.ResponseWriter, r *http.Request) (*model.Project, *model.Segment, bool) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, false
+	}
+	segment, err := h.segments.FindByKey(r.Context(), project.ID, r.PathValue("segment"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "segment not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "failed to load segment")
+		}
+		return nil, nil, false
+	}
+	return project, segment, true
+}
+
+// refresh reloads every environment of the project into the evaluation cache
+// and broadcasts a flag_update event for each flag referencing the segment.
+func (h *SegmentHandler) refresh(ctx context.Context, project *model.Project, segmentKey string) {
+	envs, err := h.environments.ListByProject(ctx, project.ID)
+	if err != nil {
+		slog.Warn("failed to list environments for cache refresh", "error", err)
+		return
+	}
+	for _, env := range envs {
+		if err := h.cache.Refresh(ctx, h.pool, project.Key, env.Key); err != nil {
+			slog.Warn("failed to refresh cache", "project", project.Key, "env", env.Key, "error", err)
+		}
+		for _, flagKey := range h.cache.FlagsUsingSegment(project.Key, env.Key, segmentKey) {
+			h.hub.Broadcast(project.Key, env.Key, stream.Event{
+				Type:    "flag_update",
+				FlagKey: flagKey,
+			})
+		}
+	}
+}
+
+// recordAudit records a best-effort audit entry for a segment change.
+func (h *SegmentHandler) recordAudit(r *http.Request, projectID, action, key string, oldSegment, newSegment *model.Segment) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return
+	}
+	entry := model.AuditEntry{
+		ProjectID:  &projectID,
+		UserID:     &user.ID,
+		Action:     action,
+		EntityType: "segment",
+		EntityID:   key,
+	}
+	if oldSegment != nil {
+		entry.OldValue, _ = json.Marshal(oldSegment)
+	}
+	if newSegment != nil {
+		entry.NewValue, _ = json.Marshal(newSegment)
+	}
+	if err := h.audit.Record(r.Context(), entry); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
@@ -0,0 +1,133 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+func TestSegmentHandler_UpdateAppliesToRulesAndDeleteInUse(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	environments := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("segment"), "Segment", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := environments.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	flag, err := flags.Create(ctx, project.ID, "eu-billing", "EU Billing", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), nil)
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	_, err = flags.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "off",
+		json.RawMessage(`[{"key":"off","value":false},{"key":"on","value":true}]`),
+		json.RawMessage(`[{"conditions":[{"attribute":"","operator":"in_segment","value":"enterprise-eu"}],"variant":"on"}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("updating environment config: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	h := handler.NewSegmentHandler(store.NewSegmentStore(pool), projects, environments, store.NewAuditStore(pool), stream.NewHub(), cache, pool)
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	do := func(fn http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("segment", "enterprise-eu")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		return rec
+	}
+	evaluate := func(plan string) string {
+		fd, ok := cache.GetFlag(project.Key, env.Key, "eu-billing")
+		if !ok {
+			t.Fatal("expected flag in cache")
+		}
+		evalCtx := &model.EvaluationContext{UserID: "u1", Attributes: map[string]any{"plan": plan, "region": "eu"}}
+		return evaluation.NewEngine().Evaluate(&fd.Flag, &fd.Config, evalCtx, nil).Variant
+	}
+
+	rec := do(h.Create, `{
+		"key": "enterprise-eu",
+		"name": "Enterprise EU",
+		"conditions": [
+			{"attribute": "plan", "operator": "equals", "value": "enterprise"},
+			{"attribute": "region", "operator": "equals", "value": "eu"}
+		]
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := evaluate("enterprise"); got != "on" {
+		t.Errorf("after create: expected enterprise EU user to get on, got %q", got)
+	}
+
+	rec = do(h.Update, `{
+		"name": "Pro EU",
+		"conditions": [{"attribute": "plan", "operator": "equals", "value": "pro"}]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Update: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := evaluate("enterprise"); got != "off" {
+		t.Errorf("after update: expected enterprise user to get off, got %q", got)
+	}
+	if got := evaluate("pro"); got != "on" {
+		t.Errorf("after update: expected pro user to get on, got %q", got)
+	}
+
+	rec = do(h.Delete, "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Delete in use: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "eu-billing") {
+		t.Errorf("expected conflict to name eu-billing, got %s", rec.Body.String())
+	}
+}
+
+func TestSegmentHandler_CreateRejectsNestedSegment(t *testing.T) {
+	pool := testPool(t)
+	projects := store.NewProjectStore(pool)
+	h := handler.NewSegmentHandler(store.NewSegmentStore(pool), projects, store.NewEnvironmentStore(pool), store.NewAuditStore(pool), stream.NewHub(), evaluation.NewCache(), pool)
+
+	project, err := projects.Create(context.Background(), uniqueKey("segmentnest"), "Segment Nest", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{
+		"key": "nested",
+		"name": "Nested",
+		"conditions": [{"attribute": "", "operator": "in_segment", "value": "other"}]
+	}`))
+	req.SetPathValue("key", project.Key)
+	req = req.WithContext(auth.ContextWithProjectRole(req.Context(), model.ProjectRoleEditor))
+	rec := httptest.NewRecorder()
+	h.Create(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "conditions[0].operator") {
+		t.Errorf("expected a problem at conditions[0].operator, got %s", rec.Body.String())
+	}
+}
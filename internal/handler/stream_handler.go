@@ -2,21 +2,39 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/auth"
 	"github.com/togglerino/togglerino/internal/stream"
 )
 
+// streamRetryAfterSeconds is the Retry-After sent when a scope is at its
+// subscriber limit, so reconnecting clients back off instead of hammering.
+const streamRetryAfterSeconds = 5
+
+// DefaultStreamKeepaliveSeconds is the default interval between keepalive
+// comments, chosen to stay under the common 30s proxy idle timeout.
+const DefaultStreamKeepaliveSeconds = 25
+
 // StreamHandler handles SSE connections for real-time flag updates.
 type StreamHandler struct {
-	hub *stream.Hub
+	hub       *stream.Hub
+	keepalive time.Duration
 }
 
 // NewStreamHandler creates a new StreamHandler backed by the given Hub.
 func NewStreamHandler(hub *stream.Hub) *StreamHandler {
-	return &StreamHandler{hub: hub}
+	return &StreamHandler{hub: hub, keepalive: DefaultStreamKeepaliveSeconds * time.Second}
+}
+
+// SetKeepaliveInterval sets how often an idle connection receives a
+// `: keepalive` comment. Zero or less disables keepalives.
+func (h *StreamHandler) SetKeepaliveInterval(d time.Duration) {
+	h.keepalive = d
 }
 
 // Handle serves GET /api/v1/stream as an SSE endpoint.
@@ -26,43 +44,85 @@ func (h *StreamHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	projectKey := sdkKey.ProjectKey
 	envKey := sdkKey.EnvironmentKey
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
-	// Subscribe to events
-	ch := h.hub.Subscribe(projectKey, envKey)
+	// Subscribe to events, shedding load when the scope is full. A client
+	// reconnecting with Last-Event-ID first receives the events it missed.
+	var ch chan stream.Event
+	var missed []stream.Event
+	var err error
+	if lastID, parseErr := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); parseErr == nil {
+		ch, missed, err = h.hub.SubscribeSince(projectKey, envKey, lastID)
+	} else {
+		ch, err = h.hub.Subscribe(projectKey, envKey)
+	}
+	if errors.Is(err, stream.ErrTooManySubscribers) {
+		w.Header().Set("Retry-After", strconv.Itoa(streamRetryAfterSeconds))
+		writeError(w, http.StatusServiceUnavailable, "too many stream subscribers, retry later")
+		return
+	}
+	if errors.Is(err, stream.ErrDraining) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down, retry later")
+		return
+	}
 	defer h.hub.Unsubscribe(projectKey, envKey, ch)
 
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
 	// Send initial keepalive
 	fmt.Fprintf(w, ": connected\n\n")
+	for _, event := range missed {
+		writeStreamEvent(w, event)
+	}
 	flusher.Flush()
 
+	// Keep idle connections alive through proxies; a nil channel never fires
+	var keepalive <-chan time.Time
+	if h.keepalive > 0 {
+		ticker := time.NewTicker(h.keepalive)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+
 	// Stream events until client disconnects
 	ctx := r.Context()
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-keepalive:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
 		case event, ok := <-ch:
-			if !ok {
+			if !ok || event.Type == stream.EventClosing {
+				// The hub is draining for shutdown; tell the client before
+				// the connection ends so it reconnects without an error.
+				fmt.Fprintf(w, ": closing\n\n")
+				flusher.Flush()
 				return
 			}
-			data, _ := json.Marshal(event)
-			eventName := event.Type
-			if eventName == "" {
-				eventName = "flag_update"
-			}
-			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventName, data)
+			writeStreamEvent(w, event)
 			flusher.Flush()
 		}
 	}
 }
+
+// writeStreamEvent writes one SSE event. The id line lets clients resume
+// from it with Last-Event-ID.
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	data, _ := json.Marshal(event)
+	eventName := event.Type
+	if eventName == "" {
+		eventName = "flag_update"
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, eventName, data)
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// syncRecorder is a flushable ResponseWriter that is safe to read while the
+// stream handler is still writing.
+type syncRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	body   strings.Builder
+}
+
+func (r *syncRecorder) Header() http.Header { return r.header }
+func (r *syncRecorder) WriteHeader(int)     {}
+func (r *syncRecorder) Flush()              {}
+
+func (r *syncRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body.Write(p)
+}
+
+func (r *syncRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body.String()
+}
+
+func TestStreamHandler_Keepalive(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("keepalive"), "Keepalive", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	h := handler.NewStreamHandler(stream.NewHub())
+	h.SetKeepaliveInterval(20 * time.Millisecond)
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil).WithContext(reqCtx)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := &syncRecorder{header: http.Header{}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(h.Handle)).ServeHTTP(rec, req)
+	}()
+
+	deadline := time.After(time.Second)
+	for !strings.Contains(rec.String(), ": keepalive\n\n") {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatalf("no keepalive within deadline, got %q", rec.String())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return after client disconnect")
+	}
+	if !strings.HasPrefix(rec.String(), ": connected\n\n") {
+		t.Errorf("expected stream to start with connected comment, got %q", rec.String())
+	}
+}
+
+func TestRequireSDKScope_EvaluateOnlyKey(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("scoped"), "Scoped", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "browser", []model.SDKKeyScope{model.SDKKeyScopeEvaluate})
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	sdkAuth := auth.SDKAuth(store.NewSDKKeyStore(pool))
+	eh := handler.NewEvaluateHandler(evaluation.NewCache(), evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sh := handler.NewStreamHandler(stream.NewHub())
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/evaluate", sdkAuth(auth.RequireSDKScope(model.SDKKeyScopeEvaluate)(http.HandlerFunc(eh.EvaluateAll))))
+	mux.Handle("GET /api/v1/stream", sdkAuth(auth.RequireSDKScope(model.SDKKeyScopeStream)(http.HandlerFunc(sh.Handle))))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("stream: expected 403, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("evaluate: expected 200, got %d, body %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// TokenHandler manages the current user's personal access tokens.
+type TokenHandler struct {
+	tokens *store.PersonalAccessTokenStore
+}
+
+// NewTokenHandler creates a new TokenHandler.
+func NewTokenHandler(tokens *store.PersonalAccessTokenStore) *TokenHandler {
+	return &TokenHandler{tokens: tokens}
+}
+
+// Create handles POST /api/v1/auth/tokens
+// The plaintext token is only ever returned in this response.
+func (h *TokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req struct {
+		Name      string     `json:"name"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	token, err := h.tokens.Create(r.Context(), user.ID, req.Name, req.ExpiresAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	writeJSON(w, http.StatusCreated, token)
+}
+
+// List handles GET /api/v1/auth/tokens
+func (h *TokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	tokens, err := h.tokens.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list tokens")
+		return
+	}
+	if tokens == nil {
+		tokens = []model.PersonalAccessToken{}
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// Revoke handles DELETE /api/v1/auth/tokens/{id}
+func (h *TokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	err := h.tokens.Revoke(r.Context(), r.PathValue("id"), user.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestTokenHandler_AuthenticatesAndRevokes(t *testing.T) {
+	pool := testPool(t)
+	sessions := store.NewSessionStore(pool)
+	users := store.NewUserStore(pool)
+	tokens := store.NewPersonalAccessTokenStore(pool)
+	h := handler.NewTokenHandler(tokens)
+
+	member, cookie := testSession(t, pool, model.RoleMember)
+	sessionOnly := auth.SessionAuth(sessions, users)
+	managementAuth := auth.ManagementAuth(sessions, users, tokens)
+
+	// Create a token from the browser session
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/tokens", strings.NewReader(`{"name":"ci"}`))
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionOnly(http.HandlerFunc(h.Create)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var created model.PersonalAccessToken
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding token: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, "pat_") {
+		t.Fatalf("expected plaintext pat_ token, got %q", created.Token)
+	}
+
+	// The token authenticates as its owner and carries the owner's role
+	call := func(token string, next http.Handler) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		managementAuth(next).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	var seen *model.User
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	if code := call(created.Token, whoami); code != http.StatusOK {
+		t.Fatalf("token auth: expected %d, got %d", http.StatusOK, code)
+	}
+	if seen == nil || seen.ID != member.ID || seen.Role != model.RoleMember {
+		t.Errorf("expected request to run as the member, got %+v", seen)
+	}
+	if code := call(created.Token, auth.RequireRole(model.RoleAdmin)(whoami)); code != http.StatusForbidden {
+		t.Errorf("admin-only route: expected %d, got %d", http.StatusForbidden, code)
+	}
+	if code := call("pat_unknown", whoami); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+
+	// Listing never reveals the plaintext
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/auth/tokens", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	sessionOnly(http.HandlerFunc(h.List)).ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), created.Token) {
+		t.Error("expected list response to omit the plaintext token")
+	}
+
+	// Revoked tokens stop working
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/auth/tokens/"+created.ID, nil)
+	req.SetPathValue("id", created.ID)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	sessionOnly(http.HandlerFunc(h.Revoke)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke: expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if code := call(created.Token, whoami); code != http.StatusUnauthorized {
+		t.Errorf("revoked token: expected %d, got %d", http.StatusUnauthorized, code)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
 
@@ -43,6 +44,9 @@ func (h *UnknownFlagHandler) List(w http.ResponseWriter, r *http.Request) {
 
 // Dismiss handles DELETE /api/v1/projects/{key}/unknown-flags/{id}
 func (h *UnknownFlagHandler) Dismiss(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
 	projectKey := r.PathValue("key")
 	if projectKey == "" {
 		writeError(w, http.StatusBadRequest, "project key is required")
@@ -0,0 +1,128 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// serveUnknownFlag calls an unknown flag handler as a logged-in member with
+// the given project key and unknown flag ID.
+func serveUnknownFlag(t *testing.T, pool *pgxpool.Pool, fn http.HandlerFunc, method, body, projectKey, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.SetPathValue("key", projectKey)
+	req.SetPathValue("id", id)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	sessionAuth(fn).ServeHTTP(rec, req)
+	return rec
+}
+
+// setupUnknownFlags creates a project with two environments in which SDKs
+// have requested the flag key "new-checkout", returning the project.
+func setupUnknownFlags(t *testing.T, pool *pgxpool.Pool, prefix string) *model.Project {
+	t.Helper()
+	ctx := context.Background()
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey(prefix), "Unknown Flags", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	unknownFlags := store.NewUnknownFlagStore(pool)
+	for _, key := range []string{"staging", "production"} {
+		env, err := store.NewEnvironmentStore(pool).Create(ctx, project.ID, key, key)
+		if err != nil {
+			t.Fatalf("creating environment: %v", err)
+		}
+		if err := unknownFlags.Upsert(ctx, project.ID, env.ID, "new-checkout"); err != nil {
+			t.Fatalf("recording unknown flag: %v", err)
+		}
+	}
+	return project
+}
+
+func listUnknownFlags(t *testing.T, pool *pgxpool.Pool, h *handler.UnknownFlagHandler, projectKey string) []model.UnknownFlag {
+	t.Helper()
+	rec := serveUnknownFlag(t, pool, h.List, http.MethodGet, "", projectKey, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var flags []model.UnknownFlag
+	if err := json.Unmarshal(rec.Body.Bytes(), &flags); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return flags
+}
+
+func TestUnknownFlagHandler_ListAndDismiss(t *testing.T) {
+	pool := testPool(t)
+	project := setupUnknownFlags(t, pool, "unknownlist")
+	h := handler.NewUnknownFlagHandler(store.NewUnknownFlagStore(pool), store.NewProjectStore(pool))
+
+	flags := listUnknownFlags(t, pool, h, project.Key)
+	if len(flags) != 2 {
+		t.Fatalf("expected 2 unknown flags, got %d", len(flags))
+	}
+	for _, f := range flags {
+		if f.FlagKey != "new-checkout" || f.EnvironmentKey == "" {
+			t.Errorf("unexpected unknown flag %+v", f)
+		}
+	}
+
+	rec := serveUnknownFlag(t, pool, h.Dismiss, http.MethodDelete, "", project.Key, flags[0].ID)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if remaining := listUnknownFlags(t, pool, h, project.Key); len(remaining) != 1 || remaining[0].ID != flags[1].ID {
+		t.Errorf("expected only %s to remain, got %+v", flags[1].ID, remaining)
+	}
+}
+
+func TestFlagHandler_CreateFromUnknown(t *testing.T) {
+	pool := testPool(t)
+	project := setupUnknownFlags(t, pool, "unknowncreate")
+	unknownFlags := store.NewUnknownFlagStore(pool)
+	h := newTestFlagHandler(pool)
+
+	unknown, err := unknownFlags.ListByProject(context.Background(), project.ID)
+	if err != nil || len(unknown) != 2 {
+		t.Fatalf("listing unknown flags: %v (%d)", err, len(unknown))
+	}
+
+	rec := serveUnknownFlag(t, pool, h.CreateFromUnknown, http.MethodPost,
+		`{"key": "ignored", "name": "New checkout", "flag_type": "experiment"}`, project.Key, unknown[0].ID)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var flag model.Flag
+	if err := json.Unmarshal(rec.Body.Bytes(), &flag); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if flag.Key != "new-checkout" || flag.Name != "New checkout" || flag.FlagType != model.FlagTypeExperiment {
+		t.Errorf("expected experiment flag new-checkout, got %+v", flag)
+	}
+
+	remaining, err := unknownFlags.ListByProject(context.Background(), project.ID)
+	if err != nil {
+		t.Fatalf("listing unknown flags: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("expected unknown flags to be cleared in every environment, got %+v", remaining)
+	}
+
+	rec = serveUnknownFlag(t, pool, h.CreateFromUnknown, http.MethodPost, `{"name": "Again"}`, project.Key, unknown[1].ID)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a cleared unknown flag, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/quota"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+// UsageHandler serves per-environment evaluation usage and quota settings.
+type UsageHandler struct {
+	usage        *store.EvaluationUsageStore
+	tracker      *quota.Tracker
+	projects     *store.ProjectStore
+	environments *store.EnvironmentStore
+	audit        *store.AuditStore
+}
+
+// NewUsageHandler creates a new UsageHandler.
+func NewUsageHandler(usage *store.EvaluationUsageStore, tracker *quota.Tracker, projects *store.ProjectStore, environments *store.EnvironmentStore, audit *store.AuditStore) *UsageHandler {
+	return &UsageHandler{usage: usage, tracker: tracker, projects: projects, environments: environments, audit: audit}
+}
+
+// resolveEnvironment looks up the project and environment named in the path,
+// writing a 404 if either is missing.
+func (h *UsageHandler) resolveEnvironment(w http.ResponseWriter, r *http.Request) (*model.Project, *model.Environment, bool) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, false
+	}
+	env, err := h.environments.FindByKey(r.Context(), project.ID, r.PathValue("env"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return nil, nil, false
+	}
+	return project, env, true
+}
+
+// Get handles GET /api/v1/projects/{key}/environments/{env}/usage
+// It returns the current month's evaluation count and the environment's quota.
+func (h *UsageHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, env, ok := h.resolveEnvironment(w, r)
+	if !ok {
+		return
+	}
+
+	usage := h.tracker.Usage(env.ID)
+	resp := map[string]any{
+		"environment":   env.Key,
+		"period":        usage.Period.Format("2006-01"),
+		"evaluations":   usage.Evaluations,
+		"rejected":      usage.Rejected,
+		"monthly_quota": usage.Quota,
+		"resets_at":     usage.ResetsAt,
+	}
+	if usage.Quota != nil {
+		resp["remaining"] = max(*usage.Quota-usage.Evaluations, 0)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// SetQuota handles PUT /api/v1/projects/{key}/environments/{env}/quota
+// Body: {"monthly_quota": 1000000}; null removes the quota.
+func (h *UsageHandler) SetQuota(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleAdmin) {
+		return
+	}
+	project, env, ok := h.resolveEnvironment(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		MonthlyQuota *int64 `json:"monthly_quota"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.MonthlyQuota != nil && *req.MonthlyQuota < 0 {
+		writeError(w, http.StatusBadRequest, "monthly_quota must be non-negative")
+		return
+	}
+
+	old := h.tracker.Usage(env.ID).Quota
+	if err := h.usage.SetQuota(r.Context(), env.ID, req.MonthlyQuota); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to set quota")
+		return
+	}
+	h.tracker.SetQuota(env.ID, req.MonthlyQuota)
+
+	// Best-effort audit logging
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		oldVal, _ := json.Marshal(map[string]any{"monthly_quota": old})
+		newVal, _ := json.Marshal(map[string]any{"monthly_quota": req.MonthlyQuota})
+		if err := h.audit.Record(r.Context(), model.AuditEntry{
+			ProjectID:  &project.ID,
+			UserID:     &user.ID,
+			Action:     "update",
+			EntityType: "environment_quota",
+			EntityID:   env.Key,
+			OldValue:   oldVal,
+			NewValue:   newVal,
+		}); err != nil {
+			slog.Warn("failed to record audit log", "error", err)
+		}
+	}
+
+	h.Get(w, r)
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// UserOverrideHandler manages sticky per-user variant overrides.
+type UserOverrideHandler struct {
+	overrides    *store.UserOverrideStore
+	flags        *store.FlagStore
+	projects     *store.ProjectStore
+	environments *store.EnvironmentStore
+	audit        *store.AuditStore
+	hub          *stream.Hub
+	cache        *evaluation.Cache
+	pool         *pgxpool.Pool
+}
+
+// NewUserOverrideHandler creates a new UserOverrideHandler.
+func NewUserOverrideHandler(overrides *store.UserOverrideStore, flags *store.FlagStore, projects *store.ProjectStore, environments *store.EnvironmentStore, audit *store.AuditStore, hub *stream.Hub, cache *evaluation.Cache, pool *pgxpool.Pool) *UserOverrideHandler {
+	return &UserOverrideHandler{overrides: overrides, flags: flags, projects: projects, environments: environments, audit: audit, hub: hub, cache: cache, pool: pool}
+}
+
+// List handles GET /api/v1/projects/{key}/users/{user}/overrides
+func (h *UserOverrideHandler) List(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	overrides, err := h.overrides.ListByUser(r.Context(), project.ID, r.PathValue("user"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list user overrides")
+		return
+	}
+	if overrides == nil {
+		overrides = []model.UserOverride{}
+	}
+
+	writeJSON(w, http.StatusOK, overrides)
+}
+
+// Set handles PUT /api/v1/projects/{key}/users/{user}/overrides/{flag}/environments/{env}
+// Body: {"variant": "on"}. The variant must exist in the flag's config for that environment.
+func (h *UserOverrideHandler) Set(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, flag, env, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Variant string `json:"variant"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Variant == "" {
+		writeError(w, http.StatusBadRequest, "variant is required")
+		return
+	}
+
+	cfg, err := h.flags.GetEnvironmentConfig(r.Context(), flag.ID, env.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load environment config")
+		return
+	}
+	known := false
+	for _, v := range cfg.Variants {
+		if v.Key == req.Variant {
+			known = true
+			break
+		}
+	}
+	if !known {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("variant %q is not defined for this flag in %s", req.Variant, env.Key))
+		return
+	}
+
+	override, err := h.overrides.Set(r.Context(), flag.ID, env.ID, r.PathValue("user"), req.Variant)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to set user override")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "update", override.UserID, nil, override)
+	h.refresh(r, project.Key, env.Key, flag.Key)
+	writeJSON(w, http.StatusOK, override)
+}
+
+// Clear handles DELETE /api/v1/projects/{key}/users/{user}/overrides/{flag}/environments/{env}
+func (h *UserOverrideHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	if !requireProjectRole(w, r, model.ProjectRoleEditor) {
+		return
+	}
+	project, flag, env, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+
+	userID := r.PathValue("user")
+	if err := h.overrides.Delete(r.Context(), flag.ID, env.ID, userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "user override not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to clear user override")
+		return
+	}
+
+	h.recordAudit(r, project.ID, "delete", userID, &model.UserOverride{
+		FlagID: flag.ID, FlagKey: flag.Key, EnvironmentID: env.ID, EnvironmentKey: env.Key, UserID: userID,
+	}, nil)
+	h.refresh(r, project.Key, env.Key, flag.Key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolve looks up the project, flag and environment named in the path,
+// writing a 404 if any is missing.
+func (h *UserOverrideHandler) resolve(w http.ResponseWriter, r *http.Request) (*model.Project, *model.Flag, *model.Environment, bool) {
+	project, err := h.projects.FindByKey(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, nil, nil, false
+	}
+	flag, err := h.flags.FindByKey(r.Context(), project.ID, r.PathValue("flag"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "flag not found")
+		return nil, nil, nil, false
+	}
+	env, err := h.environments.FindByKey(r.Context(), project.ID, r.PathValue("env"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "environment not found")
+		return nil, nil, nil, false
+	}
+	return project, flag, env, true
+}
+
+// refresh reloads the environment into the evaluation cache and notifies
+// streaming clients so the override takes effect immediately.
+func (h *UserOverrideHandler) refresh(r *http.Request, projectKey, envKey, flagKey string) {
+	if err := h.cache.Refresh(r.Context(), h.pool, projectKey, envKey); err != nil {
+		slog.Warn("failed to refresh cache", "error", err)
+	}
+	h.hub.Broadcast(projectKey, envKey, stream.Event{
+		Type:    "flag_update",
+		FlagKey: flagKey,
+	})
+}
+
+// recordAudit records a best-effort audit entry for an override change.
+func (h *UserOverrideHandler) recordAudit(r *http.Request, projectID, action, userID string, oldOverride, newOverride *model.UserOverride) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return
+	}
+	entry := model.AuditEntry{
+		ProjectID:  &projectID,
+		UserID:     &user.ID,
+		Action:     action,
+		EntityType: "user_override",
+		EntityID:   userID,
+	}
+	if oldOverride != nil {
+		entry.OldValue, _ = json.Marshal(oldOverride)
+	}
+	if newOverride != nil {
+		entry.NewValue, _ = json.Marshal(newOverride)
+	}
+	if err := h.audit.Record(r.Context(), entry); err != nil {
+		slog.Warn("failed to record audit log", "error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/evaluation"
+	"github.com/togglerino/togglerino/internal/handler"
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+func TestUserOverrideHandler_OverrideSupersedesRulesAndClears(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	projects := store.NewProjectStore(pool)
+	environments := store.NewEnvironmentStore(pool)
+	flags := store.NewFlagStore(pool)
+
+	project, err := projects.Create(ctx, uniqueKey("useroverride"), "User Override", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := environments.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	flag, err := flags.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), nil)
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	_, err = flags.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "off",
+		json.RawMessage(`[{"key":"off","value":false},{"key":"on","value":true}]`),
+		json.RawMessage(`[{"conditions":[],"variant":"off","percentage_rollout":100}]`),
+		json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("updating environment config: %v", err)
+	}
+	sdkKey, err := store.NewSDKKeyStore(pool).Create(ctx, env.ID, "test", nil)
+	if err != nil {
+		t.Fatalf("creating sdk key: %v", err)
+	}
+
+	cache := evaluation.NewCache()
+	if err := cache.Refresh(ctx, pool, project.Key, env.Key); err != nil {
+		t.Fatalf("refreshing cache: %v", err)
+	}
+	h := handler.NewUserOverrideHandler(store.NewUserOverrideStore(pool), flags, projects, environments, store.NewAuditStore(pool), stream.NewHub(), cache, pool)
+	eh := handler.NewEvaluateHandler(cache, evaluation.NewEngine(), store.NewUnknownFlagStore(pool), store.NewContextAttributeStore(pool))
+	sessionAuth := sessionAuthAs(pool, model.ProjectRoleEditor)
+	_, cookie := testSession(t, pool, model.RoleMember)
+
+	manage := func(fn http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		req.SetPathValue("key", project.Key)
+		req.SetPathValue("user", "qa-user")
+		req.SetPathValue("flag", "checkout")
+		req.SetPathValue("env", env.Key)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		sessionAuth(fn).ServeHTTP(rec, req)
+		return rec
+	}
+	evaluate := func() model.EvaluationResult {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"context":{"user_id":"qa-user"}}`))
+		req.SetPathValue("flag", "checkout")
+		req.Header.Set("Authorization", "Bearer "+sdkKey.Key)
+		rec := httptest.NewRecorder()
+		auth.SDKAuth(store.NewSDKKeyStore(pool))(http.HandlerFunc(eh.EvaluateSingle)).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("EvaluateSingle: got status %d, body %s", rec.Code, rec.Body.String())
+		}
+		var result model.EvaluationResult
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatalf("decoding result: %v", err)
+		}
+		return result
+	}
+
+	if rec := manage(h.Set, `{"variant":"missing"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Set unknown variant: expected 400, got %d", rec.Code)
+	}
+
+	if rec := manage(h.Set, `{"variant":"on"}`); rec.Code != http.StatusOK {
+		t.Fatalf("Set: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if result := evaluate(); result.Reason != "user_override" || result.Variant != "on" {
+		t.Errorf("with override: expected user_override/on, got %s/%s", result.Reason, result.Variant)
+	}
+
+	rec := manage(h.List, "")
+	var overrides []model.UserOverride
+	if err := json.NewDecoder(rec.Body).Decode(&overrides); err != nil {
+		t.Fatalf("decoding overrides: %v", err)
+	}
+	if len(overrides) != 1 || overrides[0].FlagKey != "checkout" || overrides[0].Variant != "on" {
+		t.Errorf("List: unexpected overrides %+v", overrides)
+	}
+
+	if rec := manage(h.Clear, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Clear: got status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if result := evaluate(); result.Reason != "rule_match" || result.Variant != "off" {
+		t.Errorf("after clearing: expected rule_match/off, got %s/%s", result.Reason, result.Variant)
+	}
+	if rec := manage(h.Clear, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Clear again: expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,92 @@
+// Package impressions counts how often each flag variant is served, the
+// foundation for experiment results. Counts are kept in memory and flushed
+// to the store in batches, so serving a flag costs no database write.
+package impressions
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// Store is the interface for persisting variant evaluation counts.
+type Store interface {
+	Increment(ctx context.Context, at time.Time, counts map[model.VariantEvaluation]int64) error
+}
+
+// Tracker accumulates variant evaluation counts and flushes them to the
+// store every interval.
+type Tracker struct {
+	store    Store
+	interval time.Duration
+	now      func() time.Time // injectable for testing
+
+	mu sync.Mutex
+	// pending holds counts recorded since the last flush.
+	pending map[model.VariantEvaluation]int64
+}
+
+// NewTracker creates a tracker that flushes counts to store every interval.
+func NewTracker(store Store, interval time.Duration) *Tracker {
+	return &Tracker{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[model.VariantEvaluation]int64),
+	}
+}
+
+// Record counts one evaluation of a flag that served variant. Evaluations
+// without a variant, such as a flag's default value, are not counted.
+func (t *Tracker) Record(flagID, environmentID, variant string) {
+	if flagID == "" || variant == "" {
+		return
+	}
+	t.mu.Lock()
+	t.pending[model.VariantEvaluation{FlagID: flagID, EnvironmentID: environmentID, Variant: variant}]++
+	t.mu.Unlock()
+}
+
+// Flush persists the counts recorded since the last flush, attributed to the
+// current hour. Counts that fail to persist are retried on the next flush.
+func (t *Tracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	counts := t.pending
+	t.pending = make(map[model.VariantEvaluation]int64)
+	t.mu.Unlock()
+
+	if err := t.store.Increment(ctx, t.now(), counts); err != nil {
+		slog.Error("impression tracker: failed to flush variant evaluations", "variants", len(counts), "error", err)
+		t.mu.Lock()
+		for k, n := range counts {
+			t.pending[k] += n
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Run flushes counts every interval until ctx is cancelled. Call Flush
+// during shutdown to persist the final counts.
+func (t *Tracker) Run(ctx context.Context) {
+	slog.Info("impression tracker started", "interval", t.interval)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("impression tracker stopped")
+			return
+		case <-ticker.C:
+			t.Flush(ctx)
+		}
+	}
+}
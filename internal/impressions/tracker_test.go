@@ -0,0 +1,82 @@
+package impressions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type mockStore struct {
+	err     error
+	flushes int
+	counts  map[model.VariantEvaluation]int64
+}
+
+func (m *mockStore) Increment(_ context.Context, _ time.Time, counts map[model.VariantEvaluation]int64) error {
+	m.flushes++
+	if m.err != nil {
+		return m.err
+	}
+	if m.counts == nil {
+		m.counts = make(map[model.VariantEvaluation]int64)
+	}
+	for k, n := range counts {
+		m.counts[k] += n
+	}
+	return nil
+}
+
+func TestFlush_BatchesCountsPerVariant(t *testing.T) {
+	store := &mockStore{}
+	tr := NewTracker(store, time.Minute)
+
+	tr.Record("flag-1", "env-1", "on")
+	tr.Record("flag-1", "env-1", "on")
+	tr.Record("flag-1", "env-1", "off")
+	tr.Record("flag-1", "env-2", "on")
+	tr.Record("flag-1", "env-1", "") // default value, not counted
+	tr.Flush(context.Background())
+
+	if store.flushes != 1 {
+		t.Fatalf("expected one batched write, got %d", store.flushes)
+	}
+	want := map[model.VariantEvaluation]int64{
+		{FlagID: "flag-1", EnvironmentID: "env-1", Variant: "on"}:  2,
+		{FlagID: "flag-1", EnvironmentID: "env-1", Variant: "off"}: 1,
+		{FlagID: "flag-1", EnvironmentID: "env-2", Variant: "on"}:  1,
+	}
+	if len(store.counts) != len(want) {
+		t.Fatalf("expected %d counters, got %+v", len(want), store.counts)
+	}
+	for k, n := range want {
+		if store.counts[k] != n {
+			t.Errorf("%+v: got %d, want %d", k, store.counts[k], n)
+		}
+	}
+
+	// Nothing new to write.
+	tr.Flush(context.Background())
+	if store.flushes != 1 {
+		t.Errorf("expected an empty flush to skip the store, got %d writes", store.flushes)
+	}
+}
+
+func TestFlush_RetriesFailedCounts(t *testing.T) {
+	store := &mockStore{err: errors.New("db down")}
+	tr := NewTracker(store, time.Minute)
+
+	tr.Record("flag-1", "env-1", "on")
+	tr.Flush(context.Background())
+
+	store.err = nil
+	tr.Record("flag-1", "env-1", "on")
+	tr.Flush(context.Background())
+
+	key := model.VariantEvaluation{FlagID: "flag-1", EnvironmentID: "env-1", Variant: "on"}
+	if store.counts[key] != 2 {
+		t.Errorf("expected the failed count to be retried, got %d", store.counts[key])
+	}
+}
@@ -2,19 +2,31 @@
 package logging
 
 import (
+	"io"
 	"log/slog"
 	"os"
 )
 
-// Setup configures the default slog logger based on the given format.
-// If format is "text", a human-readable text handler is used.
+// output is where Setup's handlers write; tests replace it.
+var output io.Writer = os.Stdout
+
+// level is shared by every handler Setup installs, so a later call changes
+// the level of loggers derived from an earlier default too.
+var level slog.LevelVar
+
+// Setup configures the default slog logger based on the given format and
+// minimum level. If format is "text", a human-readable text handler is used.
 // Otherwise (including "json" and empty string), a JSON handler is used.
-func Setup(format string) {
+// Records logged with a request context carry its request ID. Setup may be
+// called again to switch format or level while the server runs.
+func Setup(format string, minLevel slog.Level) {
+	level.Set(minLevel)
+	opts := &slog.HandlerOptions{Level: &level}
 	var handler slog.Handler
 	if format == "text" {
-		handler = slog.NewTextHandler(os.Stdout, nil)
+		handler = slog.NewTextHandler(output, opts)
 	} else {
-		handler = slog.NewJSONHandler(os.Stdout, nil)
+		handler = slog.NewJSONHandler(output, opts)
 	}
-	slog.SetDefault(slog.New(handler))
+	slog.SetDefault(slog.New(contextHandler{handler}))
 }
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLogs points Setup's output at a buffer for the test's duration and
+// restores the previous default logger afterwards.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prevOutput, prevLogger, prevLevel := output, slog.Default(), level.Level()
+	output = &buf
+	t.Cleanup(func() {
+		output = prevOutput
+		level.Set(prevLevel)
+		slog.SetDefault(prevLogger)
+	})
+	return &buf
+}
+
+func TestSetup_LevelFiltersRecords(t *testing.T) {
+	buf := captureLogs(t)
+
+	Setup("json", slog.LevelInfo)
+	slog.Debug("debug detail")
+	slog.Error("something broke")
+	if strings.Contains(buf.String(), "debug detail") {
+		t.Errorf("expected debug logs to be suppressed at info level, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "something broke") {
+		t.Errorf("expected the error to be logged at info level, got %q", buf.String())
+	}
+
+	buf.Reset()
+	Setup("text", slog.LevelError)
+	slog.Warn("minor issue")
+	slog.Error("still broken")
+	if strings.Contains(buf.String(), "minor issue") || !strings.Contains(buf.String(), "still broken") {
+		t.Errorf("expected only the error at error level, got %q", buf.String())
+	}
+}
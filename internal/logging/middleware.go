@@ -2,10 +2,43 @@ package logging
 
 import (
 	"log/slog"
+	"math"
+	"math/rand/v2"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// evaluatePathPrefix matches the SDK evaluate endpoints, whose successful
+// requests are sampled.
+const evaluatePathPrefix = "/api/v1/evaluate"
+
+// evaluateSampleRate holds the float64 bits of the fraction of successful
+// evaluate requests that are logged.
+var evaluateSampleRate atomic.Uint64
+
+func init() {
+	evaluateSampleRate.Store(math.Float64bits(1))
+}
+
+// SetEvaluateSampleRate sets the fraction, from 0 to 1, of successful
+// evaluate requests that Middleware logs. Failed evaluate requests and all
+// other requests are always logged. It may be changed while serving.
+func SetEvaluateSampleRate(rate float64) {
+	evaluateSampleRate.Store(math.Float64bits(min(max(rate, 0), 1)))
+}
+
+// sampledOut reports whether the request log for a response should be
+// skipped under the evaluate sample rate.
+func sampledOut(path string, status int) bool {
+	if status >= http.StatusBadRequest || !strings.HasPrefix(path, evaluatePathPrefix) {
+		return false
+	}
+	rate := math.Float64frombits(evaluateSampleRate.Load())
+	return rate < 1 && rand.Float64() >= rate
+}
+
 // statusWriter wraps http.ResponseWriter to capture the response status code.
 type statusWriter struct {
 	http.ResponseWriter
@@ -26,19 +59,29 @@ func (w *statusWriter) Flush() {
 }
 
 // Middleware returns HTTP middleware that logs every request with method, path,
-// status code, and duration in milliseconds.
+// status code, duration in milliseconds and request ID; successful evaluate
+// requests are sampled (see SetEvaluateSampleRate). The request ID is
+// taken from an incoming X-Request-ID header or generated, stored in the
+// request context (see RequestIDFromContext) and echoed in the response.
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		id := requestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(WithRequestID(r.Context(), id))
 		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
 
 		next.ServeHTTP(sw, r)
 
+		if sampledOut(r.URL.Path, sw.status) {
+			return
+		}
 		slog.Info("request",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", sw.status,
 			"duration_ms", time.Since(start).Milliseconds(),
+			"request_id", id,
 		)
 	})
 }
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveWithRequestID(t *testing.T, incoming string) (header, fromContext string) {
+	t.Helper()
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromContext = RequestIDFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/flags", nil)
+	if incoming != "" {
+		req.Header.Set(RequestIDHeader, incoming)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Header().Get(RequestIDHeader), fromContext
+}
+
+func TestMiddleware_EchoesIncomingRequestID(t *testing.T) {
+	header, fromContext := serveWithRequestID(t, "req-abc-123")
+	if header != "req-abc-123" {
+		t.Errorf("response %s = %q, want req-abc-123", RequestIDHeader, header)
+	}
+	if fromContext != "req-abc-123" {
+		t.Errorf("context request ID = %q, want req-abc-123", fromContext)
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	header, fromContext := serveWithRequestID(t, "")
+	if len(header) != 32 {
+		t.Fatalf("response %s = %q, want a generated 32-character ID", RequestIDHeader, header)
+	}
+	if fromContext != header {
+		t.Errorf("context request ID = %q, want %q", fromContext, header)
+	}
+	if other, _ := serveWithRequestID(t, ""); other == header {
+		t.Errorf("two requests got the same generated ID %q", header)
+	}
+}
+
+func TestMiddleware_ReplacesInvalidRequestID(t *testing.T) {
+	for _, incoming := range []string{"has space", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		header, _ := serveWithRequestID(t, incoming)
+		if header == incoming || len(header) != 32 {
+			t.Errorf("incoming %q: response %s = %q, want a generated ID", incoming, RequestIDHeader, header)
+		}
+	}
+}
+
+func TestMiddleware_SamplesSuccessfulEvaluateRequests(t *testing.T) {
+	buf := captureLogs(t)
+	Setup("json", slog.LevelInfo)
+	SetEvaluateSampleRate(0)
+	t.Cleanup(func() { SetEvaluateSampleRate(1) })
+
+	serve := func(path string, status int) string {
+		buf.Reset()
+		h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+		return buf.String()
+	}
+
+	if got := serve("/api/v1/evaluate", http.StatusOK); got != "" {
+		t.Errorf("expected a successful evaluate request to be sampled out, got %q", got)
+	}
+	if got := serve("/api/v1/evaluate/dark-mode", http.StatusUnauthorized); !strings.Contains(got, `"status":401`) {
+		t.Errorf("expected a failed evaluate request to be logged, got %q", got)
+	}
+	if got := serve("/api/v1/projects", http.StatusOK); !strings.Contains(got, `"path":"/api/v1/projects"`) {
+		t.Errorf("expected other requests to be logged, got %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Package metrics exposes server metrics to Prometheus. It wraps a
+// client_golang registry holding the server's own metrics alongside the
+// standard Go runtime and process collectors.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the evaluation latency
+// histogram. Evaluations are in-memory, so they concentrate below 10ms.
+var latencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25}
+
+// Registry collects evaluation metrics and reports stream subscribers.
+type Registry struct {
+	registry    *prometheus.Registry
+	evaluations *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+}
+
+// NewRegistry creates a registry with the evaluation metrics and the Go
+// runtime and process collectors registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		evaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "togglerino_evaluations_total",
+			Help: "Flag evaluations served, by project, environment and reason.",
+		}, []string{"project", "env", "reason"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "togglerino_evaluation_duration_seconds",
+			Help:    "Time spent evaluating flags per evaluate request.",
+			Buckets: latencyBuckets,
+		}, []string{"endpoint"}),
+	}
+	r.registry.MustRegister(
+		r.evaluations,
+		r.latency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// SetHub makes the registry report the hub's current subscriber count. It must
+// be called at most once.
+func (r *Registry) SetHub(hub *stream.Hub) {
+	r.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "togglerino_stream_subscribers",
+		Help: "Connected SSE stream subscribers.",
+	}, func() float64 {
+		var total int
+		for _, s := range hub.Scopes() {
+			total += hub.SubscriberCount(s.ProjectKey, s.EnvKey)
+		}
+		return float64(total)
+	}))
+}
+
+// CountEvaluation counts one flag evaluation served with the given reason.
+func (r *Registry) CountEvaluation(projectKey, envKey, reason string) {
+	r.evaluations.WithLabelValues(projectKey, envKey, reason).Inc()
+}
+
+// ObserveLatency records how long an evaluate request spent evaluating flags.
+// endpoint names the request kind, e.g. "all" or "single".
+func (r *Registry) ObserveLatency(endpoint string, d time.Duration) {
+	r.latency.WithLabelValues(endpoint).Observe(d.Seconds())
+}
+
+// Handler serves the metrics, as for GET /metrics.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
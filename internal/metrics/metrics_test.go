@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/stream"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestRegistry_Evaluations(t *testing.T) {
+	r := NewRegistry()
+	r.CountEvaluation("shop", "production", "rule_match")
+	r.CountEvaluation("shop", "production", "rule_match")
+	r.CountEvaluation("shop", "staging", "default")
+
+	body := scrape(t, r)
+	for _, want := range []string{
+		"# TYPE togglerino_evaluations_total counter\n",
+		`togglerino_evaluations_total{env="production",project="shop",reason="rule_match"} 2` + "\n",
+		`togglerino_evaluations_total{env="staging",project="shop",reason="default"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestRegistry_LatencyHistogramIsCumulative(t *testing.T) {
+	r := NewRegistry()
+	r.ObserveLatency("single", 200*time.Microsecond)
+	r.ObserveLatency("single", 3*time.Millisecond)
+	r.ObserveLatency("single", time.Second)
+
+	body := scrape(t, r)
+	for _, want := range []string{
+		`togglerino_evaluation_duration_seconds_bucket{endpoint="single",le="0.00025"} 1` + "\n",
+		`togglerino_evaluation_duration_seconds_bucket{endpoint="single",le="0.005"} 2` + "\n",
+		`togglerino_evaluation_duration_seconds_bucket{endpoint="single",le="0.25"} 2` + "\n",
+		`togglerino_evaluation_duration_seconds_bucket{endpoint="single",le="+Inf"} 3` + "\n",
+		`togglerino_evaluation_duration_seconds_count{endpoint="single"} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestRegistry_StreamSubscribers(t *testing.T) {
+	hub := stream.NewHub()
+	a, _ := hub.Subscribe("shop", "production")
+	b, _ := hub.Subscribe("shop", "production")
+	c, _ := hub.Subscribe("shop", "staging")
+	defer hub.Unsubscribe("shop", "production", a)
+	defer hub.Unsubscribe("shop", "production", b)
+	defer hub.Unsubscribe("shop", "staging", c)
+
+	r := NewRegistry()
+	r.SetHub(hub)
+
+	body := scrape(t, r)
+	want := "togglerino_stream_subscribers 3\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("missing %q in:\n%s", want, body)
+	}
+}
+
+func TestRegistry_IncludesRuntimeMetrics(t *testing.T) {
+	if body := scrape(t, NewRegistry()); !strings.Contains(body, "go_goroutines ") {
+		t.Errorf("expected Go runtime metrics, got:\n%s", body)
+	}
+}
@@ -14,5 +14,11 @@ type AuditEntry struct {
 	EntityID   string          `json:"entity_id"`
 	OldValue   json.RawMessage `json:"old_value,omitempty"`
 	NewValue   json.RawMessage `json:"new_value,omitempty"`
-	CreatedAt  time.Time       `json:"created_at"`
+	// Diff is a field-level summary of what changed, recorded for flag
+	// config updates.
+	Diff json.RawMessage `json:"diff,omitempty"`
+	// RequestID is the ID of the request that made the change, matching
+	// the request_id field of the request log line.
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
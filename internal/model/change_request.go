@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ChangeRequestStatus is the review state of a change request.
+type ChangeRequestStatus string
+
+const (
+	ChangeRequestPending  ChangeRequestStatus = "pending"
+	ChangeRequestApproved ChangeRequestStatus = "approved"
+	ChangeRequestRejected ChangeRequestStatus = "rejected"
+)
+
+// ChangeRequest is a flag environment config update held for review because
+// the environment requires approval. Approving it applies Proposed.
+type ChangeRequest struct {
+	ID             string         `json:"id"`
+	ProjectID      string         `json:"project_id"`
+	FlagID         string         `json:"flag_id"`
+	FlagKey        string         `json:"flag_key"`
+	EnvironmentID  string         `json:"environment_id"`
+	EnvironmentKey string         `json:"environment_key"`
+	Proposed       ProposedConfig `json:"proposed"`
+	// Previous is the config when the request was made, nil if the flag was
+	// unconfigured, so reviewers can see the diff.
+	Previous    *FlagEnvironmentConfig `json:"previous"`
+	Status      ChangeRequestStatus    `json:"status"`
+	RequestedBy string                 `json:"requested_by"`
+	ReviewedBy  *string                `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time             `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// ProposedConfig is the body of a flag environment config update.
+type ProposedConfig struct {
+	Enabled         bool            `json:"enabled"`
+	DefaultVariant  string          `json:"default_variant"`
+	Variants        json.RawMessage `json:"variants"`
+	TargetingRules  json.RawMessage `json:"targeting_rules"`
+	Prerequisites   json.RawMessage `json:"prerequisites"`
+	DefaultRollout  json.RawMessage `json:"default_rollout,omitempty"`
+	IncludedUsers   []string        `json:"included_users,omitempty"`
+	ExcludedUsers   []string        `json:"excluded_users,omitempty"`
+	IncludedVariant string          `json:"included_variant,omitempty"`
+}
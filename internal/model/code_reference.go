@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// CodeReference is a location in source code where a CI scanner found a
+// flag key. A flag has at most one reference per repo, file and line.
+type CodeReference struct {
+	ID     string `json:"id"`
+	FlagID string `json:"flag_id"`
+	Repo   string `json:"repo"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	// SHA is the commit the reference was last seen at.
+	SHA       string    `json:"sha"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
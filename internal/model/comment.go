@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// FlagComment is a note left by a user on a flag's discussion thread.
+type FlagComment struct {
+	ID        string    `json:"id"`
+	FlagID    string    `json:"flag_id"`
+	UserID    *string   `json:"user_id,omitempty"`
+	UserEmail string    `json:"user_email"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// FieldChange records the old and new value of a single config field.
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// RuleChange records a targeting rule that was added, removed or edited at a
+// position in the rule list. Old is nil for added rules, New for removed ones.
+type RuleChange struct {
+	Index int            `json:"index"`
+	Old   *TargetingRule `json:"old,omitempty"`
+	New   *TargetingRule `json:"new,omitempty"`
+}
+
+// ConfigDiff is a field-level summary of a flag environment config update.
+// Fields that did not change are omitted when encoded.
+type ConfigDiff struct {
+	Enabled         *FieldChange `json:"enabled,omitempty"`
+	DefaultVariant  *FieldChange `json:"default_variant,omitempty"`
+	VariantsAdded   []string     `json:"variants_added,omitempty"`
+	VariantsRemoved []string     `json:"variants_removed,omitempty"`
+	VariantsChanged []string     `json:"variants_changed,omitempty"`
+	RulesChanged    []RuleChange `json:"rules_changed,omitempty"`
+	Prerequisites   *FieldChange `json:"prerequisites,omitempty"`
+	DefaultRollout  *FieldChange `json:"default_rollout,omitempty"`
+	IncludedUsers   *FieldChange `json:"included_users,omitempty"`
+	ExcludedUsers   *FieldChange `json:"excluded_users,omitempty"`
+	IncludedVariant *FieldChange `json:"included_variant,omitempty"`
+}
+
+// DiffEnvironmentConfigs compares two configs of the same flag. A nil old
+// config is treated as an empty one. Variants are matched by key and rules by
+// position.
+func DiffEnvironmentConfigs(old, new *FlagEnvironmentConfig) ConfigDiff {
+	if old == nil {
+		old = &FlagEnvironmentConfig{}
+	}
+	var d ConfigDiff
+
+	if old.Enabled != new.Enabled {
+		d.Enabled = &FieldChange{Old: old.Enabled, New: new.Enabled}
+	}
+	if old.DefaultVariant != new.DefaultVariant {
+		d.DefaultVariant = &FieldChange{Old: old.DefaultVariant, New: new.DefaultVariant}
+	}
+
+	oldVariants := make(map[string]Variant, len(old.Variants))
+	for _, v := range old.Variants {
+		oldVariants[v.Key] = v
+	}
+	newKeys := make(map[string]bool, len(new.Variants))
+	for _, v := range new.Variants {
+		newKeys[v.Key] = true
+		prev, ok := oldVariants[v.Key]
+		if !ok {
+			d.VariantsAdded = append(d.VariantsAdded, v.Key)
+		} else if !sameJSON(prev, v) {
+			d.VariantsChanged = append(d.VariantsChanged, v.Key)
+		}
+	}
+	for _, v := range old.Variants {
+		if !newKeys[v.Key] {
+			d.VariantsRemoved = append(d.VariantsRemoved, v.Key)
+		}
+	}
+
+	for i := 0; i < max(len(old.TargetingRules), len(new.TargetingRules)); i++ {
+		var before, after *TargetingRule
+		if i < len(old.TargetingRules) {
+			before = &old.TargetingRules[i]
+		}
+		if i < len(new.TargetingRules) {
+			after = &new.TargetingRules[i]
+		}
+		if before == nil || after == nil || !sameJSON(before, after) {
+			d.RulesChanged = append(d.RulesChanged, RuleChange{Index: i, Old: before, New: after})
+		}
+	}
+
+	if !sameJSON(nonNil(old.Prerequisites), nonNil(new.Prerequisites)) {
+		d.Prerequisites = &FieldChange{Old: nonNil(old.Prerequisites), New: nonNil(new.Prerequisites)}
+	}
+	if !sameJSON(nonNilWeights(old.DefaultRollout), nonNilWeights(new.DefaultRollout)) {
+		d.DefaultRollout = &FieldChange{Old: nonNilWeights(old.DefaultRollout), New: nonNilWeights(new.DefaultRollout)}
+	}
+	if !slices.Equal(old.IncludedUsers, new.IncludedUsers) {
+		d.IncludedUsers = &FieldChange{Old: nonNilUsers(old.IncludedUsers), New: nonNilUsers(new.IncludedUsers)}
+	}
+	if !slices.Equal(old.ExcludedUsers, new.ExcludedUsers) {
+		d.ExcludedUsers = &FieldChange{Old: nonNilUsers(old.ExcludedUsers), New: nonNilUsers(new.ExcludedUsers)}
+	}
+	if old.IncludedVariant != new.IncludedVariant {
+		d.IncludedVariant = &FieldChange{Old: old.IncludedVariant, New: new.IncludedVariant}
+	}
+
+	return d
+}
+
+func sameJSON(a, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+func nonNil(p []Prerequisite) []Prerequisite {
+	if p == nil {
+		return []Prerequisite{}
+	}
+	return p
+}
+
+func nonNilWeights(w []VariantWeight) []VariantWeight {
+	if w == nil {
+		return []VariantWeight{}
+	}
+	return w
+}
+
+func nonNilUsers(u []string) []string {
+	if u == nil {
+		return []string{}
+	}
+	return u
+}
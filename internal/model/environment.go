@@ -1,23 +1,114 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
 
 type Environment struct {
-	ID        string    `json:"id"`
-	ProjectID string    `json:"project_id"`
-	Key       string    `json:"key"`
-	Name      string    `json:"name"`
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	// SortOrder positions the environment in listings, lowest first.
+	SortOrder int       `json:"sort_order"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// EnvironmentTemplate describes an environment created along with a new project.
+type EnvironmentTemplate struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// DefaultEnvironmentTemplates are the environments created for a new project
+// when neither the server configuration nor the request specifies a list.
+var DefaultEnvironmentTemplates = []EnvironmentTemplate{
+	{Key: "development", Name: "Development"},
+	{Key: "staging", Name: "Staging"},
+	{Key: "production", Name: "Production"},
+}
+
+var environmentKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidEnvironmentKey reports whether key can be used as an environment key:
+// up to 64 letters, digits, '_', '.' or '-', starting with a letter or digit.
+func ValidEnvironmentKey(key string) bool {
+	return environmentKeyPattern.MatchString(key)
+}
+
+// ValidateEnvironmentTemplates checks that a template list is non-empty and
+// that every entry has a valid, unique key and a name.
+func ValidateEnvironmentTemplates(templates []EnvironmentTemplate) error {
+	if len(templates) == 0 {
+		return fmt.Errorf("at least one environment is required")
+	}
+	seen := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		if !ValidEnvironmentKey(t.Key) {
+			return fmt.Errorf("invalid environment key %q", t.Key)
+		}
+		if seen[t.Key] {
+			return fmt.Errorf("duplicate environment key %q", t.Key)
+		}
+		seen[t.Key] = true
+		if t.Name == "" {
+			return fmt.Errorf("environment %q has no name", t.Key)
+		}
+	}
+	return nil
+}
+
+// SDKKeyScope grants an SDK key access to one group of SDK routes.
+type SDKKeyScope string
+
+const (
+	// SDKKeyScopeEvaluate allows the evaluate endpoints.
+	SDKKeyScopeEvaluate SDKKeyScope = "evaluate"
+	// SDKKeyScopeStream allows the SSE stream of flag changes.
+	SDKKeyScopeStream SDKKeyScope = "stream"
+	// SDKKeyScopeServer allows fetching the full rule set for local
+	// evaluation, which only server-side SDKs should see.
+	SDKKeyScopeServer SDKKeyScope = "server"
+)
+
+// AllSDKKeyScopes are the scopes of keys created without a list, and of
+// keys that predate scopes.
+var AllSDKKeyScopes = []SDKKeyScope{SDKKeyScopeEvaluate, SDKKeyScopeStream, SDKKeyScopeServer}
+
+// ValidSDKKeyScopes is the set of known SDK key scopes.
+var ValidSDKKeyScopes = map[SDKKeyScope]bool{
+	SDKKeyScopeEvaluate: true,
+	SDKKeyScopeStream:   true,
+	SDKKeyScopeServer:   true,
+}
+
 type SDKKey struct {
-	ID             string    `json:"id"`
-	Key            string    `json:"key"`
-	EnvironmentID  string    `json:"environment_id"`
-	Name           string    `json:"name"`
-	Revoked        bool      `json:"revoked"`
-	CreatedAt      time.Time `json:"created_at"`
-	ProjectID      string    `json:"project_id"`
-	ProjectKey     string    `json:"project_key"`
-	EnvironmentKey string    `json:"environment_key"`
+	ID            string `json:"id"`
+	Key           string `json:"key"`
+	EnvironmentID string `json:"environment_id"`
+	Name          string `json:"name"`
+	Revoked       bool   `json:"revoked"`
+	// Scopes lists the SDK routes the key may call.
+	Scopes    []SDKKeyScope `json:"scopes"`
+	CreatedAt time.Time     `json:"created_at"`
+	// LastUsedAt is when the key last authenticated a request, recorded to
+	// within a minute. Nil if it has never been used.
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// ExpiresAt is when a rotated-out key stops authenticating. Nil for keys
+	// that have not been rotated.
+	ExpiresAt *time.Time `json:"expires_at"`
+	// RotatedFromID is the key this one replaced, if it was issued by a
+	// rotation.
+	RotatedFromID  *string `json:"rotated_from_id"`
+	ProjectID      string  `json:"project_id"`
+	ProjectKey     string  `json:"project_key"`
+	EnvironmentKey string  `json:"environment_key"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *SDKKey) HasScope(scope SDKKeyScope) bool {
+	return slices.Contains(k.Scopes, scope)
 }
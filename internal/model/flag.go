@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -37,19 +38,31 @@ const (
 )
 
 type Flag struct {
-	ID                       string          `json:"id"`
-	ProjectID                string          `json:"project_id"`
-	Key                      string          `json:"key"`
-	Name                     string          `json:"name"`
-	Description              string          `json:"description"`
-	ValueType                ValueType       `json:"value_type"`
-	FlagType                 FlagType        `json:"flag_type"`
-	DefaultValue             json.RawMessage `json:"default_value"`
-	Tags                     []string        `json:"tags"`
+	ID                string          `json:"id"`
+	ProjectID         string          `json:"project_id"`
+	Key               string          `json:"key"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	ValueType         ValueType       `json:"value_type"`
+	FlagType          FlagType        `json:"flag_type"`
+	DefaultValue      json.RawMessage `json:"default_value"`
+	Tags              []string        `json:"tags"`
+	RolloutStages     []string        `json:"rollout_stages"`
+	PollTTLSeconds    *int            `json:"poll_ttl_seconds"`
+	RequireIdentifier bool            `json:"require_identifier"`
+	// JSONSchema, for JSON flags, constrains the default value and variant
+	// values. Nil leaves them unconstrained.
+	JSONSchema               json.RawMessage `json:"json_schema"`
 	LifecycleStatus          LifecycleStatus `json:"lifecycle_status"`
 	LifecycleStatusChangedAt *time.Time      `json:"lifecycle_status_changed_at"`
-	CreatedAt                time.Time       `json:"created_at"`
-	UpdatedAt                time.Time       `json:"updated_at"`
+	// LastEvaluatedAt is when an SDK was last served the flag, recorded to
+	// within an hour. Nil if it has never been evaluated.
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at"`
+	// LastModifiedBy is the ID of the user who last changed the flag or one
+	// of its environment configs. Nil if the change was not made by a user.
+	LastModifiedBy *string   `json:"last_modified_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type FlagEnvironmentConfig struct {
@@ -60,18 +73,119 @@ type FlagEnvironmentConfig struct {
 	DefaultVariant string          `json:"default_variant"`
 	Variants       []Variant       `json:"variants"`
 	TargetingRules []TargetingRule `json:"targeting_rules"`
-	UpdatedAt      time.Time       `json:"updated_at"`
+	// Prerequisites must all be met, in the same environment, before the
+	// targeting rules are evaluated.
+	Prerequisites []Prerequisite `json:"prerequisites"`
+	// DefaultRollout splits contexts that match no targeting rule across
+	// weighted variants. When empty they get DefaultVariant.
+	DefaultRollout []VariantWeight `json:"default_rollout"`
+	// IncludedUsers are served IncludedVariant, or DefaultVariant when it is
+	// empty, and ExcludedUsers the flag's default value, ahead of the
+	// targeting rules. Both match on the context's user ID.
+	IncludedUsers   []string  `json:"included_users"`
+	ExcludedUsers   []string  `json:"excluded_users"`
+	IncludedVariant string    `json:"included_variant"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	// UserOverrides maps user IDs to a forced variant key. It is populated by
+	// the evaluation cache and managed through the user override endpoints.
+	UserOverrides map[string]string `json:"-"`
+}
+
+// Prerequisite requires another flag in the same environment to serve Variant.
+type Prerequisite struct {
+	FlagKey string `json:"flag_key"`
+	Variant string `json:"variant"`
+}
+
+// FlagDependent is a flag whose config in one environment lists another
+// flag as a prerequisite.
+type FlagDependent struct {
+	FlagKey         string          `json:"flag_key"`
+	FlagName        string          `json:"flag_name"`
+	LifecycleStatus LifecycleStatus `json:"lifecycle_status"`
+	EnvironmentKey  string          `json:"environment_key"`
+	// Enabled is whether the dependent flag is enabled in that environment.
+	Enabled bool `json:"enabled"`
+	// Variant is the variant the prerequisite requires.
+	Variant string `json:"variant"`
 }
 
 type Variant struct {
 	Key   string          `json:"key"`
 	Value json.RawMessage `json:"value"`
+	// Localized holds optional per-locale overrides of Value, keyed by locale
+	// (e.g. "fr", "pt-BR"). Locales without an entry serve Value.
+	Localized map[string]json.RawMessage `json:"localized,omitempty"`
 }
 
 type TargetingRule struct {
-	Conditions        []Condition `json:"conditions"`
-	Variant           string      `json:"variant"`
-	PercentageRollout *int        `json:"percentage_rollout,omitempty"`
+	// Description optionally explains the rule; it is reported in evaluation
+	// results when the rule matches.
+	Description string      `json:"description,omitempty"`
+	Conditions  []Condition `json:"conditions"`
+	// Group optionally adds nested AND/OR logic; a rule matches when all of
+	// Conditions and the group match.
+	Group *ConditionGroup `json:"group,omitempty"`
+	// Negate inverts the match, so the rule targets everyone its conditions
+	// do not match. Any percentage rollout applies after the inversion.
+	Negate            bool   `json:"negate,omitempty"`
+	Variant           string `json:"variant"`
+	PercentageRollout *int   `json:"percentage_rollout,omitempty"`
+	// VariantWeights splits matching users across several variants instead of
+	// serving Variant. Weights must sum to 100.
+	VariantWeights []VariantWeight `json:"variant_weights,omitempty"`
+	// BucketBy names a context attribute whose value is hashed for the
+	// rule's rollout instead of the user ID, e.g. "company_id" so a whole
+	// organization gets the same variant. Missing or empty values fall back
+	// to the user ID.
+	BucketBy string `json:"bucket_by,omitempty"`
+	// Salt is mixed into the rollout hash. Changing it reshuffles which users
+	// fall into the rollout and which arm they get; empty keeps the unsalted
+	// assignment.
+	Salt string `json:"salt,omitempty"`
+}
+
+// ConditionLists returns the rule's conditions followed by those of every
+// group, depth first. The slices share the rule's storage, so conditions can
+// be rewritten in place.
+func (r *TargetingRule) ConditionLists() [][]Condition {
+	lists := [][]Condition{r.Conditions}
+	if r.Group != nil {
+		lists = r.Group.appendConditionLists(lists)
+	}
+	return lists
+}
+
+// GroupOperator combines the members of a ConditionGroup.
+type GroupOperator string
+
+const (
+	GroupAnd GroupOperator = "and"
+	GroupOr  GroupOperator = "or"
+)
+
+// ConditionGroup is a set of conditions and nested groups combined with one
+// operator: an "and" group matches when every member matches, an "or" group
+// when any does.
+type ConditionGroup struct {
+	Operator   GroupOperator    `json:"operator"`
+	Conditions []Condition      `json:"conditions,omitempty"`
+	Groups     []ConditionGroup `json:"groups,omitempty"`
+}
+
+func (g *ConditionGroup) appendConditionLists(lists [][]Condition) [][]Condition {
+	lists = append(lists, g.Conditions)
+	for i := range g.Groups {
+		lists = g.Groups[i].appendConditionLists(lists)
+	}
+	return lists
+}
+
+// VariantWeight is one arm of a weighted split: the share of users (0-100)
+// that receive Variant.
+type VariantWeight struct {
+	Variant string `json:"variant"`
+	Weight  int    `json:"weight"`
 }
 
 type Condition struct {
@@ -98,8 +212,51 @@ const (
 	OpExists      Operator = "exists"
 	OpNotExists   Operator = "not_exists"
 	OpMatches     Operator = "matches"
+	// OpInSegment matches when all conditions of the segment whose key is
+	// the condition value match; the attribute is ignored.
+	OpInSegment Operator = "in_segment"
+	// Case-insensitive variants lowercase both sides before comparing.
+	OpEqualsCI     Operator = "equals_ci"
+	OpInCI         Operator = "in_ci"
+	OpContainsCI   Operator = "contains_ci"
+	OpStartsWithCI Operator = "starts_with_ci"
+	OpEndsWithCI   Operator = "ends_with_ci"
+	// Time operators compare RFC3339 timestamps or Unix epoch seconds.
+	// OpWithinLast takes a duration (e.g. "168h") and matches attributes
+	// within that window before the current time.
+	OpBefore     Operator = "before"
+	OpAfter      Operator = "after"
+	OpWithinLast Operator = "within_last"
 )
 
+// ValidOperators is the set of all valid condition operators.
+var ValidOperators = map[Operator]bool{
+	OpEquals:       true,
+	OpNotEquals:    true,
+	OpContains:     true,
+	OpNotContains:  true,
+	OpStartsWith:   true,
+	OpEndsWith:     true,
+	OpGreaterThan:  true,
+	OpLessThan:     true,
+	OpGTE:          true,
+	OpLTE:          true,
+	OpIn:           true,
+	OpNotIn:        true,
+	OpExists:       true,
+	OpNotExists:    true,
+	OpMatches:      true,
+	OpInSegment:    true,
+	OpEqualsCI:     true,
+	OpInCI:         true,
+	OpContainsCI:   true,
+	OpStartsWithCI: true,
+	OpEndsWithCI:   true,
+	OpBefore:       true,
+	OpAfter:        true,
+	OpWithinLast:   true,
+}
+
 // ValidValueTypes is the set of all valid value types.
 var ValidValueTypes = map[ValueType]bool{
 	ValueTypeBoolean: true,
@@ -117,8 +274,59 @@ var ValidFlagTypes = map[FlagType]bool{
 	FlagTypePermission:  true,
 }
 
+// ZeroValue returns the default value used for a new flag of the given value
+// type when none is supplied.
+func ZeroValue(valueType ValueType) json.RawMessage {
+	switch valueType {
+	case ValueTypeString:
+		return json.RawMessage(`""`)
+	case ValueTypeNumber:
+		return json.RawMessage(`0`)
+	case ValueTypeJSON:
+		return json.RawMessage(`{}`)
+	default:
+		return json.RawMessage(`false`)
+	}
+}
+
+// ValidateValue checks that a raw JSON value matches the given value type.
+func ValidateValue(valueType ValueType, raw json.RawMessage) error {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return errors.New("value is not valid JSON")
+	}
+	switch valueType {
+	case ValueTypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return errors.New("value must be a boolean")
+		}
+	case ValueTypeString:
+		if _, ok := v.(string); !ok {
+			return errors.New("value must be a string")
+		}
+	case ValueTypeNumber:
+		if _, ok := v.(float64); !ok {
+			return errors.New("value must be a number")
+		}
+	}
+	return nil
+}
+
+// ValidateFlagValue checks a raw value against a flag's value type and, for
+// JSON flags with a schema, against the schema.
+func ValidateFlagValue(valueType ValueType, schema, raw json.RawMessage) error {
+	if err := ValidateValue(valueType, raw); err != nil {
+		return err
+	}
+	if valueType == ValueTypeJSON && schema != nil {
+		return ValidateAgainstSchema(schema, raw)
+	}
+	return nil
+}
+
 type EvaluationContext struct {
 	UserID     string         `json:"user_id"`
+	Locale     string         `json:"locale,omitempty"`
 	Attributes map[string]any `json:"attributes"`
 }
 
@@ -126,11 +334,31 @@ type EvaluationResult struct {
 	Value   any    `json:"value"`
 	Variant string `json:"variant"`
 	Reason  string `json:"reason"`
+	// PollTTLSeconds hints to polling SDKs how soon this flag should be re-fetched.
+	PollTTLSeconds *int `json:"poll_ttl_seconds,omitempty"`
+	// RuleIndex is the index of the targeting rule that matched, set only
+	// for reason "rule_match".
+	RuleIndex *int `json:"rule_index,omitempty"`
+	// RuleDescription is the matched rule's description, if it has one.
+	RuleDescription string `json:"rule_description,omitempty"`
+}
+
+// ConditionResult is the outcome of one targeting condition evaluated on its own.
+type ConditionResult struct {
+	Attribute string `json:"attribute"`
+	Operator  string `json:"operator"`
+	Value     any    `json:"value"`
+	// Actual is the context's value for Attribute, nil if it was not sent.
+	Actual any  `json:"actual"`
+	Passed bool `json:"passed"`
 }
 
 type ContextAttribute struct {
-	ID         string    `json:"id"`
-	ProjectID  string    `json:"project_id"`
-	Name       string    `json:"name"`
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	// Values holds recently seen distinct values, newest first, for
+	// suggestions in the targeting rule builder.
+	Values     []string  `json:"values"`
 	LastSeenAt time.Time `json:"last_seen_at"`
 }
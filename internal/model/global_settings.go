@@ -0,0 +1,77 @@
+package model
+
+import "time"
+
+// Built-in defaults used when a global setting is unset.
+const (
+	DefaultGracePeriodDays        = 14
+	DefaultAuthRateLimitPerMinute = 10
+	DefaultMaxTargetingRules      = 100
+	DefaultMaxConditionsPerRule   = 50
+)
+
+// GlobalSettings holds instance-wide defaults that admins can change at
+// runtime. Nil fields fall back to the built-in defaults.
+type GlobalSettings struct {
+	// FlagLifetimes overrides DefaultFlagLifetimes for projects that don't set
+	// their own. A present key with a nil value makes that flag type permanent.
+	FlagLifetimes map[FlagType]*int `json:"flag_lifetimes"`
+	// GracePeriodDays is how long a flag stays potentially stale before it is
+	// marked stale.
+	GracePeriodDays        *int      `json:"grace_period_days"`
+	AuthRateLimitPerMinute *int      `json:"auth_rate_limit_per_minute"`
+	MaxTargetingRules      *int      `json:"max_targeting_rules"`
+	MaxConditionsPerRule   *int      `json:"max_conditions_per_rule"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// Lifetime returns the default expected lifetime in days for a flag type.
+// It is safe to call on a nil *GlobalSettings.
+func (gs *GlobalSettings) Lifetime(ft FlagType) *int {
+	if gs != nil && gs.FlagLifetimes != nil {
+		if v, ok := gs.FlagLifetimes[ft]; ok {
+			return v
+		}
+	}
+	return DefaultFlagLifetimes()[ft]
+}
+
+// GracePeriod returns the potentially-stale grace period.
+func (gs *GlobalSettings) GracePeriod() time.Duration {
+	days := DefaultGracePeriodDays
+	if gs != nil {
+		days = intOr(gs.GracePeriodDays, days)
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// AuthRateLimit returns the allowed auth requests per minute per client IP.
+func (gs *GlobalSettings) AuthRateLimit() int {
+	if gs == nil {
+		return DefaultAuthRateLimitPerMinute
+	}
+	return intOr(gs.AuthRateLimitPerMinute, DefaultAuthRateLimitPerMinute)
+}
+
+// TargetingRuleLimit returns the maximum number of rules per environment config.
+func (gs *GlobalSettings) TargetingRuleLimit() int {
+	if gs == nil {
+		return DefaultMaxTargetingRules
+	}
+	return intOr(gs.MaxTargetingRules, DefaultMaxTargetingRules)
+}
+
+// ConditionLimit returns the maximum number of conditions per targeting rule.
+func (gs *GlobalSettings) ConditionLimit() int {
+	if gs == nil {
+		return DefaultMaxConditionsPerRule
+	}
+	return intOr(gs.MaxConditionsPerRule, DefaultMaxConditionsPerRule)
+}
+
+func intOr(v *int, fallback int) int {
+	if v != nil {
+		return *v
+	}
+	return fallback
+}
@@ -0,0 +1,293 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaError reports a value that does not match a flag's JSON Schema.
+type SchemaError struct {
+	// SchemaPath is a JSON pointer to the keyword that failed, e.g.
+	// "#/properties/theme/required".
+	SchemaPath string
+	// InstancePath is a JSON pointer to the offending part of the value,
+	// empty for the value itself.
+	InstancePath string
+	Message      string
+}
+
+func (e *SchemaError) Error() string {
+	if e.InstancePath == "" {
+		return fmt.Sprintf("%s (schema path %s)", e.Message, e.SchemaPath)
+	}
+	return fmt.Sprintf("%s at %s (schema path %s)", e.Message, e.InstancePath, e.SchemaPath)
+}
+
+// CheckJSONSchema checks that schema is a JSON Schema this package can
+// apply. The supported keywords are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum and maximum; other keywords such as title are ignored.
+func CheckJSONSchema(schema json.RawMessage) error {
+	var s any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return errors.New("schema is not valid JSON")
+	}
+	if _, ok := s.(map[string]any); !ok {
+		return errors.New("schema must be a JSON object")
+	}
+	return checkSchemaNode(s, "#")
+}
+
+func checkSchemaNode(node any, path string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	s, ok := node.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or boolean", path)
+	}
+	if t, ok := s["type"]; ok {
+		types, ok := schemaTypes(t)
+		if !ok {
+			return fmt.Errorf("%s/type: must be a type name or an array of type names", path)
+		}
+		for _, name := range types {
+			if !slices.Contains([]string{"null", "boolean", "object", "array", "number", "integer", "string"}, name) {
+				return fmt.Errorf("%s/type: unknown type %q", path, name)
+			}
+		}
+	}
+	if e, ok := s["enum"]; ok {
+		if _, ok := e.([]any); !ok {
+			return fmt.Errorf("%s/enum: must be an array", path)
+		}
+	}
+	if r, ok := s["required"]; ok {
+		names, ok := r.([]any)
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array of property names", path)
+		}
+		for _, n := range names {
+			if _, ok := n.(string); !ok {
+				return fmt.Errorf("%s/required: must be an array of property names", path)
+			}
+		}
+	}
+	if p, ok := s["properties"]; ok {
+		props, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s/properties: must be an object", path)
+		}
+		for name, sub := range props {
+			if err := checkSchemaNode(sub, path+"/properties/"+escapePointer(name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, kw := range []string{"additionalProperties", "items"} {
+		if sub, ok := s[kw]; ok {
+			if err := checkSchemaNode(sub, path+"/"+kw); err != nil {
+				return err
+			}
+		}
+	}
+	for _, kw := range []string{"minItems", "maxItems", "minLength", "maxLength"} {
+		if n, ok := s[kw]; ok {
+			if f, ok := n.(float64); !ok || f < 0 || f != math.Trunc(f) {
+				return fmt.Errorf("%s/%s: must be a non-negative integer", path, kw)
+			}
+		}
+	}
+	for _, kw := range []string{"minimum", "maximum"} {
+		if n, ok := s[kw]; ok {
+			if _, ok := n.(float64); !ok {
+				return fmt.Errorf("%s/%s: must be a number", path, kw)
+			}
+		}
+	}
+	if p, ok := s["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: must be a string", path)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s/pattern: invalid regular expression: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// ValidateAgainstSchema checks a raw JSON value against a schema accepted by
+// CheckJSONSchema. A mismatch is returned as a *SchemaError.
+func ValidateAgainstSchema(schema, raw json.RawMessage) error {
+	var s, v any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return errors.New("schema is not valid JSON")
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return errors.New("value is not valid JSON")
+	}
+	if err := validateSchemaNode(s, v, "#", ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateSchemaNode(node, v any, path, at string) *SchemaError {
+	fail := func(keyword, format string, args ...any) *SchemaError {
+		return &SchemaError{SchemaPath: path + "/" + keyword, InstancePath: at, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if b, ok := node.(bool); ok {
+		if !b {
+			return &SchemaError{SchemaPath: path, InstancePath: at, Message: "no value is allowed"}
+		}
+		return nil
+	}
+	s, _ := node.(map[string]any)
+
+	if t, ok := s["type"]; ok {
+		types, _ := schemaTypes(t)
+		if !slices.ContainsFunc(types, func(name string) bool { return matchesType(name, v) }) {
+			return fail("type", "must be of type %s", strings.Join(types, " or "))
+		}
+	}
+	if e, ok := s["enum"].([]any); ok {
+		if !slices.ContainsFunc(e, func(allowed any) bool { return jsonEqual(allowed, v) }) {
+			return fail("enum", "must be one of the allowed values")
+		}
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, v) {
+		return fail("const", "must equal the constant value")
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if required, ok := s["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					return fail("required", "required property %q is missing", name)
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propAt := at + "/" + escapePointer(name)
+			if sub, ok := props[name]; ok {
+				if err := validateSchemaNode(sub, v[name], path+"/properties/"+escapePointer(name), propAt); err != nil {
+					return err
+				}
+			} else if sub, ok := s["additionalProperties"]; ok {
+				if err := validateSchemaNode(sub, v[name], path+"/additionalProperties", propAt); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if n, ok := s["minItems"].(float64); ok && float64(len(v)) < n {
+			return fail("minItems", "must have at least %v items", n)
+		}
+		if n, ok := s["maxItems"].(float64); ok && float64(len(v)) > n {
+			return fail("maxItems", "must have at most %v items", n)
+		}
+		if sub, ok := s["items"]; ok {
+			for i, item := range v {
+				if err := validateSchemaNode(sub, item, path+"/items", at+"/"+strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := s["minLength"].(float64); ok && length < n {
+			return fail("minLength", "must be at least %v characters", n)
+		}
+		if n, ok := s["maxLength"].(float64); ok && length > n {
+			return fail("maxLength", "must be at most %v characters", n)
+		}
+		if p, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(v) {
+				return fail("pattern", "must match pattern %q", p)
+			}
+		}
+	case float64:
+		if n, ok := s["minimum"].(float64); ok && v < n {
+			return fail("minimum", "must be at least %v", n)
+		}
+		if n, ok := s["maximum"].(float64); ok && v > n {
+			return fail("maximum", "must be at most %v", n)
+		}
+	}
+	return nil
+}
+
+// schemaTypes returns the type names of a "type" keyword, which may be a
+// single name or an array of names.
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		names := make([]string, 0, len(t))
+		for _, n := range t {
+			name, ok := n.(string)
+			if !ok {
+				return nil, false
+			}
+			names = append(names, name)
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+func matchesType(name string, v any) bool {
+	switch name {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "string":
+		_, ok := v.(string)
+		return ok
+	}
+	return false
+}
+
+// jsonEqual compares two decoded JSON values.
+func jsonEqual(a, b any) bool {
+	ea, _ := json.Marshal(a)
+	eb, _ := json.Marshal(b)
+	return string(ea) == string(eb)
+}
+
+// escapePointer escapes a property name for use in a JSON pointer.
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
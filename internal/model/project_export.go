@@ -0,0 +1,52 @@
+package model
+
+import "encoding/json"
+
+// ProjectExportVersion is the format version written by project exports.
+const ProjectExportVersion = 1
+
+// ProjectExport is a portable snapshot of a project's flag configuration,
+// used to move a project between togglerino instances. Environments are
+// referenced by key rather than ID so they can be remapped on import.
+type ProjectExport struct {
+	Version      int                   `json:"version"`
+	Project      ExportedProject       `json:"project"`
+	Environments []EnvironmentTemplate `json:"environments"`
+	Flags        []ExportedFlag        `json:"flags"`
+}
+
+type ExportedProject struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ExportedFlag is a flag's metadata together with its config in each
+// environment, keyed by environment key. Lifecycle state is not exported;
+// imported flags start active.
+type ExportedFlag struct {
+	Key               string                        `json:"key"`
+	Name              string                        `json:"name"`
+	Description       string                        `json:"description"`
+	ValueType         ValueType                     `json:"value_type"`
+	FlagType          FlagType                      `json:"flag_type"`
+	DefaultValue      json.RawMessage               `json:"default_value"`
+	Tags              []string                      `json:"tags"`
+	RolloutStages     []string                      `json:"rollout_stages"`
+	PollTTLSeconds    *int                          `json:"poll_ttl_seconds"`
+	RequireIdentifier bool                          `json:"require_identifier"`
+	JSONSchema        json.RawMessage               `json:"json_schema"`
+	Environments      map[string]ExportedFlagConfig `json:"environments"`
+}
+
+type ExportedFlagConfig struct {
+	Enabled         bool            `json:"enabled"`
+	DefaultVariant  string          `json:"default_variant"`
+	Variants        []Variant       `json:"variants"`
+	TargetingRules  []TargetingRule `json:"targeting_rules"`
+	Prerequisites   []Prerequisite  `json:"prerequisites"`
+	DefaultRollout  []VariantWeight `json:"default_rollout,omitempty"`
+	IncludedUsers   []string        `json:"included_users,omitempty"`
+	ExcludedUsers   []string        `json:"excluded_users,omitempty"`
+	IncludedVariant string          `json:"included_variant,omitempty"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// ProjectRole is a user's role within a single project. Global admins act as
+// project admins everywhere; other users without a membership are viewers.
+type ProjectRole string
+
+const (
+	ProjectRoleViewer ProjectRole = "viewer"
+	ProjectRoleEditor ProjectRole = "editor"
+	ProjectRoleAdmin  ProjectRole = "admin"
+)
+
+// projectRoleRank orders project roles from least to most privileged.
+var projectRoleRank = map[ProjectRole]int{
+	ProjectRoleViewer: 1,
+	ProjectRoleEditor: 2,
+	ProjectRoleAdmin:  3,
+}
+
+// Valid reports whether r is a known project role.
+func (r ProjectRole) Valid() bool {
+	_, ok := projectRoleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r grants everything min does.
+func (r ProjectRole) AtLeast(min ProjectRole) bool {
+	return projectRoleRank[r] >= projectRoleRank[min]
+}
+
+// ProjectMembership grants a user a role in a project.
+type ProjectMembership struct {
+	ProjectID string      `json:"project_id"`
+	UserID    string      `json:"user_id"`
+	Email     string      `json:"email"`
+	Role      ProjectRole `json:"role"`
+	CreatedAt time.Time   `json:"created_at"`
+}
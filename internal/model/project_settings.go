@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // DefaultFlagLifetimes returns the default expected lifetimes (in days) per flag type.
 // nil means permanent (never stale).
@@ -21,16 +24,46 @@ type ProjectSettings struct {
 	ID            string            `json:"id"`
 	ProjectID     string            `json:"project_id"`
 	FlagLifetimes map[FlagType]*int `json:"flag_lifetimes"`
-	UpdatedAt     time.Time         `json:"updated_at"`
+	// CaseInsensitiveFlagKeys makes single-flag evaluation match flag keys
+	// regardless of case (e.g. "Dark-Mode" resolves "dark-mode").
+	CaseInsensitiveFlagKeys bool `json:"case_insensitive_flag_keys"`
+	// RequireApprovalEnvironments lists environment keys whose flag config
+	// updates are held as change requests until another user approves them.
+	RequireApprovalEnvironments []string `json:"require_approval_environments"`
+	// AuditRetentionDays overrides the instance-wide audit log retention for
+	// the project; zero keeps its entries forever and nil uses the default.
+	AuditRetentionDays *int      `json:"audit_retention_days"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RequiresApproval reports whether flag config updates in envKey must go
+// through a change request.
+func (ps *ProjectSettings) RequiresApproval(envKey string) bool {
+	return ps != nil && slices.Contains(ps.RequireApprovalEnvironments, envKey)
+}
+
+// AuditRetention returns how long the project's audit entries are kept: the
+// project override if set, otherwise def. Zero or less keeps them forever.
+func (ps *ProjectSettings) AuditRetention(def time.Duration) time.Duration {
+	if ps != nil && ps.AuditRetentionDays != nil {
+		return time.Duration(*ps.AuditRetentionDays) * 24 * time.Hour
+	}
+	return def
 }
 
 // GetLifetime returns the expected lifetime in days for a flag type,
 // using the project setting if available, otherwise the global default.
 func (ps *ProjectSettings) GetLifetime(ft FlagType) *int {
+	return ps.GetLifetimeWithDefaults(ft, nil)
+}
+
+// GetLifetimeWithDefaults is like GetLifetime but falls back to the
+// instance-wide defaults in gs before the built-in ones.
+func (ps *ProjectSettings) GetLifetimeWithDefaults(ft FlagType, gs *GlobalSettings) *int {
 	if ps != nil && ps.FlagLifetimes != nil {
 		if v, ok := ps.FlagLifetimes[ft]; ok {
 			return v
 		}
 	}
-	return DefaultFlagLifetimes()[ft]
+	return gs.Lifetime(ft)
 }
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// RuleTemplate is a reusable set of targeting conditions with placeholders,
+// written as {{name}}, that are filled in when the template is instantiated.
+type RuleTemplate struct {
+	ID          string      `json:"id"`
+	ProjectID   string      `json:"project_id"`
+	Key         string      `json:"key"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  []string    `json:"parameters"`
+	Conditions  []Condition `json:"conditions"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+var (
+	// placeholderPattern matches a template placeholder such as {{domain}}.
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	// parameterNamePattern matches a valid template parameter name.
+	parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Placeholders returns the distinct parameter names referenced by the
+// template's conditions, in order of first use.
+func (t *RuleTemplate) Placeholders() []string {
+	var names []string
+	collect := func(s string) {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+			if !slices.Contains(names, m[1]) {
+				names = append(names, m[1])
+			}
+		}
+	}
+	for _, c := range t.Conditions {
+		collect(c.Attribute)
+		forEachString(c.Value, collect)
+	}
+	return names
+}
+
+// Instantiate returns the template's conditions with every placeholder
+// replaced by its parameter value. All declared parameters must be supplied,
+// and unknown parameters are rejected.
+func (t *RuleTemplate) Instantiate(params map[string]string) ([]Condition, error) {
+	for _, name := range t.Parameters {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("missing parameter %q", name)
+		}
+	}
+	for name := range params {
+		if !slices.Contains(t.Parameters, name) {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	replace := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			return params[placeholderPattern.FindStringSubmatch(m)[1]]
+		})
+	}
+
+	conditions := make([]Condition, len(t.Conditions))
+	for i, c := range t.Conditions {
+		conditions[i] = Condition{
+			Attribute: replace(c.Attribute),
+			Operator:  c.Operator,
+			Value:     substituteValue(c.Value, replace),
+		}
+	}
+	return conditions, nil
+}
+
+// substituteValue applies replace to a string value, or to each string
+// element of a list value. Other values are returned unchanged.
+func substituteValue(v any, replace func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return replace(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			if s, ok := item.(string); ok {
+				out[i] = replace(s)
+			} else {
+				out[i] = item
+			}
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// forEachString calls fn for a string value or each string element of a list value.
+func forEachString(v any, fn func(string)) {
+	switch v := v.(type) {
+	case string:
+		fn(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				fn(s)
+			}
+		}
+	}
+}
+
+// ValidParameterName reports whether name can be used as a template parameter.
+func ValidParameterName(name string) bool {
+	return parameterNamePattern.MatchString(name)
+}
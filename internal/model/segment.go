@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Segment is a named, reusable set of targeting conditions. A targeting rule
+// condition with the in_segment operator and the segment's key as its value
+// matches when every one of the segment's conditions matches.
+type Segment struct {
+	ID          string      `json:"id"`
+	ProjectID   string      `json:"project_id"`
+	Key         string      `json:"key"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Conditions  []Condition `json:"conditions"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
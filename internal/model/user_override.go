@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// UserOverride forces a flag to serve a specific variant to one user in one
+// environment, ahead of any targeting rules.
+type UserOverride struct {
+	FlagID         string    `json:"flag_id"`
+	FlagKey        string    `json:"flag_key"`
+	EnvironmentID  string    `json:"environment_id"`
+	EnvironmentKey string    `json:"environment_key"`
+	UserID         string    `json:"user_id"`
+	Variant        string    `json:"variant"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package model
+
+// VariantEvaluation identifies one variant of a flag served in one
+// environment, the unit variant evaluation counts are kept for.
+type VariantEvaluation struct {
+	FlagID        string
+	EnvironmentID string
+	Variant       string
+}
@@ -0,0 +1,65 @@
+package msgpack
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalUnmarshal_RoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	in := map[string]any{
+		"nil":    nil,
+		"true":   true,
+		"false":  false,
+		"small":  int64(7),
+		"neg":    int64(-5),
+		"int16":  int64(-1000),
+		"int64":  int64(1 << 40),
+		"float":  3.25,
+		"str":    "hello",
+		"long":   long,
+		"array":  []any{int64(1), "two", false},
+		"nested": map[string]any{"a": []any{}},
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	out, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip mismatch:\n got %#v\nwant %#v", out, in)
+	}
+}
+
+func TestMarshalJSONValue_UsesJSONFieldNames(t *testing.T) {
+	v := struct {
+		Value   any    `json:"value"`
+		Variant string `json:"variant"`
+		Omitted *int   `json:"omitted,omitempty"`
+	}{Value: 1.5, Variant: "on"}
+
+	data, err := MarshalJSONValue(v)
+	if err != nil {
+		t.Fatalf("MarshalJSONValue: %v", err)
+	}
+	out, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := map[string]any{"value": 1.5, "variant": "on"}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %#v, want %#v", out, want)
+	}
+}
+
+func TestUnmarshal_Truncated(t *testing.T) {
+	data, _ := Marshal("hello")
+	if _, err := Unmarshal(data[:3]); err != ErrTruncated {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package quota enforces per-environment monthly SDK evaluation quotas.
+// Evaluations are counted in memory and flushed to the store in batches.
+package quota
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Store is the interface for usage persistence needed by the tracker.
+type Store interface {
+	Increment(ctx context.Context, environmentID string, period time.Time, n int64) (int64, error)
+	ListByPeriod(ctx context.Context, period time.Time) (map[string]int64, error)
+	ListQuotas(ctx context.Context) (map[string]int64, error)
+}
+
+// Usage is an environment's evaluation usage for the current period.
+type Usage struct {
+	Period      time.Time `json:"period"`
+	Evaluations int64     `json:"evaluations"`
+	Rejected    int64     `json:"rejected"`
+	// Quota is nil when the environment is unlimited.
+	Quota *int64 `json:"monthly_quota"`
+	// ResetsAt is when the counter starts over.
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// Tracker counts evaluations per environment and reports whether an
+// environment has exhausted its monthly quota.
+type Tracker struct {
+	store    Store
+	interval time.Duration
+	now      func() time.Time // injectable for testing
+
+	mu     sync.Mutex
+	period time.Time
+	// used holds the persisted totals for period as of the last flush.
+	used map[string]int64
+	// pending holds evaluations for period not yet flushed.
+	pending map[string]int64
+	// carry holds unflushed evaluations of earlier periods, after a rollover.
+	carry    map[time.Time]map[string]int64
+	rejected map[string]int64
+	quotas   map[string]int64
+}
+
+// NewTracker creates a tracker that flushes counts to store every interval.
+func NewTracker(store Store, interval time.Duration) *Tracker {
+	t := &Tracker{store: store, interval: interval, now: time.Now}
+	t.reset(PeriodStart(t.now()))
+	t.carry = make(map[time.Time]map[string]int64)
+	return t
+}
+
+// PeriodStart returns the first instant of the UTC calendar month containing t.
+func PeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// reset starts a new period with empty counters. Must be called with t.mu held.
+func (t *Tracker) reset(period time.Time) {
+	t.period = period
+	t.used = make(map[string]int64)
+	t.pending = make(map[string]int64)
+	t.rejected = make(map[string]int64)
+}
+
+// rollover moves to the current period if the month has changed, keeping
+// unflushed counts of the old period so they are still persisted.
+// Must be called with t.mu held.
+func (t *Tracker) rollover() {
+	current := PeriodStart(t.now())
+	if current.Equal(t.period) {
+		return
+	}
+	if len(t.pending) > 0 {
+		t.carry[t.period] = t.pending
+	}
+	t.reset(current)
+}
+
+// Load reads quotas and the current period's usage from the store.
+func (t *Tracker) Load(ctx context.Context) error {
+	quotas, err := t.store.ListQuotas(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.rollover()
+	period := t.period
+	t.mu.Unlock()
+
+	used, err := t.store.ListByPeriod(ctx, period)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas = quotas
+	if t.period.Equal(period) {
+		t.used = used
+	}
+	return nil
+}
+
+// SetQuota updates an environment's quota in memory. A nil quota means unlimited.
+func (t *Tracker) SetQuota(environmentID string, quota *int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.quotas == nil {
+		t.quotas = make(map[string]int64)
+	}
+	if quota == nil {
+		delete(t.quotas, environmentID)
+		return
+	}
+	t.quotas[environmentID] = *quota
+}
+
+// Allow records n evaluations for an environment and reports whether they fit
+// within its quota. Rejected evaluations are not counted toward usage.
+func (t *Tracker) Allow(environmentID string, n int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	if quota, ok := t.quotas[environmentID]; ok {
+		if t.used[environmentID]+t.pending[environmentID]+n > quota {
+			if t.rejected[environmentID] == 0 {
+				slog.Warn("evaluation quota exceeded",
+					"environment_id", environmentID, "quota", quota, "period", t.period.Format("2006-01"))
+			}
+			t.rejected[environmentID] += n
+			return false
+		}
+	}
+	t.pending[environmentID] += n
+	return true
+}
+
+// Usage returns an environment's usage for the current period, including
+// evaluations not yet flushed.
+func (t *Tracker) Usage(environmentID string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+
+	u := Usage{
+		Period:      t.period,
+		Evaluations: t.used[environmentID] + t.pending[environmentID],
+		Rejected:    t.rejected[environmentID],
+		ResetsAt:    t.period.AddDate(0, 1, 0),
+	}
+	if quota, ok := t.quotas[environmentID]; ok {
+		u.Quota = &quota
+	}
+	return u
+}
+
+// Flush persists pending counts. Counts that fail to persist are kept and
+// retried on the next flush.
+func (t *Tracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	t.rollover()
+	batches := t.carry
+	batches[t.period] = t.pending
+	t.carry = make(map[time.Time]map[string]int64)
+	t.pending = make(map[string]int64)
+	period := t.period
+	t.mu.Unlock()
+
+	for p, counts := range batches {
+		for envID, n := range counts {
+			if n == 0 {
+				continue
+			}
+			total, err := t.store.Increment(ctx, envID, p, n)
+			t.mu.Lock()
+			switch {
+			case err != nil:
+				slog.Error("quota: failed to flush evaluation usage", "environment_id", envID, "error", err)
+				if p.Equal(t.period) {
+					t.pending[envID] += n
+				} else {
+					if t.carry[p] == nil {
+						t.carry[p] = make(map[string]int64)
+					}
+					t.carry[p][envID] += n
+				}
+			case p.Equal(period) && t.period.Equal(period):
+				// The store total includes other instances' evaluations.
+				t.used[envID] = total
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Run flushes pending counts and reloads quotas every interval until ctx is
+// cancelled. Call Flush during shutdown to persist the final counts.
+func (t *Tracker) Run(ctx context.Context) {
+	slog.Info("quota tracker started", "interval", t.interval)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("quota tracker stopped")
+			return
+		case <-ticker.C:
+			t.Flush(ctx)
+			if err := t.Load(ctx); err != nil {
+				slog.Error("quota: failed to reload quotas", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	mu     sync.Mutex
+	totals map[time.Time]map[string]int64
+	quotas map[string]int64
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{totals: make(map[time.Time]map[string]int64), quotas: make(map[string]int64)}
+}
+
+func (m *mockStore) Increment(_ context.Context, envID string, period time.Time, n int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totals[period] == nil {
+		m.totals[period] = make(map[string]int64)
+	}
+	m.totals[period][envID] += n
+	return m.totals[period][envID], nil
+}
+
+func (m *mockStore) ListByPeriod(_ context.Context, period time.Time) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]int64)
+	for k, v := range m.totals[period] {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *mockStore) ListQuotas(_ context.Context) (map[string]int64, error) {
+	return m.quotas, nil
+}
+
+func newTestTracker(store Store, now *time.Time) *Tracker {
+	t := NewTracker(store, time.Minute)
+	t.now = func() time.Time { return *now }
+	t.reset(PeriodStart(*now))
+	return t
+}
+
+func TestAllow_RejectsBeyondQuota(t *testing.T) {
+	store := newMockStore()
+	store.quotas["env-1"] = 3
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	tr := newTestTracker(store, &now)
+	if err := tr.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if !tr.Allow("env-1", 1) {
+			t.Fatalf("evaluation %d: expected allowed", i+1)
+		}
+	}
+	if tr.Allow("env-1", 1) {
+		t.Fatal("expected evaluation beyond quota to be rejected")
+	}
+	if !tr.Allow("env-2", 100) {
+		t.Error("expected environment without quota to be unlimited")
+	}
+
+	u := tr.Usage("env-1")
+	if u.Evaluations != 3 || u.Rejected != 1 {
+		t.Errorf("usage: got evaluations=%d rejected=%d, want 3 and 1", u.Evaluations, u.Rejected)
+	}
+}
+
+func TestFlush_PersistsBatchedCounts(t *testing.T) {
+	store := newMockStore()
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	tr := newTestTracker(store, &now)
+
+	tr.Allow("env-1", 10)
+	tr.Allow("env-1", 5)
+	tr.Flush(context.Background())
+
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := store.totals[march]["env-1"]; got != 15 {
+		t.Errorf("persisted total: got %d, want 15", got)
+	}
+	if got := tr.Usage("env-1").Evaluations; got != 15 {
+		t.Errorf("usage after flush: got %d, want 15", got)
+	}
+}
+
+func TestUsage_ResetsAtMonthBoundary(t *testing.T) {
+	store := newMockStore()
+	store.quotas["env-1"] = 10
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	tr := newTestTracker(store, &now)
+	if err := tr.Load(context.Background()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if !tr.Allow("env-1", 10) {
+		t.Fatal("expected evaluations within quota to be allowed")
+	}
+	if tr.Allow("env-1", 1) {
+		t.Fatal("expected quota to be exhausted")
+	}
+
+	now = time.Date(2026, 4, 1, 0, 30, 0, 0, time.UTC)
+	if !tr.Allow("env-1", 1) {
+		t.Fatal("expected quota to reset in the new month")
+	}
+
+	// The previous month's unflushed counts still land in the old period
+	tr.Flush(context.Background())
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	if got := store.totals[march]["env-1"]; got != 10 {
+		t.Errorf("march total: got %d, want 10", got)
+	}
+	if got := store.totals[april]["env-1"]; got != 1 {
+		t.Errorf("april total: got %d, want 1", got)
+	}
+	if u := tr.Usage("env-1"); !u.ResetsAt.Equal(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ResetsAt: got %v, want 2026-05-01", u.ResetsAt)
+	}
+}
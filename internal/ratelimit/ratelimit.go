@@ -1,24 +1,47 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
 )
 
+// sweepInterval is how often Run drops entries of idle clients.
+const sweepInterval = time.Minute
+
 type entry struct {
+	// Fixed window: requests counted in the window starting at windowStart.
 	count       int
 	windowStart time.Time
+	// Token bucket: tokens available as of lastRefill.
+	tokens     float64
+	lastRefill time.Time
 }
 
-// Limiter implements a fixed-window rate limiter keyed by client IP.
+// Limiter limits requests per client. It runs either as a fixed window
+// (New) or as a token bucket (NewTokenBucket), and keys clients by IP unless
+// a different key function is set.
 type Limiter struct {
-	mu            sync.Mutex
-	entries       map[string]*entry
+	mu      sync.Mutex
+	entries map[string]*entry
+	key     func(*http.Request) string
+	now     func() time.Time
+
+	// Fixed window mode.
 	limit         int
 	windowSeconds int
+
+	// Token bucket mode, used when rate is positive: rate tokens per second
+	// refill a bucket holding at most burst tokens.
+	rate  float64
+	burst int
 }
 
 // New creates a new Limiter that allows limit requests per windowSeconds
@@ -26,44 +49,163 @@ type Limiter struct {
 func New(limit, windowSeconds int) *Limiter {
 	return &Limiter{
 		entries:       make(map[string]*entry),
+		key:           KeyByIP,
+		now:           time.Now,
 		limit:         limit,
 		windowSeconds: windowSeconds,
 	}
 }
 
-// Middleware returns an http.Handler that enforces the rate limit before
-// passing the request to next. If the limit is exceeded, it responds with
-// HTTP 429 and a JSON error body.
-func (l *Limiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
-		if err != nil {
-			// If we can't parse the address, use RemoteAddr as-is.
-			ip = r.RemoteAddr
+// NewTokenBucket creates a Limiter that lets each client make rate requests
+// per second on average, with bursts of up to burst requests. Unlike a fixed
+// window it never admits a double burst across a window boundary.
+func NewTokenBucket(rate float64, burst int) *Limiter {
+	return &Limiter{
+		entries: make(map[string]*entry),
+		key:     KeyByIP,
+		now:     time.Now,
+		rate:    rate,
+		burst:   burst,
+	}
+}
+
+// SetKeyFunc changes how requests are grouped into clients. The default is
+// KeyByIP.
+func (l *Limiter) SetKeyFunc(key func(*http.Request) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.key = key
+}
+
+// SetLimit changes the number of requests allowed per window, or the burst
+// size of a token bucket. It applies to windows already in progress.
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate > 0 {
+		l.burst = limit
+		return
+	}
+	l.limit = limit
+}
+
+// SetRate changes the refill rate and burst size of a token bucket. Buckets
+// keep their current tokens, capped at the new burst on their next request.
+// It has no effect on a fixed window Limiter.
+func (l *Limiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 || rate <= 0 {
+		return
+	}
+	l.rate, l.burst = rate, burst
+}
+
+// KeyByIP keys requests by the client IP address.
+func KeyByIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// If we can't parse the address, use RemoteAddr as-is.
+		ip = r.RemoteAddr
+	}
+	return ip
+}
+
+// KeyBySDKKey keys requests by the SDK key authenticated by auth.SDKAuth,
+// so clients sharing an address are limited separately. Requests without an
+// SDK key fall back to KeyByIP.
+func KeyBySDKKey(r *http.Request) string {
+	if k := auth.SDKKeyFromContext(r.Context()); k != nil {
+		return "sdk:" + k.ID
+	}
+	return KeyByIP(r)
+}
+
+// allow records a request from key and reports whether it may proceed, the
+// requests left afterwards, and how long to wait when it may not.
+func (l *Limiter) allow(key string) (ok bool, remaining int, retryAfter time.Duration) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate > 0 {
+		e, exists := l.entries[key]
+		if !exists {
+			e = &entry{tokens: float64(l.burst), lastRefill: now}
+			l.entries[key] = e
 		}
+		e.tokens = math.Min(float64(l.burst), e.tokens+now.Sub(e.lastRefill).Seconds()*l.rate)
+		e.lastRefill = now
+		if e.tokens < 1 {
+			return false, 0, time.Duration((1 - e.tokens) / l.rate * float64(time.Second))
+		}
+		e.tokens--
+		return true, int(e.tokens), 0
+	}
 
-		l.mu.Lock()
+	window := time.Duration(l.windowSeconds) * time.Second
+	e, exists := l.entries[key]
+	if !exists || now.Sub(e.windowStart) >= window {
+		// New window: create or reset the entry.
+		l.entries[key] = &entry{count: 1, windowStart: now}
+		return true, max(l.limit-1, 0), 0
+	}
+	if e.count >= l.limit {
+		return false, 0, window - now.Sub(e.windowStart)
+	}
+	e.count++
+	return true, l.limit - e.count, 0
+}
 
-		now := time.Now()
-		window := time.Duration(l.windowSeconds) * time.Second
+// sweep drops entries that carry no state: token buckets refilled to burst
+// and fixed windows that have expired. A client coming back starts afresh,
+// exactly as it would with its entry kept.
+func (l *Limiter) sweep() {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-		e, exists := l.entries[ip]
-		if !exists || now.Sub(e.windowStart) >= window {
-			// New window: create or reset the entry.
-			l.entries[ip] = &entry{
-				count:       1,
-				windowStart: now,
+	window := time.Duration(l.windowSeconds) * time.Second
+	for key, e := range l.entries {
+		if l.rate > 0 {
+			if e.tokens+now.Sub(e.lastRefill).Seconds()*l.rate >= float64(l.burst) {
+				delete(l.entries, key)
 			}
-			l.mu.Unlock()
-			next.ServeHTTP(w, r)
+		} else if now.Sub(e.windowStart) >= window {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// Run sweeps idle clients every minute so the limiter's memory stays bounded
+// by its active clients. Blocks until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			l.sweep()
 		}
+	}
+}
 
-		if e.count >= l.limit {
-			remaining := window - now.Sub(e.windowStart)
-			retryAfter := int(remaining.Seconds()) + 1
-			l.mu.Unlock()
+// Middleware returns an http.Handler that enforces the rate limit before
+// passing the request to next. Every response carries X-RateLimit-Remaining.
+// If the limit is exceeded, it responds with HTTP 429 and a JSON error body.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		key := l.key(r)
+		l.mu.Unlock()
 
+		ok, remaining, wait := l.allow(key)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			retryAfter := int(wait.Seconds()) + 1
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
 			w.WriteHeader(http.StatusTooManyRequests)
@@ -71,9 +213,6 @@ func (l *Limiter) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		e.count++
-		l.mu.Unlock()
-
 		next.ServeHTTP(w, r)
 	})
 }
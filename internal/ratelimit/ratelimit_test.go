@@ -1,9 +1,14 @@
 package ratelimit
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/auth"
+	"github.com/togglerino/togglerino/internal/model"
 )
 
 func okHandler() http.Handler {
@@ -113,3 +118,158 @@ func TestRateLimiter_SeparateIPs(t *testing.T) {
 		t.Errorf("IP 2, request 2: expected status 429, got %d", rr4.Code)
 	}
 }
+
+func TestRateLimiter_SetLimit(t *testing.T) {
+	limiter := New(1, 60)
+	handler := limiter.Middleware(okHandler())
+
+	do := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+		req.RemoteAddr = "1.2.3.4:1111"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	do()
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("second request at limit 1: expected 429, got %d", code)
+	}
+
+	// Raising the limit applies to the window already in progress.
+	limiter.SetLimit(3)
+	if code := do(); code != http.StatusOK {
+		t.Errorf("after raising limit: expected 200, got %d", code)
+	}
+}
+
+// fakeClock lets tests advance time without sleeping.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestTokenBucket_SteadyRateNeverBlocked(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewTokenBucket(10, 10)
+	limiter.now = clock.now
+	handler := limiter.Middleware(okHandler())
+
+	// 8 requests per second for a simulated minute, crossing many second
+	// boundaries, stays under the 10/s rate and must never be rejected.
+	for i := 0; i < 480; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d at %s: expected 200, got %d", i+1, clock.t.Format(time.StampMilli), rr.Code)
+		}
+		clock.advance(125 * time.Millisecond)
+	}
+}
+
+func TestTokenBucket_LimitsBurstsAcrossWindowBoundary(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 900_000_000, time.UTC)}
+	limiter := NewTokenBucket(5, 5)
+	limiter.now = clock.now
+	handler := limiter.Middleware(okHandler())
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 5; i++ {
+		rr := do()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("burst request %d: expected 200, got %d", i+1, rr.Code)
+		}
+		if got, want := rr.Header().Get("X-RateLimit-Remaining"), fmt.Sprint(4-i); got != want {
+			t.Errorf("burst request %d: expected X-RateLimit-Remaining %s, got %s", i+1, want, got)
+		}
+	}
+
+	// A fixed window would admit a second full burst just after the
+	// boundary; the bucket has only refilled one token.
+	clock.advance(200 * time.Millisecond)
+	if rr := do(); rr.Code != http.StatusOK {
+		t.Fatalf("after refill: expected 200, got %d", rr.Code)
+	}
+	rr := do()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("second burst: expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header to be set")
+	}
+}
+
+func TestLimiter_KeyBySDKKey(t *testing.T) {
+	limiter := NewTokenBucket(1, 1)
+	limiter.SetKeyFunc(KeyBySDKKey)
+	handler := limiter.Middleware(okHandler())
+
+	do := func(sdkKeyID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/evaluate", nil)
+		req.RemoteAddr = "203.0.113.7:1234" // shared NAT address
+		req = req.WithContext(auth.ContextWithSDKKey(req.Context(), &model.SDKKey{ID: sdkKeyID}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := do("noisy"); code != http.StatusOK {
+		t.Fatalf("noisy key, request 1: expected 200, got %d", code)
+	}
+	if code := do("noisy"); code != http.StatusTooManyRequests {
+		t.Errorf("noisy key, request 2: expected 429, got %d", code)
+	}
+	if code := do("quiet"); code != http.StatusOK {
+		t.Errorf("quiet key behind the same address: expected 200, got %d", code)
+	}
+}
+
+func TestLimiter_SweepEvictsIdleKeys(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	bucket := NewTokenBucket(1, 2)
+	bucket.now = clock.now
+	window := New(5, 60)
+	window.now = clock.now
+
+	for _, l := range []*Limiter{bucket, window} {
+		l.allow("idle")
+		l.allow("idle")
+	}
+	clock.advance(2 * time.Second)
+	for _, l := range []*Limiter{bucket, window} {
+		l.allow("busy")
+		l.allow("busy")
+	}
+
+	// The idle bucket has refilled to burst; the busy one has not.
+	bucket.sweep()
+	if _, ok := bucket.entries["idle"]; ok {
+		t.Error("token bucket: expected refilled idle key to be evicted")
+	}
+	if _, ok := bucket.entries["busy"]; !ok {
+		t.Error("token bucket: expected busy key to be kept")
+	}
+
+	// Both windows are still open until the first one expires.
+	window.sweep()
+	if len(window.entries) != 2 {
+		t.Fatalf("fixed window: expected both keys kept, got %d", len(window.entries))
+	}
+	clock.advance(58 * time.Second)
+	window.sweep()
+	if _, ok := window.entries["idle"]; ok {
+		t.Error("fixed window: expected expired idle key to be evicted")
+	}
+	if _, ok := window.entries["busy"]; !ok {
+		t.Error("fixed window: expected busy key to be kept")
+	}
+}
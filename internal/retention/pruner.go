@@ -0,0 +1,121 @@
+// Package retention prunes audit log entries once they are older than their
+// retention period, and revokes SDK keys whose rotation grace period is over.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// AuditStore is the interface for deleting old audit entries.
+type AuditStore interface {
+	DeleteOlderThan(ctx context.Context, projectID string, cutoff time.Time) (int64, error)
+}
+
+// ProjectLister is the interface for listing the projects to prune.
+type ProjectLister interface {
+	List(ctx context.Context) ([]model.Project, error)
+}
+
+// SettingsStore is the interface for reading per-project retention overrides.
+type SettingsStore interface {
+	GetAll(ctx context.Context) (map[string]*model.ProjectSettings, error)
+}
+
+// SDKKeyRevoker is the interface for revoking SDK keys past their expiry.
+type SDKKeyRevoker interface {
+	RevokeExpired(ctx context.Context) (int64, error)
+}
+
+// Pruner periodically deletes audit entries older than the retention period:
+// the project's override if it has one, otherwise the instance-wide default.
+type Pruner struct {
+	audit     AuditStore
+	projects  ProjectLister
+	settings  SettingsStore
+	sdkKeys   SDKKeyRevoker // nil = expired SDK keys are not revoked
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time // injectable for testing
+}
+
+// NewPruner creates a pruner that runs every interval. retention is the
+// default age after which entries are deleted; zero or less keeps entries of
+// projects without an override forever.
+func NewPruner(audit AuditStore, projects ProjectLister, settings SettingsStore, retention, interval time.Duration) *Pruner {
+	return &Pruner{audit: audit, projects: projects, settings: settings, retention: retention, interval: interval, now: time.Now}
+}
+
+// SetSDKKeys makes each run also revoke SDK keys whose rotation grace period
+// has passed. They are already rejected by authentication; revoking them
+// marks them as such in the key list. Nil disables it.
+func (p *Pruner) SetSDKKeys(keys SDKKeyRevoker) {
+	p.sdkKeys = keys
+}
+
+// Run starts the pruning loop. Blocks until ctx is cancelled.
+func (p *Pruner) Run(ctx context.Context) {
+	slog.Info("audit pruner started", "interval", p.interval, "retention", p.retention)
+
+	// Run immediately on startup
+	p.tick(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("audit pruner stopped")
+			return
+		case <-ticker.C:
+			p.tick(ctx)
+		}
+	}
+}
+
+func (p *Pruner) tick(ctx context.Context) {
+	if p.sdkKeys != nil {
+		if revoked, err := p.sdkKeys.RevokeExpired(ctx); err != nil {
+			slog.Error("audit pruner: failed to revoke expired SDK keys", "error", err)
+		} else if revoked > 0 {
+			slog.Info("audit pruner: revoked expired SDK keys", "count", revoked)
+		}
+	}
+
+	projects, err := p.projects.List(ctx)
+	if err != nil {
+		slog.Error("audit pruner: failed to list projects", "error", err)
+		return
+	}
+	allSettings, err := p.settings.GetAll(ctx)
+	if err != nil {
+		slog.Error("audit pruner: failed to load settings", "error", err)
+		return
+	}
+
+	now := p.now()
+	var pruned int64
+	// Entries that belong to no project always use the default retention.
+	pruned += p.prune(ctx, "", p.retention, now)
+	for _, project := range projects {
+		pruned += p.prune(ctx, project.ID, allSettings[project.ID].AuditRetention(p.retention), now)
+	}
+	slog.Info("audit pruner: pruned audit entries", "count", pruned)
+}
+
+// prune deletes one project's entries older than retention and returns how
+// many were removed. A retention of zero or less deletes nothing.
+func (p *Pruner) prune(ctx context.Context, projectID string, retention time.Duration, now time.Time) int64 {
+	if retention <= 0 {
+		return 0
+	}
+	deleted, err := p.audit.DeleteOlderThan(ctx, projectID, now.Add(-retention))
+	if err != nil {
+		slog.Error("audit pruner: failed to delete audit entries", "project_id", projectID, "error", err)
+	}
+	return deleted
+}
@@ -0,0 +1,117 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type deletion struct {
+	projectID string
+	cutoff    time.Time
+}
+
+type mockAuditStore struct {
+	deletions []deletion
+	counts    map[string]int64
+}
+
+func (m *mockAuditStore) DeleteOlderThan(_ context.Context, projectID string, cutoff time.Time) (int64, error) {
+	m.deletions = append(m.deletions, deletion{projectID, cutoff})
+	return m.counts[projectID], nil
+}
+
+type mockProjects struct {
+	projects []model.Project
+}
+
+func (m *mockProjects) List(_ context.Context) ([]model.Project, error) {
+	return m.projects, nil
+}
+
+type mockSettings struct {
+	settings map[string]*model.ProjectSettings
+}
+
+func (m *mockSettings) GetAll(_ context.Context) (map[string]*model.ProjectSettings, error) {
+	return m.settings, nil
+}
+
+type mockSDKKeys struct {
+	calls int
+}
+
+func (m *mockSDKKeys) RevokeExpired(_ context.Context) (int64, error) {
+	m.calls++
+	return 2, nil
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestPruner_UsesProjectOverrides(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	audit := &mockAuditStore{counts: map[string]int64{"p1": 3, "p2": 4}}
+	projects := &mockProjects{projects: []model.Project{{ID: "p1"}, {ID: "p2"}, {ID: "p3"}}}
+	settings := &mockSettings{settings: map[string]*model.ProjectSettings{
+		"p2": {AuditRetentionDays: intPtr(7)},
+		"p3": {AuditRetentionDays: intPtr(0)},
+	}}
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	p := NewPruner(audit, projects, settings, 90*24*time.Hour, time.Hour)
+	p.now = func() time.Time { return now }
+	p.tick(context.Background())
+
+	want := []deletion{
+		{"", now.Add(-90 * 24 * time.Hour)},
+		{"p1", now.Add(-90 * 24 * time.Hour)},
+		{"p2", now.Add(-7 * 24 * time.Hour)},
+	}
+	if len(audit.deletions) != len(want) {
+		t.Fatalf("expected %d deletions, got %+v", len(want), audit.deletions)
+	}
+	for i, d := range want {
+		if audit.deletions[i] != d {
+			t.Errorf("deletion %d: expected %+v, got %+v", i, d, audit.deletions[i])
+		}
+	}
+	if !strings.Contains(buf.String(), "count=7") {
+		t.Errorf("expected the pruned count in the log, got %q", buf.String())
+	}
+}
+
+func TestPruner_DisabledByDefaultRetention(t *testing.T) {
+	audit := &mockAuditStore{}
+	projects := &mockProjects{projects: []model.Project{{ID: "p1"}, {ID: "p2"}}}
+	settings := &mockSettings{settings: map[string]*model.ProjectSettings{
+		"p2": {AuditRetentionDays: intPtr(30)},
+	}}
+
+	p := NewPruner(audit, projects, settings, 0, time.Hour)
+	p.tick(context.Background())
+
+	if len(audit.deletions) != 1 || audit.deletions[0].projectID != "p2" {
+		t.Errorf("expected only the project with an override to be pruned, got %+v", audit.deletions)
+	}
+}
+
+func TestPruner_RevokesExpiredSDKKeys(t *testing.T) {
+	audit := &mockAuditStore{}
+	keys := &mockSDKKeys{}
+
+	p := NewPruner(audit, &mockProjects{}, &mockSettings{}, 0, time.Hour)
+	p.SetSDKKeys(keys)
+	p.tick(context.Background())
+	if keys.calls != 1 {
+		t.Errorf("expected one revocation pass per run, got %d", keys.calls)
+	}
+}
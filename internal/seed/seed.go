@@ -0,0 +1,176 @@
+// Package seed applies flag environment defaults from process environment
+// variables at startup, for GitOps-style deployments.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// EnvPrefix is the prefix of environment variables holding flag defaults, in
+// the form TOGGLERINO_FLAG_DEFAULT_<flag-key>_<env-key>=<value>. The
+// environment key is everything after the last underscore.
+const EnvPrefix = "TOGGLERINO_FLAG_DEFAULT_"
+
+// seededVariant is the key of the variant created to serve a seeded value.
+const seededVariant = "default"
+
+// FlagStore is the interface for flag operations needed by the seeder.
+type FlagStore interface {
+	ListNonArchived(ctx context.Context) ([]model.Flag, error)
+	GetEnvironmentConfig(ctx context.Context, flagID, environmentID string) (*model.FlagEnvironmentConfig, error)
+	UpdateEnvironmentConfig(ctx context.Context, flagID, environmentID string, enabled bool, defaultVariant string, variants json.RawMessage, targetingRules json.RawMessage, prerequisites json.RawMessage, defaultRollout json.RawMessage, includedUsers, excludedUsers []string, includedVariant, modifiedBy string) (*model.FlagEnvironmentConfig, error)
+}
+
+// EnvironmentStore is the interface for environment operations needed by the seeder.
+type EnvironmentStore interface {
+	ListByProject(ctx context.Context, projectID string) ([]model.Environment, error)
+}
+
+// AuditRecorder is the interface for recording audit events.
+type AuditRecorder interface {
+	Record(ctx context.Context, entry model.AuditEntry) error
+}
+
+// Default is a single flag/environment default parsed from the environment.
+type Default struct {
+	FlagKey string
+	EnvKey  string
+	Value   json.RawMessage
+}
+
+// ParseEnv extracts flag defaults from environ (as returned by os.Environ).
+// Values that are not valid JSON are treated as strings, so
+// TOGGLERINO_FLAG_DEFAULT_theme_production=dark seeds "dark".
+func ParseEnv(environ []string) []Default {
+	var defaults []Default
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, EnvPrefix)
+		i := strings.LastIndex(rest, "_")
+		if i <= 0 || i == len(rest)-1 {
+			slog.Warn("seed: ignoring malformed flag default variable", "name", name)
+			continue
+		}
+
+		raw := json.RawMessage(value)
+		if !json.Valid(raw) {
+			raw, _ = json.Marshal(value)
+		}
+		defaults = append(defaults, Default{FlagKey: rest[:i], EnvKey: rest[i+1:], Value: raw})
+	}
+	return defaults
+}
+
+// Seeder applies flag defaults to environment configs that are still in
+// their initial, unconfigured state.
+type Seeder struct {
+	flags FlagStore
+	envs  EnvironmentStore
+	audit AuditRecorder
+}
+
+// NewSeeder creates a new Seeder.
+func NewSeeder(flags FlagStore, envs EnvironmentStore, audit AuditRecorder) *Seeder {
+	return &Seeder{flags: flags, envs: envs, audit: audit}
+}
+
+// Apply seeds each default into every project that has a matching flag and
+// environment. Configs that were already changed from their initial state are
+// left alone, which makes repeated startups idempotent. It returns the number
+// of configs seeded.
+func (s *Seeder) Apply(ctx context.Context, defaults []Default) (int, error) {
+	if len(defaults) == 0 {
+		return 0, nil
+	}
+
+	flags, err := s.flags.ListNonArchived(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	envsByProject := make(map[string][]model.Environment)
+	applied := 0
+	for _, d := range defaults {
+		matched := false
+		for _, f := range flags {
+			if f.Key != d.FlagKey {
+				continue
+			}
+
+			envs, ok := envsByProject[f.ProjectID]
+			if !ok {
+				envs, err = s.envs.ListByProject(ctx, f.ProjectID)
+				if err != nil {
+					return applied, err
+				}
+				envsByProject[f.ProjectID] = envs
+			}
+			for _, env := range envs {
+				if env.Key != d.EnvKey {
+					continue
+				}
+				matched = true
+				if s.applyOne(ctx, f, env, d.Value) {
+					applied++
+				}
+			}
+		}
+		if !matched {
+			slog.Warn("seed: no matching flag/environment for default", "flag", d.FlagKey, "env", d.EnvKey)
+		}
+	}
+	return applied, nil
+}
+
+// applyOne seeds a single flag/environment config, reporting whether it was changed.
+func (s *Seeder) applyOne(ctx context.Context, f model.Flag, env model.Environment, value json.RawMessage) bool {
+	if err := model.ValidateFlagValue(f.ValueType, f.JSONSchema, value); err != nil {
+		slog.Warn("seed: skipping flag default", "flag", f.Key, "env", env.Key, "error", err)
+		return false
+	}
+
+	cfg, err := s.flags.GetEnvironmentConfig(ctx, f.ID, env.ID)
+	if err != nil {
+		slog.Error("seed: failed to load environment config", "flag", f.Key, "env", env.Key, "error", err)
+		return false
+	}
+	if !isInitial(cfg) {
+		slog.Info("seed: skipping already configured flag", "flag", f.Key, "env", env.Key)
+		return false
+	}
+
+	variants, _ := json.Marshal([]model.Variant{{Key: seededVariant, Value: value}})
+	updated, err := s.flags.UpdateEnvironmentConfig(ctx, f.ID, env.ID, true, seededVariant, variants, json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		slog.Error("seed: failed to apply flag default", "flag", f.Key, "env", env.Key, "error", err)
+		return false
+	}
+
+	newVal, _ := json.Marshal(updated)
+	if err := s.audit.Record(ctx, model.AuditEntry{
+		ProjectID:  &f.ProjectID,
+		Action:     "seed",
+		EntityType: "flag_config",
+		EntityID:   f.Key,
+		NewValue:   newVal,
+	}); err != nil {
+		slog.Warn("seed: failed to record audit", "error", err)
+	}
+
+	slog.Info("seed: applied flag default", "flag", f.Key, "env", env.Key, "value", string(value))
+	return true
+}
+
+// isInitial reports whether a config is still as created alongside its flag:
+// disabled, with no variants and no targeting rules.
+func isInitial(cfg *model.FlagEnvironmentConfig) bool {
+	return !cfg.Enabled && len(cfg.Variants) == 0 && len(cfg.TargetingRules) == 0
+}
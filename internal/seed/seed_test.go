@@ -0,0 +1,165 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// --- Mock stores ---
+
+type mockFlagStore struct {
+	flags   []model.Flag
+	configs map[string]*model.FlagEnvironmentConfig // key: flagID + "/" + envID
+	updates int
+}
+
+func (m *mockFlagStore) ListNonArchived(_ context.Context) ([]model.Flag, error) {
+	return m.flags, nil
+}
+
+func (m *mockFlagStore) GetEnvironmentConfig(_ context.Context, flagID, environmentID string) (*model.FlagEnvironmentConfig, error) {
+	return m.configs[flagID+"/"+environmentID], nil
+}
+
+func (m *mockFlagStore) UpdateEnvironmentConfig(_ context.Context, flagID, environmentID string, enabled bool, defaultVariant string, variants json.RawMessage, targetingRules json.RawMessage, prerequisites json.RawMessage, defaultRollout json.RawMessage, includedUsers, excludedUsers []string, includedVariant, modifiedBy string) (*model.FlagEnvironmentConfig, error) {
+	m.updates++
+	cfg := &model.FlagEnvironmentConfig{FlagID: flagID, EnvironmentID: environmentID, Enabled: enabled, DefaultVariant: defaultVariant}
+	json.Unmarshal(variants, &cfg.Variants)
+	json.Unmarshal(targetingRules, &cfg.TargetingRules)
+	m.configs[flagID+"/"+environmentID] = cfg
+	return cfg, nil
+}
+
+type mockEnvStore struct {
+	envs map[string][]model.Environment
+}
+
+func (m *mockEnvStore) ListByProject(_ context.Context, projectID string) ([]model.Environment, error) {
+	return m.envs[projectID], nil
+}
+
+type mockAudit struct {
+	entries []model.AuditEntry
+}
+
+func (m *mockAudit) Record(_ context.Context, entry model.AuditEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func newFixture() (*mockFlagStore, *mockEnvStore, *mockAudit) {
+	flags := &mockFlagStore{
+		flags: []model.Flag{{ID: "flag-1", ProjectID: "proj-1", Key: "dark-mode", ValueType: model.ValueTypeBoolean}},
+		configs: map[string]*model.FlagEnvironmentConfig{
+			"flag-1/env-prod": {FlagID: "flag-1", EnvironmentID: "env-prod", DefaultVariant: "off"},
+		},
+	}
+	envs := &mockEnvStore{envs: map[string][]model.Environment{
+		"proj-1": {{ID: "env-prod", ProjectID: "proj-1", Key: "production"}},
+	}}
+	return flags, envs, &mockAudit{}
+}
+
+// --- Tests ---
+
+func TestParseEnv(t *testing.T) {
+	defaults := ParseEnv([]string{
+		"PATH=/usr/bin",
+		"TOGGLERINO_FLAG_DEFAULT_dark-mode_production=true",
+		"TOGGLERINO_FLAG_DEFAULT_new_checkout_staging=42",
+		"TOGGLERINO_FLAG_DEFAULT_theme_production=dark",
+		"TOGGLERINO_FLAG_DEFAULT_noenv=true",
+	})
+	if len(defaults) != 3 {
+		t.Fatalf("expected 3 defaults, got %d: %+v", len(defaults), defaults)
+	}
+
+	want := []Default{
+		{FlagKey: "dark-mode", EnvKey: "production", Value: json.RawMessage(`true`)},
+		{FlagKey: "new_checkout", EnvKey: "staging", Value: json.RawMessage(`42`)},
+		{FlagKey: "theme", EnvKey: "production", Value: json.RawMessage(`"dark"`)},
+	}
+	for i, w := range want {
+		got := defaults[i]
+		if got.FlagKey != w.FlagKey || got.EnvKey != w.EnvKey || string(got.Value) != string(w.Value) {
+			t.Errorf("defaults[%d] = {%s %s %s}, want {%s %s %s}", i, got.FlagKey, got.EnvKey, got.Value, w.FlagKey, w.EnvKey, w.Value)
+		}
+	}
+}
+
+func TestSeeder_AppliesToUnconfiguredFlag(t *testing.T) {
+	flags, envs, audit := newFixture()
+	s := NewSeeder(flags, envs, audit)
+
+	applied, err := s.Apply(context.Background(), ParseEnv([]string{"TOGGLERINO_FLAG_DEFAULT_dark-mode_production=true"}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if applied != 1 {
+		t.Fatalf("expected 1 applied, got %d", applied)
+	}
+
+	cfg := flags.configs["flag-1/env-prod"]
+	if !cfg.Enabled {
+		t.Error("expected seeded config to be enabled")
+	}
+	if cfg.DefaultVariant != seededVariant || len(cfg.Variants) != 1 || string(cfg.Variants[0].Value) != "true" {
+		t.Errorf("unexpected seeded config: %+v", cfg)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != "seed" {
+		t.Errorf("expected one seed audit entry, got %+v", audit.entries)
+	}
+}
+
+func TestSeeder_SkipsAlreadyConfiguredFlag(t *testing.T) {
+	flags, envs, audit := newFixture()
+	flags.configs["flag-1/env-prod"] = &model.FlagEnvironmentConfig{
+		FlagID:         "flag-1",
+		EnvironmentID:  "env-prod",
+		Enabled:        true,
+		DefaultVariant: "off",
+		Variants:       []model.Variant{{Key: "off", Value: json.RawMessage(`false`)}},
+	}
+	s := NewSeeder(flags, envs, audit)
+
+	applied, err := s.Apply(context.Background(), ParseEnv([]string{"TOGGLERINO_FLAG_DEFAULT_dark-mode_production=true"}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if applied != 0 || flags.updates != 0 {
+		t.Errorf("expected no changes, got applied=%d updates=%d", applied, flags.updates)
+	}
+}
+
+func TestSeeder_IsIdempotent(t *testing.T) {
+	flags, envs, audit := newFixture()
+	s := NewSeeder(flags, envs, audit)
+	defaults := ParseEnv([]string{"TOGGLERINO_FLAG_DEFAULT_dark-mode_production=true"})
+
+	if _, err := s.Apply(context.Background(), defaults); err != nil {
+		t.Fatalf("first Apply: %v", err)
+	}
+	applied, err := s.Apply(context.Background(), defaults)
+	if err != nil {
+		t.Fatalf("second Apply: %v", err)
+	}
+	if applied != 0 || flags.updates != 1 {
+		t.Errorf("expected second startup to be a no-op, got applied=%d updates=%d", applied, flags.updates)
+	}
+}
+
+func TestSeeder_SkipsValueOfWrongType(t *testing.T) {
+	flags, envs, audit := newFixture()
+	s := NewSeeder(flags, envs, audit)
+
+	applied, err := s.Apply(context.Background(), ParseEnv([]string{"TOGGLERINO_FLAG_DEFAULT_dark-mode_production=yes"}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if applied != 0 || flags.updates != 0 {
+		t.Errorf("expected mistyped value to be skipped, got applied=%d updates=%d", applied, flags.updates)
+	}
+}
@@ -25,6 +25,23 @@ type AuditRecorder interface {
 	Record(ctx context.Context, entry model.AuditEntry) error
 }
 
+// GlobalSettingsStore is the interface for reading instance-wide defaults.
+type GlobalSettingsStore interface {
+	Get(ctx context.Context) (*model.GlobalSettings, error)
+}
+
+// ConfigStore is the interface for reading a flag's environment configs,
+// needed to detect fully rolled out flags.
+type ConfigStore interface {
+	GetAllEnvironmentConfigs(ctx context.Context, flagID string) ([]model.FlagEnvironmentConfig, error)
+}
+
+// DeletedFlagPurger is the interface for permanently removing soft-deleted
+// flags once their retention period has passed.
+type DeletedFlagPurger interface {
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
 // CacheRefresher is the interface for refreshing the in-memory flag cache.
 type CacheRefresher interface {
 	LoadAll(ctx context.Context) error
@@ -38,6 +55,28 @@ type Checker struct {
 	cache    CacheRefresher
 	interval time.Duration
 	now      func() time.Time // injectable for testing
+
+	// configs and fullRolloutAfter enable early promotion of flags that have
+	// been fully rolled out in every environment; nil configs disables it.
+	configs          ConfigStore
+	fullRolloutAfter time.Duration
+
+	// global supplies default lifetimes and the grace period; nil uses the
+	// built-in defaults.
+	global GlobalSettingsStore
+
+	// purger and deletedRetention hard-delete flags that were soft-deleted
+	// longer ago than the retention; nil purger keeps them forever.
+	purger           DeletedFlagPurger
+	deletedRetention time.Duration
+
+	// notifier receives a digest of the flags that became stale in a tick;
+	// nil disables notifications.
+	notifier StaleNotifier
+
+	// usageWindow keeps flags evaluated by an SDK within it from being
+	// promoted when their lifetime expires; zero disables the check.
+	usageWindow time.Duration
 }
 
 // NewChecker creates a new staleness checker.
@@ -45,6 +84,51 @@ func NewChecker(flags FlagStore, settings SettingsStore, audit AuditRecorder, ca
 	return &Checker{flags: flags, settings: settings, audit: audit, cache: cache, interval: interval, now: time.Now}
 }
 
+// SetFullRolloutThreshold makes the checker promote active flags to
+// potentially stale once they have served their new behavior to all users in
+// every environment for longer than threshold, even within their lifetime.
+// A threshold of zero or less disables this.
+func (c *Checker) SetFullRolloutThreshold(configs ConfigStore, threshold time.Duration) {
+	if threshold <= 0 {
+		c.configs = nil
+		return
+	}
+	c.configs = configs
+	c.fullRolloutAfter = threshold
+}
+
+// SetGlobalSettings makes the checker read default lifetimes and the grace
+// period from store on every tick, so changes apply without a restart.
+func (c *Checker) SetGlobalSettings(store GlobalSettingsStore) {
+	c.global = store
+}
+
+// SetDeletedFlagRetention makes the checker permanently remove flags that
+// were deleted more than retention ago. A retention of zero or less disables
+// purging, so deleted flags stay restorable indefinitely.
+func (c *Checker) SetDeletedFlagRetention(purger DeletedFlagPurger, retention time.Duration) {
+	if retention <= 0 {
+		c.purger = nil
+		return
+	}
+	c.purger = purger
+	c.deletedRetention = retention
+}
+
+// SetNotifier makes the checker send a digest to notifier after every tick in
+// which flags were promoted to stale.
+func (c *Checker) SetNotifier(notifier StaleNotifier) {
+	c.notifier = notifier
+}
+
+// SetUsageWindow makes the checker leave active flags alone past their
+// lifetime while SDKs evaluated them within window, since a flag still in use
+// is not safe to remove. Flags never evaluated count as unused. A window of
+// zero or less disables the check, so age alone decides.
+func (c *Checker) SetUsageWindow(window time.Duration) {
+	c.usageWindow = max(window, 0)
+}
+
 // Run starts the staleness checker loop. Blocks until ctx is cancelled.
 func (c *Checker) Run(ctx context.Context) {
 	slog.Info("staleness checker started", "interval", c.interval)
@@ -66,9 +150,16 @@ func (c *Checker) Run(ctx context.Context) {
 	}
 }
 
-const gracePeriod = 14 * 24 * time.Hour // 14 days
+// Audit reasons recorded with each promotion.
+const (
+	reasonLifetimeExpired = "lifetime_expired"
+	reasonGracePeriod     = "grace_period_elapsed"
+	reasonFullRollout     = "full_rollout"
+)
 
 func (c *Checker) tick(ctx context.Context) {
+	c.purgeDeleted(ctx)
+
 	flags, err := c.flags.ListNonArchived(ctx)
 	if err != nil {
 		slog.Error("staleness checker: failed to list flags", "error", err)
@@ -81,7 +172,18 @@ func (c *Checker) tick(ctx context.Context) {
 		return
 	}
 
+	var global *model.GlobalSettings
+	if c.global != nil {
+		if global, err = c.global.Get(ctx); err != nil {
+			// Fall back to built-in defaults rather than skipping the tick.
+			slog.Error("staleness checker: failed to load global settings", "error", err)
+			global = nil
+		}
+	}
+	gracePeriod := global.GracePeriod()
+
 	promoted := 0
+	var becameStale []model.Flag
 	now := c.now()
 	for _, f := range flags {
 		settings := allSettings[f.ProjectID]
@@ -90,24 +192,37 @@ func (c *Checker) tick(ctx context.Context) {
 			ps = settings
 		}
 
-		lifetime := ps.GetLifetime(f.FlagType)
+		lifetime := ps.GetLifetimeWithDefaults(f.FlagType, global)
 		if lifetime == nil {
 			// Permanent flag type — skip
 			continue
 		}
 
-		expectedEnd := f.CreatedAt.Add(time.Duration(*lifetime) * 24 * time.Hour)
+		createdAt := clampFuture(f, "created_at", f.CreatedAt, now)
+		expectedEnd := createdAt.Add(time.Duration(*lifetime) * 24 * time.Hour)
 
 		switch f.LifecycleStatus {
 		case model.LifecycleActive:
 			if now.After(expectedEnd) {
-				c.promote(ctx, f, model.LifecyclePotentiallyStale)
-				promoted++
+				if c.recentlyEvaluated(f, now) {
+					break
+				}
+				if c.promote(ctx, f, model.LifecyclePotentiallyStale, reasonLifetimeExpired) {
+					promoted++
+				}
+			} else if c.fullyRolledOut(ctx, f, now) {
+				if c.promote(ctx, f, model.LifecyclePotentiallyStale, reasonFullRollout) {
+					promoted++
+				}
 			}
 		case model.LifecyclePotentiallyStale:
-			if f.LifecycleStatusChangedAt != nil && now.After(f.LifecycleStatusChangedAt.Add(gracePeriod)) {
-				c.promote(ctx, f, model.LifecycleStale)
+			if f.LifecycleStatusChangedAt == nil {
+				break
+			}
+			changedAt := clampFuture(f, "lifecycle_status_changed_at", *f.LifecycleStatusChangedAt, now)
+			if now.After(changedAt.Add(gracePeriod)) && c.promote(ctx, f, model.LifecycleStale, reasonGracePeriod) {
 				promoted++
+				becameStale = append(becameStale, f)
 			}
 		case model.LifecycleStale:
 			// Already stale — nothing to do
@@ -120,18 +235,81 @@ func (c *Checker) tick(ctx context.Context) {
 			slog.Error("staleness checker: failed to refresh cache", "error", err)
 		}
 	}
+
+	if c.notifier != nil && len(becameStale) > 0 {
+		if err := c.notifier.NotifyStale(ctx, buildDigest(becameStale, now)); err != nil {
+			slog.Error("staleness checker: failed to send stale flag digest", "error", err)
+		}
+	}
+}
+
+// purgeDeleted hard-deletes flags whose retention period has passed. Deleted
+// flags are already absent from the cache, so no refresh is needed.
+func (c *Checker) purgeDeleted(ctx context.Context) {
+	if c.purger == nil {
+		return
+	}
+	purged, err := c.purger.PurgeDeleted(ctx, c.now().Add(-c.deletedRetention))
+	if err != nil {
+		slog.Error("staleness checker: failed to purge deleted flags", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.Info("staleness checker: purged deleted flags", "count", purged)
+	}
+}
+
+// clampFuture returns t, or now if t lies in the future. A future timestamp
+// means clock skew on insert or a restored backup; treating it as now keeps
+// the age math non-negative so the flag is neither promoted early nor skipped.
+func clampFuture(flag model.Flag, field string, t, now time.Time) time.Time {
+	if !t.After(now) {
+		return t
+	}
+	slog.Warn("staleness checker: flag timestamp is in the future, treating as now",
+		"flag", flag.Key, "field", field, "timestamp", t, "now", now, "skew", t.Sub(now))
+	return now
+}
+
+// recentlyEvaluated reports whether an SDK evaluated a flag within the usage
+// window.
+func (c *Checker) recentlyEvaluated(flag model.Flag, now time.Time) bool {
+	if c.usageWindow == 0 || flag.LastEvaluatedAt == nil {
+		return false
+	}
+	return now.Sub(*flag.LastEvaluatedAt) < c.usageWindow
 }
 
-func (c *Checker) promote(ctx context.Context, flag model.Flag, newStatus model.LifecycleStatus) {
+// fullyRolledOut reports whether a flag has been fully rolled out in every
+// environment for longer than the configured threshold.
+func (c *Checker) fullyRolledOut(ctx context.Context, flag model.Flag, now time.Time) bool {
+	if c.configs == nil {
+		return false
+	}
+	configs, err := c.configs.GetAllEnvironmentConfigs(ctx, flag.ID)
+	if err != nil {
+		slog.Error("staleness checker: failed to load environment configs", "flag", flag.Key, "error", err)
+		return false
+	}
+	since, ok := fullyRolledOutSince(flag, configs)
+	if !ok {
+		return false
+	}
+	since = clampFuture(flag, "config_updated_at", since, now)
+	return now.After(since.Add(c.fullRolloutAfter))
+}
+
+// promote moves a flag to newStatus and reports whether the update succeeded.
+func (c *Checker) promote(ctx context.Context, flag model.Flag, newStatus model.LifecycleStatus, reason string) bool {
 	updated, err := c.flags.SetLifecycleStatus(ctx, flag.ID, newStatus)
 	if err != nil {
 		slog.Error("staleness checker: failed to update status",
 			"flag", flag.Key, "to", string(newStatus), "error", err)
-		return
+		return false
 	}
 
 	oldVal, _ := json.Marshal(map[string]string{"lifecycle_status": string(flag.LifecycleStatus)})
-	newVal, _ := json.Marshal(map[string]string{"lifecycle_status": string(updated.LifecycleStatus)})
+	newVal, _ := json.Marshal(map[string]string{"lifecycle_status": string(updated.LifecycleStatus), "reason": reason})
 
 	if err := c.audit.Record(ctx, model.AuditEntry{
 		ProjectID:  &flag.ProjectID,
@@ -145,5 +323,6 @@ func (c *Checker) promote(ctx context.Context, flag model.Flag, newStatus model.
 	}
 
 	slog.Info("staleness checker: promoted flag",
-		"flag", flag.Key, "from", string(flag.LifecycleStatus), "to", string(newStatus))
+		"flag", flag.Key, "from", string(flag.LifecycleStatus), "to", string(newStatus), "reason", reason)
+	return true
 }
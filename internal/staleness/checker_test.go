@@ -1,7 +1,11 @@
 package staleness
 
 import (
+	"bytes"
 	"context"
+	"log/slog"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -294,3 +298,454 @@ func TestTick_OperationalFlagShorterLifetime(t *testing.T) {
 		t.Errorf("expected potentially_stale, got %s", flags.promoted[0].status)
 	}
 }
+
+// captureLogs redirects the default slog logger into a buffer for the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestTick_FutureCreatedAt_NoPromotionAndWarns(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Created "100 days from now" due to clock skew on insert
+	flags := &mockFlagStore{
+		flags: []model.Flag{
+			makeFlag("skewed-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(100*24*time.Hour), nil),
+		},
+	}
+	logs := captureLogs(t)
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 0 {
+		t.Errorf("expected no promotions, got %d", len(flags.promoted))
+	}
+	out := logs.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "skewed-flag") || !strings.Contains(out, "field=created_at") {
+		t.Errorf("expected clock skew warning for skewed-flag, got logs: %s", out)
+	}
+}
+
+func TestTick_FutureStatusChangedAt_NoPromotionAndWarns(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	changedAt := now.Add(30 * 24 * time.Hour) // restored from a backup taken with a fast clock
+	flags := &mockFlagStore{
+		flags: []model.Flag{
+			makeFlag("skewed-flag", "proj-1", model.FlagTypeRelease, model.LifecyclePotentiallyStale, now.Add(-60*24*time.Hour), &changedAt),
+		},
+	}
+	logs := captureLogs(t)
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 0 {
+		t.Errorf("expected no promotions, got %d", len(flags.promoted))
+	}
+	if !strings.Contains(logs.String(), "field=lifecycle_status_changed_at") {
+		t.Errorf("expected clock skew warning for lifecycle_status_changed_at, got logs: %s", logs.String())
+	}
+}
+
+type mockConfigStore struct {
+	configs map[string][]model.FlagEnvironmentConfig
+}
+
+func (m *mockConfigStore) GetAllEnvironmentConfigs(_ context.Context, flagID string) ([]model.FlagEnvironmentConfig, error) {
+	return m.configs[flagID], nil
+}
+
+// onOffConfig returns an enabled boolean config whose unconditional rule
+// serves "on" to pct percent of users.
+func onOffConfig(pct int, updatedAt time.Time) model.FlagEnvironmentConfig {
+	return model.FlagEnvironmentConfig{
+		Enabled:        true,
+		DefaultVariant: "off",
+		Variants: []model.Variant{
+			{Key: "off", Value: []byte(`false`)},
+			{Key: "on", Value: []byte(`true`)},
+		},
+		TargetingRules: []model.TargetingRule{{Variant: "on", PercentageRollout: intPtr(pct)}},
+		UpdatedAt:      updatedAt,
+	}
+}
+
+func TestTick_FullyRolledOut_PromotedEarly(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Created 20 days ago, well within the 40-day release lifetime.
+	full := makeFlag("full-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-20*24*time.Hour), nil)
+	full.DefaultValue = []byte(`false`)
+	partial := makeFlag("partial-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-20*24*time.Hour), nil)
+	partial.DefaultValue = []byte(`false`)
+	recent := makeFlag("recent-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-20*24*time.Hour), nil)
+	recent.DefaultValue = []byte(`false`)
+
+	longAgo := now.Add(-10 * 24 * time.Hour)
+	configs := &mockConfigStore{configs: map[string][]model.FlagEnvironmentConfig{
+		full.ID:    {onOffConfig(100, longAgo), onOffConfig(100, longAgo)},
+		partial.ID: {onOffConfig(100, longAgo), onOffConfig(50, longAgo)},
+		recent.ID:  {onOffConfig(100, longAgo), onOffConfig(100, now.Add(-2*24*time.Hour))},
+	}}
+
+	flags := &mockFlagStore{flags: []model.Flag{full, partial, recent}}
+	audit := &mockAudit{}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    audit,
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+	c.SetFullRolloutThreshold(configs, 7*24*time.Hour)
+
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 1 {
+		t.Fatalf("expected 1 promotion, got %d: %+v", len(flags.promoted), flags.promoted)
+	}
+	if flags.promoted[0].flagID != full.ID || flags.promoted[0].status != model.LifecyclePotentiallyStale {
+		t.Errorf("expected full-flag promoted to potentially_stale, got %+v", flags.promoted[0])
+	}
+	if len(audit.entries) != 1 || !strings.Contains(string(audit.entries[0].NewValue), `"reason":"full_rollout"`) {
+		t.Errorf("expected audit entry with reason full_rollout, got %+v", audit.entries)
+	}
+}
+
+func TestTick_FullyRolledOut_DisabledByDefault(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	flag := makeFlag("full-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-20*24*time.Hour), nil)
+	flag.DefaultValue = []byte(`false`)
+	flags := &mockFlagStore{flags: []model.Flag{flag}}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 0 {
+		t.Errorf("expected no promotions without a threshold, got %d", len(flags.promoted))
+	}
+}
+
+func TestFullyRolledOutSince(t *testing.T) {
+	flag := model.Flag{DefaultValue: []byte(`false`)}
+	at := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	conditional := onOffConfig(100, at)
+	conditional.TargetingRules[0].Conditions = []model.Condition{{Attribute: "country", Operator: "equals", Value: "DE"}}
+
+	defaultOn := onOffConfig(100, at)
+	defaultOn.DefaultVariant = "on"
+	defaultOn.TargetingRules = nil
+
+	disabled := onOffConfig(100, at)
+	disabled.Enabled = false
+
+	gated := onOffConfig(100, at)
+	gated.Prerequisites = []model.Prerequisite{{FlagKey: "parent", Variant: "on"}}
+
+	weightedSplit := onOffConfig(100, at)
+	weightedSplit.TargetingRules[0].VariantWeights = []model.VariantWeight{{Variant: "off", Weight: 50}, {Variant: "on", Weight: 50}}
+
+	weightedAllOn := onOffConfig(100, at)
+	weightedAllOn.TargetingRules[0].VariantWeights = []model.VariantWeight{{Variant: "off", Weight: 0}, {Variant: "on", Weight: 100}}
+
+	tests := []struct {
+		name string
+		cfg  model.FlagEnvironmentConfig
+		want bool
+	}{
+		{"unconditional 100%", onOffConfig(100, at), true},
+		{"default variant on", defaultOn, true},
+		{"partial rollout", onOffConfig(99, at), false},
+		{"conditional rule", conditional, false},
+		{"disabled", disabled, false},
+		{"gated by prerequisite", gated, false},
+		{"weighted split", weightedSplit, false},
+		{"weighted all on", weightedAllOn, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := fullyRolledOutSince(flag, []model.FlagEnvironmentConfig{tt.cfg}); got != tt.want {
+				t.Errorf("fullyRolledOutSince = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type mockGlobalSettings struct {
+	settings *model.GlobalSettings
+}
+
+func (m *mockGlobalSettings) Get(_ context.Context) (*model.GlobalSettings, error) {
+	return m.settings, nil
+}
+
+func TestTick_GlobalSettingsApplyWithoutRestart(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// 20 days old: within the built-in 40-day release lifetime.
+	flags := &mockFlagStore{
+		flags: []model.Flag{
+			makeFlag("young-flag", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-20*24*time.Hour), nil),
+			makeFlag("waiting-flag", "proj-1", model.FlagTypeRelease, model.LifecyclePotentiallyStale, now.Add(-60*24*time.Hour), timePtr(now.Add(-5*24*time.Hour))),
+		},
+	}
+	global := &mockGlobalSettings{settings: &model.GlobalSettings{}}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+	c.SetGlobalSettings(global)
+
+	c.tick(context.Background())
+	if len(flags.promoted) != 0 {
+		t.Fatalf("expected no promotions with default settings, got %+v", flags.promoted)
+	}
+
+	global.settings = &model.GlobalSettings{
+		FlagLifetimes:   map[model.FlagType]*int{model.FlagTypeRelease: intPtr(10)},
+		GracePeriodDays: intPtr(3),
+	}
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 2 {
+		t.Fatalf("expected 2 promotions after changing global settings, got %+v", flags.promoted)
+	}
+	got := map[string]model.LifecycleStatus{}
+	for _, p := range flags.promoted {
+		got[p.flagID] = p.status
+	}
+	if got["young-flag-id"] != model.LifecyclePotentiallyStale || got["waiting-flag-id"] != model.LifecycleStale {
+		t.Errorf("unexpected promotions: %+v", got)
+	}
+}
+
+type mockPurger struct {
+	before []time.Time
+}
+
+func (m *mockPurger) PurgeDeleted(_ context.Context, before time.Time) (int64, error) {
+	m.before = append(m.before, before)
+	return 1, nil
+}
+
+func TestTick_PurgesDeletedFlagsPastRetention(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	purger := &mockPurger{}
+	c := &Checker{
+		flags:    &mockFlagStore{},
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+
+	c.SetDeletedFlagRetention(purger, 0)
+	c.tick(context.Background())
+	if len(purger.before) != 0 {
+		t.Fatalf("expected no purge with zero retention, got %d", len(purger.before))
+	}
+
+	c.SetDeletedFlagRetention(purger, 30*24*time.Hour)
+	c.tick(context.Background())
+	if len(purger.before) != 1 {
+		t.Fatalf("expected one purge, got %d", len(purger.before))
+	}
+	if want := now.Add(-30 * 24 * time.Hour); !purger.before[0].Equal(want) {
+		t.Errorf("expected cutoff %v, got %v", want, purger.before[0])
+	}
+}
+
+type mockNotifier struct {
+	digests []StaleDigest
+}
+
+func (m *mockNotifier) NotifyStale(_ context.Context, digest StaleDigest) error {
+	m.digests = append(m.digests, digest)
+	return nil
+}
+
+func TestTick_NotifiesFlagsPromotedToStale(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	pastGrace := timePtr(now.Add(-15 * 24 * time.Hour))
+	created := now.Add(-60 * 24 * time.Hour)
+	flags := &mockFlagStore{
+		flags: []model.Flag{
+			makeFlag("stale-a", "proj-1", model.FlagTypeRelease, model.LifecyclePotentiallyStale, created, pastGrace),
+			makeFlag("stale-b", "proj-2", model.FlagTypeRelease, model.LifecyclePotentiallyStale, created, pastGrace),
+			makeFlag("stale-c", "proj-1", model.FlagTypeRelease, model.LifecyclePotentiallyStale, created, pastGrace),
+			makeFlag("expiring", "proj-1", model.FlagTypeRelease, model.LifecycleActive, created, nil),
+			makeFlag("already-stale", "proj-1", model.FlagTypeRelease, model.LifecycleStale, created, pastGrace),
+		},
+	}
+	notifier := &mockNotifier{}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+	c.SetNotifier(notifier)
+
+	c.tick(context.Background())
+
+	if len(notifier.digests) != 1 {
+		t.Fatalf("expected 1 digest, got %d", len(notifier.digests))
+	}
+	got := map[string][]string{}
+	for _, p := range notifier.digests[0].Projects {
+		for _, f := range p.Flags {
+			got[p.ProjectID] = append(got[p.ProjectID], f.Key)
+		}
+	}
+	want := map[string][]string{"proj-1": {"stale-a", "stale-c"}, "proj-2": {"stale-b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("digest flags = %v, want %v", got, want)
+	}
+
+	// Once promoted, the flags stay stale and are not announced again.
+	for i := range flags.flags {
+		if flags.flags[i].LifecycleStatus == model.LifecyclePotentiallyStale {
+			flags.flags[i].LifecycleStatus = model.LifecycleStale
+		}
+	}
+	flags.flags = flags.flags[:3]
+	c.tick(context.Background())
+	if len(notifier.digests) != 1 {
+		t.Errorf("expected no digest for a tick without new stale flags, got %d digests", len(notifier.digests))
+	}
+}
+
+func TestTick_UsageWindowKeepsEvaluatedFlagsActive(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	created := now.Add(-50 * 24 * time.Hour) // past the 40-day release lifetime
+
+	inUse := makeFlag("in-use", "proj-1", model.FlagTypeRelease, model.LifecycleActive, created, nil)
+	inUse.LastEvaluatedAt = timePtr(now.Add(-2 * 24 * time.Hour))
+	dormant := makeFlag("dormant", "proj-1", model.FlagTypeRelease, model.LifecycleActive, created, nil)
+	dormant.LastEvaluatedAt = timePtr(now.Add(-20 * 24 * time.Hour))
+	neverEvaluated := makeFlag("never-evaluated", "proj-1", model.FlagTypeRelease, model.LifecycleActive, created, nil)
+	young := makeFlag("young", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-10*24*time.Hour), nil)
+
+	flags := &mockFlagStore{flags: []model.Flag{inUse, dormant, neverEvaluated, young}}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+	c.SetUsageWindow(7 * 24 * time.Hour)
+
+	c.tick(context.Background())
+
+	want := []promotion{
+		{"dormant-id", model.LifecyclePotentiallyStale},
+		{"never-evaluated-id", model.LifecyclePotentiallyStale},
+	}
+	if !reflect.DeepEqual(flags.promoted, want) {
+		t.Errorf("promotions = %v, want %v", flags.promoted, want)
+	}
+}
+
+func TestTick_UsageWindowDisabledPromotesByAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := makeFlag("in-use", "proj-1", model.FlagTypeRelease, model.LifecycleActive, now.Add(-50*24*time.Hour), nil)
+	f.LastEvaluatedAt = timePtr(now.Add(-time.Hour))
+
+	flags := &mockFlagStore{flags: []model.Flag{f}}
+	c := &Checker{
+		flags:    flags,
+		settings: &mockSettingsStore{},
+		audit:    &mockAudit{},
+		cache:    &mockCache{},
+		now:      func() time.Time { return now },
+	}
+
+	c.tick(context.Background())
+
+	if len(flags.promoted) != 1 {
+		t.Fatalf("expected 1 promotion, got %d", len(flags.promoted))
+	}
+}
+
+type mockUsageStore struct {
+	marked    [][]string
+	returnErr error
+}
+
+func (m *mockUsageStore) MarkEvaluated(_ context.Context, flagIDs []string, _ time.Time) error {
+	if m.returnErr != nil {
+		return m.returnErr
+	}
+	m.marked = append(m.marked, flagIDs)
+	return nil
+}
+
+func TestUsageTracker_SamplesWrites(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &mockUsageStore{}
+	tracker := NewUsageTracker(store, time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("flag-1")
+	tracker.Record("flag-1")
+	tracker.Flush(context.Background())
+	if want := [][]string{{"flag-1"}}; !reflect.DeepEqual(store.marked, want) {
+		t.Fatalf("marked = %v, want %v", store.marked, want)
+	}
+
+	// Within the resolution, further evaluations are not written again.
+	now = now.Add(30 * time.Minute)
+	tracker.Record("flag-1")
+	tracker.Flush(context.Background())
+	if len(store.marked) != 1 {
+		t.Fatalf("expected no write within the resolution, got %v", store.marked)
+	}
+
+	now = now.Add(usageResolution)
+	tracker.Record("flag-1")
+	tracker.Flush(context.Background())
+	if len(store.marked) != 2 {
+		t.Fatalf("expected a write after the resolution, got %v", store.marked)
+	}
+}
+
+func TestUsageTracker_RetriesFailedFlush(t *testing.T) {
+	store := &mockUsageStore{returnErr: context.DeadlineExceeded}
+	tracker := NewUsageTracker(store, time.Minute)
+
+	tracker.Record("flag-1")
+	tracker.Flush(context.Background())
+
+	store.returnErr = nil
+	tracker.Flush(context.Background())
+	if want := [][]string{{"flag-1"}}; !reflect.DeepEqual(store.marked, want) {
+		t.Errorf("marked = %v, want %v", store.marked, want)
+	}
+}
@@ -0,0 +1,86 @@
+package staleness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// StaleDigest lists the flags that became stale during one checker tick,
+// grouped by project.
+type StaleDigest struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Projects    []StaleProject `json:"projects"`
+}
+
+// StaleProject is one project's part of a StaleDigest.
+type StaleProject struct {
+	ProjectID string      `json:"project_id"`
+	Flags     []StaleFlag `json:"flags"`
+}
+
+// StaleFlag identifies a flag that was promoted to stale.
+type StaleFlag struct {
+	Key      string         `json:"key"`
+	Name     string         `json:"name"`
+	FlagType model.FlagType `json:"flag_type"`
+}
+
+// StaleNotifier is the interface for announcing flags that became stale.
+type StaleNotifier interface {
+	NotifyStale(ctx context.Context, digest StaleDigest) error
+}
+
+// WebhookNotifier posts each digest as JSON to a URL.
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NotifyStale posts the digest and fails unless the webhook answers 2xx.
+func (n *WebhookNotifier) NotifyStale(ctx context.Context, digest StaleDigest) error {
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("encoding stale digest: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting stale digest: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting stale digest: webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// buildDigest groups flags by project, keeping the order they were promoted.
+func buildDigest(flags []model.Flag, now time.Time) StaleDigest {
+	digest := StaleDigest{GeneratedAt: now}
+	index := make(map[string]int)
+	for _, f := range flags {
+		i, ok := index[f.ProjectID]
+		if !ok {
+			i = len(digest.Projects)
+			index[f.ProjectID] = i
+			digest.Projects = append(digest.Projects, StaleProject{ProjectID: f.ProjectID})
+		}
+		digest.Projects[i].Flags = append(digest.Projects[i].Flags, StaleFlag{Key: f.Key, Name: f.Name, FlagType: f.FlagType})
+	}
+	return digest
+}
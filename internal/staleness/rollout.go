@@ -0,0 +1,117 @@
+package staleness
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// fullyRolledOutSince reports whether every environment config serves the
+// flag's new behavior to all users, and if so returns the most recent config
+// change, i.e. how long the flag has been fully rolled out everywhere.
+func fullyRolledOutSince(flag model.Flag, configs []model.FlagEnvironmentConfig) (time.Time, bool) {
+	if len(configs) == 0 {
+		return time.Time{}, false
+	}
+	var since time.Time
+	for _, cfg := range configs {
+		if !servesRolloutToAll(flag, cfg) {
+			return time.Time{}, false
+		}
+		if cfg.UpdatedAt.After(since) {
+			since = cfg.UpdatedAt
+		}
+	}
+	return since, true
+}
+
+// servesRolloutToAll reports whether an enabled config serves the same
+// variant to every context and that variant's value differs from the flag's
+// default, which is what the flag serves when disabled. Prerequisites make
+// the result depend on other flags, so a gated config never qualifies, and
+// neither does one that excludes users or includes them in another variant.
+func servesRolloutToAll(flag model.Flag, cfg model.FlagEnvironmentConfig) bool {
+	if !cfg.Enabled || len(cfg.Prerequisites) > 0 || len(cfg.ExcludedUsers) > 0 {
+		return false
+	}
+	variant, ok := uniformVariant(cfg)
+	if !ok {
+		return false
+	}
+	if len(cfg.IncludedUsers) > 0 {
+		included := cfg.IncludedVariant
+		if included == "" {
+			included = cfg.DefaultVariant
+		}
+		if included != variant {
+			return false
+		}
+	}
+	for _, v := range cfg.Variants {
+		if v.Key == variant {
+			return !jsonEqual(v.Value, flag.DefaultValue)
+		}
+	}
+	return false
+}
+
+// uniformVariant returns the variant every context receives: that of the first
+// unconditional rule at 100% rollout, or the default variant (or the single
+// arm of the default rollout) when no such rule exists. Any earlier rule
+// serving a different variant makes the result depend on the context.
+func uniformVariant(cfg model.FlagEnvironmentConfig) (string, bool) {
+	served, reachable := cfg.DefaultVariant, cfg.TargetingRules
+	split := false
+	if len(cfg.DefaultRollout) > 0 {
+		variant, ok := singleArm(cfg.DefaultRollout)
+		served, split = variant, !ok
+	}
+	for i, rule := range cfg.TargetingRules {
+		if len(rule.Conditions) == 0 && rule.Group == nil && !rule.Negate && (rule.PercentageRollout == nil || *rule.PercentageRollout >= 100) {
+			variant, ok := ruleVariant(rule)
+			if !ok {
+				return "", false
+			}
+			served, reachable, split = variant, cfg.TargetingRules[:i], false
+			break
+		}
+	}
+	if split {
+		return "", false
+	}
+	for _, rule := range reachable {
+		if variant, ok := ruleVariant(rule); !ok || variant != served {
+			return "", false
+		}
+	}
+	return served, true
+}
+
+// ruleVariant returns the single variant a rule serves. A weighted rule only
+// has one if a single arm carries all the weight.
+func ruleVariant(rule model.TargetingRule) (string, bool) {
+	if len(rule.VariantWeights) == 0 {
+		return rule.Variant, true
+	}
+	return singleArm(rule.VariantWeights)
+}
+
+// singleArm returns the variant of a split whose weight is all on one arm.
+func singleArm(weights []model.VariantWeight) (string, bool) {
+	for _, w := range weights {
+		if w.Weight == 100 {
+			return w.Variant, true
+		}
+	}
+	return "", false
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
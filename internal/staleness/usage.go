@@ -0,0 +1,113 @@
+package staleness
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// usageResolution is how often a flag's last evaluation time is persisted
+// per instance. Evaluations within this long of the last recorded one are
+// not written, so busy flags cost one update per hour rather than one per
+// request.
+const usageResolution = time.Hour
+
+// UsageStore is the interface for persisting when flags were last evaluated.
+type UsageStore interface {
+	MarkEvaluated(ctx context.Context, flagIDs []string, at time.Time) error
+}
+
+// UsageTracker records which flags SDKs are evaluating and flushes their
+// last evaluation time to the store in batches.
+type UsageTracker struct {
+	store    UsageStore
+	interval time.Duration
+	now      func() time.Time // injectable for testing
+
+	mu sync.Mutex
+	// pending holds flags evaluated since the last flush.
+	pending map[string]struct{}
+	// recorded holds when each flag's evaluation time was last persisted.
+	recorded map[string]time.Time
+}
+
+// NewUsageTracker creates a tracker that flushes evaluations to store every
+// interval.
+func NewUsageTracker(store UsageStore, interval time.Duration) *UsageTracker {
+	return &UsageTracker{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[string]struct{}),
+		recorded: make(map[string]time.Time),
+	}
+}
+
+// Record notes that a flag was evaluated. It is cheap enough to call for
+// every flag served.
+func (t *UsageTracker) Record(flagID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.recorded[flagID]; ok && t.now().Sub(last) < usageResolution {
+		return
+	}
+	t.pending[flagID] = struct{}{}
+}
+
+// Flush persists the flags evaluated since the last flush. Flags that fail to
+// persist are retried on the next flush.
+func (t *UsageTracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	ids := make([]string, 0, len(t.pending))
+	for id := range t.pending {
+		ids = append(ids, id)
+	}
+	t.pending = make(map[string]struct{})
+	now := t.now()
+	t.mu.Unlock()
+
+	err := t.store.MarkEvaluated(ctx, ids, now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		slog.Error("usage tracker: failed to flush flag evaluations", "flags", len(ids), "error", err)
+		for _, id := range ids {
+			t.pending[id] = struct{}{}
+		}
+		return
+	}
+	for _, id := range ids {
+		t.recorded[id] = now
+	}
+	// Forget flags that have gone quiet so deleted flags don't accumulate.
+	for id, at := range t.recorded {
+		if now.Sub(at) >= usageResolution {
+			delete(t.recorded, id)
+		}
+	}
+}
+
+// Run flushes evaluations every interval until ctx is cancelled. Call Flush
+// during shutdown to persist the final evaluations.
+func (t *UsageTracker) Run(ctx context.Context) {
+	slog.Info("usage tracker started", "interval", t.interval)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("usage tracker stopped")
+			return
+		case <-ticker.C:
+			t.Flush(ctx)
+		}
+	}
+}
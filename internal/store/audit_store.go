@@ -3,8 +3,10 @@ package store
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/logging"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
@@ -16,12 +18,16 @@ func NewAuditStore(pool *pgxpool.Pool) *AuditStore {
 	return &AuditStore{pool: pool}
 }
 
-// Record inserts an audit log entry.
+// Record inserts an audit log entry. An entry without a RequestID takes the
+// request ID from ctx, if any.
 func (s *AuditStore) Record(ctx context.Context, entry model.AuditEntry) error {
+	if entry.RequestID == "" {
+		entry.RequestID = logging.RequestIDFromContext(ctx)
+	}
 	_, err := s.pool.Exec(ctx,
-		`INSERT INTO audit_log (project_id, user_id, action, entity_type, entity_id, old_value, new_value)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		entry.ProjectID, entry.UserID, entry.Action, entry.EntityType, entry.EntityID, entry.OldValue, entry.NewValue,
+		`INSERT INTO audit_log (project_id, user_id, action, entity_type, entity_id, old_value, new_value, diff, request_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))`,
+		entry.ProjectID, entry.UserID, entry.Action, entry.EntityType, entry.EntityID, entry.OldValue, entry.NewValue, entry.Diff, entry.RequestID,
 	)
 	if err != nil {
 		return fmt.Errorf("recording audit entry: %w", err)
@@ -29,28 +35,139 @@ func (s *AuditStore) Record(ctx context.Context, entry model.AuditEntry) error {
 	return nil
 }
 
-// ListByProject returns audit entries for a project, ordered by created_at DESC, with pagination.
-func (s *AuditStore) ListByProject(ctx context.Context, projectID string, limit, offset int) ([]model.AuditEntry, error) {
-	rows, err := s.pool.Query(ctx,
-		`SELECT id, project_id, user_id, action, entity_type, entity_id, old_value, new_value, created_at
-		 FROM audit_log WHERE project_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
-		projectID, limit, offset,
-	)
+// auditDeleteBatchSize caps the rows one DeleteOlderThan statement removes,
+// so pruning a large backlog never holds locks for long.
+const auditDeleteBatchSize = 1000
+
+// DeleteOlderThan removes a project's audit entries created before cutoff,
+// in batches of auditDeleteBatchSize, and returns how many were removed. An
+// empty projectID targets the entries that belong to no project.
+func (s *AuditStore) DeleteOlderThan(ctx context.Context, projectID string, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM audit_log WHERE id IN (
+		 SELECT id FROM audit_log WHERE project_id = $1 AND created_at < $2 LIMIT $3)`
+	args := []any{projectID, cutoff, auditDeleteBatchSize}
+	if projectID == "" {
+		query = `DELETE FROM audit_log WHERE id IN (
+		 SELECT id FROM audit_log WHERE project_id IS NULL AND created_at < $1 LIMIT $2)`
+		args = args[1:]
+	}
+
+	var deleted int64
+	for {
+		tag, err := s.pool.Exec(ctx, query, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("deleting old audit entries: %w", err)
+		}
+		deleted += tag.RowsAffected()
+		if tag.RowsAffected() < auditDeleteBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// AuditFilter narrows an audit log listing. Empty fields and nil times are
+// ignored; From and To are inclusive bounds on created_at.
+type AuditFilter struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	UserID     string
+	From       *time.Time
+	To         *time.Time
+}
+
+// ListByProject returns audit entries for a project matching the filter,
+// ordered by created_at DESC, with pagination. The second return value is the
+// total number of matching entries, ignoring limit and offset.
+func (s *AuditStore) ListByProject(ctx context.Context, projectID string, filter AuditFilter, limit, offset int) ([]model.AuditEntry, int, error) {
+	where, args := auditWhere(projectID, filter)
+	argIdx := len(args) + 1
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting audit entries: %w", err)
+	}
+
+	query := `SELECT id, project_id, user_id, action, entity_type, entity_id, old_value, new_value, diff, COALESCE(request_id, ''), created_at
+		 FROM audit_log` + where + fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argIdx, argIdx+1)
+	args = append(args, limit, offset)
+
+	var entries []model.AuditEntry
+	err := s.each(ctx, query, args, func(e model.AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Export calls fn for every audit entry of a project matching the filter,
+// ordered by created_at DESC. Rows are read from the cursor one at a time so
+// the full log is never held in memory; an error from fn stops the iteration.
+func (s *AuditStore) Export(ctx context.Context, projectID string, filter AuditFilter, fn func(model.AuditEntry) error) error {
+	where, args := auditWhere(projectID, filter)
+	query := `SELECT id, project_id, user_id, action, entity_type, entity_id, old_value, new_value, diff, COALESCE(request_id, ''), created_at
+		 FROM audit_log` + where + ` ORDER BY created_at DESC`
+	return s.each(ctx, query, args, fn)
+}
+
+func (s *AuditStore) each(ctx context.Context, query string, args []any, fn func(model.AuditEntry) error) error {
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("listing audit entries: %w", err)
+		return fmt.Errorf("listing audit entries: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []model.AuditEntry
 	for rows.Next() {
 		var e model.AuditEntry
-		if err := rows.Scan(&e.ID, &e.ProjectID, &e.UserID, &e.Action, &e.EntityType, &e.EntityID, &e.OldValue, &e.NewValue, &e.CreatedAt); err != nil {
-			return nil, fmt.Errorf("scanning audit entry: %w", err)
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.UserID, &e.Action, &e.EntityType, &e.EntityID, &e.OldValue, &e.NewValue, &e.Diff, &e.RequestID, &e.CreatedAt); err != nil {
+			return fmt.Errorf("scanning audit entry: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
 		}
-		entries = append(entries, e)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating audit entries: %w", err)
+		return fmt.Errorf("iterating audit entries: %w", err)
 	}
-	return entries, nil
+	return nil
+}
+
+// auditWhere builds the WHERE clause and arguments for a filtered listing.
+func auditWhere(projectID string, filter AuditFilter) (string, []any) {
+	where := ` WHERE project_id = $1`
+	args := []any{projectID}
+	argIdx := 2
+
+	for _, f := range []struct {
+		column string
+		value  string
+	}{
+		{"action", filter.Action},
+		{"entity_type", filter.EntityType},
+		{"entity_id", filter.EntityID},
+		// Compared as text so a malformed ID matches nothing instead of erroring.
+		{"user_id::text", filter.UserID},
+	} {
+		if f.value != "" {
+			where += fmt.Sprintf(" AND %s = $%d", f.column, argIdx)
+			args = append(args, f.value)
+			argIdx++
+		}
+	}
+
+	if filter.From != nil {
+		where += fmt.Sprintf(" AND created_at >= $%d", argIdx)
+		args = append(args, *filter.From)
+		argIdx++
+	}
+
+	if filter.To != nil {
+		where += fmt.Sprintf(" AND created_at <= $%d", argIdx)
+		args = append(args, *filter.To)
+	}
+
+	return where, args
 }
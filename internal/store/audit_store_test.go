@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
@@ -92,7 +93,7 @@ func TestAuditStore_ListByProject(t *testing.T) {
 	}
 
 	// List with limit and offset
-	entries, err := as.ListByProject(ctx, project.ID, 50, 0)
+	entries, _, err := as.ListByProject(ctx, project.ID, store.AuditFilter{}, 50, 0)
 	if err != nil {
 		t.Fatalf("ListByProject: %v", err)
 	}
@@ -159,16 +160,19 @@ func TestAuditStore_ListByProject_Pagination(t *testing.T) {
 	}
 
 	// Fetch first page (limit=2)
-	page1, err := as.ListByProject(ctx, project.ID, 2, 0)
+	page1, total, err := as.ListByProject(ctx, project.ID, store.AuditFilter{}, 2, 0)
 	if err != nil {
 		t.Fatalf("ListByProject page1: %v", err)
 	}
 	if len(page1) != 2 {
 		t.Fatalf("page1: expected 2 entries, got %d", len(page1))
 	}
+	if total != 5 {
+		t.Errorf("expected total 5, got %d", total)
+	}
 
 	// Fetch second page (limit=2, offset=2)
-	page2, err := as.ListByProject(ctx, project.ID, 2, 2)
+	page2, _, err := as.ListByProject(ctx, project.ID, store.AuditFilter{}, 2, 2)
 	if err != nil {
 		t.Fatalf("ListByProject page2: %v", err)
 	}
@@ -195,7 +199,7 @@ func TestAuditStore_ListByProject_Empty(t *testing.T) {
 		t.Fatalf("Create project: %v", err)
 	}
 
-	entries, err := as.ListByProject(ctx, project.ID, 50, 0)
+	entries, _, err := as.ListByProject(ctx, project.ID, store.AuditFilter{}, 50, 0)
 	if err != nil {
 		t.Fatalf("ListByProject: %v", err)
 	}
@@ -204,3 +208,138 @@ func TestAuditStore_ListByProject_Empty(t *testing.T) {
 		t.Errorf("expected nil for empty result, got %d entries", len(entries))
 	}
 }
+
+func TestAuditStore_ListByProject_Filters(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	us := store.NewUserStore(pool)
+	as := store.NewAuditStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("audit-filter"), "Filter Audit Project", "")
+	if err != nil {
+		t.Fatalf("Create project: %v", err)
+	}
+	user, err := us.Create(ctx, uniqueEmail("audit-filter"), "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+
+	seed := []model.AuditEntry{
+		{Action: "create", EntityType: "flag", EntityID: "checkout", UserID: &user.ID},
+		{Action: "update", EntityType: "flag", EntityID: "checkout", UserID: &user.ID},
+		{Action: "update", EntityType: "flag", EntityID: "banner"},
+		{Action: "create", EntityType: "segment", EntityID: "beta", UserID: &user.ID},
+	}
+	for i, e := range seed {
+		e.ProjectID = &project.ID
+		if err := as.Record(ctx, e); err != nil {
+			t.Fatalf("Record %d: %v", i, err)
+		}
+	}
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter store.AuditFilter
+		want   int
+	}{
+		{"action", store.AuditFilter{Action: "update"}, 2},
+		{"entity_type", store.AuditFilter{EntityType: "segment"}, 1},
+		{"entity_id", store.AuditFilter{EntityID: "checkout"}, 2},
+		{"user_id", store.AuditFilter{UserID: user.ID}, 3},
+		{"malformed user_id", store.AuditFilter{UserID: "not-a-uuid"}, 0},
+		{"from", store.AuditFilter{From: &past}, 4},
+		{"from in future", store.AuditFilter{From: &future}, 0},
+		{"to", store.AuditFilter{To: &future}, 4},
+		{"to in past", store.AuditFilter{To: &past}, 0},
+		{"combined", store.AuditFilter{Action: "update", EntityType: "flag", UserID: user.ID, From: &past, To: &future}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := as.ListByProject(ctx, project.ID, tt.filter, 50, 0)
+			if err != nil {
+				t.Fatalf("ListByProject: %v", err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("expected %d entries, got %d", tt.want, len(entries))
+			}
+			if total != tt.want {
+				t.Errorf("expected total %d, got %d", tt.want, total)
+			}
+		})
+	}
+
+	// Pagination applies after filtering, and total ignores the limit.
+	entries, total, err := as.ListByProject(ctx, project.ID, store.AuditFilter{EntityType: "flag"}, 1, 0)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(entries) != 1 || total != 3 {
+		t.Errorf("expected 1 entry of 3, got %d of %d", len(entries), total)
+	}
+	if entries[0].EntityID != "banner" {
+		t.Errorf("expected newest entry first, got %q", entries[0].EntityID)
+	}
+}
+
+func TestAuditStore_DeleteOlderThan(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	as := store.NewAuditStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("audit-prune"), "Prune Project", "")
+	if err != nil {
+		t.Fatalf("Create project: %v", err)
+	}
+	other, err := ps.Create(ctx, uniqueKey("audit-keep"), "Other Project", "")
+	if err != nil {
+		t.Fatalf("Create other project: %v", err)
+	}
+
+	record := func(projectID, entityID string, age time.Duration) {
+		t.Helper()
+		if err := as.Record(ctx, model.AuditEntry{ProjectID: &projectID, Action: "update", EntityType: "flag", EntityID: entityID}); err != nil {
+			t.Fatalf("Record %s: %v", entityID, err)
+		}
+		if _, err := pool.Exec(ctx,
+			`UPDATE audit_log SET created_at = $3 WHERE project_id = $1 AND entity_id = $2`,
+			projectID, entityID, time.Now().Add(-age),
+		); err != nil {
+			t.Fatalf("backdating %s: %v", entityID, err)
+		}
+	}
+	record(project.ID, "old-a", 100*24*time.Hour)
+	record(project.ID, "old-b", 40*24*time.Hour)
+	record(project.ID, "recent", time.Hour)
+	record(other.ID, "other-old", 100*24*time.Hour)
+
+	deleted, err := as.DeleteOlderThan(ctx, project.ID, time.Now().Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteOlderThan: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 entries deleted, got %d", deleted)
+	}
+
+	entries, total, err := as.ListByProject(ctx, project.ID, store.AuditFilter{}, 50, 0)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if total != 1 || len(entries) != 1 || entries[0].EntityID != "recent" {
+		t.Errorf("expected only the recent entry to remain, got %d: %+v", total, entries)
+	}
+
+	// Other projects are untouched.
+	_, total, err = as.ListByProject(ctx, other.ID, store.AuditFilter{}, 50, 0)
+	if err != nil {
+		t.Fatalf("ListByProject other: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected the other project to keep its entry, got %d", total)
+	}
+}
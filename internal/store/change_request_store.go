@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// changeRequestSelect selects the columns scanned by scanChangeRequest.
+const changeRequestSelect = `SELECT cr.id, cr.project_id, cr.flag_id, f.key, cr.environment_id, e.key,
+	cr.proposed, cr.previous, cr.status, cr.requested_by, cr.reviewed_by, cr.reviewed_at, cr.created_at
+	FROM change_requests cr
+	JOIN flags f ON f.id = cr.flag_id
+	JOIN environments e ON e.id = cr.environment_id`
+
+type ChangeRequestStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewChangeRequestStore(pool *pgxpool.Pool) *ChangeRequestStore {
+	return &ChangeRequestStore{pool: pool}
+}
+
+// Create records a pending change request. previous may be nil.
+func (s *ChangeRequestStore) Create(ctx context.Context, projectID, flagID, environmentID, requestedBy string, proposed model.ProposedConfig, previous *model.FlagEnvironmentConfig) (*model.ChangeRequest, error) {
+	proposedJSON, err := json.Marshal(proposed)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling proposed config: %w", err)
+	}
+	var previousJSON []byte
+	if previous != nil {
+		if previousJSON, err = json.Marshal(previous); err != nil {
+			return nil, fmt.Errorf("marshaling previous config: %w", err)
+		}
+	}
+
+	var id string
+	err = s.pool.QueryRow(ctx,
+		`INSERT INTO change_requests (project_id, flag_id, environment_id, proposed, previous, requested_by)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		projectID, flagID, environmentID, proposedJSON, previousJSON, requestedBy,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("creating change request: %w", err)
+	}
+	return s.FindByID(ctx, projectID, id)
+}
+
+// ListByProject returns a project's change requests, newest first. An empty
+// status returns every status.
+func (s *ChangeRequestStore) ListByProject(ctx context.Context, projectID string, status model.ChangeRequestStatus) ([]model.ChangeRequest, error) {
+	rows, err := s.pool.Query(ctx,
+		changeRequestSelect+` WHERE cr.project_id = $1 AND ($2 = '' OR cr.status = $2) ORDER BY cr.created_at DESC`,
+		projectID, string(status),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing change requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []model.ChangeRequest
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *cr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating change requests: %w", err)
+	}
+	return requests, nil
+}
+
+// FindByID returns a change request of a project.
+func (s *ChangeRequestStore) FindByID(ctx context.Context, projectID, id string) (*model.ChangeRequest, error) {
+	cr, err := scanChangeRequest(s.pool.QueryRow(ctx,
+		changeRequestSelect+` WHERE cr.project_id = $1 AND cr.id = $2`,
+		projectID, id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding change request: %w", err)
+	}
+	return cr, nil
+}
+
+// Resolve moves a pending change request to status. The conditional UPDATE
+// ensures only one concurrent review succeeds; it returns false if the
+// request was no longer pending.
+func (s *ChangeRequestStore) Resolve(ctx context.Context, id string, status model.ChangeRequestStatus, reviewerID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE change_requests SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		 WHERE id = $1 AND status = 'pending'`,
+		id, status, reviewerID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("resolving change request: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Reopen returns a resolved change request to pending, for when applying an
+// approval fails.
+func (s *ChangeRequestStore) Reopen(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE change_requests SET status = 'pending', reviewed_by = NULL, reviewed_at = NULL WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("reopening change request: %w", err)
+	}
+	return nil
+}
+
+func scanChangeRequest(row pgx.Row) (*model.ChangeRequest, error) {
+	var cr model.ChangeRequest
+	var proposedJSON, previousJSON []byte
+	err := row.Scan(&cr.ID, &cr.ProjectID, &cr.FlagID, &cr.FlagKey, &cr.EnvironmentID, &cr.EnvironmentKey,
+		&proposedJSON, &previousJSON, &cr.Status, &cr.RequestedBy, &cr.ReviewedBy, &cr.ReviewedAt, &cr.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning change request: %w", err)
+	}
+	if err := json.Unmarshal(proposedJSON, &cr.Proposed); err != nil {
+		return nil, fmt.Errorf("decoding proposed config: %w", err)
+	}
+	if previousJSON != nil {
+		if err := json.Unmarshal(previousJSON, &cr.Previous); err != nil {
+			return nil, fmt.Errorf("decoding previous config: %w", err)
+		}
+	}
+	return &cr, nil
+}
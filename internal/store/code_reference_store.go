@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type CodeReferenceStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewCodeReferenceStore(pool *pgxpool.Pool) *CodeReferenceStore {
+	return &CodeReferenceStore{pool: pool}
+}
+
+// Upsert records code references for a flag in one transaction. A reference
+// to a repo, file and line the flag already has replaces the stored SHA, so
+// re-uploading a scan does not create duplicates.
+func (s *CodeReferenceStore) Upsert(ctx context.Context, flagID string, refs []model.CodeReference) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, ref := range refs {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO code_references (flag_id, repo, file, line, sha)
+			 VALUES ($1, $2, $3, $4, $5)
+			 ON CONFLICT (flag_id, repo, file, line) DO UPDATE
+			 SET sha = EXCLUDED.sha, updated_at = NOW()`,
+			flagID, ref.Repo, ref.File, ref.Line, ref.SHA,
+		)
+		if err != nil {
+			return fmt.Errorf("upserting code reference: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// ListByFlag returns a flag's code references ordered by repo, file and line.
+func (s *CodeReferenceStore) ListByFlag(ctx context.Context, flagID string) ([]model.CodeReference, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, flag_id, repo, file, line, sha, updated_at
+		 FROM code_references
+		 WHERE flag_id = $1
+		 ORDER BY repo, file, line`,
+		flagID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing code references: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []model.CodeReference
+	for rows.Next() {
+		var ref model.CodeReference
+		if err := rows.Scan(&ref.ID, &ref.FlagID, &ref.Repo, &ref.File, &ref.Line, &ref.SHA, &ref.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning code reference: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating code references: %w", err)
+	}
+	return refs, nil
+}
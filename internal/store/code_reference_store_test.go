@@ -0,0 +1,122 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestCodeReferenceStore_UpsertAndList(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	crs := store.NewCodeReferenceStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("coderefs"), "Code Refs Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	other, err := fs.Create(ctx, project.ID, "other-flag", "Other", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating other flag: %v", err)
+	}
+
+	err = crs.Upsert(ctx, flag.ID, []model.CodeReference{
+		{Repo: "acme/web", File: "src/app.ts", Line: 42, SHA: "aaa"},
+		{Repo: "acme/api", File: "main.go", Line: 7, SHA: "bbb"},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := crs.Upsert(ctx, other.ID, []model.CodeReference{{Repo: "acme/web", File: "src/app.ts", Line: 42, SHA: "aaa"}}); err != nil {
+		t.Fatalf("Upsert other: %v", err)
+	}
+
+	refs, err := crs.ListByFlag(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("ListByFlag: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 references, got %d", len(refs))
+	}
+	if refs[0].Repo != "acme/api" || refs[1].Repo != "acme/web" {
+		t.Errorf("expected references ordered by repo, got %q then %q", refs[0].Repo, refs[1].Repo)
+	}
+	if refs[1].File != "src/app.ts" || refs[1].Line != 42 || refs[1].SHA != "aaa" || refs[1].FlagID != flag.ID {
+		t.Errorf("unexpected reference: %+v", refs[1])
+	}
+}
+
+func TestCodeReferenceStore_UpsertDedupsByLocation(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	crs := store.NewCodeReferenceStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("coderefsdedup"), "Code Refs Dedup", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	if err := crs.Upsert(ctx, flag.ID, []model.CodeReference{{Repo: "acme/web", File: "src/app.ts", Line: 42, SHA: "old"}}); err != nil {
+		t.Fatalf("first Upsert: %v", err)
+	}
+	// A rescan finds the same location again, plus a duplicate within the batch.
+	err = crs.Upsert(ctx, flag.ID, []model.CodeReference{
+		{Repo: "acme/web", File: "src/app.ts", Line: 42, SHA: "new"},
+		{Repo: "acme/web", File: "src/app.ts", Line: 42, SHA: "new"},
+		{Repo: "acme/web", File: "src/app.ts", Line: 43, SHA: "new"},
+	})
+	if err != nil {
+		t.Fatalf("second Upsert: %v", err)
+	}
+
+	refs, err := crs.ListByFlag(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("ListByFlag: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 references after dedup, got %d", len(refs))
+	}
+	if refs[0].Line != 42 || refs[0].SHA != "new" {
+		t.Errorf("expected line 42 updated to sha new, got %+v", refs[0])
+	}
+}
+
+func TestCodeReferenceStore_ListByFlag_Empty(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	crs := store.NewCodeReferenceStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("coderefsempty"), "Code Refs Empty", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	refs, err := crs.ListByFlag(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("ListByFlag: %v", err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("expected no references, got %d", len(refs))
+	}
+}
@@ -16,19 +16,61 @@ func NewContextAttributeStore(pool *pgxpool.Pool) *ContextAttributeStore {
 	return &ContextAttributeStore{pool: pool}
 }
 
-// UpsertByProjectKey inserts or updates context attributes for a project identified by key.
-// Uses a single query that resolves the project key to ID and unnests the attribute names.
+// MaxAttributeValues is how many distinct values are kept per attribute.
+const MaxAttributeValues = 10
+
+// UpsertByProjectKey inserts or updates context attributes for a project
+// identified by key, without sampling any values.
 func (s *ContextAttributeStore) UpsertByProjectKey(ctx context.Context, projectKey string, names []string) error {
-	if len(names) == 0 {
+	samples := make(map[string][]string, len(names))
+	for _, name := range names {
+		samples[name] = nil
+	}
+	return s.UpsertSamplesByProjectKey(ctx, projectKey, samples)
+}
+
+// UpsertSamplesByProjectKey inserts or updates context attributes for a
+// project identified by key. samples maps each attribute name to values seen
+// for it, most recent first. They are merged ahead of the stored values,
+// keeping at most MaxAttributeValues distinct ones per attribute.
+func (s *ContextAttributeStore) UpsertSamplesByProjectKey(ctx context.Context, projectKey string, samples map[string][]string) error {
+	if len(samples) == 0 {
 		return nil
 	}
 
+	// Flatten into one (name, value) row per value, and a row with a NULL
+	// value for names without any, since Postgres has no ragged arrays.
+	var names []string
+	var values []*string
+	for name, vals := range samples {
+		vals = distinctValues(vals, MaxAttributeValues)
+		if len(vals) == 0 {
+			names = append(names, name)
+			values = append(values, nil)
+		}
+		for i := range vals {
+			names = append(names, name)
+			values = append(values, &vals[i])
+		}
+	}
+
 	_, err := s.pool.Exec(ctx,
-		`INSERT INTO context_attributes (project_id, name)
-		 SELECT p.id, unnest($2::text[])
-		 FROM projects p WHERE p.key = $1
-		 ON CONFLICT (project_id, name) DO UPDATE SET last_seen_at = NOW()`,
-		projectKey, names,
+		`WITH input AS (
+		     SELECT name, COALESCE(array_agg(v ORDER BY n) FILTER (WHERE v IS NOT NULL), '{}') AS vals
+		     FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS i(name, v, n)
+		     GROUP BY name
+		 )
+		 INSERT INTO context_attributes (project_id, name, sample_values)
+		 SELECT p.id, input.name, input.vals
+		 FROM projects p, input
+		 WHERE p.key = $1
+		 ON CONFLICT (project_id, name) DO UPDATE
+		 SET last_seen_at = NOW(),
+		     sample_values = ARRAY(
+		         SELECT v FROM unnest(EXCLUDED.sample_values || context_attributes.sample_values) WITH ORDINALITY AS u(v, n)
+		         GROUP BY v ORDER BY min(n) LIMIT $4
+		     )`,
+		projectKey, names, values, MaxAttributeValues,
 	)
 	if err != nil {
 		return fmt.Errorf("upserting context attributes: %w", err)
@@ -36,10 +78,27 @@ func (s *ContextAttributeStore) UpsertByProjectKey(ctx context.Context, projectK
 	return nil
 }
 
+// distinctValues returns the first max distinct entries of vals.
+func distinctValues(vals []string, max int) []string {
+	out := make([]string, 0, min(len(vals), max))
+	seen := make(map[string]struct{}, len(vals))
+	for _, v := range vals {
+		if len(out) == max {
+			break
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
 // ListByProject returns all context attributes for a project, ordered alphabetically by name.
 func (s *ContextAttributeStore) ListByProject(ctx context.Context, projectID string) ([]model.ContextAttribute, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, project_id, name, last_seen_at
+		`SELECT id, project_id, name, sample_values, last_seen_at
 		 FROM context_attributes WHERE project_id = $1 ORDER BY name`,
 		projectID,
 	)
@@ -51,7 +110,7 @@ func (s *ContextAttributeStore) ListByProject(ctx context.Context, projectID str
 	var attrs []model.ContextAttribute
 	for rows.Next() {
 		var a model.ContextAttribute
-		if err := rows.Scan(&a.ID, &a.ProjectID, &a.Name, &a.LastSeenAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.ProjectID, &a.Name, &a.Values, &a.LastSeenAt); err != nil {
 			return nil, fmt.Errorf("scanning context attribute: %w", err)
 		}
 		attrs = append(attrs, a)
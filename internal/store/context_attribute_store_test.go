@@ -2,6 +2,8 @@ package store_test
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/togglerino/togglerino/internal/store"
@@ -117,3 +119,49 @@ func TestContextAttributeStore_UpsertEmptySlice(t *testing.T) {
 		t.Fatalf("UpsertByProjectKey with empty slice: %v", err)
 	}
 }
+
+func TestContextAttributeStore_SamplesValuesUpToCap(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	cas := store.NewContextAttributeStore(pool)
+	ctx := context.Background()
+
+	key := uniqueKey("ctx-values")
+	project, err := ps.Create(ctx, key, "Values Project", "for value sampling tests")
+	if err != nil {
+		t.Fatalf("Create project: %v", err)
+	}
+
+	err = cas.UpsertSamplesByProjectKey(ctx, key, map[string][]string{
+		"country": {"DE", "US", "DE"},
+		"plan":    nil,
+	})
+	if err != nil {
+		t.Fatalf("first UpsertSamplesByProjectKey: %v", err)
+	}
+
+	// Newer values go first; a repeated value moves to the front rather than
+	// being stored twice, and the oldest fall off past the cap.
+	var newer []string
+	for i := 0; i < store.MaxAttributeValues; i++ {
+		newer = append(newer, fmt.Sprintf("c%02d", i))
+	}
+	newer[len(newer)-1] = "US"
+	if err := cas.UpsertSamplesByProjectKey(ctx, key, map[string][]string{"country": newer}); err != nil {
+		t.Fatalf("second UpsertSamplesByProjectKey: %v", err)
+	}
+
+	attrs, err := cas.ListByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(attrs) != 2 || attrs[0].Name != "country" || attrs[1].Name != "plan" {
+		t.Fatalf("expected country and plan, got %+v", attrs)
+	}
+	if len(attrs[1].Values) != 0 {
+		t.Errorf("expected no values for plan, got %v", attrs[1].Values)
+	}
+	if !slices.Equal(attrs[0].Values, newer) {
+		t.Errorf("expected country values %v, got %v", newer, attrs[0].Values)
+	}
+}
@@ -2,12 +2,20 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// environmentColumns is the column list scanned by scanEnvironment.
+const environmentColumns = `id, project_id, key, name, sort_order, created_at`
+
+// nextSortOrder places a new environment after the project's existing ones.
+const nextSortOrder = `(SELECT COALESCE(MAX(sort_order) + 1, 0) FROM environments WHERE project_id = $1)`
+
 type EnvironmentStore struct {
 	pool *pgxpool.Pool
 }
@@ -16,24 +24,24 @@ func NewEnvironmentStore(pool *pgxpool.Pool) *EnvironmentStore {
 	return &EnvironmentStore{pool: pool}
 }
 
-// Create inserts a new environment for a project.
+// Create inserts a new environment for a project, ordered after the
+// project's existing environments.
 func (s *EnvironmentStore) Create(ctx context.Context, projectID, key, name string) (*model.Environment, error) {
-	var e model.Environment
-	err := s.pool.QueryRow(ctx,
-		`INSERT INTO environments (project_id, key, name) VALUES ($1, $2, $3)
-		 RETURNING id, project_id, key, name, created_at`,
+	e, err := scanEnvironment(s.pool.QueryRow(ctx,
+		`INSERT INTO environments (project_id, key, name, sort_order) VALUES ($1, $2, $3, `+nextSortOrder+`)
+		 RETURNING `+environmentColumns,
 		projectID, key, name,
-	).Scan(&e.ID, &e.ProjectID, &e.Key, &e.Name, &e.CreatedAt)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("creating environment: %w", err)
 	}
-	return &e, nil
+	return e, nil
 }
 
-// ListByProject returns all environments for a project.
+// ListByProject returns all environments for a project in their sort order.
 func (s *EnvironmentStore) ListByProject(ctx context.Context, projectID string) ([]model.Environment, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, project_id, key, name, created_at FROM environments WHERE project_id = $1 ORDER BY created_at`,
+		`SELECT `+environmentColumns+` FROM environments WHERE project_id = $1 ORDER BY sort_order, created_at`,
 		projectID,
 	)
 	if err != nil {
@@ -43,11 +51,11 @@ func (s *EnvironmentStore) ListByProject(ctx context.Context, projectID string)
 
 	var envs []model.Environment
 	for rows.Next() {
-		var e model.Environment
-		if err := rows.Scan(&e.ID, &e.ProjectID, &e.Key, &e.Name, &e.CreatedAt); err != nil {
+		e, err := scanEnvironment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning environment: %w", err)
 		}
-		envs = append(envs, e)
+		envs = append(envs, *e)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("iterating environments: %w", err)
@@ -57,18 +65,68 @@ func (s *EnvironmentStore) ListByProject(ctx context.Context, projectID string)
 
 // FindByKey returns an environment by project ID and environment key.
 func (s *EnvironmentStore) FindByKey(ctx context.Context, projectID, key string) (*model.Environment, error) {
-	var e model.Environment
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, project_id, key, name, created_at FROM environments WHERE project_id = $1 AND key = $2`,
+	e, err := scanEnvironment(s.pool.QueryRow(ctx,
+		`SELECT `+environmentColumns+` FROM environments WHERE project_id = $1 AND key = $2`,
 		projectID, key,
-	).Scan(&e.ID, &e.ProjectID, &e.Key, &e.Name, &e.CreatedAt)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("finding environment by key: %w", err)
 	}
-	return &e, nil
+	return e, nil
+}
+
+// Update renames an environment. The key is immutable because SDKs and
+// rollout stages refer to it. Returns ErrNotFound if the environment does not
+// exist.
+func (s *EnvironmentStore) Update(ctx context.Context, id, name string) (*model.Environment, error) {
+	e, err := scanEnvironment(s.pool.QueryRow(ctx,
+		`UPDATE environments SET name = $2 WHERE id = $1
+		 RETURNING `+environmentColumns,
+		id, name,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("updating environment: %w", err)
+	}
+	return e, nil
+}
+
+// Reorder sets the sort order of a project's environments to the order of
+// keys. Keys must name every environment of the project exactly once;
+// otherwise nothing changes and ErrInvalidOrder is returned.
+func (s *EnvironmentStore) Reorder(ctx context.Context, projectID string, keys []string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE environments SET sort_order = array_position($2::text[], key) - 1
+		 WHERE project_id = $1 AND key = ANY($2)`,
+		projectID, keys,
+	)
+	if err != nil {
+		return fmt.Errorf("reordering environments: %w", err)
+	}
+	var total int64
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM environments WHERE project_id = $1`, projectID).Scan(&total); err != nil {
+		return fmt.Errorf("counting environments: %w", err)
+	}
+	if tag.RowsAffected() != total || int64(len(keys)) != total {
+		return ErrInvalidOrder
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
 }
 
-// Delete deletes an environment by ID.
+// Delete deletes an environment by ID. Its SDK keys, flag configs and other
+// per-environment rows are removed with it by ON DELETE CASCADE.
 func (s *EnvironmentStore) Delete(ctx context.Context, id string) error {
 	_, err := s.pool.Exec(ctx, `DELETE FROM environments WHERE id = $1`, id)
 	if err != nil {
@@ -77,25 +135,29 @@ func (s *EnvironmentStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// CreateDefaultEnvironments creates development, staging, production environments for a project.
-func (s *EnvironmentStore) CreateDefaultEnvironments(ctx context.Context, projectID string) error {
-	defaults := []struct {
-		key  string
-		name string
-	}{
-		{"development", "Development"},
-		{"staging", "Staging"},
-		{"production", "Production"},
+// CreateDefaultEnvironments creates the given environments for a new project,
+// falling back to model.DefaultEnvironmentTemplates when templates is empty.
+func (s *EnvironmentStore) CreateDefaultEnvironments(ctx context.Context, projectID string, templates []model.EnvironmentTemplate) error {
+	if len(templates) == 0 {
+		templates = model.DefaultEnvironmentTemplates
 	}
 
-	for _, d := range defaults {
+	for _, t := range templates {
 		_, err := s.pool.Exec(ctx,
-			`INSERT INTO environments (project_id, key, name) VALUES ($1, $2, $3)`,
-			projectID, d.key, d.name,
+			`INSERT INTO environments (project_id, key, name, sort_order) VALUES ($1, $2, $3, `+nextSortOrder+`)`,
+			projectID, t.Key, t.Name,
 		)
 		if err != nil {
-			return fmt.Errorf("creating default environment %q: %w", d.key, err)
+			return fmt.Errorf("creating default environment %q: %w", t.Key, err)
 		}
 	}
 	return nil
 }
+
+func scanEnvironment(row pgx.Row) (*model.Environment, error) {
+	var e model.Environment
+	if err := row.Scan(&e.ID, &e.ProjectID, &e.Key, &e.Name, &e.SortOrder, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -2,8 +2,11 @@ package store_test
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
 
+	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
 
@@ -199,7 +202,7 @@ func TestEnvironmentStore_CreateDefaultEnvironments(t *testing.T) {
 
 	projectID := createTestProject(t, ps)
 
-	err := es.CreateDefaultEnvironments(ctx, projectID)
+	err := es.CreateDefaultEnvironments(ctx, projectID, nil)
 	if err != nil {
 		t.Fatalf("CreateDefaultEnvironments: %v", err)
 	}
@@ -239,3 +242,131 @@ func TestEnvironmentStore_CreateDefaultEnvironments(t *testing.T) {
 		t.Errorf("missing environments: %v", expectedKeys)
 	}
 }
+
+func TestEnvironmentStore_CreateDefaultEnvironments_CustomList(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ctx := context.Background()
+
+	projectID := createTestProject(t, ps)
+
+	err := es.CreateDefaultEnvironments(ctx, projectID, []model.EnvironmentTemplate{
+		{Key: "dev", Name: "Dev"},
+		{Key: "prod-eu", Name: "Prod EU"},
+	})
+	if err != nil {
+		t.Fatalf("CreateDefaultEnvironments: %v", err)
+	}
+
+	envs, err := es.ListByProject(ctx, projectID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("expected 2 environments, got %d", len(envs))
+	}
+	for _, env := range envs {
+		if env.Key != "dev" && env.Key != "prod-eu" {
+			t.Errorf("unexpected environment key: %q", env.Key)
+		}
+	}
+}
+
+// envKeys returns the keys of envs in order.
+func envKeys(envs []model.Environment) []string {
+	keys := make([]string, len(envs))
+	for i, e := range envs {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+func TestEnvironmentStore_Create_AppendsToSortOrder(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ctx := context.Background()
+
+	projectID := createTestProject(t, ps)
+
+	for i, key := range []string{"production", "staging", "dev"} {
+		env, err := es.Create(ctx, projectID, key, key)
+		if err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+		if env.SortOrder != i {
+			t.Errorf("%s: SortOrder got %d, want %d", key, env.SortOrder, i)
+		}
+	}
+}
+
+func TestEnvironmentStore_Reorder(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ctx := context.Background()
+
+	projectID := createTestProject(t, ps)
+	for _, key := range []string{"production", "staging", "dev"} {
+		if _, err := es.Create(ctx, projectID, key, key); err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+	}
+
+	want := []string{"dev", "staging", "production"}
+	if err := es.Reorder(ctx, projectID, want); err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+	envs, err := es.ListByProject(ctx, projectID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if got := envKeys(envs); !slices.Equal(got, want) {
+		t.Errorf("order: got %v, want %v", got, want)
+	}
+
+	// A new environment goes after the reordered ones.
+	if _, err := es.Create(ctx, projectID, "qa", "QA"); err != nil {
+		t.Fatalf("Create qa: %v", err)
+	}
+	envs, err = es.ListByProject(ctx, projectID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if got := envKeys(envs); got[len(got)-1] != "qa" {
+		t.Errorf("expected qa last, got %v", got)
+	}
+}
+
+func TestEnvironmentStore_Reorder_RejectsIncompleteOrder(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ctx := context.Background()
+
+	projectID := createTestProject(t, ps)
+	for _, key := range []string{"production", "staging"} {
+		if _, err := es.Create(ctx, projectID, key, key); err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+	}
+
+	for _, keys := range [][]string{
+		{"staging"},
+		{"staging", "staging"},
+		{"staging", "production", "unknown"},
+	} {
+		if err := es.Reorder(ctx, projectID, keys); !errors.Is(err, store.ErrInvalidOrder) {
+			t.Errorf("Reorder(%v): got %v, want ErrInvalidOrder", keys, err)
+		}
+	}
+
+	envs, err := es.ListByProject(ctx, projectID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if got := envKeys(envs); !slices.Equal(got, []string{"production", "staging"}) {
+		t.Errorf("expected order unchanged, got %v", got)
+	}
+}
@@ -4,3 +4,13 @@ import "errors"
 
 // ErrNotFound is returned when a requested resource does not exist.
 var ErrNotFound = errors.New("not found")
+
+// ErrInvalidOrder is returned when a reorder request does not list every item
+// exactly once.
+var ErrInvalidOrder = errors.New("order must list every item exactly once")
+
+// ErrInvalidSort is returned when a listing is asked for an unsupported sort.
+var ErrInvalidSort = errors.New("unsupported sort")
+
+// ErrAlreadyExists is returned when creating a resource whose key is taken.
+var ErrAlreadyExists = errors.New("already exists")
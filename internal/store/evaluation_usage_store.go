@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type EvaluationUsageStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewEvaluationUsageStore(pool *pgxpool.Pool) *EvaluationUsageStore {
+	return &EvaluationUsageStore{pool: pool}
+}
+
+// Increment adds n evaluations to an environment's counter for the given
+// period (the first day of a month) and returns the new total.
+func (s *EvaluationUsageStore) Increment(ctx context.Context, environmentID string, period time.Time, n int64) (int64, error) {
+	var total int64
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO evaluation_usage (environment_id, period, evaluations)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (environment_id, period) DO UPDATE
+		 SET evaluations = evaluation_usage.evaluations + EXCLUDED.evaluations
+		 RETURNING evaluations`,
+		environmentID, period, n,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("incrementing evaluation usage: %w", err)
+	}
+	return total, nil
+}
+
+// Get returns an environment's evaluation count for the given period.
+// Periods with no recorded evaluations return zero.
+func (s *EvaluationUsageStore) Get(ctx context.Context, environmentID string, period time.Time) (int64, error) {
+	var total int64
+	err := s.pool.QueryRow(ctx,
+		`SELECT evaluations FROM evaluation_usage WHERE environment_id = $1 AND period = $2`,
+		environmentID, period,
+	).Scan(&total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting evaluation usage: %w", err)
+	}
+	return total, nil
+}
+
+// ListByPeriod returns the evaluation count of every environment with usage
+// in the given period, keyed by environment ID.
+func (s *EvaluationUsageStore) ListByPeriod(ctx context.Context, period time.Time) (map[string]int64, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT environment_id, evaluations FROM evaluation_usage WHERE period = $1`,
+		period,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing evaluation usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int64)
+	for rows.Next() {
+		var envID string
+		var total int64
+		if err := rows.Scan(&envID, &total); err != nil {
+			return nil, fmt.Errorf("scanning evaluation usage: %w", err)
+		}
+		usage[envID] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating evaluation usage: %w", err)
+	}
+	return usage, nil
+}
+
+// ListQuotas returns every configured monthly quota, keyed by environment ID.
+func (s *EvaluationUsageStore) ListQuotas(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.pool.Query(ctx, `SELECT environment_id, monthly_evaluations FROM environment_quotas`)
+	if err != nil {
+		return nil, fmt.Errorf("listing environment quotas: %w", err)
+	}
+	defer rows.Close()
+
+	quotas := make(map[string]int64)
+	for rows.Next() {
+		var envID string
+		var quota int64
+		if err := rows.Scan(&envID, &quota); err != nil {
+			return nil, fmt.Errorf("scanning environment quota: %w", err)
+		}
+		quotas[envID] = quota
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating environment quotas: %w", err)
+	}
+	return quotas, nil
+}
+
+// SetQuota sets an environment's monthly evaluation quota. A nil quota
+// removes it, making the environment unlimited.
+func (s *EvaluationUsageStore) SetQuota(ctx context.Context, environmentID string, quota *int64) error {
+	var err error
+	if quota == nil {
+		_, err = s.pool.Exec(ctx, `DELETE FROM environment_quotas WHERE environment_id = $1`, environmentID)
+	} else {
+		_, err = s.pool.Exec(ctx,
+			`INSERT INTO environment_quotas (environment_id, monthly_evaluations)
+			 VALUES ($1, $2)
+			 ON CONFLICT (environment_id) DO UPDATE
+			 SET monthly_evaluations = EXCLUDED.monthly_evaluations, updated_at = NOW()`,
+			environmentID, *quota,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("setting environment quota: %w", err)
+	}
+	return nil
+}
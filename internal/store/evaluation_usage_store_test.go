@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestEvaluationUsageStore_IncrementAndReset(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	us := store.NewEvaluationUsageStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("usage"), "Usage Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// Untouched period reads as zero
+	if got, err := us.Get(ctx, env.ID, march); err != nil || got != 0 {
+		t.Fatalf("Get before increment: got %d, %v; want 0, nil", got, err)
+	}
+
+	// Batched increments accumulate
+	if total, err := us.Increment(ctx, env.ID, march, 40); err != nil || total != 40 {
+		t.Fatalf("first Increment: got %d, %v; want 40, nil", total, err)
+	}
+	if total, err := us.Increment(ctx, env.ID, march, 2); err != nil || total != 42 {
+		t.Fatalf("second Increment: got %d, %v; want 42, nil", total, err)
+	}
+
+	// A new month starts from zero
+	if got, err := us.Get(ctx, env.ID, april); err != nil || got != 0 {
+		t.Fatalf("Get for new period: got %d, %v; want 0, nil", got, err)
+	}
+	if total, err := us.Increment(ctx, env.ID, april, 5); err != nil || total != 5 {
+		t.Fatalf("Increment in new period: got %d, %v; want 5, nil", total, err)
+	}
+
+	usage, err := us.ListByPeriod(ctx, march)
+	if err != nil {
+		t.Fatalf("ListByPeriod: %v", err)
+	}
+	if usage[env.ID] != 42 {
+		t.Errorf("ListByPeriod(march)[env]: got %d, want 42", usage[env.ID])
+	}
+}
+
+func TestEvaluationUsageStore_SetQuota(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	us := store.NewEvaluationUsageStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("quota"), "Quota Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+
+	limit := int64(1000)
+	if err := us.SetQuota(ctx, env.ID, &limit); err != nil {
+		t.Fatalf("SetQuota: %v", err)
+	}
+	quotas, err := us.ListQuotas(ctx)
+	if err != nil {
+		t.Fatalf("ListQuotas: %v", err)
+	}
+	if quotas[env.ID] != 1000 {
+		t.Errorf("quota: got %d, want 1000", quotas[env.ID])
+	}
+
+	if err := us.SetQuota(ctx, env.ID, nil); err != nil {
+		t.Fatalf("SetQuota(nil): %v", err)
+	}
+	quotas, err = us.ListQuotas(ctx)
+	if err != nil {
+		t.Fatalf("ListQuotas after clear: %v", err)
+	}
+	if _, ok := quotas[env.ID]; ok {
+		t.Error("expected quota to be removed")
+	}
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type FlagCommentStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewFlagCommentStore(pool *pgxpool.Pool) *FlagCommentStore {
+	return &FlagCommentStore{pool: pool}
+}
+
+// Create adds a comment to a flag's discussion thread, attributed to the given user.
+func (s *FlagCommentStore) Create(ctx context.Context, flagID, userID, body string) (*model.FlagComment, error) {
+	var c model.FlagComment
+	err := s.pool.QueryRow(ctx,
+		`WITH inserted AS (
+		     INSERT INTO flag_comments (flag_id, user_id, body) VALUES ($1, $2, $3)
+		     RETURNING id, flag_id, user_id, body, created_at
+		 )
+		 SELECT i.id, i.flag_id, i.user_id, COALESCE(u.email, ''), i.body, i.created_at
+		 FROM inserted i
+		 LEFT JOIN users u ON u.id = i.user_id`,
+		flagID, userID, body,
+	).Scan(&c.ID, &c.FlagID, &c.UserID, &c.UserEmail, &c.Body, &c.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("creating flag comment: %w", err)
+	}
+	return &c, nil
+}
+
+// ListByFlag returns all comments for a flag in chronological order (oldest first).
+// The author's email is populated via JOIN; it is empty if the user was deleted.
+func (s *FlagCommentStore) ListByFlag(ctx context.Context, flagID string) ([]model.FlagComment, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT c.id, c.flag_id, c.user_id, COALESCE(u.email, ''), c.body, c.created_at
+		 FROM flag_comments c
+		 LEFT JOIN users u ON u.id = c.user_id
+		 WHERE c.flag_id = $1
+		 ORDER BY c.created_at, c.id`,
+		flagID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing flag comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []model.FlagComment
+	for rows.Next() {
+		var c model.FlagComment
+		if err := rows.Scan(&c.ID, &c.FlagID, &c.UserID, &c.UserEmail, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning flag comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating flag comments: %w", err)
+	}
+	return comments, nil
+}
@@ -0,0 +1,86 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestFlagCommentStore_CreateAndList(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	us := store.NewUserStore(pool)
+	cs := store.NewFlagCommentStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("comments"), "Comments Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "dark-mode", "Dark Mode", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+	email := uniqueEmail("commenter")
+	user, err := us.Create(ctx, email, "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	first, err := cs.Create(ctx, flag.ID, user.ID, "first note")
+	if err != nil {
+		t.Fatalf("Create first: %v", err)
+	}
+	if first.UserEmail != email {
+		t.Errorf("UserEmail: got %q, want %q", first.UserEmail, email)
+	}
+	if first.UserID == nil || *first.UserID != user.ID {
+		t.Errorf("UserID: got %v, want %q", first.UserID, user.ID)
+	}
+	if _, err := cs.Create(ctx, flag.ID, user.ID, "second note"); err != nil {
+		t.Fatalf("Create second: %v", err)
+	}
+
+	comments, err := cs.ListByFlag(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("ListByFlag: %v", err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(comments))
+	}
+	if comments[0].Body != "first note" || comments[1].Body != "second note" {
+		t.Errorf("expected chronological order, got %q then %q", comments[0].Body, comments[1].Body)
+	}
+	if comments[1].CreatedAt.Before(comments[0].CreatedAt) {
+		t.Error("expected second comment to be created after the first")
+	}
+}
+
+func TestFlagCommentStore_ListByFlag_Empty(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	cs := store.NewFlagCommentStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("commentsempty"), "Comments Empty", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "quiet-flag", "Quiet", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	comments, err := cs.ListByFlag(ctx, flag.ID)
+	if err != nil {
+		t.Fatalf("ListByFlag: %v", err)
+	}
+	if len(comments) != 0 {
+		t.Errorf("expected 0 comments, got %d", len(comments))
+	}
+}
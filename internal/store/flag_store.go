@@ -3,14 +3,19 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// flagColumns is the column list scanned by scanFlag.
+const flagColumns = `id, project_id, key, name, description, value_type, flag_type, default_value, tags, rollout_stages, poll_ttl_seconds, require_identifier, json_schema, lifecycle_status, lifecycle_status_changed_at, last_evaluated_at, last_modified_by, created_at, updated_at`
+
 type FlagStore struct {
 	pool *pgxpool.Pool
 }
@@ -22,19 +27,24 @@ func NewFlagStore(pool *pgxpool.Pool) *FlagStore {
 // Create inserts a new flag and creates a FlagEnvironmentConfig row for each
 // environment in the project (all disabled by default with default variants).
 func (s *FlagStore) Create(ctx context.Context, projectID, key, name, description string, valueType model.ValueType, flagType model.FlagType, defaultValue json.RawMessage, tags []string) (*model.Flag, error) {
+	return s.CreateWithSchema(ctx, projectID, key, name, description, valueType, flagType, defaultValue, tags, nil)
+}
+
+// CreateWithSchema is Create for a flag whose values must match jsonSchema.
+// A nil schema leaves values unconstrained.
+func (s *FlagStore) CreateWithSchema(ctx context.Context, projectID, key, name, description string, valueType model.ValueType, flagType model.FlagType, defaultValue json.RawMessage, tags []string, jsonSchema json.RawMessage) (*model.Flag, error) {
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	var f model.Flag
-	err = tx.QueryRow(ctx,
-		`INSERT INTO flags (project_id, key, name, description, value_type, flag_type, default_value, tags)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		 RETURNING id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at`,
-		projectID, key, name, description, valueType, flagType, defaultValue, tags,
-	).Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt)
+	f, err := scanFlag(tx.QueryRow(ctx,
+		`INSERT INTO flags (project_id, key, name, description, value_type, flag_type, default_value, tags, json_schema)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING `+flagColumns,
+		projectID, key, name, description, valueType, flagType, defaultValue, tags, jsonSchema,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("creating flag: %w", err)
 	}
@@ -73,128 +83,286 @@ func (s *FlagStore) Create(ctx context.Context, projectID, key, name, descriptio
 		return nil, fmt.Errorf("committing transaction: %w", err)
 	}
 
-	if f.Tags == nil {
-		f.Tags = []string{}
-	}
+	return f, nil
+}
 
-	return &f, nil
+// flagSorts maps the sort values ListByProject accepts to ORDER BY clauses.
+// Only these clauses are ever added to the query. key breaks ties so pages
+// don't overlap.
+var flagSorts = map[string]string{
+	"":            "created_at DESC, key",
+	"name":        "name, key",
+	"-name":       "name DESC, key",
+	"updated_at":  "updated_at, key",
+	"-updated_at": "updated_at DESC, key",
+	// Lifecycle order rather than alphabetical: active flags first, archived last.
+	"status": "CASE lifecycle_status WHEN 'active' THEN 0 WHEN 'potentially_stale' THEN 1 WHEN 'stale' THEN 2 ELSE 3 END, name, key",
 }
 
-// ListByProject returns all flags for a project. Supports optional tag filter, search query,
-// lifecycle status filter, and flag type filter.
-func (s *FlagStore) ListByProject(ctx context.Context, projectID string, tag string, search string, lifecycleStatus string, flagType string) ([]model.Flag, error) {
-	query := `SELECT id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at
-		FROM flags WHERE project_id = $1`
+// ListByProject returns flags for a project, newest first unless sort is one of name, -name,
+// updated_at, -updated_at or status (ErrInvalidSort otherwise). Supports optional tag filter, search query,
+// lifecycle status filter, and flag type filter. A positive limit caps the page size and offset skips
+// that many matches; a limit of zero or less returns every match after offset. The second return
+// value is the total number of matching flags, ignoring limit and offset.
+func (s *FlagStore) ListByProject(ctx context.Context, projectID string, tag string, search string, lifecycleStatus string, flagType string, sort string, limit, offset int) ([]model.Flag, int, error) {
+	orderBy, ok := flagSorts[sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("sorting flags by %q: %w", sort, ErrInvalidSort)
+	}
+
+	where := ` WHERE project_id = $1 AND deleted_at IS NULL`
 	args := []any{projectID}
 	argIdx := 2
 
 	if tag != "" {
-		query += fmt.Sprintf(" AND $%d = ANY(tags)", argIdx)
+		where += fmt.Sprintf(" AND $%d = ANY(tags)", argIdx)
 		args = append(args, tag)
 		argIdx++
 	}
 
 	if search != "" {
-		query += fmt.Sprintf(" AND (key ILIKE '%%' || $%d || '%%' OR name ILIKE '%%' || $%d || '%%')", argIdx, argIdx)
+		where += fmt.Sprintf(" AND (key ILIKE '%%' || $%d || '%%' OR name ILIKE '%%' || $%d || '%%')", argIdx, argIdx)
 		args = append(args, search)
 		argIdx++
 	}
 
 	if lifecycleStatus != "" {
 		values := strings.Split(lifecycleStatus, ",")
-		query += fmt.Sprintf(" AND lifecycle_status = ANY($%d)", argIdx)
+		where += fmt.Sprintf(" AND lifecycle_status = ANY($%d)", argIdx)
 		args = append(args, values)
 		argIdx++
 	}
 
 	if flagType != "" {
 		values := strings.Split(flagType, ",")
-		query += fmt.Sprintf(" AND flag_type = ANY($%d)", argIdx)
+		where += fmt.Sprintf(" AND flag_type = ANY($%d)", argIdx)
 		args = append(args, values)
 		argIdx++
 	}
 
-	query += " ORDER BY created_at DESC"
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM flags`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting flags: %w", err)
+	}
+
+	query := `SELECT ` + flagColumns + ` FROM flags` + where + " ORDER BY " + orderBy
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argIdx)
+		args = append(args, limit)
+		argIdx++
+	}
+	if offset > 0 {
+		query += fmt.Sprintf(" OFFSET $%d", argIdx)
+		args = append(args, offset)
+	}
 
 	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("listing flags: %w", err)
+		return nil, 0, fmt.Errorf("listing flags: %w", err)
 	}
 	defer rows.Close()
 
 	var flags []model.Flag
 	for rows.Next() {
-		var f model.Flag
-		if err := rows.Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scanning flag: %w", err)
-		}
-		if f.Tags == nil {
-			f.Tags = []string{}
+		f, err := scanFlag(rows)
+		if err != nil {
+			return nil, 0, err
 		}
-		flags = append(flags, f)
+		flags = append(flags, *f)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterating flags: %w", err)
+		return nil, 0, fmt.Errorf("iterating flags: %w", err)
 	}
-	return flags, nil
+	return flags, total, nil
+}
+
+// Clone copies a flag's metadata into targetProjectID under newKey. The copy
+// starts active. Rollout stages keep only environments the target project
+// has. With copyConfigs, each target environment takes the config of the
+// source environment with the same key; the rest get default configs.
+func (s *FlagStore) Clone(ctx context.Context, sourceFlagID, targetProjectID, newKey string, copyConfigs bool) (*model.Flag, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	f, err := scanFlag(tx.QueryRow(ctx,
+		`INSERT INTO flags (project_id, key, name, description, value_type, flag_type, default_value, tags, rollout_stages, poll_ttl_seconds, require_identifier, json_schema)
+		 SELECT $2, $3, name, description, value_type, flag_type, default_value, tags,
+		        ARRAY(SELECT stage FROM unnest(rollout_stages) WITH ORDINALITY AS s(stage, n)
+		              WHERE stage IN (SELECT key FROM environments WHERE project_id = $2) ORDER BY n),
+		        poll_ttl_seconds, require_identifier, json_schema
+		 FROM flags WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+flagColumns,
+		sourceFlagID, targetProjectID, newKey,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cloning flag: %w", err)
+	}
+
+	if copyConfigs {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO flag_environment_configs (flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant)
+			 SELECT $1, te.id, fec.enabled, fec.default_variant, fec.variants, fec.targeting_rules, fec.prerequisites, fec.default_rollout, fec.included_users, fec.excluded_users, fec.included_variant
+			 FROM flag_environment_configs fec
+			 JOIN environments se ON se.id = fec.environment_id
+			 JOIN environments te ON te.key = se.key AND te.project_id = $3
+			 WHERE fec.flag_id = $2`,
+			f.ID, sourceFlagID, targetProjectID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("copying flag environment configs: %w", err)
+		}
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO flag_environment_configs (flag_id, environment_id)
+		 SELECT $1, id FROM environments WHERE project_id = $2
+		 ON CONFLICT (flag_id, environment_id) DO NOTHING`,
+		f.ID, targetProjectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating flag environment configs: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return f, nil
 }
 
 // FindByKey returns a flag by project ID and flag key.
 func (s *FlagStore) FindByKey(ctx context.Context, projectID, key string) (*model.Flag, error) {
-	var f model.Flag
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at
-		 FROM flags WHERE project_id = $1 AND key = $2`,
+	f, err := scanFlag(s.pool.QueryRow(ctx,
+		`SELECT `+flagColumns+`
+		 FROM flags WHERE project_id = $1 AND key = $2 AND deleted_at IS NULL`,
 		projectID, key,
-	).Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("finding flag by key: %w", err)
 	}
-	if f.Tags == nil {
-		f.Tags = []string{}
-	}
-	return &f, nil
+	return f, nil
 }
 
-// Update updates a flag's metadata (name, description, tags, flag_type).
-func (s *FlagStore) Update(ctx context.Context, flagID, name, description string, tags []string, flagType model.FlagType) (*model.Flag, error) {
-	var f model.Flag
-	err := s.pool.QueryRow(ctx,
-		`UPDATE flags SET name=$2, description=$3, tags=$4, flag_type=$5, updated_at=NOW() WHERE id=$1
-		 RETURNING id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at`,
-		flagID, name, description, tags, flagType,
-	).Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt)
+// Update updates a flag's metadata (name, description, tags, flag_type, rollout_stages, poll_ttl_seconds)
+// and records modifiedBy, a user ID or empty for none, as its last editor.
+func (s *FlagStore) Update(ctx context.Context, flagID, name, description string, tags []string, flagType model.FlagType, rolloutStages []string, pollTTLSeconds *int, requireIdentifier bool, modifiedBy string) (*model.Flag, error) {
+	if rolloutStages == nil {
+		rolloutStages = []string{}
+	}
+	f, err := scanFlag(s.pool.QueryRow(ctx,
+		`UPDATE flags SET name=$2, description=$3, tags=$4, flag_type=$5, rollout_stages=$6, poll_ttl_seconds=$7, require_identifier=$8, last_modified_by=NULLIF($9, '')::uuid, updated_at=NOW() WHERE id=$1
+		 RETURNING `+flagColumns,
+		flagID, name, description, tags, flagType, rolloutStages, pollTTLSeconds, requireIdentifier, modifiedBy,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("updating flag: %w", err)
 	}
-	if f.Tags == nil {
-		f.Tags = []string{}
+	return f, nil
+}
+
+// SetJSONSchema replaces the JSON Schema a flag's values must match. A nil
+// schema removes it.
+func (s *FlagStore) SetJSONSchema(ctx context.Context, flagID string, jsonSchema json.RawMessage) (*model.Flag, error) {
+	f, err := scanFlag(s.pool.QueryRow(ctx,
+		`UPDATE flags SET json_schema=$2, updated_at=NOW() WHERE id=$1
+		 RETURNING `+flagColumns,
+		flagID, jsonSchema,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("setting flag json schema: %w", err)
 	}
-	return &f, nil
+	return f, nil
+}
+
+// BulkUpdateTags adds and removes tags on the named flags of a project in a
+// single transaction. Adding a tag a flag already has and removing one it
+// lacks are no-ops; removals win over additions of the same tag. It returns
+// the flags that exist, ordered by key.
+func (s *FlagStore) BulkUpdateTags(ctx context.Context, projectID string, flagKeys, add, remove []string) ([]model.Flag, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT `+flagColumns+`
+		 FROM flags WHERE project_id = $1 AND key = ANY($2) AND deleted_at IS NULL
+		 ORDER BY key FOR UPDATE`,
+		projectID, flagKeys,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("locking flags: %w", err)
+	}
+	var current []model.Flag
+	for rows.Next() {
+		f, err := scanFlag(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		current = append(current, *f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating flags: %w", err)
+	}
+
+	removed := make(map[string]bool, len(remove))
+	for _, tag := range remove {
+		removed[tag] = true
+	}
+
+	updated := make([]model.Flag, 0, len(current))
+	for _, f := range current {
+		tags := []string{}
+		seen := make(map[string]bool, len(f.Tags)+len(add))
+		for _, tag := range append(f.Tags, add...) {
+			if !removed[tag] && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+
+		result, err := scanFlag(tx.QueryRow(ctx,
+			`UPDATE flags SET tags=$2, updated_at=NOW() WHERE id=$1
+			 RETURNING `+flagColumns,
+			f.ID, tags,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("updating flag tags: %w", err)
+		}
+		updated = append(updated, *result)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return updated, nil
 }
 
 // SetLifecycleStatus sets the lifecycle status of a flag.
 func (s *FlagStore) SetLifecycleStatus(ctx context.Context, flagID string, status model.LifecycleStatus) (*model.Flag, error) {
-	var f model.Flag
-	err := s.pool.QueryRow(ctx,
+	f, err := scanFlag(s.pool.QueryRow(ctx,
 		`UPDATE flags SET lifecycle_status=$2, lifecycle_status_changed_at=NOW(), updated_at=NOW() WHERE id=$1
-		 RETURNING id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at`,
+		 RETURNING `+flagColumns,
 		flagID, status,
-	).Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("setting flag lifecycle status: %w", err)
 	}
-	if f.Tags == nil {
-		f.Tags = []string{}
-	}
-	return &f, nil
+	return f, nil
 }
 
 // ListNonArchived returns all flags that are not archived (for cache loading and staleness checks).
 func (s *FlagStore) ListNonArchived(ctx context.Context) ([]model.Flag, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, project_id, key, name, description, value_type, flag_type, default_value, tags, lifecycle_status, lifecycle_status_changed_at, created_at, updated_at
-		 FROM flags WHERE lifecycle_status != 'archived'`)
+		`SELECT `+flagColumns+`
+		 FROM flags WHERE lifecycle_status != 'archived' AND deleted_at IS NULL`)
 	if err != nil {
 		return nil, fmt.Errorf("listing non-archived flags: %w", err)
 	}
@@ -202,14 +370,11 @@ func (s *FlagStore) ListNonArchived(ctx context.Context) ([]model.Flag, error) {
 
 	var flags []model.Flag
 	for rows.Next() {
-		var f model.Flag
-		if err := rows.Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.CreatedAt, &f.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("scanning flag: %w", err)
-		}
-		if f.Tags == nil {
-			f.Tags = []string{}
+		f, err := scanFlag(rows)
+		if err != nil {
+			return nil, err
 		}
-		flags = append(flags, f)
+		flags = append(flags, *f)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("iterating flags: %w", err)
@@ -217,29 +382,115 @@ func (s *FlagStore) ListNonArchived(ctx context.Context) ([]model.Flag, error) {
 	return flags, nil
 }
 
-// Delete deletes a flag by ID (cascades to environment configs).
+// Delete soft-deletes a flag by ID. The flag and its environment configs are
+// kept, hidden from every lookup, until Restore or PurgeDeleted.
 func (s *FlagStore) Delete(ctx context.Context, flagID string) error {
-	_, err := s.pool.Exec(ctx, `DELETE FROM flags WHERE id = $1`, flagID)
+	_, err := s.pool.Exec(ctx, `UPDATE flags SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, flagID)
 	if err != nil {
 		return fmt.Errorf("deleting flag: %w", err)
 	}
 	return nil
 }
 
-// GetEnvironmentConfig returns the flag config for a specific environment.
+// Restore brings back the most recently deleted flag with the given key. It
+// returns ErrNotFound if there is none, and a unique violation if a flag with
+// the same key has been created since.
+func (s *FlagStore) Restore(ctx context.Context, projectID, key string) (*model.Flag, error) {
+	f, err := scanFlag(s.pool.QueryRow(ctx,
+		`UPDATE flags SET deleted_at = NULL, updated_at = NOW()
+		 WHERE id = (
+		     SELECT id FROM flags WHERE project_id = $1 AND key = $2 AND deleted_at IS NOT NULL
+		     ORDER BY deleted_at DESC LIMIT 1
+		 )
+		 RETURNING `+flagColumns,
+		projectID, key,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restoring flag: %w", err)
+	}
+	return f, nil
+}
+
+// PurgeDeleted permanently removes flags soft-deleted before the given time
+// (cascading to their environment configs) and returns how many were removed.
+func (s *FlagStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM flags WHERE deleted_at IS NOT NULL AND deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purging deleted flags: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MarkEvaluated records that the given flags were served to an SDK at at.
+// Timestamps only move forward, so a delayed flush from another instance
+// cannot overwrite a more recent one.
+func (s *FlagStore) MarkEvaluated(ctx context.Context, flagIDs []string, at time.Time) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE flags SET last_evaluated_at = $2
+		 WHERE id = ANY($1) AND (last_evaluated_at IS NULL OR last_evaluated_at < $2)`,
+		flagIDs, at,
+	)
+	if err != nil {
+		return fmt.Errorf("marking flags evaluated: %w", err)
+	}
+	return nil
+}
+
+// GetEnvironmentConfig returns the flag config for a specific environment, or
+// ErrNotFound if the flag has never been configured there.
 func (s *FlagStore) GetEnvironmentConfig(ctx context.Context, flagID, environmentID string) (*model.FlagEnvironmentConfig, error) {
 	row := s.pool.QueryRow(ctx,
-		`SELECT id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, updated_at
+		`SELECT id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant, updated_at
 		 FROM flag_environment_configs WHERE flag_id = $1 AND environment_id = $2`,
 		flagID, environmentID,
 	)
-	return scanFlagEnvConfig(row)
+	cfg, err := scanFlagEnvConfig(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return cfg, err
+}
+
+// ListDependents returns the flags of a project that list flagKey as a
+// prerequisite, once per environment that does, ordered by flag key and
+// environment key.
+func (s *FlagStore) ListDependents(ctx context.Context, projectID, flagKey string) ([]model.FlagDependent, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT f.key, f.name, f.lifecycle_status, e.key, fec.enabled, p->>'variant'
+		 FROM flag_environment_configs fec
+		 JOIN flags f ON f.id = fec.flag_id
+		 JOIN environments e ON e.id = fec.environment_id
+		 CROSS JOIN LATERAL jsonb_array_elements(fec.prerequisites) p
+		 WHERE f.project_id = $1 AND f.deleted_at IS NULL AND p->>'flag_key' = $2
+		 ORDER BY f.key, e.key`,
+		projectID, flagKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing flag dependents: %w", err)
+	}
+	defer rows.Close()
+
+	var dependents []model.FlagDependent
+	for rows.Next() {
+		var d model.FlagDependent
+		if err := rows.Scan(&d.FlagKey, &d.FlagName, &d.LifecycleStatus, &d.EnvironmentKey, &d.Enabled, &d.Variant); err != nil {
+			return nil, fmt.Errorf("scanning flag dependent: %w", err)
+		}
+		dependents = append(dependents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating flag dependents: %w", err)
+	}
+	return dependents, nil
 }
 
 // GetAllEnvironmentConfigs returns all environment configs for a flag.
 func (s *FlagStore) GetAllEnvironmentConfigs(ctx context.Context, flagID string) ([]model.FlagEnvironmentConfig, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, updated_at
+		`SELECT id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant, updated_at
 		 FROM flag_environment_configs WHERE flag_id = $1 ORDER BY updated_at`,
 		flagID,
 	)
@@ -251,19 +502,34 @@ func (s *FlagStore) GetAllEnvironmentConfigs(ctx context.Context, flagID string)
 	var configs []model.FlagEnvironmentConfig
 	for rows.Next() {
 		var cfg model.FlagEnvironmentConfig
-		var variantsJSON, rulesJSON json.RawMessage
+		var variantsJSON, rulesJSON, prereqsJSON, rolloutJSON json.RawMessage
 		if err := rows.Scan(&cfg.ID, &cfg.FlagID, &cfg.EnvironmentID, &cfg.Enabled,
-			&cfg.DefaultVariant, &variantsJSON, &rulesJSON, &cfg.UpdatedAt); err != nil {
+			&cfg.DefaultVariant, &variantsJSON, &rulesJSON, &prereqsJSON, &rolloutJSON,
+			&cfg.IncludedUsers, &cfg.ExcludedUsers, &cfg.IncludedVariant, &cfg.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning environment config: %w", err)
 		}
 		json.Unmarshal(variantsJSON, &cfg.Variants)
 		json.Unmarshal(rulesJSON, &cfg.TargetingRules)
+		json.Unmarshal(prereqsJSON, &cfg.Prerequisites)
+		json.Unmarshal(rolloutJSON, &cfg.DefaultRollout)
 		if cfg.Variants == nil {
 			cfg.Variants = []model.Variant{}
 		}
 		if cfg.TargetingRules == nil {
 			cfg.TargetingRules = []model.TargetingRule{}
 		}
+		if cfg.Prerequisites == nil {
+			cfg.Prerequisites = []model.Prerequisite{}
+		}
+		if cfg.DefaultRollout == nil {
+			cfg.DefaultRollout = []model.VariantWeight{}
+		}
+		if cfg.IncludedUsers == nil {
+			cfg.IncludedUsers = []string{}
+		}
+		if cfg.ExcludedUsers == nil {
+			cfg.ExcludedUsers = []string{}
+		}
 		configs = append(configs, cfg)
 	}
 	if err := rows.Err(); err != nil {
@@ -273,33 +539,132 @@ func (s *FlagStore) GetAllEnvironmentConfigs(ctx context.Context, flagID string)
 }
 
 // UpdateEnvironmentConfig updates the flag config for a specific environment.
-// This includes enabled, default_variant, variants (JSON), and targeting_rules (JSON).
-func (s *FlagStore) UpdateEnvironmentConfig(ctx context.Context, flagID, environmentID string, enabled bool, defaultVariant string, variants json.RawMessage, targetingRules json.RawMessage) (*model.FlagEnvironmentConfig, error) {
+// This includes enabled, default_variant, variants (JSON), targeting_rules (JSON),
+// prerequisites (JSON), default_rollout (JSON, nil for none) and the included
+// and excluded user lists. The flag's updated_at is bumped and modifiedBy, a
+// user ID or empty for none, is recorded as its last editor.
+func (s *FlagStore) UpdateEnvironmentConfig(ctx context.Context, flagID, environmentID string, enabled bool, defaultVariant string, variants json.RawMessage, targetingRules json.RawMessage, prerequisites json.RawMessage, defaultRollout json.RawMessage, includedUsers, excludedUsers []string, includedVariant, modifiedBy string) (*model.FlagEnvironmentConfig, error) {
 	row := s.pool.QueryRow(ctx,
-		`UPDATE flag_environment_configs
-		 SET enabled=$3, default_variant=$4, variants=$5, targeting_rules=$6, updated_at=NOW()
-		 WHERE flag_id=$1 AND environment_id=$2
-		 RETURNING id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, updated_at`,
-		flagID, environmentID, enabled, defaultVariant, variants, targetingRules,
+		`WITH cfg AS (
+		   UPDATE flag_environment_configs
+		   SET enabled=$3, default_variant=$4, variants=$5, targeting_rules=$6, prerequisites=$7, default_rollout=COALESCE($8, '[]'::jsonb),
+		       included_users=COALESCE($9, '{}'), excluded_users=COALESCE($10, '{}'), included_variant=$11, updated_at=NOW()
+		   WHERE flag_id=$1 AND environment_id=$2
+		   RETURNING id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant, updated_at
+		 ), touched AS (
+		   UPDATE flags SET last_modified_by=NULLIF($12, '')::uuid, updated_at=NOW() WHERE id IN (SELECT flag_id FROM cfg)
+		 )
+		 SELECT * FROM cfg`,
+		flagID, environmentID, enabled, defaultVariant, variants, targetingRules, prerequisites, defaultRollout,
+		includedUsers, excludedUsers, includedVariant, modifiedBy,
 	)
 	return scanFlagEnvConfig(row)
 }
 
+// SetEnabled turns a flag on or off in one environment, leaving its variants,
+// targeting rules, prerequisites, default rollout and user lists as they are. It returns ErrNotFound if
+// the flag has no config in the environment. Like UpdateEnvironmentConfig it
+// records modifiedBy as the flag's last editor.
+func (s *FlagStore) SetEnabled(ctx context.Context, flagID, environmentID string, enabled bool, modifiedBy string) (*model.FlagEnvironmentConfig, error) {
+	cfg, err := scanFlagEnvConfig(s.pool.QueryRow(ctx,
+		`WITH cfg AS (
+		   UPDATE flag_environment_configs
+		   SET enabled=$3, updated_at=NOW()
+		   WHERE flag_id=$1 AND environment_id=$2
+		   RETURNING id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant, updated_at
+		 ), touched AS (
+		   UPDATE flags SET last_modified_by=NULLIF($4, '')::uuid, updated_at=NOW() WHERE id IN (SELECT flag_id FROM cfg)
+		 )
+		 SELECT * FROM cfg`,
+		flagID, environmentID, enabled, modifiedBy,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return cfg, err
+}
+
+// SetEnabledAllEnvironments turns a flag on or off in every environment in a
+// single statement, so either all environments change or none do. It returns
+// the configs whose enabled state changed, and records modifiedBy as the
+// flag's last editor if any did.
+func (s *FlagStore) SetEnabledAllEnvironments(ctx context.Context, flagID string, enabled bool, modifiedBy string) ([]model.FlagEnvironmentConfig, error) {
+	rows, err := s.pool.Query(ctx,
+		`WITH cfg AS (
+		   UPDATE flag_environment_configs
+		   SET enabled=$2, updated_at=NOW()
+		   WHERE flag_id=$1 AND enabled <> $2
+		   RETURNING id, flag_id, environment_id, enabled, default_variant, variants, targeting_rules, prerequisites, default_rollout, included_users, excluded_users, included_variant, updated_at
+		 ), touched AS (
+		   UPDATE flags SET last_modified_by=NULLIF($3, '')::uuid, updated_at=NOW() WHERE id IN (SELECT flag_id FROM cfg)
+		 )
+		 SELECT * FROM cfg`,
+		flagID, enabled, modifiedBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("setting flag enabled in all environments: %w", err)
+	}
+	defer rows.Close()
+
+	var configs []model.FlagEnvironmentConfig
+	for rows.Next() {
+		cfg, err := scanFlagEnvConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, *cfg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating environment configs: %w", err)
+	}
+	return configs, nil
+}
+
+func scanFlag(row pgx.Row) (*model.Flag, error) {
+	var f model.Flag
+	err := row.Scan(&f.ID, &f.ProjectID, &f.Key, &f.Name, &f.Description, &f.ValueType, &f.FlagType, &f.DefaultValue, &f.Tags, &f.RolloutStages, &f.PollTTLSeconds, &f.RequireIdentifier, &f.JSONSchema, &f.LifecycleStatus, &f.LifecycleStatusChangedAt, &f.LastEvaluatedAt, &f.LastModifiedBy, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning flag: %w", err)
+	}
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+	if f.RolloutStages == nil {
+		f.RolloutStages = []string{}
+	}
+	return &f, nil
+}
+
 func scanFlagEnvConfig(row pgx.Row) (*model.FlagEnvironmentConfig, error) {
 	var cfg model.FlagEnvironmentConfig
-	var variantsJSON, rulesJSON json.RawMessage
+	var variantsJSON, rulesJSON, prereqsJSON, rolloutJSON json.RawMessage
 	err := row.Scan(&cfg.ID, &cfg.FlagID, &cfg.EnvironmentID, &cfg.Enabled,
-		&cfg.DefaultVariant, &variantsJSON, &rulesJSON, &cfg.UpdatedAt)
+		&cfg.DefaultVariant, &variantsJSON, &rulesJSON, &prereqsJSON, &rolloutJSON,
+		&cfg.IncludedUsers, &cfg.ExcludedUsers, &cfg.IncludedVariant, &cfg.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("scanning flag environment config: %w", err)
 	}
 	json.Unmarshal(variantsJSON, &cfg.Variants)
 	json.Unmarshal(rulesJSON, &cfg.TargetingRules)
+	json.Unmarshal(prereqsJSON, &cfg.Prerequisites)
+	json.Unmarshal(rolloutJSON, &cfg.DefaultRollout)
 	if cfg.Variants == nil {
 		cfg.Variants = []model.Variant{}
 	}
 	if cfg.TargetingRules == nil {
 		cfg.TargetingRules = []model.TargetingRule{}
 	}
+	if cfg.Prerequisites == nil {
+		cfg.Prerequisites = []model.Prerequisite{}
+	}
+	if cfg.DefaultRollout == nil {
+		cfg.DefaultRollout = []model.VariantWeight{}
+	}
+	if cfg.IncludedUsers == nil {
+		cfg.IncludedUsers = []string{}
+	}
+	if cfg.ExcludedUsers == nil {
+		cfg.ExcludedUsers = []string{}
+	}
 	return &cfg, nil
 }
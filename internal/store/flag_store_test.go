@@ -3,7 +3,10 @@ package store_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
@@ -133,7 +136,7 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 
 	// Basic list — should return all 3
-	flags, err := fs.ListByProject(ctx, project.ID, "", "", "", "")
+	flags, _, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("ListByProject: %v", err)
 	}
@@ -142,7 +145,7 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 
 	// Filter by tag "ui" — should return flag-a and flag-c
-	flags, err = fs.ListByProject(ctx, project.ID, "ui", "", "", "")
+	flags, _, err = fs.ListByProject(ctx, project.ID, "ui", "", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("ListByProject with tag: %v", err)
 	}
@@ -151,7 +154,7 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 
 	// Filter by tag "backend" — should return flag-b
-	flags, err = fs.ListByProject(ctx, project.ID, "backend", "", "", "")
+	flags, _, err = fs.ListByProject(ctx, project.ID, "backend", "", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("ListByProject with tag 'backend': %v", err)
 	}
@@ -163,7 +166,7 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 
 	// Search by name "Dark" — should return flag-c
-	flags, err = fs.ListByProject(ctx, project.ID, "", "Dark", "", "")
+	flags, _, err = fs.ListByProject(ctx, project.ID, "", "Dark", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("ListByProject with search 'Dark': %v", err)
 	}
@@ -175,7 +178,7 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 
 	// Search by key "flag-a" — should match flag-a
-	flags, err = fs.ListByProject(ctx, project.ID, "", "flag-a", "", "")
+	flags, _, err = fs.ListByProject(ctx, project.ID, "", "flag-a", "", "", "", 0, 0)
 	if err != nil {
 		t.Fatalf("ListByProject with search 'flag-a': %v", err)
 	}
@@ -187,6 +190,142 @@ func TestFlagStore_ListByProject(t *testing.T) {
 	}
 }
 
+func TestFlagStore_ListByProject_Pagination(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagpage"), "Flag Page Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := es.Create(ctx, project.ID, "dev", "Development"); err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+
+	for _, key := range []string{"page-1", "page-2", "page-3", "page-4", "page-5"} {
+		tags := []string{"even"}
+		if key[len(key)-1]%2 == 1 {
+			tags = []string{"odd"}
+		}
+		if _, err := fs.Create(ctx, project.ID, key, key, "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), tags); err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+	}
+
+	all, total, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(all) != 5 || total != 5 {
+		t.Fatalf("expected 5 flags and total 5, got %d flags and total %d", len(all), total)
+	}
+
+	page1, total, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 2, 0)
+	if err != nil {
+		t.Fatalf("ListByProject page1: %v", err)
+	}
+	page2, _, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 2, 2)
+	if err != nil {
+		t.Fatalf("ListByProject page2: %v", err)
+	}
+	page3, _, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 2, 4)
+	if err != nil {
+		t.Fatalf("ListByProject page3: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("expected total 5 for a limited page, got %d", total)
+	}
+	if len(page1) != 2 || len(page2) != 2 || len(page3) != 1 {
+		t.Fatalf("expected pages of 2, 2 and 1 flags, got %d, %d and %d", len(page1), len(page2), len(page3))
+	}
+	var paged []string
+	for _, page := range [][]model.Flag{page1, page2, page3} {
+		for _, f := range page {
+			paged = append(paged, f.Key)
+		}
+	}
+	for i, f := range all {
+		if paged[i] != f.Key {
+			t.Errorf("position %d: paged %q, unpaged %q", i, paged[i], f.Key)
+		}
+	}
+
+	odd, total, err := fs.ListByProject(ctx, project.ID, "odd", "", "", "", "", 1, 0)
+	if err != nil {
+		t.Fatalf("ListByProject odd: %v", err)
+	}
+	if len(odd) != 1 || total != 3 {
+		t.Errorf("expected 1 flag and a filtered total of 3, got %d flags and total %d", len(odd), total)
+	}
+	_, total, err = fs.ListByProject(ctx, project.ID, "", "page-2", "", "", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ListByProject search: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected a search total of 1, got %d", total)
+	}
+}
+
+func TestFlagStore_ListByProject_Sort(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagsort"), "Flag Sort Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := es.Create(ctx, project.ID, "dev", "Development"); err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+
+	ids := map[string]string{}
+	for _, f := range []struct{ key, name string }{{"sort-b", "Bravo"}, {"sort-c", "Charlie"}, {"sort-a", "Alpha"}} {
+		created, err := fs.Create(ctx, project.ID, f.key, f.name, "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), nil)
+		if err != nil {
+			t.Fatalf("Create %s: %v", f.key, err)
+		}
+		ids[f.key] = created.ID
+	}
+	// Touch the flags so their updated_at order is c, a, b from oldest.
+	for _, key := range []string{"sort-c", "sort-a", "sort-b"} {
+		time.Sleep(5 * time.Millisecond)
+		if _, err := fs.Update(ctx, ids[key], key, "touched", nil, model.FlagTypeRelease, nil, nil, false, ""); err != nil {
+			t.Fatalf("Update %s: %v", key, err)
+		}
+	}
+
+	keys := func(sort string) string {
+		t.Helper()
+		flags, _, err := fs.ListByProject(ctx, project.ID, "", "", "", "", sort, 0, 0)
+		if err != nil {
+			t.Fatalf("ListByProject sort %q: %v", sort, err)
+		}
+		var out []string
+		for _, f := range flags {
+			out = append(out, f.Key)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := keys("name"); got != "sort-a,sort-b,sort-c" {
+		t.Errorf("sort=name: got %s, want sort-a,sort-b,sort-c", got)
+	}
+	if got := keys("-updated_at"); got != "sort-b,sort-a,sort-c" {
+		t.Errorf("sort=-updated_at: got %s, want sort-b,sort-a,sort-c", got)
+	}
+
+	_, _, err = fs.ListByProject(ctx, project.ID, "", "", "", "", "name; DROP TABLE flags", 0, 0)
+	if !errors.Is(err, store.ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort for an unknown sort, got %v", err)
+	}
+}
+
 func TestFlagStore_FindByKey(t *testing.T) {
 	pool := testPool(t)
 	ps := store.NewProjectStore(pool)
@@ -267,7 +406,7 @@ func TestFlagStore_Update(t *testing.T) {
 		t.Fatalf("Create: %v", err)
 	}
 
-	updated, err := fs.Update(ctx, created.ID, "New Name", "new description", []string{"new", "updated"}, model.FlagTypeRelease)
+	updated, err := fs.Update(ctx, created.ID, "New Name", "new description", []string{"new", "updated"}, model.FlagTypeRelease, nil, nil, true, "")
 	if err != nil {
 		t.Fatalf("Update: %v", err)
 	}
@@ -287,6 +426,9 @@ func TestFlagStore_Update(t *testing.T) {
 	if len(updated.Tags) != 2 {
 		t.Errorf("Tags length: got %d, want 2", len(updated.Tags))
 	}
+	if !updated.RequireIdentifier {
+		t.Error("RequireIdentifier: got false, want true")
+	}
 }
 
 func TestFlagStore_Delete(t *testing.T) {
@@ -317,19 +459,120 @@ func TestFlagStore_Delete(t *testing.T) {
 		t.Fatalf("Delete: %v", err)
 	}
 
-	// Verify it's gone
+	// Verify it's hidden
 	_, err = fs.FindByKey(ctx, project.ID, "delete-me")
 	if err == nil {
 		t.Fatal("expected error after deletion, got nil")
 	}
+	flags, _, err := fs.ListByProject(ctx, project.ID, "", "", "", "", "", 0, 0)
+	if err != nil {
+		t.Fatalf("ListByProject after delete: %v", err)
+	}
+	if len(flags) != 0 {
+		t.Errorf("expected deleted flag to be hidden from list, got %d flags", len(flags))
+	}
 
-	// Verify environment configs are also gone (cascade)
-	configs, err := fs.GetAllEnvironmentConfigs(ctx, flag.ID)
+	// The key is free for a new flag while the old one is soft-deleted
+	if _, err := fs.Create(ctx, project.ID, "delete-me", "Replacement", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("Create with deleted key: %v", err)
+	}
+	if _, err := fs.Restore(ctx, project.ID, "delete-me"); err == nil {
+		t.Error("expected restore to fail while a flag with the same key exists")
+	}
+}
+
+func TestFlagStore_Restore(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagrestore"), "Flag Restore Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "dev", "Development")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "restore-me", "Restore Me", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, err = fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "on",
+		json.RawMessage(`[{"key":"on","value":true},{"key":"off","value":false}]`), json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("UpdateEnvironmentConfig: %v", err)
+	}
+
+	if _, err := fs.Restore(ctx, project.ID, "restore-me"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Restore of live flag: expected ErrNotFound, got %v", err)
+	}
+
+	if err := fs.Delete(ctx, flag.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	restored, err := fs.Restore(ctx, project.ID, "restore-me")
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.ID != flag.ID {
+		t.Errorf("expected restored flag %s, got %s", flag.ID, restored.ID)
+	}
+	if _, err := fs.FindByKey(ctx, project.ID, "restore-me"); err != nil {
+		t.Fatalf("FindByKey after restore: %v", err)
+	}
+
+	cfg, err := fs.GetEnvironmentConfig(ctx, flag.ID, env.ID)
 	if err != nil {
-		t.Fatalf("GetAllEnvironmentConfigs after delete: %v", err)
+		t.Fatalf("GetEnvironmentConfig after restore: %v", err)
 	}
-	if len(configs) != 0 {
-		t.Errorf("expected 0 configs after flag deletion, got %d", len(configs))
+	if !cfg.Enabled || cfg.DefaultVariant != "on" || len(cfg.Variants) != 2 {
+		t.Errorf("expected environment config intact, got %+v", cfg)
+	}
+}
+
+func TestFlagStore_PurgeDeleted(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagpurge"), "Flag Purge Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := es.Create(ctx, project.ID, "dev", "Development"); err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "purge-me", "Purge Me", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := fs.Delete(ctx, flag.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Deleted just now, so a cutoff in the past keeps it
+	if _, err := fs.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	configs, err := fs.GetAllEnvironmentConfigs(ctx, flag.ID)
+	if err != nil || len(configs) != 1 {
+		t.Fatalf("expected configs kept within retention, got %d (%v)", len(configs), err)
+	}
+
+	if _, err := fs.PurgeDeleted(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if _, err := fs.Restore(ctx, project.ID, "purge-me"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected purged flag to be gone, got %v", err)
+	}
+	configs, err = fs.GetAllEnvironmentConfigs(ctx, flag.ID)
+	if err != nil || len(configs) != 0 {
+		t.Errorf("expected configs removed with the flag, got %d (%v)", len(configs), err)
 	}
 }
 
@@ -379,6 +622,11 @@ func TestFlagStore_GetEnvironmentConfig(t *testing.T) {
 	if cfg.TargetingRules == nil {
 		t.Error("expected non-nil TargetingRules")
 	}
+
+	// No flag has the environment's ID, so there is no config to find
+	if _, err := fs.GetEnvironmentConfig(ctx, env.ID, env.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unconfigured flag, got %v", err)
+	}
 }
 
 func TestFlagStore_GetAllEnvironmentConfigs(t *testing.T) {
@@ -499,8 +747,10 @@ func TestFlagStore_UpdateEnvironmentConfig(t *testing.T) {
 	// Update the config: enable flag, set variants, add targeting rules
 	variants := json.RawMessage(`[{"key":"on","value":true},{"key":"off","value":false}]`)
 	rules := json.RawMessage(`[{"conditions":[{"attribute":"country","operator":"equals","value":"US"}],"variant":"on"}]`)
+	prereqs := json.RawMessage(`[{"flag_key":"parent","variant":"on"}]`)
+	rollout := json.RawMessage(`[{"variant":"on","weight":25},{"variant":"off","weight":75}]`)
 
-	cfg, err := fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "on", variants, rules)
+	cfg, err := fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "on", variants, rules, prereqs, rollout, nil, nil, "", "")
 	if err != nil {
 		t.Fatalf("UpdateEnvironmentConfig: %v", err)
 	}
@@ -552,4 +802,345 @@ func TestFlagStore_UpdateEnvironmentConfig(t *testing.T) {
 	if len(readCfg.Variants) != 2 {
 		t.Errorf("Variants length after re-read: got %d, want 2", len(readCfg.Variants))
 	}
+	if len(readCfg.Prerequisites) != 1 || readCfg.Prerequisites[0] != (model.Prerequisite{FlagKey: "parent", Variant: "on"}) {
+		t.Errorf("Prerequisites after re-read: got %+v", readCfg.Prerequisites)
+	}
+	if len(readCfg.DefaultRollout) != 2 || readCfg.DefaultRollout[0] != (model.VariantWeight{Variant: "on", Weight: 25}) {
+		t.Errorf("DefaultRollout after re-read: got %+v", readCfg.DefaultRollout)
+	}
+}
+
+func TestFlagStore_UpdateEnvironmentConfig_StampsModifier(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	us := store.NewUserStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagmodifier"), "Modifier Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "modified-flag", "Modified Flag", "test", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if flag.LastModifiedBy != nil {
+		t.Errorf("expected a new flag to have no last editor, got %q", *flag.LastModifiedBy)
+	}
+	user, err := us.Create(ctx, uniqueEmail("flag-modifier"), "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	if _, err := fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, "on",
+		json.RawMessage(`[{"key":"on","value":true},{"key":"off","value":false}]`), json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", user.ID); err != nil {
+		t.Fatalf("UpdateEnvironmentConfig: %v", err)
+	}
+
+	found, err := fs.FindByKey(ctx, project.ID, "modified-flag")
+	if err != nil {
+		t.Fatalf("FindByKey: %v", err)
+	}
+	if found.LastModifiedBy == nil || *found.LastModifiedBy != user.ID {
+		t.Errorf("expected last_modified_by %q, got %v", user.ID, found.LastModifiedBy)
+	}
+	if !found.UpdatedAt.After(flag.UpdatedAt) {
+		t.Errorf("expected updated_at to advance past %v, got %v", flag.UpdatedAt, found.UpdatedAt)
+	}
+}
+
+func TestFlagStore_BulkUpdateTags(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagbulktag"), "Bulk Tag Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	if _, err := fs.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{"ui", "beta"}); err != nil {
+		t.Fatalf("Create checkout: %v", err)
+	}
+	if _, err := fs.Create(ctx, project.ID, "banner", "Banner", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{}); err != nil {
+		t.Fatalf("Create banner: %v", err)
+	}
+
+	updated, err := fs.BulkUpdateTags(ctx, project.ID, []string{"checkout", "banner", "missing"}, []string{"ui", "q3"}, []string{"beta", "legacy"})
+	if err != nil {
+		t.Fatalf("BulkUpdateTags: %v", err)
+	}
+	if len(updated) != 2 {
+		t.Fatalf("expected 2 updated flags, got %d", len(updated))
+	}
+
+	want := map[string][]string{
+		"banner":   {"ui", "q3"},
+		"checkout": {"ui", "q3"},
+	}
+	for _, f := range updated {
+		if strings.Join(f.Tags, ",") != strings.Join(want[f.Key], ",") {
+			t.Errorf("%s: expected tags %v, got %v", f.Key, want[f.Key], f.Tags)
+		}
+	}
+
+	// The change is persisted, not just returned
+	f, err := fs.FindByKey(ctx, project.ID, "checkout")
+	if err != nil {
+		t.Fatalf("FindByKey: %v", err)
+	}
+	if strings.Join(f.Tags, ",") != "ui,q3" {
+		t.Errorf("expected persisted tags [ui q3], got %v", f.Tags)
+	}
+}
+
+func TestFlagStore_Clone_SameProject(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagclone"), "Clone Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "dev", "Development")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	source, err := fs.Create(ctx, project.ID, "checkout", "Checkout", "new checkout", model.ValueTypeBoolean, model.FlagTypeExperiment, json.RawMessage(`false`), []string{"ui"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := fs.SetLifecycleStatus(ctx, source.ID, model.LifecycleArchived); err != nil {
+		t.Fatalf("SetLifecycleStatus: %v", err)
+	}
+	_, err = fs.UpdateEnvironmentConfig(ctx, source.ID, env.ID, true, "on",
+		json.RawMessage(`[{"key":"on","value":true},{"key":"off","value":false}]`), json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", "")
+	if err != nil {
+		t.Fatalf("UpdateEnvironmentConfig: %v", err)
+	}
+
+	clone, err := fs.Clone(ctx, source.ID, project.ID, "checkout-v2", true)
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if clone.Key != "checkout-v2" || clone.Name != "Checkout" || clone.FlagType != model.FlagTypeExperiment {
+		t.Errorf("expected metadata copied, got %+v", clone)
+	}
+	if clone.LifecycleStatus != model.LifecycleActive {
+		t.Errorf("expected clone to start active, got %s", clone.LifecycleStatus)
+	}
+	cfg, err := fs.GetEnvironmentConfig(ctx, clone.ID, env.ID)
+	if err != nil {
+		t.Fatalf("GetEnvironmentConfig: %v", err)
+	}
+	if !cfg.Enabled || cfg.DefaultVariant != "on" || len(cfg.Variants) != 2 {
+		t.Errorf("expected config copied, got %+v", cfg)
+	}
+
+	if _, err := fs.Clone(ctx, source.ID, project.ID, "checkout-v2", false); err == nil {
+		t.Error("expected cloning onto an existing key to fail")
+	}
+}
+
+func TestFlagStore_Clone_AcrossProjects(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	source, err := ps.Create(ctx, uniqueKey("clonesrc"), "Clone Source", "test")
+	if err != nil {
+		t.Fatalf("creating source project: %v", err)
+	}
+	target, err := ps.Create(ctx, uniqueKey("clonedst"), "Clone Target", "test")
+	if err != nil {
+		t.Fatalf("creating target project: %v", err)
+	}
+	srcEnvs := map[string]*model.Environment{}
+	for _, key := range []string{"staging", "production"} {
+		env, err := es.Create(ctx, source.ID, key, key)
+		if err != nil {
+			t.Fatalf("creating source env %s: %v", key, err)
+		}
+		srcEnvs[key] = env
+	}
+	// The target project only shares production, and has an env of its own.
+	dstProd, err := es.Create(ctx, target.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating target env: %v", err)
+	}
+	dstQA, err := es.Create(ctx, target.ID, "qa", "QA")
+	if err != nil {
+		t.Fatalf("creating target env: %v", err)
+	}
+
+	flag, err := fs.Create(ctx, source.ID, "banner", "Banner", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for key, env := range srcEnvs {
+		_, err := fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, true, key,
+			json.RawMessage(`[{"key":"staging","value":true},{"key":"production","value":true}]`), json.RawMessage(`[]`), json.RawMessage(`[]`), nil, nil, nil, "", "")
+		if err != nil {
+			t.Fatalf("UpdateEnvironmentConfig %s: %v", key, err)
+		}
+	}
+
+	clone, err := fs.Clone(ctx, flag.ID, target.ID, "banner", true)
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if clone.ProjectID != target.ID {
+		t.Errorf("expected clone in target project, got %s", clone.ProjectID)
+	}
+
+	configs, err := fs.GetAllEnvironmentConfigs(ctx, clone.ID)
+	if err != nil {
+		t.Fatalf("GetAllEnvironmentConfigs: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("expected a config per target env, got %d", len(configs))
+	}
+	for _, cfg := range configs {
+		switch cfg.EnvironmentID {
+		case dstProd.ID:
+			if !cfg.Enabled || cfg.DefaultVariant != "production" {
+				t.Errorf("expected production config copied, got %+v", cfg)
+			}
+		case dstQA.ID:
+			if cfg.Enabled {
+				t.Errorf("expected default config for unmatched env, got %+v", cfg)
+			}
+		default:
+			t.Errorf("unexpected environment %s", cfg.EnvironmentID)
+		}
+	}
+}
+
+func TestFlagStore_ListDependents(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagdeps"), "Flag Deps Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	dev, err := es.Create(ctx, project.ID, "dev", "Development")
+	if err != nil {
+		t.Fatalf("creating dev: %v", err)
+	}
+	prod, err := es.Create(ctx, project.ID, "prod", "Production")
+	if err != nil {
+		t.Fatalf("creating prod: %v", err)
+	}
+
+	create := func(key string) *model.Flag {
+		t.Helper()
+		f, err := fs.Create(ctx, project.ID, key, key, "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), nil)
+		if err != nil {
+			t.Fatalf("Create %s: %v", key, err)
+		}
+		return f
+	}
+	create("base")
+	checkout := create("checkout")
+	create("unrelated")
+
+	requireBase := json.RawMessage(`[{"flag_key": "base", "variant": "on"}]`)
+	if _, err := fs.UpdateEnvironmentConfig(ctx, checkout.ID, dev.ID, true, "", json.RawMessage(`[]`), json.RawMessage(`[]`), requireBase, nil, nil, nil, "", ""); err != nil {
+		t.Fatalf("UpdateEnvironmentConfig dev: %v", err)
+	}
+	if _, err := fs.UpdateEnvironmentConfig(ctx, checkout.ID, prod.ID, false, "", json.RawMessage(`[]`), json.RawMessage(`[]`), requireBase, nil, nil, nil, "", ""); err != nil {
+		t.Fatalf("UpdateEnvironmentConfig prod: %v", err)
+	}
+
+	dependents, err := fs.ListDependents(ctx, project.ID, "base")
+	if err != nil {
+		t.Fatalf("ListDependents: %v", err)
+	}
+	if len(dependents) != 2 {
+		t.Fatalf("expected checkout in 2 environments, got %+v", dependents)
+	}
+	if d := dependents[0]; d.FlagKey != "checkout" || d.EnvironmentKey != "dev" || !d.Enabled || d.Variant != "on" {
+		t.Errorf("dependents[0] = %+v, want checkout enabled in dev requiring on", d)
+	}
+	if d := dependents[1]; d.EnvironmentKey != "prod" || d.Enabled {
+		t.Errorf("dependents[1] = %+v, want checkout disabled in prod", d)
+	}
+
+	if dependents, err := fs.ListDependents(ctx, project.ID, "unrelated"); err != nil || len(dependents) != 0 {
+		t.Errorf("expected no dependents of unrelated, got %+v, %v", dependents, err)
+	}
+}
+
+func TestFlagStore_SetEnabled_KeepsConfig(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("flagsetenabled"), "Set Enabled Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "set-enabled-flag", "Set Enabled Flag", "test", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	variants := json.RawMessage(`[{"key":"on","value":true},{"key":"off","value":false}]`)
+	rules := json.RawMessage(`[{"conditions":[{"attribute":"country","operator":"equals","value":"US"}],"variant":"on"}]`)
+	prereqs := json.RawMessage(`[{"flag_key":"parent","variant":"on"}]`)
+	if _, err := fs.UpdateEnvironmentConfig(ctx, flag.ID, env.ID, false, "off", variants, rules, prereqs, nil, nil, nil, "", ""); err != nil {
+		t.Fatalf("UpdateEnvironmentConfig: %v", err)
+	}
+
+	cfg, err := fs.SetEnabled(ctx, flag.ID, env.ID, true, "")
+	if err != nil {
+		t.Fatalf("SetEnabled: %v", err)
+	}
+	if !cfg.Enabled {
+		t.Error("expected Enabled to be true")
+	}
+
+	stored, err := fs.GetEnvironmentConfig(ctx, flag.ID, env.ID)
+	if err != nil {
+		t.Fatalf("GetEnvironmentConfig: %v", err)
+	}
+	if !stored.Enabled || stored.DefaultVariant != "off" {
+		t.Errorf("expected enabled with default variant off, got enabled=%v default=%q", stored.Enabled, stored.DefaultVariant)
+	}
+	if len(stored.Variants) != 2 || len(stored.TargetingRules) != 1 || len(stored.Prerequisites) != 1 {
+		t.Errorf("expected variants, rules and prerequisites to survive, got %d variants, %d rules, %d prerequisites",
+			len(stored.Variants), len(stored.TargetingRules), len(stored.Prerequisites))
+	}
+	if len(stored.TargetingRules) == 1 && stored.TargetingRules[0].Variant != "on" {
+		t.Errorf("expected the rule to keep variant on, got %q", stored.TargetingRules[0].Variant)
+	}
+
+	otherEnv, err := es.Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating staging: %v", err)
+	}
+	if _, err := fs.SetEnabled(ctx, flag.ID, otherEnv.ID, true, ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unconfigured environment, got %v", err)
+	}
 }
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type GlobalSettingsStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewGlobalSettingsStore(pool *pgxpool.Pool) *GlobalSettingsStore {
+	return &GlobalSettingsStore{pool: pool}
+}
+
+// Get returns the instance-wide settings. If none have been saved yet it
+// returns empty settings, so every value falls back to its built-in default.
+func (s *GlobalSettingsStore) Get(ctx context.Context) (*model.GlobalSettings, error) {
+	var settingsJSON []byte
+	var gs model.GlobalSettings
+	err := s.pool.QueryRow(ctx, `SELECT settings, updated_at FROM global_settings`).Scan(&settingsJSON, &gs.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &model.GlobalSettings{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting global settings: %w", err)
+	}
+	if err := json.Unmarshal(settingsJSON, &gs); err != nil {
+		return nil, fmt.Errorf("unmarshaling global settings: %w", err)
+	}
+	return &gs, nil
+}
+
+// Update upserts the settings row, overwriting only the top-level keys in
+// patch. A key set to nil resets that setting to its built-in default.
+func (s *GlobalSettingsStore) Update(ctx context.Context, patch map[string]any) (*model.GlobalSettings, error) {
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling global settings: %w", err)
+	}
+
+	var settingsJSON []byte
+	var gs model.GlobalSettings
+	err = s.pool.QueryRow(ctx,
+		`INSERT INTO global_settings (id, settings)
+		 VALUES (true, $1)
+		 ON CONFLICT (id) DO UPDATE SET settings = global_settings.settings || $1, updated_at = NOW()
+		 RETURNING settings, updated_at`,
+		patchJSON,
+	).Scan(&settingsJSON, &gs.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("updating global settings: %w", err)
+	}
+	if err := json.Unmarshal(settingsJSON, &gs); err != nil {
+		return nil, fmt.Errorf("unmarshaling global settings: %w", err)
+	}
+	return &gs, nil
+}
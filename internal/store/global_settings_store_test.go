@@ -0,0 +1,56 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestGlobalSettingsStore_SingletonUpsert(t *testing.T) {
+	pool := testPool(t)
+	gss := store.NewGlobalSettingsStore(pool)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), `DELETE FROM global_settings`)
+	})
+
+	if _, err := pool.Exec(ctx, `DELETE FROM global_settings`); err != nil {
+		t.Fatalf("clearing global settings: %v", err)
+	}
+	gs, err := gss.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get (empty): %v", err)
+	}
+	if gs.GracePeriodDays != nil || gs.GracePeriod().Hours() != 14*24 {
+		t.Errorf("expected built-in defaults with no row, got %+v", gs)
+	}
+
+	if _, err := gss.Update(ctx, map[string]any{"grace_period_days": 3}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	gs, err = gss.Update(ctx, map[string]any{"max_conditions_per_rule": 5})
+	if err != nil {
+		t.Fatalf("Update (second): %v", err)
+	}
+	// The second update merges into the same row rather than replacing it.
+	if gs.GracePeriodDays == nil || *gs.GracePeriodDays != 3 || gs.ConditionLimit() != 5 {
+		t.Errorf("expected merged settings, got grace=%v conditions=%d", gs.GracePeriodDays, gs.ConditionLimit())
+	}
+
+	var rows int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM global_settings`).Scan(&rows); err != nil {
+		t.Fatalf("counting rows: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("expected exactly 1 settings row, got %d", rows)
+	}
+
+	gs, err = gss.Update(ctx, map[string]any{"grace_period_days": nil})
+	if err != nil {
+		t.Fatalf("Update (reset): %v", err)
+	}
+	if gs.GracePeriodDays != nil {
+		t.Errorf("expected grace period reset to default, got %d", *gs.GracePeriodDays)
+	}
+}
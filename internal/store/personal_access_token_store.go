@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so they can be
+// told apart from session IDs and SDK keys.
+const PersonalAccessTokenPrefix = "pat_"
+
+// tokenColumns is the column list scanned by scanToken.
+const tokenColumns = `id, user_id, name, expires_at, last_used_at, created_at`
+
+type PersonalAccessTokenStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPersonalAccessTokenStore(pool *pgxpool.Pool) *PersonalAccessTokenStore {
+	return &PersonalAccessTokenStore{pool: pool}
+}
+
+// Create generates a token for a user and stores its hash. The returned token
+// carries the plaintext, which cannot be recovered afterwards.
+// Token format: "pat_" + 64 random hex characters (using crypto/rand).
+func (s *PersonalAccessTokenStore) Create(ctx context.Context, userID, name string, expiresAt *time.Time) (*model.PersonalAccessToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generating random token: %w", err)
+	}
+	plaintext := PersonalAccessTokenPrefix + hex.EncodeToString(b)
+
+	token, err := scanToken(s.pool.QueryRow(ctx,
+		`INSERT INTO personal_access_tokens (user_id, name, token_hash, expires_at)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+tokenColumns,
+		userID, name, hashToken(plaintext), expiresAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating personal access token: %w", err)
+	}
+	token.Token = plaintext
+	return token, nil
+}
+
+// ListByUser returns a user's tokens, newest first.
+func (s *PersonalAccessTokenStore) ListByUser(ctx context.Context, userID string) ([]model.PersonalAccessToken, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+tokenColumns+` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []model.PersonalAccessToken
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating personal access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Authenticate looks up an unexpired token by its plaintext and records the
+// use. Returns ErrNotFound for unknown, revoked or expired tokens.
+func (s *PersonalAccessTokenStore) Authenticate(ctx context.Context, plaintext string) (*model.PersonalAccessToken, error) {
+	if !strings.HasPrefix(plaintext, PersonalAccessTokenPrefix) {
+		return nil, ErrNotFound
+	}
+	token, err := scanToken(s.pool.QueryRow(ctx,
+		`UPDATE personal_access_tokens SET last_used_at = NOW()
+		 WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		 RETURNING `+tokenColumns,
+		hashToken(plaintext),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("authenticating personal access token: %w", err)
+	}
+	return token, nil
+}
+
+// Revoke deletes a token owned by userID. Returns ErrNotFound if the user
+// has no such token.
+func (s *PersonalAccessTokenStore) Revoke(ctx context.Context, id, userID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("revoking personal access token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// hashToken returns the hex SHA-256 of a token. Tokens are high-entropy
+// random values, so a fast unsalted hash is sufficient.
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanToken(row pgx.Row) (*model.PersonalAccessToken, error) {
+	var token model.PersonalAccessToken
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning personal access token: %w", err)
+	}
+	return &token, nil
+}
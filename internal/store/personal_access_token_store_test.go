@@ -0,0 +1,83 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestPersonalAccessTokenStore_Lifecycle(t *testing.T) {
+	pool := testPool(t)
+	us := store.NewUserStore(pool)
+	ts := store.NewPersonalAccessTokenStore(pool)
+	ctx := context.Background()
+
+	owner, err := us.Create(ctx, uniqueEmail("pat-owner"), "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	other, err := us.Create(ctx, uniqueEmail("pat-other"), "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	created, err := ts.Create(ctx, owner.ID, "ci", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, store.PersonalAccessTokenPrefix) {
+		t.Errorf("expected plaintext token with prefix, got %q", created.Token)
+	}
+
+	var storedHash string
+	if err := pool.QueryRow(ctx, `SELECT token_hash FROM personal_access_tokens WHERE id = $1`, created.ID).Scan(&storedHash); err != nil {
+		t.Fatalf("reading hash: %v", err)
+	}
+	if storedHash == created.Token || strings.Contains(storedHash, created.Token) {
+		t.Error("expected only a hash of the token to be stored")
+	}
+
+	found, err := ts.Authenticate(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if found.UserID != owner.ID || found.LastUsedAt == nil || found.Token != "" {
+		t.Errorf("unexpected authenticated token: %+v", found)
+	}
+	if _, err := ts.Authenticate(ctx, created.Token+"0"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("wrong token: expected ErrNotFound, got %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	expired, err := ts.Create(ctx, owner.ID, "old", &past)
+	if err != nil {
+		t.Fatalf("Create expired: %v", err)
+	}
+	if _, err := ts.Authenticate(ctx, expired.Token); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expired token: expected ErrNotFound, got %v", err)
+	}
+
+	tokens, err := ts.ListByUser(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Token != "" {
+		t.Errorf("expected 2 tokens without plaintext, got %+v", tokens)
+	}
+
+	// Only the owner can revoke
+	if err := ts.Revoke(ctx, created.ID, other.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("revoke by other user: expected ErrNotFound, got %v", err)
+	}
+	if err := ts.Revoke(ctx, created.ID, owner.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := ts.Authenticate(ctx, created.Token); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("revoked token: expected ErrNotFound, got %v", err)
+	}
+}
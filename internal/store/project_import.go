@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// Import recreates an exported project in a single transaction. Environment
+// references are remapped by key. If the project key is taken, Import returns
+// ErrAlreadyExists unless overwrite is set, in which case the project's
+// name, description, listed environments, listed flags and their configs are
+// replaced; environments and flags missing from doc are left alone. The
+// caller is responsible for validating doc.
+func (s *ProjectStore) Import(ctx context.Context, doc *model.ProjectExport, overwrite bool) (*model.Project, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var p model.Project
+	err = tx.QueryRow(ctx,
+		`INSERT INTO projects (key, name, description) VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO NOTHING
+		 RETURNING id, key, name, description, created_at, updated_at`,
+		doc.Project.Key, doc.Project.Name, doc.Project.Description,
+	).Scan(&p.ID, &p.Key, &p.Name, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if !overwrite {
+			return nil, ErrAlreadyExists
+		}
+		err = tx.QueryRow(ctx,
+			`UPDATE projects SET name=$2, description=$3, updated_at=NOW() WHERE key=$1
+			 RETURNING id, key, name, description, created_at, updated_at`,
+			doc.Project.Key, doc.Project.Name, doc.Project.Description,
+		).Scan(&p.ID, &p.Key, &p.Name, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("importing project: %w", err)
+	}
+
+	envIDs := make(map[string]string, len(doc.Environments))
+	for _, env := range doc.Environments {
+		var id string
+		err := tx.QueryRow(ctx,
+			`INSERT INTO environments (project_id, key, name, sort_order) VALUES ($1, $2, $3, `+nextSortOrder+`)
+			 ON CONFLICT (project_id, key) DO UPDATE SET name = EXCLUDED.name
+			 RETURNING id`,
+			p.ID, env.Key, env.Name,
+		).Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("importing environment %s: %w", env.Key, err)
+		}
+		envIDs[env.Key] = id
+	}
+
+	for _, f := range doc.Flags {
+		var flagID string
+		err := tx.QueryRow(ctx,
+			`INSERT INTO flags (project_id, key, name, description, value_type, flag_type, default_value, tags, rollout_stages, poll_ttl_seconds, require_identifier, json_schema)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			 ON CONFLICT (project_id, key) WHERE deleted_at IS NULL DO UPDATE
+			 SET name = EXCLUDED.name, description = EXCLUDED.description, value_type = EXCLUDED.value_type,
+			     flag_type = EXCLUDED.flag_type, default_value = EXCLUDED.default_value, tags = EXCLUDED.tags,
+			     rollout_stages = EXCLUDED.rollout_stages, poll_ttl_seconds = EXCLUDED.poll_ttl_seconds,
+			     require_identifier = EXCLUDED.require_identifier, json_schema = EXCLUDED.json_schema, updated_at = NOW()
+			 RETURNING id`,
+			p.ID, f.Key, f.Name, f.Description, f.ValueType, f.FlagType, f.DefaultValue, f.Tags, f.RolloutStages, f.PollTTLSeconds, f.RequireIdentifier, f.JSONSchema,
+		).Scan(&flagID)
+		if err != nil {
+			return nil, fmt.Errorf("importing flag %s: %w", f.Key, err)
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO flag_environment_configs (flag_id, environment_id)
+			 SELECT $1, id FROM environments WHERE project_id = $2
+			 ON CONFLICT (flag_id, environment_id) DO NOTHING`,
+			flagID, p.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("creating flag environment configs for %s: %w", f.Key, err)
+		}
+
+		for envKey, cfg := range f.Environments {
+			variants, _ := json.Marshal(cfg.Variants)
+			rules, _ := json.Marshal(cfg.TargetingRules)
+			prereqs, _ := json.Marshal(cfg.Prerequisites)
+			rollout, _ := json.Marshal(cfg.DefaultRollout)
+			_, err := tx.Exec(ctx,
+				`UPDATE flag_environment_configs
+				 SET enabled=$3, default_variant=$4, variants=$5, targeting_rules=$6, prerequisites=$7, default_rollout=$8,
+				     included_users=$9, excluded_users=$10, included_variant=$11, updated_at=NOW()
+				 WHERE flag_id=$1 AND environment_id=$2`,
+				flagID, envIDs[envKey], cfg.Enabled, cfg.DefaultVariant, variants, rules, prereqs, rollout,
+				cfg.IncludedUsers, cfg.ExcludedUsers, cfg.IncludedVariant,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("importing %s config for %s: %w", envKey, f.Key, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type ProjectMembershipStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewProjectMembershipStore(pool *pgxpool.Pool) *ProjectMembershipStore {
+	return &ProjectMembershipStore{pool: pool}
+}
+
+// Set grants a user a role in a project, replacing any existing role.
+func (s *ProjectMembershipStore) Set(ctx context.Context, projectID, userID string, role model.ProjectRole) (*model.ProjectMembership, error) {
+	var m model.ProjectMembership
+	err := s.pool.QueryRow(ctx,
+		`WITH upserted AS (
+			INSERT INTO project_memberships (project_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			RETURNING project_id, user_id, role, created_at
+		 )
+		 SELECT m.project_id, m.user_id, u.email, m.role, m.created_at
+		 FROM upserted m JOIN users u ON u.id = m.user_id`,
+		projectID, userID, role,
+	).Scan(&m.ProjectID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("setting project membership: %w", err)
+	}
+	return &m, nil
+}
+
+// ListByProject returns a project's memberships ordered by email.
+func (s *ProjectMembershipStore) ListByProject(ctx context.Context, projectID string) ([]model.ProjectMembership, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT m.project_id, m.user_id, u.email, m.role, m.created_at
+		 FROM project_memberships m JOIN users u ON u.id = m.user_id
+		 WHERE m.project_id = $1 ORDER BY u.email`,
+		projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing project memberships: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []model.ProjectMembership
+	for rows.Next() {
+		var m model.ProjectMembership
+		if err := rows.Scan(&m.ProjectID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning project membership: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating project memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+// RoleFor returns a user's role in the project with the given key.
+// Returns ErrNotFound if the user has no membership there.
+func (s *ProjectMembershipStore) RoleFor(ctx context.Context, userID, projectKey string) (model.ProjectRole, error) {
+	var role model.ProjectRole
+	err := s.pool.QueryRow(ctx,
+		`SELECT m.role FROM project_memberships m
+		 JOIN projects p ON p.id = m.project_id
+		 WHERE m.user_id = $1 AND p.key = $2`,
+		userID, projectKey,
+	).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("finding project role: %w", err)
+	}
+	return role, nil
+}
+
+// Delete removes a user's membership. Returns ErrNotFound if there was none.
+func (s *ProjectMembershipStore) Delete(ctx context.Context, projectID, userID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM project_memberships WHERE project_id = $1 AND user_id = $2`, projectID, userID)
+	if err != nil {
+		return fmt.Errorf("deleting project membership: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
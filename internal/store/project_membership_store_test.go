@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestProjectMembershipStore_CRUD(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	ms := store.NewProjectMembershipStore(pool)
+
+	project, err := store.NewProjectStore(pool).Create(ctx, uniqueKey("members"), "Members", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	user, err := store.NewUserStore(pool).Create(ctx, uniqueEmail("member"), "hash", model.RoleMember)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	if _, err := ms.RoleFor(ctx, user.ID, project.Key); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("before grant: expected ErrNotFound, got %v", err)
+	}
+
+	m, err := ms.Set(ctx, project.ID, user.ID, model.ProjectRoleViewer)
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if m.Email != user.Email || m.Role != model.ProjectRoleViewer {
+		t.Errorf("unexpected membership: %+v", m)
+	}
+
+	// Setting again replaces the role
+	if _, err := ms.Set(ctx, project.ID, user.ID, model.ProjectRoleEditor); err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+	role, err := ms.RoleFor(ctx, user.ID, project.Key)
+	if err != nil || role != model.ProjectRoleEditor {
+		t.Errorf("RoleFor: got %q, %v; want editor", role, err)
+	}
+
+	list, err := ms.ListByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(list) != 1 || list[0].UserID != user.ID {
+		t.Errorf("expected one membership for the user, got %+v", list)
+	}
+
+	if err := ms.Delete(ctx, project.ID, user.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := ms.Delete(ctx, project.ID, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete: expected ErrNotFound, got %v", err)
+	}
+}
@@ -18,6 +18,29 @@ func NewProjectSettingsStore(pool *pgxpool.Pool) *ProjectSettingsStore {
 	return &ProjectSettingsStore{pool: pool}
 }
 
+// settingsDocument is the JSON shape of the project_settings.settings column.
+type settingsDocument struct {
+	FlagLifetimes               map[model.FlagType]*int `json:"flag_lifetimes"`
+	CaseInsensitiveFlagKeys     bool                    `json:"case_insensitive_flag_keys"`
+	RequireApprovalEnvironments []string                `json:"require_approval_environments"`
+	AuditRetentionDays          *int                    `json:"audit_retention_days"`
+}
+
+// decodeSettings unmarshals a settings column into ps.
+func decodeSettings(ps *model.ProjectSettings, settingsJSON []byte) error {
+	var doc settingsDocument
+	if len(settingsJSON) > 0 {
+		if err := json.Unmarshal(settingsJSON, &doc); err != nil {
+			return err
+		}
+	}
+	ps.FlagLifetimes = doc.FlagLifetimes
+	ps.CaseInsensitiveFlagKeys = doc.CaseInsensitiveFlagKeys
+	ps.RequireApprovalEnvironments = doc.RequireApprovalEnvironments
+	ps.AuditRetentionDays = doc.AuditRetentionDays
+	return nil
+}
+
 // Get returns the project settings for a project. Returns nil (no error) if no settings exist yet.
 func (s *ProjectSettingsStore) Get(ctx context.Context, projectID string) (*model.ProjectSettings, error) {
 	var ps model.ProjectSettings
@@ -33,25 +56,38 @@ func (s *ProjectSettingsStore) Get(ctx context.Context, projectID string) (*mode
 		return nil, fmt.Errorf("getting project settings: %w", err)
 	}
 
-	var raw struct {
-		FlagLifetimes map[model.FlagType]*int `json:"flag_lifetimes"`
-	}
-	if len(settingsJSON) > 0 {
-		if err := json.Unmarshal(settingsJSON, &raw); err != nil {
-			return nil, fmt.Errorf("unmarshaling project settings: %w", err)
-		}
+	if err := decodeSettings(&ps, settingsJSON); err != nil {
+		return nil, fmt.Errorf("unmarshaling project settings: %w", err)
 	}
-	ps.FlagLifetimes = raw.FlagLifetimes
 	return &ps, nil
 }
 
-// Upsert creates or updates project settings.
+// Upsert creates or updates a project's flag lifetimes. Other settings are preserved.
 func (s *ProjectSettingsStore) Upsert(ctx context.Context, projectID string, flagLifetimes map[model.FlagType]*int) (*model.ProjectSettings, error) {
-	settings := struct {
-		FlagLifetimes map[model.FlagType]*int `json:"flag_lifetimes"`
-	}{FlagLifetimes: flagLifetimes}
+	return s.merge(ctx, projectID, map[string]any{"flag_lifetimes": flagLifetimes})
+}
+
+// SetCaseInsensitiveFlagKeys enables or disables case-insensitive flag key
+// lookup on evaluate for a project. Other settings are preserved.
+func (s *ProjectSettingsStore) SetCaseInsensitiveFlagKeys(ctx context.Context, projectID string, enabled bool) (*model.ProjectSettings, error) {
+	return s.merge(ctx, projectID, map[string]any{"case_insensitive_flag_keys": enabled})
+}
+
+// SetRequireApprovalEnvironments replaces the environments whose flag config
+// updates need approval. Other settings are preserved.
+func (s *ProjectSettingsStore) SetRequireApprovalEnvironments(ctx context.Context, projectID string, envKeys []string) (*model.ProjectSettings, error) {
+	return s.merge(ctx, projectID, map[string]any{"require_approval_environments": envKeys})
+}
 
-	settingsJSON, err := json.Marshal(settings)
+// SetAuditRetentionDays overrides the audit log retention for a project; nil
+// reverts to the instance-wide default. Other settings are preserved.
+func (s *ProjectSettingsStore) SetAuditRetentionDays(ctx context.Context, projectID string, days *int) (*model.ProjectSettings, error) {
+	return s.merge(ctx, projectID, map[string]any{"audit_retention_days": days})
+}
+
+// merge upserts the project's settings row, overwriting only the top-level keys in patch.
+func (s *ProjectSettingsStore) merge(ctx context.Context, projectID string, patch map[string]any) (*model.ProjectSettings, error) {
+	patchJSON, err := json.Marshal(patch)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling settings: %w", err)
 	}
@@ -61,21 +97,17 @@ func (s *ProjectSettingsStore) Upsert(ctx context.Context, projectID string, fla
 	err = s.pool.QueryRow(ctx,
 		`INSERT INTO project_settings (project_id, settings)
 		 VALUES ($1, $2)
-		 ON CONFLICT (project_id) DO UPDATE SET settings = $2, updated_at = NOW()
+		 ON CONFLICT (project_id) DO UPDATE SET settings = project_settings.settings || $2, updated_at = NOW()
 		 RETURNING id, project_id, settings, updated_at`,
-		projectID, settingsJSON,
+		projectID, patchJSON,
 	).Scan(&ps.ID, &ps.ProjectID, &returnedJSON, &ps.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("upserting project settings: %w", err)
 	}
 
-	var raw struct {
-		FlagLifetimes map[model.FlagType]*int `json:"flag_lifetimes"`
-	}
-	if err := json.Unmarshal(returnedJSON, &raw); err != nil {
+	if err := decodeSettings(&ps, returnedJSON); err != nil {
 		return nil, fmt.Errorf("unmarshaling upserted settings: %w", err)
 	}
-	ps.FlagLifetimes = raw.FlagLifetimes
 	return &ps, nil
 }
 
@@ -94,13 +126,9 @@ func (s *ProjectSettingsStore) GetAll(ctx context.Context) (map[string]*model.Pr
 		if err := rows.Scan(&ps.ID, &ps.ProjectID, &settingsJSON, &ps.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scanning project settings: %w", err)
 		}
-		var raw struct {
-			FlagLifetimes map[model.FlagType]*int `json:"flag_lifetimes"`
-		}
-		if err := json.Unmarshal(settingsJSON, &raw); err != nil {
+		if err := decodeSettings(&ps, settingsJSON); err != nil {
 			return nil, fmt.Errorf("unmarshaling project settings row: %w", err)
 		}
-		ps.FlagLifetimes = raw.FlagLifetimes
 		result[ps.ProjectID] = &ps
 	}
 	return result, rows.Err()
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// ruleTemplateColumns is the column list scanned by scanRuleTemplate.
+const ruleTemplateColumns = `id, project_id, key, name, description, parameters, conditions, created_at, updated_at`
+
+type RuleTemplateStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewRuleTemplateStore(pool *pgxpool.Pool) *RuleTemplateStore {
+	return &RuleTemplateStore{pool: pool}
+}
+
+// Create inserts a new rule template for a project.
+func (s *RuleTemplateStore) Create(ctx context.Context, projectID, key, name, description string, parameters []string, conditions []model.Condition) (*model.RuleTemplate, error) {
+	conditionsJSON, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling conditions: %w", err)
+	}
+	t, err := scanRuleTemplate(s.pool.QueryRow(ctx,
+		`INSERT INTO rule_templates (project_id, key, name, description, parameters, conditions)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+ruleTemplateColumns,
+		projectID, key, name, description, parameters, conditionsJSON,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating rule template: %w", err)
+	}
+	return t, nil
+}
+
+// ListByProject returns all rule templates for a project, ordered by key.
+func (s *RuleTemplateStore) ListByProject(ctx context.Context, projectID string) ([]model.RuleTemplate, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+ruleTemplateColumns+` FROM rule_templates WHERE project_id = $1 ORDER BY key`,
+		projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing rule templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.RuleTemplate
+	for rows.Next() {
+		t, err := scanRuleTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rule templates: %w", err)
+	}
+	return templates, nil
+}
+
+// FindByKey returns a rule template by project ID and template key.
+func (s *RuleTemplateStore) FindByKey(ctx context.Context, projectID, key string) (*model.RuleTemplate, error) {
+	t, err := scanRuleTemplate(s.pool.QueryRow(ctx,
+		`SELECT `+ruleTemplateColumns+` FROM rule_templates WHERE project_id = $1 AND key = $2`,
+		projectID, key,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding rule template: %w", err)
+	}
+	return t, nil
+}
+
+// Update replaces a rule template's name, description, parameters, and conditions.
+func (s *RuleTemplateStore) Update(ctx context.Context, id, name, description string, parameters []string, conditions []model.Condition) (*model.RuleTemplate, error) {
+	conditionsJSON, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling conditions: %w", err)
+	}
+	t, err := scanRuleTemplate(s.pool.QueryRow(ctx,
+		`UPDATE rule_templates SET name=$2, description=$3, parameters=$4, conditions=$5, updated_at=NOW() WHERE id=$1
+		 RETURNING `+ruleTemplateColumns,
+		id, name, description, parameters, conditionsJSON,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("updating rule template: %w", err)
+	}
+	return t, nil
+}
+
+// Delete removes a rule template. Rules already instantiated from it are unaffected.
+func (s *RuleTemplateStore) Delete(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM rule_templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting rule template: %w", err)
+	}
+	return nil
+}
+
+func scanRuleTemplate(row pgx.Row) (*model.RuleTemplate, error) {
+	var t model.RuleTemplate
+	var conditionsJSON []byte
+	err := row.Scan(&t.ID, &t.ProjectID, &t.Key, &t.Name, &t.Description, &t.Parameters, &conditionsJSON, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning rule template: %w", err)
+	}
+	if err := json.Unmarshal(conditionsJSON, &t.Conditions); err != nil {
+		return nil, fmt.Errorf("decoding rule template conditions: %w", err)
+	}
+	if t.Parameters == nil {
+		t.Parameters = []string{}
+	}
+	if t.Conditions == nil {
+		t.Conditions = []model.Condition{}
+	}
+	return &t, nil
+}
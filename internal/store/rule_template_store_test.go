@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestRuleTemplateStore_CRUD(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	rts := store.NewRuleTemplateStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("ruletemplates"), "Rule Templates Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+
+	conditions := []model.Condition{{Attribute: "email", Operator: "ends_with", Value: "@{{domain}}"}}
+	created, err := rts.Create(ctx, project.ID, "internal-users", "Internal users", "", []string{"domain"}, conditions)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	found, err := rts.FindByKey(ctx, project.ID, "internal-users")
+	if err != nil {
+		t.Fatalf("FindByKey: %v", err)
+	}
+	if found.ID != created.ID || len(found.Conditions) != 1 || found.Conditions[0].Value != "@{{domain}}" {
+		t.Errorf("unexpected template: %+v", found)
+	}
+
+	updated, err := rts.Update(ctx, created.ID, "Staff", "staff only", []string{"domain"}, conditions)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Name != "Staff" || updated.Description != "staff only" {
+		t.Errorf("Update: got name=%q description=%q", updated.Name, updated.Description)
+	}
+
+	list, err := rts.ListByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 template, got %d", len(list))
+	}
+
+	if err := rts.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := rts.FindByKey(ctx, project.ID, "internal-users"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("FindByKey after delete: expected ErrNotFound, got %v", err)
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// SDKKeyTouchInterval limits how often Touch writes last_used_at, so SDK
+// requests don't each cost a database write.
+const SDKKeyTouchInterval = time.Minute
+
 type SDKKeyStore struct {
 	pool *pgxpool.Pool
 }
@@ -18,9 +25,13 @@ func NewSDKKeyStore(pool *pgxpool.Pool) *SDKKeyStore {
 	return &SDKKeyStore{pool: pool}
 }
 
-// Create generates a new SDK key for an environment.
+// Create generates a new SDK key for an environment with the given scopes;
+// nil or empty grants every scope.
 // Key format: "sdk_" + 32 random hex characters (using crypto/rand).
-func (s *SDKKeyStore) Create(ctx context.Context, environmentID, name string) (*model.SDKKey, error) {
+func (s *SDKKeyStore) Create(ctx context.Context, environmentID, name string, scopes []model.SDKKeyScope) (*model.SDKKey, error) {
+	if len(scopes) == 0 {
+		scopes = model.AllSDKKeyScopes
+	}
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
 		return nil, fmt.Errorf("generating random key: %w", err)
@@ -29,20 +40,21 @@ func (s *SDKKeyStore) Create(ctx context.Context, environmentID, name string) (*
 
 	var k model.SDKKey
 	err := s.pool.QueryRow(ctx,
-		`INSERT INTO sdk_keys (key, environment_id, name) VALUES ($1, $2, $3)
-		 RETURNING id, key, environment_id, name, revoked, created_at`,
-		key, environmentID, name,
-	).Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.CreatedAt)
+		`INSERT INTO sdk_keys (key, environment_id, name, scopes) VALUES ($1, $2, $3, $4)
+		 RETURNING id, key, environment_id, name, revoked, scopes, created_at, last_used_at, expires_at, rotated_from_id`,
+		key, environmentID, name, scopes,
+	).Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RotatedFromID)
 	if err != nil {
 		return nil, fmt.Errorf("creating SDK key: %w", err)
 	}
 	return &k, nil
 }
 
-// ListByEnvironment returns all SDK keys for an environment.
+// ListByEnvironment returns all SDK keys for an environment, with when each
+// was last used.
 func (s *SDKKeyStore) ListByEnvironment(ctx context.Context, environmentID string) ([]model.SDKKey, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, key, environment_id, name, revoked, created_at FROM sdk_keys WHERE environment_id = $1 ORDER BY created_at DESC`,
+		`SELECT id, key, environment_id, name, revoked, scopes, created_at, last_used_at, expires_at, rotated_from_id FROM sdk_keys WHERE environment_id = $1 ORDER BY created_at DESC`,
 		environmentID,
 	)
 	if err != nil {
@@ -53,7 +65,7 @@ func (s *SDKKeyStore) ListByEnvironment(ctx context.Context, environmentID strin
 	var keys []model.SDKKey
 	for rows.Next() {
 		var k model.SDKKey
-		if err := rows.Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.CreatedAt); err != nil {
+		if err := rows.Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RotatedFromID); err != nil {
 			return nil, fmt.Errorf("scanning SDK key: %w", err)
 		}
 		keys = append(keys, k)
@@ -64,25 +76,98 @@ func (s *SDKKeyStore) ListByEnvironment(ctx context.Context, environmentID strin
 	return keys, nil
 }
 
-// FindByKey looks up an SDK key by its key string. Returns error if not found or revoked.
+// FindByKey looks up an SDK key by its key string. Returns error if not found,
+// revoked or past its expiry.
 // Joins environments and projects to resolve the project and environment keys
 // so handlers can verify the SDK key is authorized for the requested scope.
 func (s *SDKKeyStore) FindByKey(ctx context.Context, key string) (*model.SDKKey, error) {
 	var k model.SDKKey
 	err := s.pool.QueryRow(ctx,
-		`SELECT sk.id, sk.key, sk.environment_id, sk.name, sk.revoked, sk.created_at, p.id, p.key, e.key
+		`SELECT sk.id, sk.key, sk.environment_id, sk.name, sk.revoked, sk.scopes, sk.created_at, sk.last_used_at, sk.expires_at, sk.rotated_from_id, p.id, p.key, e.key
 		 FROM sdk_keys sk
 		 JOIN environments e ON e.id = sk.environment_id
 		 JOIN projects p ON p.id = e.project_id
-		 WHERE sk.key = $1 AND sk.revoked = FALSE`,
+		 WHERE sk.key = $1 AND sk.revoked = FALSE AND (sk.expires_at IS NULL OR sk.expires_at > NOW())`,
 		key,
-	).Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.CreatedAt, &k.ProjectID, &k.ProjectKey, &k.EnvironmentKey)
+	).Scan(&k.ID, &k.Key, &k.EnvironmentID, &k.Name, &k.Revoked, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RotatedFromID, &k.ProjectID, &k.ProjectKey, &k.EnvironmentKey)
 	if err != nil {
 		return nil, fmt.Errorf("finding SDK key: %w", err)
 	}
 	return &k, nil
 }
 
+// Touch records that an SDK key authenticated a request. Writes are skipped
+// if the key was already marked used within SDKKeyTouchInterval.
+func (s *SDKKeyStore) Touch(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE sdk_keys SET last_used_at = NOW()
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $2))`,
+		id, SDKKeyTouchInterval.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("touching SDK key: %w", err)
+	}
+	return nil
+}
+
+// Rotate issues a replacement for an environment's unrevoked SDK key, with
+// the same name and scopes, and lets the old key expire after grace so
+// clients can move over without a hard cutover. A key that already expires
+// sooner keeps its expiry. Returns ErrNotFound if the environment has no
+// such key.
+func (s *SDKKeyStore) Rotate(ctx context.Context, environmentID, id string, grace time.Duration) (old, replacement *model.SDKKey, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, fmt.Errorf("generating random key: %w", err)
+	}
+	key := "sdk_" + hex.EncodeToString(b)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	old = &model.SDKKey{}
+	err = tx.QueryRow(ctx,
+		`UPDATE sdk_keys SET expires_at = LEAST(expires_at, NOW() + make_interval(secs => $3))
+		 WHERE id = $1 AND environment_id = $2 AND revoked = FALSE
+		 RETURNING id, key, environment_id, name, revoked, scopes, created_at, last_used_at, expires_at, rotated_from_id`,
+		id, environmentID, grace.Seconds(),
+	).Scan(&old.ID, &old.Key, &old.EnvironmentID, &old.Name, &old.Revoked, &old.Scopes, &old.CreatedAt, &old.LastUsedAt, &old.ExpiresAt, &old.RotatedFromID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("expiring SDK key: %w", err)
+	}
+
+	replacement = &model.SDKKey{}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO sdk_keys (key, environment_id, name, scopes, rotated_from_id) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, key, environment_id, name, revoked, scopes, created_at, last_used_at, expires_at, rotated_from_id`,
+		key, old.EnvironmentID, old.Name, old.Scopes, old.ID,
+	).Scan(&replacement.ID, &replacement.Key, &replacement.EnvironmentID, &replacement.Name, &replacement.Revoked, &replacement.Scopes, &replacement.CreatedAt, &replacement.LastUsedAt, &replacement.ExpiresAt, &replacement.RotatedFromID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating replacement SDK key: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return old, replacement, nil
+}
+
+// RevokeExpired revokes every key whose rotation grace period has passed and
+// returns how many were revoked.
+func (s *SDKKeyStore) RevokeExpired(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE sdk_keys SET revoked = TRUE WHERE revoked = FALSE AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("revoking expired SDK keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
 // Revoke marks an SDK key as revoked.
 func (s *SDKKeyStore) Revoke(ctx context.Context, id string) error {
 	_, err := s.pool.Exec(ctx, `UPDATE sdk_keys SET revoked = TRUE WHERE id = $1`, id)
@@ -2,9 +2,13 @@ package store_test
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/togglerino/togglerino/internal/model"
 	"github.com/togglerino/togglerino/internal/store"
 )
 
@@ -36,7 +40,7 @@ func TestSDKKeyStore_Create(t *testing.T) {
 
 	_, envID := createTestEnvironment(t, ps, es)
 
-	sdkKey, err := ks.Create(ctx, envID, "My API Key")
+	sdkKey, err := ks.Create(ctx, envID, "My API Key", nil)
 	if err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -73,11 +77,11 @@ func TestSDKKeyStore_ListByEnvironment(t *testing.T) {
 
 	_, envID := createTestEnvironment(t, ps, es)
 
-	_, err := ks.Create(ctx, envID, "Key One")
+	_, err := ks.Create(ctx, envID, "Key One", nil)
 	if err != nil {
 		t.Fatalf("Create key 1: %v", err)
 	}
-	_, err = ks.Create(ctx, envID, "Key Two")
+	_, err = ks.Create(ctx, envID, "Key Two", nil)
 	if err != nil {
 		t.Fatalf("Create key 2: %v", err)
 	}
@@ -128,7 +132,7 @@ func TestSDKKeyStore_FindByKey(t *testing.T) {
 
 	_, envID := createTestEnvironment(t, ps, es)
 
-	created, err := ks.Create(ctx, envID, "Findable Key")
+	created, err := ks.Create(ctx, envID, "Findable Key", nil)
 	if err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -178,7 +182,7 @@ func TestSDKKeyStore_FindByKey_Revoked(t *testing.T) {
 
 	_, envID := createTestEnvironment(t, ps, es)
 
-	created, err := ks.Create(ctx, envID, "Soon Revoked Key")
+	created, err := ks.Create(ctx, envID, "Soon Revoked Key", nil)
 	if err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -204,7 +208,7 @@ func TestSDKKeyStore_Revoke(t *testing.T) {
 
 	_, envID := createTestEnvironment(t, ps, es)
 
-	created, err := ks.Create(ctx, envID, "To Revoke")
+	created, err := ks.Create(ctx, envID, "To Revoke", nil)
 	if err != nil {
 		t.Fatalf("Create: %v", err)
 	}
@@ -227,3 +231,131 @@ func TestSDKKeyStore_Revoke(t *testing.T) {
 		t.Error("expected key to be revoked")
 	}
 }
+
+func TestSDKKeyStore_Touch(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ks := store.NewSDKKeyStore(pool)
+	ctx := context.Background()
+
+	_, envID := createTestEnvironment(t, ps, es)
+
+	created, err := ks.Create(ctx, envID, "Used Key", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.LastUsedAt != nil {
+		t.Fatalf("expected a new key to be unused, got %v", created.LastUsedAt)
+	}
+
+	lastUsed := func() *time.Time {
+		t.Helper()
+		keys, err := ks.ListByEnvironment(ctx, envID)
+		if err != nil || len(keys) != 1 {
+			t.Fatalf("ListByEnvironment: %v, %d keys", err, len(keys))
+		}
+		return keys[0].LastUsedAt
+	}
+
+	if err := ks.Touch(ctx, created.ID); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	first := lastUsed()
+	if first == nil {
+		t.Fatal("expected last_used_at to be set after Touch")
+	}
+
+	// A second use within the touch interval writes nothing.
+	if err := ks.Touch(ctx, created.ID); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if again := lastUsed(); again == nil || !again.Equal(*first) {
+		t.Errorf("expected last_used_at to stay %v within the interval, got %v", first, again)
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE sdk_keys SET last_used_at = NOW() - INTERVAL '10 minutes' WHERE id = $1`, created.ID); err != nil {
+		t.Fatalf("backdating last_used_at: %v", err)
+	}
+	if err := ks.Touch(ctx, created.ID); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if advanced := lastUsed(); advanced == nil || time.Since(*advanced) > time.Minute {
+		t.Errorf("expected last_used_at to advance to now, got %v", advanced)
+	}
+}
+
+func TestSDKKeyStore_FindByKey_RejectsExpired(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ks := store.NewSDKKeyStore(pool)
+	ctx := context.Background()
+
+	_, envID := createTestEnvironment(t, ps, es)
+
+	created, err := ks.Create(ctx, envID, "Expired Key", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE sdk_keys SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, created.ID); err != nil {
+		t.Fatalf("expiring key: %v", err)
+	}
+
+	if _, err := ks.FindByKey(ctx, created.Key); err == nil {
+		t.Error("expected an expired key to be rejected")
+	}
+
+	revoked, err := ks.RevokeExpired(ctx)
+	if err != nil {
+		t.Fatalf("RevokeExpired: %v", err)
+	}
+	if revoked < 1 {
+		t.Errorf("expected the expired key to be revoked, got %d", revoked)
+	}
+	keys, err := ks.ListByEnvironment(ctx, envID)
+	if err != nil || len(keys) != 1 || !keys[0].Revoked {
+		t.Errorf("expected the expired key to be marked revoked, got %+v (err %v)", keys, err)
+	}
+}
+
+func TestSDKKeyStore_Rotate(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	ks := store.NewSDKKeyStore(pool)
+	ctx := context.Background()
+
+	_, envID := createTestEnvironment(t, ps, es)
+
+	created, err := ks.Create(ctx, envID, "Rotated Key", []model.SDKKeyScope{model.SDKKeyScopeEvaluate})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	old, replacement, err := ks.Rotate(ctx, envID, created.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if old.ID != created.ID || old.ExpiresAt == nil || time.Until(*old.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expected the old key to expire in an hour, got %+v", old)
+	}
+	if replacement.Key == created.Key || replacement.RotatedFromID == nil || *replacement.RotatedFromID != created.ID {
+		t.Errorf("expected a new key linked to the old one, got %+v", replacement)
+	}
+	if replacement.Name != created.Name || !slices.Equal(replacement.Scopes, created.Scopes) || replacement.ExpiresAt != nil {
+		t.Errorf("expected the new key to keep name and scopes without expiry, got %+v", replacement)
+	}
+
+	// Both keys authenticate during the grace period.
+	for _, key := range []string{created.Key, replacement.Key} {
+		if _, err := ks.FindByKey(ctx, key); err != nil {
+			t.Errorf("FindByKey(%s) during grace period: %v", key, err)
+		}
+	}
+
+	_, otherEnvID := createTestEnvironment(t, ps, es)
+	if _, _, err := ks.Rotate(ctx, otherEnvID, created.ID, time.Hour); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("rotating via another environment: expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+// segmentColumns is the column list scanned by scanSegment.
+const segmentColumns = `id, project_id, key, name, description, conditions, created_at, updated_at`
+
+type SegmentStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewSegmentStore(pool *pgxpool.Pool) *SegmentStore {
+	return &SegmentStore{pool: pool}
+}
+
+// Create inserts a new segment for a project.
+func (s *SegmentStore) Create(ctx context.Context, projectID, key, name, description string, conditions []model.Condition) (*model.Segment, error) {
+	conditionsJSON, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling conditions: %w", err)
+	}
+	seg, err := scanSegment(s.pool.QueryRow(ctx,
+		`INSERT INTO segments (project_id, key, name, description, conditions)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+segmentColumns,
+		projectID, key, name, description, conditionsJSON,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating segment: %w", err)
+	}
+	return seg, nil
+}
+
+// ListByProject returns all segments for a project, ordered by key.
+func (s *SegmentStore) ListByProject(ctx context.Context, projectID string) ([]model.Segment, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+segmentColumns+` FROM segments WHERE project_id = $1 ORDER BY key`,
+		projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing segments: %w", err)
+	}
+	defer rows.Close()
+
+	var segments []model.Segment
+	for rows.Next() {
+		seg, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, *seg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating segments: %w", err)
+	}
+	return segments, nil
+}
+
+// FindByKey returns a segment by project ID and segment key.
+func (s *SegmentStore) FindByKey(ctx context.Context, projectID, key string) (*model.Segment, error) {
+	seg, err := scanSegment(s.pool.QueryRow(ctx,
+		`SELECT `+segmentColumns+` FROM segments WHERE project_id = $1 AND key = $2`,
+		projectID, key,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding segment: %w", err)
+	}
+	return seg, nil
+}
+
+// Update replaces a segment's name, description, and conditions.
+func (s *SegmentStore) Update(ctx context.Context, id, name, description string, conditions []model.Condition) (*model.Segment, error) {
+	conditionsJSON, err := json.Marshal(conditions)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling conditions: %w", err)
+	}
+	seg, err := scanSegment(s.pool.QueryRow(ctx,
+		`UPDATE segments SET name=$2, description=$3, conditions=$4, updated_at=NOW() WHERE id=$1
+		 RETURNING `+segmentColumns,
+		id, name, description, conditionsJSON,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("updating segment: %w", err)
+	}
+	return seg, nil
+}
+
+// Delete removes a segment.
+func (s *SegmentStore) Delete(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM segments WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting segment: %w", err)
+	}
+	return nil
+}
+
+func scanSegment(row pgx.Row) (*model.Segment, error) {
+	var seg model.Segment
+	var conditionsJSON []byte
+	err := row.Scan(&seg.ID, &seg.ProjectID, &seg.Key, &seg.Name, &seg.Description, &conditionsJSON, &seg.CreatedAt, &seg.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("scanning segment: %w", err)
+	}
+	if err := json.Unmarshal(conditionsJSON, &seg.Conditions); err != nil {
+		return nil, fmt.Errorf("decoding segment conditions: %w", err)
+	}
+	if seg.Conditions == nil {
+		seg.Conditions = []model.Condition{}
+	}
+	return &seg, nil
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestSegmentStore_CRUD(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	ss := store.NewSegmentStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("segments"), "Segments Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+
+	conditions := []model.Condition{
+		{Attribute: "plan", Operator: "equals", Value: "enterprise"},
+		{Attribute: "region", Operator: "equals", Value: "eu"},
+	}
+	created, err := ss.Create(ctx, project.ID, "enterprise-eu", "Enterprise EU", "", conditions)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	found, err := ss.FindByKey(ctx, project.ID, "enterprise-eu")
+	if err != nil {
+		t.Fatalf("FindByKey: %v", err)
+	}
+	if found.ID != created.ID || len(found.Conditions) != 2 || found.Conditions[1].Value != "eu" {
+		t.Errorf("unexpected segment: %+v", found)
+	}
+
+	updated, err := ss.Update(ctx, created.ID, "Enterprise (EU)", "EU enterprise customers", conditions[:1])
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Name != "Enterprise (EU)" || len(updated.Conditions) != 1 {
+		t.Errorf("Update: got name=%q conditions=%d", updated.Name, len(updated.Conditions))
+	}
+
+	list, err := ss.ListByProject(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("ListByProject: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(list))
+	}
+
+	if err := ss.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := ss.FindByKey(ctx, project.ID, "enterprise-eu"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("FindByKey after delete: expected ErrNotFound, got %v", err)
+	}
+}
@@ -4,21 +4,45 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
 
+// sessionTouchInterval limits how often Touch writes last_active_at, so
+// authenticated requests don't each cost a database write.
+const sessionTouchInterval = time.Minute
+
+const sessionColumns = `id, user_id, expires_at, last_active_at, created_at`
+
 type SessionStore struct {
 	pool *pgxpool.Pool
+	// idleTimeout rejects sessions inactive for longer; zero disables it.
+	idleTimeout time.Duration
 }
 
 func NewSessionStore(pool *pgxpool.Pool) *SessionStore {
 	return &SessionStore{pool: pool}
 }
 
+// SetIdleTimeout makes FindByID reject sessions that have not been active
+// within d. Zero or less disables idle expiry.
+func (s *SessionStore) SetIdleTimeout(d time.Duration) {
+	s.idleTimeout = d
+}
+
+func scanSession(row pgx.Row) (*model.Session, error) {
+	var session model.Session
+	if err := row.Scan(&session.ID, &session.UserID, &session.ExpiresAt, &session.LastActiveAt, &session.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
 func (s *SessionStore) Create(ctx context.Context, userID string, duration time.Duration) (*model.Session, error) {
 	id, err := generateSessionID()
 	if err != nil {
@@ -26,29 +50,63 @@ func (s *SessionStore) Create(ctx context.Context, userID string, duration time.
 	}
 
 	expiresAt := time.Now().Add(duration)
-	var session model.Session
-	err = s.pool.QueryRow(ctx,
+	session, err := scanSession(s.pool.QueryRow(ctx,
 		`INSERT INTO sessions (id, user_id, expires_at) VALUES ($1, $2, $3)
-		 RETURNING id, user_id, expires_at, created_at`,
+		 RETURNING `+sessionColumns,
 		id, userID, expiresAt,
-	).Scan(&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("creating session: %w", err)
 	}
-	return &session, nil
+	return session, nil
 }
 
+// FindByID returns the session if it has neither expired nor, when an idle
+// timeout is set, been inactive for longer than the timeout.
 func (s *SessionStore) FindByID(ctx context.Context, id string) (*model.Session, error) {
-	var session model.Session
-	err := s.pool.QueryRow(ctx,
-		`SELECT id, user_id, expires_at, created_at FROM sessions
-		 WHERE id = $1 AND expires_at > NOW()`,
-		id,
-	).Scan(&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt)
+	session, err := scanSession(s.pool.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		 WHERE id = $1 AND expires_at > NOW()
+		   AND ($2::float8 <= 0 OR last_active_at > NOW() - make_interval(secs => $2))`,
+		id, s.idleTimeout.Seconds(),
+	))
 	if err != nil {
 		return nil, fmt.Errorf("finding session: %w", err)
 	}
-	return &session, nil
+	return session, nil
+}
+
+// Touch records activity on a session. Writes are skipped if the session was
+// already marked active within the last minute.
+func (s *SessionStore) Touch(ctx context.Context, id string) error {
+	_, err := s.pool.Exec(ctx,
+		`UPDATE sessions SET last_active_at = NOW()
+		 WHERE id = $1 AND last_active_at < NOW() - make_interval(secs => $2)`,
+		id, sessionTouchInterval.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("touching session: %w", err)
+	}
+	return nil
+}
+
+// Extend pushes an active session's expiry to duration from now and marks it
+// active. It returns ErrNotFound if the session has expired or gone idle.
+func (s *SessionStore) Extend(ctx context.Context, id string, duration time.Duration) (*model.Session, error) {
+	session, err := scanSession(s.pool.QueryRow(ctx,
+		`UPDATE sessions SET expires_at = $2, last_active_at = NOW()
+		 WHERE id = $1 AND expires_at > NOW()
+		   AND ($3::float8 <= 0 OR last_active_at > NOW() - make_interval(secs => $3))
+		 RETURNING `+sessionColumns,
+		id, time.Now().Add(duration), s.idleTimeout.Seconds(),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extending session: %w", err)
+	}
+	return session, nil
 }
 
 func (s *SessionStore) Delete(ctx context.Context, id string) error {
@@ -2,6 +2,7 @@ package store_test
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -91,3 +92,78 @@ func TestSessionStore_Delete(t *testing.T) {
 		t.Errorf("unexpected error message: %v", err)
 	}
 }
+
+func TestSessionStore_IdleTimeout(t *testing.T) {
+	pool := testPool(t)
+	us := store.NewUserStore(pool)
+	ss := store.NewSessionStore(pool)
+	ss.SetIdleTimeout(30 * time.Minute)
+	ctx := context.Background()
+
+	user, err := us.Create(ctx, uniqueEmail("session-idle"), "hashidle", model.RoleMember)
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	active, err := ss.Create(ctx, user.ID, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Create active session: %v", err)
+	}
+	idle, err := ss.Create(ctx, user.ID, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Create idle session: %v", err)
+	}
+
+	// Both sessions are unexpired; only one was used recently.
+	if _, err := pool.Exec(ctx, `UPDATE sessions SET last_active_at = NOW() - INTERVAL '10 minutes' WHERE id = $1`, active.ID); err != nil {
+		t.Fatalf("aging active session: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE sessions SET last_active_at = NOW() - INTERVAL '2 hours' WHERE id = $1`, idle.ID); err != nil {
+		t.Fatalf("aging idle session: %v", err)
+	}
+
+	if _, err := ss.FindByID(ctx, active.ID); err != nil {
+		t.Errorf("expected recently active session to be accepted, got %v", err)
+	}
+	if _, err := ss.FindByID(ctx, idle.ID); err == nil {
+		t.Error("expected idle session to be rejected")
+	}
+	if _, err := ss.Extend(ctx, idle.ID, 24*time.Hour); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected idle session not to be extended, got %v", err)
+	}
+
+	// Touching refreshes activity, keeping the session alive.
+	if err := ss.Touch(ctx, active.ID); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	found, err := ss.FindByID(ctx, active.ID)
+	if err != nil {
+		t.Fatalf("FindByID after Touch: %v", err)
+	}
+	if time.Since(found.LastActiveAt) > time.Minute {
+		t.Errorf("expected Touch to update last_active_at, got %v", found.LastActiveAt)
+	}
+}
+
+func TestSessionStore_Extend(t *testing.T) {
+	pool := testPool(t)
+	us := store.NewUserStore(pool)
+	ss := store.NewSessionStore(pool)
+	ctx := context.Background()
+
+	user, err := us.Create(ctx, uniqueEmail("session-extend"), "hashext", model.RoleMember)
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	session, err := ss.Create(ctx, user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Create session: %v", err)
+	}
+
+	extended, err := ss.Extend(ctx, session.ID, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	if !extended.ExpiresAt.After(session.ExpiresAt.Add(46 * time.Hour)) {
+		t.Errorf("expected expiry to move ~48h out, got %v (was %v)", extended.ExpiresAt, session.ExpiresAt)
+	}
+}
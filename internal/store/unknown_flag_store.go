@@ -2,8 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
@@ -72,6 +74,30 @@ func (s *UnknownFlagStore) ListByProject(ctx context.Context, projectID string)
 	return flags, nil
 }
 
+// FindByID returns an unknown flag by ID, whether or not it was dismissed.
+// The projectID parameter ensures the flag belongs to the expected project.
+func (s *UnknownFlagStore) FindByID(ctx context.Context, id, projectID string) (*model.UnknownFlag, error) {
+	var f model.UnknownFlag
+	err := s.pool.QueryRow(ctx,
+		`SELECT uf.id, uf.project_id, uf.environment_id, uf.flag_key,
+		        uf.request_count, uf.first_seen_at, uf.last_seen_at, uf.dismissed_at,
+		        e.key, e.name
+		 FROM unknown_flags uf
+		 JOIN environments e ON e.id = uf.environment_id
+		 WHERE uf.id = $1 AND uf.project_id = $2`,
+		id, projectID,
+	).Scan(&f.ID, &f.ProjectID, &f.EnvironmentID, &f.FlagKey,
+		&f.RequestCount, &f.FirstSeenAt, &f.LastSeenAt, &f.DismissedAt,
+		&f.EnvironmentKey, &f.EnvironmentName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding unknown flag: %w", err)
+	}
+	return &f, nil
+}
+
 // Dismiss soft-deletes an unknown flag by setting dismissed_at.
 // The projectID parameter ensures the flag belongs to the expected project.
 func (s *UnknownFlagStore) Dismiss(ctx context.Context, id, projectID string) error {
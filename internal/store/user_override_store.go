@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type UserOverrideStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewUserOverrideStore(pool *pgxpool.Pool) *UserOverrideStore {
+	return &UserOverrideStore{pool: pool}
+}
+
+// Set creates or replaces the override forcing variant for a user on a flag
+// in an environment.
+func (s *UserOverrideStore) Set(ctx context.Context, flagID, environmentID, userID, variant string) (*model.UserOverride, error) {
+	var o model.UserOverride
+	err := s.pool.QueryRow(ctx,
+		`WITH upserted AS (
+		     INSERT INTO flag_user_overrides (flag_id, environment_id, user_id, variant)
+		     VALUES ($1, $2, $3, $4)
+		     ON CONFLICT (flag_id, environment_id, user_id) DO UPDATE
+		     SET variant = EXCLUDED.variant, created_at = NOW()
+		     RETURNING flag_id, environment_id, user_id, variant, created_at
+		 )
+		 SELECT u.flag_id, f.key, u.environment_id, e.key, u.user_id, u.variant, u.created_at
+		 FROM upserted u
+		 JOIN flags f ON f.id = u.flag_id
+		 JOIN environments e ON e.id = u.environment_id`,
+		flagID, environmentID, userID, variant,
+	).Scan(&o.FlagID, &o.FlagKey, &o.EnvironmentID, &o.EnvironmentKey, &o.UserID, &o.Variant, &o.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("setting user override: %w", err)
+	}
+	return &o, nil
+}
+
+// ListByUser returns every override for a user across a project's flags and
+// environments, ordered by flag and environment key.
+func (s *UserOverrideStore) ListByUser(ctx context.Context, projectID, userID string) ([]model.UserOverride, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT o.flag_id, f.key, o.environment_id, e.key, o.user_id, o.variant, o.created_at
+		 FROM flag_user_overrides o
+		 JOIN flags f ON f.id = o.flag_id
+		 JOIN environments e ON e.id = o.environment_id
+		 WHERE f.project_id = $1 AND o.user_id = $2 AND f.deleted_at IS NULL
+		 ORDER BY f.key, e.key`,
+		projectID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing user overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []model.UserOverride
+	for rows.Next() {
+		var o model.UserOverride
+		if err := rows.Scan(&o.FlagID, &o.FlagKey, &o.EnvironmentID, &o.EnvironmentKey, &o.UserID, &o.Variant, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning user override: %w", err)
+		}
+		overrides = append(overrides, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating user overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// Delete removes a user's override on a flag in an environment. It returns
+// ErrNotFound if there was none.
+func (s *UserOverrideStore) Delete(ctx context.Context, flagID, environmentID, userID string) error {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM flag_user_overrides WHERE flag_id = $1 AND environment_id = $2 AND user_id = $3`,
+		flagID, environmentID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("deleting user override: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
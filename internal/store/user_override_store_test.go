@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestUserOverrideStore_SetListDelete(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	uos := store.NewUserOverrideStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("overrides"), "Overrides Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating environment: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeRelease, json.RawMessage(`false`), nil)
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	o, err := uos.Set(ctx, flag.ID, env.ID, "qa-user", "on")
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if o.FlagKey != "checkout" || o.EnvironmentKey != "staging" || o.Variant != "on" {
+		t.Errorf("unexpected override: %+v", o)
+	}
+
+	// Setting again replaces the variant.
+	if _, err := uos.Set(ctx, flag.ID, env.ID, "qa-user", "off"); err != nil {
+		t.Fatalf("Set (replace): %v", err)
+	}
+
+	list, err := uos.ListByUser(ctx, project.ID, "qa-user")
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(list) != 1 || list[0].Variant != "off" {
+		t.Fatalf("expected 1 override with variant off, got %+v", list)
+	}
+
+	if err := uos.Delete(ctx, flag.ID, env.ID, "qa-user"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := uos.Delete(ctx, flag.ID, env.ID, "qa-user"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting a missing override, got %v", err)
+	}
+}
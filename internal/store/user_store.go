@@ -2,8 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/togglerino/togglerino/internal/model"
 )
@@ -35,12 +37,81 @@ func (s *UserStore) FindByEmail(ctx context.Context, email string) (*model.User,
 		`SELECT id, email, password_hash, role, created_at, updated_at FROM users WHERE email = $1`,
 		email,
 	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("finding user by email: %w", err)
 	}
 	return &user, nil
 }
 
+// FindByOIDCIdentity returns the user linked to the provider identity, or
+// ErrNotFound if no user has signed in with it yet.
+func (s *UserStore) FindByOIDCIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	var user model.User
+	err := s.pool.QueryRow(ctx,
+		`SELECT u.id, u.email, u.password_hash, u.role, u.created_at, u.updated_at
+		 FROM oidc_identities i JOIN users u ON u.id = i.user_id
+		 WHERE i.issuer = $1 AND i.subject = $2`,
+		issuer, subject,
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding user by oidc identity: %w", err)
+	}
+	return &user, nil
+}
+
+// CreateWithOIDCIdentity creates a user without a password and links it to the
+// provider identity in one transaction.
+func (s *UserStore) CreateWithOIDCIdentity(ctx context.Context, email string, role model.Role, issuer, subject string) (*model.User, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var user model.User
+	err = tx.QueryRow(ctx,
+		`INSERT INTO users (email, password_hash, role) VALUES ($1, '', $2)
+		 RETURNING id, email, password_hash, role, created_at, updated_at`,
+		email, role,
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("creating user: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO oidc_identities (issuer, subject, user_id) VALUES ($1, $2, $3)`,
+		issuer, subject, user.ID,
+	); err != nil {
+		return nil, fmt.Errorf("linking oidc identity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return &user, nil
+}
+
+// LinkOIDCIdentity links the provider identity to an existing user. It
+// returns ErrAlreadyExists if the user is already linked to an identity.
+func (s *UserStore) LinkOIDCIdentity(ctx context.Context, userID, issuer, subject string) error {
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO oidc_identities (issuer, subject, user_id)
+		 SELECT $1, $2, $3 WHERE NOT EXISTS (SELECT 1 FROM oidc_identities WHERE user_id = $3)`,
+		issuer, subject, userID)
+	if err != nil {
+		return fmt.Errorf("linking oidc identity: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
 func (s *UserStore) FindByID(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
 	err := s.pool.QueryRow(ctx,
@@ -2,6 +2,7 @@ package store_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -124,3 +125,37 @@ func TestUserStore_Count(t *testing.T) {
 		t.Errorf("count did not increase: before=%d, after=%d", count, newCount)
 	}
 }
+
+func TestUserStore_OIDCIdentity(t *testing.T) {
+	pool := testPool(t)
+	us := store.NewUserStore(pool)
+	ctx := context.Background()
+	issuer := "https://issuer.test.togglerino.dev"
+	subject := uniqueEmail("subject")
+
+	if _, err := us.FindByOIDCIdentity(ctx, issuer, subject); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before linking, got %v", err)
+	}
+
+	user, err := us.CreateWithOIDCIdentity(ctx, uniqueEmail("oidc"), model.RoleMember, issuer, subject)
+	if err != nil {
+		t.Fatalf("CreateWithOIDCIdentity: %v", err)
+	}
+	if user.PasswordHash != "" {
+		t.Errorf("expected no password hash, got %q", user.PasswordHash)
+	}
+	found, err := us.FindByOIDCIdentity(ctx, issuer, subject)
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("expected user %s, got %+v, %v", user.ID, found, err)
+	}
+
+	// The same subject at another issuer is a different identity
+	if _, err := us.FindByOIDCIdentity(ctx, issuer+"/other", subject); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another issuer, got %v", err)
+	}
+
+	// A user keeps the identity it was first linked to
+	if err := us.LinkOIDCIdentity(ctx, user.ID, issuer, subject+"-2"); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists linking a second identity, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/togglerino/togglerino/internal/model"
+)
+
+type VariantEvaluationStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewVariantEvaluationStore(pool *pgxpool.Pool) *VariantEvaluationStore {
+	return &VariantEvaluationStore{pool: pool}
+}
+
+// Increment adds a batch of variant evaluation counts to the hour containing
+// at, in one statement. Counts for flags or environments deleted in the
+// meantime are dropped.
+func (s *VariantEvaluationStore) Increment(ctx context.Context, at time.Time, counts map[model.VariantEvaluation]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	flagIDs := make([]string, 0, len(counts))
+	envIDs := make([]string, 0, len(counts))
+	variants := make([]string, 0, len(counts))
+	ns := make([]int64, 0, len(counts))
+	for k, n := range counts {
+		flagIDs = append(flagIDs, k.FlagID)
+		envIDs = append(envIDs, k.EnvironmentID)
+		variants = append(variants, k.Variant)
+		ns = append(ns, n)
+	}
+
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO variant_evaluations (flag_id, environment_id, variant, hour, evaluations)
+		 SELECT c.flag_id, c.environment_id, c.variant, date_trunc('hour', $5::timestamptz), c.n
+		 FROM unnest($1::uuid[], $2::uuid[], $3::text[], $4::bigint[]) AS c(flag_id, environment_id, variant, n)
+		 JOIN flags f ON f.id = c.flag_id
+		 JOIN environments e ON e.id = c.environment_id
+		 ON CONFLICT (flag_id, environment_id, variant, hour) DO UPDATE
+		 SET evaluations = variant_evaluations.evaluations + EXCLUDED.evaluations`,
+		flagIDs, envIDs, variants, ns, at,
+	)
+	if err != nil {
+		return fmt.Errorf("incrementing variant evaluations: %w", err)
+	}
+	return nil
+}
+
+// CountsByFlag returns how often each variant of a flag was served since the
+// start of the hour containing since, keyed by environment key and then by
+// variant key. Environments with no evaluations are omitted.
+func (s *VariantEvaluationStore) CountsByFlag(ctx context.Context, flagID string, since time.Time) (map[string]map[string]int64, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT e.key, ve.variant, SUM(ve.evaluations)::bigint
+		 FROM variant_evaluations ve
+		 JOIN environments e ON e.id = ve.environment_id
+		 WHERE ve.flag_id = $1 AND ve.hour >= date_trunc('hour', $2::timestamptz)
+		 GROUP BY e.key, ve.variant`,
+		flagID, since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing variant evaluations: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[string]int64)
+	for rows.Next() {
+		var envKey, variant string
+		var n int64
+		if err := rows.Scan(&envKey, &variant, &n); err != nil {
+			return nil, fmt.Errorf("scanning variant evaluations: %w", err)
+		}
+		if counts[envKey] == nil {
+			counts[envKey] = make(map[string]int64)
+		}
+		counts[envKey][variant] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating variant evaluations: %w", err)
+	}
+	return counts, nil
+}
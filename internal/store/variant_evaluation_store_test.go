@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/togglerino/togglerino/internal/model"
+	"github.com/togglerino/togglerino/internal/store"
+)
+
+func TestVariantEvaluationStore_IncrementAccumulates(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	vs := store.NewVariantEvaluationStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("variants"), "Variants Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	prod, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	staging, err := es.Create(ctx, project.ID, "staging", "Staging")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "checkout", "Checkout", "", model.ValueTypeBoolean, model.FlagTypeExperiment, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	now := time.Now()
+	on := model.VariantEvaluation{FlagID: flag.ID, EnvironmentID: prod.ID, Variant: "on"}
+	off := model.VariantEvaluation{FlagID: flag.ID, EnvironmentID: prod.ID, Variant: "off"}
+	stagingOn := model.VariantEvaluation{FlagID: flag.ID, EnvironmentID: staging.ID, Variant: "on"}
+	if err := vs.Increment(ctx, now, map[model.VariantEvaluation]int64{on: 3, off: 1, stagingOn: 2}); err != nil {
+		t.Fatalf("first Increment: %v", err)
+	}
+	if err := vs.Increment(ctx, now, map[model.VariantEvaluation]int64{on: 4}); err != nil {
+		t.Fatalf("second Increment: %v", err)
+	}
+
+	counts, err := vs.CountsByFlag(ctx, flag.ID, now)
+	if err != nil {
+		t.Fatalf("CountsByFlag: %v", err)
+	}
+	if counts["production"]["on"] != 7 || counts["production"]["off"] != 1 || counts["staging"]["on"] != 2 {
+		t.Errorf("expected production on=7 off=1 and staging on=2, got %v", counts)
+	}
+}
+
+func TestVariantEvaluationStore_CountsByFlagWindow(t *testing.T) {
+	pool := testPool(t)
+	ps := store.NewProjectStore(pool)
+	es := store.NewEnvironmentStore(pool)
+	fs := store.NewFlagStore(pool)
+	vs := store.NewVariantEvaluationStore(pool)
+	ctx := context.Background()
+
+	project, err := ps.Create(ctx, uniqueKey("variantwin"), "Variant Window Project", "test")
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	env, err := es.Create(ctx, project.ID, "production", "Production")
+	if err != nil {
+		t.Fatalf("creating env: %v", err)
+	}
+	flag, err := fs.Create(ctx, project.ID, "pricing", "Pricing", "", model.ValueTypeBoolean, model.FlagTypeExperiment, json.RawMessage(`false`), []string{})
+	if err != nil {
+		t.Fatalf("creating flag: %v", err)
+	}
+
+	now := time.Now()
+	key := model.VariantEvaluation{FlagID: flag.ID, EnvironmentID: env.ID, Variant: "on"}
+	for _, at := range []time.Time{now.Add(-72 * time.Hour), now.Add(-2 * time.Hour), now} {
+		if err := vs.Increment(ctx, at, map[model.VariantEvaluation]int64{key: 5}); err != nil {
+			t.Fatalf("Increment at %v: %v", at, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		since time.Time
+		want  int64
+	}{
+		{now.Add(-96 * time.Hour), 15},
+		{now.Add(-24 * time.Hour), 10},
+		{now, 5},
+	} {
+		counts, err := vs.CountsByFlag(ctx, flag.ID, tc.since)
+		if err != nil {
+			t.Fatalf("CountsByFlag: %v", err)
+		}
+		if got := counts["production"]["on"]; got != tc.want {
+			t.Errorf("since %v: got %d, want %d", tc.since, got, tc.want)
+		}
+	}
+
+	if counts, err := vs.CountsByFlag(ctx, flag.ID, now.Add(time.Hour)); err != nil || len(counts) != 0 {
+		t.Errorf("expected no counts in a future window, got %v (err %v)", counts, err)
+	}
+}
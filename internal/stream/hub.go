@@ -1,9 +1,36 @@
 package stream
 
-import "sync"
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooManySubscribers is returned by Subscribe when a project/environment
+// already has the maximum number of subscribers.
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// ErrDraining is returned by Subscribe once the hub has started draining for
+// shutdown.
+var ErrDraining = errors.New("hub is draining")
+
+// historySize is the number of recent events kept per project/environment
+// for replay to reconnecting clients.
+const historySize = 64
+
+// EventRefetch is the type of the event sent instead of a replay when a
+// client has missed more events than the hub still holds.
+const EventRefetch = "refetch"
+
+// EventClosing is sent by Drain to tell a subscriber the server is shutting
+// down. It is not broadcast, numbered or kept in the history.
+const EventClosing = "closing"
 
 // Event represents a flag change event sent to SSE clients.
 type Event struct {
+	// ID is assigned by Broadcast and increases monotonically across the hub.
+	ID      uint64 `json:"id"`
 	Type    string `json:"type"`
 	FlagKey string `json:"flagKey"`
 	Value   any    `json:"value"`
@@ -15,29 +42,97 @@ type Hub struct {
 	mu sync.RWMutex
 	// Key: "projectKey:envKey", Value: set of subscriber channels
 	subscribers map[string]map[chan Event]struct{}
+	// maxPerScope caps subscribers per project/environment; zero means unlimited.
+	maxPerScope int
+	// history holds the most recent events per scope, oldest first.
+	history map[string][]Event
+	// evicted is the ID of the newest event dropped from a scope's history;
+	// clients that last saw an older event cannot be replayed.
+	evicted map[string]uint64
+	// baseID seeds event IDs. It is the hub's creation time so IDs issued by
+	// a previous process are older than anything this hub can replay.
+	baseID uint64
+	lastID uint64
+	// draining is set by Drain; new subscriptions are then refused.
+	draining bool
 }
 
 // NewHub creates a new Hub ready for use.
 func NewHub() *Hub {
+	base := uint64(time.Now().UnixNano())
 	return &Hub{
 		subscribers: make(map[string]map[chan Event]struct{}),
+		history:     make(map[string][]Event),
+		evicted:     make(map[string]uint64),
+		baseID:      base,
+		lastID:      base,
 	}
 }
 
+// SetMaxSubscribers caps the number of subscribers per project/environment.
+// Zero or less means unlimited.
+func (h *Hub) SetMaxSubscribers(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxPerScope = n
+}
+
 // Subscribe creates a new channel for receiving events for a project/environment.
-// Returns the channel. Caller must call Unsubscribe when done.
-func (h *Hub) Subscribe(projectKey, envKey string) chan Event {
+// Returns ErrTooManySubscribers if the scope is at its subscriber limit.
+// Caller must call Unsubscribe when done.
+func (h *Hub) Subscribe(projectKey, envKey string) (chan Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribeLocked(projectKey + ":" + envKey)
+}
+
+// SubscribeSince subscribes like Subscribe and also returns the buffered
+// events newer than lastID, so a reconnecting client can catch up without a
+// full refetch. If events after lastID have already been evicted, or lastID
+// was not issued by this hub, missed holds a single EventRefetch event
+// instead. Replay and subscription happen atomically, so no event is lost or
+// delivered twice.
+func (h *Hub) SubscribeSince(projectKey, envKey string, lastID uint64) (ch chan Event, missed []Event, err error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	key := projectKey + ":" + envKey
+	ch, err = h.subscribeLocked(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	floor := h.baseID
+	if id, ok := h.evicted[key]; ok {
+		floor = id
+	}
+	if lastID < floor || lastID > h.lastID {
+		return ch, []Event{{ID: h.lastID, Type: EventRefetch}}, nil
+	}
+	for _, event := range h.history[key] {
+		if event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+	return ch, missed, nil
+}
+
+// subscribeLocked registers a new subscriber channel. Caller must hold h.mu.
+func (h *Hub) subscribeLocked(key string) (chan Event, error) {
+	if h.draining {
+		return nil, ErrDraining
+	}
+	if h.maxPerScope > 0 && len(h.subscribers[key]) >= h.maxPerScope {
+		return nil, ErrTooManySubscribers
+	}
 	if h.subscribers[key] == nil {
 		h.subscribers[key] = make(map[chan Event]struct{})
 	}
 
 	ch := make(chan Event, 16) // buffered to avoid blocking broadcasts
 	h.subscribers[key][ch] = struct{}{}
-	return ch
+	return ch, nil
 }
 
 // Unsubscribe removes a channel from the subscriber set and closes it.
@@ -55,13 +150,23 @@ func (h *Hub) Unsubscribe(projectKey, envKey string, ch chan Event) {
 	}
 }
 
-// Broadcast sends an event to all subscribers for a project/environment.
+// Broadcast assigns the event an ID, records it in the scope's replay history
+// and sends it to all subscribers for a project/environment.
 // Non-blocking: if a subscriber's channel is full, the event is dropped for that subscriber.
 func (h *Hub) Broadcast(projectKey, envKey string, event Event) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	key := projectKey + ":" + envKey
+	h.lastID++
+	event.ID = h.lastID
+	history := append(h.history[key], event)
+	if len(history) > historySize {
+		h.evicted[key] = history[0].ID
+		history = history[1:]
+	}
+	h.history[key] = history
+
 	if subs, ok := h.subscribers[key]; ok {
 		for ch := range subs {
 			select {
@@ -73,6 +178,29 @@ func (h *Hub) Broadcast(projectKey, envKey string, event Event) {
 	}
 }
 
+// Scope identifies a project/environment pair.
+type Scope struct {
+	ProjectKey string
+	EnvKey     string
+}
+
+// Scopes returns the project/environment pairs that currently have at least
+// one subscriber, in no particular order.
+func (h *Hub) Scopes() []Scope {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	scopes := make([]Scope, 0, len(h.subscribers))
+	for key, subs := range h.subscribers {
+		if len(subs) == 0 {
+			continue
+		}
+		projectKey, envKey, _ := strings.Cut(key, ":")
+		scopes = append(scopes, Scope{ProjectKey: projectKey, EnvKey: envKey})
+	}
+	return scopes
+}
+
 // SubscriberCount returns the number of subscribers for a project/environment (for testing/monitoring).
 func (h *Hub) SubscriberCount(projectKey, envKey string) int {
 	h.mu.RLock()
@@ -82,6 +210,45 @@ func (h *Hub) SubscriberCount(projectKey, envKey string) int {
 	return len(h.subscribers[key])
 }
 
+// Drain prepares the hub for shutdown: it refuses new subscriptions, sends
+// every subscriber an EventClosing event so its client can disconnect and
+// reconnect elsewhere, waits up to grace for subscribers to unsubscribe and
+// then closes the channels of any that remain. A subscriber whose channel is
+// full misses the event and only sees its channel close.
+func (h *Hub) Drain(grace time.Duration) {
+	h.mu.Lock()
+	h.draining = true
+	for _, subs := range h.subscribers {
+		for ch := range subs {
+			select {
+			case ch <- Event{Type: EventClosing}:
+			default:
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) && h.totalSubscribers() > 0 {
+		time.Sleep(min(drainPollInterval, time.Until(deadline)))
+	}
+	h.Close()
+}
+
+// drainPollInterval is how often Drain checks whether every subscriber has
+// left before its grace period ends.
+const drainPollInterval = 20 * time.Millisecond
+
+func (h *Hub) totalSubscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := 0
+	for _, subs := range h.subscribers {
+		n += len(subs)
+	}
+	return n
+}
+
 // Close closes all subscriber channels and clears the subscribers map.
 // It should be called during graceful shutdown to notify all connected SSE clients.
 func (h *Hub) Close() {
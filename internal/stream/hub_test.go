@@ -1,15 +1,25 @@
 package stream
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
 )
 
+func mustSubscribe(t *testing.T, hub *Hub, projectKey, envKey string) chan Event {
+	t.Helper()
+	ch, err := hub.Subscribe(projectKey, envKey)
+	if err != nil {
+		t.Fatalf("Subscribe(%q, %q): %v", projectKey, envKey, err)
+	}
+	return ch
+}
+
 func TestSubscribeAndReceiveBroadcast(t *testing.T) {
 	hub := NewHub()
 
-	ch := hub.Subscribe("proj1", "staging")
+	ch := mustSubscribe(t, hub, "proj1", "staging")
 	defer hub.Unsubscribe("proj1", "staging", ch)
 
 	event := Event{FlagKey: "dark-mode", Value: true, Variant: "on"}
@@ -34,7 +44,7 @@ func TestSubscribeAndReceiveBroadcast(t *testing.T) {
 func TestUnsubscribeRemovesChannel(t *testing.T) {
 	hub := NewHub()
 
-	ch := hub.Subscribe("proj1", "prod")
+	ch := mustSubscribe(t, hub, "proj1", "prod")
 	if count := hub.SubscriberCount("proj1", "prod"); count != 1 {
 		t.Fatalf("expected 1 subscriber, got %d", count)
 	}
@@ -65,7 +75,7 @@ func TestMultipleSubscribersReceiveSameEvent(t *testing.T) {
 	const numSubscribers = 5
 	channels := make([]chan Event, numSubscribers)
 	for i := 0; i < numSubscribers; i++ {
-		channels[i] = hub.Subscribe("proj1", "dev")
+		channels[i] = mustSubscribe(t, hub, "proj1", "dev")
 	}
 	defer func() {
 		for _, ch := range channels {
@@ -101,7 +111,7 @@ func TestMultipleSubscribersReceiveSameEvent(t *testing.T) {
 func TestBroadcastDropsEventWhenChannelFull(t *testing.T) {
 	hub := NewHub()
 
-	ch := hub.Subscribe("proj1", "staging")
+	ch := mustSubscribe(t, hub, "proj1", "staging")
 	defer hub.Unsubscribe("proj1", "staging", ch)
 
 	// Fill the channel buffer (capacity 16)
@@ -136,8 +146,8 @@ func TestBroadcastDropsEventWhenChannelFull(t *testing.T) {
 func TestScopesAreIsolated(t *testing.T) {
 	hub := NewHub()
 
-	ch1 := hub.Subscribe("proj1", "staging")
-	ch2 := hub.Subscribe("proj2", "prod")
+	ch1 := mustSubscribe(t, hub, "proj1", "staging")
+	ch2 := mustSubscribe(t, hub, "proj2", "prod")
 	defer hub.Unsubscribe("proj1", "staging", ch1)
 	defer hub.Unsubscribe("proj2", "prod", ch2)
 
@@ -175,7 +185,11 @@ func TestConcurrentSubscribeUnsubscribeBroadcast(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			for j := 0; j < iterations; j++ {
-				ch := hub.Subscribe("proj1", "env1")
+				ch, err := hub.Subscribe("proj1", "env1")
+				if err != nil {
+					t.Errorf("Subscribe: %v", err)
+					return
+				}
 				hub.Unsubscribe("proj1", "env1", ch)
 			}
 		}()
@@ -208,3 +222,173 @@ func TestConcurrentSubscribeUnsubscribeBroadcast(t *testing.T) {
 		t.Errorf("expected 0 subscribers after concurrent test, got %d", count)
 	}
 }
+
+func TestSubscribeBeyondLimitRejected(t *testing.T) {
+	hub := NewHub()
+	hub.SetMaxSubscribers(2)
+
+	ch1 := mustSubscribe(t, hub, "proj1", "prod")
+	ch2 := mustSubscribe(t, hub, "proj1", "prod")
+
+	if _, err := hub.Subscribe("proj1", "prod"); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("expected ErrTooManySubscribers, got %v", err)
+	}
+	if count := hub.SubscriberCount("proj1", "prod"); count != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", count)
+	}
+
+	// The limit is per scope
+	other := mustSubscribe(t, hub, "proj1", "staging")
+	defer hub.Unsubscribe("proj1", "staging", other)
+
+	// Existing subscribers keep receiving events
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "dark-mode", Value: true})
+	for i, ch := range []chan Event{ch1, ch2} {
+		select {
+		case e := <-ch:
+			if e.FlagKey != "dark-mode" {
+				t.Errorf("subscriber %d: expected dark-mode, got %q", i, e.FlagKey)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %d: timed out waiting for event", i)
+		}
+	}
+
+	// Freeing a slot allows a new subscriber
+	hub.Unsubscribe("proj1", "prod", ch1)
+	ch3 := mustSubscribe(t, hub, "proj1", "prod")
+	hub.Unsubscribe("proj1", "prod", ch2)
+	hub.Unsubscribe("proj1", "prod", ch3)
+}
+
+func TestSubscribeSinceReplaysMissedEvents(t *testing.T) {
+	hub := NewHub()
+
+	ch := mustSubscribe(t, hub, "proj1", "prod")
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "a"})
+	first := <-ch
+	hub.Unsubscribe("proj1", "prod", ch)
+
+	// Missed while disconnected; other scopes are not replayed
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "b"})
+	hub.Broadcast("proj1", "staging", Event{FlagKey: "other"})
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "c"})
+
+	ch, missed, err := hub.SubscribeSince("proj1", "prod", first.ID)
+	if err != nil {
+		t.Fatalf("SubscribeSince: %v", err)
+	}
+	defer hub.Unsubscribe("proj1", "prod", ch)
+
+	if len(missed) != 2 || missed[0].FlagKey != "b" || missed[1].FlagKey != "c" {
+		t.Fatalf("expected replay of b and c, got %+v", missed)
+	}
+	if missed[0].ID <= first.ID || missed[1].ID <= missed[0].ID {
+		t.Errorf("expected increasing IDs after %d, got %d and %d", first.ID, missed[0].ID, missed[1].ID)
+	}
+
+	// Up to date: nothing to replay, live events still arrive
+	staging, upToDate, err := hub.SubscribeSince("proj1", "staging", hub.lastID)
+	if err != nil {
+		t.Fatalf("SubscribeSince: %v", err)
+	}
+	hub.Unsubscribe("proj1", "staging", staging)
+	if len(upToDate) != 0 {
+		t.Errorf("expected no replay for an up-to-date client, got %+v", upToDate)
+	}
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "d"})
+	select {
+	case e := <-ch:
+		if e.FlagKey != "d" {
+			t.Errorf("expected live event d, got %q", e.FlagKey)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for live event")
+	}
+}
+
+func TestSubscribeSinceAfterEvictionRequestsRefetch(t *testing.T) {
+	hub := NewHub()
+
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "first"})
+	firstID := hub.lastID
+	for i := 0; i < historySize; i++ {
+		hub.Broadcast("proj1", "prod", Event{FlagKey: "flood"})
+	}
+	if n := len(hub.history["proj1:prod"]); n != historySize {
+		t.Fatalf("expected history capped at %d, got %d", historySize, n)
+	}
+
+	// The event right after firstID is still buffered
+	ch, missed, err := hub.SubscribeSince("proj1", "prod", firstID)
+	if err != nil {
+		t.Fatalf("SubscribeSince: %v", err)
+	}
+	hub.Unsubscribe("proj1", "prod", ch)
+	if len(missed) != historySize || missed[0].Type == EventRefetch {
+		t.Fatalf("expected full replay of %d events, got %d", historySize, len(missed))
+	}
+
+	// One more event evicts the one after firstID, leaving a gap
+	hub.Broadcast("proj1", "prod", Event{FlagKey: "overflow"})
+	for _, lastID := range []uint64{firstID, hub.baseID - 1, hub.lastID + 1} {
+		ch, missed, err := hub.SubscribeSince("proj1", "prod", lastID)
+		if err != nil {
+			t.Fatalf("SubscribeSince(%d): %v", lastID, err)
+		}
+		hub.Unsubscribe("proj1", "prod", ch)
+		if len(missed) != 1 || missed[0].Type != EventRefetch || missed[0].ID != hub.lastID {
+			t.Errorf("SubscribeSince(%d): expected a single refetch event at %d, got %+v", lastID, hub.lastID, missed)
+		}
+	}
+}
+
+func TestDrainNotifiesThenClosesSubscribers(t *testing.T) {
+	hub := NewHub()
+
+	polite := mustSubscribe(t, hub, "proj1", "prod")
+	stuck := mustSubscribe(t, hub, "proj1", "staging")
+
+	// A subscriber that leaves when told to, as the stream handler does.
+	go func() {
+		if e := <-polite; e.Type == EventClosing {
+			hub.Unsubscribe("proj1", "prod", polite)
+		}
+	}()
+
+	start := time.Now()
+	hub.Drain(200 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected Drain to wait out the grace period for the stuck subscriber, returned after %v", elapsed)
+	}
+
+	if e, ok := <-stuck; !ok || e.Type != EventClosing {
+		t.Errorf("expected the closing event first, got %+v (ok=%v)", e, ok)
+	}
+	if _, ok := <-stuck; ok {
+		t.Error("expected the stuck subscriber's channel to be closed after the grace period")
+	}
+	for _, env := range []string{"prod", "staging"} {
+		if n := hub.SubscriberCount("proj1", env); n != 0 {
+			t.Errorf("expected no %s subscribers after Drain, got %d", env, n)
+		}
+	}
+	if _, err := hub.Subscribe("proj1", "prod"); !errors.Is(err, ErrDraining) {
+		t.Errorf("expected ErrDraining for a new subscriber, got %v", err)
+	}
+}
+
+func TestDrainReturnsEarlyWhenSubscribersLeave(t *testing.T) {
+	hub := NewHub()
+	ch := mustSubscribe(t, hub, "proj1", "prod")
+	go func() {
+		<-ch
+		hub.Unsubscribe("proj1", "prod", ch)
+	}()
+
+	start := time.Now()
+	hub.Drain(5 * time.Second)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Drain to return once the subscriber left, took %v", elapsed)
+	}
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordSpans installs a global tracer provider that records finished spans,
+// restoring the previous provider when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) string {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestStart_WithoutProviderIsNoop(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	defer otel.SetTracerProvider(prev)
+
+	_, span := Start(context.Background(), "work")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("expected no recording span without a tracer provider")
+	}
+}
+
+func TestMiddleware_RecordsNestedSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	var requestSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		requestSpan = trace.SpanContextFromContext(r.Context())
+		_, span := Start(r.Context(), "load")
+		span.RecordError(errors.New("not found"))
+		span.SetStatus(codes.Error, "not found")
+		span.End()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	child, root := spans[0], spans[1]
+	if child.Parent().SpanID() != root.SpanContext().SpanID() || child.SpanContext().TraceID() != root.SpanContext().TraceID() {
+		t.Error("expected child span to belong to the request span")
+	}
+	if child.Status().Code != codes.Error || len(child.Events()) != 1 {
+		t.Errorf("expected child error to be recorded, got %+v", child.Status())
+	}
+	if root.Name() != "GET /items/{id}" || root.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected root span %q of kind %v", root.Name(), root.SpanKind())
+	}
+	if got := root.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected incoming trace to be continued, got %s", got)
+	}
+	if attr(root, "http.response.status_code") != "500" || attr(root, "http.route") != "/items/{id}" {
+		t.Errorf("unexpected root attributes %v", root.Attributes())
+	}
+	if requestSpan.SpanID() != root.SpanContext().SpanID() {
+		t.Error("expected handlers to see the request span in their context")
+	}
+}
+
+func TestSetup_ExportsToCollector(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer srv.Close()
+
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+	provider, err := Setup(context.Background(), srv.URL+"/", "togglerino-test")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	_, span := Start(context.Background(), "request")
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	select {
+	case path := <-paths:
+		if path != "/v1/traces" {
+			t.Errorf("expected spans posted to /v1/traces, got %s", path)
+		}
+	default:
+		t.Fatal("expected spans to be exported on shutdown")
+	}
+}
@@ -0,0 +1,9 @@
+CREATE TABLE flag_comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    flag_id UUID NOT NULL REFERENCES flags(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_flag_comments_flag_id ON flag_comments(flag_id, created_at);
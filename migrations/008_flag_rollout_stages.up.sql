@@ -0,0 +1,3 @@
+-- Ordered environment keys a flag is rolled out through (e.g. staging, production).
+-- When set, a later stage may not roll out to a higher percentage than the stage before it.
+ALTER TABLE flags ADD COLUMN rollout_stages TEXT[] NOT NULL DEFAULT '{}';
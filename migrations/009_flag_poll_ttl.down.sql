@@ -0,0 +1 @@
+ALTER TABLE flags DROP COLUMN poll_ttl_seconds;
@@ -0,0 +1,2 @@
+-- Optional per-flag hint telling polling SDKs how often to re-fetch.
+ALTER TABLE flags ADD COLUMN poll_ttl_seconds INTEGER CHECK (poll_ttl_seconds > 0);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS environment_quotas;
+DROP TABLE IF EXISTS evaluation_usage;
@@ -0,0 +1,15 @@
+-- Monthly SDK evaluation counters per environment. Each calendar month (UTC)
+-- gets its own row, so usage resets at month boundaries.
+CREATE TABLE evaluation_usage (
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    period DATE NOT NULL,
+    evaluations BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (environment_id, period)
+);
+
+-- Optional monthly evaluation quota per environment. No row means unlimited.
+CREATE TABLE environment_quotas (
+    environment_id UUID PRIMARY KEY REFERENCES environments(id) ON DELETE CASCADE,
+    monthly_evaluations BIGINT NOT NULL CHECK (monthly_evaluations >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- When true, evaluations without a user ID serve the default variant with
+-- reason "missing_identifier" instead of bucketing all anonymous traffic together.
+ALTER TABLE flags ADD COLUMN require_identifier BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,14 @@
+-- Reusable, parameterized targeting rule conditions. Placeholders such as
+-- {{domain}} in condition attributes or values are substituted on instantiation.
+CREATE TABLE rule_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    parameters TEXT[] NOT NULL DEFAULT '{}',
+    conditions JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(project_id, key)
+);
@@ -0,0 +1,12 @@
+-- Sticky per-user variant overrides, e.g. to force a flag on for a QA user.
+-- user_id is the SDK evaluation context user ID, not a Togglerino account.
+CREATE TABLE flag_user_overrides (
+    flag_id UUID NOT NULL REFERENCES flags(id) ON DELETE CASCADE,
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    variant TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (flag_id, environment_id, user_id)
+);
+
+CREATE INDEX idx_flag_user_overrides_user_id ON flag_user_overrides(user_id);
@@ -0,0 +1,7 @@
+-- Single-row table of instance-wide defaults editable by admins at runtime.
+-- The boolean key with a CHECK constraint guarantees at most one row.
+CREATE TABLE global_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    settings JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
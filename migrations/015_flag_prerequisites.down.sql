@@ -0,0 +1 @@
+ALTER TABLE flag_environment_configs DROP COLUMN IF EXISTS prerequisites;
@@ -0,0 +1,3 @@
+-- Prerequisites gate a flag's config on other flags in the same environment
+-- serving a required variant: [{"flag_key": "...", "variant": "..."}].
+ALTER TABLE flag_environment_configs ADD COLUMN prerequisites JSONB NOT NULL DEFAULT '[]';
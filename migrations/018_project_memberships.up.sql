@@ -0,0 +1,17 @@
+-- Project-scoped roles. Members without a row are viewers of the project;
+-- global admins bypass memberships entirely.
+CREATE TABLE project_memberships (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'admin')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX idx_project_memberships_user_id ON project_memberships(user_id);
+
+-- Existing members could edit every project; keep that until admins narrow it.
+INSERT INTO project_memberships (project_id, user_id, role)
+SELECT p.id, u.id, 'editor'
+FROM projects p CROSS JOIN users u
+WHERE u.role = 'member';
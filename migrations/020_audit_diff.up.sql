@@ -0,0 +1,2 @@
+-- Field-level diff of flag config updates, alongside the full snapshots.
+ALTER TABLE audit_log ADD COLUMN diff JSONB;
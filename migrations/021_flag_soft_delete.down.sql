@@ -0,0 +1,5 @@
+DELETE FROM flags WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_flags_deleted_at;
+DROP INDEX IF EXISTS idx_flags_project_key;
+ALTER TABLE flags ADD CONSTRAINT flags_project_id_key_key UNIQUE (project_id, key);
+ALTER TABLE flags DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted flags are kept until the retention period passes so they can be
+-- restored. Keys only need to be unique among flags that are not deleted.
+ALTER TABLE flags ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE flags DROP CONSTRAINT flags_project_id_key_key;
+CREATE UNIQUE INDEX idx_flags_project_key ON flags(project_id, key) WHERE deleted_at IS NULL;
+CREATE INDEX idx_flags_deleted_at ON flags(deleted_at) WHERE deleted_at IS NOT NULL;
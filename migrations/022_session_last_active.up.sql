@@ -0,0 +1,2 @@
+-- Sessions idle for longer than the configured timeout are rejected.
+ALTER TABLE sessions ADD COLUMN last_active_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
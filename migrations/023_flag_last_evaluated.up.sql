@@ -0,0 +1,2 @@
+-- Flags still served to SDKs are not promoted to stale by age alone.
+ALTER TABLE flags ADD COLUMN last_evaluated_at TIMESTAMPTZ;
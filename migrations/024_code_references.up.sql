@@ -0,0 +1,11 @@
+-- Locations in source code where a CI scanner found a flag key.
+CREATE TABLE code_references (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    flag_id UUID NOT NULL REFERENCES flags(id) ON DELETE CASCADE,
+    repo TEXT NOT NULL,
+    file TEXT NOT NULL,
+    line INTEGER NOT NULL,
+    sha TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (flag_id, repo, file, line)
+);
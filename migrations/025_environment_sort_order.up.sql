@@ -0,0 +1,10 @@
+-- Environments are listed in a user-defined order; existing ones keep their
+-- creation order.
+ALTER TABLE environments ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+
+UPDATE environments e SET sort_order = o.n
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY created_at, id) - 1 AS n
+    FROM environments
+) o
+WHERE e.id = o.id;
@@ -0,0 +1 @@
+ALTER TABLE context_attributes DROP COLUMN IF EXISTS sample_values;
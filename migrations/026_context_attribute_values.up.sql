@@ -0,0 +1,3 @@
+-- A few recent distinct values per attribute, newest first, so the targeting
+-- rule builder can suggest values. The store caps the array's length.
+ALTER TABLE context_attributes ADD COLUMN sample_values TEXT[] NOT NULL DEFAULT '{}';
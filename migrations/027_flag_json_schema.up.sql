@@ -0,0 +1,3 @@
+-- JSON flags may carry a JSON Schema that their default value and variant
+-- values must match. NULL means no schema.
+ALTER TABLE flags ADD COLUMN json_schema JSONB;
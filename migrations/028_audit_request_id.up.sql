@@ -0,0 +1,3 @@
+-- The ID of the HTTP request that produced an audit entry, for correlating
+-- it with request logs. NULL for entries recorded outside a request.
+ALTER TABLE audit_log ADD COLUMN request_id TEXT;
@@ -0,0 +1,4 @@
+-- A default rollout splits the traffic that matches no targeting rule across
+-- weighted variants: [{"variant": "...", "weight": 50}]. Empty serves the
+-- default variant.
+ALTER TABLE flag_environment_configs ADD COLUMN default_rollout JSONB NOT NULL DEFAULT '[]';
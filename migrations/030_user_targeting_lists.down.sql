@@ -0,0 +1,4 @@
+ALTER TABLE flag_environment_configs
+    DROP COLUMN IF EXISTS included_users,
+    DROP COLUMN IF EXISTS excluded_users,
+    DROP COLUMN IF EXISTS included_variant;
@@ -0,0 +1,7 @@
+-- Users listed in included_users are served included_variant (or the
+-- default variant when it is empty) ahead of the targeting rules; users in
+-- excluded_users get the flag's default value.
+ALTER TABLE flag_environment_configs
+    ADD COLUMN included_users TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN excluded_users TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN included_variant TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_audit_log_project_created_at;
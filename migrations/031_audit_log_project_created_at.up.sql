@@ -0,0 +1,3 @@
+-- Serves per-project audit listings and retention pruning, which both filter
+-- on project_id and range over created_at.
+CREATE INDEX idx_audit_log_project_created_at ON audit_log(project_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- The user who last changed a flag's metadata or one of its environment
+-- configs. NULL for flags changed only by the system or whose editor was
+-- deleted.
+ALTER TABLE flags ADD COLUMN last_modified_by UUID REFERENCES users(id) ON DELETE SET NULL;
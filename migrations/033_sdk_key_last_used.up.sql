@@ -0,0 +1,3 @@
+-- When an SDK key last authenticated a request, recorded to within a minute,
+-- so dormant keys can be revoked safely. NULL if it has never been used.
+ALTER TABLE sdk_keys ADD COLUMN last_used_at TIMESTAMPTZ;
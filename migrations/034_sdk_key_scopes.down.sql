@@ -0,0 +1 @@
+ALTER TABLE sdk_keys DROP COLUMN IF EXISTS scopes;
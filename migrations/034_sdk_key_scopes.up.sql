@@ -0,0 +1,3 @@
+-- The SDK routes a key may call: evaluate, stream and/or server (the full
+-- rule set for local evaluation). Existing keys keep every scope.
+ALTER TABLE sdk_keys ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{evaluate,stream,server}';
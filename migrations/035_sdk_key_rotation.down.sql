@@ -0,0 +1,2 @@
+ALTER TABLE sdk_keys DROP COLUMN IF EXISTS rotated_from_id;
+ALTER TABLE sdk_keys DROP COLUMN IF EXISTS expires_at;
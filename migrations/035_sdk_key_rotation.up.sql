@@ -0,0 +1,4 @@
+-- Rotating a key gives the old one an expiry so running clients keep
+-- working during the grace period; the replacement links back to it.
+ALTER TABLE sdk_keys ADD COLUMN expires_at TIMESTAMPTZ;
+ALTER TABLE sdk_keys ADD COLUMN rotated_from_id UUID REFERENCES sdk_keys(id) ON DELETE SET NULL;
@@ -0,0 +1,9 @@
+-- How often each variant of a flag was served, per environment and hour.
+CREATE TABLE variant_evaluations (
+    flag_id UUID NOT NULL REFERENCES flags(id) ON DELETE CASCADE,
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    variant TEXT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    evaluations BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (flag_id, environment_id, variant, hour)
+);
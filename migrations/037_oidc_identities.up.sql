@@ -0,0 +1,11 @@
+-- The OpenID Connect identity each SSO user signs in with. Logins are matched
+-- on (issuer, subject), which the provider never reassigns, not on email.
+CREATE TABLE oidc_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX idx_oidc_identities_user ON oidc_identities (user_id);
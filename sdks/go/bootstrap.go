@@ -0,0 +1,84 @@
+package togglerino
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// loadBootstrap seeds the flag cache from the configured bootstrap file and
+// map, and marks the client ready if any flags were seeded. It reports
+// whether bootstrap data was found.
+func (c *Client) loadBootstrap() (bool, error) {
+	flags := make(map[string]*EvaluationResult)
+
+	if path := c.config.bootstrapFile; path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			c.config.logger.Warn("bootstrap file not found", "path", path)
+		case err != nil:
+			return false, fmt.Errorf("togglerino: failed to read bootstrap file: %w", err)
+		default:
+			var resp evaluateResponse
+			if err := json.Unmarshal(data, &resp); err != nil {
+				return false, fmt.Errorf("togglerino: failed to decode bootstrap file: %w", err)
+			}
+			for k, v := range resp.Flags {
+				flags[k] = v
+			}
+		}
+	}
+	for k, v := range c.config.bootstrap {
+		flags[k] = v
+	}
+
+	if len(flags) == 0 {
+		return false, nil
+	}
+	c.flagsMu.Lock()
+	c.flags = flags
+	c.flagsMu.Unlock()
+	c.markReady()
+	return true, nil
+}
+
+// persistFlags writes the flag cache to the configured persist file. The
+// file is replaced atomically so a crash never leaves it half-written.
+// Failures are logged, since the fetched flags are still valid.
+func (c *Client) persistFlags() {
+	path := c.config.persistFile
+	if path == "" {
+		return
+	}
+
+	c.flagsMu.RLock()
+	data, err := json.Marshal(evaluateResponse{Flags: c.flags})
+	c.flagsMu.RUnlock()
+	if err != nil {
+		c.config.logger.Warn("failed to encode flags for persistence", "error", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		c.config.logger.Warn("failed to persist flags", "path", path, "error", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		c.config.logger.Warn("failed to persist flags", "path", path, "error", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		c.config.logger.Warn("failed to persist flags", "path", path, "error", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		c.config.logger.Warn("failed to persist flags", "path", path, "error", err)
+	}
+}
@@ -0,0 +1,162 @@
+package togglerino
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unreachableURL returns the URL of a server that has already been closed.
+func unreachableURL() string {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+	return ts.URL
+}
+
+func TestBootstrap_UnreachableServerServesBootstrap(t *testing.T) {
+	client, err := New(context.Background(), Config{
+		ServerURL: unreachableURL(),
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+		Bootstrap: map[string]*EvaluationResult{
+			"dark-mode": {Value: true, Variant: "on", Reason: "bootstrap"},
+			"theme":     {Value: "dark", Variant: "dark", Reason: "bootstrap"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if got := client.BoolValue("dark-mode", false); !got {
+		t.Errorf("BoolValue = %v, want true", got)
+	}
+	if got := client.StringValue("theme", "light"); got != "dark" {
+		t.Errorf("StringValue = %q, want %q", got, "dark")
+	}
+	if err := client.WaitForReady(context.Background()); err != nil {
+		t.Errorf("WaitForReady() error: %v", err)
+	}
+}
+
+func TestBootstrap_UnreachableServerWithoutBootstrapFails(t *testing.T) {
+	_, err := New(context.Background(), Config{
+		ServerURL: unreachableURL(),
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+	})
+	if err == nil {
+		t.Fatal("expected New() to fail without bootstrap data")
+	}
+}
+
+func TestBootstrap_FileSeedsFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	data, _ := json.Marshal(evaluateResponse{Flags: map[string]*EvaluationResult{
+		"dark-mode":   {Value: true, Variant: "on", Reason: "default"},
+		"max-uploads": {Value: float64(5), Variant: "five", Reason: "default"},
+	}})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := New(context.Background(), Config{
+		ServerURL:     unreachableURL(),
+		SDKKey:        "sdk_test",
+		Streaming:     boolPtr(false),
+		BootstrapFile: path,
+		// The map overrides entries from the file.
+		Bootstrap: map[string]*EvaluationResult{"max-uploads": {Value: float64(50), Variant: "fifty"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if got := client.BoolValue("dark-mode", false); !got {
+		t.Errorf("BoolValue = %v, want true", got)
+	}
+	if got := client.NumberValue("max-uploads", 0); got != 50 {
+		t.Errorf("NumberValue = %v, want 50", got)
+	}
+}
+
+func TestBootstrap_MissingFileIsIgnored(t *testing.T) {
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode": {Value: true, Variant: "on", Reason: "default"},
+	})
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL:     ts.URL,
+		SDKKey:        "sdk_test",
+		Streaming:     boolPtr(false),
+		BootstrapFile: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if got := client.BoolValue("dark-mode", false); !got {
+		t.Errorf("BoolValue = %v, want true", got)
+	}
+}
+
+func TestBootstrap_InvalidFileFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(context.Background(), Config{
+		ServerURL:     unreachableURL(),
+		SDKKey:        "sdk_test",
+		Streaming:     boolPtr(false),
+		BootstrapFile: path,
+	})
+	if err == nil {
+		t.Fatal("expected New() to fail on an invalid bootstrap file")
+	}
+}
+
+func TestBootstrap_PersistedFlagsSurviveOutage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode": {Value: true, Variant: "on", Reason: "rule_match"},
+	})
+
+	client, err := New(context.Background(), Config{
+		ServerURL:     ts.URL,
+		SDKKey:        "sdk_test",
+		Streaming:     boolPtr(false),
+		BootstrapFile: path,
+		PersistFile:   path,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	client.Close()
+	ts.Close()
+
+	// Restart against the now unreachable server.
+	restarted, err := New(context.Background(), Config{
+		ServerURL:     ts.URL,
+		SDKKey:        "sdk_test",
+		Streaming:     boolPtr(false),
+		BootstrapFile: path,
+		PersistFile:   path,
+	})
+	if err != nil {
+		t.Fatalf("New() after outage error: %v", err)
+	}
+	defer restarted.Close()
+
+	detail, ok := restarted.Detail("dark-mode")
+	if !ok || detail.Value != true || detail.Variant != "on" {
+		t.Errorf("Detail = %+v, %v; want persisted dark-mode on", detail, ok)
+	}
+}
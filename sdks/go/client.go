@@ -14,36 +14,56 @@ import (
 // It fetches flag evaluations from the server and keeps them in sync
 // via SSE streaming or polling.
 type Client struct {
-	config      resolvedConfig
-	events      *eventEmitter
-	flags       map[string]*EvaluationResult
-	flagsMu     sync.RWMutex
+	config  resolvedConfig
+	events  *eventEmitter
+	flags   map[string]*EvaluationResult
+	flagsMu sync.RWMutex
+	// rules is the rule set flags are evaluated from when local evaluation
+	// is enabled; nil otherwise. Guarded by flagsMu.
+	rules       *ruleSet
 	initialized bool
 	cancelFunc  context.CancelFunc
 	wg          sync.WaitGroup
 	closeOnce   sync.Once
+
+	// ready is closed once the client first holds valid flag data;
+	// done is closed by Close. lastErr records the most recent sync failure.
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+	errMu     sync.Mutex
+	lastErr   error
+
+	// lastEventID is the id of the last stream event received, sent as
+	// Last-Event-ID on reconnect. Only touched by the SSE goroutine.
+	lastEventID string
 }
 
 // New creates a new Client, fetches the initial flag state, and starts
 // background synchronization (SSE or polling). The provided ctx is used
 // only for the initial fetch; a separate background context governs the
-// sync goroutine's lifetime.
+// sync goroutine's lifetime. If bootstrap data is configured, a failed
+// initial fetch is logged and the client starts from that data.
 func New(ctx context.Context, cfg Config) (*Client, error) {
 	rc := resolveConfig(cfg)
 	bgCtx, cancel := context.WithCancel(context.Background())
 
-	c := &Client{
-		config:     rc,
-		events:     newEventEmitter(),
-		flags:      make(map[string]*EvaluationResult),
-		cancelFunc: cancel,
-	}
+	c := newClient(rc, cancel)
 
-	if err := c.fetchFlags(ctx); err != nil {
+	bootstrapped, err := c.loadBootstrap()
+	if err != nil {
 		cancel()
 		return nil, err
 	}
 
+	if err := c.fetchFlags(ctx); err != nil {
+		if !bootstrapped {
+			cancel()
+			return nil, err
+		}
+		rc.logger.Warn("initial flag fetch failed, serving bootstrap flags", "error", err)
+	}
+
 	c.initialized = true
 
 	if rc.streaming {
@@ -64,10 +84,65 @@ func New(ctx context.Context, cfg Config) (*Client, error) {
 	return c, nil
 }
 
+// newClient creates a Client with empty flag state. It performs no I/O.
+func newClient(rc resolvedConfig, cancel context.CancelFunc) *Client {
+	return &Client{
+		config:     rc,
+		events:     newEventEmitter(),
+		flags:      make(map[string]*EvaluationResult),
+		cancelFunc: cancel,
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// WaitForReady blocks until the client holds valid flag data or ctx is done.
+// It returns nil once ready, ErrClosed if the client was closed first, or an
+// error wrapping ctx.Err() and the most recent sync failure (if any).
+func (c *Client) WaitForReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return nil
+	default:
+	}
+
+	select {
+	case <-c.ready:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		if err := c.lastError(); err != nil {
+			return fmt.Errorf("togglerino: client not ready: %w: %w", ctx.Err(), err)
+		}
+		return fmt.Errorf("togglerino: client not ready: %w", ctx.Err())
+	}
+}
+
+// markReady records that the client holds valid flag data. Only the first
+// call has an effect.
+func (c *Client) markReady() {
+	c.readyOnce.Do(func() { close(c.ready) })
+}
+
+// setLastError records the most recent sync failure for WaitForReady.
+func (c *Client) setLastError(err error) {
+	c.errMu.Lock()
+	c.lastErr = err
+	c.errMu.Unlock()
+}
+
+func (c *Client) lastError() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.lastErr
+}
+
 // Close shuts down background goroutines, waits for them to finish,
 // and clears all event listeners. It is safe to call multiple times.
 func (c *Client) Close() {
 	c.closeOnce.Do(func() {
+		close(c.done)
 		c.cancelFunc()
 		c.wg.Wait()
 		c.events.clear()
@@ -76,8 +151,17 @@ func (c *Client) Close() {
 
 // fetchFlags performs a POST /api/v1/evaluate request to refresh the
 // local flag cache. After initialization, it emits change events for
-// any flags whose values differ from the previous fetch.
+// any flags whose values differ from the previous fetch. With local
+// evaluation it fetches the rule set instead. The request is bounded by the
+// configured request timeout.
 func (c *Client) fetchFlags(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.requestTimeout)
+	defer cancel()
+
+	if c.config.localEvaluation {
+		return c.fetchRules(ctx)
+	}
+
 	url := c.config.serverURL + "/api/v1/evaluate"
 
 	c.flagsMu.RLock()
@@ -92,6 +176,7 @@ func (c *Client) fetchFlags(ctx context.Context) error {
 	reqBody := evaluateRequest{
 		Context: &evaluateContext{
 			UserID:     evalCtx.UserID,
+			Locale:     evalCtx.Locale,
 			Attributes: attrs,
 		},
 	}
@@ -107,9 +192,11 @@ func (c *Client) fetchFlags(ctx context.Context) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.sdkKey)
+	c.setTraceparent(req)
 
 	resp, err := c.config.httpClient.Do(req)
 	if err != nil {
+		c.setLastError(err)
 		c.events.emit(eventError, err)
 		return err
 	}
@@ -117,15 +204,35 @@ func (c *Client) fetchFlags(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusOK {
 		err := fmt.Errorf("togglerino: flag evaluation failed with status %d", resp.StatusCode)
+		c.setLastError(err)
 		c.events.emit(eventError, err)
 		return err
 	}
 
 	var evalResp evaluateResponse
 	if err := json.NewDecoder(resp.Body).Decode(&evalResp); err != nil {
-		return fmt.Errorf("togglerino: failed to decode response: %w", err)
+		err = fmt.Errorf("togglerino: failed to decode response: %w", err)
+		c.setLastError(err)
+		return err
 	}
 
+	c.replaceFlags(evalResp.Flags)
+	return nil
+}
+
+// setTraceparent adds the configured traceparent header to req, if any.
+func (c *Client) setTraceparent(req *http.Request) {
+	if c.config.traceparent == nil {
+		return
+	}
+	if tp := c.config.traceparent(req.Context()); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+}
+
+// replaceFlags swaps in a new set of flag results, marks the client ready,
+// and emits change and deleted events relative to the previous set.
+func (c *Client) replaceFlags(flags map[string]*EvaluationResult) {
 	// Collect events while holding the lock, emit after releasing to avoid
 	// deadlocks if a callback reads flag values.
 	var changeEvents []FlagChangeEvent
@@ -133,8 +240,8 @@ func (c *Client) fetchFlags(ctx context.Context) error {
 
 	c.flagsMu.Lock()
 	oldFlags := c.flags
-	c.flags = make(map[string]*EvaluationResult, len(evalResp.Flags))
-	for k, v := range evalResp.Flags {
+	c.flags = make(map[string]*EvaluationResult, len(flags))
+	for k, v := range flags {
 		c.flags[k] = v
 		if c.initialized {
 			old, existed := oldFlags[k]
@@ -156,14 +263,16 @@ func (c *Client) fetchFlags(ctx context.Context) error {
 	}
 	c.flagsMu.Unlock()
 
+	c.setLastError(nil)
+	c.markReady()
+	c.persistFlags()
+
 	for _, evt := range changeEvents {
 		c.events.emit(eventChange, evt)
 	}
 	for _, evt := range deletedEvents {
 		c.events.emit(eventDeleted, evt)
 	}
-
-	return nil
 }
 
 // jsonEqual compares two values by their JSON representations.
@@ -3,11 +3,14 @@ package togglerino
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type testServer struct {
@@ -114,6 +117,36 @@ func TestNew_StripsTrailingSlashes(t *testing.T) {
 	}
 }
 
+func TestNew_SendsTraceparent(t *testing.T) {
+	headers := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("traceparent")
+		json.NewEncoder(w).Encode(evaluateResponse{Flags: map[string]*EvaluationResult{}})
+	}))
+	defer ts.Close()
+
+	type traceKey struct{}
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := context.WithValue(context.Background(), traceKey{}, tp)
+	client, err := New(ctx, Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+		Traceparent: func(ctx context.Context) string {
+			v, _ := ctx.Value(traceKey{}).(string)
+			return v
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if got := <-headers; got != tp {
+		t.Errorf("expected traceparent %q, got %q", tp, got)
+	}
+}
+
 func TestNew_ReturnsErrorOnFetchFailure(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -131,6 +164,41 @@ func TestNew_ReturnsErrorOnFetchFailure(t *testing.T) {
 	}
 }
 
+func TestVariant_TypedValueAndVariant(t *testing.T) {
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode":   {Value: true, Variant: "on", Reason: "rule_match"},
+		"welcome-msg": {Value: "Hello!", Variant: "greeting", Reason: "default"},
+		"max-uploads": {Value: float64(10), Variant: "ten", Reason: "default"},
+	})
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if v, variant := Variant(client, "dark-mode", false); v != true || variant != "on" {
+		t.Errorf("Variant[bool] = %v, %q, want true, on", v, variant)
+	}
+	if v, variant := Variant(client, "welcome-msg", ""); v != "Hello!" || variant != "greeting" {
+		t.Errorf("Variant[string] = %q, %q, want Hello!, greeting", v, variant)
+	}
+	if v, variant := Variant(client, "max-uploads", float64(0)); v != 10 || variant != "ten" {
+		t.Errorf("Variant[float64] = %v, %q, want 10, ten", v, variant)
+	}
+	if v, variant := Variant(client, "max-uploads", "none"); v != "none" || variant != "" {
+		t.Errorf("Variant with mismatched type = %q, %q, want the default and no variant", v, variant)
+	}
+	if v, variant := Variant(client, "unknown", 5); v != 5 || variant != "" {
+		t.Errorf("Variant for a missing flag = %v, %q, want the default and no variant", v, variant)
+	}
+}
+
 func TestFlagGetters_DefaultValues(t *testing.T) {
 	ts := newTestServer(map[string]*EvaluationResult{})
 	defer ts.Close()
@@ -219,6 +287,69 @@ func TestDetail(t *testing.T) {
 	}
 }
 
+func TestVariantName(t *testing.T) {
+	ts := newTestServer(map[string]*EvaluationResult{
+		"checkout": {Value: "one-page", Variant: "one-page", Reason: "rule_match"},
+	})
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if got := client.VariantName("checkout"); got != "one-page" {
+		t.Errorf("VariantName = %q, want one-page", got)
+	}
+	if got := client.VariantName("nonexistent"); got != "" {
+		t.Errorf("VariantName for nonexistent flag = %q, want empty", got)
+	}
+}
+
+func TestAllFlags_ReturnsCopy(t *testing.T) {
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode": {Value: true, Variant: "on", Reason: "rule_match"},
+		"theme":     {Value: "dark", Variant: "dark", Reason: "default"},
+	})
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	all := client.AllFlags()
+	if len(all) != 2 {
+		t.Fatalf("AllFlags returned %d flags, want 2", len(all))
+	}
+	if all["dark-mode"].Variant != "on" || all["theme"].Value != "dark" {
+		t.Errorf("AllFlags = %+v", all)
+	}
+
+	all["dark-mode"] = EvaluationResult{Value: false, Variant: "off"}
+	delete(all, "theme")
+
+	if !client.BoolValue("dark-mode", false) {
+		t.Error("mutating the snapshot changed dark-mode")
+	}
+	if client.StringValue("theme", "light") != "dark" {
+		t.Error("deleting from the snapshot removed theme")
+	}
+	if len(client.AllFlags()) != 2 {
+		t.Error("mutating the snapshot changed the next snapshot")
+	}
+}
+
 func TestJSONValue(t *testing.T) {
 	ts := newTestServer(map[string]*EvaluationResult{
 		"config": {Value: map[string]any{"key": "val"}, Variant: "v1", Reason: "default"},
@@ -591,3 +722,79 @@ func TestClose_ClearsListeners(t *testing.T) {
 		t.Fatal("listener called after Close()")
 	}
 }
+
+func TestWaitForReady_ReadyAfterFetch(t *testing.T) {
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode": {Value: true, Variant: "on", Reason: "default"},
+	})
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.WaitForReady(ctx); err != nil {
+		t.Fatalf("WaitForReady() error: %v", err)
+	}
+}
+
+func TestWaitForReady_TimesOutWhenServerNeverResponds(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	fetchCtx, cancelFetch := context.WithCancel(context.Background())
+	client := newClient(resolveConfig(Config{ServerURL: ts.URL, SDKKey: "sdk_test"}), cancelFetch)
+	defer client.Close()
+	go client.fetchFlags(fetchCtx)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.WaitForReady(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForReady() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWaitForReady_ReportsLastError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client := newClient(resolveConfig(Config{ServerURL: ts.URL, SDKKey: "sdk_bad"}), func() {})
+	defer client.Close()
+	if err := client.fetchFlags(context.Background()); err == nil {
+		t.Fatal("expected fetch error, got nil")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.WaitForReady(ctx)
+	if err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Fatalf("WaitForReady() error = %v, want it to mention status 401", err)
+	}
+}
+
+func TestWaitForReady_ReturnsErrClosed(t *testing.T) {
+	client := newClient(resolveConfig(Config{ServerURL: "http://127.0.0.1:0"}), func() {})
+	client.Close()
+
+	if err := client.WaitForReady(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("WaitForReady() error = %v, want ErrClosed", err)
+	}
+}
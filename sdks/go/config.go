@@ -1,9 +1,11 @@
 package togglerino
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -12,6 +14,10 @@ const (
 	defaultPollingInterval = 30 * time.Second
 	defaultMaxRetryDelay   = 30 * time.Second
 	defaultBaseRetryDelay  = 1 * time.Second
+	defaultRequestTimeout  = 10 * time.Second
+	// minPollingInterval is the shortest interval a server TTL hint can
+	// make polling use.
+	minPollingInterval = 1 * time.Second
 )
 
 var (
@@ -26,6 +32,45 @@ type Config struct {
 	PollingInterval time.Duration
 	HTTPClient      *http.Client
 	Logger          *slog.Logger
+	// LocalEvaluation fetches the environment's flag rules instead of
+	// evaluated results and evaluates them in-process, so UpdateContext
+	// needs no network round trip. Stream and poll updates replace the
+	// local rule set.
+	LocalEvaluation bool
+	// Bootstrap seeds the flag cache before the initial fetch. If that
+	// fetch fails, New logs a warning and serves the bootstrapped values
+	// instead of returning an error.
+	Bootstrap map[string]*EvaluationResult
+	// BootstrapFile is read like Bootstrap, from a JSON file in the format
+	// of an evaluate response ({"flags": {...}}). Entries in Bootstrap take
+	// precedence. A missing file is ignored.
+	BootstrapFile string
+	// PersistFile, when set, receives the flag cache in BootstrapFile
+	// format after every successful refresh. Pointing BootstrapFile at the
+	// same path lets a restarted service start from its last good state.
+	PersistFile string
+	// Traceparent, when set, is called with the request context of every
+	// call to the server and its result sent as the W3C traceparent header,
+	// linking SDK requests into the caller's distributed trace. Returning ""
+	// omits the header.
+	Traceparent func(ctx context.Context) string
+	// BaseRetryDelay and MaxRetryDelay bound the delay before reconnecting
+	// a dropped stream (defaults 1s and 30s). Each attempt waits a random
+	// duration between BaseRetryDelay and an exponentially growing cap that
+	// never exceeds MaxRetryDelay, so clients that lost their connection at
+	// the same moment do not reconnect in lockstep.
+	BaseRetryDelay time.Duration
+	MaxRetryDelay  time.Duration
+	// RequestTimeout bounds each flag or rule fetch, including the initial
+	// one in New, polls and UpdateContext (default 10s). A timed-out fetch
+	// is reported to OnError like any other failure and polling carries on
+	// at the next interval.
+	RequestTimeout time.Duration
+	// Hooks are called on every BoolValue, StringValue, NumberValue,
+	// JSONValue, Variant and Detail call, for example to record evaluation
+	// metrics.
+	// See Hook for ordering and panic handling.
+	Hooks []Hook
 }
 
 type resolvedConfig struct {
@@ -36,6 +81,15 @@ type resolvedConfig struct {
 	pollingInterval time.Duration
 	httpClient      *http.Client
 	logger          *slog.Logger
+	localEvaluation bool
+	bootstrap       map[string]*EvaluationResult
+	bootstrapFile   string
+	persistFile     string
+	traceparent     func(ctx context.Context) string
+	hooks           []Hook
+	baseRetryDelay  time.Duration
+	maxRetryDelay   time.Duration
+	requestTimeout  time.Duration
 }
 
 func resolveConfig(c Config) resolvedConfig {
@@ -46,6 +100,15 @@ func resolveConfig(c Config) resolvedConfig {
 		pollingInterval: defaultPollingInterval,
 		httpClient:      http.DefaultClient,
 		logger:          slog.Default(),
+		localEvaluation: c.LocalEvaluation,
+		bootstrap:       c.Bootstrap,
+		bootstrapFile:   c.BootstrapFile,
+		persistFile:     c.PersistFile,
+		traceparent:     c.Traceparent,
+		hooks:           slices.Clone(c.Hooks),
+		baseRetryDelay:  defaultBaseRetryDelay,
+		maxRetryDelay:   defaultMaxRetryDelay,
+		requestTimeout:  defaultRequestTimeout,
 	}
 
 	if c.Context != nil {
@@ -63,6 +126,20 @@ func resolveConfig(c Config) resolvedConfig {
 		rc.pollingInterval = c.PollingInterval
 	}
 
+	if c.BaseRetryDelay > 0 {
+		rc.baseRetryDelay = c.BaseRetryDelay
+	}
+	if c.MaxRetryDelay > 0 {
+		rc.maxRetryDelay = c.MaxRetryDelay
+	}
+	if rc.maxRetryDelay < rc.baseRetryDelay {
+		rc.maxRetryDelay = rc.baseRetryDelay
+	}
+
+	if c.RequestTimeout > 0 {
+		rc.requestTimeout = c.RequestTimeout
+	}
+
 	if c.HTTPClient != nil {
 		rc.httpClient = c.HTTPClient
 	}
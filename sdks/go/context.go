@@ -19,13 +19,17 @@ func (c *Client) GetContext() EvaluationContext {
 }
 
 // UpdateContext merges the provided evaluation context into the client's
-// current context (non-empty UserID replaces, attributes are merged),
-// then re-fetches all flags and emits a context_change event.
+// current context (non-empty UserID and Locale replace, attributes are merged),
+// then re-fetches all flags and emits a context_change event. With local
+// evaluation the flags are re-evaluated in-process instead of re-fetched.
 func (c *Client) UpdateContext(ctx context.Context, evalCtx *EvaluationContext) error {
 	c.flagsMu.Lock()
 	if evalCtx.UserID != "" {
 		c.config.context.UserID = evalCtx.UserID
 	}
+	if evalCtx.Locale != "" {
+		c.config.context.Locale = evalCtx.Locale
+	}
 	if evalCtx.Attributes != nil {
 		if c.config.context.Attributes == nil {
 			c.config.context.Attributes = make(map[string]any)
@@ -36,7 +40,9 @@ func (c *Client) UpdateContext(ctx context.Context, evalCtx *EvaluationContext)
 	}
 	c.flagsMu.Unlock()
 
-	if err := c.fetchFlags(ctx); err != nil {
+	if c.config.localEvaluation {
+		c.evaluateLocally()
+	} else if err := c.fetchFlags(ctx); err != nil {
 		return err
 	}
 	c.events.emit(eventContextChange, c.GetContext())
@@ -0,0 +1,513 @@
+package togglerino
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The evaluator mirrors the server's evaluation engine
+// (internal/evaluation/engine.go) so local evaluation serves the same
+// variant the server would for the same context. Contexts without a
+// bucketing key are always bucketed by the empty key, the server's default
+// anonymous bucketing mode.
+
+// evaluate evaluates the named flag for ctx.
+func (rs *ruleSet) evaluate(key string, ctx *EvaluationContext) *EvaluationResult {
+	result := rs.evaluateFlag(rs.Flags[key], ctx, nil)
+	result.PollTTLSeconds = rs.Flags[key].Flag.PollTTLSeconds
+	return result
+}
+
+// evaluateFlag implements evaluate. visiting holds the flags on the current
+// prerequisite chain so circular prerequisites fail instead of recursing.
+func (rs *ruleSet) evaluateFlag(f *ruleFlag, ctx *EvaluationContext, visiting map[string]bool) *EvaluationResult {
+	flag, config := &f.Flag, &f.Config
+
+	if flag.LifecycleStatus == "archived" {
+		return &EvaluationResult{Value: rawToAny(flag.DefaultValue), Reason: "archived"}
+	}
+
+	if !config.Enabled {
+		return &EvaluationResult{Value: rawToAny(flag.DefaultValue), Reason: "disabled"}
+	}
+
+	if !rs.prerequisitesMet(flag.Key, config.Prerequisites, ctx, visiting) {
+		return &EvaluationResult{Value: rawToAny(flag.DefaultValue), Reason: "prerequisite_failed"}
+	}
+
+	if ctx.UserID != "" && slices.Contains(config.ExcludedUsers, ctx.UserID) {
+		return &EvaluationResult{Value: rawToAny(flag.DefaultValue), Reason: "excluded"}
+	}
+
+	if variant, ok := f.UserOverrides[ctx.UserID]; ok && ctx.UserID != "" && hasVariant(config.Variants, variant) {
+		return &EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+			Variant: variant,
+			Reason:  "user_override",
+		}
+	}
+
+	if ctx.UserID != "" && slices.Contains(config.IncludedUsers, ctx.UserID) {
+		variant := config.IncludedVariant
+		if variant == "" {
+			variant = config.DefaultVariant
+		}
+		return &EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+			Variant: variant,
+			Reason:  "included",
+		}
+	}
+
+	if flag.RequireIdentifier && !hasIdentifier(config, ctx) {
+		return &EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, config.DefaultVariant, ctx.Locale, flag.DefaultValue),
+			Variant: config.DefaultVariant,
+			Reason:  "missing_identifier",
+		}
+	}
+
+	for _, rule := range config.TargetingRules {
+		matched := rs.matchesAllConditions(rule.Conditions, ctx, false) && (rule.Group == nil || rs.matchesGroup(rule.Group, ctx))
+		if matched == rule.Negate {
+			continue
+		}
+		pct := 100
+		if rule.PercentageRollout != nil {
+			pct = *rule.PercentageRollout
+		}
+		weighted := len(rule.VariantWeights) > 0
+		bucketKey, fellBack := bucketingKey(rule.BucketBy, ctx)
+
+		variant := rule.Variant
+		reason := "rule_match"
+		if rule.PercentageRollout != nil || weighted {
+			if fellBack {
+				reason = "rule_match_bucket_fallback"
+			}
+			bucket := saltedHash(flag.Key, rule.Salt, bucketKey)
+			if bucket >= pct {
+				continue
+			}
+			if weighted {
+				if arm, ok := pickWeightedVariant(rule.VariantWeights, bucket*100/pct); ok {
+					variant = arm
+				}
+			}
+		}
+		return &EvaluationResult{
+			Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+			Variant: variant,
+			Reason:  reason,
+		}
+	}
+
+	if len(config.DefaultRollout) > 0 {
+		if variant, ok := pickWeightedVariant(config.DefaultRollout, consistentHash(flag.Key, ctx.UserID)); ok {
+			return &EvaluationResult{
+				Value:   lookupVariantValue(config.Variants, variant, ctx.Locale, flag.DefaultValue),
+				Variant: variant,
+				Reason:  "default_rollout",
+			}
+		}
+	}
+
+	return &EvaluationResult{
+		Value:   lookupVariantValue(config.Variants, config.DefaultVariant, ctx.Locale, flag.DefaultValue),
+		Variant: config.DefaultVariant,
+		Reason:  "default",
+	}
+}
+
+// prerequisitesMet reports whether every prerequisite flag serves its
+// required variant for ctx. A missing prerequisite or a cycle is not met.
+func (rs *ruleSet) prerequisitesMet(flagKey string, prereqs []rulePrerequisite, ctx *EvaluationContext, visiting map[string]bool) bool {
+	if len(prereqs) == 0 {
+		return true
+	}
+	if visiting == nil {
+		visiting = make(map[string]bool)
+	}
+	visiting[flagKey] = true
+	defer delete(visiting, flagKey)
+
+	for _, p := range prereqs {
+		f, ok := rs.Flags[p.FlagKey]
+		if !ok || visiting[p.FlagKey] {
+			return false
+		}
+		if rs.evaluateFlag(f, ctx, visiting).Variant != p.Variant {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAllConditions checks if all conditions match ctx. An in_segment
+// condition expands to the named segment's conditions; one naming an
+// unknown segment, or nested inside a segment, never matches.
+func (rs *ruleSet) matchesAllConditions(conditions []ruleCondition, ctx *EvaluationContext, inSegment bool) bool {
+	for _, cond := range conditions {
+		if cond.Operator == "in_segment" {
+			key, _ := cond.Value.(string)
+			segment, ok := rs.Segments[key]
+			if inSegment || !ok || !rs.matchesAllConditions(segment, ctx, true) {
+				return false
+			}
+			continue
+		}
+		if !evaluateCondition(contextAttribute(ctx.Attributes, cond.Attribute), cond.Operator, cond.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesGroup evaluates a condition group recursively. An "or" group
+// matches when any of its conditions or subgroups does; any other group when
+// all do.
+func (rs *ruleSet) matchesGroup(g *ruleGroup, ctx *EvaluationContext) bool {
+	if g.Operator == "or" {
+		for _, cond := range g.Conditions {
+			if rs.matchesAllConditions([]ruleCondition{cond}, ctx, false) {
+				return true
+			}
+		}
+		for i := range g.Groups {
+			if rs.matchesGroup(&g.Groups[i], ctx) {
+				return true
+			}
+		}
+		return false
+	}
+	if !rs.matchesAllConditions(g.Conditions, ctx, false) {
+		return false
+	}
+	for i := range g.Groups {
+		if !rs.matchesGroup(&g.Groups[i], ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// bucketingKey returns the value a rule's rollout hashes: the stringified
+// bucketBy attribute when set and non-empty, otherwise the user ID.
+// fellBack reports that bucketBy was set but the user ID had to be used.
+func bucketingKey(bucketBy string, ctx *EvaluationContext) (key string, fellBack bool) {
+	if bucketBy == "" {
+		return ctx.UserID, false
+	}
+	if v := toString(contextAttribute(ctx.Attributes, bucketBy)); v != "" {
+		return v, false
+	}
+	return ctx.UserID, true
+}
+
+// hasIdentifier reports whether ctx carries a stable identifier: a user ID, or
+// a value for the attribute a targeting rule buckets by.
+func hasIdentifier(config *ruleFlagConfig, ctx *EvaluationContext) bool {
+	if ctx.UserID != "" {
+		return true
+	}
+	for _, rule := range config.TargetingRules {
+		if rule.BucketBy != "" && toString(contextAttribute(ctx.Attributes, rule.BucketBy)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// contextAttribute returns the value of the named attribute: a top-level
+// attribute of that name, or else, for a dotted name such as "device.os", the
+// value found by descending into nested objects. Missing keys yield nil.
+func contextAttribute(attrs map[string]any, name string) any {
+	if v, ok := attrs[name]; ok || !strings.Contains(name, ".") {
+		return v
+	}
+	var v any = attrs
+	for _, key := range strings.Split(name, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+// consistentHash returns a deterministic bucket (0-99) for a flag key and
+// bucketing key: the first 8 bytes of SHA-256(flagKey+key), mod 100.
+func consistentHash(flagKey, key string) int {
+	h := sha256.Sum256([]byte(flagKey + key))
+	n := binary.BigEndian.Uint64(h[:8])
+	return int(n % 100)
+}
+
+// saltedHash mixes a rule's salt into the hash input; an empty salt hashes
+// exactly like consistentHash.
+func saltedHash(flagKey, salt, key string) int {
+	if salt == "" {
+		return consistentHash(flagKey, key)
+	}
+	return consistentHash(flagKey+":"+salt+":", key)
+}
+
+// pickWeightedVariant maps a bucket (0-99) onto the cumulative weights.
+func pickWeightedVariant(weights []variantWeight, bucket int) (string, bool) {
+	cumulative := 0
+	for _, w := range weights {
+		cumulative += w.Weight
+		if bucket < cumulative {
+			return w.Variant, true
+		}
+	}
+	return "", false
+}
+
+// hasVariant reports whether variants contains one with the given key.
+func hasVariant(variants []ruleVariant, key string) bool {
+	for _, v := range variants {
+		if v.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupVariantValue returns the value of a variant, localized for locale
+// when the variant has an entry for it, or the flag default if the variant
+// is not found.
+func lookupVariantValue(variants []ruleVariant, variantKey, locale string, defaultValue json.RawMessage) any {
+	for _, v := range variants {
+		if v.Key == variantKey {
+			if raw, ok := localizedValue(v.Localized, locale); ok {
+				return rawToAny(raw)
+			}
+			return rawToAny(v.Value)
+		}
+	}
+	return rawToAny(defaultValue)
+}
+
+// localizedValue looks up locale, falling back from a regional locale
+// ("fr-CA") to its base language ("fr").
+func localizedValue(localized map[string]json.RawMessage, locale string) (json.RawMessage, bool) {
+	if locale == "" || len(localized) == 0 {
+		return nil, false
+	}
+	if raw, ok := localized[locale]; ok {
+		return raw, true
+	}
+	if lang, _, found := strings.Cut(locale, "-"); found {
+		if raw, ok := localized[lang]; ok {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// rawToAny decodes a JSON value, returning the raw string if it is invalid.
+func rawToAny(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	return v
+}
+
+// evaluateCondition checks if an attribute value satisfies a condition.
+func evaluateCondition(attributeValue any, operator string, conditionValue any) bool {
+	switch operator {
+	case "equals":
+		return toString(attributeValue) == toString(conditionValue)
+	case "not_equals":
+		return toString(attributeValue) != toString(conditionValue)
+	case "contains":
+		return evalContains(attributeValue, conditionValue)
+	case "not_contains":
+		return !evalContains(attributeValue, conditionValue)
+	case "starts_with":
+		return strings.HasPrefix(toString(attributeValue), toString(conditionValue))
+	case "ends_with":
+		return strings.HasSuffix(toString(attributeValue), toString(conditionValue))
+	case "greater_than":
+		a, b, ok := toFloat64Pair(attributeValue, conditionValue)
+		return ok && a > b
+	case "less_than":
+		a, b, ok := toFloat64Pair(attributeValue, conditionValue)
+		return ok && a < b
+	case "gte":
+		a, b, ok := toFloat64Pair(attributeValue, conditionValue)
+		return ok && a >= b
+	case "lte":
+		a, b, ok := toFloat64Pair(attributeValue, conditionValue)
+		return ok && a <= b
+	case "in":
+		return evalIn(attributeValue, conditionValue)
+	case "not_in":
+		return !evalIn(attributeValue, conditionValue)
+	case "exists":
+		return attributeValue != nil
+	case "not_exists":
+		return attributeValue == nil
+	case "matches":
+		matched, err := regexp.MatchString(toString(conditionValue), toString(attributeValue))
+		return err == nil && matched
+	case "before":
+		a, b, ok := toTimePair(attributeValue, conditionValue)
+		return ok && a.Before(b)
+	case "after":
+		a, b, ok := toTimePair(attributeValue, conditionValue)
+		return ok && a.After(b)
+	case "within_last":
+		return evalWithinLast(attributeValue, conditionValue)
+	case "equals_ci":
+		return toLower(attributeValue) == toLower(conditionValue)
+	case "in_ci":
+		return evalInCI(attributeValue, conditionValue)
+	case "contains_ci":
+		return evalContainsCI(attributeValue, conditionValue)
+	case "starts_with_ci":
+		return strings.HasPrefix(toLower(attributeValue), toLower(conditionValue))
+	case "ends_with_ci":
+		return strings.HasSuffix(toLower(attributeValue), toLower(conditionValue))
+	default:
+		return false
+	}
+}
+
+func toString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toLower(v any) string {
+	return strings.ToLower(toString(v))
+}
+
+// toFloat64Pair converts both values to float64. Attributes and condition
+// values are JSON-decoded, so numbers are float64 and numeric strings parse.
+func toFloat64Pair(a, b any) (float64, float64, bool) {
+	fa, okA := toFloat64(a)
+	fb, okB := toFloat64(b)
+	return fa, fb, okA && okB
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// evalContains checks substring containment, or membership for lists.
+func evalContains(attributeValue, conditionValue any) bool {
+	if list, ok := attributeValue.([]any); ok {
+		target := toString(conditionValue)
+		for _, item := range list {
+			if toString(item) == target {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(toString(attributeValue), toString(conditionValue))
+}
+
+// evalIn checks if the attribute value is in the condition list.
+func evalIn(attributeValue, conditionValue any) bool {
+	list, ok := conditionValue.([]any)
+	if !ok {
+		return false
+	}
+	target := toString(attributeValue)
+	for _, item := range list {
+		if toString(item) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// evalContainsCI is evalContains comparing lowercased values.
+func evalContainsCI(attributeValue, conditionValue any) bool {
+	if list, ok := attributeValue.([]any); ok {
+		target := toLower(conditionValue)
+		for _, item := range list {
+			if toLower(item) == target {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(toLower(attributeValue), toLower(conditionValue))
+}
+
+// evalInCI is evalIn comparing lowercased values.
+func evalInCI(attributeValue, conditionValue any) bool {
+	list, ok := conditionValue.([]any)
+	if !ok {
+		return false
+	}
+	target := toLower(attributeValue)
+	for _, item := range list {
+		if toLower(item) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// toTime converts an RFC3339 timestamp or Unix epoch seconds to a time.
+func toTime(v any) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, true
+		}
+	}
+	secs, ok := toFloat64(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	whole := int64(secs)
+	return time.Unix(whole, int64((secs-float64(whole))*1e9)), true
+}
+
+func toTimePair(a, b any) (time.Time, time.Time, bool) {
+	ta, okA := toTime(a)
+	tb, okB := toTime(b)
+	return ta, tb, okA && okB
+}
+
+// evalWithinLast checks that the attribute time lies within the duration
+// before time.Now, inclusive, as on the server.
+func evalWithinLast(attributeValue, conditionValue any) bool {
+	t, ok := toTime(attributeValue)
+	if !ok {
+		return false
+	}
+	d, err := time.ParseDuration(toString(conditionValue))
+	if err != nil || d <= 0 {
+		return false
+	}
+	now := time.Now()
+	return !t.After(now) && !t.Before(now.Add(-d))
+}
@@ -0,0 +1,128 @@
+package togglerino
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// localEvaluationFixture is shared with the server's evaluation tests, which
+// check that the server engine produces the same expected results.
+type localEvaluationFixture struct {
+	Rules ruleSet `json:"rules"`
+	Cases []struct {
+		Name     string                      `json:"name"`
+		Context  EvaluationContext           `json:"context"`
+		Expected map[string]EvaluationResult `json:"expected"`
+	} `json:"cases"`
+}
+
+func loadLocalEvaluationFixture(t *testing.T) localEvaluationFixture {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/local_evaluation.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var fx localEvaluationFixture
+	if err := json.Unmarshal(raw, &fx); err != nil {
+		t.Fatalf("decoding fixture: %v", err)
+	}
+	return fx
+}
+
+func TestEvaluator_MatchesServerEvaluation(t *testing.T) {
+	fx := loadLocalEvaluationFixture(t)
+
+	for _, tc := range fx.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Context.Attributes == nil {
+				tc.Context.Attributes = map[string]any{}
+			}
+			if len(tc.Expected) != len(fx.Rules.Flags) {
+				t.Fatalf("case covers %d flags, rule set has %d", len(tc.Expected), len(fx.Rules.Flags))
+			}
+			for key, want := range tc.Expected {
+				got := fx.Rules.evaluate(key, &tc.Context)
+				if got.Variant != want.Variant || got.Reason != want.Reason || !jsonEqual(got.Value, want.Value) {
+					t.Errorf("%s: got (%v, %q, %q), server evaluates (%v, %q, %q)",
+						key, got.Value, got.Variant, got.Reason, want.Value, want.Variant, want.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluator_PollTTLFromRules(t *testing.T) {
+	fx := loadLocalEvaluationFixture(t)
+
+	got := fx.Rules.evaluate("upload-limit", &EvaluationContext{})
+	if got.PollTTLSeconds == nil || *got.PollTTLSeconds != 30 {
+		t.Errorf("PollTTLSeconds = %v, want 30", got.PollTTLSeconds)
+	}
+}
+
+func TestEvaluator_NestedSegmentNeverMatches(t *testing.T) {
+	rs := &ruleSet{
+		Segments: map[string][]ruleCondition{
+			"outer": {{Operator: "in_segment", Value: "inner"}},
+			"inner": {{Attribute: "plan", Operator: "equals", Value: "pro"}},
+		},
+	}
+	ctx := &EvaluationContext{Attributes: map[string]any{"plan": "pro"}}
+
+	if !rs.matchesAllConditions([]ruleCondition{{Operator: "in_segment", Value: "inner"}}, ctx, false) {
+		t.Error("expected inner segment to match")
+	}
+	if rs.matchesAllConditions([]ruleCondition{{Operator: "in_segment", Value: "outer"}}, ctx, false) {
+		t.Error("expected segment referencing another segment not to match")
+	}
+	if rs.matchesAllConditions([]ruleCondition{{Operator: "in_segment", Value: "missing"}}, ctx, false) {
+		t.Error("expected unknown segment not to match")
+	}
+}
+
+func TestEvaluator_DottedAttributePath(t *testing.T) {
+	rs := &ruleSet{}
+	conds := []ruleCondition{{Attribute: "device.os", Operator: "equals", Value: "ios"}}
+
+	nested := &EvaluationContext{Attributes: map[string]any{"device": map[string]any{"os": "ios"}}}
+	if !rs.matchesAllConditions(conds, nested, false) {
+		t.Error("expected device.os to match the nested attribute")
+	}
+	missing := &EvaluationContext{Attributes: map[string]any{"device": map[string]any{}}}
+	if rs.matchesAllConditions(conds, missing, false) {
+		t.Error("expected a missing nested attribute not to match")
+	}
+}
+
+func TestEvaluator_ConditionGroup(t *testing.T) {
+	rs := &ruleSet{}
+	// (country=US OR country=CA) AND plan=pro
+	group := &ruleGroup{
+		Operator:   "and",
+		Conditions: []ruleCondition{{Attribute: "plan", Operator: "equals", Value: "pro"}},
+		Groups: []ruleGroup{{
+			Operator: "or",
+			Conditions: []ruleCondition{
+				{Attribute: "country", Operator: "equals", Value: "US"},
+				{Attribute: "country", Operator: "equals", Value: "CA"},
+			},
+		}},
+	}
+
+	tests := []struct {
+		country, plan string
+		want          bool
+	}{
+		{"US", "pro", true},
+		{"CA", "pro", true},
+		{"UK", "pro", false},
+		{"US", "free", false},
+	}
+	for _, tt := range tests {
+		ctx := &EvaluationContext{Attributes: map[string]any{"country": tt.country, "plan": tt.plan}}
+		if got := rs.matchesGroup(group, ctx); got != tt.want {
+			t.Errorf("country %s, plan %s: matchesGroup = %v, want %v", tt.country, tt.plan, got, tt.want)
+		}
+	}
+}
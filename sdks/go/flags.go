@@ -5,48 +5,39 @@ import "encoding/json"
 // BoolValue returns the boolean value of the named flag, or defaultValue
 // if the flag is missing or not a boolean.
 func (c *Client) BoolValue(key string, defaultValue bool) bool {
-	c.flagsMu.RLock()
-	defer c.flagsMu.RUnlock()
-	result, ok := c.flags[key]
-	if !ok {
-		return defaultValue
-	}
+	c.beforeEvaluation(key)
+	result, _ := c.cached(key)
 	v, ok := result.Value.(bool)
 	if !ok {
-		return defaultValue
+		v, result = defaultValue, EvaluationResult{Value: defaultValue}
 	}
+	c.afterEvaluation(key, result)
 	return v
 }
 
 // StringValue returns the string value of the named flag, or defaultValue
 // if the flag is missing or not a string.
 func (c *Client) StringValue(key string, defaultValue string) string {
-	c.flagsMu.RLock()
-	defer c.flagsMu.RUnlock()
-	result, ok := c.flags[key]
-	if !ok {
-		return defaultValue
-	}
+	c.beforeEvaluation(key)
+	result, _ := c.cached(key)
 	v, ok := result.Value.(string)
 	if !ok {
-		return defaultValue
+		v, result = defaultValue, EvaluationResult{Value: defaultValue}
 	}
+	c.afterEvaluation(key, result)
 	return v
 }
 
 // NumberValue returns the float64 value of the named flag, or defaultValue
 // if the flag is missing or not a number.
 func (c *Client) NumberValue(key string, defaultValue float64) float64 {
-	c.flagsMu.RLock()
-	defer c.flagsMu.RUnlock()
-	result, ok := c.flags[key]
-	if !ok {
-		return defaultValue
-	}
+	c.beforeEvaluation(key)
+	result, _ := c.cached(key)
 	v, ok := result.Value.(float64)
 	if !ok {
-		return defaultValue
+		v, result = defaultValue, EvaluationResult{Value: defaultValue}
 	}
+	c.afterEvaluation(key, result)
 	return v
 }
 
@@ -54,27 +45,49 @@ func (c *Client) NumberValue(key string, defaultValue float64) float64 {
 // missing, defaultValue is used instead. Returns an error if marshaling
 // or unmarshaling fails.
 func (c *Client) JSONValue(key string, target any, defaultValue any) error {
-	c.flagsMu.RLock()
-	result, ok := c.flags[key]
-	c.flagsMu.RUnlock()
-
-	var src any
-	if ok {
-		src = result.Value
-	} else {
-		src = defaultValue
+	c.beforeEvaluation(key)
+	result, ok := c.cached(key)
+	if !ok {
+		result = EvaluationResult{Value: defaultValue}
 	}
 
-	data, err := json.Marshal(src)
+	data, err := json.Marshal(result.Value)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, target)
+	err = json.Unmarshal(data, target)
+	c.afterEvaluation(key, result)
+	return err
 }
 
 // Detail returns the full EvaluationResult for a flag. The second return
 // value is false if the flag does not exist in the cache.
 func (c *Client) Detail(key string) (EvaluationResult, bool) {
+	c.beforeEvaluation(key)
+	result, ok := c.cached(key)
+	c.afterEvaluation(key, result)
+	return result, ok
+}
+
+// Variant returns the named flag's value as a T together with the variant
+// served. If the flag is missing or its value is not a T, it returns
+// defaultVal and an empty variant. Values decoded from JSON are bool,
+// string, float64, map[string]any or []any. It is a function rather than a
+// method because Go methods cannot have type parameters.
+func Variant[T any](c *Client, key string, defaultVal T) (T, string) {
+	c.beforeEvaluation(key)
+	result, _ := c.cached(key)
+	v, ok := result.Value.(T)
+	if !ok {
+		v, result = defaultVal, EvaluationResult{Value: defaultVal}
+	}
+	c.afterEvaluation(key, result)
+	return v, result.Variant
+}
+
+// cached returns a copy of the cached result for key. The second return
+// value is false if the flag does not exist in the cache.
+func (c *Client) cached(key string) (EvaluationResult, bool) {
 	c.flagsMu.RLock()
 	defer c.flagsMu.RUnlock()
 	result, ok := c.flags[key]
@@ -83,3 +96,28 @@ func (c *Client) Detail(key string) (EvaluationResult, bool) {
 	}
 	return *result, true
 }
+
+// VariantName returns the variant key served for the named flag, or an
+// empty string if the flag does not exist in the cache.
+func (c *Client) VariantName(key string) string {
+	c.flagsMu.RLock()
+	defer c.flagsMu.RUnlock()
+	result, ok := c.flags[key]
+	if !ok {
+		return ""
+	}
+	return result.Variant
+}
+
+// AllFlags returns a snapshot of every cached flag result, keyed by flag
+// key. The snapshot reflects the most recent fetch or stream update; it is
+// a copy, so modifying it does not affect the client's state.
+func (c *Client) AllFlags() map[string]EvaluationResult {
+	c.flagsMu.RLock()
+	defer c.flagsMu.RUnlock()
+	snapshot := make(map[string]EvaluationResult, len(c.flags))
+	for k, v := range c.flags {
+		snapshot[k] = *v
+	}
+	return snapshot
+}
@@ -0,0 +1,70 @@
+package togglerino
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Hook observes flag evaluations. Either callback may be nil.
+//
+// For each evaluation, the BeforeEvaluation callbacks of all hooks run in
+// the order the hooks are listed in Config.Hooks, then the value is
+// resolved, then the AfterEvaluation callbacks run in the same order. Hooks
+// run synchronously on the calling goroutine, so they should be fast.
+//
+// AfterEvaluation receives a copy of the result: for a missing flag, or one
+// whose value has the wrong type for the getter, Value is the default the
+// getter returns and Variant and Reason are empty. Changing the copy does
+// not affect the returned value or the cache. A panic in a hook is
+// recovered and reported to OnError listeners; the remaining hooks still
+// run.
+type Hook struct {
+	BeforeEvaluation func(flagKey string)
+	AfterEvaluation  func(flagKey string, result EvaluationResult)
+}
+
+// beforeEvaluation runs the BeforeEvaluation callbacks for key.
+func (c *Client) beforeEvaluation(key string) {
+	for _, h := range c.config.hooks {
+		if h.BeforeEvaluation != nil {
+			c.runHook(key, func() { h.BeforeEvaluation(key) })
+		}
+	}
+}
+
+// afterEvaluation runs the AfterEvaluation callbacks for key, each with its
+// own copy of result.
+func (c *Client) afterEvaluation(key string, result EvaluationResult) {
+	for _, h := range c.config.hooks {
+		if h.AfterEvaluation != nil {
+			r := result
+			r.Value = copyValue(result.Value)
+			c.runHook(key, func() { h.AfterEvaluation(key, r) })
+		}
+	}
+}
+
+func (c *Client) runHook(key string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.events.emit(eventError, fmt.Errorf("togglerino: hook panicked evaluating %q: %v", key, r))
+		}
+	}()
+	fn()
+}
+
+// copyValue returns a deep copy of a decoded JSON value, so a hook changing
+// an object or array value cannot change the cached one.
+func copyValue(v any) any {
+	switch v.(type) {
+	case map[string]any, []any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var cp any
+		_ = json.Unmarshal(raw, &cp)
+		return cp
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package togglerino
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hookCall struct {
+	stage  string
+	key    string
+	result EvaluationResult
+}
+
+func newHookedClient(t *testing.T, hooks ...Hook) *Client {
+	t.Helper()
+	ts := newTestServer(map[string]*EvaluationResult{
+		"dark-mode": {Value: true, Variant: "on", Reason: "rule_match"},
+		"theme":     {Value: "dark", Variant: "dark", Reason: "default"},
+		"limit":     {Value: float64(10), Variant: "ten", Reason: "default"},
+		"layout":    {Value: map[string]any{"columns": float64(2)}, Variant: "grid", Reason: "rule_match"},
+	})
+	t.Cleanup(ts.Close)
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(false),
+		Hooks:     hooks,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestHooks_FireOnEveryGetter(t *testing.T) {
+	var calls []hookCall
+	client := newHookedClient(t, Hook{
+		BeforeEvaluation: func(key string) { calls = append(calls, hookCall{stage: "before", key: key}) },
+		AfterEvaluation: func(key string, result EvaluationResult) {
+			calls = append(calls, hookCall{stage: "after", key: key, result: result})
+		},
+	})
+
+	var layout map[string]any
+	tests := []struct {
+		name  string
+		key   string
+		call  func()
+		value any
+	}{
+		{"BoolValue", "dark-mode", func() { client.BoolValue("dark-mode", false) }, true},
+		{"StringValue", "theme", func() { client.StringValue("theme", "light") }, "dark"},
+		{"NumberValue", "limit", func() { client.NumberValue("limit", 1) }, float64(10)},
+		{"JSONValue", "layout", func() { client.JSONValue("layout", &layout, nil) }, map[string]any{"columns": float64(2)}},
+		{"Detail", "theme", func() { client.Detail("theme") }, "dark"},
+		{"missing flag", "unknown", func() { client.StringValue("unknown", "fallback") }, "fallback"},
+		{"wrong type", "theme", func() { client.BoolValue("theme", true) }, true},
+	}
+	for _, tt := range tests {
+		calls = nil
+		tt.call()
+		if len(calls) != 2 || calls[0].stage != "before" || calls[1].stage != "after" {
+			t.Errorf("%s: hook calls = %+v, want before then after", tt.name, calls)
+			continue
+		}
+		if calls[0].key != tt.key || calls[1].key != tt.key {
+			t.Errorf("%s: hook keys = %q, %q, want %q", tt.name, calls[0].key, calls[1].key, tt.key)
+		}
+		if !reflect.DeepEqual(calls[1].result.Value, tt.value) {
+			t.Errorf("%s: hook value = %v, want %v", tt.name, calls[1].result.Value, tt.value)
+		}
+	}
+}
+
+func TestHooks_CannotMutateResult(t *testing.T) {
+	client := newHookedClient(t, Hook{
+		AfterEvaluation: func(key string, result EvaluationResult) {
+			if m, ok := result.Value.(map[string]any); ok {
+				m["columns"] = float64(99)
+			}
+			result.Variant = "changed"
+		},
+	})
+
+	detail, _ := client.Detail("layout")
+	if detail.Variant != "grid" || detail.Value.(map[string]any)["columns"] != float64(2) {
+		t.Errorf("Detail = %+v, want the unmodified result", detail)
+	}
+	var layout map[string]any
+	if err := client.JSONValue("layout", &layout, nil); err != nil || layout["columns"] != float64(2) {
+		t.Errorf("JSONValue = %v (%v), want columns 2", layout, err)
+	}
+}
+
+func TestHooks_PanicIsReportedAndOrderKept(t *testing.T) {
+	var order []string
+	client := newHookedClient(t,
+		Hook{AfterEvaluation: func(string, EvaluationResult) { order = append(order, "first"); panic("boom") }},
+		Hook{AfterEvaluation: func(string, EvaluationResult) { order = append(order, "second") }},
+	)
+	var errs []error
+	client.OnError(func(err error) { errs = append(errs, err) })
+
+	if !client.BoolValue("dark-mode", false) {
+		t.Error("expected the flag value despite the panicking hook")
+	}
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Errorf("hook order = %v, want first, second", order)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "boom") {
+		t.Errorf("OnError calls = %v, want one for the panic", errs)
+	}
+}
@@ -0,0 +1,171 @@
+package togglerino
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ruleSet is the response from GET /api/v1/rules: the flags, environment
+// configs and segments the server evaluates, in its own wire format.
+type ruleSet struct {
+	Flags    map[string]*ruleFlag       `json:"flags"`
+	Segments map[string][]ruleCondition `json:"segments"`
+}
+
+type ruleFlag struct {
+	Flag          ruleFlagMeta      `json:"flag"`
+	Config        ruleFlagConfig    `json:"config"`
+	UserOverrides map[string]string `json:"user_overrides,omitempty"`
+}
+
+type ruleFlagMeta struct {
+	Key               string          `json:"key"`
+	DefaultValue      json.RawMessage `json:"default_value"`
+	PollTTLSeconds    *int            `json:"poll_ttl_seconds"`
+	RequireIdentifier bool            `json:"require_identifier"`
+	LifecycleStatus   string          `json:"lifecycle_status"`
+}
+
+type ruleFlagConfig struct {
+	Enabled         bool               `json:"enabled"`
+	DefaultVariant  string             `json:"default_variant"`
+	Variants        []ruleVariant      `json:"variants"`
+	TargetingRules  []targetingRule    `json:"targeting_rules"`
+	Prerequisites   []rulePrerequisite `json:"prerequisites"`
+	DefaultRollout  []variantWeight    `json:"default_rollout"`
+	IncludedUsers   []string           `json:"included_users"`
+	ExcludedUsers   []string           `json:"excluded_users"`
+	IncludedVariant string             `json:"included_variant"`
+}
+
+type ruleVariant struct {
+	Key       string                     `json:"key"`
+	Value     json.RawMessage            `json:"value"`
+	Localized map[string]json.RawMessage `json:"localized,omitempty"`
+}
+
+type targetingRule struct {
+	Conditions        []ruleCondition `json:"conditions"`
+	Group             *ruleGroup      `json:"group,omitempty"`
+	Negate            bool            `json:"negate,omitempty"`
+	Variant           string          `json:"variant"`
+	PercentageRollout *int            `json:"percentage_rollout,omitempty"`
+	VariantWeights    []variantWeight `json:"variant_weights,omitempty"`
+	BucketBy          string          `json:"bucket_by,omitempty"`
+	Salt              string          `json:"salt,omitempty"`
+}
+
+type variantWeight struct {
+	Variant string `json:"variant"`
+	Weight  int    `json:"weight"`
+}
+
+type ruleCondition struct {
+	Attribute string `json:"attribute"`
+	Operator  string `json:"operator"`
+	Value     any    `json:"value"`
+}
+
+// ruleGroup combines conditions and nested groups with "and" or "or".
+type ruleGroup struct {
+	Operator   string          `json:"operator"`
+	Conditions []ruleCondition `json:"conditions,omitempty"`
+	Groups     []ruleGroup     `json:"groups,omitempty"`
+}
+
+type rulePrerequisite struct {
+	FlagKey string `json:"flag_key"`
+	Variant string `json:"variant"`
+}
+
+// fetchRules performs a GET /api/v1/rules request, replaces the local rule
+// set, and re-evaluates every flag against the current context.
+func (c *Client) fetchRules(ctx context.Context) error {
+	url := c.config.serverURL + "/api/v1/rules"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("togglerino: failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.sdkKey)
+	c.setTraceparent(req)
+
+	resp, err := c.config.httpClient.Do(req)
+	if err != nil {
+		c.setLastError(err)
+		c.events.emit(eventError, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("togglerino: fetching rules failed with status %d", resp.StatusCode)
+		c.setLastError(err)
+		c.events.emit(eventError, err)
+		return err
+	}
+
+	var rules ruleSet
+	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil {
+		err = fmt.Errorf("togglerino: failed to decode rules: %w", err)
+		c.setLastError(err)
+		return err
+	}
+
+	c.flagsMu.Lock()
+	c.rules = &rules
+	c.flagsMu.Unlock()
+
+	c.evaluateLocally()
+	return nil
+}
+
+// evaluateLocally evaluates every flag of the local rule set against the
+// current context and replaces the flag cache with the results.
+func (c *Client) evaluateLocally() {
+	c.flagsMu.RLock()
+	rules := c.rules
+	evalCtx := c.config.context
+	evalCtx.Attributes = normalizeAttributes(evalCtx.Attributes)
+	c.flagsMu.RUnlock()
+
+	flags := make(map[string]*EvaluationResult)
+	if rules != nil {
+		for key := range rules.Flags {
+			flags[key] = rules.evaluate(key, &evalCtx)
+		}
+	}
+	c.replaceFlags(flags)
+}
+
+// DetailFor evaluates the named flag for evalCtx instead of the client's
+// context. It needs local evaluation; without it, the cached result for the
+// client's context is returned. Unlike Detail, it does not call hooks. The
+// second return value is false if the flag does not exist.
+func (c *Client) DetailFor(key string, evalCtx EvaluationContext) (EvaluationResult, bool) {
+	c.flagsMu.RLock()
+	rules := c.rules
+	c.flagsMu.RUnlock()
+	if !c.config.localEvaluation || rules == nil {
+		return c.cached(key)
+	}
+	if _, ok := rules.Flags[key]; !ok {
+		return EvaluationResult{}, false
+	}
+	evalCtx.Attributes = normalizeAttributes(evalCtx.Attributes)
+	return *rules.evaluate(key, &evalCtx), true
+}
+
+// normalizeAttributes round-trips attributes through JSON so conditions see
+// the same value types the server would after decoding a request body.
+func normalizeAttributes(attrs map[string]any) map[string]any {
+	raw, _ := json.Marshal(attrs)
+	var normalized map[string]any
+	_ = json.Unmarshal(raw, &normalized)
+	if normalized == nil {
+		normalized = map[string]any{}
+	}
+	return normalized
+}
@@ -0,0 +1,160 @@
+package togglerino
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// localRulesServer serves /api/v1/rules from a mutable rule set and counts
+// requests per path. If sseData is set, it is sent once on the stream.
+type localRulesServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	rules    ruleSet
+	requests map[string]int
+}
+
+func newLocalRulesServer(rules ruleSet, sseData string) *localRulesServer {
+	ts := &localRulesServer{rules: rules, requests: make(map[string]int)}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ts.requests[r.URL.Path]++
+		ts.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/api/v1/rules":
+			ts.mu.Lock()
+			defer ts.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ts.rules)
+		case "/api/v1/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			flusher, _ := w.(http.Flusher)
+			fmt.Fprint(w, ": connected\n\n")
+			flusher.Flush()
+			if sseData != "" {
+				time.Sleep(100 * time.Millisecond)
+				fmt.Fprint(w, sseData)
+				flusher.Flush()
+			}
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts
+}
+
+func (ts *localRulesServer) requestCount(path string) int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests[path]
+}
+
+func (ts *localRulesServer) setRules(rules ruleSet) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.rules = rules
+}
+
+// planRules returns a rule set with one flag, "pro-features", that is on for
+// the given plan.
+func planRules(plan string) ruleSet {
+	return ruleSet{
+		Flags: map[string]*ruleFlag{
+			"pro-features": {
+				Flag: ruleFlagMeta{Key: "pro-features", DefaultValue: json.RawMessage(`false`), LifecycleStatus: "active"},
+				Config: ruleFlagConfig{
+					Enabled:        true,
+					DefaultVariant: "off",
+					Variants:       []ruleVariant{{Key: "on", Value: json.RawMessage(`true`)}, {Key: "off", Value: json.RawMessage(`false`)}},
+					TargetingRules: []targetingRule{{
+						Conditions: []ruleCondition{{Attribute: "plan", Operator: "equals", Value: plan}},
+						Variant:    "on",
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestLocalEvaluation_UpdateContextWithoutRequest(t *testing.T) {
+	ts := newLocalRulesServer(planRules("pro"), "")
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL:       ts.URL,
+		SDKKey:          "sdk_test",
+		Streaming:       boolPtr(false),
+		PollingInterval: time.Hour,
+		LocalEvaluation: true,
+		Context:         &EvaluationContext{UserID: "user-1", Attributes: map[string]any{"plan": "free"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if client.BoolValue("pro-features", true) {
+		t.Error("expected pro-features off for the free plan")
+	}
+
+	var changes []FlagChangeEvent
+	client.OnChange(func(e FlagChangeEvent) { changes = append(changes, e) })
+
+	if err := client.UpdateContext(context.Background(), &EvaluationContext{Attributes: map[string]any{"plan": "pro"}}); err != nil {
+		t.Fatalf("UpdateContext() error: %v", err)
+	}
+	if !client.BoolValue("pro-features", false) {
+		t.Error("expected pro-features on after switching to the pro plan")
+	}
+	if len(changes) != 1 || changes[0].FlagKey != "pro-features" {
+		t.Errorf("change events = %v, want one for pro-features", changes)
+	}
+
+	if n := ts.requestCount("/api/v1/rules"); n != 1 {
+		t.Errorf("rules requested %d times, want 1", n)
+	}
+	if n := ts.requestCount("/api/v1/evaluate"); n != 0 {
+		t.Errorf("evaluate requested %d times, want 0", n)
+	}
+}
+
+func TestLocalEvaluation_StreamUpdateReplacesRules(t *testing.T) {
+	sseData := "event: flag_update\ndata: {\"type\":\"flag_update\",\"flagKey\":\"pro-features\"}\n\n"
+	ts := newLocalRulesServer(planRules("pro"), sseData)
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL:       ts.URL,
+		SDKKey:          "sdk_test",
+		Streaming:       boolPtr(true),
+		LocalEvaluation: true,
+		Context:         &EvaluationContext{UserID: "user-1", Attributes: map[string]any{"plan": "free"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	if client.BoolValue("pro-features", true) {
+		t.Fatal("expected pro-features off before the update")
+	}
+
+	// The rule now targets the free plan; the stream event triggers a refetch.
+	ts.setRules(planRules("free"))
+	time.Sleep(400 * time.Millisecond)
+
+	if !client.BoolValue("pro-features", false) {
+		t.Error("expected pro-features on after the stream update")
+	}
+	if n := ts.requestCount("/api/v1/rules"); n != 2 {
+		t.Errorf("rules requested %d times, want 2", n)
+	}
+}
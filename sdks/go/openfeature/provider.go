@@ -0,0 +1,237 @@
+// Package openfeature adapts a togglerino Client to the OpenFeature provider
+// API.
+//
+// The types here mirror the provider side of the OpenFeature Go SDK
+// (github.com/open-feature/go-sdk/openfeature) field for field, so wiring a
+// Provider into openfeature.SetProvider is a direct conversion. The SDK
+// module has no dependencies, and this package does not import the
+// OpenFeature SDK to keep it that way.
+package openfeature
+
+import (
+	"context"
+	"math"
+
+	togglerino "github.com/joCur/togglerino/sdks/go"
+)
+
+// TargetingKey is the flattened context key OpenFeature uses for the
+// subject of an evaluation. It becomes the togglerino user ID.
+const TargetingKey = "targetingKey"
+
+// FlattenedContext is an OpenFeature evaluation context merged into a
+// single map, as passed to a provider.
+type FlattenedContext map[string]any
+
+// Reason is an OpenFeature resolution reason.
+type Reason string
+
+const (
+	TargetingMatchReason Reason = "TARGETING_MATCH"
+	SplitReason          Reason = "SPLIT"
+	DefaultReason        Reason = "DEFAULT"
+	DisabledReason       Reason = "DISABLED"
+	UnknownReason        Reason = "UNKNOWN"
+	ErrorReason          Reason = "ERROR"
+)
+
+// ErrorCode is an OpenFeature resolution error code.
+type ErrorCode string
+
+const (
+	FlagNotFoundCode ErrorCode = "FLAG_NOT_FOUND"
+	TypeMismatchCode ErrorCode = "TYPE_MISMATCH"
+)
+
+// ResolutionError describes why a flag could not be resolved. The
+// resolution then carries the caller's default value.
+type ResolutionError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ResolutionError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// ProviderResolutionDetail is the part of a resolution common to every
+// value type. ResolutionError is nil when the flag resolved.
+type ProviderResolutionDetail struct {
+	ResolutionError *ResolutionError
+	Reason          Reason
+	Variant         string
+	FlagMetadata    map[string]any
+}
+
+type BoolResolutionDetail struct {
+	Value bool
+	ProviderResolutionDetail
+}
+
+type StringResolutionDetail struct {
+	Value string
+	ProviderResolutionDetail
+}
+
+type FloatResolutionDetail struct {
+	Value float64
+	ProviderResolutionDetail
+}
+
+type IntResolutionDetail struct {
+	Value int64
+	ProviderResolutionDetail
+}
+
+type InterfaceResolutionDetail struct {
+	Value any
+	ProviderResolutionDetail
+}
+
+// Metadata describes a provider.
+type Metadata struct {
+	Name string
+}
+
+// Provider resolves OpenFeature flag evaluations from a togglerino Client.
+// An evaluation with a non-empty context is evaluated for that context when
+// the client uses local evaluation; otherwise it is served from the
+// client's cache, which is evaluated for the client's own context.
+type Provider struct {
+	client *togglerino.Client
+}
+
+// NewProvider returns a Provider backed by client. The caller keeps
+// ownership of the client and closes it.
+func NewProvider(client *togglerino.Client) *Provider {
+	return &Provider{client: client}
+}
+
+// Metadata returns the provider's metadata.
+func (p *Provider) Metadata() Metadata {
+	return Metadata{Name: "togglerino"}
+}
+
+// BooleanEvaluation resolves a boolean flag.
+func (p *Provider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, flatCtx FlattenedContext) BoolResolutionDetail {
+	result, detail := p.resolve(flag, flatCtx)
+	if detail.ResolutionError != nil {
+		return BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	v, ok := result.Value.(bool)
+	if !ok {
+		return BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(flag, "boolean")}
+	}
+	return BoolResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+}
+
+// StringEvaluation resolves a string flag.
+func (p *Provider) StringEvaluation(ctx context.Context, flag string, defaultValue string, flatCtx FlattenedContext) StringResolutionDetail {
+	result, detail := p.resolve(flag, flatCtx)
+	if detail.ResolutionError != nil {
+		return StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	v, ok := result.Value.(string)
+	if !ok {
+		return StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(flag, "string")}
+	}
+	return StringResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+}
+
+// FloatEvaluation resolves a number flag.
+func (p *Provider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, flatCtx FlattenedContext) FloatResolutionDetail {
+	result, detail := p.resolve(flag, flatCtx)
+	if detail.ResolutionError != nil {
+		return FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	v, ok := result.Value.(float64)
+	if !ok {
+		return FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(flag, "number")}
+	}
+	return FloatResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+}
+
+// IntEvaluation resolves a number flag whose value is a whole number.
+func (p *Provider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, flatCtx FlattenedContext) IntResolutionDetail {
+	result, detail := p.resolve(flag, flatCtx)
+	if detail.ResolutionError != nil {
+		return IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	v, ok := result.Value.(float64)
+	if !ok || v != math.Trunc(v) || math.Abs(v) > math.MaxInt64 {
+		return IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(flag, "integer")}
+	}
+	return IntResolutionDetail{Value: int64(v), ProviderResolutionDetail: detail}
+}
+
+// ObjectEvaluation resolves a flag of any type, typically a json flag. The
+// value is the decoded JSON value.
+func (p *Provider) ObjectEvaluation(ctx context.Context, flag string, defaultValue any, flatCtx FlattenedContext) InterfaceResolutionDetail {
+	result, detail := p.resolve(flag, flatCtx)
+	if detail.ResolutionError != nil {
+		return InterfaceResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return InterfaceResolutionDetail{Value: result.Value, ProviderResolutionDetail: detail}
+}
+
+// resolve looks up flag for flatCtx and maps the result's variant and
+// reason. The togglerino reason is kept in the flag metadata as "reason".
+func (p *Provider) resolve(flag string, flatCtx FlattenedContext) (togglerino.EvaluationResult, ProviderResolutionDetail) {
+	var (
+		result togglerino.EvaluationResult
+		ok     bool
+	)
+	if len(flatCtx) == 0 {
+		result, ok = p.client.Detail(flag)
+	} else {
+		result, ok = p.client.DetailFor(flag, evaluationContext(flatCtx))
+	}
+	if !ok {
+		return result, ProviderResolutionDetail{
+			ResolutionError: &ResolutionError{Code: FlagNotFoundCode, Message: "flag " + flag + " not found"},
+			Reason:          ErrorReason,
+		}
+	}
+	return result, ProviderResolutionDetail{
+		Reason:       mapReason(result.Reason),
+		Variant:      result.Variant,
+		FlagMetadata: map[string]any{"reason": result.Reason},
+	}
+}
+
+func typeMismatch(flag, want string) ProviderResolutionDetail {
+	return ProviderResolutionDetail{
+		ResolutionError: &ResolutionError{Code: TypeMismatchCode, Message: "flag " + flag + " is not a " + want},
+		Reason:          ErrorReason,
+	}
+}
+
+// evaluationContext maps a flattened OpenFeature context to a togglerino
+// context: the targeting key becomes the user ID and every other entry an
+// attribute.
+func evaluationContext(flatCtx FlattenedContext) togglerino.EvaluationContext {
+	evalCtx := togglerino.EvaluationContext{Attributes: make(map[string]any, len(flatCtx))}
+	for k, v := range flatCtx {
+		if k == TargetingKey {
+			evalCtx.UserID, _ = v.(string)
+			continue
+		}
+		evalCtx.Attributes[k] = v
+	}
+	return evalCtx
+}
+
+// mapReason maps a togglerino evaluation reason to an OpenFeature reason.
+func mapReason(reason string) Reason {
+	switch reason {
+	case "rule_match", "rule_match_bucket_fallback", "user_override", "included", "excluded":
+		return TargetingMatchReason
+	case "default_rollout":
+		return SplitReason
+	case "default", "prerequisite_failed", "missing_identifier", "anonymous_control":
+		return DefaultReason
+	case "disabled", "archived":
+		return DisabledReason
+	}
+	return UnknownReason
+}
@@ -0,0 +1,121 @@
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	togglerino "github.com/joCur/togglerino/sdks/go"
+)
+
+// newTestClient starts a server answering path with body and returns a
+// polling client for it.
+func newTestClient(t *testing.T, path, body string, local bool) *togglerino.Client {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+
+	streaming := false
+	client, err := togglerino.New(context.Background(), togglerino.Config{
+		ServerURL:       ts.URL,
+		SDKKey:          "sdk_test",
+		Streaming:       &streaming,
+		PollingInterval: time.Hour,
+		LocalEvaluation: local,
+		Context:         &togglerino.EvaluationContext{UserID: "user-1"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestProvider_TypedEvaluations(t *testing.T) {
+	client := newTestClient(t, "/api/v1/evaluate", `{"flags": {
+		"dark-mode": {"value": true, "variant": "on", "reason": "rule_match"},
+		"theme": {"value": "dark", "variant": "dark", "reason": "default"},
+		"ratio": {"value": 0.25, "variant": "quarter", "reason": "user_override"},
+		"max-uploads": {"value": 10, "variant": "ten", "reason": "disabled"},
+		"layout": {"value": {"columns": 2}, "variant": "grid", "reason": "rule_match"}
+	}}`, false)
+	p := NewProvider(client)
+	ctx := context.Background()
+
+	if got := p.BooleanEvaluation(ctx, "dark-mode", false, nil); !got.Value || got.Variant != "on" || got.Reason != TargetingMatchReason || got.ResolutionError != nil {
+		t.Errorf("BooleanEvaluation = %+v", got)
+	}
+	if got := p.StringEvaluation(ctx, "theme", "light", nil); got.Value != "dark" || got.Variant != "dark" || got.Reason != DefaultReason {
+		t.Errorf("StringEvaluation = %+v", got)
+	}
+	if got := p.FloatEvaluation(ctx, "ratio", 1, nil); got.Value != 0.25 || got.Reason != TargetingMatchReason {
+		t.Errorf("FloatEvaluation = %+v", got)
+	}
+	if got := p.IntEvaluation(ctx, "max-uploads", 1, nil); got.Value != 10 || got.Reason != DisabledReason || got.FlagMetadata["reason"] != "disabled" {
+		t.Errorf("IntEvaluation = %+v", got)
+	}
+	got := p.ObjectEvaluation(ctx, "layout", nil, nil)
+	if !reflect.DeepEqual(got.Value, map[string]any{"columns": float64(2)}) || got.Variant != "grid" {
+		t.Errorf("ObjectEvaluation = %+v", got)
+	}
+}
+
+func TestProvider_ErrorsServeDefault(t *testing.T) {
+	client := newTestClient(t, "/api/v1/evaluate", `{"flags": {
+		"theme": {"value": "dark", "variant": "dark", "reason": "default"},
+		"ratio": {"value": 0.25, "variant": "quarter", "reason": "default"}
+	}}`, false)
+	p := NewProvider(client)
+	ctx := context.Background()
+
+	missing := p.BooleanEvaluation(ctx, "unknown", true, nil)
+	if !missing.Value || missing.Reason != ErrorReason || missing.ResolutionError == nil || missing.ResolutionError.Code != FlagNotFoundCode {
+		t.Errorf("missing flag = %+v, want default with FLAG_NOT_FOUND", missing)
+	}
+	mismatch := p.BooleanEvaluation(ctx, "theme", true, nil)
+	if !mismatch.Value || mismatch.ResolutionError == nil || mismatch.ResolutionError.Code != TypeMismatchCode {
+		t.Errorf("string flag as boolean = %+v, want default with TYPE_MISMATCH", mismatch)
+	}
+	fraction := p.IntEvaluation(ctx, "ratio", 3, nil)
+	if fraction.Value != 3 || fraction.ResolutionError == nil || fraction.ResolutionError.Code != TypeMismatchCode {
+		t.Errorf("fractional number as int = %+v, want default with TYPE_MISMATCH", fraction)
+	}
+}
+
+func TestProvider_EvaluatesForTargetingKey(t *testing.T) {
+	rules, _ := json.Marshal(map[string]any{
+		"flags": map[string]any{
+			"beta": map[string]any{
+				"flag": map[string]any{"key": "beta", "default_value": false, "lifecycle_status": "active"},
+				"config": map[string]any{
+					"enabled":         true,
+					"default_variant": "off",
+					"variants":        []any{map[string]any{"key": "on", "value": true}, map[string]any{"key": "off", "value": false}},
+				},
+				"user_overrides": map[string]any{"user-2": "on"},
+			},
+		},
+	})
+	client := newTestClient(t, "/api/v1/rules", string(rules), true)
+	p := NewProvider(client)
+	ctx := context.Background()
+
+	if got := p.BooleanEvaluation(ctx, "beta", true, nil); got.Value || got.Reason != DefaultReason {
+		t.Errorf("client context = %+v, want off by default", got)
+	}
+	got := p.BooleanEvaluation(ctx, "beta", false, FlattenedContext{TargetingKey: "user-2", "plan": "pro"})
+	if !got.Value || got.Variant != "on" || got.Reason != TargetingMatchReason {
+		t.Errorf("targeting key user-2 = %+v, want on by user override", got)
+	}
+}
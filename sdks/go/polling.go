@@ -6,17 +6,41 @@ import (
 )
 
 func (c *Client) runPolling(ctx context.Context) {
-	ticker := time.NewTicker(c.config.pollingInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(c.nextPollInterval())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			if err := c.fetchFlags(ctx); err != nil {
 				c.events.emit(eventError, err)
 			}
+			timer.Reset(c.nextPollInterval())
 		}
 	}
 }
+
+// nextPollInterval returns the smallest poll TTL hint across the current
+// flags (never below minPollingInterval), or the configured polling interval
+// when no flag carries a hint.
+func (c *Client) nextPollInterval() time.Duration {
+	c.flagsMu.RLock()
+	defer c.flagsMu.RUnlock()
+
+	interval := time.Duration(0)
+	for _, f := range c.flags {
+		if f.PollTTLSeconds == nil {
+			continue
+		}
+		ttl := time.Duration(*f.PollTTLSeconds) * time.Second
+		if interval == 0 || ttl < interval {
+			interval = ttl
+		}
+	}
+	if interval == 0 {
+		return c.config.pollingInterval
+	}
+	return max(interval, minPollingInterval)
+}
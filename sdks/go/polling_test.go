@@ -3,6 +3,7 @@ package togglerino
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -132,3 +133,113 @@ func TestPolling_EmitsChangeOnFlagUpdate(t *testing.T) {
 	}
 	changesMu.Unlock()
 }
+
+func TestPolling_HonorsMinimumTTL(t *testing.T) {
+	var fetchCount atomic.Int32
+	fastTTL, slowTTL := 1, 600
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/evaluate" {
+			fetchCount.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(evaluateResponse{Flags: map[string]*EvaluationResult{
+				"kill-switch": {Value: false, Variant: "off", Reason: "default", PollTTLSeconds: &fastTTL},
+				"slow-config": {Value: "a", Variant: "a", Reason: "default", PollTTLSeconds: &slowTTL},
+			}})
+		}
+	}))
+	defer ts.Close()
+
+	// The configured interval is far longer than the test; only the 1s TTL
+	// hint on kill-switch can trigger a poll.
+	client, err := New(context.Background(), Config{
+		ServerURL:       ts.URL,
+		SDKKey:          "sdk_test",
+		Streaming:       boolPtr(false),
+		PollingInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	if got := client.nextPollInterval(); got != time.Second {
+		t.Errorf("nextPollInterval() = %v, want 1s", got)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	client.Close()
+
+	if count := fetchCount.Load(); count < 2 {
+		t.Errorf("expected at least 2 fetches (1 initial + 1 TTL poll), got %d", count)
+	}
+}
+
+func TestNextPollInterval_FallsBackToConfiguredInterval(t *testing.T) {
+	client := newClient(resolveConfig(Config{PollingInterval: 200 * time.Millisecond}), func() {})
+	client.flags["plain"] = &EvaluationResult{Value: true}
+
+	if got := client.nextPollInterval(); got != 200*time.Millisecond {
+		t.Errorf("nextPollInterval() = %v, want 200ms", got)
+	}
+}
+
+func TestPolling_TimedOutFetchErrorsAndKeepsPolling(t *testing.T) {
+	var fetchCount atomic.Int32
+	release := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/evaluate" {
+			http.NotFound(w, r)
+			return
+		}
+		n := fetchCount.Add(1)
+		if n == 2 {
+			// Hang the first poll past the request timeout.
+			<-release
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(evaluateResponse{Flags: map[string]*EvaluationResult{
+			"dark-mode": {Value: n > 2, Variant: "on", Reason: "default"},
+		}})
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	client, err := New(context.Background(), Config{
+		ServerURL:       ts.URL,
+		SDKKey:          "sdk_test",
+		Streaming:       boolPtr(false),
+		PollingInterval: 100 * time.Millisecond,
+		RequestTimeout:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	errs := make(chan error, 10)
+	client.OnError(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected a deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the hung poll to time out with an error")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.BoolValue("dark-mode", false) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected polling to recover after the timeout, %d fetches", fetchCount.Load())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"net/http"
 	"strings"
 	"time"
@@ -26,6 +27,11 @@ func (c *Client) runSSE(ctx context.Context) {
 				c.events.emit(eventReconnected, nil)
 			}
 			retryCount = 0
+			// The last fetch failed (e.g. the client started from bootstrap
+			// data), so the stream alone would leave the flags stale.
+			if c.lastError() != nil {
+				c.fetchFlags(ctx)
+			}
 		})
 
 		if ctx.Err() != nil {
@@ -64,6 +70,10 @@ func (c *Client) connectSSE(ctx context.Context, onConnected func()) error {
 	}
 	req.Header.Set("Authorization", "Bearer "+c.config.sdkKey)
 	req.Header.Set("Accept", "text/event-stream")
+	c.setTraceparent(req)
+	if c.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", c.lastEventID)
+	}
 
 	resp, err := c.config.httpClient.Do(req)
 	if err != nil {
@@ -88,7 +98,7 @@ func (c *Client) connectSSE(ctx context.Context, onConnected func()) error {
 
 		if line == "" {
 			if data != "" {
-				c.handleSSEEvent(eventType, data)
+				c.handleSSEEvent(ctx, eventType, data)
 			}
 			eventType = ""
 			data = ""
@@ -99,7 +109,9 @@ func (c *Client) connectSSE(ctx context.Context, onConnected func()) error {
 			continue // comment/keepalive
 		}
 
-		if strings.HasPrefix(line, "event:") {
+		if strings.HasPrefix(line, "id:") {
+			c.lastEventID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		} else if strings.HasPrefix(line, "event:") {
 			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
 		} else if strings.HasPrefix(line, "data:") {
 			// Per SSE spec, multiple data: lines are concatenated with \n
@@ -115,8 +127,25 @@ func (c *Client) connectSSE(ctx context.Context, onConnected func()) error {
 	return scanner.Err()
 }
 
-func (c *Client) handleSSEEvent(eventType, data string) {
+func (c *Client) handleSSEEvent(ctx context.Context, eventType, data string) {
+	if c.config.localEvaluation {
+		// Events name the changed flag but carry no rules, so refetch the
+		// whole rule set and re-evaluate against our own context.
+		if eventType == "flag_update" || eventType == "flag_deleted" || eventType == "refetch" {
+			if err := c.fetchFlags(ctx); err != nil {
+				c.config.logger.Warn("failed to refresh rules", "error", err)
+			}
+		}
+		return
+	}
+
 	switch eventType {
+	case "refetch":
+		// The server could not replay everything missed since Last-Event-ID.
+		if err := c.fetchFlags(ctx); err != nil {
+			c.config.logger.Warn("failed to refetch flags", "error", err)
+		}
+
 	case "flag_update":
 		var evt sseEvent
 		if err := json.Unmarshal([]byte(data), &evt); err != nil {
@@ -158,10 +187,14 @@ func (c *Client) handleSSEEvent(eventType, data string) {
 	}
 }
 
+// retryDelay returns the delay before reconnect attempt retryCount+1: a
+// random duration between the base delay and base*2^retryCount, capped at
+// the maximum delay.
 func (c *Client) retryDelay(retryCount int) time.Duration {
-	delay := defaultBaseRetryDelay * time.Duration(math.Pow(2, float64(retryCount)))
-	if delay > defaultMaxRetryDelay {
-		delay = defaultMaxRetryDelay
+	base, maxDelay := c.config.baseRetryDelay, c.config.maxRetryDelay
+	ceiling := maxDelay
+	if d := float64(base) * math.Pow(2, float64(retryCount)); d < float64(maxDelay) {
+		ceiling = time.Duration(d)
 	}
-	return delay
+	return base + rand.N(ceiling-base+1)
 }
@@ -309,3 +309,113 @@ func TestSSE_EmitsReconnectedOnSuccess(t *testing.T) {
 	}
 	reconnectedMu.Unlock()
 }
+
+func TestSSE_ResumesWithLastEventIDAndRefetches(t *testing.T) {
+	var mu sync.Mutex
+	sseConnCount := 0
+	evaluateCount := 0
+	var resumedFrom string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/evaluate" {
+			mu.Lock()
+			evaluateCount++
+			refetched := evaluateCount > 1
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(evaluateResponse{
+				Flags: map[string]*EvaluationResult{
+					"dark-mode": {Value: refetched, Variant: "on", Reason: "default"},
+				},
+			})
+			return
+		}
+		if r.URL.Path == "/api/v1/stream" {
+			mu.Lock()
+			sseConnCount++
+			first := sseConnCount == 1
+			if !first {
+				resumedFrom = r.Header.Get("Last-Event-ID")
+			}
+			mu.Unlock()
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			flusher, _ := w.(http.Flusher)
+			fmt.Fprint(w, ": connected\n\n")
+			if first {
+				// Deliver one event, then drop the connection
+				fmt.Fprint(w, "id: 42\nevent: flag_update\ndata: {\"id\":42,\"type\":\"flag_update\",\"flagKey\":\"beta\",\"value\":true}\n\n")
+				flusher.Flush()
+				return
+			}
+			fmt.Fprint(w, "id: 99\nevent: refetch\ndata: {\"id\":99,\"type\":\"refetch\"}\n\n")
+			flusher.Flush()
+			<-r.Context().Done()
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	client, err := New(context.Background(), Config{
+		ServerURL: ts.URL,
+		SDKKey:    "sdk_test",
+		Streaming: boolPtr(true),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer client.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !client.BoolValue("dark-mode", false) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if !client.BoolValue("dark-mode", false) {
+		t.Error("expected refetch event to trigger a full flag fetch")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if resumedFrom != "42" {
+		t.Errorf("Last-Event-ID on reconnect = %q, want 42", resumedFrom)
+	}
+}
+
+func TestRetryDelay_StaysWithinBounds(t *testing.T) {
+	client := newClient(resolveConfig(Config{BaseRetryDelay: 100 * time.Millisecond, MaxRetryDelay: time.Second}), func() {})
+
+	for retry := 0; retry < 10; retry++ {
+		ceiling := 100 * time.Millisecond << retry
+		if ceiling > time.Second {
+			ceiling = time.Second
+		}
+		for i := 0; i < 50; i++ {
+			if d := client.retryDelay(retry); d < 100*time.Millisecond || d > ceiling {
+				t.Fatalf("retryDelay(%d) = %v, want between 100ms and %v", retry, d, ceiling)
+			}
+		}
+	}
+	if d := client.retryDelay(0); d != 100*time.Millisecond {
+		t.Errorf("retryDelay(0) = %v, want the base delay", d)
+	}
+}
+
+func TestRetryDelay_DefaultsAndJitter(t *testing.T) {
+	a := newClient(resolveConfig(Config{}), func() {})
+	b := newClient(resolveConfig(Config{}), func() {})
+
+	if d := a.retryDelay(20); d < defaultBaseRetryDelay || d > defaultMaxRetryDelay {
+		t.Errorf("retryDelay(20) = %v, want between %v and %v", d, defaultBaseRetryDelay, defaultMaxRetryDelay)
+	}
+
+	same := true
+	for i := 0; i < 5; i++ {
+		if a.retryDelay(5) != b.retryDelay(5) {
+			same = false
+		}
+	}
+	if same {
+		t.Error("expected two clients to pick different jittered delays")
+	}
+}
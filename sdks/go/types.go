@@ -3,6 +3,7 @@ package togglerino
 // EvaluationContext holds user identity and attributes for flag evaluation.
 type EvaluationContext struct {
 	UserID     string         `json:"user_id"`
+	Locale     string         `json:"locale,omitempty"`
 	Attributes map[string]any `json:"attributes,omitempty"`
 }
 
@@ -11,6 +12,9 @@ type EvaluationResult struct {
 	Value   any    `json:"value"`
 	Variant string `json:"variant"`
 	Reason  string `json:"reason"`
+	// PollTTLSeconds is the server's hint for how soon this flag should be
+	// re-fetched in polling mode. Nil when the flag has no hint.
+	PollTTLSeconds *int `json:"poll_ttl_seconds,omitempty"`
 }
 
 // FlagChangeEvent is emitted when a flag value changes.
@@ -34,6 +38,7 @@ type evaluateRequest struct {
 // evaluateContext is the wire format for EvaluationContext.
 type evaluateContext struct {
 	UserID     string         `json:"user_id"`
+	Locale     string         `json:"locale,omitempty"`
 	Attributes map[string]any `json:"attributes"`
 }
 
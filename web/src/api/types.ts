@@ -20,6 +20,7 @@ export interface Environment {
   project_id: string
   key: string
   name: string
+  sort_order: number
   created_at: string
 }
 
@@ -48,6 +49,7 @@ export interface Flag {
   tags: string[]
   lifecycle_status: LifecycleStatus
   lifecycle_status_changed_at: string | null
+  last_evaluated_at: string | null
   created_at: string
   updated_at: string
 }
@@ -93,6 +95,7 @@ export interface AuditEntry {
   entity_id: string
   old_value?: unknown
   new_value?: unknown
+  request_id?: string
   created_at: string
 }
 
@@ -112,5 +115,16 @@ export interface ContextAttribute {
   id: string
   project_id: string
   name: string
+  values: string[]
   last_seen_at: string
 }
+
+export interface CodeReference {
+  id: string
+  flag_id: string
+  repo: string
+  file: string
+  line: number
+  sha: string
+  updated_at: string
+}